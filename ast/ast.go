@@ -1,7 +1,6 @@
 package ast
 
 import (
-	"dyego0/errors"
 	"dyego0/location"
 	"fmt"
 )
@@ -29,10 +28,14 @@ type TypedElement interface {
 	Type() Element
 }
 
-// Literal is a rune literal
+// Literal is a literal value
 type Literal interface {
 	Element
 	Value() interface{}
+
+	// Spelling is the text of the literal in the source, such as 0xFF for a literal with the
+	// value 255. Spelling is empty for a literal that was not scanned from source.
+	Spelling() string
 }
 
 // Selection is a member selector
@@ -40,6 +43,15 @@ type Selection interface {
 	Element
 	Target() Element
 	Member() Name
+	IsSelection() bool
+}
+
+// SafeSelection is a member selector, target?.member, that is null when its target is null
+type SafeSelection interface {
+	Element
+	Target() Element
+	Member() Name
+	IsSafeSelection() bool
 }
 
 // Sequence is a sequence of expressions
@@ -113,9 +125,11 @@ type NamedMemberInitializer interface {
 	IsNamedMemberInitializer() bool
 }
 
-// Lambda is a lambda
+// Lambda is a lambda. The type parameters of a generic lambda, such as X of { X | x: X -> x },
+// are given before the parameters.
 type Lambda interface {
 	Element
+	TypeParameters() []TypeParameter
 	Parameters() []Parameter
 	Body() Element
 	Result() Element
@@ -148,6 +162,24 @@ type Parameter interface {
 	IsParameter() bool
 }
 
+// TypeParameter is a type parameter of a generic lambda or type literal. The constraint, if
+// given, is the type the type arguments must conform to.
+type TypeParameter interface {
+	Element
+	Name() Name
+	Constraint() Element
+	IsTypeParameter() bool
+}
+
+// Instantiation gives the type arguments of a generic lambda or type, such as Box<Int> or the
+// <Int> of f(<Int> 1)
+type Instantiation interface {
+	Element
+	Target() Element
+	TypeArguments() []Element
+	IsInstantiation() bool
+}
+
 // Return is a return statement
 type Return interface {
 	Element
@@ -195,9 +227,11 @@ type Storage interface {
 	Mutable() bool
 }
 
-// TypeLiteral a type literal
+// TypeLiteral a type literal. The type parameters of a generic type literal, such as X of
+// < X -> value: X >, are given before the members.
 type TypeLiteral interface {
 	Element
+	TypeParameters() []TypeParameter
 	Members() []Element
 	IsTypeLiteral() bool
 }
@@ -236,6 +270,14 @@ type VocabularyLiteral interface {
 	IsVocabularyLiteral() bool
 }
 
+// Error is an element that could not be parsed. It takes the place of the element that was
+// expected and covers the tokens skipped to recover from the error.
+type Error interface {
+	Element
+	error
+	IsError() bool
+}
+
 // OperatorPlacement declares the placement of an operator
 type OperatorPlacement int
 
@@ -276,6 +318,10 @@ const (
 	// Right declares an operator to be right associative
 	Right
 
+	// NonAssociative declares an operator that cannot be chained with operators at its
+	// precedence, such as a < b < c
+	NonAssociative
+
 	// UnspecifiedAssociativity is the value of associativity when it was not specified
 	UnspecifiedAssociativity
 )
@@ -286,6 +332,8 @@ func (a OperatorAssociativity) String() string {
 		return "left"
 	case Right:
 		return "right"
+	case NonAssociative:
+		return "none"
 	default:
 		return "invalid associativity"
 	}
@@ -300,6 +348,9 @@ const (
 
 	// After indicates that the precedence is after the referenced operator
 	After
+
+	// SameAs indicates that the precedence is the same as the referenced operator
+	SameAs
 )
 
 func (r OperatorPrecedenceRelation) String() string {
@@ -308,6 +359,8 @@ func (r OperatorPrecedenceRelation) String() string {
 		return "before"
 	case After:
 		return "after"
+	case SameAs:
+		return "same as"
 	default:
 		return "invalid relation"
 	}
@@ -343,18 +396,19 @@ type Builder interface {
 	PushContext()
 	PopContext()
 	Name(value string) Name
-	Literal(value interface{}) Literal
+	Literal(value interface{}, spelling string) Literal
 	Break(label Name) Break
 	Continue(label Name) Continue
 	Sequence(left, right Element) Sequence
 	Selection(target Element, member Name) Selection
+	SafeSelection(target Element, member Name) SafeSelection
 	Spread(target Element) Spread
 	Call(target Element, arguments []Element) Call
 	NamedArgument(name Name, value Element) NamedArgument
 	ObjectInitializer(mutable bool, typ Element, members []Element) ObjectInitializer
 	ArrayInitializer(mutable bool, typ Element, elements []Element) ArrayInitializer
 	NamedMemberInitializer(name Name, typ Element, value Element) NamedMemberInitializer
-	Lambda(parameters []Parameter, body Element, result Element) Lambda
+	Lambda(typeParameters []TypeParameter, parameters []Parameter, body Element, result Element) Lambda
 	IntrinsicLambda(parameters []Parameter, body Element, result Element) IntrinsicLambda
 	Loop(label Name, body Element) Loop
 	Return(value Element) Return
@@ -362,9 +416,11 @@ type Builder interface {
 	WhenValueClause(value Element, body Element) WhenValueClause
 	WhenElseClause(body Element) WhenElseClause
 	Parameter(name Name, typ Element, deflt Element) Parameter
+	TypeParameter(name Name, constraint Element) TypeParameter
+	Instantiation(target Element, typeArguments []Element) Instantiation
 	Definition(name Name, typ Element, value Element) Definition
 	Storage(name Name, typ Element, value Element, mutable bool) Storage
-	TypeLiteral(members []Element) TypeLiteral
+	TypeLiteral(typeParameters []TypeParameter, members []Element) TypeLiteral
 	CallableTypeMember(parameters []Element, result Element) CallableTypeMember
 	SequenceType(elements Element) SequenceType
 	OptionalType(target Element) OptionalType
@@ -382,7 +438,7 @@ type Builder interface {
 		relation OperatorPrecedenceRelation,
 	) VocabularyOperatorPrecedence
 	VocabularyEmbedding(name []Name) VocabularyEmbedding
-	Error(message string, args ...interface{}) errors.Error
+	Error(message string, args ...interface{}) Error
 	Clone(context BuilderContext) Builder
 	Loc() location.Location
 }
@@ -393,6 +449,12 @@ type BuilderContext interface {
 	End() location.Pos
 }
 
+// previousEndContext is a BuilderContext that can also provide the end of the token before the
+// current token
+type previousEndContext interface {
+	PreviousEnd() location.Pos
+}
+
 type builderImpl struct {
 	context   BuilderContext
 	locations []location.Pos
@@ -411,9 +473,16 @@ func (b *builderImpl) PopContext() {
 	b.locations = b.locations[0 : len(b.locations)-1]
 }
 
+// Loc is the location of the current context. A context that starts before the current token
+// ends at the end of the previous token, if the builder context can provide it, so the
+// location does not include the token that follows the element.
 func (b *builderImpl) Loc() location.Location {
 	start := b.locations[len(b.locations)-1]
-	return location.NewLocation(start, b.context.End())
+	end := b.context.End()
+	if previous, ok := b.context.(previousEndContext); ok && start != b.context.Start() {
+		end = previous.PreviousEnd()
+	}
+	return location.NewLocation(start, end)
 }
 
 type nameImpl struct {
@@ -435,15 +504,22 @@ func (b *builderImpl) Name(text string) Name {
 
 type literalImpl struct {
 	location.Location
-	value interface{}
+	value    interface{}
+	spelling string
 }
 
 func (l *literalImpl) Value() interface{} {
 	return l.value
 }
 
+func (l *literalImpl) Spelling() string {
+	return l.spelling
+}
+
 func sOf(value interface{}) string {
 	switch v := value.(type) {
+	case nil:
+		return "null"
 	case rune:
 		return fmt.Sprintf("'%c'", v)
 	case byte:
@@ -468,8 +544,8 @@ func (l *literalImpl) String() string {
 	return fmt.Sprintf("Literal(%s, %s)", l.Location, sOf(l.value))
 }
 
-func (b *builderImpl) Literal(value interface{}) Literal {
-	return &literalImpl{Location: b.Loc(), value: value}
+func (b *builderImpl) Literal(value interface{}, spelling string) Literal {
+	return &literalImpl{Location: b.Loc(), value: value, spelling: spelling}
 }
 
 type breakImpl struct {
@@ -528,6 +604,10 @@ func (l *selectionImpl) Member() Name {
 	return l.member
 }
 
+func (l *selectionImpl) IsSelection() bool {
+	return true
+}
+
 func s(e Element) string {
 	if e == nil {
 		return "nil"
@@ -543,6 +623,32 @@ func (b *builderImpl) Selection(target Element, member Name) Selection {
 	return &selectionImpl{Location: b.Loc(), target: target, member: member}
 }
 
+type safeSelectionImpl struct {
+	location.Location
+	target Element
+	member Name
+}
+
+func (l *safeSelectionImpl) Target() Element {
+	return l.target
+}
+
+func (l *safeSelectionImpl) Member() Name {
+	return l.member
+}
+
+func (l *safeSelectionImpl) IsSafeSelection() bool {
+	return true
+}
+
+func (l *safeSelectionImpl) String() string {
+	return fmt.Sprintf("SafeSelection(%s, target: %s, member: %s)", l.Location, s(l.target), s(l.member))
+}
+
+func (b *builderImpl) SafeSelection(target Element, member Name) SafeSelection {
+	return &safeSelectionImpl{Location: b.Loc(), target: target, member: member}
+}
+
 type sequenceImpl struct {
 	location.Location
 	left  Element
@@ -788,9 +894,14 @@ func (b *builderImpl) NamedMemberInitializer(name Name, typ Element, value Eleme
 
 type lambdaImpl struct {
 	location.Location
-	parameters []Parameter
-	body       Element
-	result     Element
+	typeParameters []TypeParameter
+	parameters     []Parameter
+	body           Element
+	result         Element
+}
+
+func (l *lambdaImpl) TypeParameters() []TypeParameter {
+	return l.typeParameters
 }
 
 func (l *lambdaImpl) Parameters() []Parameter {
@@ -822,13 +933,29 @@ func parametersToString(parameters []Parameter) string {
 	return elementsToString(LowerParameters(parameters))
 }
 
-func (l *lambdaImpl) String() string {
-	return fmt.Sprintf("Lambda(%s, parameters: %s, body: %s, result: %s)", l.Location,
-		parametersToString(l.parameters), s(l.body), s(l.result))
+// LowerTypeParameters converts a []TypeParameter to []Element
+func LowerTypeParameters(typeParameters []TypeParameter) []Element {
+	var result = make([]Element, len(typeParameters))
+	for i, e := range typeParameters {
+		result[i] = e
+	}
+	return result
 }
 
-func (b *builderImpl) Lambda(parameters []Parameter, body Element, ret Element) Lambda {
-	return &lambdaImpl{Location: b.Loc(), parameters: parameters, body: body}
+func (l *lambdaImpl) String() string {
+	return fmt.Sprintf("Lambda(%s, typeParameters: %s, parameters: %s, body: %s, result: %s)", l.Location,
+		elementsToString(LowerTypeParameters(l.typeParameters)), parametersToString(l.parameters), s(l.body),
+		s(l.result))
+}
+
+func (b *builderImpl) Lambda(typeParameters []TypeParameter, parameters []Parameter, body Element, ret Element) Lambda {
+	return &lambdaImpl{
+		Location:       b.Loc(),
+		typeParameters: typeParameters,
+		parameters:     parameters,
+		body:           body,
+		result:         ret,
+	}
 }
 
 type intrinsicLambdaImpl struct {
@@ -920,6 +1047,59 @@ func (b *builderImpl) Parameter(name Name, typ Element, deflt Element) Parameter
 	return &parameterImpl{Location: b.Loc(), name: name, typ: typ, deflt: deflt}
 }
 
+type typeParameterImpl struct {
+	location.Location
+	name       Name
+	constraint Element
+}
+
+func (p *typeParameterImpl) Name() Name {
+	return p.name
+}
+
+func (p *typeParameterImpl) Constraint() Element {
+	return p.constraint
+}
+
+func (p *typeParameterImpl) IsTypeParameter() bool {
+	return true
+}
+
+func (p *typeParameterImpl) String() string {
+	return fmt.Sprintf("TypeParameter(%s, name: %s, constraint: %s)", p.Location, s(p.name), s(p.constraint))
+}
+
+func (b *builderImpl) TypeParameter(name Name, constraint Element) TypeParameter {
+	return &typeParameterImpl{Location: b.Loc(), name: name, constraint: constraint}
+}
+
+type instantiationImpl struct {
+	location.Location
+	target        Element
+	typeArguments []Element
+}
+
+func (i *instantiationImpl) Target() Element {
+	return i.target
+}
+
+func (i *instantiationImpl) TypeArguments() []Element {
+	return i.typeArguments
+}
+
+func (i *instantiationImpl) IsInstantiation() bool {
+	return true
+}
+
+func (i *instantiationImpl) String() string {
+	return fmt.Sprintf("Instantiation(%s, target: %s, typeArguments: %s)", i.Location, s(i.target),
+		elementsToString(i.typeArguments))
+}
+
+func (b *builderImpl) Instantiation(target Element, typeArguments []Element) Instantiation {
+	return &instantiationImpl{Location: b.Loc(), target: target, typeArguments: typeArguments}
+}
+
 type returnImpl struct {
 	location.Location
 	value Element
@@ -1076,7 +1256,12 @@ func (b *builderImpl) Definition(name Name, typ, value Element) Definition {
 
 type typeLiteralImpl struct {
 	location.Location
-	members []Element
+	typeParameters []TypeParameter
+	members        []Element
+}
+
+func (t *typeLiteralImpl) TypeParameters() []TypeParameter {
+	return t.typeParameters
 }
 
 func (t *typeLiteralImpl) Members() []Element {
@@ -1088,11 +1273,12 @@ func (t *typeLiteralImpl) IsTypeLiteral() bool {
 }
 
 func (t *typeLiteralImpl) String() string {
-	return fmt.Sprintf("TypeLiteral(%s, members: %s)", t.Location, elementsToString(t.members))
+	return fmt.Sprintf("TypeLiteral(%s, typeParameters: %s, members: %s)", t.Location,
+		elementsToString(LowerTypeParameters(t.typeParameters)), elementsToString(t.members))
 }
 
-func (b *builderImpl) TypeLiteral(members []Element) TypeLiteral {
-	return &typeLiteralImpl{Location: b.Loc(), members: members}
+func (b *builderImpl) TypeLiteral(typeParameters []TypeParameter, members []Element) TypeLiteral {
+	return &typeLiteralImpl{Location: b.Loc(), typeParameters: typeParameters, members: members}
 }
 
 type callableTypeMemberImpl struct {
@@ -1270,8 +1456,30 @@ func (b *builderImpl) VocabularyEmbedding(name []Name) VocabularyEmbedding {
 	return &vocabularyEmbeddingImpl{Location: b.Loc(), name: name}
 }
 
-func (b *builderImpl) Error(message string, args ...interface{}) errors.Error {
-	return errors.New(b.Loc(), message, args...)
+type errorImpl struct {
+	location.Location
+	message string
+}
+
+func (e *errorImpl) Error() string {
+	return e.message
+}
+
+func (e *errorImpl) IsError() bool {
+	return true
+}
+
+func (e *errorImpl) String() string {
+	return fmt.Sprintf("Error(%s, %s)", e.Location, e.message)
+}
+
+// NewError creates an error element located at loc
+func NewError(loc location.Locatable, message string, args ...interface{}) Error {
+	return &errorImpl{Location: location.NewLocation(loc.Start(), loc.End()), message: fmt.Sprintf(message, args...)}
+}
+
+func (b *builderImpl) Error(message string, args ...interface{}) Error {
+	return NewError(b.Loc(), message, args...)
 }
 
 func (b *builderImpl) Clone(context BuilderContext) Builder {
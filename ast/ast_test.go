@@ -25,17 +25,19 @@ var _ = Describe("ast", func() {
 			Expect(s(n)).To(Equal("Name(Location(0-1), text)"))
 		})
 		It("Literal", func() {
-			n := b.Literal('a')
+			n := b.Literal('a', "'a'")
 			Expect(n.Value()).To(Equal('a'))
+			Expect(n.Spelling()).To(Equal("'a'"))
 			Expect(s(n)).To(Equal("Literal(Location(0-1), 'a')"))
-			Expect(s(b.Literal(1))).To(Equal("Literal(Location(0-1), 1)"))
-			Expect(s(b.Literal(byte(1)))).To(Equal("Literal(Location(0-1), 1b)"))
-			Expect(s(b.Literal(uint(1)))).To(Equal("Literal(Location(0-1), 1u)"))
-			Expect(s(b.Literal(int64(1)))).To(Equal("Literal(Location(0-1), 1l)"))
-			Expect(s(b.Literal(float32(1.0)))).To(Equal("Literal(Location(0-1), 1.000000f)"))
-			Expect(s(b.Literal(1.0))).To(Equal("Literal(Location(0-1), 1.000000)"))
-			Expect(s(b.Literal("a"))).To(Equal("Literal(Location(0-1), \"a\")"))
-			Expect(s(b.Literal(uintptr(1)))).To(Equal("Literal(Location(0-1), %!s(uintptr=1))"))
+			Expect(s(b.Literal(1, "1"))).To(Equal("Literal(Location(0-1), 1)"))
+			Expect(s(b.Literal(byte(1), "1ub"))).To(Equal("Literal(Location(0-1), 1b)"))
+			Expect(s(b.Literal(uint(1), "1u"))).To(Equal("Literal(Location(0-1), 1u)"))
+			Expect(s(b.Literal(int64(1), "1l"))).To(Equal("Literal(Location(0-1), 1l)"))
+			Expect(s(b.Literal(float32(1.0), "1.0f"))).To(Equal("Literal(Location(0-1), 1.000000f)"))
+			Expect(s(b.Literal(1.0, "1.0"))).To(Equal("Literal(Location(0-1), 1.000000)"))
+			Expect(s(b.Literal("a", `"a"`))).To(Equal("Literal(Location(0-1), \"a\")"))
+			Expect(s(b.Literal(nil, "null"))).To(Equal("Literal(Location(0-1), null)"))
+			Expect(s(b.Literal(uintptr(1), ""))).To(Equal("Literal(Location(0-1), %!s(uintptr=1))"))
 		})
 		It("Break", func() {
 			n := b.Break(nil)
@@ -53,8 +55,16 @@ var _ = Describe("ast", func() {
 			l := b.Selection(nil, nil)
 			Expect(l.Target()).To(BeNil())
 			Expect(l.Member()).To(BeNil())
+			Expect(l.IsSelection()).To(Equal(true))
 			Expect(s(l)).To(Equal("Selection(Location(0-1), target: nil, member: nil)"))
 		})
+		It("SafeSelection", func() {
+			l := b.SafeSelection(nil, nil)
+			Expect(l.Target()).To(BeNil())
+			Expect(l.Member()).To(BeNil())
+			Expect(l.IsSafeSelection()).To(Equal(true))
+			Expect(s(l)).To(Equal("SafeSelection(Location(0-1), target: nil, member: nil)"))
+		})
 		It("Sequence", func() {
 			n := b.Sequence(nil, nil)
 			Expect(n.Left()).To(BeNil())
@@ -70,7 +80,7 @@ var _ = Describe("ast", func() {
 		})
 		It("Call", func() {
 			var args []ast.Element
-			args = append(args, b.Literal(1), b.Literal(2))
+			args = append(args, b.Literal(1, "1"), b.Literal(2, "2"))
 			l := b.Call(nil, args)
 			Expect(l.Target()).To(BeNil())
 			Expect(l.Arguments()).To(Equal(args))
@@ -108,11 +118,12 @@ var _ = Describe("ast", func() {
 			Expect(s(l)).To(Equal("NamedMemberInitializer(Location(0-1), name: Name(Location(0-1), name), type: nil, value: nil)"))
 		})
 		It("Lambda", func() {
-			l := b.Lambda(nil, nil, nil)
+			l := b.Lambda(nil, nil, nil, nil)
+			Expect(l.TypeParameters()).To(BeNil())
 			Expect(l.Parameters()).To(BeNil())
 			Expect(l.Body()).To(BeNil())
 			Expect(l.Result()).To(BeNil())
-			Expect(s(l)).To(Equal("Lambda(Location(0-1), parameters: [], body: nil, result: nil)"))
+			Expect(s(l)).To(Equal("Lambda(Location(0-1), typeParameters: [], parameters: [], body: nil, result: nil)"))
 		})
 		It("IntrinsicLambda", func() {
 			l := b.IntrinsicLambda(nil, nil, nil)
@@ -137,6 +148,21 @@ var _ = Describe("ast", func() {
 			Expect(l.IsParameter()).To(Equal(true))
 			Expect(s(l)).To(Equal("Parameter(Location(0-1), name: Name(Location(0-1), name), type: nil, default: nil)"))
 		})
+		It("TypeParameter", func() {
+			p := b.TypeParameter(b.Name("X"), nil)
+			Expect(p.Name().Text()).To(Equal("X"))
+			Expect(p.Constraint()).To(BeNil())
+			Expect(p.IsTypeParameter()).To(BeTrue())
+			Expect(s(p)).To(Equal("TypeParameter(Location(0-1), name: Name(Location(0-1), X), constraint: nil)"))
+		})
+		It("Instantiation", func() {
+			i := b.Instantiation(b.Name("Box"), []ast.Element{b.Name("Int")})
+			Expect(i.Target().(ast.Name).Text()).To(Equal("Box"))
+			Expect(i.TypeArguments()).To(HaveLen(1))
+			Expect(i.IsInstantiation()).To(BeTrue())
+			Expect(s(i)).To(Equal("Instantiation(Location(0-1), target: Name(Location(0-1), Box), " +
+				"typeArguments: [Name(Location(0-1), Int)])"))
+		})
 		It("Return", func() {
 			n := b.Return(nil)
 			Expect(n.Value()).To(BeNil())
@@ -178,10 +204,11 @@ var _ = Describe("ast", func() {
 			Expect(s(l)).To(Equal("Definition(Location(0-1), name: nil, type: nil, value: nil)"))
 		})
 		It("TypeLiteral", func() {
-			t := b.TypeLiteral(nil)
+			t := b.TypeLiteral(nil, nil)
+			Expect(t.TypeParameters()).To(BeNil())
 			Expect(t.Members()).To(BeNil())
 			Expect(t.IsTypeLiteral()).To(BeTrue())
-			Expect(s(t)).To(Equal("TypeLiteral(Location(0-1), members: [])"))
+			Expect(s(t)).To(Equal("TypeLiteral(Location(0-1), typeParameters: [], members: [])"))
 		})
 		It("CallableTypeMember", func() {
 			m := b.CallableTypeMember(nil, nil)
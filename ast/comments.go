@@ -0,0 +1,102 @@
+package ast
+
+import (
+	"dyego0/location"
+)
+
+// Comment is a comment in the source of an element
+type Comment struct {
+	location.Location
+
+	// Text is the text of the comment including its delimiters
+	Text string
+
+	// OwnLine is true if the comment is not preceded by code on the same line
+	OwnLine bool
+}
+
+// NewComment creates a comment
+func NewComment(start, end location.Pos, text string, ownLine bool) Comment {
+	return Comment{Location: location.NewLocation(start, end), Text: text, OwnLine: ownLine}
+}
+
+// Comments are the comments attached to an element. Leading comments precede the element.
+// Trailing comments follow the element on the same line or, if the element is a block that
+// contains no element after the comment, are at the end of the block.
+type Comments struct {
+	Leading  []Comment
+	Trailing []Comment
+}
+
+// CommentMap maps an element to the comments attached to it
+type CommentMap map[Element]*Comments
+
+// AttachComments attaches each comment to the nearest element of root. A comment that follows
+// code on the same line trails the outermost element that ends before it. Any other comment
+// leads the outermost element that starts after it in the innermost element that contains the
+// comment, or trails the containing element if no element follows it. Of elements with the same
+// location, the outermost is used. Comments that cannot be attached, such as the comments of an
+// empty module, are not in the map.
+func AttachComments(root Element, comments []Comment) CommentMap {
+	result := make(CommentMap)
+	c := &elementCollector{}
+	Walk(root, c)
+	for _, comment := range comments {
+		var container Element
+		for _, element := range c.elements {
+			if element.Start() <= comment.Start() && element.End() >= comment.End() &&
+				(container == nil || element.Length() < container.Length()) {
+				container = element
+			}
+		}
+		var preceding, following Element
+		for _, element := range c.elements {
+			if container != nil && (element == container || element.Start() < container.Start() ||
+				element.End() > container.End()) {
+				continue
+			}
+			if element.End() <= comment.Start() && (preceding == nil || element.End() > preceding.End()) {
+				preceding = element
+			}
+			if element.Start() >= comment.End() && (following == nil || element.Start() < following.Start()) {
+				following = element
+			}
+		}
+		switch {
+		case !comment.OwnLine && preceding != nil:
+			result.comments(preceding).Trailing = append(result.comments(preceding).Trailing, comment)
+		case following != nil:
+			result.comments(following).Leading = append(result.comments(following).Leading, comment)
+		case container != nil:
+			result.comments(container).Trailing = append(result.comments(container).Trailing, comment)
+		case preceding != nil:
+			result.comments(preceding).Trailing = append(result.comments(preceding).Trailing, comment)
+		}
+	}
+	return result
+}
+
+func (m CommentMap) comments(element Element) *Comments {
+	result, ok := m[element]
+	if !ok {
+		result = &Comments{}
+		m[element] = result
+	}
+	return result
+}
+
+// elementCollector collects the elements with a valid location in the order they are walked.
+// Sequences are not collected as they only group the elements of a block.
+type elementCollector struct {
+	elements []Element
+}
+
+func (c *elementCollector) Visit(element Element) bool {
+	if _, ok := element.(Sequence); ok {
+		return true
+	}
+	if element.Start().IsValid() && element.Start() <= element.End() {
+		c.elements = append(c.elements, element)
+	}
+	return true
+}
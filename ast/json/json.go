@@ -0,0 +1,645 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"dyego0/ast"
+	"dyego0/location"
+)
+
+// Marshal returns the JSON encoding of element. Each element is encoded as an object with its
+// kind, such as "Call", its start and end, and a field for each of its properties. Properties
+// that are nil are omitted.
+func Marshal(element ast.Element) (result []byte, err error) {
+	defer recoverError(&err)
+	return json.Marshal(encode(element))
+}
+
+// MarshalIndent is like Marshal but indents the encoding as json.MarshalIndent does
+func MarshalIndent(element ast.Element, prefix, indent string) (result []byte, err error) {
+	defer recoverError(&err)
+	return json.MarshalIndent(encode(element), prefix, indent)
+}
+
+// Unmarshal returns the element encoded in data by Marshal
+func Unmarshal(data []byte) (result ast.Element, err error) {
+	defer recoverError(&err)
+	d := &decoder{}
+	d.builder = ast.NewBuilder(&d.context)
+	d.builder.PushContext()
+	return d.element(data), nil
+}
+
+// jsonError is a panic used to unwind the encoder or decoder
+type jsonError struct {
+	err error
+}
+
+func fail(message string, args ...interface{}) {
+	panic(&jsonError{fmt.Errorf("ast/json: "+message, args...)})
+}
+
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		e, ok := r.(*jsonError)
+		if !ok {
+			panic(r)
+		}
+		*err = e.err
+	}
+}
+
+var placements = map[ast.OperatorPlacement]string{
+	ast.Infix:                "infix",
+	ast.Prefix:               "prefix",
+	ast.Postfix:              "postfix",
+	ast.UnspecifiedPlacement: "unspecified",
+}
+
+var associativities = map[ast.OperatorAssociativity]string{
+	ast.Left:                     "left",
+	ast.Right:                    "right",
+	ast.NonAssociative:           "none",
+	ast.UnspecifiedAssociativity: "unspecified",
+}
+
+var relations = map[ast.OperatorPrecedenceRelation]string{
+	ast.Before: "before",
+	ast.After:  "after",
+	ast.SameAs: "same as",
+}
+
+// field is a field of an encoded object
+type field struct {
+	key   string
+	value interface{}
+}
+
+// object is an encoded element which, unlike a map, keeps its fields in order. Fields with a
+// nil value are omitted.
+type object []field
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var result bytes.Buffer
+	result.WriteByte('{')
+	first := true
+	for _, f := range o {
+		if f.value == nil {
+			continue
+		}
+		if !first {
+			result.WriteByte(',')
+		}
+		first = false
+		key, _ := json.Marshal(f.key)
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		result.Write(key)
+		result.WriteByte(':')
+		result.Write(value)
+	}
+	result.WriteByte('}')
+	return result.Bytes(), nil
+}
+
+// node encodes element as an object of kind with fields
+func node(element ast.Element, kind string, fields ...field) object {
+	return append(object{{"kind", kind}, {"start", int(element.Start())}, {"end", int(element.End())}}, fields...)
+}
+
+func encode(element ast.Element) interface{} {
+	if element == nil {
+		return nil
+	}
+	switch e := element.(type) {
+	case ast.Name:
+		return node(e, "Name", field{"text", e.Text()})
+	case ast.Literal:
+		valueType, value := encodeValue(e.Value())
+		return node(e, "Literal", field{"valueType", valueType}, field{"value", value},
+			field{"spelling", e.Spelling()})
+	case ast.Selection:
+		return node(e, "Selection", field{"target", encode(e.Target())}, field{"member", encode(e.Member())})
+	case ast.SafeSelection:
+		return node(e, "SafeSelection", field{"target", encode(e.Target())}, field{"member", encode(e.Member())})
+	case ast.Sequence:
+		return node(e, "Sequence", field{"left", encode(e.Left())}, field{"right", encode(e.Right())})
+	case ast.Spread:
+		return node(e, "Spread", field{"target", encode(e.Target())})
+	case ast.Break:
+		return node(e, "Break", field{"label", encode(e.Label())})
+	case ast.Call:
+		return node(e, "Call", field{"target", encode(e.Target())}, field{"arguments", encodeElements(e.Arguments())})
+	case ast.Continue:
+		return node(e, "Continue", field{"label", encode(e.Label())})
+	case ast.NamedArgument:
+		return node(e, "NamedArgument", field{"name", encode(e.Name())}, field{"value", encode(e.Value())})
+	case ast.ObjectInitializer:
+		return node(e, "ObjectInitializer", field{"mutable", e.Mutable()}, field{"type", encode(e.Type())},
+			field{"members", encodeElements(e.Members())})
+	case ast.ArrayInitializer:
+		return node(e, "ArrayInitializer", field{"mutable", e.Mutable()}, field{"type", encode(e.Type())},
+			field{"elements", encodeElements(e.Elements())})
+	case ast.NamedMemberInitializer:
+		return node(e, "NamedMemberInitializer", field{"name", encode(e.Name())},
+			field{"type", encode(e.Type())}, field{"value", encode(e.Value())})
+	case ast.Lambda:
+		return node(e, "Lambda", field{"typeParameters", encodeTypeParameters(e.TypeParameters())},
+			field{"parameters", encodeParameters(e.Parameters())}, field{"body", encode(e.Body())},
+			field{"result", encode(e.Result())})
+	case ast.IntrinsicLambda:
+		return node(e, "IntrinsicLambda", field{"parameters", encodeParameters(e.Parameters())},
+			field{"body", encode(e.Body())}, field{"result", encode(e.Result())})
+	case ast.Loop:
+		return node(e, "Loop", field{"label", encode(e.Label())}, field{"body", encode(e.Body())})
+	case ast.Parameter:
+		return node(e, "Parameter", field{"name", encode(e.Name())}, field{"type", encode(e.Type())},
+			field{"default", encode(e.Default())})
+	case ast.TypeParameter:
+		return node(e, "TypeParameter", field{"name", encode(e.Name())}, field{"constraint", encode(e.Constraint())})
+	case ast.Instantiation:
+		return node(e, "Instantiation", field{"target", encode(e.Target())},
+			field{"typeArguments", encodeElements(e.TypeArguments())})
+	case ast.Return:
+		return node(e, "Return", field{"value", encode(e.Value())})
+	case ast.When:
+		return node(e, "When", field{"target", encode(e.Target())}, field{"clauses", encodeElements(e.Clauses())})
+	case ast.WhenValueClause:
+		return node(e, "WhenValueClause", field{"value", encode(e.Value())}, field{"body", encode(e.Body())})
+	case ast.WhenElseClause:
+		return node(e, "WhenElseClause", field{"body", encode(e.Body())})
+	case ast.Definition:
+		return node(e, "Definition", field{"name", encode(e.Name())}, field{"type", encode(e.Type())},
+			field{"value", encode(e.Value())})
+	case ast.Storage:
+		return node(e, "Storage", field{"name", encode(e.Name())}, field{"type", encode(e.Type())},
+			field{"value", encode(e.Value())}, field{"mutable", e.Mutable()})
+	case ast.TypeLiteral:
+		return node(e, "TypeLiteral", field{"typeParameters", encodeTypeParameters(e.TypeParameters())},
+			field{"members", encodeElements(e.Members())})
+	case ast.CallableTypeMember:
+		return node(e, "CallableTypeMember", field{"parameters", encodeElements(e.Parameters())},
+			field{"result", encode(e.Result())})
+	case ast.SequenceType:
+		return node(e, "SequenceType", field{"elements", encode(e.Elements())})
+	case ast.OptionalType:
+		return node(e, "OptionalType", field{"target", encode(e.Target())})
+	case ast.ReferenceType:
+		return node(e, "ReferenceType", field{"referent", encode(e.Referent())})
+	case ast.VocabularyLiteral:
+		return node(e, "VocabularyLiteral", field{"members", encodeElements(e.Members())})
+	case ast.VocabularyOperatorDeclaration:
+		return node(e, "VocabularyOperatorDeclaration", field{"names", encodeNames(e.Names())},
+			field{"placement", placements[e.Placement()]}, field{"precedence", encode(e.Precedence())},
+			field{"associativity", associativities[e.Associativity()]})
+	case ast.VocabularyOperatorPrecedence:
+		return node(e, "VocabularyOperatorPrecedence", field{"name", encode(e.Name())},
+			field{"placement", placements[e.Placement()]}, field{"relation", relations[e.Relation()]})
+	case ast.VocabularyEmbedding:
+		return node(e, "VocabularyEmbedding", field{"name", encodeNames(e.Name())})
+	case ast.Error:
+		return node(e, "Error", field{"message", e.Error()})
+	}
+	fail("Unknown element %#v", element)
+	return nil
+}
+
+// encodeElements encodes elements. An empty list is encoded as an empty array and a nil list is
+// omitted so the difference is preserved.
+func encodeElements(elements []ast.Element) interface{} {
+	if elements == nil {
+		return nil
+	}
+	result := make([]interface{}, len(elements))
+	for index, element := range elements {
+		result[index] = encode(element)
+	}
+	return result
+}
+
+func encodeNames(names []ast.Name) interface{} {
+	if names == nil {
+		return nil
+	}
+	return encodeElements(ast.LowerNames(names))
+}
+
+func encodeParameters(parameters []ast.Parameter) interface{} {
+	if parameters == nil {
+		return nil
+	}
+	return encodeElements(ast.LowerParameters(parameters))
+}
+
+func encodeTypeParameters(typeParameters []ast.TypeParameter) interface{} {
+	if typeParameters == nil {
+		return nil
+	}
+	return encodeElements(ast.LowerTypeParameters(typeParameters))
+}
+
+// encodeValue returns the type and the encoding of a literal value. Integers and floating point
+// values are encoded as numbers in a form that parses back to the same value.
+func encodeValue(value interface{}) (string, interface{}) {
+	switch v := value.(type) {
+	case nil:
+		return "null", nil
+	case bool:
+		return "bool", v
+	case string:
+		return "string", v
+	case int:
+		return "int", json.Number(strconv.FormatInt(int64(v), 10))
+	case rune:
+		return "rune", json.Number(strconv.FormatInt(int64(v), 10))
+	case int64:
+		return "int64", json.Number(strconv.FormatInt(v, 10))
+	case byte:
+		return "byte", json.Number(strconv.FormatUint(uint64(v), 10))
+	case uint:
+		return "uint", json.Number(strconv.FormatUint(uint64(v), 10))
+	case uint32:
+		return "uint32", json.Number(strconv.FormatUint(uint64(v), 10))
+	case uint64:
+		return "uint64", json.Number(strconv.FormatUint(v, 10))
+	case float32:
+		return "float32", json.Number(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	case float64:
+		return "float64", json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	fail("Unsupported literal value %#v", value)
+	return "", nil
+}
+
+// span is a BuilderContext for the location of the element being decoded
+type span struct {
+	start, end location.Pos
+}
+
+func (s *span) Start() location.Pos {
+	return s.start
+}
+
+func (s *span) End() location.Pos {
+	return s.end
+}
+
+type decoder struct {
+	context span
+	builder ast.Builder
+}
+
+// fields are the fields of an encoded element
+type fields map[string]json.RawMessage
+
+// at locates the next element built at the location encoded in f
+func (d *decoder) at(f fields) ast.Builder {
+	d.context.start, d.context.end = location.Pos(d.int(f, "start")), location.Pos(d.int(f, "end"))
+	d.builder.PopContext()
+	d.builder.PushContext()
+	return d.builder
+}
+
+func (d *decoder) element(data json.RawMessage) ast.Element {
+	if isNull(data) {
+		return nil
+	}
+	var f fields
+	if err := json.Unmarshal(data, &f); err != nil {
+		fail("%s", err)
+	}
+	switch kind := d.string(f, "kind"); kind {
+	case "Name":
+		return d.at(f).Name(d.string(f, "text"))
+	case "Literal":
+		value := d.value(d.string(f, "valueType"), f["value"])
+		return d.at(f).Literal(value, d.string(f, "spelling"))
+	case "Selection":
+		target, member := d.element(f["target"]), d.name(f["member"])
+		return d.at(f).Selection(target, member)
+	case "SafeSelection":
+		target, member := d.element(f["target"]), d.name(f["member"])
+		return d.at(f).SafeSelection(target, member)
+	case "Sequence":
+		left, right := d.element(f["left"]), d.element(f["right"])
+		return d.at(f).Sequence(left, right)
+	case "Spread":
+		target := d.element(f["target"])
+		return d.at(f).Spread(target)
+	case "Break":
+		label := d.name(f["label"])
+		return d.at(f).Break(label)
+	case "Call":
+		target, arguments := d.element(f["target"]), d.elements(f["arguments"])
+		return d.at(f).Call(target, arguments)
+	case "Continue":
+		label := d.name(f["label"])
+		return d.at(f).Continue(label)
+	case "NamedArgument":
+		name, value := d.name(f["name"]), d.element(f["value"])
+		return d.at(f).NamedArgument(name, value)
+	case "ObjectInitializer":
+		typ, members := d.element(f["type"]), d.elements(f["members"])
+		return d.at(f).ObjectInitializer(d.bool(f, "mutable"), typ, members)
+	case "ArrayInitializer":
+		typ, elements := d.element(f["type"]), d.elements(f["elements"])
+		return d.at(f).ArrayInitializer(d.bool(f, "mutable"), typ, elements)
+	case "NamedMemberInitializer":
+		name, typ, value := d.name(f["name"]), d.element(f["type"]), d.element(f["value"])
+		return d.at(f).NamedMemberInitializer(name, typ, value)
+	case "Lambda":
+		typeParameters, parameters := d.typeParameters(f["typeParameters"]), d.parameters(f["parameters"])
+		body, result := d.element(f["body"]), d.element(f["result"])
+		return d.at(f).Lambda(typeParameters, parameters, body, result)
+	case "IntrinsicLambda":
+		parameters, body, result := d.parameters(f["parameters"]), d.element(f["body"]), d.element(f["result"])
+		return d.at(f).IntrinsicLambda(parameters, body, result)
+	case "Loop":
+		label, body := d.name(f["label"]), d.element(f["body"])
+		return d.at(f).Loop(label, body)
+	case "Parameter":
+		name, typ, deflt := d.name(f["name"]), d.element(f["type"]), d.element(f["default"])
+		return d.at(f).Parameter(name, typ, deflt)
+	case "TypeParameter":
+		name, constraint := d.name(f["name"]), d.element(f["constraint"])
+		return d.at(f).TypeParameter(name, constraint)
+	case "Instantiation":
+		target, typeArguments := d.element(f["target"]), d.elements(f["typeArguments"])
+		return d.at(f).Instantiation(target, typeArguments)
+	case "Return":
+		value := d.element(f["value"])
+		return d.at(f).Return(value)
+	case "When":
+		target, clauses := d.element(f["target"]), d.elements(f["clauses"])
+		return d.at(f).When(target, clauses)
+	case "WhenValueClause":
+		value, body := d.element(f["value"]), d.element(f["body"])
+		return d.at(f).WhenValueClause(value, body)
+	case "WhenElseClause":
+		body := d.element(f["body"])
+		return d.at(f).WhenElseClause(body)
+	case "Definition":
+		name, typ, value := d.name(f["name"]), d.element(f["type"]), d.element(f["value"])
+		return d.at(f).Definition(name, typ, value)
+	case "Storage":
+		name, typ, value := d.name(f["name"]), d.element(f["type"]), d.element(f["value"])
+		return d.at(f).Storage(name, typ, value, d.bool(f, "mutable"))
+	case "TypeLiteral":
+		typeParameters, members := d.typeParameters(f["typeParameters"]), d.elements(f["members"])
+		return d.at(f).TypeLiteral(typeParameters, members)
+	case "CallableTypeMember":
+		parameters, result := d.elements(f["parameters"]), d.element(f["result"])
+		return d.at(f).CallableTypeMember(parameters, result)
+	case "SequenceType":
+		elements := d.element(f["elements"])
+		return d.at(f).SequenceType(elements)
+	case "OptionalType":
+		target := d.element(f["target"])
+		return d.at(f).OptionalType(target)
+	case "ReferenceType":
+		referent := d.element(f["referent"])
+		return d.at(f).ReferenceType(referent)
+	case "VocabularyLiteral":
+		members := d.elements(f["members"])
+		return d.at(f).VocabularyLiteral(members)
+	case "VocabularyOperatorDeclaration":
+		names, precedence := d.names(f["names"]), d.precedence(f["precedence"])
+		placement, associativity := d.placement(f), d.associativity(f)
+		return d.at(f).VocabularyOperatorDeclaration(names, placement, precedence, associativity)
+	case "VocabularyOperatorPrecedence":
+		return d.precedence(data)
+	case "VocabularyEmbedding":
+		names := d.names(f["name"])
+		return d.at(f).VocabularyEmbedding(names)
+	case "Error":
+		return d.at(f).Error("%s", d.string(f, "message"))
+	default:
+		fail("Unknown element kind '%s'", kind)
+		return nil
+	}
+}
+
+func (d *decoder) elements(data json.RawMessage) []ast.Element {
+	if isNull(data) {
+		return nil
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		fail("%s", err)
+	}
+	result := make([]ast.Element, len(list))
+	for index, item := range list {
+		result[index] = d.element(item)
+	}
+	return result
+}
+
+func (d *decoder) name(data json.RawMessage) ast.Name {
+	element := d.element(data)
+	if element == nil {
+		return nil
+	}
+	name, ok := element.(ast.Name)
+	if !ok {
+		fail("Expected a Name, received %s", element)
+	}
+	return name
+}
+
+func (d *decoder) names(data json.RawMessage) []ast.Name {
+	elements := d.elements(data)
+	if elements == nil {
+		return nil
+	}
+	result := make([]ast.Name, len(elements))
+	for index, element := range elements {
+		name, ok := element.(ast.Name)
+		if !ok {
+			fail("Expected a Name, received %s", element)
+		}
+		result[index] = name
+	}
+	return result
+}
+
+func (d *decoder) parameters(data json.RawMessage) []ast.Parameter {
+	elements := d.elements(data)
+	if elements == nil {
+		return nil
+	}
+	result := make([]ast.Parameter, len(elements))
+	for index, element := range elements {
+		parameter, ok := element.(ast.Parameter)
+		if !ok {
+			fail("Expected a Parameter, received %s", element)
+		}
+		result[index] = parameter
+	}
+	return result
+}
+
+func (d *decoder) typeParameters(data json.RawMessage) []ast.TypeParameter {
+	elements := d.elements(data)
+	if elements == nil {
+		return nil
+	}
+	result := make([]ast.TypeParameter, len(elements))
+	for index, element := range elements {
+		typeParameter, ok := element.(ast.TypeParameter)
+		if !ok {
+			fail("Expected a TypeParameter, received %s", element)
+		}
+		result[index] = typeParameter
+	}
+	return result
+}
+
+func (d *decoder) precedence(data json.RawMessage) ast.VocabularyOperatorPrecedence {
+	if isNull(data) {
+		return nil
+	}
+	var f fields
+	if err := json.Unmarshal(data, &f); err != nil {
+		fail("%s", err)
+	}
+	if kind := d.string(f, "kind"); kind != "VocabularyOperatorPrecedence" {
+		fail("Expected a VocabularyOperatorPrecedence, received %s", kind)
+	}
+	name := d.name(f["name"])
+	relation := d.relation(f)
+	return d.at(f).VocabularyOperatorPrecedence(name, d.placement(f), relation)
+}
+
+func (d *decoder) placement(f fields) ast.OperatorPlacement {
+	text := d.string(f, "placement")
+	for placement, name := range placements {
+		if name == text {
+			return placement
+		}
+	}
+	fail("Unknown placement '%s'", text)
+	return ast.UnspecifiedPlacement
+}
+
+func (d *decoder) associativity(f fields) ast.OperatorAssociativity {
+	text := d.string(f, "associativity")
+	for associativity, name := range associativities {
+		if name == text {
+			return associativity
+		}
+	}
+	fail("Unknown associativity '%s'", text)
+	return ast.UnspecifiedAssociativity
+}
+
+func (d *decoder) relation(f fields) ast.OperatorPrecedenceRelation {
+	text := d.string(f, "relation")
+	for relation, name := range relations {
+		if name == text {
+			return relation
+		}
+	}
+	fail("Unknown relation '%s'", text)
+	return ast.After
+}
+
+// value decodes a literal value of valueType
+func (d *decoder) value(valueType string, data json.RawMessage) interface{} {
+	switch valueType {
+	case "null":
+		return nil
+	case "bool":
+		var result bool
+		d.unmarshal(data, &result)
+		return result
+	case "string":
+		var result string
+		d.unmarshal(data, &result)
+		return result
+	case "int":
+		return int(d.parseInt(data, strconv.IntSize))
+	case "rune":
+		return rune(d.parseInt(data, 32))
+	case "int64":
+		return d.parseInt(data, 64)
+	case "byte":
+		return byte(d.parseUint(data, 8))
+	case "uint":
+		return uint(d.parseUint(data, strconv.IntSize))
+	case "uint32":
+		return uint32(d.parseUint(data, 32))
+	case "uint64":
+		return d.parseUint(data, 64)
+	case "float32":
+		return float32(d.parseFloat(data, 32))
+	case "float64":
+		return d.parseFloat(data, 64)
+	}
+	fail("Unknown literal value type '%s'", valueType)
+	return nil
+}
+
+func (d *decoder) parseInt(data json.RawMessage, size int) int64 {
+	result, err := strconv.ParseInt(string(data), 10, size)
+	if err != nil {
+		fail("Invalid literal value %s: %s", data, err)
+	}
+	return result
+}
+
+func (d *decoder) parseUint(data json.RawMessage, size int) uint64 {
+	result, err := strconv.ParseUint(string(data), 10, size)
+	if err != nil {
+		fail("Invalid literal value %s: %s", data, err)
+	}
+	return result
+}
+
+func (d *decoder) parseFloat(data json.RawMessage, size int) float64 {
+	result, err := strconv.ParseFloat(string(data), size)
+	if err != nil {
+		fail("Invalid literal value %s: %s", data, err)
+	}
+	return result
+}
+
+func (d *decoder) string(f fields, key string) string {
+	var result string
+	if data, ok := f[key]; ok {
+		d.unmarshal(data, &result)
+	}
+	return result
+}
+
+func (d *decoder) int(f fields, key string) int {
+	data, ok := f[key]
+	if !ok {
+		fail("Missing field '%s'", key)
+	}
+	var result int
+	d.unmarshal(data, &result)
+	return result
+}
+
+func (d *decoder) bool(f fields, key string) bool {
+	var result bool
+	if data, ok := f[key]; ok {
+		d.unmarshal(data, &result)
+	}
+	return result
+}
+
+func (d *decoder) unmarshal(data json.RawMessage, v interface{}) {
+	if err := json.Unmarshal(data, v); err != nil {
+		fail("%s", err)
+	}
+}
+
+func isNull(data json.RawMessage) bool {
+	return len(data) == 0 || string(bytes.TrimSpace(data)) == "null"
+}
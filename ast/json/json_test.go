@@ -0,0 +1,141 @@
+package json_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"dyego0/ast"
+	"dyego0/ast/json"
+	"dyego0/location"
+	"dyego0/parser"
+	"dyego0/scanner"
+)
+
+var _ = Describe("json", func() {
+	b := ast.NewBuilder(location.NewLocation(0, 1))
+	b.PushContext()
+
+	n := b.Name("n")
+	m := b.Name("m")
+	one := b.Literal(1, "0x1")
+	param := b.Parameter(n, m, one)
+	typeParam := b.TypeParameter(n, m)
+	precedence := b.VocabularyOperatorPrecedence(n, ast.UnspecifiedPlacement, ast.Before)
+	elements := []ast.Element{
+		n,
+		one,
+		b.Break(n),
+		b.Continue(nil),
+		b.Selection(n, m),
+		b.SafeSelection(n, m),
+		b.Spread(n),
+		b.Call(n, []ast.Element{m, one}),
+		b.Call(n, []ast.Element{}),
+		b.NamedArgument(n, one),
+		b.ObjectInitializer(true, n, []ast.Element{m, one}),
+		b.ArrayInitializer(false, nil, []ast.Element{m, one}),
+		b.NamedMemberInitializer(n, m, one),
+		b.Lambda([]ast.TypeParameter{typeParam}, []ast.Parameter{param}, one, nil),
+		b.Instantiation(n, []ast.Element{m, one}),
+		b.IntrinsicLambda([]ast.Parameter{param}, one, m),
+		b.Loop(n, one),
+		b.Return(one),
+		b.When(n, []ast.Element{b.WhenValueClause(n, one), b.WhenElseClause(one)}),
+		b.Definition(n, m, one),
+		b.Storage(n, nil, one, true),
+		b.TypeLiteral([]ast.TypeParameter{typeParam}, []ast.Element{b.CallableTypeMember([]ast.Element{param}, m)}),
+		b.SequenceType(n),
+		b.OptionalType(n),
+		b.ReferenceType(n),
+		b.VocabularyLiteral([]ast.Element{
+			b.VocabularyOperatorDeclaration([]ast.Name{n, m}, ast.Postfix, precedence, ast.Right),
+			b.VocabularyOperatorDeclaration([]ast.Name{n}, ast.Infix, nil, ast.UnspecifiedAssociativity),
+			b.VocabularyOperatorDeclaration([]ast.Name{m}, ast.Infix,
+				b.VocabularyOperatorPrecedence(n, ast.Infix, ast.SameAs), ast.NonAssociative),
+			b.VocabularyEmbedding([]ast.Name{n, m}),
+		}),
+		b.Error("msg %d", 1),
+	}
+
+	roundTrip := func(element ast.Element) ast.Element {
+		data, err := json.Marshal(element)
+		Expect(err).To(BeNil())
+		result, err := json.Unmarshal(data)
+		Expect(err).To(BeNil())
+		return result
+	}
+	It("round trips every kind of element", func() {
+		for _, element := range elements {
+			Expect(roundTrip(element)).To(Equal(element), fmt.Sprint(element))
+		}
+		sequence := ast.SequenceOf(elements)
+		Expect(roundTrip(sequence)).To(Equal(sequence))
+	})
+	It("round trips literal values", func() {
+		for _, value := range []interface{}{
+			true, "a \"b\" é", 1, 'é', int64(-9223372036854775808), byte(255), uint(4294967295),
+			uint32(0xFFFFFFFF), uint64(18446744073709551615), float32(0.1), 0.1, 1e300,
+		} {
+			literal := b.Literal(value, "")
+			Expect(roundTrip(literal).(ast.Literal).Value()).To(Equal(value))
+		}
+		Expect(roundTrip(b.Literal(nil, "null")).(ast.Literal).Value()).To(BeNil())
+	})
+	It("encodes locations and omits missing properties", func() {
+		c := ast.NewBuilder(location.NewLocation(2, 5))
+		c.PushContext()
+		data, err := json.Marshal(c.Break(nil))
+		Expect(err).To(BeNil())
+		Expect(string(data)).To(Equal(`{"kind":"Break","start":2,"end":5}`))
+		data, err = json.Marshal(c.Literal(byte(7), "7ub"))
+		Expect(err).To(BeNil())
+		Expect(string(data)).To(Equal(
+			`{"kind":"Literal","start":2,"end":5,"valueType":"byte","value":7,"spelling":"7ub"}`))
+	})
+	It("encodes nil as null", func() {
+		data, err := json.Marshal(nil)
+		Expect(err).To(BeNil())
+		Expect(string(data)).To(Equal("null"))
+		element, err := json.Unmarshal(data)
+		Expect(err).To(BeNil())
+		Expect(element).To(BeNil())
+	})
+	It("round trips a parsed file", func() {
+		src, err := ioutil.ReadFile("../../examples/Example.dg")
+		Expect(err).To(BeNil())
+		p := parser.NewParser(scanner.NewScanner(append(src, 0), 0, nil), parser.DefaultVocabularyScope())
+		element := p.Parse()
+		data, err := json.MarshalIndent(element, "", "  ")
+		Expect(err).To(BeNil())
+		Expect(roundTrip(element)).To(Equal(element))
+		result, err := json.Unmarshal(data)
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal(element))
+	})
+	It("reports an unsupported literal value", func() {
+		_, err := json.Marshal(b.Literal(uintptr(1), ""))
+		Expect(err).To(MatchError(ContainSubstring("Unsupported literal value")))
+	})
+	It("reports invalid encodings", func() {
+		for text, message := range map[string]string{
+			`{"kind":"Foo","start":0,"end":0}`: "Unknown element kind 'Foo'",
+			`{"kind":"Name","end":0}`:          "Missing field 'start'",
+			`{"kind":"Break","start":0,"end":0,"label":{"kind":"Error","start":0,"end":0}}`: "Expected a Name",
+			`{"kind":"Literal","start":0,"end":0,"valueType":"byte","value":256}`:           "Invalid literal value 256",
+			`{"kind":"Literal","start":0,"end":0,"valueType":"complex","value":1}`:          "Unknown literal value type",
+			`[1]`: "cannot unmarshal",
+		} {
+			_, err := json.Unmarshal([]byte(text))
+			Expect(err).To(MatchError(ContainSubstring(message)), text)
+		}
+	})
+})
+
+func TestJson(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "AST JSON suite")
+}
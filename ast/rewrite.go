@@ -0,0 +1,332 @@
+package ast
+
+import (
+	"dyego0/assert"
+)
+
+// RewriteFunc is called by Rewrite for an element at cursor. Returning false from pre skips the
+// children of the element and the call to post; returning false from post stops the rewrite.
+type RewriteFunc func(cursor *Cursor) bool
+
+// Cursor is an element being rewritten by Rewrite and its position in the tree
+type Cursor struct {
+	rewriter *rewriter
+	element  Element
+}
+
+// Element is the current element. In post it is the element rebuilt with the rewritten
+// children.
+func (c *Cursor) Element() Element {
+	return c.element
+}
+
+// Replace replaces the current element with element. An element replaced in pre has the children
+// of the replacement rewritten instead. Replacing an element of a list with nil removes it from
+// the list. A Name, Parameter, TypeParameter or VocabularyOperatorPrecedence can only be replaced
+// by an element of the same kind or nil.
+func (c *Cursor) Replace(element Element) {
+	c.element = element
+}
+
+// Parent is the element that contains the current element, or nil for the root
+func (c *Cursor) Parent() Element {
+	path := c.rewriter.path
+	if len(path) == 0 {
+		return nil
+	}
+	return path[len(path)-1]
+}
+
+// Path are the elements that contain the current element starting with the root
+func (c *Cursor) Path() []Element {
+	path := c.rewriter.path
+	return path[:len(path):len(path)]
+}
+
+// Builder is a builder that locates the elements it builds at the current element, so a
+// replacement can be located at the element it replaces
+func (c *Cursor) Builder() Builder {
+	return c.rewriter.at(c.element)
+}
+
+// Rewrite returns element with its elements rewritten by calling pre before, and post after,
+// the children of each element are rewritten; either can be nil. An element with rewritten
+// children is rebuilt at its original location; an element with no rewritten children is
+// returned as is.
+func Rewrite(element Element, pre, post RewriteFunc) Element {
+	r := &rewriter{pre: pre, post: post}
+	r.builder = NewBuilder(&r.context)
+	r.builder.PushContext()
+	return r.element(element)
+}
+
+// Transform returns element with each element replaced by the result of calling transform with
+// the element after its children are transformed
+func Transform(element Element, transform func(element Element) Element) Element {
+	return Rewrite(element, nil, func(cursor *Cursor) bool {
+		cursor.Replace(transform(cursor.Element()))
+		return true
+	})
+}
+
+type rewriter struct {
+	pre, post RewriteFunc
+	path      []Element
+	stopped   bool
+	context   fixedContext
+	builder   Builder
+}
+
+// at locates the next element built at the location of element
+func (r *rewriter) at(element Element) Builder {
+	if element != nil {
+		r.context.start, r.context.end = element.Start(), element.End()
+	}
+	r.builder.PopContext()
+	r.builder.PushContext()
+	return r.builder
+}
+
+func (r *rewriter) element(element Element) Element {
+	if element == nil || r.stopped {
+		return element
+	}
+	cursor := &Cursor{rewriter: r, element: element}
+	if r.pre != nil && !r.pre(cursor) {
+		return cursor.element
+	}
+	if cursor.element == nil {
+		return nil
+	}
+	r.path = append(r.path, cursor.element)
+	cursor.element = r.children(cursor.element)
+	r.path = r.path[:len(r.path)-1]
+	if r.post != nil && !r.stopped && !r.post(cursor) {
+		r.stopped = true
+	}
+	return cursor.element
+}
+
+// children returns element rebuilt with its children rewritten, or element if none of its
+// children changed
+func (r *rewriter) children(element Element) Element {
+	c := &rewrittenChildren{rewriter: r}
+	var build func(b Builder) Element
+	switch e := element.(type) {
+	case Name, Literal, Error:
+		return element
+	case Selection:
+		target, member := c.element(e.Target()), c.name(e.Member())
+		build = func(b Builder) Element { return b.Selection(target, member) }
+	case SafeSelection:
+		target, member := c.element(e.Target()), c.name(e.Member())
+		build = func(b Builder) Element { return b.SafeSelection(target, member) }
+	case Sequence:
+		left, right := c.element(e.Left()), c.element(e.Right())
+		build = func(b Builder) Element { return b.Sequence(left, right) }
+	case Spread:
+		target := c.element(e.Target())
+		build = func(b Builder) Element { return b.Spread(target) }
+	case Break:
+		label := c.name(e.Label())
+		build = func(b Builder) Element { return b.Break(label) }
+	case Call:
+		target, arguments := c.element(e.Target()), c.elements(e.Arguments())
+		build = func(b Builder) Element { return b.Call(target, arguments) }
+	case Continue:
+		label := c.name(e.Label())
+		build = func(b Builder) Element { return b.Continue(label) }
+	case NamedArgument:
+		name, value := c.name(e.Name()), c.element(e.Value())
+		build = func(b Builder) Element { return b.NamedArgument(name, value) }
+	case ObjectInitializer:
+		typ, members := c.element(e.Type()), c.elements(e.Members())
+		build = func(b Builder) Element { return b.ObjectInitializer(e.Mutable(), typ, members) }
+	case ArrayInitializer:
+		typ, elements := c.element(e.Type()), c.elements(e.Elements())
+		build = func(b Builder) Element { return b.ArrayInitializer(e.Mutable(), typ, elements) }
+	case NamedMemberInitializer:
+		name, typ, value := c.name(e.Name()), c.element(e.Type()), c.element(e.Value())
+		build = func(b Builder) Element { return b.NamedMemberInitializer(name, typ, value) }
+	case Lambda:
+		typeParameters, parameters := c.typeParameters(e.TypeParameters()), c.parameters(e.Parameters())
+		body, result := c.element(e.Body()), c.element(e.Result())
+		build = func(b Builder) Element { return b.Lambda(typeParameters, parameters, body, result) }
+	case IntrinsicLambda:
+		parameters, body, result := c.parameters(e.Parameters()), c.element(e.Body()), c.element(e.Result())
+		build = func(b Builder) Element { return b.IntrinsicLambda(parameters, body, result) }
+	case Loop:
+		label, body := c.name(e.Label()), c.element(e.Body())
+		build = func(b Builder) Element { return b.Loop(label, body) }
+	case Parameter:
+		name, typ, deflt := c.name(e.Name()), c.element(e.Type()), c.element(e.Default())
+		build = func(b Builder) Element { return b.Parameter(name, typ, deflt) }
+	case TypeParameter:
+		name, constraint := c.name(e.Name()), c.element(e.Constraint())
+		build = func(b Builder) Element { return b.TypeParameter(name, constraint) }
+	case Instantiation:
+		target, typeArguments := c.element(e.Target()), c.elements(e.TypeArguments())
+		build = func(b Builder) Element { return b.Instantiation(target, typeArguments) }
+	case Return:
+		value := c.element(e.Value())
+		build = func(b Builder) Element { return b.Return(value) }
+	case When:
+		target, clauses := c.element(e.Target()), c.elements(e.Clauses())
+		build = func(b Builder) Element { return b.When(target, clauses) }
+	case WhenValueClause:
+		value, body := c.element(e.Value()), c.element(e.Body())
+		build = func(b Builder) Element { return b.WhenValueClause(value, body) }
+	case WhenElseClause:
+		body := c.element(e.Body())
+		build = func(b Builder) Element { return b.WhenElseClause(body) }
+	case Definition:
+		name, typ, value := c.name(e.Name()), c.element(e.Type()), c.element(e.Value())
+		build = func(b Builder) Element { return b.Definition(name, typ, value) }
+	case Storage:
+		name, typ, value := c.name(e.Name()), c.element(e.Type()), c.element(e.Value())
+		build = func(b Builder) Element { return b.Storage(name, typ, value, e.Mutable()) }
+	case TypeLiteral:
+		typeParameters, members := c.typeParameters(e.TypeParameters()), c.elements(e.Members())
+		build = func(b Builder) Element { return b.TypeLiteral(typeParameters, members) }
+	case CallableTypeMember:
+		parameters, result := c.elements(e.Parameters()), c.element(e.Result())
+		build = func(b Builder) Element { return b.CallableTypeMember(parameters, result) }
+	case SequenceType:
+		elements := c.element(e.Elements())
+		build = func(b Builder) Element { return b.SequenceType(elements) }
+	case OptionalType:
+		target := c.element(e.Target())
+		build = func(b Builder) Element { return b.OptionalType(target) }
+	case ReferenceType:
+		referent := c.element(e.Referent())
+		build = func(b Builder) Element { return b.ReferenceType(referent) }
+	case VocabularyLiteral:
+		members := c.elements(e.Members())
+		build = func(b Builder) Element { return b.VocabularyLiteral(members) }
+	case VocabularyOperatorDeclaration:
+		names, precedence := c.names(e.Names()), c.precedence(e.Precedence())
+		build = func(b Builder) Element {
+			return b.VocabularyOperatorDeclaration(names, e.Placement(), precedence, e.Associativity())
+		}
+	case VocabularyOperatorPrecedence:
+		name := c.name(e.Name())
+		build = func(b Builder) Element { return b.VocabularyOperatorPrecedence(name, e.Placement(), e.Relation()) }
+	case VocabularyEmbedding:
+		names := c.names(e.Name())
+		build = func(b Builder) Element { return b.VocabularyEmbedding(names) }
+	default:
+		assert.Fail("Unknown element %#v", element)
+	}
+	if !c.changed {
+		return element
+	}
+	return build(r.at(element))
+}
+
+// rewrittenChildren rewrites the children of an element and records whether any changed
+type rewrittenChildren struct {
+	rewriter *rewriter
+	changed  bool
+}
+
+func (c *rewrittenChildren) element(element Element) Element {
+	result := c.rewriter.element(element)
+	if result != element {
+		c.changed = true
+	}
+	return result
+}
+
+func (c *rewrittenChildren) elements(elements []Element) []Element {
+	if elements == nil {
+		return nil
+	}
+	result := make([]Element, 0, len(elements))
+	for _, element := range elements {
+		if rewritten := c.element(element); rewritten != nil || element == nil {
+			result = append(result, rewritten)
+		}
+	}
+	return result
+}
+
+func (c *rewrittenChildren) name(name Name) Name {
+	if name == nil {
+		return nil
+	}
+	return asName(c.element(name), name)
+}
+
+func (c *rewrittenChildren) names(names []Name) []Name {
+	if names == nil {
+		return nil
+	}
+	result := make([]Name, 0, len(names))
+	for _, name := range names {
+		if rewritten := c.name(name); rewritten != nil {
+			result = append(result, rewritten)
+		}
+	}
+	return result
+}
+
+func (c *rewrittenChildren) parameters(parameters []Parameter) []Parameter {
+	if parameters == nil {
+		return nil
+	}
+	result := make([]Parameter, 0, len(parameters))
+	for _, parameter := range parameters {
+		switch rewritten := c.element(parameter).(type) {
+		case nil:
+		case Parameter:
+			result = append(result, rewritten)
+		default:
+			assert.Fail("Expected a Parameter to replace %s, received %s", parameter, rewritten)
+		}
+	}
+	return result
+}
+
+func (c *rewrittenChildren) typeParameters(typeParameters []TypeParameter) []TypeParameter {
+	if typeParameters == nil {
+		return nil
+	}
+	result := make([]TypeParameter, 0, len(typeParameters))
+	for _, typeParameter := range typeParameters {
+		switch rewritten := c.element(typeParameter).(type) {
+		case nil:
+		case TypeParameter:
+			result = append(result, rewritten)
+		default:
+			assert.Fail("Expected a TypeParameter to replace %s, received %s", typeParameter, rewritten)
+		}
+	}
+	return result
+}
+
+func (c *rewrittenChildren) precedence(precedence VocabularyOperatorPrecedence) VocabularyOperatorPrecedence {
+	if precedence == nil {
+		return nil
+	}
+	switch rewritten := c.element(precedence).(type) {
+	case nil:
+		return nil
+	case VocabularyOperatorPrecedence:
+		return rewritten
+	default:
+		assert.Fail("Expected a VocabularyOperatorPrecedence to replace %s, received %s", precedence, rewritten)
+		return nil
+	}
+}
+
+// asName returns element, the replacement of name, as a Name
+func asName(element Element, name Name) Name {
+	if element == nil {
+		return nil
+	}
+	result, ok := element.(Name)
+	if !ok {
+		assert.Fail("Expected a Name to replace %s, received %s", name, element)
+	}
+	return result
+}
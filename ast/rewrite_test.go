@@ -0,0 +1,191 @@
+package ast_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"dyego0/ast"
+	"dyego0/location"
+)
+
+var _ = Describe("rewrite", func() {
+	b := ast.NewBuilder(location.NewLocation(0, 1))
+	b.PushContext()
+	at := func(start, end int) ast.Builder {
+		result := ast.NewBuilder(location.NewLocation(location.Pos(start), location.Pos(end)))
+		result.PushContext()
+		return result
+	}
+
+	n := b.Name("n")
+	m := b.Name("m")
+	one := b.Literal(1, "1")
+	param := b.Parameter(n, m, one)
+	typeParam := b.TypeParameter(n, m)
+	precedence := b.VocabularyOperatorPrecedence(n, ast.Infix, ast.Before)
+	elements := []ast.Element{
+		b.Break(n),
+		b.Selection(n, m),
+		b.SafeSelection(n, m),
+		b.Spread(n),
+		b.Call(n, []ast.Element{m, one}),
+		b.NamedArgument(n, one),
+		b.ObjectInitializer(true, n, []ast.Element{m, one}),
+		b.ArrayInitializer(false, nil, []ast.Element{n, one}),
+		b.NamedMemberInitializer(n, m, one),
+		b.Lambda([]ast.TypeParameter{typeParam}, []ast.Parameter{param}, one, nil),
+		b.Instantiation(n, []ast.Element{m, one}),
+		b.IntrinsicLambda([]ast.Parameter{param}, one, m),
+		b.Loop(n, one),
+		b.Return(n),
+		b.When(n, []ast.Element{b.WhenValueClause(n, one), b.WhenElseClause(one)}),
+		b.Definition(n, m, one),
+		b.Storage(n, nil, one, true),
+		b.TypeLiteral([]ast.TypeParameter{typeParam}, []ast.Element{b.CallableTypeMember([]ast.Element{param}, m)}),
+		b.SequenceType(n),
+		b.OptionalType(n),
+		b.ReferenceType(n),
+		b.VocabularyLiteral([]ast.Element{
+			b.VocabularyOperatorDeclaration([]ast.Name{n, m}, ast.Infix, precedence, ast.Right),
+			b.VocabularyEmbedding([]ast.Name{n, m}),
+		}),
+		b.Error("msg"),
+	}
+	sequence := ast.SequenceOf(elements)
+
+	It("returns the element when nothing is rewritten", func() {
+		Expect(ast.Rewrite(sequence, nil, nil)).To(BeIdenticalTo(sequence))
+		Expect(ast.Transform(sequence, func(element ast.Element) ast.Element {
+			return element
+		})).To(BeIdenticalTo(sequence))
+		Expect(ast.Rewrite(nil, nil, nil)).To(BeNil())
+	})
+	It("rebuilds every kind of element with a replaced child", func() {
+		renamed := ast.Transform(sequence, func(element ast.Element) ast.Element {
+			if name, ok := element.(ast.Name); ok && name.Text() == "n" {
+				return at(5, 6).Name("x")
+			}
+			return element
+		})
+		original := ast.Statements(sequence)
+		for index, element := range ast.Statements(renamed) {
+			if _, ok := element.(ast.Error); ok {
+				Expect(element).To(BeIdenticalTo(original[index]))
+				continue
+			}
+			Expect(element).ToNot(BeIdenticalTo(original[index]))
+			Expect(element.Start()).To(Equal(location.Pos(0)))
+			Expect(element.End()).To(Equal(location.Pos(1)))
+			var names []string
+			ast.Walk(element, visitorFunc(func(e ast.Element) bool {
+				if name, ok := e.(ast.Name); ok {
+					names = append(names, name.Text())
+				}
+				return true
+			}))
+			Expect(names).ToNot(ContainElement("n"))
+		}
+	})
+	It("calls pre and post in order with the path", func() {
+		call := b.Call(n, []ast.Element{m})
+		var events []string
+		ast.Rewrite(call, func(cursor *ast.Cursor) bool {
+			events = append(events, "pre "+describe(cursor.Element()))
+			Expect(cursor.Path()).To(HaveLen(depth(cursor.Element(), call)))
+			if cursor.Element() != call {
+				Expect(cursor.Parent()).To(BeIdenticalTo(call))
+			} else {
+				Expect(cursor.Parent()).To(BeNil())
+			}
+			return true
+		}, func(cursor *ast.Cursor) bool {
+			events = append(events, "post "+describe(cursor.Element()))
+			return true
+		})
+		Expect(events).To(Equal([]string{"pre call", "pre n", "post n", "pre m", "post m", "post call"}))
+	})
+	It("skips the children of an element when pre returns false", func() {
+		var visited []string
+		ast.Rewrite(b.Call(n, []ast.Element{b.Selection(m, n)}), func(cursor *ast.Cursor) bool {
+			visited = append(visited, describe(cursor.Element()))
+			_, ok := cursor.Element().(ast.Selection)
+			return !ok
+		}, nil)
+		Expect(visited).To(Equal([]string{"call", "n", "selection"}))
+	})
+	It("stops when post returns false", func() {
+		count := 0
+		result := ast.Rewrite(b.Call(n, []ast.Element{n, n}), nil, func(cursor *ast.Cursor) bool {
+			count++
+			cursor.Replace(cursor.Builder().Name("x"))
+			return count < 2
+		}).(ast.Call)
+		Expect(count).To(Equal(2))
+		Expect(result.Target().(ast.Name).Text()).To(Equal("x"))
+		Expect(result.Arguments()[0].(ast.Name).Text()).To(Equal("x"))
+		Expect(result.Arguments()[1]).To(BeIdenticalTo(n))
+	})
+	It("rewrites the children of an element replaced in pre", func() {
+		loop := at(2, 9).Loop(nil, n)
+		result := ast.Rewrite(loop, func(cursor *ast.Cursor) bool {
+			if loop, ok := cursor.Element().(ast.Loop); ok {
+				builder := cursor.Builder()
+				cursor.Replace(builder.When(nil, []ast.Element{builder.WhenElseClause(loop.Body())}))
+			}
+			return true
+		}, func(cursor *ast.Cursor) bool {
+			if cursor.Element() == n {
+				cursor.Replace(m)
+			}
+			return true
+		})
+		when := result.(ast.When)
+		Expect(when.Start()).To(Equal(location.Pos(2)))
+		Expect(when.End()).To(Equal(location.Pos(9)))
+		Expect(when.Clauses()[0].(ast.WhenElseClause).Body()).To(BeIdenticalTo(m))
+	})
+	It("removes an element of a list replaced with nil", func() {
+		result := ast.Transform(b.Call(n, []ast.Element{one, m, one}), func(element ast.Element) ast.Element {
+			if element == one {
+				return nil
+			}
+			return element
+		}).(ast.Call)
+		Expect(result.Arguments()).To(Equal([]ast.Element{m}))
+	})
+	It("requires a name to be replaced by a name", func() {
+		Expect(func() {
+			ast.Transform(b.Break(n), func(element ast.Element) ast.Element {
+				if element == n {
+					return one
+				}
+				return element
+			})
+		}).To(Panic())
+	})
+})
+
+type visitorFunc func(element ast.Element) bool
+
+func (f visitorFunc) Visit(element ast.Element) bool {
+	return f(element)
+}
+
+func describe(element ast.Element) string {
+	switch e := element.(type) {
+	case ast.Name:
+		return e.Text()
+	case ast.Call:
+		return "call"
+	case ast.Selection:
+		return "selection"
+	}
+	return "?"
+}
+
+func depth(element, root ast.Element) int {
+	if element == root {
+		return 0
+	}
+	return 1
+}
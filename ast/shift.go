@@ -0,0 +1,273 @@
+package ast
+
+import (
+	"dyego0/assert"
+	"dyego0/location"
+)
+
+// Shift returns a copy of element with all of its locations moved by delta. Invalid positions
+// are not moved.
+func Shift(element Element, delta int) Element {
+	if delta == 0 {
+		return element
+	}
+	s := &shifter{delta: delta}
+	s.builder = NewBuilder(&s.context)
+	s.builder.PushContext()
+	return s.element(element)
+}
+
+// SequenceOf returns the elements as right nested sequence located from the start of the first
+// element to the end of the last. Returns nil if elements is empty.
+func SequenceOf(elements []Element) Element {
+	l := len(elements)
+	if l == 0 {
+		return nil
+	}
+	result := elements[l-1]
+	context := &fixedContext{end: result.End()}
+	builder := NewBuilder(context)
+	for index := l - 2; index >= 0; index-- {
+		context.start = elements[index].Start()
+		builder.PushContext()
+		result = builder.Sequence(elements[index], result)
+		builder.PopContext()
+	}
+	return result
+}
+
+// Statements returns the elements of the right nested sequence of element, the reverse of
+// SequenceOf.
+func Statements(element Element) []Element {
+	var result []Element
+	for element != nil {
+		sequence, ok := element.(Sequence)
+		if !ok {
+			return append(result, element)
+		}
+		result = append(result, sequence.Left())
+		element = sequence.Right()
+	}
+	return result
+}
+
+// fixedContext is a BuilderContext for a builder that locates elements at an explicit location
+type fixedContext struct {
+	start, end location.Pos
+}
+
+func (c *fixedContext) Start() location.Pos {
+	return c.start
+}
+
+func (c *fixedContext) End() location.Pos {
+	return c.end
+}
+
+type shifter struct {
+	delta   int
+	context fixedContext
+	builder Builder
+}
+
+func (s *shifter) pos(p location.Pos) location.Pos {
+	if p.IsValid() {
+		return p + location.Pos(s.delta)
+	}
+	return p
+}
+
+// at locates the next element built at the shifted location of element. The context pushed
+// records the start of the location so is replaced.
+func (s *shifter) at(element Element) Builder {
+	s.context.start = s.pos(element.Start())
+	s.context.end = s.pos(element.End())
+	s.builder.PopContext()
+	s.builder.PushContext()
+	return s.builder
+}
+
+func (s *shifter) element(element Element) Element {
+	if element == nil {
+		return nil
+	}
+	switch e := element.(type) {
+	case Name:
+		return s.name(e)
+	case Literal:
+		return s.at(e).Literal(e.Value(), e.Spelling())
+	case Selection:
+		target, member := s.element(e.Target()), s.name(e.Member())
+		return s.at(e).Selection(target, member)
+	case SafeSelection:
+		target, member := s.element(e.Target()), s.name(e.Member())
+		return s.at(e).SafeSelection(target, member)
+	case Sequence:
+		left, right := s.element(e.Left()), s.element(e.Right())
+		return s.at(e).Sequence(left, right)
+	case Spread:
+		target := s.element(e.Target())
+		return s.at(e).Spread(target)
+	case Break:
+		label := s.name(e.Label())
+		return s.at(e).Break(label)
+	case Call:
+		target, arguments := s.element(e.Target()), s.elements(e.Arguments())
+		return s.at(e).Call(target, arguments)
+	case Continue:
+		label := s.name(e.Label())
+		return s.at(e).Continue(label)
+	case NamedArgument:
+		name, value := s.name(e.Name()), s.element(e.Value())
+		return s.at(e).NamedArgument(name, value)
+	case ObjectInitializer:
+		typ, members := s.element(e.Type()), s.elements(e.Members())
+		return s.at(e).ObjectInitializer(e.Mutable(), typ, members)
+	case ArrayInitializer:
+		typ, elements := s.element(e.Type()), s.elements(e.Elements())
+		return s.at(e).ArrayInitializer(e.Mutable(), typ, elements)
+	case NamedMemberInitializer:
+		name, typ, value := s.name(e.Name()), s.element(e.Type()), s.element(e.Value())
+		return s.at(e).NamedMemberInitializer(name, typ, value)
+	case Lambda:
+		typeParameters, parameters := s.typeParameters(e.TypeParameters()), s.parameters(e.Parameters())
+		body, result := s.element(e.Body()), s.element(e.Result())
+		return s.at(e).Lambda(typeParameters, parameters, body, result)
+	case IntrinsicLambda:
+		parameters, body, result := s.parameters(e.Parameters()), s.element(e.Body()), s.element(e.Result())
+		return s.at(e).IntrinsicLambda(parameters, body, result)
+	case Loop:
+		label, body := s.name(e.Label()), s.element(e.Body())
+		return s.at(e).Loop(label, body)
+	case Parameter:
+		return s.parameter(e)
+	case TypeParameter:
+		return s.typeParameter(e)
+	case Instantiation:
+		target, typeArguments := s.element(e.Target()), s.elements(e.TypeArguments())
+		return s.at(e).Instantiation(target, typeArguments)
+	case Return:
+		value := s.element(e.Value())
+		return s.at(e).Return(value)
+	case When:
+		target, clauses := s.element(e.Target()), s.elements(e.Clauses())
+		return s.at(e).When(target, clauses)
+	case WhenValueClause:
+		value, body := s.element(e.Value()), s.element(e.Body())
+		return s.at(e).WhenValueClause(value, body)
+	case WhenElseClause:
+		body := s.element(e.Body())
+		return s.at(e).WhenElseClause(body)
+	case Definition:
+		name, typ, value := s.name(e.Name()), s.element(e.Type()), s.element(e.Value())
+		return s.at(e).Definition(name, typ, value)
+	case Storage:
+		name, typ, value := s.name(e.Name()), s.element(e.Type()), s.element(e.Value())
+		return s.at(e).Storage(name, typ, value, e.Mutable())
+	case TypeLiteral:
+		typeParameters, members := s.typeParameters(e.TypeParameters()), s.elements(e.Members())
+		return s.at(e).TypeLiteral(typeParameters, members)
+	case CallableTypeMember:
+		parameters, result := s.elements(e.Parameters()), s.element(e.Result())
+		return s.at(e).CallableTypeMember(parameters, result)
+	case SequenceType:
+		elements := s.element(e.Elements())
+		return s.at(e).SequenceType(elements)
+	case OptionalType:
+		target := s.element(e.Target())
+		return s.at(e).OptionalType(target)
+	case ReferenceType:
+		referent := s.element(e.Referent())
+		return s.at(e).ReferenceType(referent)
+	case VocabularyLiteral:
+		members := s.elements(e.Members())
+		return s.at(e).VocabularyLiteral(members)
+	case VocabularyOperatorDeclaration:
+		return s.operatorDeclaration(e)
+	case VocabularyOperatorPrecedence:
+		return s.operatorPrecedence(e)
+	case VocabularyEmbedding:
+		names := s.names(e.Name())
+		return s.at(e).VocabularyEmbedding(names)
+	case Error:
+		return NewError(location.NewLocation(s.pos(e.Start()), s.pos(e.End())), "%s", e.Error())
+	default:
+		assert.Fail("Unknown element %#v", element)
+		return nil
+	}
+}
+
+func (s *shifter) elements(elements []Element) []Element {
+	if elements == nil {
+		return nil
+	}
+	result := make([]Element, len(elements))
+	for index, element := range elements {
+		result[index] = s.element(element)
+	}
+	return result
+}
+
+func (s *shifter) name(name Name) Name {
+	if name == nil {
+		return nil
+	}
+	return s.at(name).Name(name.Text())
+}
+
+func (s *shifter) names(names []Name) []Name {
+	if names == nil {
+		return nil
+	}
+	result := make([]Name, len(names))
+	for index, name := range names {
+		result[index] = s.name(name)
+	}
+	return result
+}
+
+func (s *shifter) parameter(parameter Parameter) Parameter {
+	name, typ, deflt := s.name(parameter.Name()), s.element(parameter.Type()), s.element(parameter.Default())
+	return s.at(parameter).Parameter(name, typ, deflt)
+}
+
+func (s *shifter) parameters(parameters []Parameter) []Parameter {
+	if parameters == nil {
+		return nil
+	}
+	result := make([]Parameter, len(parameters))
+	for index, parameter := range parameters {
+		result[index] = s.parameter(parameter)
+	}
+	return result
+}
+
+func (s *shifter) typeParameter(typeParameter TypeParameter) TypeParameter {
+	name, constraint := s.name(typeParameter.Name()), s.element(typeParameter.Constraint())
+	return s.at(typeParameter).TypeParameter(name, constraint)
+}
+
+func (s *shifter) typeParameters(typeParameters []TypeParameter) []TypeParameter {
+	if typeParameters == nil {
+		return nil
+	}
+	result := make([]TypeParameter, len(typeParameters))
+	for index, typeParameter := range typeParameters {
+		result[index] = s.typeParameter(typeParameter)
+	}
+	return result
+}
+
+func (s *shifter) operatorPrecedence(precedence VocabularyOperatorPrecedence) VocabularyOperatorPrecedence {
+	if precedence == nil {
+		return nil
+	}
+	name := s.name(precedence.Name())
+	return s.at(precedence).VocabularyOperatorPrecedence(name, precedence.Placement(), precedence.Relation())
+}
+
+func (s *shifter) operatorDeclaration(declaration VocabularyOperatorDeclaration) VocabularyOperatorDeclaration {
+	names, precedence := s.names(declaration.Names()), s.operatorPrecedence(declaration.Precedence())
+	return s.at(declaration).VocabularyOperatorDeclaration(
+		names, declaration.Placement(), precedence, declaration.Associativity())
+}
@@ -0,0 +1,88 @@
+package ast_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"dyego0/ast"
+	"dyego0/location"
+)
+
+var _ = Describe("shift", func() {
+	b := ast.NewBuilder(location.NewLocation(0, 1))
+	b.PushContext()
+
+	n := b.Name("n")
+	m := b.Name("m")
+	one := b.Literal(1, "0x1")
+	param := b.Parameter(n, m, one)
+	typeParam := b.TypeParameter(n, m)
+	precedence := b.VocabularyOperatorPrecedence(n, ast.Infix, ast.Before)
+	elements := []ast.Element{
+		n,
+		one,
+		b.Break(n),
+		b.Continue(nil),
+		b.Selection(n, m),
+		b.SafeSelection(n, m),
+		b.Spread(n),
+		b.Call(n, []ast.Element{m, one}),
+		b.NamedArgument(n, one),
+		b.ObjectInitializer(true, n, []ast.Element{m, one}),
+		b.ArrayInitializer(false, nil, []ast.Element{m, one}),
+		b.NamedMemberInitializer(n, m, one),
+		b.Lambda([]ast.TypeParameter{typeParam}, []ast.Parameter{param}, one, nil),
+		b.Instantiation(n, []ast.Element{m, one}),
+		b.IntrinsicLambda([]ast.Parameter{param}, one, m),
+		b.Loop(n, one),
+		b.Return(one),
+		b.When(n, []ast.Element{b.WhenValueClause(n, one), b.WhenElseClause(one)}),
+		b.Definition(n, m, one),
+		b.Storage(n, nil, one, true),
+		b.TypeLiteral([]ast.TypeParameter{typeParam}, []ast.Element{b.CallableTypeMember([]ast.Element{param}, m)}),
+		b.SequenceType(n),
+		b.ReferenceType(n),
+		b.VocabularyLiteral([]ast.Element{
+			b.VocabularyOperatorDeclaration([]ast.Name{n, m}, ast.Infix, precedence, ast.Right),
+			b.VocabularyEmbedding([]ast.Name{n, m}),
+		}),
+		b.Error("msg %d", 1),
+	}
+	sequence := ast.SequenceOf(elements)
+
+	It("moves the location of every element", func() {
+		shifted := ast.Shift(sequence, 10)
+		original := collect(sequence)
+		moved := collect(shifted)
+		Expect(moved).To(HaveLen(len(original)))
+		for index, element := range moved {
+			Expect(fmt.Sprintf("%T", element)).To(Equal(fmt.Sprintf("%T", original[index])))
+			Expect(element.Start()).To(Equal(location.Pos(10)))
+			Expect(element.End()).To(Equal(location.Pos(11)))
+		}
+		Expect(ast.Statements(shifted)[1].(ast.Literal).Spelling()).To(Equal("0x1"))
+		Expect(ast.Statements(shifted)[len(elements)-1].(ast.Error).Error()).To(Equal("msg 1"))
+	})
+	It("does not move invalid positions", func() {
+		invalid := ast.NewBuilder(location.NewLocation(-1, -1))
+		invalid.PushContext()
+		Expect(ast.Shift(invalid.Name("n"), 10).Start()).To(Equal(location.Pos(-1)))
+	})
+	It("returns the element when it is not moved", func() {
+		Expect(ast.Shift(sequence, 0)).To(BeIdenticalTo(sequence))
+		Expect(ast.Shift(nil, 1)).To(BeNil())
+	})
+	It("can split and join a sequence", func() {
+		Expect(ast.Statements(sequence)).To(Equal(elements))
+		Expect(ast.SequenceOf(nil)).To(BeNil())
+		Expect(ast.SequenceOf(elements[:1])).To(BeIdenticalTo(n))
+	})
+})
+
+func collect(element ast.Element) []ast.Element {
+	v := &testVisitor{}
+	ast.Walk(element, v)
+	return v.elements
+}
@@ -2,7 +2,6 @@ package ast
 
 import (
 	"dyego0/assert"
-	"dyego0/errors"
 )
 
 // Visitor is an AST visitor
@@ -31,6 +30,8 @@ func WalkChildren(element Element, visitor Visitor) bool {
 			return true
 		case Selection:
 			return Walk(e.Target(), visitor) && Walk(e.Member(), visitor)
+		case SafeSelection:
+			return Walk(e.Target(), visitor) && Walk(e.Member(), visitor)
 		case Sequence:
 			return Walk(e.Left(), visitor) && Walk(e.Right(), visitor)
 		case Spread:
@@ -50,8 +51,8 @@ func WalkChildren(element Element, visitor Visitor) bool {
 		case NamedMemberInitializer:
 			return Walk(e.Name(), visitor) && Walk(e.Type(), visitor) && Walk(e.Value(), visitor)
 		case Lambda:
-			return walkParameters(e.Parameters(), visitor) && Walk(e.Body(), visitor) &&
-				Walk(e.Result(), visitor)
+			return walkTypeParameters(e.TypeParameters(), visitor) && walkParameters(e.Parameters(), visitor) &&
+				Walk(e.Body(), visitor) && Walk(e.Result(), visitor)
 		case IntrinsicLambda:
 			return walkParameters(e.Parameters(), visitor) && Walk(e.Body(), visitor) &&
 				Walk(e.Result(), visitor)
@@ -59,6 +60,10 @@ func WalkChildren(element Element, visitor Visitor) bool {
 			return Walk(e.Label(), visitor) && Walk(e.Body(), visitor)
 		case Parameter:
 			return Walk(e.Name(), visitor) && Walk(e.Type(), visitor) && Walk(e.Default(), visitor)
+		case TypeParameter:
+			return Walk(e.Name(), visitor) && Walk(e.Constraint(), visitor)
+		case Instantiation:
+			return Walk(e.Target(), visitor) && walkElements(e.TypeArguments(), visitor)
 		case Return:
 			return Walk(e.Value(), visitor)
 		case When:
@@ -72,11 +77,13 @@ func WalkChildren(element Element, visitor Visitor) bool {
 		case Storage:
 			return Walk(e.Name(), visitor) && Walk(e.Type(), visitor) && Walk(e.Value(), visitor)
 		case TypeLiteral:
-			return walkElements(e.Members(), visitor)
+			return walkTypeParameters(e.TypeParameters(), visitor) && walkElements(e.Members(), visitor)
 		case CallableTypeMember:
 			return walkElements(e.Parameters(), visitor) && Walk(e.Result(), visitor)
 		case SequenceType:
 			return Walk(e.Elements(), visitor)
+		case OptionalType:
+			return Walk(e.Target(), visitor)
 		case ReferenceType:
 			return Walk(e.Referent(), visitor)
 		case VocabularyLiteral:
@@ -87,7 +94,7 @@ func WalkChildren(element Element, visitor Visitor) bool {
 			return Walk(e.Name(), visitor)
 		case VocabularyEmbedding:
 			return walkNames(e.Name(), visitor)
-		case errors.Error:
+		case Error:
 			return true
 		default:
 			assert.Fail("Unknown element %#v", element)
@@ -114,6 +121,15 @@ func walkParameters(parameters []Parameter, visitor Visitor) bool {
 	return true
 }
 
+func walkTypeParameters(typeParameters []TypeParameter, visitor Visitor) bool {
+	for _, typeParameter := range typeParameters {
+		if !Walk(typeParameter, visitor) {
+			return false
+		}
+	}
+	return true
+}
+
 func walkNames(names []Name, visitor Visitor) bool {
 	for _, name := range names {
 		if !Walk(name, visitor) {
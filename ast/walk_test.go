@@ -17,7 +17,7 @@ var _ = Describe("walk", func() {
 		expect(n)
 	})
 	It("Literal", func() {
-		expect(b.Literal('a'))
+		expect(b.Literal('a', "'a'"))
 	})
 	brk := b.Break(n)
 	It("Break", func() {
@@ -34,13 +34,16 @@ var _ = Describe("walk", func() {
 	It("Selection", func() {
 		expect(b.Selection(n, m), n, m)
 	})
+	It("SafeSelection", func() {
+		expect(b.SafeSelection(n, m), n, m)
+	})
 	It("Spread", func() {
 		expect(b.Spread(n), n)
 	})
 	It("Call", func() {
 		expect(b.Call(n, []ast.Element{m, m}), n, m, m)
 	})
-	one := b.Literal(1)
+	one := b.Literal(1, "1")
 	It("NamedArgument", func() {
 		expect(b.NamedArgument(n, one), n, one)
 	})
@@ -55,7 +58,12 @@ var _ = Describe("walk", func() {
 	})
 	param := b.Parameter(n, m, one)
 	It("Lambda", func() {
-		expect(b.Lambda([]ast.Parameter{param}, one, nil), param, n, m, one, one)
+		expect(b.Lambda(nil, []ast.Parameter{param}, one, nil), param, n, m, one, one)
+	})
+	It("generic Lambda", func() {
+		typeParam := b.TypeParameter(n, m)
+		expect(b.Lambda([]ast.TypeParameter{typeParam}, []ast.Parameter{param}, one, nil), typeParam, n, m, param, n,
+			m, one, one)
 	})
 	It("IntrinsicLambda", func() {
 		expect(b.IntrinsicLambda([]ast.Parameter{param}, one, m), param, n, m, one, one, m)
@@ -78,6 +86,12 @@ var _ = Describe("walk", func() {
 	It("Parameter", func() {
 		expect(param, n, m, one)
 	})
+	It("TypeParameter", func() {
+		expect(b.TypeParameter(n, m), n, m)
+	})
+	It("Instantiation", func() {
+		expect(b.Instantiation(n, []ast.Element{m}), n, m)
+	})
 	It("Definition", func() {
 		expect(b.Definition(n, m, one), n, m, one)
 	})
@@ -85,7 +99,7 @@ var _ = Describe("walk", func() {
 		expect(b.Storage(n, nil, one, false), n, one)
 	})
 	It("TypeLiteral", func() {
-		expect(b.TypeLiteral([]ast.Element{n}), n)
+		expect(b.TypeLiteral(nil, []ast.Element{n}), n)
 	})
 	It("CallableTypeMember", func() {
 		expect(b.CallableTypeMember([]ast.Element{param}, m), param, n, m, one, m)
@@ -93,6 +107,9 @@ var _ = Describe("walk", func() {
 	It("SequenceType", func() {
 		expect(b.SequenceType(n), n)
 	})
+	It("OptionalType", func() {
+		expect(b.OptionalType(n), n)
+	})
 	It("ReferenceType", func() {
 		expect(b.ReferenceType(n), n)
 	})
@@ -116,7 +133,7 @@ var _ = Describe("walk", func() {
 		stopsAfter(b.VocabularyLiteral([]ast.Element{n, n, n}), 3)
 	})
 	It("Can stop walking parameters early", func() {
-		stopsAfter(b.Lambda([]ast.Parameter{param, param, param}, one, nil), 3)
+		stopsAfter(b.Lambda(nil, []ast.Parameter{param, param, param}, one, nil), 3)
 	})
 	It("Can stop walking names early", func() {
 		stopsAfter(b.VocabularyEmbedding([]ast.Name{n, m, n, m}), 3)
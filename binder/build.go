@@ -17,9 +17,19 @@ type buildVisitor struct {
 	builders            map[symbols.Symbol]symbols.ScopeBuilder
 	openTypeSymbols     map[types.TypeSymbol]ast.Element
 	openElements        map[ast.Element]types.TypeSymbol
+	typeParameters      []types.TypeSymbol
+	spreads             []spread
 	context             *BindingContext
 }
 
+// spread is a type spread into a type literal. The members of target are inserted before the
+// member at index once target is built.
+type spread struct {
+	element ast.Element
+	target  types.TypeSymbol
+	index   int
+}
+
 func newBuilderVisitor(
 	container types.TypeSymbol,
 	scope symbols.Scope,
@@ -39,53 +49,8 @@ func newBuilderVisitor(
 	}
 }
 
-func (v *buildVisitor) findTypeInType(element ast.Element, typeSym types.TypeSymbol) types.TypeSymbol {
-	if types.IsError(typeSym) {
-		return typeSym
-	}
-	t := typeSym.Type()
-	if t == nil {
-		// Type is not built yet, use the builder instead
-		b, ok := v.builders[typeSym]
-		assert.Assert(ok, "Unbuilt type not found in builders")
-		return v.findTypeIn(element, b)
-	}
-	return v.findTypeIn(element, t.TypeScope())
-}
-
-func (v *buildVisitor) findTypeIn(element ast.Element, scope symbols.Scope) types.TypeSymbol {
-	switch n := element.(type) {
-	case ast.Name:
-		sym, ok := scope.Find(n.Text())
-		if !ok {
-			v.context.Error(n, "Undefined symbol %s", n.Text())
-			return types.NewErrorType()
-		}
-		typeSym, ok := sym.(types.TypeSymbol)
-		if !ok {
-			v.context.Error(n, "Expected %s to be a type symbol", n.Text())
-			return types.NewErrorType()
-		}
-		return typeSym
-	case ast.Selection:
-		container := v.findTypeIn(n.Target(), scope)
-		if types.IsError(container) {
-			return container
-		}
-		return v.findTypeInType(n.Member(), container)
-	case ast.SequenceType:
-		elements := v.findTypeIn(n.Elements(), scope)
-		return types.MakeArray(elements)
-	case ast.ReferenceType:
-		referant := v.findTypeIn(n.Referent(), scope)
-		return types.MakeReference(referant)
-	}
-	assert.Fail("Unhandled element type %#v", element)
-	return nil
-}
-
 func (v *buildVisitor) findType(element ast.Element) types.TypeSymbol {
-	return v.findTypeIn(element, v.scope)
+	return v.context.findTypeIn(element, v.scope)
 }
 
 func (v *buildVisitor) openTypeFor(element ast.Element) types.TypeSymbol {
@@ -101,6 +66,7 @@ func (v *buildVisitor) enterMember(element ast.Element, member types.Member) {
 	_, ok := v.membersScopeBuilder.Enter(member)
 	if ok {
 		v.members = append(v.members, member)
+		v.context.Definitions[member] = element
 	} else {
 		v.context.Error(element, "Duplicate member")
 	}
@@ -108,7 +74,9 @@ func (v *buildVisitor) enterMember(element ast.Element, member types.Member) {
 
 func (v *buildVisitor) enterTypeMember(element ast.Element, member types.TypeMember) {
 	_, ok := v.typeScopeBuilder.Enter(member)
-	if !ok {
+	if ok {
+		v.context.Definitions[member] = element
+	} else {
 		v.context.Error(element, "Duplicate member")
 	}
 }
@@ -170,10 +138,8 @@ func (v *buildVisitor) Visit(element ast.Element) bool {
 				assert.Assert(ok, "Expected a type symbol %#v", typeSym)
 				builder, ok := v.builders[typeSym]
 				assert.Assert(ok, "Build missing")
-				nestedScope := symbols.Merge(v.scope, v.typeScopeBuilder)
-				nested := newBuilderVisitor(typeSym, nestedScope, v.context, v.builders, builder)
-				ast.WalkChildren(n.Value(), nested)
-				nested.Done(typeSym, types.Record, v.container)
+				literal := n.Value().(ast.TypeLiteral)
+				v.context.buildType(typeSym, builder, literal, symbols.Merge(v.scope, v.typeScopeBuilder), v.container)
 			} else {
 				var typeSym types.TypeSymbol
 				if n.Type() != nil {
@@ -183,6 +149,8 @@ func (v *buildVisitor) Visit(element ast.Element) bool {
 				}
 				v.enterTypeMember(n, types.NewTypeMember(n.Name().Text(), typeSym))
 			}
+		case ast.CallableTypeMember:
+			v.signatures = append(v.signatures, v.signature(n))
 		}
 		break
 	}
@@ -190,10 +158,39 @@ func (v *buildVisitor) Visit(element ast.Element) bool {
 	return false
 }
 
+// signature builds the signature of a callable type member
+func (v *buildVisitor) signature(member ast.CallableTypeMember) types.Signature {
+	var parameters []types.Parameter
+	names := make(map[string]bool)
+	for _, element := range member.Parameters() {
+		parameter, ok := element.(ast.Parameter)
+		if !ok {
+			continue
+		}
+		name := parameter.Name().Text()
+		if names[name] {
+			v.context.Error(parameter, "Duplicate parameter %s", name)
+		}
+		names[name] = true
+		var typ types.TypeSymbol
+		if parameter.Type() != nil {
+			typ = v.findType(parameter.Type())
+		} else {
+			v.context.Error(parameter, "Parameter %s requires a type", name)
+			typ = types.NewErrorType()
+		}
+		param := types.NewParameter(name, typ)
+		v.context.Definitions[param] = parameter
+		parameters = append(parameters, param)
+	}
+	return types.NewSignature(nil, parameters, v.findType(member.Result()))
+}
+
 func (v *buildVisitor) Done(typeSym types.TypeSymbol, kind types.TypeKind, container types.TypeSymbol) {
-	types.NewType(
+	types.NewGenericType(
 		typeSym,
 		kind,
+		v.typeParameters,
 		v.members,
 		v.membersScopeBuilder.Build(),
 		v.typeScopeBuilder.Build(),
@@ -204,7 +201,408 @@ func (v *buildVisitor) Done(typeSym types.TypeSymbol, kind types.TypeKind, conta
 
 // Build builds the types in the given module
 func (c *BindingContext) Build(moduleSymbol types.TypeSymbol, element ast.Element) {
-	v := newBuilderVisitor(moduleSymbol, c.Scope, c, c.Builders, c.Scope)
+	v := newBuilderVisitor(moduleSymbol, symbols.Merge(c.Scope, c.Outer), c, c.Builders, c.Scope)
 	v.Visit(element)
 	v.Done(moduleSymbol, types.Module, nil)
+	c.completeSpreads()
+	c.completeInstantiations()
+	c.checkReferences()
+}
+
+// checkReferences reports the references to generic types without type arguments found
+// before the generic types were built
+func (c *BindingContext) checkReferences() {
+	for _, r := range c.references {
+		if t := r.typeSym.Type(); t != nil && len(t.TypeParameters()) > 0 {
+			c.Error(r.element, "%s requires %d type arguments", r.typeSym, len(t.TypeParameters()))
+		}
+	}
+	c.references = nil
+}
+
+// buildType builds the type declared by a type literal using builder as its type scope
+func (c *BindingContext) buildType(
+	typeSym types.TypeSymbol,
+	builder symbols.ScopeBuilder,
+	literal ast.TypeLiteral,
+	scope symbols.Scope,
+	container types.TypeSymbol,
+) {
+	typeParameters, nestedScope := c.typeParameters(literal.TypeParameters(), scope)
+	nested := newBuilderVisitor(typeSym, nestedScope, c, c.Builders, builder)
+	nested.typeParameters = typeParameters
+	for _, member := range literal.Members() {
+		if s, ok := member.(ast.Spread); ok {
+			nested.spreads = append(nested.spreads, spread{
+				element: s,
+				target:  nested.findType(s.Target()),
+				index:   len(nested.members),
+			})
+			continue
+		}
+		nested.Visit(member)
+	}
+	c.complete(nested, typeSym, container)
+}
+
+// complete finishes building a record type if the types it spreads are built, otherwise it is
+// completed by completeSpreads
+func (c *BindingContext) complete(v *buildVisitor, typeSym types.TypeSymbol, container types.TypeSymbol) {
+	if !c.spreadsBuilt(v) {
+		c.pending = append(c.pending, pendingType{visitor: v, typeSym: typeSym, container: container})
+		return
+	}
+	v.spreadMembers()
+	v.Done(typeSym, types.Record, container)
+}
+
+// spreadsBuilt reports whether the types spread into the type being built by v are built,
+// completing the instances of generic types that are built
+func (c *BindingContext) spreadsBuilt(v *buildVisitor) bool {
+	for _, s := range v.spreads {
+		if s.target.Type() != nil {
+			continue
+		}
+		generic, _ := types.GenericOf(s.target)
+		if generic == nil || generic.Type() == nil {
+			return false
+		}
+		c.completeInstance(s.target)
+	}
+	return true
+}
+
+// completeInstance builds an instance of a built generic type
+func (c *BindingContext) completeInstance(instance types.TypeSymbol) {
+	for _, i := range c.instantiations {
+		if i.instance != instance {
+			continue
+		}
+		generic, arguments := types.GenericOf(instance)
+		if !c.checkArity(i.element, generic, len(arguments)) {
+			types.UpdateTypeSymbol(instance, types.NewErrorType().Type())
+			return
+		}
+		break
+	}
+	types.Complete(instance)
+	c.defineInstance(instance)
+}
+
+// completeSpreads builds the types that spread types that were not built yet. When no more
+// progress can be made the types spread each other and the unbuilt spreads of one are dropped.
+func (c *BindingContext) completeSpreads() {
+	for len(c.pending) > 0 {
+		pending := c.pending
+		c.pending = nil
+		progress := false
+		for _, p := range pending {
+			if c.spreadsBuilt(p.visitor) {
+				p.visitor.spreadMembers()
+				p.visitor.Done(p.typeSym, types.Record, p.container)
+				progress = true
+			} else {
+				c.pending = append(c.pending, p)
+			}
+		}
+		if progress {
+			continue
+		}
+		v := c.pending[0].visitor
+		var spreads []spread
+		for _, s := range v.spreads {
+			if s.target.Type() == nil {
+				c.Error(s.element, "Type %s is spread recursively", s.target)
+				continue
+			}
+			spreads = append(spreads, s)
+		}
+		v.spreads = spreads
+	}
+}
+
+// spreadMembers inserts the members, type members and signatures of the spread types into the
+// type being built
+func (v *buildVisitor) spreadMembers() {
+	if len(v.spreads) == 0 {
+		return
+	}
+	var members []types.Member
+	next := 0
+	for _, s := range v.spreads {
+		members = append(members, v.members[next:s.index]...)
+		next = s.index
+		if types.IsError(s.target) {
+			continue
+		}
+		t := s.target.Type()
+		if t.Kind() != types.Record {
+			v.context.Error(s.element, "Only record types can be spread but found %s", s.target)
+			continue
+		}
+		for _, member := range t.Members() {
+			if v.spreadMember(s, member) {
+				members = append(members, member)
+			}
+		}
+		t.TypeScope().ForEach(func(sym symbols.Symbol) bool {
+			existing, ok := v.typeScopeBuilder.Find(sym.Name())
+			if !ok {
+				v.typeScopeBuilder.Enter(sym)
+			} else if existing != sym {
+				v.context.Error(s.element, "Type member %s spread from %s conflicts with another type member",
+					sym.Name(), s.target)
+			}
+			return false
+		})
+		for _, signature := range t.Signatures() {
+			if !containsSignature(v.signatures, signature) {
+				v.signatures = append(v.signatures, signature)
+			}
+		}
+	}
+	v.members = append(members, v.members[next:]...)
+}
+
+// spreadMember enters a member spread from another type reporting whether it is new. A member
+// with the same name and type as an existing member is merged with it.
+func (v *buildVisitor) spreadMember(s spread, member types.Member) bool {
+	sym, ok := v.membersScopeBuilder.Find(member.Name())
+	if !ok {
+		v.membersScopeBuilder.Enter(member)
+		return true
+	}
+	existing, _ := sym.(types.Member)
+	if existing != member && (existing == nil || !types.Identical(existing.Type(), member.Type())) {
+		v.context.Error(s.element, "Member %s spread from %s conflicts with another member", member.Name(), s.target)
+	}
+	return false
+}
+
+// containsSignature reports whether signatures has a signature identical to signature
+func containsSignature(signatures []types.Signature, signature types.Signature) bool {
+	for _, existing := range signatures {
+		if types.IdenticalSignatures(existing, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+// compose builds the record type of left & right which has the members of both
+func (c *BindingContext) compose(call ast.Call, left, right types.TypeSymbol) types.TypeSymbol {
+	if types.IsError(left) {
+		return left
+	}
+	if types.IsError(right) {
+		return right
+	}
+	typeSym := types.NewTypeSymbol("", nil)
+	v := newBuilderVisitor(typeSym, nil, c, c.Builders, symbols.NewBuilder())
+	v.spreads = []spread{
+		{element: call.Target().(ast.Selection).Target(), target: left},
+		{element: call.Arguments()[0], target: right},
+	}
+	c.complete(v, typeSym, nil)
+	return typeSym
+}
+
+// anonymousType builds the type of a type literal used as a type reference, such as the type
+// of the parameter f in { f: < { x: Int -> Int } > -> f(1) }
+func (c *BindingContext) anonymousType(literal ast.TypeLiteral, scope symbols.Scope) types.TypeSymbol {
+	typeSym := types.NewTypeSymbol("", nil)
+	builder := symbols.NewBuilder()
+	enter := newEnterVisitor(builder, c.Builders, c.Definitions)
+	for _, member := range literal.Members() {
+		enter.Visit(member)
+	}
+	c.Errors = append(c.Errors, enter.errors...)
+	c.buildType(typeSym, builder, literal, symbols.Merge(scope, builder), nil)
+	return typeSym
+}
+
+// typeParameters creates the type variables of a generic type or lambda and returns them with
+// the scope they are declared in
+func (c *BindingContext) typeParameters(
+	parameters []ast.TypeParameter,
+	scope symbols.Scope,
+) ([]types.TypeSymbol, symbols.Scope) {
+	if len(parameters) == 0 {
+		return nil, scope
+	}
+	builder := symbols.NewBuilder()
+	scope = symbols.Merge(builder, scope)
+	var result []types.TypeSymbol
+	for _, parameter := range parameters {
+		var constraint types.TypeSymbol
+		if parameter.Constraint() != nil {
+			constraint = c.findTypeIn(parameter.Constraint(), scope)
+		}
+		variable := types.MakeTypeVariable(parameter.Name().Text(), constraint)
+		_, ok := builder.Enter(variable)
+		if !ok {
+			c.Error(parameter, "Duplicate type parameter %s", parameter.Name().Text())
+			continue
+		}
+		c.Definitions[variable] = parameter
+		result = append(result, variable)
+	}
+	return result, scope
+}
+
+// instantiate instantiates a generic type with the type arguments of element. The instances of
+// generic types that are not built yet are completed at the end of Build.
+func (c *BindingContext) instantiate(
+	element ast.Instantiation,
+	generic types.TypeSymbol,
+	arguments []types.TypeSymbol,
+) types.TypeSymbol {
+	if types.IsError(generic) {
+		return generic
+	}
+	for _, argument := range arguments {
+		if types.IsError(argument) {
+			return argument
+		}
+	}
+	if generic.Type() != nil && !c.checkArity(element, generic, len(arguments)) {
+		return types.NewErrorType()
+	}
+	instance := types.Instantiate(generic, arguments)
+	c.instantiations = append(c.instantiations, instantiation{element: element, instance: instance})
+	if instance.Type() != nil {
+		c.defineInstance(instance)
+	}
+	return instance
+}
+
+// checkArity checks that generic is a generic type with count type parameters
+func (c *BindingContext) checkArity(element ast.Instantiation, generic types.TypeSymbol, count int) bool {
+	typeParameters := generic.Type().TypeParameters()
+	if len(typeParameters) == 0 {
+		c.Error(element, "%s is not generic", generic)
+		return false
+	}
+	if len(typeParameters) != count {
+		c.Error(element, "Expected %d type arguments but found %d", len(typeParameters), count)
+		return false
+	}
+	return true
+}
+
+// completeInstantiations builds the instances of generic types that were instantiated before
+// the generic type was built
+func (c *BindingContext) completeInstantiations() {
+	for _, i := range c.instantiations {
+		if i.instance.Type() != nil {
+			continue
+		}
+		generic, arguments := types.GenericOf(i.instance)
+		if generic.Type() == nil || !c.checkArity(i.element, generic, len(arguments)) {
+			types.UpdateTypeSymbol(i.instance, types.NewErrorType().Type())
+			continue
+		}
+		types.Complete(i.instance)
+		c.defineInstance(i.instance)
+	}
+}
+
+// defineInstance records the declarations of the members of a generic type as the
+// declarations of the members of its instance
+func (c *BindingContext) defineInstance(instance types.TypeSymbol) {
+	generic, _ := types.GenericOf(instance)
+	members := generic.Type().Members()
+	for index, member := range instance.Type().Members() {
+		if _, ok := c.Definitions[member]; ok {
+			continue
+		}
+		if definition, ok := c.Definitions[members[index]]; ok {
+			c.Definitions[member] = definition
+		}
+	}
+}
+
+func (c *BindingContext) findTypeInType(element ast.Element, typeSym types.TypeSymbol) types.TypeSymbol {
+	if types.IsError(typeSym) {
+		return typeSym
+	}
+	t := typeSym.Type()
+	if t == nil {
+		// Type is not built yet, use the builder instead
+		b, ok := c.Builders[typeSym]
+		assert.Assert(ok, "Unbuilt type not found in builders")
+		return c.findGenericTypeIn(element, b)
+	}
+	return c.findGenericTypeIn(element, t.TypeScope())
+}
+
+// findTypeIn finds the type referenced by element. Generic types must be given type arguments.
+func (c *BindingContext) findTypeIn(element ast.Element, scope symbols.Scope) types.TypeSymbol {
+	result := c.findGenericTypeIn(element, scope)
+	switch element.(type) {
+	case ast.Name, ast.Selection:
+		if t := result.Type(); t != nil && len(t.TypeParameters()) > 0 {
+			c.Error(element, "%s requires %d type arguments", result, len(t.TypeParameters()))
+			return types.NewErrorType()
+		}
+		if result.Type() == nil {
+			c.references = append(c.references, typeReference{element: element, typeSym: result})
+		}
+	}
+	return result
+}
+
+// findGenericTypeIn finds the type referenced by element which can be a generic type without
+// type arguments
+func (c *BindingContext) findGenericTypeIn(element ast.Element, scope symbols.Scope) types.TypeSymbol {
+	switch n := element.(type) {
+	case ast.Name:
+		sym, ok := scope.Find(n.Text())
+		if !ok {
+			c.Error(n, "Undefined symbol %s", n.Text())
+			return types.NewErrorType()
+		}
+		typeSym, ok := sym.(types.TypeSymbol)
+		if !ok {
+			c.Error(n, "Expected %s to be a type symbol", n.Text())
+			return types.NewErrorType()
+		}
+		c.References[n] = typeSym
+		return typeSym
+	case ast.Selection:
+		container := c.findGenericTypeIn(n.Target(), scope)
+		if types.IsError(container) {
+			return container
+		}
+		return c.findTypeInType(n.Member(), container)
+	case ast.SequenceType:
+		elements := c.findTypeIn(n.Elements(), scope)
+		return types.MakeArray(elements)
+	case ast.ReferenceType:
+		referant := c.findTypeIn(n.Referent(), scope)
+		return types.MakeReference(referant)
+	case ast.OptionalType:
+		target := c.findTypeIn(n.Target(), scope)
+		return types.MakeOptional(target)
+	case ast.Instantiation:
+		generic := c.findGenericTypeIn(n.Target(), scope)
+		var arguments []types.TypeSymbol
+		for _, argument := range n.TypeArguments() {
+			arguments = append(arguments, c.findTypeIn(argument, scope))
+		}
+		return c.instantiate(n, generic, arguments)
+	case ast.TypeLiteral:
+		return c.anonymousType(n, scope)
+	case ast.Call:
+		if selection, ok := n.Target().(ast.Selection); ok && selection.Member().Text() == "&" &&
+			len(n.Arguments()) == 1 {
+			left := c.findTypeIn(selection.Target(), scope)
+			right := c.findTypeIn(n.Arguments()[0], scope)
+			return c.compose(n, left, right)
+		}
+	case ast.Error:
+		return types.NewErrorType()
+	}
+	assert.Fail("Unhandled element type %#v", element)
+	return nil
 }
@@ -48,6 +48,26 @@ var _ = Describe("Build", func() {
 		af := findMember(at, "a")
 		Expect(af).To(Not(BeNil()))
 	})
+	It("can build a type with multiple members", func() {
+		module := m("let a = < a: Int, b: Int, let c = 1 >")
+		at := findType(module, "a")
+		Expect(findMember(at, "a")).To(Not(BeNil()))
+		Expect(findMember(at, "b")).To(Not(BeNil()))
+		Expect(findTypeMember(at, "c")).To(Not(BeNil()))
+	})
+	It("can build a type with an optional member", func() {
+		module := m("let a = < b: Int? >")
+		b := findMember(findType(module, "a"), "b")
+		Expect(b.Type().Type().Kind()).To(Equal(types.Optional))
+		Expect(b.Type().String()).To(Equal("moule.Int?"))
+	})
+	It("can build a type with callable members", func() {
+		module := m("let a = < { x: Int -> Int } >")
+		signatures := findType(module, "a").Signatures()
+		Expect(signatures).To(HaveLen(1))
+		Expect(signatures[0].Parameters()[0].Name()).To(Equal("x"))
+		Expect(signatures[0].String()).To(Equal("{x: moule.Int -> moule.Int}"))
+	})
 	It("can build module literal", func() {
 		modules := m("let a = 1")
 		am := findTypeMember(modules, "a")
@@ -68,6 +88,32 @@ var _ = Describe("Build", func() {
 		ma := findMember(modules, "a")
 		Expect(ma).To(Not(BeNil()))
 	})
+	It("can spread the members of another type", func() {
+		module := m("let A = < a: Int, let T = <>, { x: Int -> Int } >\nlet B = < b: Int, ...A, c: Int >")
+		bt := findType(module, "B")
+		var names []string
+		for _, member := range bt.Members() {
+			names = append(names, member.Name())
+		}
+		Expect(names).To(Equal([]string{"b", "a", "c"}))
+		Expect(findMember(bt, "a")).To(Equal(findMember(findType(module, "A"), "a")))
+		Expect(findType(bt, "T")).To(Equal(findType(findType(module, "A"), "T")))
+		Expect(bt.Signatures()).To(HaveLen(1))
+	})
+	It("can spread a type declared later", func() {
+		module := m("let B = < ...A, b: Int >\nlet A = < ...C, a: Int >\nlet C = < c: Int >")
+		Expect(findType(module, "B").Members()).To(HaveLen(3))
+	})
+	It("can spread an instance of a generic type", func() {
+		module := m("let B = < ...Box<Int> >\nlet Box = < X -> value: X >")
+		Expect(findMember(findType(module, "B"), "value").Type().String()).To(Equal("moule.Int"))
+	})
+	It("can compose types", func() {
+		module := m("let A = < a: Int >\nlet B = < b: Int >\nvar ab: A & B")
+		ab := findMember(module, "ab").Type()
+		Expect(ab.String()).To(Equal("<a: moule.Int, b: moule.Int>"))
+		Expect(findMember(ab.Type(), "b")).To(Equal(findMember(findType(module, "B"), "b")))
+	})
 	It("can create a sequence reference", func() {
 		modules := m("var a: Int[]")
 		ma := findMember(modules, "a")
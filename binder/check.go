@@ -0,0 +1,1459 @@
+package binder
+
+import (
+	"dyego0/assert"
+	"dyego0/ast"
+	"dyego0/symbols"
+	"dyego0/types"
+)
+
+// declarationState tracks the progress of checking a declaration
+type declarationState int
+
+const (
+	unchecked declarationState = iota
+	checking
+	checked
+)
+
+// declaration is a type member or field declared in a module or type literal
+type declaration struct {
+	member types.Member
+	name   ast.Name
+	value  ast.Element
+	scope  symbols.Scope
+	state  declarationState
+
+	// this is the type declaring the member, if the member is not declared by a module
+	this types.TypeSymbol
+}
+
+// function is the context of the lambda, or module, being checked
+type function struct {
+	result   types.TypeSymbol
+	inferred types.TypeSymbol
+	loops    []ast.Loop
+	isModule bool
+}
+
+// narrowed is a symbol known not to be null in a scope. References to it have the type of the
+// target of the symbol's optional type.
+type narrowed struct {
+	symbol symbols.Symbol
+	typ    types.TypeSymbol
+}
+
+func (n *narrowed) Name() string {
+	return n.symbol.Name()
+}
+
+// localScope is a block scope where locals are entered as they are declared
+type localScope struct {
+	symbols.Scope
+	builder symbols.ScopeBuilder
+}
+
+func newLocalScope(parent symbols.Scope) *localScope {
+	builder := symbols.NewBuilder()
+	return &localScope{Scope: symbols.Merge(builder, parent), builder: builder}
+}
+
+type checker struct {
+	context         *BindingContext
+	scope           symbols.Scope
+	declarations    map[ast.Element]*declaration
+	members         map[ast.Element]bool
+	typeScopes      map[ast.Element]symbols.Scope
+	open            map[types.TypeSymbol]*declaration
+	aliases         map[types.Member]bool
+	typeExpressions map[ast.Element]bool
+	builtins        map[string]types.TypeSymbol
+	function        *function
+}
+
+func newChecker(context *BindingContext, scope symbols.Scope) *checker {
+	return &checker{
+		context:         context,
+		scope:           scope,
+		declarations:    make(map[ast.Element]*declaration),
+		members:         make(map[ast.Element]bool),
+		typeScopes:      make(map[ast.Element]symbols.Scope),
+		open:            make(map[types.TypeSymbol]*declaration),
+		aliases:         make(map[types.Member]bool),
+		typeExpressions: make(map[ast.Element]bool),
+		builtins:        make(map[string]types.TypeSymbol),
+		function:        &function{isModule: true},
+	}
+}
+
+// forEachStatement calls block for each element of a sequence
+func forEachStatement(element ast.Element, block func(element ast.Element)) {
+	for {
+		sequence, ok := element.(ast.Sequence)
+		if !ok {
+			break
+		}
+		forEachStatement(sequence.Left(), block)
+		element = sequence.Right() // Simulated tail call
+	}
+	if element != nil {
+		block(element)
+	}
+}
+
+// typeScopeOf is the scope used to check the members declared by typeSym
+func typeScopeOf(typeSym types.TypeSymbol, outer symbols.Scope) symbols.Scope {
+	t := typeSym.Type()
+	this := symbols.NewBuilder()
+	this.Enter(types.NewParameter("this", typeSym))
+	for _, typeParameter := range t.TypeParameters() {
+		this.Enter(typeParameter)
+	}
+	return symbols.Merge(this, t.MemberScope(), t.TypeScope(), outer)
+}
+
+// declare records the fields and type members declared in members so the types of untyped
+// declarations can be inferred on demand
+func (k *checker) declare(container types.Type, scope symbols.Scope, members []ast.Element) {
+	var this types.TypeSymbol
+	if container.Kind() != types.Module {
+		this = container.Symbol()
+	}
+	for _, element := range members {
+		k.members[element] = true
+		switch n := element.(type) {
+		case ast.Definition:
+			sym, ok := container.TypeScope().Find(n.Name().Text())
+			if !ok || k.context.Definitions[sym] != element {
+				continue
+			}
+			if typeSym, ok := sym.(types.TypeSymbol); ok {
+				if typ, ok := n.Value().(ast.TypeLiteral); ok && typeSym.Type() != nil {
+					nested := typeScopeOf(typeSym, scope)
+					k.typeScopes[element] = nested
+					k.declare(typeSym.Type(), nested, typ.Members())
+				}
+				continue
+			}
+			if _, ok := n.Value().(ast.VocabularyLiteral); ok {
+				// Vocabularies are used by the parser and have no value
+				continue
+			}
+			if member, ok := sym.(types.Member); ok {
+				k.enterDeclaration(element, &declaration{
+					member: member,
+					name:   n.Name(),
+					value:  n.Value(),
+					scope:  scope,
+					this:   this,
+				})
+			}
+		case ast.Storage:
+			sym, ok := container.MemberScope().Find(n.Name().Text())
+			if !ok || k.context.Definitions[sym] != element {
+				continue
+			}
+			if member, ok := sym.(types.Member); ok {
+				k.enterDeclaration(element, &declaration{
+					member: member,
+					name:   n.Name(),
+					value:  n.Value(),
+					scope:  scope,
+					this:   this,
+				})
+			}
+		}
+	}
+}
+
+func (k *checker) enterDeclaration(element ast.Element, d *declaration) {
+	k.declarations[element] = d
+	if d.member.Type().Type() == nil {
+		k.open[d.member.Type()] = d
+	}
+}
+
+// checkDeclaration checks the value of a declaration inferring the type of the declaration if
+// it was not given explicitly
+func (k *checker) checkDeclaration(d *declaration) {
+	if d.state != unchecked {
+		return
+	}
+	d.state = checking
+	previous := k.function
+	k.function = &function{}
+	defer func() {
+		k.function = previous
+		d.state = checked
+	}()
+
+	typeSym := d.member.Type()
+	if typeSym.Type() != nil {
+		if d.value != nil {
+			k.value(d.value, d.scope, typeSym)
+		}
+		return
+	}
+	if d.value == nil {
+		k.context.Error(d.name, "%s requires a type or a value", d.member.Name())
+		types.UpdateTypeSymbol(typeSym, types.NewErrorType().Type())
+		return
+	}
+	var result types.TypeSymbol
+	switch value := d.value.(type) {
+	case ast.Lambda:
+		result = k.lambda(value, d.this, value.TypeParameters(), value.Parameters(), value.Body(), value.Result(),
+			d.scope, nil, typeSym)
+	case ast.IntrinsicLambda:
+		result = k.lambda(value, d.this, nil, value.Parameters(), nil, value.Result(), d.scope, nil, typeSym)
+	default:
+		result = k.expression(d.value, d.scope, nil)
+		if result == nil {
+			k.context.Error(d.value, "Expression does not produce a value")
+			result = types.NewErrorType()
+		}
+		if k.typeExpressions[d.value] {
+			k.aliases[d.member] = true
+		}
+	}
+	if typeSym.Type() == nil {
+		types.UpdateTypeSymbol(typeSym, k.resolve(d.value, result).Type())
+	}
+}
+
+// resolve ensures the type of an untyped declaration has been inferred
+func (k *checker) resolve(element ast.Element, typeSym types.TypeSymbol) types.TypeSymbol {
+	if typeSym.Type() != nil {
+		return typeSym
+	}
+	d, ok := k.open[typeSym]
+	if !ok {
+		return types.NewErrorType()
+	}
+	if d.state == checking {
+		k.context.Error(element, "%s is used recursively and requires an explicit type", d.member.Name())
+		return types.NewErrorType()
+	}
+	k.checkDeclaration(d)
+	if typeSym.Type() == nil {
+		return types.NewErrorType()
+	}
+	return typeSym
+}
+
+// builtin finds the builtin type with the given name
+func (k *checker) builtin(element ast.Element, name string) types.TypeSymbol {
+	result, ok := k.builtins[name]
+	if ok {
+		return result
+	}
+	sym, ok := k.scope.Find(name)
+	if ok {
+		result, ok = sym.(types.TypeSymbol)
+	}
+	if !ok {
+		k.context.Error(element, "Undefined symbol %s", name)
+		result = types.NewErrorType()
+	}
+	k.builtins[name] = result
+	return result
+}
+
+// literalType is the builtin type of the value of a literal. Character literals are scanned
+// as runes and are currently indistinguishable from Int.
+func (k *checker) literalType(literal ast.Literal) types.TypeSymbol {
+	switch literal.Value().(type) {
+	case bool:
+		return k.builtin(literal, "Boolean")
+	case byte:
+		return k.builtin(literal, "Byte")
+	case int, int32:
+		return k.builtin(literal, "Int")
+	case uint, uint32:
+		return k.builtin(literal, "UInt")
+	case int64:
+		return k.builtin(literal, "Long")
+	case uint64:
+		return k.builtin(literal, "ULong")
+	case float32:
+		return k.builtin(literal, "Float")
+	case float64:
+		return k.builtin(literal, "Double")
+	case string:
+		return k.builtin(literal, "String")
+	}
+	assert.Fail("Unknown literal value %#v", literal.Value())
+	return nil
+}
+
+// null is the type of a null literal, which is the optional type expected
+func (k *checker) null(literal ast.Literal, expected types.TypeSymbol) types.TypeSymbol {
+	if expected == nil {
+		k.context.Error(literal, "The type of null must be given")
+		return types.NewErrorType()
+	}
+	if !types.IsError(expected) && !types.IsOptional(expected) {
+		k.context.Error(literal, "%s cannot be null", expected)
+		return types.NewErrorType()
+	}
+	return expected
+}
+
+// nonOptional is the target of typeSym if it is an optional type or typeSym otherwise
+func nonOptional(typeSym types.TypeSymbol) types.TypeSymbol {
+	if typeSym != nil && types.IsOptional(typeSym) {
+		return typeSym.Type().Target()
+	}
+	return typeSym
+}
+
+// value checks element as an expression that must produce a value of the expected type, if
+// one is given
+func (k *checker) value(element ast.Element, scope symbols.Scope, expected types.TypeSymbol) types.TypeSymbol {
+	result := k.expression(element, scope, expected)
+	if result == nil {
+		k.context.Error(element, "Expression does not produce a value")
+		return types.NewErrorType()
+	}
+	if k.typeExpressions[element] {
+		k.context.Error(element, "Expected a value but found type %s", result)
+		return types.NewErrorType()
+	}
+	if expected != nil && !types.AssignableTo(result, expected) {
+		k.context.Error(element, "Expected a value of type %s but found %s", expected, result)
+	}
+	k.convert(element, result, expected)
+	return result
+}
+
+// convert records the conversion of the record value of element to another record type
+func (k *checker) convert(element ast.Element, result, expected types.TypeSymbol) {
+	result, expected = nonOptional(result), nonOptional(expected)
+	if expected == nil || result.Type() == nil || expected.Type() == nil || types.Identical(result, expected) {
+		return
+	}
+	if result.Type().Kind() == types.Record && expected.Type().Kind() == types.Record {
+		k.context.Conversions[element] = expected
+	}
+}
+
+// expression checks element and returns its type or nil if the expression does not produce
+// a value. The expected type, if given, is used to infer the type of initializers and lambdas.
+func (k *checker) expression(element ast.Element, scope symbols.Scope, expected types.TypeSymbol) types.TypeSymbol {
+	result := k.check(element, scope, expected)
+	if result != nil {
+		k.context.Types[element] = result
+	}
+	return result
+}
+
+func (k *checker) check(element ast.Element, scope symbols.Scope, expected types.TypeSymbol) types.TypeSymbol {
+	switch n := element.(type) {
+	case ast.Sequence:
+		k.expression(n.Left(), scope, nil)
+		return k.expression(n.Right(), scope, expected)
+	case ast.Literal:
+		if n.Value() == nil {
+			return k.null(n, expected)
+		}
+		return k.literalType(n)
+	case ast.Error:
+		// Already reported by the parser
+		return types.NewErrorType()
+	case ast.Name:
+		sym, ok := scope.Find(n.Text())
+		if !ok {
+			k.context.Error(n, "Undefined symbol %s", n.Text())
+			return types.NewErrorType()
+		}
+		if narrowing, ok := sym.(*narrowed); ok {
+			k.context.References[n] = narrowing.symbol
+			return narrowing.typ
+		}
+		k.context.References[n] = sym
+		return k.symbolType(n, sym)
+	case ast.Selection:
+		target := k.expression(n.Target(), scope, nil)
+		if target == nil {
+			k.context.Error(n.Target(), "Expression does not produce a value")
+			return types.NewErrorType()
+		}
+		return k.selection(n, n.Member(), target, k.typeExpressions[n.Target()])
+	case ast.SafeSelection:
+		return optionalOf(k.safeSelection(n, scope))
+	case ast.Call:
+		return k.call(n, scope)
+	case ast.Instantiation:
+		return k.instantiation(n, scope)
+	case ast.Lambda:
+		return k.lambda(n, nil, n.TypeParameters(), n.Parameters(), n.Body(), n.Result(), scope, expected, nil)
+	case ast.IntrinsicLambda:
+		return k.lambda(n, nil, nil, n.Parameters(), nil, n.Result(), scope, expected, nil)
+	case ast.ObjectInitializer:
+		return k.objectInitializer(n, scope, expected)
+	case ast.ArrayInitializer:
+		return k.arrayInitializer(n, scope, expected)
+	case ast.When:
+		return k.when(n, scope, expected)
+	case ast.Loop:
+		f := k.function
+		f.loops = append(f.loops, n)
+		k.expression(n.Body(), newLocalScope(scope), nil)
+		f.loops = f.loops[:len(f.loops)-1]
+	case ast.Break:
+		k.loopOf(n, n.Label(), "break")
+	case ast.Continue:
+		k.loopOf(n, n.Label(), "continue")
+	case ast.Return:
+		k.returnValue(n, scope)
+	case ast.Storage:
+		if d, ok := k.declarations[n]; ok {
+			k.checkDeclaration(d)
+			return nil
+		}
+		if k.members[n] {
+			// A duplicate member already reported by Build
+			return nil
+		}
+		k.local(n, n.Name(), n.Type(), n.Value(), n.Mutable(), scope)
+	case ast.Definition:
+		if d, ok := k.declarations[n]; ok {
+			k.checkDeclaration(d)
+			return nil
+		}
+		if isTypeDeclaration(n) {
+			k.typeDeclaration(n)
+			return nil
+		}
+		if k.members[n] {
+			// A duplicate member already reported by Build
+			return nil
+		}
+		k.local(n, n.Name(), n.Type(), n.Value(), false, scope)
+	}
+	return nil
+}
+
+// typeDeclaration checks the declarations of the members of a type literal
+func (k *checker) typeDeclaration(definition ast.Definition) {
+	nested, ok := k.typeScopes[definition]
+	if !ok {
+		k.context.Error(definition, "Types can only be declared in modules and types")
+		return
+	}
+	for _, member := range definition.Value().(ast.TypeLiteral).Members() {
+		k.expression(member, nested, nil)
+	}
+}
+
+// local declares a local variable in a lambda or block
+func (k *checker) local(
+	element ast.Element,
+	name ast.Name,
+	typeReference ast.Element,
+	value ast.Element,
+	mutable bool,
+	scope symbols.Scope,
+) {
+	var typ types.TypeSymbol
+	if typeReference != nil {
+		typ = k.context.findTypeIn(typeReference, scope)
+	}
+	if value != nil {
+		result := k.value(value, scope, typ)
+		if typ == nil {
+			typ = result
+		}
+	}
+	if typ == nil {
+		k.context.Error(element, "%s requires a type or a value", name.Text())
+		typ = types.NewErrorType()
+	}
+	field := types.NewField(name.Text(), typ, mutable)
+	local, ok := scope.(*localScope)
+	if !ok {
+		k.context.Error(element, "Locals can only be declared in lambdas and blocks")
+		return
+	}
+	_, ok = local.builder.Enter(field)
+	if !ok {
+		k.context.Error(element, "Duplicate symbol")
+		return
+	}
+	k.context.Definitions[field] = element
+}
+
+// symbolType is the type of the value referenced by sym
+func (k *checker) symbolType(element ast.Element, sym symbols.Symbol) types.TypeSymbol {
+	switch s := sym.(type) {
+	case types.TypeSymbol:
+		k.typeExpressions[element] = true
+		return s
+	case types.Member:
+		result := k.resolve(element, s.Type())
+		if k.aliases[s] {
+			k.typeExpressions[element] = true
+		}
+		return result
+	case types.Parameter:
+		return s.Type()
+	}
+	assert.Fail("Unknown symbol %#v", sym)
+	return nil
+}
+
+// selection finds the type of the member selected from target
+func (k *checker) selection(
+	selection ast.Element,
+	member ast.Name,
+	target types.TypeSymbol,
+	isType bool,
+) types.TypeSymbol {
+	if types.IsError(target) {
+		return target
+	}
+	name := member.Text()
+	t := target.Type()
+	if !isType && t.Kind() == types.Optional {
+		k.context.Error(member, "%s can be null and must be checked for null before selecting %s",
+			target, name)
+		return types.NewErrorType()
+	}
+	if isType {
+		sym, ok := t.TypeScope().Find(name)
+		if !ok && t.Kind() == types.Module {
+			// The fields of a module are in static memory so they can be selected from the module
+			sym, ok = t.MemberScope().Find(name)
+		}
+		if ok {
+			k.context.References[member] = sym
+			return k.symbolType(selection, sym)
+		}
+	} else {
+		sym, ok := t.MemberScope().Find(name)
+		if !ok {
+			sym, ok = t.TypeScope().Find(name)
+			if ok {
+				_, ok = sym.(types.TypeMember)
+			}
+		}
+		if ok {
+			k.context.References[member] = sym
+			return k.symbolType(selection, sym)
+		}
+		if t.Kind() == types.Array {
+			result := k.arrayMember(selection, t, name)
+			if result != nil {
+				return result
+			}
+		}
+	}
+	k.context.Error(member, "%s does not have a member %s", t.DisplayName(), name)
+	return types.NewErrorType()
+}
+
+// safeSelection finds the type of a member selected from a target that can be null. The
+// selection is null when the target is, so its value is optional.
+func (k *checker) safeSelection(selection ast.SafeSelection, scope symbols.Scope) types.TypeSymbol {
+	target := k.value(selection.Target(), scope, nil)
+	if types.IsError(target) {
+		return target
+	}
+	if k.typeExpressions[selection.Target()] || !types.IsOptional(target) {
+		k.context.Error(selection.Target(), "%s cannot be null and must be selected with .", target)
+		return types.NewErrorType()
+	}
+	return k.selection(selection, selection.Member(), nonOptional(target), false)
+}
+
+// optionalOf is the optional type of typeSym, or typeSym if it is an error
+func optionalOf(typeSym types.TypeSymbol) types.TypeSymbol {
+	if typeSym == nil || types.IsError(typeSym) {
+		return typeSym
+	}
+	return types.MakeOptional(typeSym)
+}
+
+// arrayMember is the type of the intrinsic members of arrays
+func (k *checker) arrayMember(element ast.Element, array types.Type, name string) types.TypeSymbol {
+	switch name {
+	case "get":
+		return lambdaType(
+			[]types.Parameter{types.NewParameter("index", k.builtin(element, "Int"))},
+			array.Elements(),
+		)
+	case "set":
+		return lambdaType(
+			[]types.Parameter{
+				types.NewParameter("index", k.builtin(element, "Int")),
+				types.NewParameter("value", array.Elements()),
+			},
+			array.Elements(),
+		)
+	case "size":
+		return k.builtin(element, "Int")
+	}
+	return nil
+}
+
+// lambdaType creates the type of a lambda with the given parameters and result
+func lambdaType(parameters []types.Parameter, result types.TypeSymbol) types.TypeSymbol {
+	return types.MakeFunction(types.NewSignature(nil, parameters, result))
+}
+
+// signatureOf returns the single signature of a callable type
+func signatureOf(typeSym types.TypeSymbol) types.Signature {
+	if typeSym == nil || typeSym.Type() == nil {
+		return nil
+	}
+	signatures := typeSym.Type().Signatures()
+	if len(signatures) != 1 {
+		return nil
+	}
+	return signatures[0]
+}
+
+// lambda checks a lambda or an intrinsic lambda. If typeSym is given it is updated with the
+// type of the lambda as soon as it is known allowing the lambda to be called recursively. This
+// is the type the lambda is a member of, if any.
+func (k *checker) lambda(
+	element ast.Element,
+	this types.TypeSymbol,
+	typeParameters []ast.TypeParameter,
+	parameters []ast.Parameter,
+	body ast.Element,
+	result ast.Element,
+	scope symbols.Scope,
+	expected types.TypeSymbol,
+	typeSym types.TypeSymbol,
+) types.TypeSymbol {
+	typeParams, scope := k.context.typeParameters(typeParameters, scope)
+	expectedSignature := signatureOf(nonOptional(expected))
+	if expectedSignature != nil && len(expectedSignature.Parameters()) != len(parameters) {
+		expectedSignature = nil
+	}
+	bodyScope := newLocalScope(scope)
+	var params []types.Parameter
+	for i, parameter := range parameters {
+		var typ types.TypeSymbol
+		if parameter.Type() != nil {
+			typ = k.context.findTypeIn(parameter.Type(), scope)
+		} else if expectedSignature != nil {
+			typ = expectedSignature.Parameters()[i].Type()
+		} else {
+			k.context.Error(parameter, "Parameter %s requires a type", parameter.Name().Text())
+			typ = types.NewErrorType()
+		}
+		if parameter.Default() != nil {
+			k.value(parameter.Default(), scope, typ)
+		}
+		param := types.NewParameter(parameter.Name().Text(), typ)
+		params = append(params, param)
+		_, ok := bodyScope.builder.Enter(param)
+		if !ok {
+			k.context.Error(parameter, "Duplicate parameter %s", parameter.Name().Text())
+		}
+		k.context.Definitions[param] = parameter
+	}
+	var resultType types.TypeSymbol
+	if result != nil {
+		resultType = k.context.findTypeIn(result, scope)
+	} else if expectedSignature != nil {
+		resultType = expectedSignature.Result()
+	}
+	var lambdaSym types.TypeSymbol
+	update := func() {
+		lambdaSym = types.MakeFunction(types.NewGenericSignature(typeParams, this, params, resultType))
+		if typeSym != nil && typeSym.Type() == nil {
+			types.UpdateTypeSymbol(typeSym, lambdaSym.Type())
+		}
+	}
+	if resultType != nil || body == nil {
+		update()
+	}
+	if body == nil {
+		if intrinsic, ok := element.(ast.IntrinsicLambda); ok {
+			k.intrinsic(intrinsic, params, resultType, scope)
+		}
+		return lambdaSym
+	}
+
+	previous := k.function
+	f := &function{result: resultType}
+	k.function = f
+	bodyResult := k.expression(body, bodyScope, resultType)
+	k.function = previous
+
+	if resultType != nil {
+		if bodyResult != nil && !k.typeExpressions[body] && !types.AssignableTo(bodyResult, resultType) {
+			k.context.Error(body, "Expected a value of type %s but found %s", resultType, bodyResult)
+		}
+		if bodyResult != nil {
+			k.convert(body, bodyResult, resultType)
+		}
+		return lambdaSym
+	}
+	resultType = f.inferred
+	if resultType == nil && !k.typeExpressions[body] {
+		resultType = bodyResult
+	}
+	update()
+	return lambdaSym
+}
+
+// returnValue checks a return against the result of the enclosing lambda
+func (k *checker) returnValue(element ast.Return, scope symbols.Scope) {
+	f := k.function
+	if element.Value() == nil {
+		if f.result != nil {
+			k.context.Error(element, "Expected a value of type %s to be returned", f.result)
+		}
+		return
+	}
+	if f.isModule {
+		k.value(element.Value(), scope, nil)
+		return
+	}
+	expected := f.result
+	if expected == nil {
+		expected = f.inferred
+	}
+	result := k.value(element.Value(), scope, expected)
+	if expected == nil {
+		f.inferred = result
+	}
+}
+
+// loopOf checks that a break or continue is in a loop with the given label
+func (k *checker) loopOf(element ast.Element, label ast.Name, kind string) {
+	loops := k.function.loops
+	if len(loops) == 0 {
+		k.context.Error(element, "%s must be in a loop", kind)
+		return
+	}
+	if label == nil {
+		return
+	}
+	for i := len(loops) - 1; i >= 0; i-- {
+		loopLabel := loops[i].Label()
+		if loopLabel != nil && loopLabel.Text() == label.Text() {
+			return
+		}
+	}
+	k.context.Error(label, "Undefined label %s", label.Text())
+}
+
+// when checks a when expression. A when produces a value only if it has an else clause and
+// all of its clauses produce a value of the same type.
+func (k *checker) when(element ast.When, scope symbols.Scope, expected types.TypeSymbol) types.TypeSymbol {
+	var target types.TypeSymbol
+	if element.Target() != nil {
+		target = k.value(element.Target(), scope, nil)
+	} else {
+		target = k.builtin(element, "Boolean")
+	}
+	var result types.TypeSymbol
+	consistent := true
+	hasElse := false
+	first := true
+	// The scope of the remaining clauses in which the previous clauses are known not to match
+	rest := scope
+	for _, clause := range element.Clauses() {
+		var body ast.Element
+		bodyScope := rest
+		switch c := clause.(type) {
+		case ast.WhenValueClause:
+			k.value(c.Value(), rest, target)
+			body = c.Body()
+			if element.Target() == nil {
+				bodyScope = k.narrow(c.Value(), rest, true)
+				rest = k.narrow(c.Value(), rest, false)
+			} else if isNull(c.Value()) {
+				rest = k.nonNull(element.Target(), rest)
+			}
+		case ast.WhenElseClause:
+			hasElse = true
+			body = c.Body()
+		default:
+			continue
+		}
+		var bodyResult types.TypeSymbol
+		if body != nil {
+			bodyResult = k.expression(body, newLocalScope(bodyScope), expected)
+		}
+		if first {
+			result = bodyResult
+			first = false
+		} else if result == nil || bodyResult == nil || !types.Identical(result, bodyResult) {
+			consistent = false
+		}
+	}
+	if !hasElse || !consistent {
+		return nil
+	}
+	return result
+}
+
+// call checks a call to a lambda or an operator
+func (k *checker) call(call ast.Call, scope symbols.Scope) types.TypeSymbol {
+	if selection, ok := call.Target().(ast.Selection); ok && len(call.Arguments()) == 1 {
+		switch selection.Member().Text() {
+		case "=":
+			return k.assignment(call, selection, scope)
+		case "&&", "||":
+			boolean := k.builtin(call, "Boolean")
+			k.value(selection.Target(), scope, boolean)
+			// The right operand is only evaluated when the left operand is true for && and false for ||
+			right := k.narrow(selection.Target(), scope, selection.Member().Text() == "&&")
+			k.value(call.Arguments()[0], right, boolean)
+			return boolean
+		case "==", "!=":
+			if operand, null := nullComparison(selection.Target(), call.Arguments()[0]); operand != nil {
+				return k.nullCheck(call, operand, null, scope)
+			}
+		case "+=", "-=", "*=", "/=", "%=":
+			result := k.compoundAssignment(call, selection, scope)
+			if result != nil {
+				return result
+			}
+		}
+	}
+	var callee types.TypeSymbol
+	selection, safe := call.Target().(ast.SafeSelection)
+	if safe {
+		callee = k.safeSelection(selection, scope)
+		k.context.Types[selection] = optionalOf(callee)
+	} else {
+		callee = k.value(call.Target(), scope, nil)
+	}
+	if types.IsError(callee) {
+		for _, argument := range call.Arguments() {
+			k.expression(argumentValue(argument), scope, nil)
+		}
+		return callee
+	}
+	if safe {
+		// The member is not called when the target is null
+		return optionalOf(k.arguments(call, callee, call.Arguments(), scope))
+	}
+	return k.arguments(call, callee, call.Arguments(), scope)
+}
+
+// isNull returns true if element is a null literal
+func isNull(element ast.Element) bool {
+	literal, ok := element.(ast.Literal)
+	return ok && literal.Value() == nil
+}
+
+// nullComparison returns the operand of a comparison with null and the null literal it is
+// compared with, if either operand is null
+func nullComparison(left, right ast.Element) (ast.Element, ast.Element) {
+	if isNull(right) {
+		return left, right
+	}
+	if isNull(left) {
+		return right, left
+	}
+	return nil, nil
+}
+
+// nullCheck checks a comparison of operand with null
+func (k *checker) nullCheck(call ast.Call, operand, null ast.Element, scope symbols.Scope) types.TypeSymbol {
+	result := k.value(operand, scope, nil)
+	if !types.IsError(result) && !types.IsOptional(result) {
+		k.context.Error(operand, "%s cannot be null", result)
+		result = types.NewErrorType()
+	}
+	k.value(null, scope, result)
+	return k.builtin(call, "Boolean")
+}
+
+// narrow returns the scope in which condition is known to have the value given. Comparing an
+// immutable local, field or parameter with null narrows its type in the scope where it is known
+// not to be null.
+func (k *checker) narrow(condition ast.Element, scope symbols.Scope, value bool) symbols.Scope {
+	call, ok := condition.(ast.Call)
+	if !ok || len(call.Arguments()) != 1 {
+		return scope
+	}
+	selection, ok := call.Target().(ast.Selection)
+	if !ok {
+		return scope
+	}
+	left, right := selection.Target(), call.Arguments()[0]
+	switch selection.Member().Text() {
+	case "&&":
+		if value {
+			return k.narrow(right, k.narrow(left, scope, true), true)
+		}
+	case "||":
+		if !value {
+			return k.narrow(right, k.narrow(left, scope, false), false)
+		}
+	case "==", "!=":
+		if operand, _ := nullComparison(left, right); operand != nil && (selection.Member().Text() == "!=") == value {
+			return k.nonNull(operand, scope)
+		}
+	}
+	return scope
+}
+
+// nonNull returns the scope in which element is known not to be null
+func (k *checker) nonNull(element ast.Element, scope symbols.Scope) symbols.Scope {
+	name, ok := element.(ast.Name)
+	if !ok {
+		return scope
+	}
+	typeSym, ok := k.context.Types[name]
+	if !ok || !types.IsOptional(typeSym) {
+		return scope
+	}
+	sym := k.context.References[name]
+	switch s := sym.(type) {
+	case types.Parameter:
+	case types.Field:
+		if s.Mutable() {
+			return scope
+		}
+	default:
+		return scope
+	}
+	builder := symbols.NewBuilder()
+	builder.Enter(&narrowed{symbol: sym, typ: typeSym.Type().Target()})
+	return symbols.Merge(builder, scope)
+}
+
+func argumentValue(argument ast.Element) ast.Element {
+	if named, ok := argument.(ast.NamedArgument); ok {
+		return named.Value()
+	}
+	return argument
+}
+
+// arguments checks the arguments of a call to callee and returns the result of the call
+func (k *checker) arguments(
+	call ast.Element,
+	callee types.TypeSymbol,
+	arguments []ast.Element,
+	scope symbols.Scope,
+) types.TypeSymbol {
+	signatures := callee.Type().Signatures()
+	if len(signatures) == 0 {
+		k.context.Error(call, "%s is not callable", callee)
+		return types.NewErrorType()
+	}
+	signature := signatures[0]
+	for _, candidate := range signatures {
+		if len(candidate.Parameters()) == len(arguments) {
+			signature = candidate
+			break
+		}
+	}
+	parameters := signature.Parameters()
+	generic := len(signature.TypeParameters()) > 0
+	var values []ast.Element
+	var valueParameters []types.Parameter
+	value := func(value ast.Element, parameter types.Parameter) {
+		if generic {
+			// The values are checked once the type arguments are inferred
+			values = append(values, value)
+			valueParameters = append(valueParameters, parameter)
+			return
+		}
+		k.value(value, scope, parameter.Type())
+	}
+	assigned := make([]bool, len(parameters))
+	next := 0
+	for _, argument := range arguments {
+		if named, ok := argument.(ast.NamedArgument); ok {
+			index := -1
+			for i, parameter := range parameters {
+				if parameter.Name() == named.Name().Text() {
+					index = i
+					break
+				}
+			}
+			if index < 0 {
+				k.context.Error(named.Name(), "Undefined parameter %s", named.Name().Text())
+				k.value(named.Value(), scope, nil)
+				continue
+			}
+			if assigned[index] {
+				k.context.Error(named.Name(), "Parameter %s already has a value", named.Name().Text())
+			}
+			assigned[index] = true
+			value(named.Value(), parameters[index])
+			continue
+		}
+		for next < len(parameters) && assigned[next] {
+			next++
+		}
+		if next >= len(parameters) {
+			k.context.Error(argument, "Too many arguments, expected %d", len(parameters))
+			k.value(argument, scope, nil)
+			continue
+		}
+		assigned[next] = true
+		value(argument, parameters[next])
+	}
+	for i, parameter := range parameters {
+		if !assigned[i] {
+			k.context.Error(call, "No value given for parameter %s", parameter.Name())
+		}
+	}
+	if generic {
+		return k.infer(call, signature, values, valueParameters, scope)
+	}
+	return signature.Result()
+}
+
+// instantiation checks an explicit instantiation of a generic lambda, such as f(<Int> 1), and
+// returns the type of the instance
+func (k *checker) instantiation(element ast.Instantiation, scope symbols.Scope) types.TypeSymbol {
+	target := k.value(element.Target(), scope, nil)
+	var arguments []types.TypeSymbol
+	for _, argument := range element.TypeArguments() {
+		arguments = append(arguments, k.context.findTypeIn(argument, scope))
+	}
+	if types.IsError(target) {
+		return target
+	}
+	signature := signatureOf(target)
+	if signature == nil || len(signature.TypeParameters()) == 0 {
+		k.context.Error(element, "%s is not generic", target)
+		return types.NewErrorType()
+	}
+	typeParameters := signature.TypeParameters()
+	if len(typeParameters) != len(arguments) {
+		k.context.Error(element, "Expected %d type arguments but found %d", len(typeParameters), len(arguments))
+		return types.NewErrorType()
+	}
+	substitution := make(map[types.TypeSymbol]types.TypeSymbol)
+	for i, typeParameter := range typeParameters {
+		substitution[typeParameter] = arguments[i]
+	}
+	for i, typeParameter := range typeParameters {
+		k.constraint(element.TypeArguments()[i], typeParameter, substitution)
+	}
+	return types.MakeFunction(types.SubstituteSignature(signature, substitution))
+}
+
+// infer infers the type arguments of a call to a generic lambda from the values given for its
+// parameters and returns the result of the call
+func (k *checker) infer(
+	call ast.Element,
+	signature types.Signature,
+	values []ast.Element,
+	parameters []types.Parameter,
+	scope symbols.Scope,
+) types.TypeSymbol {
+	substitution := make(map[types.TypeSymbol]types.TypeSymbol)
+	for _, typeParameter := range signature.TypeParameters() {
+		substitution[typeParameter] = nil
+	}
+	for i, value := range values {
+		parameter := parameters[i].Type()
+		expected := types.Substitute(parameter, substitution)
+		if unbound(expected, substitution) {
+			result := k.value(value, scope, nil)
+			k.unify(parameter, result, substitution)
+			if expected = types.Substitute(parameter, substitution); !types.Identical(result, expected) {
+				k.context.Error(value, "Expected a value of type %s but found %s", expected, result)
+			}
+			continue
+		}
+		k.value(value, scope, expected)
+	}
+	for _, typeParameter := range signature.TypeParameters() {
+		if substitution[typeParameter] == nil {
+			k.context.Error(call, "Cannot infer type parameter %s", typeParameter.Name())
+			substitution[typeParameter] = types.NewErrorType()
+		}
+	}
+	for _, typeParameter := range signature.TypeParameters() {
+		k.constraint(call, typeParameter, substitution)
+	}
+	return types.Substitute(signature.Result(), substitution)
+}
+
+// unify binds the unbound type parameters in parameter to the corresponding types in argument
+func (k *checker) unify(parameter, argument types.TypeSymbol, substitution map[types.TypeSymbol]types.TypeSymbol) {
+	if parameter == nil || argument == nil || types.IsError(argument) {
+		return
+	}
+	if bound, ok := substitution[parameter]; ok {
+		if bound == nil {
+			substitution[parameter] = argument
+		}
+		return
+	}
+	if generic, arguments := types.GenericOf(parameter); generic != nil {
+		other, otherArguments := types.GenericOf(argument)
+		if other == generic && len(otherArguments) == len(arguments) {
+			for i, typeArgument := range arguments {
+				k.unify(typeArgument, otherArguments[i], substitution)
+			}
+		}
+		return
+	}
+	pt, at := parameter.Type(), argument.Type()
+	if pt == nil || at == nil || pt.Kind() != at.Kind() {
+		return
+	}
+	switch pt.Kind() {
+	case types.Array:
+		k.unify(pt.Elements(), at.Elements(), substitution)
+	case types.Reference:
+		k.unify(pt.Referant(), at.Referant(), substitution)
+	case types.Optional:
+		k.unify(pt.Target(), at.Target(), substitution)
+	case types.Record:
+		signature, other := signatureOf(parameter), signatureOf(argument)
+		if signature == nil || other == nil || len(signature.Parameters()) != len(other.Parameters()) {
+			return
+		}
+		for i, p := range signature.Parameters() {
+			k.unify(p.Type(), other.Parameters()[i].Type(), substitution)
+		}
+		k.unify(signature.Result(), other.Result(), substitution)
+	}
+}
+
+// unbound returns true if typeSym refers to a type parameter substitution has not bound
+func unbound(typeSym types.TypeSymbol, substitution map[types.TypeSymbol]types.TypeSymbol) bool {
+	if typeSym == nil {
+		return false
+	}
+	if bound, ok := substitution[typeSym]; ok {
+		return bound == nil
+	}
+	if generic, arguments := types.GenericOf(typeSym); generic != nil {
+		for _, argument := range arguments {
+			if unbound(argument, substitution) {
+				return true
+			}
+		}
+		return false
+	}
+	t := typeSym.Type()
+	if t == nil {
+		return false
+	}
+	switch t.Kind() {
+	case types.Array:
+		return unbound(t.Elements(), substitution)
+	case types.Reference:
+		return unbound(t.Referant(), substitution)
+	case types.Optional:
+		return unbound(t.Target(), substitution)
+	case types.Record:
+		if t.Symbol().Name() != "" {
+			return false
+		}
+		for _, member := range t.Members() {
+			if unbound(member.Type(), substitution) {
+				return true
+			}
+		}
+		for _, signature := range t.Signatures() {
+			for _, parameter := range signature.Parameters() {
+				if unbound(parameter.Type(), substitution) {
+					return true
+				}
+			}
+			if unbound(signature.Result(), substitution) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// constraint checks the type argument substitution gives typeParameter satisfies its constraint
+func (k *checker) constraint(
+	element ast.Element,
+	typeParameter types.TypeSymbol,
+	substitution map[types.TypeSymbol]types.TypeSymbol,
+) {
+	constraint := types.Substitute(typeParameter.Type().Constraint(), substitution)
+	argument := substitution[typeParameter]
+	if !k.satisfies(element, argument, constraint) {
+		k.context.Error(element, "Type %s does not satisfy the constraint %s of type parameter %s", argument,
+			constraint, typeParameter.Name())
+	}
+}
+
+// satisfies returns true if typeSym satisfies constraint. A type satisfies a constraint if it is
+// the constraint or it has the members of the constraint with the same types.
+func (k *checker) satisfies(element ast.Element, typeSym, constraint types.TypeSymbol) bool {
+	if constraint == nil || types.Identical(typeSym, constraint) {
+		return true
+	}
+	t, c := typeSym.Type(), constraint.Type()
+	if t == nil || c == nil {
+		return false
+	}
+	if t.Kind() == types.Variable {
+		return t.Constraint() != nil && k.satisfies(element, t.Constraint(), constraint)
+	}
+	has := func(scope symbols.Scope, member types.Member) bool {
+		sym, ok := scope.Find(member.Name())
+		if !ok {
+			return false
+		}
+		other, ok := sym.(types.Member)
+		return ok && types.Identical(k.resolve(element, other.Type()), k.resolve(element, member.Type()))
+	}
+	for _, member := range c.Members() {
+		if !has(t.MemberScope(), member) {
+			return false
+		}
+	}
+	result := true
+	c.TypeScope().ForEach(func(sym symbols.Symbol) bool {
+		if member, ok := sym.(types.TypeMember); ok && !has(t.TypeScope(), member) {
+			result = false
+		}
+		return !result
+	})
+	return result
+}
+
+// assignment checks an assignment of a value to a mutable field or local
+func (k *checker) assignment(call ast.Call, selection ast.Selection, scope symbols.Scope) types.TypeSymbol {
+	target := k.assignable(selection.Target(), scope)
+	k.value(call.Arguments()[0], scope, target)
+	return target
+}
+
+// compoundAssignment checks an operator assignment, such as a += b, for types that only
+// declare the operator, as a = a + b
+func (k *checker) compoundAssignment(call ast.Call, selection ast.Selection, scope symbols.Scope) types.TypeSymbol {
+	target := k.expression(selection.Target(), scope, nil)
+	if target == nil || types.IsError(target) || k.typeExpressions[selection.Target()] {
+		return nil
+	}
+	name := selection.Member().Text()
+	if _, ok := target.Type().TypeScope().Find(name); ok {
+		return nil
+	}
+	operator, ok := target.Type().TypeScope().Find(name[:len(name)-1])
+	if !ok {
+		return nil
+	}
+	k.context.References[selection.Member()] = operator
+	target = k.assignable(selection.Target(), scope)
+	operatorType := k.symbolType(selection, operator)
+	if types.IsError(operatorType) {
+		return operatorType
+	}
+	result := k.arguments(call, operatorType, call.Arguments(), scope)
+	if result != nil && !types.Identical(result, target) {
+		k.context.Error(call, "Expected a value of type %s but found %s", target, result)
+	}
+	return target
+}
+
+// assignable checks that element refers to a mutable field or local and returns its type
+func (k *checker) assignable(element ast.Element, scope symbols.Scope) types.TypeSymbol {
+	result := k.value(element, scope, nil)
+	var name ast.Name
+	switch n := element.(type) {
+	case ast.Name:
+		name = n
+	case ast.Selection:
+		name = n.Member()
+	default:
+		k.context.Error(element, "Expression cannot be assigned")
+		return result
+	}
+	sym, ok := k.context.References[name]
+	if !ok {
+		return result
+	}
+	field, ok := sym.(types.Field)
+	if !ok || !field.Mutable() {
+		k.context.Error(element, "%s cannot be assigned", name.Text())
+	}
+	return result
+}
+
+// objectInitializer checks an object initializer. The type of the object is the type given
+// explicitly or the expected type. Otherwise, the type is a record with the members given.
+func (k *checker) objectInitializer(
+	element ast.ObjectInitializer,
+	scope symbols.Scope,
+	expected types.TypeSymbol,
+) types.TypeSymbol {
+	var typeSym types.TypeSymbol
+	expected = nonOptional(expected)
+	if element.Type() != nil {
+		typeSym = k.context.findTypeIn(element.Type(), scope)
+	} else if expected != nil && expected.Type() != nil && expected.Type().Kind() == types.Record &&
+		len(expected.Type().Signatures()) == 0 {
+		typeSym = expected
+	}
+	if typeSym == nil {
+		return k.recordInitializer(element, scope)
+	}
+	if types.IsError(typeSym) {
+		for _, member := range element.Members() {
+			k.expression(member, scope, nil)
+		}
+		return typeSym
+	}
+	t := typeSym.Type()
+	if t.Kind() != types.Record {
+		k.context.Error(element, "%s cannot be initialized with an object initializer", t.DisplayName())
+		return types.NewErrorType()
+	}
+	initialized := make(map[string]bool)
+	for _, member := range element.Members() {
+		switch m := member.(type) {
+		case ast.NamedMemberInitializer:
+			name := m.Name().Text()
+			if initialized[name] {
+				k.context.Error(m.Name(), "Member %s already initialized", name)
+			}
+			initialized[name] = true
+			sym, ok := t.MemberScope().Find(name)
+			field, isField := sym.(types.Field)
+			if !ok || !isField {
+				k.context.Error(m.Name(), "%s does not have a member %s", t.DisplayName(), name)
+				k.value(m.Value(), scope, nil)
+				continue
+			}
+			k.context.References[m.Name()] = field
+			k.value(m.Value(), scope, k.resolve(m, field.Type()))
+		case ast.Spread:
+			spread := k.value(m.Target(), scope, nil)
+			if types.IsError(spread) {
+				continue
+			}
+			for _, spreadMember := range spread.Type().Members() {
+				sym, ok := t.MemberScope().Find(spreadMember.Name())
+				if !ok {
+					continue
+				}
+				initialized[spreadMember.Name()] = true
+				field := sym.(types.Member)
+				if !types.Identical(spreadMember.Type(), field.Type()) {
+					k.context.Error(m, "Expected member %s to be of type %s but found %s",
+						field.Name(), field.Type(), spreadMember.Type())
+				}
+			}
+		}
+	}
+	for _, member := range t.Members() {
+		if initialized[member.Name()] {
+			continue
+		}
+		if storage, ok := k.context.Definitions[member].(ast.Storage); ok && storage.Value() != nil {
+			continue
+		}
+		k.context.Error(element, "No value given for member %s", member.Name())
+	}
+	return typeSym
+}
+
+// recordInitializer creates an anonymous record type for an untyped object initializer
+func (k *checker) recordInitializer(element ast.ObjectInitializer, scope symbols.Scope) types.TypeSymbol {
+	var members []types.Member
+	builder := symbols.NewBuilder()
+	enter := func(location ast.Element, name string, typ types.TypeSymbol) {
+		field := types.NewField(name, typ, element.Mutable())
+		_, ok := builder.Enter(field)
+		if !ok {
+			k.context.Error(location, "Member %s already initialized", name)
+			return
+		}
+		members = append(members, field)
+	}
+	for _, member := range element.Members() {
+		switch m := member.(type) {
+		case ast.NamedMemberInitializer:
+			enter(m.Name(), m.Name().Text(), k.value(m.Value(), scope, nil))
+		case ast.Spread:
+			spread := k.value(m.Target(), scope, nil)
+			if types.IsError(spread) {
+				continue
+			}
+			for _, spreadMember := range spread.Type().Members() {
+				enter(m, spreadMember.Name(), spreadMember.Type())
+			}
+		}
+	}
+	result := types.NewTypeSymbol("", nil)
+	types.NewType(result, types.Record, members, builder.Build(), nil, nil, nil)
+	return result
+}
+
+// arrayInitializer checks an array initializer. The element type is taken from the type given
+// explicitly, the expected type or the first element.
+func (k *checker) arrayInitializer(
+	element ast.ArrayInitializer,
+	scope symbols.Scope,
+	expected types.TypeSymbol,
+) types.TypeSymbol {
+	var typeSym types.TypeSymbol
+	expected = nonOptional(expected)
+	if element.Type() != nil {
+		typeSym = k.context.findTypeIn(element.Type(), scope)
+	} else if expected != nil && expected.Type() != nil && expected.Type().Kind() == types.Array {
+		typeSym = expected
+	}
+	var elements types.TypeSymbol
+	if typeSym != nil && !types.IsError(typeSym) {
+		if typeSym.Type().Kind() != types.Array {
+			k.context.Error(element.Type(), "Expected %s to be an array type", typeSym)
+			typeSym = types.NewErrorType()
+		} else {
+			elements = typeSym.Type().Elements()
+		}
+	}
+	for _, item := range element.Elements() {
+		result := k.value(item, scope, elements)
+		if elements == nil {
+			elements = result
+		}
+	}
+	if typeSym != nil {
+		return typeSym
+	}
+	if elements == nil {
+		k.context.Error(element, "The type of an empty array must be given")
+		return types.NewErrorType()
+	}
+	return types.MakeArray(elements)
+}
+
+// Check checks the expressions in the module, reporting type errors and inferring the
+// types of declarations that were not given an explicit type
+func (c *BindingContext) Check(moduleSymbol types.TypeSymbol, element ast.Element) {
+	module := moduleSymbol.Type()
+	assert.Assert(module != nil, "Module must be built before it is checked")
+	scope := symbols.Merge(module.MemberScope(), module.TypeScope(), c.Outer)
+	k := newChecker(c, scope)
+	var statements []ast.Element
+	forEachStatement(element, func(statement ast.Element) {
+		statements = append(statements, statement)
+	})
+	k.declare(module, scope, statements)
+	moduleScope := newLocalScope(scope)
+	for _, statement := range statements {
+		k.expression(statement, moduleScope, nil)
+	}
+	for _, i := range c.instantiations {
+		generic, arguments := types.GenericOf(i.instance)
+		if types.IsError(i.instance) {
+			continue
+		}
+		substitution := make(map[types.TypeSymbol]types.TypeSymbol)
+		for index, typeParameter := range generic.Type().TypeParameters() {
+			substitution[typeParameter] = arguments[index]
+		}
+		for index, typeParameter := range generic.Type().TypeParameters() {
+			k.constraint(i.element.TypeArguments()[index], typeParameter, substitution)
+		}
+	}
+}
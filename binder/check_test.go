@@ -0,0 +1,407 @@
+package binder_test
+
+import (
+	"dyego0/ast"
+	"dyego0/binder"
+	"dyego0/parser"
+	"dyego0/scanner"
+	"dyego0/symbols"
+	"dyego0/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Check", func() {
+	prelude := "...Dyego0\n" +
+		"let Boolean = < >\n" +
+		"let Int = <\n" +
+		"  let `+` = {! other: Int -> !}: Int\n" +
+		"  let `-` = {! other: Int -> !}: Int\n" +
+		"  let `<` = {! other: Int -> !}: Boolean\n" +
+		"  let toDouble = {! !}: Double\n" +
+		">\n" +
+		"let Double = <\n" +
+		"  let `*` = {! other: Double -> !}: Double\n" +
+		">\n"
+	machine := "let Byte = < >\n" +
+		"let valtype = <\n  let i32 = 0x7Fub\n  let f64 = 0x7Cub\n>\n" +
+		"let inst = <\n  let call = 0x10ub\n  let f64 = <\n    let add = 0xA0ub\n    let lt = 0x63ub\n  >\n>\n" +
+		"let I32 = <\n  let `@fmt` = valtype.i32\n>\n" +
+		"let F64 = <\n  let `@fmt` = valtype.f64\n>\n"
+	check := func(text string) (*binder.BindingContext, types.TypeSymbol) {
+		element := parseWith(prelude+text, parser.DefaultVocabularyScope())
+		context := binder.NewContext()
+		module := types.NewTypeSymbol("m", nil)
+		context.Enter(element)
+		context.Build(module, element)
+		Expect(context.Errors).To(BeNil())
+		context.Check(module, element)
+		return context, module
+	}
+	c := func(text string) types.TypeSymbol {
+		context, module := check(text)
+		for _, err := range context.Errors {
+			Expect(err.Error()).To(Equal(""))
+		}
+		return module
+	}
+	e := func(text string, messages ...string) {
+		context, _ := check(text)
+		var actual []string
+		for _, err := range context.Errors {
+			actual = append(actual, err.Error())
+		}
+		Expect(actual).To(Equal(messages))
+	}
+	buildErrors := func(text string) []string {
+		element := parseWith(prelude+text, parser.DefaultVocabularyScope())
+		context := binder.NewContext()
+		context.Enter(element)
+		context.Build(types.NewTypeSymbol("m", nil), element)
+		var messages []string
+		for _, err := range context.Errors {
+			messages = append(messages, err.Error())
+		}
+		return messages
+	}
+	typeOf := func(module types.TypeSymbol, name string) string {
+		sym, ok := symbols.Merge(module.Type().MemberScope(), module.Type().TypeScope()).Find(name)
+		Expect(ok).To(BeTrue())
+		return sym.(types.Member).Type().String()
+	}
+	It("can infer the type of a literal", func() {
+		m := c("val a = 1\nval b = true")
+		Expect(typeOf(m, "a")).To(Equal("m.Int"))
+		Expect(typeOf(m, "b")).To(Equal("m.Boolean"))
+	})
+	It("can infer the type of an operator", func() {
+		m := c("val a = 1 + 2\nval b = 1 < 2")
+		Expect(typeOf(m, "a")).To(Equal("m.Int"))
+		Expect(typeOf(m, "b")).To(Equal("m.Boolean"))
+	})
+	It("can infer the type of a lambda", func() {
+		m := c("let f = { a: Int -> a + 1 }\nval b = f(1)")
+		Expect(typeOf(m, "f")).To(Equal("{a: m.Int -> m.Int}"))
+		Expect(typeOf(m, "b")).To(Equal("m.Int"))
+	})
+	It("can infer the type of a declaration used before it is declared", func() {
+		m := c("val b = f(1)\nlet f = { a: Int -> a }")
+		Expect(typeOf(m, "b")).To(Equal("m.Int"))
+	})
+	It("can call a lambda recursively with an explicit result", func() {
+		c("let f = { a: Int -> f(a - 1) }: Int")
+	})
+	It("reports an untyped recursive lambda", func() {
+		e("let f = { a: Int -> f(a - 1) }", "f is used recursively and requires an explicit type")
+	})
+	It("can call with named arguments", func() {
+		c("let f = { a: Int, b: Double -> b }: Double\nval r = f(b: 1.0, a: 2)")
+	})
+	It("reports argument errors", func() {
+		e("let f = { a: Int -> a }: Int\nval r = f(true)", "Expected a value of type m.Int but found m.Boolean")
+		e("let f = { a: Int -> a }: Int\nval r = f(1, 2)", "Too many arguments, expected 1")
+		e("let f = { a: Int -> a }: Int\nval r = f()", "No value given for parameter a")
+		e("let f = { a: Int -> a }: Int\nval r = f(b: 1)", "Undefined parameter b", "No value given for parameter a")
+	})
+	It("reports calling a value that is not callable", func() {
+		e("val a = 1\nval b = a(1)", "m.Int is not callable")
+	})
+	It("reports an undefined member", func() {
+		e("val a = 1\nval b = a.missing", "m.Int does not have a member missing")
+		e("val a = 1.0 + 2.0", "m.Double does not have a member +")
+	})
+	It("reports an undefined symbol", func() {
+		e("val a = b", "Undefined symbol b")
+	})
+	It("reports a mismatched result", func() {
+		e("let f = { a: Int -> a }: Double", "Expected a value of type m.Double but found m.Int")
+		e("let f = { a: Int -> return true }: Int", "Expected a value of type m.Int but found m.Boolean")
+	})
+	It("can use members of the type in type members", func() {
+		m := c("let V = < x: Int, let inc = { x + 1 }, let self = { this } >\nval v = [<V> x: 1].inc()")
+		Expect(typeOf(m, "v")).To(Equal("m.Int"))
+	})
+	It("can check object initializers", func() {
+		c("let V = < x: Int, y: Int >\nval v: V = [x: 1, y: 2]")
+		e("let V = < x: Int, y: Int >\nval v: V = [x: 1]", "No value given for member y")
+		e("let V = < x: Int >\nval v: V = [x: 1, z: 2]", "m.V does not have a member z")
+		e("let V = < x: Int >\nval v: V = [x: true]", "Expected a value of type m.Int but found m.Boolean")
+	})
+	It("can infer the type of a record", func() {
+		m := c("val v = [x: 1, y: true]")
+		Expect(typeOf(m, "v")).To(Equal("<x: m.Int, y: m.Boolean>"))
+	})
+	It("can check array initializers", func() {
+		m := c("val a = [1, 2, 3]\nval b = a[0]")
+		Expect(typeOf(m, "a")).To(Equal("m.Int[]"))
+		Expect(typeOf(m, "b")).To(Equal("m.Int"))
+		e("val a = [1, true]", "Expected a value of type m.Int but found m.Boolean")
+	})
+	It("can check locals", func() {
+		c("let f = { a: Int ->\n  var b = a\n  b = b + 1\n  b\n}: Int")
+	})
+	It("reports assignment to a value", func() {
+		e("let f = { a: Int ->\n  val b = a\n  b = 1\n}", "b cannot be assigned")
+		e("val a = 1\nlet f = { a = 2 }", "a cannot be assigned")
+	})
+	It("reports a duplicate local", func() {
+		e("let f = { a: Int ->\n  val b = a\n  val b = a\n}", "Duplicate symbol")
+	})
+	It("can check a while loop", func() {
+		c("var i = 0\nwhile (i < 10) {\n  i = i + 1\n}")
+		e("var i = 0\nwhile (i) { i = i + 1 }", "Expected a value of type m.Boolean but found m.Int")
+	})
+	It("can check break and continue", func() {
+		e("let f = { break }", "break must be in a loop")
+		e("loop { continue }")
+		e("loop a { loop { break a } }")
+		e("loop { break b }", "Undefined label b")
+	})
+	It("can infer the type of a when", func() {
+		m := c("let f = { a: Int -> when {\n  a < 0 -> { 0 }\n  else -> { a }\n} }\nval r = f(1)")
+		Expect(typeOf(m, "r")).To(Equal("m.Int"))
+	})
+	It("reports a when without a value", func() {
+		e("let f = { a: Int -> if (a < 0) { 0 } }\nval r = f(1)", "Expression does not produce a value")
+	})
+	It("can use logical operators", func() {
+		c("val a = 1 < 2 && 2 < 3 || true")
+		e("val a = 1 && true", "Expected a value of type m.Boolean but found m.Int")
+	})
+	It("can use compound assignment", func() {
+		c("var a = 1\nlet f = { a += 2 }")
+	})
+	It("can check intrinsic lambdas", func() {
+		context, _ := check(machine + "let R = <\n  let `@fmt` = valtype.f64\n" +
+			"  let `+` = {! other: R -> inst.f64.add !}: R\n" +
+			"  let lt = {! other: R -> inst.f64.lt !}: I32\n>")
+		Expect(context.Errors).To(BeNil())
+		var operations []string
+		for _, intrinsic := range context.Intrinsics {
+			for _, instruction := range intrinsic.Instructions {
+				operations = append(operations, instruction.Operation.Name)
+			}
+		}
+		Expect(operations).To(ConsistOf("f64.add", "f64.lt"))
+	})
+	It("reports invalid intrinsic lambdas", func() {
+		r := "let R = <\n  let `@fmt` = valtype.f64\n  let f = "
+		e(machine+r+"{! other: I32 -> inst.f64.add !}: R\n>",
+			"Expected an operand of type f64 for f64.add but found i32")
+		e(machine+r+"{! other: R -> inst.f64.add !}: I32\n>",
+			"Expected the intrinsic to produce i32 but it produces f64")
+		e(machine+r+"{! other: R -> other !}: R\n>", "Expected an instruction")
+		e(machine+r+"{! inst.call, 0x00ub !}\n>", "call cannot be used in an intrinsic")
+	})
+	It("can infer the type arguments of a generic lambda", func() {
+		m := c("let id = { X | x: X -> x }\nval a = id(1)\nval b = id(true)")
+		Expect(typeOf(m, "id")).To(Equal("{X | x: X -> X}"))
+		Expect(typeOf(m, "a")).To(Equal("m.Int"))
+		Expect(typeOf(m, "b")).To(Equal("m.Boolean"))
+		e("let f = { X | a: X, b: X -> a }\nval a = f(1, true)", "Expected a value of type m.Int but found m.Boolean")
+		e("let f = { X | 1 }\nval a = f()", "Cannot infer type parameter X")
+	})
+	It("can instantiate a generic lambda explicitly", func() {
+		m := c("let id = { X | x: X -> x }: X\nval a = id(<Int> 1)")
+		Expect(typeOf(m, "a")).To(Equal("m.Int"))
+		e("let id = { X | x: X -> x }: X\nval a = id(<Int> true)", "Expected a value of type m.Int but found m.Boolean")
+		e("let id = { X | x: X -> x }: X\nval a = id(<Int, Int> 1)", "Expected 1 type arguments but found 2")
+		e("let f = { x: Int -> x }\nval a = f(<Int> 1)", "{x: m.Int -> m.Int} is not generic")
+	})
+	It("can use the members of the constraint of a type parameter", func() {
+		source := "let HasX = < x: Int >\nlet P = < x: Int, y: Int >\nlet getX = { T: HasX | t: T -> t.x }\n"
+		m := c(source + "val p: P = [x: 1, y: 2]\nval a = getX(p)")
+		Expect(typeOf(m, "a")).To(Equal("m.Int"))
+		e(source+"val a = getX(1)", "Type m.Int does not satisfy the constraint m.HasX of type parameter T")
+	})
+	It("can instantiate a generic type", func() {
+		m := c("let Box = < X -> value: X, let get = { value } >\nval b: Box<Int> = [value: 1]\nval c: Box<Int> = b\n" +
+			"val v = b.value\nval w = c.get()")
+		Expect(typeOf(m, "b")).To(Equal("m.Box<m.Int>"))
+		Expect(typeOf(m, "v")).To(Equal("m.Int"))
+		e("let Box = < X -> value: X >\nval b: Box<Int> = [value: true]",
+			"Expected a value of type m.Int but found m.Boolean")
+	})
+	It("can instantiate a generic type before it is declared", func() {
+		m := c("var b: Box<Int>\nval v = b.value\nlet Box = < X -> value: X >")
+		Expect(typeOf(m, "v")).To(Equal("m.Int"))
+	})
+	It("can use optional instances of generic types", func() {
+		m := c("let List = < X -> head: X, tail: List<X>? >\nval l: List<Int> = [head: 1, tail: null]\n" +
+			"var b: List<Int>?\nval t = l.tail")
+		Expect(typeOf(m, "t")).To(Equal("m.List<m.Int>?"))
+	})
+	It("reports generic types used without type arguments", func() {
+		Expect(buildErrors("let Box = < X -> value: X >\nvar b: Box\nvar c: Box[]")).
+			To(Equal([]string{"m.Box requires 1 type arguments", "m.Box requires 1 type arguments"}))
+		Expect(buildErrors("var b: Box?\nlet Box = < X -> value: X >")).
+			To(Equal([]string{"m.Box requires 1 type arguments"}))
+		context, _ := check("let Box = < X -> value: X >\nlet f = { b: Box -> b.value }\nval v = 1")
+		var messages []string
+		for _, err := range context.Errors {
+			messages = append(messages, err.Error())
+		}
+		Expect(messages).To(Equal([]string{"m.Box requires 1 type arguments"}))
+	})
+	It("reports a type argument that does not satisfy its constraint", func() {
+		e("let HasX = < x: Int >\nlet Box = < X: HasX -> value: X >\nvar b: Box<Int>",
+			"Type m.Int does not satisfy the constraint m.HasX of type parameter X")
+	})
+	It("reports the wrong number of type arguments", func() {
+		Expect(buildErrors("let Box = < X -> value: X >\nvar b: Box<Int, Int>\nvar c: Int<Int>")).
+			To(Equal([]string{"Expected 1 type arguments but found 2", "m.Int is not generic"}))
+	})
+	It("binds this in the type of lambdas declared by a type", func() {
+		m := c("let Vector = <\n  x: Double\n  let `*` = { s: Double -> [x: x * s] }: Vector\n>\n" +
+			"val v: Vector = [x: 1.0]\nval w = v * 2.0\nlet f = { a: Int -> a }")
+		vector, _ := m.Type().TypeScope().Find("Vector")
+		scale, _ := vector.(types.TypeSymbol).Type().TypeScope().Find("*")
+		Expect(scale.(types.Member).Type().String()).To(Equal("m.Vector.{s: m.Double -> m.Vector}"))
+		Expect(typeOf(m, "w")).To(Equal("m.Vector"))
+		Expect(typeOf(m, "f")).To(Equal("{a: m.Int -> m.Int}"))
+	})
+	It("can call values of callable types", func() {
+		m := c("let F = < { x: Int -> Int } >\nlet apply = { f: F, x: Int -> f(x) }\n" +
+			"val a = apply({ x -> x + 1 }, 1)\nval f: F = { x -> x }")
+		Expect(typeOf(m, "a")).To(Equal("m.Int"))
+		e("let F = < { x: Int -> Int } >\nval f: F = { x: Int -> x < 1 }",
+			"Expected a value of type m.Int but found m.Boolean")
+	})
+	It("selects the signature of a callable type by the number of arguments", func() {
+		m := c("let F = < { x: Int -> Int }, { x: Int, y: Int -> Boolean } >\nvar f: F\nval a = f(1)\nval b = f(1, 2)")
+		Expect(typeOf(m, "a")).To(Equal("m.Int"))
+		Expect(typeOf(m, "b")).To(Equal("m.Boolean"))
+	})
+	It("can use a type literal as a type reference", func() {
+		m := c("let apply = { f: < { x: Int -> Int } >, x: Int -> f(x) }\nval a = apply({ x -> x }, 1)")
+		Expect(typeOf(m, "apply")).To(Equal("{f: <{x: m.Int -> m.Int}>, x: m.Int -> m.Int}"))
+		Expect(typeOf(m, "a")).To(Equal("m.Int"))
+	})
+	It("reports invalid parameters of callable type members", func() {
+		Expect(buildErrors("let F = < { x -> Int } >\nlet G = < { x: Int, x: Int -> Int } >")).
+			To(Equal([]string{"Parameter x requires a type", "Duplicate parameter x"}))
+	})
+	It("reports conflicting spread members", func() {
+		Expect(buildErrors("let A = < x: Int >\nlet B = < x: Int, ...A >\nlet C = < x: Boolean, ...A >")).
+			To(Equal([]string{"Member x spread from m.A conflicts with another member"}))
+		Expect(buildErrors("let A = < let T = < > >\nlet B = < let T = < >, ...A >")).
+			To(Equal([]string{"Type member T spread from m.A conflicts with another type member"}))
+	})
+	It("reports invalid spreads", func() {
+		Expect(buildErrors("let A = < ...B >\nlet B = < ...A >")).
+			To(Equal([]string{"Type B is spread recursively"}))
+		Expect(buildErrors("let A = < ...Int? >")).
+			To(Equal([]string{"Only record types can be spread but found m.Int?"}))
+		Expect(buildErrors("let A = < ...Box<Int, Int> >\nlet Box = < X -> value: X >")).
+			To(Equal([]string{"Expected 1 type arguments but found 2"}))
+	})
+	It("can initialize and select the members of spread and composed types", func() {
+		m := c("let A = < a: Int >\nlet B = < ...A, b: Int >\nval b: B = [a: 1, b: 2]\nval x = b.a + b.b\n" +
+			"let C = < c: Boolean >\nval ac: A & C = [a: 1, c: true]\nval y = ac.c")
+		Expect(typeOf(m, "x")).To(Equal("m.Int"))
+		Expect(typeOf(m, "ac")).To(Equal("<a: m.Int, c: m.Boolean>"))
+		Expect(typeOf(m, "y")).To(Equal("m.Boolean"))
+		e("let A = < a: Int >\nlet C = < c: Boolean >\nval ac: A & C = [a: 1]", "No value given for member c")
+	})
+	It("can assign composed records to the records they are composed from", func() {
+		m := c("let A = < a: Int >\nlet B = < b: Boolean >\nlet C = < ...B, ...A >\n" +
+			"val ab: A & B = [a: 1, b: true]\nval a: A = ab\nval b: B = ab\nval c: C = ab\nval d: A & B = c\n" +
+			"val f = { x: A -> x.a }\nval y = f(c)")
+		Expect(typeOf(m, "d")).To(Equal("<a: m.Int, b: m.Boolean>"))
+		Expect(typeOf(m, "y")).To(Equal("m.Int"))
+		e("let A = < a: Int >\nlet B = < b: Boolean >\nval a: A = [a: 1]\nval ab: A & B = a",
+			"Expected a value of type <a: m.Int, b: m.Boolean> but found m.A")
+		e("let A = < a: Int >\nlet B = < a: Int >\nval ab: A & < c: Int > = [a: 1, c: 2]\nval b: B = ab",
+			"Expected a value of type m.B but found <a: m.Int, c: m.Int>")
+	})
+	It("can assign anonymous records to record types with the same fields", func() {
+		m := c("let Vector = < x: Double, y: Double >\nval a = [y: 2.0, x: 1.0]\nval v: Vector = a\n" +
+			"val o: Vector? = a\nval w = v.x")
+		Expect(typeOf(m, "a")).To(Equal("<y: m.Double, x: m.Double>"))
+		Expect(typeOf(m, "w")).To(Equal("m.Double"))
+		e("let Vector = < x: Double, y: Double >\nval a = [x: 1.0]\nval v: Vector = a",
+			"Expected a value of type m.Vector but found <x: m.Double>")
+		e("let A = < x: Double >\nlet B = < x: Double >\nval a: A = [x: 1.0]\nval b: B = a",
+			"Expected a value of type m.B but found m.A")
+	})
+	It("reports declarations without a type or a value", func() {
+		e("var x", "x requires a type or a value")
+		e("val x\nval y = x", "x requires a type or a value")
+	})
+	It("reports locals declared outside of lambdas and blocks", func() {
+		e("val a = (let b = 1)", "Locals can only be declared in lambdas and blocks",
+			"Expression does not produce a value")
+		e("val a = [ let b = 1 ]", "Locals can only be declared in lambdas and blocks",
+			"Expression does not produce a value")
+	})
+	It("can check optional values", func() {
+		m := c("val a: Int? = null\nval b: Int? = 1\nvar c: Int?\nlet P = < x: Int >\nval p: P? = [x: 1]")
+		Expect(typeOf(m, "a")).To(Equal("m.Int?"))
+		Expect(typeOf(m, "p")).To(Equal("m.P?"))
+		e("val a = null", "The type of null must be given")
+		e("val a: Int = null", "m.Int cannot be null")
+		e("val a: Int? = 1\nval b: Int = a", "Expected a value of type m.Int but found m.Int?")
+		e("val a = 1\nval b = a != null", "m.Int cannot be null")
+	})
+	It("can return null from a lambda with an optional result", func() {
+		c("let f = { a: Int -> if (a < 0) { return null }\n  a }: Int?")
+	})
+	It("reports selecting a member of a value that can be null", func() {
+		e("val a: Int? = 1\nval b = a + 1", "m.Int? can be null and must be checked for null before selecting +")
+		e("let P = < x: Int >\nval p: P? = null\nval x = p.x",
+			"m.P? can be null and must be checked for null before selecting x")
+	})
+	It("can select a member of a value that can be null with ?.", func() {
+		m := c("let P = < x: Int, q: P? >\nval p: P? = null\nval x = p?.x\nval q = p?.q\nval r = p?.q?.x\n" +
+			"val a: Int? = 1\nval b = a?.`+`(1)")
+		Expect(typeOf(m, "x")).To(Equal("m.Int?"))
+		Expect(typeOf(m, "q")).To(Equal("m.P?"))
+		Expect(typeOf(m, "r")).To(Equal("m.Int?"))
+		Expect(typeOf(m, "b")).To(Equal("m.Int?"))
+		e("let P = < x: Int >\nval p: P = [x: 1]\nval x = p?.x", "m.P cannot be null and must be selected with .")
+		e("let P = < x: Int >\nval p: P? = null\nval y = p?.y", "m.P does not have a member y")
+		e("val a: Int? = 1\nval b = a?.`+`(true)", "Expected a value of type m.Int but found m.Boolean")
+	})
+	It("narrows the type of a value checked for null", func() {
+		c("let f = { a: Int? -> if (a != null) { a + 1 } else { 0 } }: Int")
+		c("let f = { a: Int? -> if (a == null) { 0 } else { a + 1 } }: Int")
+		c("let f = { a: Int?, b: Int? -> if (null != a && b != null) { a + b } else { 0 } }: Int")
+		c("let f = { a: Int? -> a != null && a < 1 }: Boolean")
+		c("let f = { a: Int? -> a == null || a < 1 }: Boolean")
+		c("let f = { a: Int? -> when (a) { null -> { 0 }\n else -> { a + 1 } } }: Int")
+		m := c("let P = < x: Int >\nval p: P? = [x: 1]\nval x = if (p != null) { p.x } else { 0 }")
+		Expect(typeOf(m, "x")).To(Equal("m.Int"))
+	})
+	It("does not narrow the type of mutable variables", func() {
+		e("var a: Int? = 1\nval b = if (a != null) { a + 1 } else { 0 }",
+			"m.Int? can be null and must be checked for null before selecting +")
+		e("let f = { a: Int? -> if (a != null) { 0 } else { a + 1 } }: Int",
+			"m.Int? can be null and must be checked for null before selecting +")
+	})
+	It("records the types and references of expressions", func() {
+		context, module := check("val a = 1\nval b = a")
+		var reference ast.Element
+		for element, sym := range context.References {
+			if n, ok := element.(ast.Name); ok && n.Text() == "a" {
+				reference = element
+				Expect(sym.Name()).To(Equal("a"))
+			}
+		}
+		Expect(reference).To(Not(BeNil()))
+		Expect(context.Types[reference].String()).To(Equal("m.Int"))
+		Expect(typeOf(module, "b")).To(Equal("m.Int"))
+	})
+	It("does not report errors for elements the parser could not parse", func() {
+		p := parser.NewParser(scanner.NewScanner([]byte(prelude+"val a = 1 + )\nval b = a + 1\x00"), 0, nil),
+			parser.DefaultVocabularyScope())
+		element := p.Parse()
+		Expect(p.Errors()).To(HaveLen(1))
+		context := binder.NewContext()
+		module := types.NewTypeSymbol("m", nil)
+		context.Enter(element)
+		context.Build(module, element)
+		context.Check(module, element)
+		Expect(context.Errors).To(BeEmpty())
+	})
+})
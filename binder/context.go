@@ -1,9 +1,12 @@
 package binder
 
 import (
+	"dyego0/ast"
 	"dyego0/errors"
+	"dyego0/intrinsics"
 	"dyego0/location"
 	"dyego0/symbols"
+	"dyego0/types"
 )
 
 // BindingContext is a context for binding symbols
@@ -11,18 +14,86 @@ type BindingContext struct {
 	// Scope is the root scope of the context
 	Scope symbols.ScopeBuilder
 
+	// Outer is the scope used to find symbols not declared in the module
+	Outer symbols.Scope
+
 	// Builders is a map of scope symbols to their builders
 	Builders map[symbols.Symbol]symbols.ScopeBuilder
 
+	// Definitions is a map of the symbols declared to the element that declared them
+	Definitions map[symbols.Symbol]ast.Element
+
+	// References is a map of the names to the symbols they refer to
+	References map[ast.Element]symbols.Symbol
+
+	// Types is a map of expressions to the type of the expression
+	Types map[ast.Element]types.TypeSymbol
+
+	// Conversions is a map of the expressions whose record value is used as a value of another
+	// record type, such as a value of A & B used as an A, to that type
+	Conversions map[ast.Element]types.TypeSymbol
+
+	// Registry is the registry of the operations intrinsic lambdas can perform
+	Registry *intrinsics.Registry
+
+	// Intrinsics is a map of intrinsic lambdas to the instructions they encode
+	Intrinsics map[ast.Element]*intrinsics.Intrinsic
+
+	// Imports are the contexts of the modules that declare the symbols found in Outer. They
+	// are used to evaluate the constants, such as opcodes, referred to by intrinsic lambdas.
+	Imports []*BindingContext
+
 	// Errors is the errors reported during binding
 	Errors []errors.Error
+
+	// instantiations are the instantiations of generic types found in type references. The
+	// constraints of their type parameters are checked by Check.
+	instantiations []instantiation
+
+	// references are the type references to types that were not built when they were found.
+	// Build reports those that refer to generic types.
+	references []typeReference
+
+	// pending are the record types waiting for the types they spread to be built
+	pending []pendingType
+}
+
+// typeReference is a reference to a type that was not built yet
+type typeReference struct {
+	element ast.Element
+	typeSym types.TypeSymbol
+}
+
+// pendingType is a record type that is built once the types it spreads are built
+type pendingType struct {
+	visitor   *buildVisitor
+	typeSym   types.TypeSymbol
+	container types.TypeSymbol
+}
+
+// instantiation is an instance of a generic type and the type reference that instantiated it
+type instantiation struct {
+	element  ast.Instantiation
+	instance types.TypeSymbol
 }
 
 // NewContext creates a new binding context
 func NewContext() *BindingContext {
+	return NewContextIn(symbols.EmptyScope())
+}
+
+// NewContextIn creates a new binding context for a module that can refer to the symbols in outer
+func NewContextIn(outer symbols.Scope) *BindingContext {
 	return &BindingContext{
-		Scope:    symbols.NewBuilder(),
-		Builders: make(map[symbols.Symbol]symbols.ScopeBuilder),
+		Scope:       symbols.NewBuilder(),
+		Outer:       outer,
+		Builders:    make(map[symbols.Symbol]symbols.ScopeBuilder),
+		Definitions: make(map[symbols.Symbol]ast.Element),
+		References:  make(map[ast.Element]symbols.Symbol),
+		Types:       make(map[ast.Element]types.TypeSymbol),
+		Conversions: make(map[ast.Element]types.TypeSymbol),
+		Registry:    intrinsics.Standard(),
+		Intrinsics:  make(map[ast.Element]*intrinsics.Intrinsic),
 	}
 }
 
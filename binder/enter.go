@@ -8,20 +8,27 @@ import (
 )
 
 type enterVisitor struct {
-	scope    symbols.ScopeBuilder
-	builders map[symbols.Symbol]symbols.ScopeBuilder
-	errors   []errors.Error
+	scope       symbols.ScopeBuilder
+	builders    map[symbols.Symbol]symbols.ScopeBuilder
+	definitions map[symbols.Symbol]ast.Element
+	errors      []errors.Error
 }
 
-func newEnterVisitor(scope symbols.ScopeBuilder, builders map[symbols.Symbol]symbols.ScopeBuilder) *enterVisitor {
-	return &enterVisitor{scope: scope, builders: builders}
+func newEnterVisitor(
+	scope symbols.ScopeBuilder,
+	builders map[symbols.Symbol]symbols.ScopeBuilder,
+	definitions map[symbols.Symbol]ast.Element,
+) *enterVisitor {
+	return &enterVisitor{scope: scope, builders: builders, definitions: definitions}
 }
 
 func (v *enterVisitor) enterSymbol(symbol symbols.Symbol, node ast.Element) {
 	_, ok := v.scope.Enter(symbol)
 	if !ok {
 		v.errors = append(v.errors, errors.New(node, "Duplicate symbol"))
+		return
 	}
+	v.definitions[symbol] = node
 }
 
 func (v *enterVisitor) Visit(element ast.Element) bool {
@@ -40,7 +47,7 @@ func (v *enterVisitor) Visit(element ast.Element) bool {
 					v.enterSymbol(typSym, n)
 					typeScope := symbols.NewBuilder()
 					v.builders[typSym] = typeScope
-					nestedEnter := newEnterVisitor(typeScope, v.builders)
+					nestedEnter := newEnterVisitor(typeScope, v.builders, v.definitions)
 					for _, member := range typ.Members() {
 						nestedEnter.Visit(member)
 					}
@@ -59,7 +66,7 @@ func isTypeDeclaration(declaration ast.Definition) bool {
 
 // Enter enters the types declared a the root of emement into the scope
 func (c *BindingContext) Enter(element ast.Element) {
-	v := newEnterVisitor(c.Scope, c.Builders)
+	v := newEnterVisitor(c.Scope, c.Builders, c.Definitions)
 	v.Visit(element)
 	c.Errors = append(c.Errors, v.errors...)
 }
@@ -78,9 +78,13 @@ func scan(text string, fb tokens.FileBuilder) *scanner.Scanner {
 }
 
 func parseNamed(text, filename string) ast.Element {
+	return parseNamedWith(text, filename, nil)
+}
+
+func parseNamedWith(text, filename string, scope parser.VocabularyScope) ast.Element {
 	fs := tokens.NewFileSet()
 	fb := fs.BuildFile(filename, len(text))
-	p := parser.NewParser(scan(text, fb), nil)
+	p := parser.NewParser(scan(text, fb), scope)
 	r := p.Parse()
 	recordLines(fb, text)
 	fb.Build()
@@ -96,3 +100,7 @@ func parseNamed(text, filename string) ast.Element {
 func parse(text string) ast.Element {
 	return parseNamed(text, "test")
 }
+
+func parseWith(text string, scope parser.VocabularyScope) ast.Element {
+	return parseNamedWith(text, "test", scope)
+}
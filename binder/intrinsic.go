@@ -0,0 +1,63 @@
+package binder
+
+import (
+	"dyego0/ast"
+	"dyego0/intrinsics"
+	"dyego0/symbols"
+	"dyego0/types"
+	"dyego0/wasm"
+)
+
+// intrinsic resolves the instructions encoded by an intrinsic lambda and checks them against
+// the parameters and result of the lambda. The receiver of a lambda declared by a type is the
+// first operand. The check is skipped if a type involved does not declare its format with
+// `@fmt`.
+func (k *checker) intrinsic(
+	lambda ast.IntrinsicLambda,
+	params []types.Parameter,
+	result types.TypeSymbol,
+	scope symbols.Scope,
+) {
+	context := k.context
+	evaluator := &intrinsics.Evaluator{
+		Definitions: []map[symbols.Symbol]ast.Element{context.Definitions},
+		Scope:       scope,
+	}
+	for _, imported := range context.Imports {
+		evaluator.Definitions = append(evaluator.Definitions, imported.Definitions)
+	}
+	intrinsic, err := context.Registry.Resolve(evaluator, lambda)
+	if err != nil {
+		context.Errors = append(context.Errors, err)
+		return
+	}
+	context.Intrinsics[lambda] = intrinsic
+
+	var operands []types.TypeSymbol
+	if this, ok := scope.Find("this"); ok {
+		if parameter, ok := this.(types.Parameter); ok {
+			operands = append(operands, parameter.Type())
+		}
+	}
+	for _, param := range params {
+		operands = append(operands, param.Type())
+	}
+	var paramFormats, resultFormats []wasm.ValueType
+	for _, operand := range operands {
+		format, ok := evaluator.Format(operand)
+		if !ok {
+			return
+		}
+		paramFormats = append(paramFormats, format)
+	}
+	if result != nil {
+		format, ok := evaluator.Format(result)
+		if !ok {
+			return
+		}
+		resultFormats = append(resultFormats, format)
+	}
+	if err := intrinsic.Check(paramFormats, resultFormats); err != nil {
+		context.Error(lambda, "%s", err)
+	}
+}
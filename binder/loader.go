@@ -0,0 +1,579 @@
+package binder
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"dyego0/ast"
+	"dyego0/errors"
+	"dyego0/parser"
+	"dyego0/scanner"
+	"dyego0/symbols"
+	"dyego0/tokens"
+	"dyego0/types"
+)
+
+// Module is a module loaded by a Loader
+type Module struct {
+	// Name is the qualified name of the module, such as a.b.C
+	Name string
+
+	// Source is the source the module was loaded from
+	Source ModuleSource
+
+	// Text is the text of the source
+	Text string
+
+	// File is the file the text is declared as in the loader's file set
+	File tokens.File
+
+	// Element is the parsed module
+	Element ast.Element
+
+	// Context is the context the module was bound and checked in
+	Context *BindingContext
+
+	// Symbol is the type symbol of the module
+	Symbol types.TypeSymbol
+
+	// Imports are the modules referred to by the module in the order they are first referred to
+	Imports []*Module
+
+	// Vocabularies are the vocabularies declared by the module, such as Operators declared by
+	// let Operators = <| ... |>. A module refers to the vocabularies of module a.b.C by
+	// embedding them with a spread such as ...a::b::C::Operators.
+	Vocabularies map[string]parser.Vocabulary
+
+	loading bool
+}
+
+// Loader loads modules, and the modules they refer to, from a module source scope. A module
+// refers to another module by its qualified name, such as a.b.C, where a and b are the
+// scopes that contain C. Each module is parsed, bound and checked once, after the modules it
+// refers to. The names declared by a module hide the scopes and modules of the root scope.
+// In vocabulary references, the vocabularies declared by a module and those of the loader's
+// vocabulary scope hide the scopes and modules of the root scope.
+type Loader struct {
+	root       ModuleSourceScope
+	fileSet    tokens.FileSet
+	vocabulary parser.VocabularyScope
+	outer      symbols.Scope
+	modules    map[string]*Module
+	order      []*Module
+	loading    []*Module
+
+	// Imports are the contexts of the modules that declare the symbols of outer, such as the
+	// builtins, which every module imports
+	Imports []*BindingContext
+
+	// BindOnly binds the modules without checking their expressions
+	BindOnly bool
+
+	// Errors are the errors reported while loading modules
+	Errors []errors.Error
+}
+
+// NewLoader creates a loader for the modules in root. The files are declared in fileSet, parsed
+// with the given vocabulary scope, and bound in a context that can refer to the symbols of
+// outer as well as the modules they refer to.
+func NewLoader(
+	root ModuleSourceScope,
+	fileSet tokens.FileSet,
+	vocabulary parser.VocabularyScope,
+	outer symbols.Scope,
+) *Loader {
+	return &Loader{
+		root:       root,
+		fileSet:    fileSet,
+		vocabulary: vocabulary,
+		outer:      outer,
+		modules:    make(map[string]*Module),
+	}
+}
+
+// Load loads the module with the given qualified name and the modules it refers to. Returns
+// an error if the module cannot be found or read. Errors in the module are recorded in
+// Errors.
+func (l *Loader) Load(name string) (*Module, error) {
+	if module, ok := l.modules[name]; ok {
+		return module, nil
+	}
+	parts := strings.Split(name, ".")
+	scope := l.root
+	for _, part := range parts[:len(parts)-1] {
+		nested, err := scope.FindScope(part)
+		if err != nil {
+			return nil, err
+		}
+		scope = nested
+	}
+	source, err := scope.Find(parts[len(parts)-1])
+	if err != nil {
+		return nil, err
+	}
+	return l.load(name, source)
+}
+
+// Modules are the modules loaded so far. A module is after the modules it refers to unless
+// they refer to each other.
+func (l *Loader) Modules() []*Module {
+	return l.order
+}
+
+// VocabularyScope returns the vocabulary scope of the vocabularies that can be referred to by a
+// module, those of the loader's vocabulary scope and those of the modules of the root scope,
+// such as ...a::b::C::Operators. A module is loaded when its vocabularies are referred to.
+func (l *Loader) VocabularyScope() parser.VocabularyScope {
+	return newVocabularyScope(l)
+}
+
+// Module returns the loaded module with the given qualified name
+func (l *Loader) Module(name string) (*Module, bool) {
+	module, ok := l.modules[name]
+	return module, ok
+}
+
+func (l *Loader) load(name string, source ModuleSource) (*Module, error) {
+	reader, err := source.NewReader()
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := reader.(interface{ Close() error }); ok {
+		defer closer.Close()
+	}
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	module := &Module{Name: name, Source: source, Text: string(content), loading: true}
+	l.modules[name] = module
+	l.loading = append(l.loading, module)
+	defer func() {
+		module.loading = false
+		l.loading = l.loading[:len(l.loading)-1]
+		l.order = append(l.order, module)
+	}()
+	fb := l.fileSet.BuildFile(source.FileName(), len(content))
+	l.Errors = append(l.Errors, l.bind(module, fb)...)
+	return module, nil
+}
+
+// LoadText loads a module from text instead of the root scope, such as the text of a document
+// being edited, declaring the text in the file set with fb. The modules it refers to are
+// loaded from the root scope. The module is not recorded by the loader so it can be loaded
+// again when the text changes. Returns the errors in the module, which are not recorded in
+// Errors.
+func (l *Loader) LoadText(name, fileName, text string, fb tokens.FileBuilder) (*Module, []errors.Error) {
+	parts := strings.Split(name, ".")
+	source := NewModuleSource(parts[len(parts)-1], fileName, func() (io.Reader, error) {
+		return strings.NewReader(text), nil
+	})
+	module := &Module{Name: name, Source: source, Text: text}
+	return module, l.bind(module, fb)
+}
+
+// bind parses the text of module, loads the modules it refers to, and binds and, unless
+// BindOnly is set, checks it. Returns the errors in the module.
+func (l *Loader) bind(module *Module, fb tokens.FileBuilder) []errors.Error {
+	vocabularies := newVocabularyScope(l)
+	p := parser.NewParser(scanner.NewScanner(append([]byte(module.Text), 0), 0, fb), vocabularies)
+	module.Element = p.Parse()
+	module.File = fb.Build()
+	errs := p.Errors()
+	errs = append(errs, l.declareVocabularies(module, vocabularies)...)
+
+	packages := newPackages()
+	for _, reference := range l.references(module.Element) {
+		imported, ok := l.modules[reference.name]
+		if !ok {
+			var err error
+			imported, err = l.load(reference.name, reference.source)
+			if err != nil {
+				errs = append(errs, errors.New(reference.element, "%s", err))
+				continue
+			}
+		}
+		if imported.loading {
+			if !vocabularies.cycles[imported] {
+				errs = append(errs, errors.New(reference.element, "Import cycle %s", l.cycle(imported)))
+			}
+			name := reference.parts[len(reference.parts)-1]
+			packages.enter(reference.parts, types.NewTypeSymbol(name, types.NewErrorType().Type()))
+			continue
+		}
+		if !module.imports(imported) {
+			module.Imports = append(module.Imports, imported)
+			packages.enter(reference.parts, imported.Symbol)
+		}
+	}
+
+	context := NewContextIn(symbols.Merge(packages.scope(), l.outer))
+	context.Imports = append(context.Imports, l.Imports...)
+	for _, imported := range module.Imports {
+		context.Imports = append(context.Imports, imported.Context)
+	}
+	module.Context = context
+	module.Symbol = types.NewTypeSymbol(module.Source.Name(), nil)
+	if module.Element != nil {
+		context.Enter(module.Element)
+		context.Build(module.Symbol, module.Element)
+		if !l.BindOnly {
+			context.Check(module.Symbol, module.Element)
+		}
+	} else {
+		types.NewType(module.Symbol, types.Module, nil, symbols.EmptyScope(), symbols.EmptyScope(), nil, nil)
+	}
+	return append(errs, context.Errors...)
+}
+
+// declareVocabularies builds the vocabularies declared by the definitions of module. A
+// vocabulary can embed the vocabularies declared before it. Returns the errors in the
+// vocabularies.
+func (l *Loader) declareVocabularies(module *Module, scope *vocabularyScope) []errors.Error {
+	var errs []errors.Error
+	module.Vocabularies = make(map[string]parser.Vocabulary)
+	scope.declared = module.Vocabularies
+	for _, statement := range ast.Statements(module.Element) {
+		definition, ok := statement.(ast.Definition)
+		if !ok {
+			continue
+		}
+		literal, ok := definition.Value().(ast.VocabularyLiteral)
+		if !ok {
+			continue
+		}
+		name := definition.Name().Text()
+		vocabulary, vocabularyErrs := parser.BuildVocabulary(scope, literal)
+		errs = append(errs, vocabularyErrs...)
+		if _, ok := module.Vocabularies[name]; !ok {
+			module.Vocabularies[name] = vocabulary
+		}
+	}
+	return errs
+}
+
+// loadVocabularies loads the module with the given qualified name for a vocabulary reference.
+// Returns the vocabulary scope of the module or an error if it cannot be loaded.
+func (l *Loader) loadVocabularies(name string, source ModuleSource) interface{} {
+	imported, ok := l.modules[name]
+	if !ok {
+		var err error
+		imported, err = l.load(name, source)
+		if err != nil {
+			return err
+		}
+	}
+	if imported.loading {
+		return fmt.Errorf("Import cycle %s", l.cycle(imported))
+	}
+	return moduleVocabularies{module: imported}
+}
+
+func (m *Module) imports(module *Module) bool {
+	for _, imported := range m.Imports {
+		if imported == module {
+			return true
+		}
+	}
+	return false
+}
+
+// cycle describes the chain of modules being loaded that leads back to module
+func (l *Loader) cycle(module *Module) string {
+	var names []string
+	for index := len(l.loading) - 1; index >= 0; index-- {
+		names = append([]string{l.loading[index].Name}, names...)
+		if l.loading[index] == module {
+			break
+		}
+	}
+	return strings.Join(append(names, module.Name), " -> ")
+}
+
+// reference is a reference to a module by its qualified name
+type reference struct {
+	name    string
+	parts   []string
+	source  ModuleSource
+	element ast.Element
+}
+
+// references finds the references to other modules in element. A reference is a name, or a
+// selection of names, that starts with the name of a scope or module of the root scope and
+// selects a module. Names that are declared, such as the names of parameters, hide the scopes
+// and modules of the root scope in the scope they are declared in.
+func (l *Loader) references(element ast.Element) []reference {
+	state := &referenceState{loader: l, skipped: make(map[ast.Element]bool), walked: make(map[ast.Element]bool)}
+	v := newReferenceVisitor(state, element, nil)
+	ast.Walk(element, v)
+	return v.state.references
+}
+
+// referenceState is the state shared by the reference visitors of the scopes of a module
+type referenceState struct {
+	loader     *Loader
+	references []reference
+
+	// skipped are the names that are not references, such as the member of a selection
+	skipped map[ast.Element]bool
+
+	// walked are the elements already walked by the visitor of a nested scope
+	walked map[ast.Element]bool
+}
+
+// referenceVisitor finds the references in a scope, the module, a lambda or a type literal
+type referenceVisitor struct {
+	state  *referenceState
+	parent *referenceVisitor
+	names  map[string]bool
+}
+
+func newReferenceVisitor(state *referenceState, scope ast.Element, parent *referenceVisitor) *referenceVisitor {
+	v := &referenceVisitor{state: state, parent: parent, names: make(map[string]bool)}
+	ast.WalkChildren(scope, &declarationVisitor{names: v.names, walked: make(map[ast.Element]bool)})
+	return v
+}
+
+// declares returns true if name is declared in the scope of v or a scope that contains it
+func (v *referenceVisitor) declares(name string) bool {
+	for scope := v; scope != nil; scope = scope.parent {
+		if scope.names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *referenceVisitor) Visit(element ast.Element) bool {
+	if v.state.walked[element] {
+		return true
+	}
+	v.state.walked[element] = true
+	skipped := v.state.skipped
+	switch n := element.(type) {
+	case ast.Lambda, ast.IntrinsicLambda, ast.TypeLiteral:
+		ast.WalkChildren(element, newReferenceVisitor(v.state, element, v))
+	case ast.Definition:
+		skipped[n.Name()] = true
+	case ast.Storage:
+		skipped[n.Name()] = true
+	case ast.Parameter:
+		skipped[n.Name()] = true
+	case ast.TypeParameter:
+		skipped[n.Name()] = true
+	case ast.NamedArgument:
+		skipped[n.Name()] = true
+	case ast.NamedMemberInitializer:
+		skipped[n.Name()] = true
+	case ast.SafeSelection:
+		skipped[n.Member()] = true
+	case ast.Selection:
+		skipped[n.Member()] = true
+		if skipped[n] {
+			skipped[n.Target()] = true
+			return true
+		}
+		var elements []ast.Element
+		if !qualifiedName(n, &elements) {
+			return true
+		}
+		if v.declares(lastName(elements[0])) {
+			return true
+		}
+		if reference, ok := v.state.loader.resolve(elements); ok {
+			v.state.references = append(v.state.references, reference)
+			for _, element := range elements {
+				skipped[element] = true
+			}
+		}
+	case ast.VocabularyEmbedding:
+		var elements []ast.Element
+		for _, name := range n.Name() {
+			elements = append(elements, name)
+			skipped[name] = true
+		}
+		if reference, ok := v.state.loader.resolve(elements); ok {
+			v.state.references = append(v.state.references, reference)
+		}
+	case ast.Name:
+		if skipped[n] || v.declares(n.Text()) {
+			return true
+		}
+		if reference, ok := v.state.loader.resolve([]ast.Element{n}); ok {
+			v.state.references = append(v.state.references, reference)
+		}
+	}
+	return true
+}
+
+// declarationVisitor collects the names declared directly in a scope. The names declared in
+// nested lambdas and type literals are declared in their own scopes.
+type declarationVisitor struct {
+	names  map[string]bool
+	walked map[ast.Element]bool
+}
+
+func (v *declarationVisitor) Visit(element ast.Element) bool {
+	if v.walked[element] {
+		return true
+	}
+	v.walked[element] = true
+	switch n := element.(type) {
+	case ast.Lambda, ast.IntrinsicLambda, ast.TypeLiteral:
+		ast.WalkChildren(element, &declarationVisitor{names: make(map[string]bool), walked: v.walked})
+	case ast.Definition:
+		if name, ok := n.Name().(ast.Name); ok {
+			v.names[name.Text()] = true
+		}
+	case ast.Storage:
+		v.names[n.Name().Text()] = true
+	case ast.Parameter:
+		v.names[n.Name().Text()] = true
+	case ast.TypeParameter:
+		v.names[n.Name().Text()] = true
+	}
+	return true
+}
+
+// qualifiedName collects the prefixes of a selection of names, such as a, a.b and a.b.C for
+// a.b.C
+func qualifiedName(element ast.Element, elements *[]ast.Element) bool {
+	switch n := element.(type) {
+	case ast.Name:
+		*elements = append(*elements, n)
+		return true
+	case ast.Selection:
+		if !qualifiedName(n.Target(), elements) {
+			return false
+		}
+		*elements = append(*elements, n)
+		return true
+	}
+	return false
+}
+
+// resolve finds the module selected by the shortest prefix of a qualified name that names a
+// module
+func (l *Loader) resolve(elements []ast.Element) (reference, bool) {
+	scope := l.root
+	var parts []string
+	for _, element := range elements {
+		part := lastName(element)
+		parts = append(parts, part)
+		if source, err := scope.Find(part); err == nil {
+			return reference{
+				name:    strings.Join(parts, "."),
+				parts:   parts,
+				source:  source,
+				element: element,
+			}, true
+		}
+		nested, err := scope.FindScope(part)
+		if err != nil {
+			break
+		}
+		scope = nested
+	}
+	return reference{}, false
+}
+
+// vocabularyScope is the vocabulary scope a module is parsed with. A vocabulary reference, such
+// as ...a::b::C::Operators, selects the vocabularies of a module like a qualified name selects
+// the module. The module it selects is loaded before the parse continues.
+type vocabularyScope struct {
+	loader   *Loader
+	scope    ModuleSourceScope
+	parts    []string
+	declared map[string]parser.Vocabulary
+
+	// cycles are the modules that could not be loaded because they refer back to the module
+	// being parsed
+	cycles map[*Module]bool
+}
+
+func newVocabularyScope(loader *Loader) *vocabularyScope {
+	return &vocabularyScope{loader: loader, scope: loader.root, cycles: make(map[*Module]bool)}
+}
+
+func (s *vocabularyScope) Get(name string) (interface{}, bool) {
+	root := len(s.parts) == 0
+	if root {
+		if vocabulary, ok := s.declared[name]; ok {
+			return vocabulary, true
+		}
+		if result, ok := s.loader.vocabulary.Get(name); ok {
+			return result, true
+		}
+	}
+	parts := append(s.parts[:len(s.parts):len(s.parts)], name)
+	if source, err := s.scope.Find(name); err == nil {
+		qualified := strings.Join(parts, ".")
+		if imported, ok := s.loader.modules[qualified]; ok && imported.loading {
+			s.cycles[imported] = true
+		}
+		return s.loader.loadVocabularies(qualified, source), true
+	}
+	if nested, err := s.scope.FindScope(name); err == nil {
+		return &vocabularyScope{loader: s.loader, scope: nested, parts: parts, cycles: s.cycles}, true
+	}
+	if root {
+		return nil, false
+	}
+	return fmt.Errorf("Undefined vocabulary '%s', %s has no module or scope %s",
+		strings.Join(parts, "::"), strings.Join(s.parts, "."), name), true
+}
+
+// moduleVocabularies is the vocabulary scope of the vocabularies declared by a module
+type moduleVocabularies struct {
+	module *Module
+}
+
+func (m moduleVocabularies) Get(name string) (interface{}, bool) {
+	if vocabulary, ok := m.module.Vocabularies[name]; ok {
+		return vocabulary, true
+	}
+	return fmt.Errorf("Undefined vocabulary '%s::%s', module %s does not declare %s",
+		strings.ReplaceAll(m.module.Name, ".", "::"), name, m.module.Name, name), true
+}
+
+func lastName(element ast.Element) string {
+	if selection, ok := element.(ast.Selection); ok {
+		return selection.Member().Text()
+	}
+	return element.(ast.Name).Text()
+}
+
+// packages are the type symbols of the scopes that contain the modules a module refers to
+type packages struct {
+	builders map[string]symbols.ScopeBuilder
+	root     symbols.ScopeBuilder
+}
+
+func newPackages() *packages {
+	return &packages{builders: make(map[string]symbols.ScopeBuilder), root: symbols.NewBuilder()}
+}
+
+// enter enters the module symbol for the qualified name given by parts creating the symbols of
+// the scopes that contain it
+func (p *packages) enter(parts []string, module types.TypeSymbol) {
+	builder := p.root
+	for index, part := range parts[:len(parts)-1] {
+		name := strings.Join(parts[:index+1], ".")
+		nested, ok := p.builders[name]
+		if !ok {
+			nested = symbols.NewBuilder()
+			p.builders[name] = nested
+			sym := types.NewTypeSymbol(part, nil)
+			types.NewType(sym, types.Module, nil, symbols.EmptyScope(), nested, nil, nil)
+			builder.Enter(sym)
+		}
+		builder = nested
+	}
+	builder.Enter(module)
+}
+
+func (p *packages) scope() symbols.Scope {
+	return p.root
+}
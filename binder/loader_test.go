@@ -0,0 +1,217 @@
+package binder_test
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"dyego0/binder"
+	"dyego0/parser"
+	"dyego0/symbols"
+	"dyego0/tokens"
+	"dyego0/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("loader", func() {
+	var outer symbols.Scope
+	BeforeEach(func() {
+		prelude := "let Boolean = < >\nlet Int = <\n  let `+` = {! other: Int -> !}: Int\n" +
+			"  let `^` = {! other: Int -> !}: Int\n>\n"
+		element := parseWith(prelude, parser.DefaultVocabularyScope())
+		context := binder.NewContext()
+		module := types.NewTypeSymbol("prelude", nil)
+		context.Enter(element)
+		context.Build(module, element)
+		context.Check(module, element)
+		Expect(context.Errors).To(BeNil())
+		outer = module.Type().TypeScope()
+	})
+	load := func(files map[string]string, name string) (*binder.Loader, *binder.Module, []string) {
+		var names []string
+		for fileName := range files {
+			names = append(names, fileName)
+		}
+		sort.Strings(names)
+		root, err := binder.NewFilesModuleSourceScope(names, func(fileName string) (io.Reader, error) {
+			text, ok := files[fileName]
+			if !ok {
+				return nil, fmt.Errorf("Cannot read %s", fileName)
+			}
+			return strings.NewReader("...Dyego0\n" + text), nil
+		})
+		Expect(err).To(BeNil())
+		loader := binder.NewLoader(root, tokens.NewFileSet(), parser.DefaultVocabularyScope(), outer)
+		module, err := loader.Load(name)
+		Expect(err).To(BeNil())
+		var messages []string
+		for _, err := range loader.Errors {
+			messages = append(messages, err.Error())
+		}
+		return loader, module, messages
+	}
+	moduleNames := func(modules []*binder.Module) []string {
+		var result []string
+		for _, module := range modules {
+			result = append(result, module.Name)
+		}
+		return result
+	}
+	It("can load a module", func() {
+		loader, module, messages := load(map[string]string{"a/b/C.dg": "val x = 1"}, "a.b.C")
+		Expect(messages).To(BeNil())
+		Expect(module.Name).To(Equal("a.b.C"))
+		Expect(module.Symbol.Name()).To(Equal("C"))
+		Expect(module.Symbol.Type().Kind()).To(Equal(types.Module))
+		Expect(module.Source.FileName()).To(Equal("a/b/C.dg"))
+		Expect(moduleNames(loader.Modules())).To(Equal([]string{"a.b.C"}))
+	})
+	It("can refer to the members of other modules", func() {
+		loader, module, messages := load(map[string]string{
+			"a/b/C.dg": "let Point = <\n  x: Int\n  y: Int\n>\nval origin: Point = [x: 0, y: 0]\n" +
+				"let add = { a: Int, b: Int -> a + b }",
+			"a/D.dg": "val p: a.b.C.Point = a.b.C.origin\nval s = a.b.C.add(p.x, 1)",
+			"e/F.dg": "val t = a.D.s + a.b.C.add(1, 2)",
+		}, "e.F")
+		Expect(messages).To(BeNil())
+		Expect(moduleNames(loader.Modules())).To(Equal([]string{"a.b.C", "a.D", "e.F"}))
+		Expect(moduleNames(module.Imports)).To(Equal([]string{"a.D", "a.b.C"}))
+		d, ok := loader.Module("a.D")
+		Expect(ok).To(BeTrue())
+		Expect(moduleNames(d.Imports)).To(Equal([]string{"a.b.C"}))
+		sym, ok := module.Symbol.Type().MemberScope().Find("t")
+		Expect(ok).To(BeTrue())
+		Expect(sym.(types.Member).Type().String()).To(Equal("prelude.Int"))
+	})
+	It("loads each module once", func() {
+		loader, _, messages := load(map[string]string{
+			"a/A.dg": "val a = 1",
+			"a/B.dg": "val b = a.A.a",
+			"a/C.dg": "val c = a.A.a + a.B.b",
+		}, "a.C")
+		Expect(messages).To(BeNil())
+		Expect(moduleNames(loader.Modules())).To(Equal([]string{"a.A", "a.B", "a.C"}))
+		again, err := loader.Load("a.A")
+		Expect(err).To(BeNil())
+		Expect(again).To(BeIdenticalTo(loader.Modules()[0]))
+	})
+	It("does not treat declared names as references", func() {
+		_, module, messages := load(map[string]string{
+			"a/A.dg": "val x = 1",
+			"b/B.dg": "let f = { a: Int -> a }\nval a = 2",
+		}, "b.B")
+		Expect(messages).To(BeNil())
+		Expect(module.Imports).To(BeNil())
+	})
+	It("hides modules by the names declared in a scope", func() {
+		_, module, messages := load(map[string]string{
+			"a/A.dg": "val x = b.B.y",
+			"b/B.dg": "val a = [A: 1]\nval y = a.A",
+		}, "a.A")
+		Expect(messages).To(BeNil())
+		Expect(moduleNames(module.Imports)).To(Equal([]string{"b.B"}))
+		Expect(module.Imports[0].Imports).To(BeNil())
+		_, module, messages = load(map[string]string{
+			"a/A.dg": "val x = b.B.y",
+			"b/B.dg": "val y = 1\nlet f = { -> val a = [A: 1]\n a.A }",
+		}, "a.A")
+		Expect(messages).To(BeNil())
+		Expect(module.Imports[0].Imports).To(BeNil())
+		_, module, messages = load(map[string]string{
+			"a/A.dg": "val x = 1",
+			"b/B.dg": "let f = { a: Int -> a }\nlet g = { -> a.A.x }",
+		}, "b.B")
+		Expect(messages).To(BeNil())
+		Expect(moduleNames(module.Imports)).To(Equal([]string{"a.A"}))
+	})
+	It("reports import cycles", func() {
+		loader, _, messages := load(map[string]string{
+			"a/A.dg": "val x = a.B.y",
+			"a/B.dg": "val y: Int = a.C.z",
+			"a/C.dg": "val z: Int = a.A.x",
+		}, "a.A")
+		Expect(messages).To(Equal([]string{"Import cycle a.A -> a.B -> a.C -> a.A"}))
+		Expect(moduleNames(loader.Modules())).To(Equal([]string{"a.C", "a.B", "a.A"}))
+	})
+	It("exports vocabularies to dependent modules", func() {
+		loader, module, messages := load(map[string]string{
+			"a/Ops.dg": "let Power = <| infix operator `^` right |>",
+			"b/C.dg":   "...a::Ops::Power\nval x = 2 ^ 3 + 1",
+		}, "b.C")
+		Expect(messages).To(BeNil())
+		Expect(moduleNames(module.Imports)).To(Equal([]string{"a.Ops"}))
+		ops, ok := loader.Module("a.Ops")
+		Expect(ok).To(BeTrue())
+		Expect(ops.Vocabularies).To(HaveKey("Power"))
+		sym, ok := module.Symbol.Type().MemberScope().Find("x")
+		Expect(ok).To(BeTrue())
+		Expect(sym.(types.Member).Type().String()).To(Equal("prelude.Int"))
+	})
+	It("can embed the vocabularies of other modules in a vocabulary", func() {
+		loader, module, messages := load(map[string]string{
+			"a/Ops.dg":  "let Power = <| infix operator `^` right |>",
+			"a/More.dg": "let More = <| ...a::Ops::Power |>\nlet Most = <| ...More |>",
+			"b/C.dg":    "...a::More::Most\nval x = 2 ^ 3",
+		}, "b.C")
+		Expect(messages).To(BeNil())
+		Expect(moduleNames(loader.Modules())).To(Equal([]string{"a.Ops", "a.More", "b.C"}))
+		more, _ := loader.Module("a.More")
+		Expect(moduleNames(more.Imports)).To(Equal([]string{"a.Ops"}))
+		Expect(moduleNames(module.Imports)).To(Equal([]string{"a.More"}))
+	})
+	It("reports unknown vocabularies with the module path", func() {
+		_, _, messages := load(map[string]string{
+			"a/Ops.dg": "let Power = <| infix operator `^` right |>",
+			"b/C.dg":   "...a::Ops::Missing\n...a::Nope::Power\nlet V = <| ...a::Ops::Other |>",
+		}, "b.C")
+		Expect(messages).To(Equal([]string{
+			"Undefined vocabulary 'a::Ops::Missing', module a.Ops does not declare Missing",
+			"Undefined vocabulary 'a::Nope', a has no module or scope Nope",
+			"Undefined vocabulary 'a::Ops::Other', module a.Ops does not declare Other",
+		}))
+	})
+	It("reports import cycles between vocabularies", func() {
+		loader, _, messages := load(map[string]string{
+			"a/A.dg": "let V = <| infix operator `^` right |>\n...a::B::W",
+			"a/B.dg": "...a::A::V\nlet W = <| |>",
+		}, "a.A")
+		Expect(messages).To(Equal([]string{"Import cycle a.A -> a.B -> a.A"}))
+		Expect(moduleNames(loader.Modules())).To(Equal([]string{"a.B", "a.A"}))
+	})
+	It("can load a module from text", func() {
+		root, err := binder.NewFilesModuleSourceScope([]string{"a/A.dg"}, func(string) (io.Reader, error) {
+			return strings.NewReader("...Dyego0\nval x = 1"), nil
+		})
+		Expect(err).To(BeNil())
+		fileSet := tokens.NewFileSet()
+		loader := binder.NewLoader(root, fileSet, parser.DefaultVocabularyScope(), outer)
+		text := "...Dyego0\nval y = a.A.x\nval z = w"
+		module, errs := loader.LoadText("b.B", "b/B.dg", text, fileSet.BuildFile("b/B.dg", len(text)))
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Error()).To(Equal("Undefined symbol w"))
+		Expect(module.File.FileName()).To(Equal("b/B.dg"))
+		Expect(moduleNames(module.Imports)).To(Equal([]string{"a.A"}))
+		Expect(moduleNames(loader.Modules())).To(Equal([]string{"a.A"}))
+		Expect(loader.Errors).To(BeNil())
+		text = "...Dyego0\nval y = a.A.x"
+		module, errs = loader.LoadText("b.B", "b/B.dg", text, fileSet.BuildFile("b/B.dg", len(text)))
+		Expect(errs).To(BeNil())
+		Expect(module.Imports[0]).To(BeIdenticalTo(loader.Modules()[0]))
+	})
+	It("reports modules that cannot be read", func() {
+		root, err := binder.NewFilesModuleSourceScope([]string{"a/A.dg"}, func(string) (io.Reader, error) {
+			return nil, fmt.Errorf("Cannot read")
+		})
+		Expect(err).To(BeNil())
+		loader := binder.NewLoader(root, tokens.NewFileSet(), parser.DefaultVocabularyScope(), outer)
+		_, err = loader.Load("a.A")
+		Expect(err).To(MatchError("Cannot read"))
+		_, err = loader.Load("a.Missing")
+		Expect(err).To(MatchError("File 'Missing' not found"))
+		_, err = loader.Load("b.A")
+		Expect(err).To(MatchError("Directory 'b' not found"))
+	})
+})
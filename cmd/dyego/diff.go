@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines printed around a change
+const diffContext = 3
+
+// edit is a line of a diff
+type edit struct {
+	kind byte
+	text string
+}
+
+// unifiedDiff returns the changes from before to after, the old and new content of fileName, in
+// unified diff format. Returns an empty string if the content is the same.
+func unifiedDiff(fileName, before, after string) string {
+	if before == after {
+		return ""
+	}
+	edits := diffLines(splitLines(before), splitLines(after))
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fileName, fileName)
+	oldLine, newLine := 1, 1
+	for start := 0; start < len(edits); {
+		if edits[start].kind == ' ' {
+			start++
+			oldLine++
+			newLine++
+			continue
+		}
+
+		// Extend the hunk until the unchanged lines between changes exceed twice the context
+		end := start
+		for index := start; index < len(edits); index++ {
+			if edits[index].kind != ' ' {
+				end = index + 1
+			} else if index-end >= 2*diffContext {
+				break
+			}
+		}
+		first := start - diffContext
+		if first < 0 {
+			first = 0
+		}
+		last := end + diffContext
+		if last > len(edits) {
+			last = len(edits)
+		}
+		oldStart, newStart := oldLine-(start-first), newLine-(start-first)
+		oldCount, newCount := 0, 0
+		for _, e := range edits[first:last] {
+			if e.kind != '+' {
+				oldCount++
+			}
+			if e.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		for _, e := range edits[first:last] {
+			fmt.Fprintf(&b, "%c%s\n", e.kind, e.text)
+		}
+		for _, e := range edits[start:last] {
+			if e.kind != '+' {
+				oldLine++
+			}
+			if e.kind != '-' {
+				newLine++
+			}
+		}
+		start = last
+	}
+	return b.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func splitLines(text string) []string {
+	lines := strings.Split(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines finds the edits that turn before into after using the longest common subsequence
+// of their lines
+func diffLines(before, after []string) []edit {
+	lengths := make([][]int, len(before)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if before[i] == after[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+	var result []edit
+	i, j := 0, 0
+	for i < len(before) && j < len(after) {
+		switch {
+		case before[i] == after[j]:
+			result = append(result, edit{' ', before[i]})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			result = append(result, edit{'-', before[i]})
+			i++
+		default:
+			result = append(result, edit{'+', after[j]})
+			j++
+		}
+	}
+	for ; i < len(before); i++ {
+		result = append(result, edit{'-', before[i]})
+	}
+	for ; j < len(after); j++ {
+		result = append(result, edit{'+', after[j]})
+	}
+	return result
+}
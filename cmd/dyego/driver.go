@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"dyego0/ast"
+	"dyego0/binder"
+	"dyego0/codegen"
+	"dyego0/diagnostics"
+	"dyego0/errors"
+	"dyego0/parser"
+	"dyego0/scanner"
+	"dyego0/symbols"
+	"dyego0/tokens"
+	"dyego0/types"
+)
+
+// sourceExt is the extension of Dyego0 source files
+const sourceExt = ".dg"
+
+// driver feeds source files through the scanner, parser and binder collecting the errors reported
+type driver struct {
+	stdin      io.Reader
+	stdout     io.Writer
+	stderr     io.Writer
+	fileSet    tokens.FileSet
+	sources    map[string]string
+	vocabulary parser.VocabularyScope
+	outer      symbols.Scope
+	imports    []*binder.BindingContext
+	errors     []errors.Error
+}
+
+// unit is a single source file processed by the driver
+type unit struct {
+	fileName string
+	text     string
+	file     tokens.File
+	element  ast.Element
+	context  *binder.BindingContext
+	module   types.TypeSymbol
+
+	// imports are the units of the modules the unit refers to
+	imports []*unit
+}
+
+func newDriver(stdin io.Reader, stdout, stderr io.Writer) *driver {
+	return &driver{
+		stdin:      stdin,
+		stdout:     stdout,
+		stderr:     stderr,
+		fileSet:    tokens.NewFileSet(),
+		sources:    make(map[string]string),
+		vocabulary: parser.DefaultVocabularyScope(),
+		outer:      symbols.EmptyScope(),
+	}
+}
+
+// Source implements diagnostics.SourceProvider
+func (d *driver) Source(fileName string) diagnostics.Source {
+	text, ok := d.sources[fileName]
+	if !ok {
+		return nil
+	}
+	return source(text)
+}
+
+type source string
+
+func (s source) Text(start, end int) string {
+	if end > len(s) {
+		end = len(s)
+	}
+	return string(s[start:end])
+}
+
+// collectFiles expands directory arguments into the source files they contain
+func collectFiles(args []string) ([]string, error) {
+	var result []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			result = append(result, arg)
+			continue
+		}
+		var found []string
+		err = filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && filepath.Ext(path) == sourceExt {
+				found = append(found, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(found)
+		result = append(result, found...)
+	}
+	return result, nil
+}
+
+// parseAll parses all the files given by args. The files can refer to the vocabularies of the
+// modules given by args, which are loaded as loadAll loads them, and the vocabulary scope of the
+// driver is replaced by one that resolves them. Returns false if the files could not be read.
+func (d *driver) parseAll(args []string) ([]*unit, bool) {
+	root, _, err := moduleSources(args)
+	if err != nil {
+		fmt.Fprintf(d.stderr, "dyego: %s\n", err)
+		return nil, false
+	}
+	d.vocabulary = binder.NewLoader(root, d.fileSet, d.vocabulary, d.outer).VocabularyScope()
+	files, err := collectFiles(args)
+	if err != nil {
+		fmt.Fprintf(d.stderr, "dyego: %s\n", err)
+		return nil, false
+	}
+	var units []*unit
+	for _, fileName := range files {
+		content, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			fmt.Fprintf(d.stderr, "dyego: %s\n", err)
+			return nil, false
+		}
+		units = append(units, d.parse(fileName, string(content)))
+	}
+	return units, true
+}
+
+// parse parses text as the content of fileName
+func (d *driver) parse(fileName, text string) *unit {
+	d.sources[fileName] = text
+	fb := d.fileSet.BuildFile(fileName, len(text))
+	p := parser.NewParser(scanner.NewScanner(append([]byte(text), 0), 0, fb), d.vocabulary)
+	element := p.Parse()
+	file := fb.Build()
+	d.errors = append(d.errors, p.Errors()...)
+	return &unit{fileName: fileName, text: text, file: file, element: element}
+}
+
+// loadAll loads the files given by args as modules that can refer to each other by their
+// qualified names. The files of a directory are named by their path in the directory, so
+// a/b/C.dg is module a.b.C, and a file given directly is named by its base name. Returns the
+// units of the modules after the modules they refer to, or false if the files could not be
+// read. The modules are only bound, and not checked, if check is false.
+func (d *driver) loadAll(args []string, check bool) ([]*unit, bool) {
+	root, names, err := moduleSources(args)
+	if err != nil {
+		fmt.Fprintf(d.stderr, "dyego: %s\n", err)
+		return nil, false
+	}
+	loader := binder.NewLoader(root, d.fileSet, d.vocabulary, d.outer)
+	loader.Imports = d.imports
+	loader.BindOnly = !check
+	for _, name := range names {
+		if _, err := loader.Load(name); err != nil {
+			fmt.Fprintf(d.stderr, "dyego: %s\n", err)
+			return nil, false
+		}
+	}
+	var units []*unit
+	loaded := make(map[*binder.Module]*unit)
+	for _, module := range loader.Modules() {
+		u := &unit{
+			fileName: module.Source.FileName(),
+			text:     module.Text,
+			element:  module.Element,
+			context:  module.Context,
+			module:   module.Symbol,
+		}
+		for _, imported := range module.Imports {
+			u.imports = append(u.imports, loaded[imported])
+		}
+		loaded[module] = u
+		units = append(units, u)
+		d.sources[u.fileName] = u.text
+	}
+	d.errors = append(d.errors, loader.Errors...)
+	return units, true
+}
+
+// moduleSources creates the module source scope of the files given by args. Returns the scope
+// and the qualified names of the files in the order they are given.
+func moduleSources(args []string) (binder.ModuleSourceScope, []string, error) {
+	root := newSourceScope()
+	var names []string
+	for _, arg := range args {
+		files, err := collectFiles([]string{arg})
+		if err != nil {
+			return nil, nil, err
+		}
+		base := filepath.Dir(arg)
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			base = arg
+		}
+		for _, fileName := range files {
+			relative, err := filepath.Rel(base, fileName)
+			if err != nil {
+				return nil, nil, err
+			}
+			parts := strings.Split(filepath.ToSlash(strings.TrimSuffix(relative, filepath.Ext(relative))), "/")
+			if err := root.enter(parts, fileName); err != nil {
+				return nil, nil, err
+			}
+			names = append(names, strings.Join(parts, "."))
+		}
+	}
+	return root, names, nil
+}
+
+// sourceScope is a module source scope of the files given to the driver
+type sourceScope struct {
+	sources map[string]binder.ModuleSource
+	scopes  map[string]*sourceScope
+}
+
+func newSourceScope() *sourceScope {
+	return &sourceScope{sources: make(map[string]binder.ModuleSource), scopes: make(map[string]*sourceScope)}
+}
+
+// enter declares the module with the qualified name given by parts read from fileName
+func (s *sourceScope) enter(parts []string, fileName string) error {
+	scope := s
+	for _, part := range parts[:len(parts)-1] {
+		nested, ok := scope.scopes[part]
+		if !ok {
+			nested = newSourceScope()
+			scope.scopes[part] = nested
+		}
+		scope = nested
+	}
+	name := parts[len(parts)-1]
+	if existing, ok := scope.sources[name]; ok {
+		return fmt.Errorf("%s and %s are both module %s", existing.FileName(), fileName,
+			strings.Join(parts, "."))
+	}
+	scope.sources[name] = binder.NewModuleSource(name, fileName, func() (io.Reader, error) {
+		return os.Open(fileName)
+	})
+	return nil
+}
+
+func (s *sourceScope) Find(name string) (binder.ModuleSource, error) {
+	source, ok := s.sources[name]
+	if !ok {
+		return nil, fmt.Errorf("Module '%s' not found", name)
+	}
+	return source, nil
+}
+
+func (s *sourceScope) FindScope(name string) (binder.ModuleSourceScope, error) {
+	scope, ok := s.scopes[name]
+	if !ok {
+		return nil, fmt.Errorf("Scope '%s' not found", name)
+	}
+	return scope, nil
+}
+
+// bind enters and builds the types declared in unit
+func (d *driver) bind(u *unit) {
+	if u.element == nil {
+		return
+	}
+	context := binder.NewContextIn(d.outer)
+	context.Imports = d.imports
+	base := filepath.Base(u.fileName)
+	module := types.NewTypeSymbol(base[0:len(base)-len(filepath.Ext(base))], nil)
+	context.Enter(u.element)
+	context.Build(module, u.element)
+	u.context = context
+	u.module = module
+	d.errors = append(d.errors, context.Errors...)
+}
+
+// check checks the expressions of a bound unit
+func (d *driver) check(u *unit) {
+	if u.context == nil {
+		return
+	}
+	reported := len(u.context.Errors)
+	u.context.Check(u.module, u.element)
+	d.errors = append(d.errors, u.context.Errors[reported:]...)
+}
+
+// loadBuiltins parses, binds and checks the builtins module declared by fileName. Returns
+// false if the file could not be read or reported errors.
+func (d *driver) loadBuiltins(fileName string) (*codegen.Builtins, bool) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		fmt.Fprintf(d.stderr, "dyego: %s\n", err)
+		return nil, false
+	}
+	u := d.parse(fileName, string(content))
+	d.bind(u)
+	d.check(u)
+	if len(d.errors) > 0 || u.context == nil {
+		return nil, false
+	}
+	return codegen.NewBuiltins(u.context, u.module), true
+}
+
+// report writes the errors collected to stderr and returns the exit code
+func (d *driver) report() int {
+	if len(d.errors) == 0 {
+		return 0
+	}
+	fmt.Fprint(d.stderr, diagnostics.Format(d.errors, d.fileSet, d))
+	return 1
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"dyego0/ast"
+	astjson "dyego0/ast/json"
+	"dyego0/binder"
+	"dyego0/codegen"
+	"dyego0/errors"
+	"dyego0/format"
+	"dyego0/interp"
+	"dyego0/lsp"
+	"dyego0/parser"
+	"dyego0/symbols"
+)
+
+// command is a dyego subcommand
+type command struct {
+	name        string
+	description string
+	run         func(d *driver, args []string) int
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "parse", description: "parse the files and report syntax errors", run: parseCommand},
+		{name: "bind", description: "parse and bind the files and report errors", run: bindCommand},
+		{name: "check", description: "run all available analysis on the files and report errors", run: checkCommand},
+		{name: "run", description: "run the files with the interpreter", run: runCommand},
+		{name: "wasm", description: "compile the files to WebAssembly modules", run: wasmCommand},
+		{name: "lsp", description: "run a language server over stdin and stdout", run: lspCommand},
+		{name: "fmt", description: "format the files", run: fmtCommand},
+		{name: "vocab", description: "print the operator tables of the vocabularies declared by the files", run: vocabCommand},
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command line given by args and returns the process exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	name := args[0]
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd.run(newDriver(stdin, stdout, stderr), args[1:])
+		}
+	}
+	switch name {
+	case "help", "-h", "-help", "--help":
+		usage(stdout)
+		return 0
+	}
+	fmt.Fprintf(stderr, "dyego: unknown command '%s'\n", name)
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: dyego <command> [flags] <file or directory>...")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	names := make([]string, 0, len(commands))
+	descriptions := make(map[string]string)
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+		descriptions[cmd.name] = cmd.description
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, descriptions[name])
+	}
+}
+
+// newFlagSet creates a flag set for the command that reports to the driver's error writer
+func newFlagSet(d *driver, name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(d.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(d.stderr, "usage: dyego %s [flags] <file or directory>...\n", name)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseFlags parses the flags of a command, returning the remaining arguments and false if the
+// command should exit
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, bool) {
+	if err := flags.Parse(args); err != nil {
+		return nil, false
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return nil, false
+	}
+	return flags.Args(), true
+}
+
+func parseCommand(d *driver, args []string) int {
+	flags := newFlagSet(d, "parse")
+	printTree := flags.Bool("print", false, "print the parsed tree")
+	printJSON := flags.Bool("json", false, "print the parsed tree of each file as a line of JSON")
+	files, ok := parseFlags(flags, args)
+	if !ok {
+		return 2
+	}
+	units, ok := d.parseAll(files)
+	if !ok {
+		return 2
+	}
+	for _, unit := range units {
+		if *printTree {
+			fmt.Fprintf(d.stdout, "%s:\n%s\n", unit.fileName, unit.element)
+		}
+		if *printJSON {
+			tree, err := astjson.Marshal(unit.element)
+			if err == nil {
+				tree, err = json.Marshal(struct {
+					File string          `json:"file"`
+					Tree json.RawMessage `json:"tree"`
+				}{unit.fileName, tree})
+			}
+			if err != nil {
+				fmt.Fprintf(d.stderr, "%s: %s\n", unit.fileName, err)
+				return 2
+			}
+			fmt.Fprintf(d.stdout, "%s\n", tree)
+		}
+	}
+	return d.report()
+}
+
+func bindCommand(d *driver, args []string) int {
+	flags := newFlagSet(d, "bind")
+	printTypes := flags.Bool("print", false, "print the types of the bound modules")
+	files, ok := parseFlags(flags, args)
+	if !ok {
+		return 2
+	}
+	units, ok := d.loadAll(files, false)
+	if !ok {
+		return 2
+	}
+	for _, unit := range units {
+		if *printTypes && unit.module != nil && unit.module.Type() != nil {
+			fmt.Fprintf(d.stdout, "%s: %s\n", unit.fileName, describeModule(unit))
+		}
+	}
+	return d.report()
+}
+
+func checkCommand(d *driver, args []string) int {
+	flags := newFlagSet(d, "check")
+	files, ok := parseFlags(flags, args)
+	if !ok {
+		return 2
+	}
+	if _, ok := d.loadAll(files, true); !ok {
+		return 2
+	}
+	return d.report()
+}
+
+func runCommand(d *driver, args []string) int {
+	flags := newFlagSet(d, "run")
+	files, ok := parseFlags(flags, args)
+	if !ok {
+		return 2
+	}
+	d.outer = interp.Prelude()
+	units, ok := d.loadAll(files, true)
+	if !ok {
+		return 2
+	}
+	if len(d.errors) > 0 {
+		return d.report()
+	}
+	interpreters := make(map[*unit]*interp.Interpreter)
+	for _, unit := range units {
+		if unit.element == nil {
+			continue
+		}
+		interpreter := interp.New(unit.context, unit.module, d.stdout)
+		for _, imported := range unit.imports {
+			if importedInterpreter, ok := interpreters[imported]; ok {
+				interpreter.Import(importedInterpreter)
+			}
+		}
+		interpreters[unit] = interpreter
+		result, err := interpreter.Run(unit.element)
+		if err != nil {
+			if e, ok := err.(errors.Error); ok {
+				d.errors = append(d.errors, e)
+				return d.report()
+			}
+			fmt.Fprintf(d.stderr, "dyego: %s\n", err)
+			return 1
+		}
+		if code, ok := result.(int); ok && code != 0 {
+			return code
+		}
+	}
+	return 0
+}
+
+// defaultBuiltins is the builtins module used by the wasm command
+const defaultBuiltins = "builtins/Dyego0_wasm.dg"
+
+func wasmCommand(d *driver, args []string) int {
+	flags := newFlagSet(d, "wasm")
+	builtinsFile := flags.String("builtins", defaultBuiltins, "the builtins module declaring the primitive types")
+	output := flags.String("o", "", "the file to write the module to; defaults to the source file with a .wasm extension")
+	files, ok := parseFlags(flags, args)
+	if !ok {
+		return 2
+	}
+	builtins, ok := d.loadBuiltins(*builtinsFile)
+	if !ok {
+		if len(d.errors) > 0 {
+			return d.report()
+		}
+		return 2
+	}
+	d.outer = builtins.Scope()
+	d.imports = []*binder.BindingContext{builtins.Context()}
+	units, ok := d.loadAll(files, true)
+	if !ok {
+		return 2
+	}
+	if *output != "" && len(units) != 1 {
+		fmt.Fprintln(d.stderr, "dyego: -o requires a single file")
+		return 2
+	}
+	if len(d.errors) > 0 {
+		return d.report()
+	}
+	for _, unit := range units {
+		if unit.element == nil {
+			continue
+		}
+		module, errs := codegen.Generate(builtins, unit.context, unit.module, unit.element)
+		if len(errs) > 0 {
+			d.errors = append(d.errors, errs...)
+			continue
+		}
+		if err := module.Validate(); err != nil {
+			fmt.Fprintf(d.stderr, "dyego: %s: %s\n", unit.fileName, err)
+			return 1
+		}
+		fileName := *output
+		if fileName == "" {
+			fileName = strings.TrimSuffix(unit.fileName, filepath.Ext(unit.fileName)) + ".wasm"
+		}
+		if err := ioutil.WriteFile(fileName, module.Encode(), 0644); err != nil {
+			fmt.Fprintf(d.stderr, "dyego: %s\n", err)
+			return 1
+		}
+	}
+	return d.report()
+}
+
+func fmtCommand(d *driver, args []string) int {
+	flags := newFlagSet(d, "fmt")
+	write := flags.Bool("w", false, "write the result to the source files instead of stdout")
+	diff := flags.Bool("d", false, "print a diff of the changes instead of the formatted source")
+	files, ok := parseFlags(flags, args)
+	if !ok {
+		return 2
+	}
+	units, ok := d.parseAll(files)
+	if !ok {
+		return 2
+	}
+	if len(d.errors) > 0 {
+		return d.report()
+	}
+	for _, unit := range units {
+		formatted, errs := format.Source([]byte(unit.text), d.vocabulary)
+		if len(errs) > 0 {
+			fmt.Fprintf(d.stderr, "dyego: %s: %s\n", unit.fileName, errs[0].Error())
+			return 1
+		}
+		text := string(formatted)
+		if *diff {
+			fmt.Fprint(d.stdout, unifiedDiff(unit.fileName, unit.text, text))
+		}
+		if *write {
+			if text != unit.text {
+				if err := ioutil.WriteFile(unit.fileName, formatted, 0644); err != nil {
+					fmt.Fprintf(d.stderr, "dyego: %s\n", err)
+					return 1
+				}
+			}
+		} else if !*diff {
+			fmt.Fprint(d.stdout, text)
+		}
+	}
+	return d.report()
+}
+
+func vocabCommand(d *driver, args []string) int {
+	flags := newFlagSet(d, "vocab")
+	name := flags.String("name", "", "print only the vocabulary with the given name")
+	printJSON := flags.Bool("json", false, "print the table of each vocabulary as a line of JSON")
+	files, ok := parseFlags(flags, args)
+	if !ok {
+		return 2
+	}
+	units, ok := d.parseAll(files)
+	if !ok {
+		return 2
+	}
+	found := false
+	for _, unit := range units {
+		scope := &declaredVocabularies{declared: make(map[string]parser.Vocabulary), outer: d.vocabulary}
+		for _, statement := range ast.Statements(unit.element) {
+			definition, ok := statement.(ast.Definition)
+			if !ok {
+				continue
+			}
+			literal, ok := definition.Value().(ast.VocabularyLiteral)
+			if !ok {
+				continue
+			}
+			vocabulary, errs := parser.BuildVocabulary(scope, literal)
+			d.errors = append(d.errors, errs...)
+			vocabularyName := definition.Name().Text()
+			scope.declared[vocabularyName] = vocabulary
+			if *name != "" && vocabularyName != *name {
+				continue
+			}
+			found = true
+			table := parser.NewOperatorTable(vocabulary)
+			if *printJSON {
+				line, err := json.Marshal(struct {
+					File       string               `json:"file"`
+					Vocabulary string               `json:"vocabulary"`
+					Levels     parser.OperatorTable `json:"levels"`
+				}{unit.fileName, vocabularyName, table})
+				if err != nil {
+					fmt.Fprintf(d.stderr, "%s: %s\n", unit.fileName, err)
+					return 2
+				}
+				fmt.Fprintf(d.stdout, "%s\n", line)
+			} else {
+				fmt.Fprintf(d.stdout, "%s: %s\n%s", unit.fileName, vocabularyName, table)
+			}
+		}
+	}
+	if *name != "" && !found {
+		fmt.Fprintf(d.stderr, "dyego: vocabulary '%s' not found\n", *name)
+		return 1
+	}
+	return d.report()
+}
+
+// declaredVocabularies is the vocabulary scope of the vocabularies declared by a file. They
+// hide the vocabularies of outer.
+type declaredVocabularies struct {
+	declared map[string]parser.Vocabulary
+	outer    parser.VocabularyScope
+}
+
+func (s *declaredVocabularies) Get(name string) (interface{}, bool) {
+	if vocabulary, ok := s.declared[name]; ok {
+		return vocabulary, true
+	}
+	return s.outer.Get(name)
+}
+
+func lspCommand(d *driver, args []string) int {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	flags.SetOutput(d.stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(d.stderr, "usage: dyego lsp [flags]")
+		flags.PrintDefaults()
+	}
+	builtinsFile := flags.String("builtins", "", "a module declaring the primitive types the documents can refer to")
+	root := flags.String("root", "", "a directory of the modules the documents can refer to by their qualified names")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	options := lsp.Options{FileSet: d.fileSet, Vocabulary: d.vocabulary, Sources: d}
+	if *root != "" {
+		scope, _, err := moduleSources([]string{*root})
+		if err != nil {
+			fmt.Fprintf(d.stderr, "dyego: %s\n", err)
+			return 2
+		}
+		options.Root = scope
+	}
+	if *builtinsFile != "" {
+		builtins, ok := d.loadBuiltins(*builtinsFile)
+		if !ok {
+			if len(d.errors) > 0 {
+				return d.report()
+			}
+			return 2
+		}
+		options.Outer = builtins.Scope()
+		options.Imports = []*binder.BindingContext{builtins.Context()}
+	}
+	if err := lsp.NewServer(options).Serve(d.stdin, d.stdout); err != nil {
+		fmt.Fprintf(d.stderr, "dyego: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func describeModule(unit *unit) string {
+	var names []string
+	t := unit.module.Type()
+	t.TypeScope().ForEach(func(sym symbols.Symbol) bool {
+		names = append(names, sym.Name())
+		return false
+	})
+	sort.Strings(names)
+	var members []string
+	for _, member := range t.Members() {
+		members = append(members, fmt.Sprintf("%s: %s", member.Name(), member.Type()))
+	}
+	return fmt.Sprintf("types [%s], members [%s]", strings.Join(names, ", "), strings.Join(members, ", "))
+}
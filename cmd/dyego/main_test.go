@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dyego0/lsp"
+	"dyego0/wasm"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dyego", func() {
+	var dir string
+	BeforeEach(func() {
+		d, err := ioutil.TempDir("", "dyego")
+		Expect(err).To(BeNil())
+		dir = d
+	})
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+	write := func(name, text string) string {
+		fileName := filepath.Join(dir, name)
+		Expect(os.MkdirAll(filepath.Dir(fileName), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(fileName, []byte(text), 0644)).To(Succeed())
+		return fileName
+	}
+	dyegoWithInput := func(stdin io.Reader, args ...string) (int, string, string) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		code := run(args, stdin, stdout, stderr)
+		return code, stdout.String(), stderr.String()
+	}
+	dyego := func(args ...string) (int, string, string) {
+		return dyegoWithInput(&bytes.Buffer{}, args...)
+	}
+	It("reports usage with no arguments", func() {
+		code, _, stderr := dyego()
+		Expect(code).To(Equal(2))
+		Expect(stderr).To(ContainSubstring("usage: dyego"))
+	})
+	It("reports an unknown command", func() {
+		code, _, stderr := dyego("unknown")
+		Expect(code).To(Equal(2))
+		Expect(stderr).To(ContainSubstring("unknown command 'unknown'"))
+	})
+	It("reports a missing file", func() {
+		code, _, stderr := dyego("parse", filepath.Join(dir, "missing.dg"))
+		Expect(code).To(Equal(2))
+		Expect(stderr).To(ContainSubstring("missing.dg"))
+	})
+	It("requires a file", func() {
+		code, _, _ := dyego("parse")
+		Expect(code).To(Equal(2))
+	})
+	It("can parse a file", func() {
+		file := write("a.dg", "...Dyego0\nvar a = 1 + 2\n")
+		code, stdout, stderr := dyego("parse", "-print", file)
+		Expect(stderr).To(Equal(""))
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(ContainSubstring("Storage("))
+	})
+	It("can print the parsed tree as JSON", func() {
+		file := write("a.dg", "...Dyego0\nvar a = 1\n")
+		code, stdout, stderr := dyego("parse", "-json", file)
+		Expect(stderr).To(Equal(""))
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(HavePrefix(`{"file":"` + file + `","tree":{"kind":"Sequence"`))
+		Expect(stdout).To(ContainSubstring(`{"kind":"Storage","start":10,"end":19`))
+	})
+	It("reports parse errors", func() {
+		file := write("a.dg", "var a = 1\nlet b = else\n")
+		code, _, stderr := dyego("parse", file)
+		Expect(code).To(Equal(1))
+		Expect(stderr).To(ContainSubstring("a.dg:2:9: Expected one of <|"))
+		Expect(stderr).To(ContainSubstring("let b = else\n        ^^^^"))
+	})
+	It("can parse a directory", func() {
+		write("a/b.dg", "let b = 1")
+		write("a/c.dg", "let c = else")
+		write("a/d.txt", "let d = else")
+		code, _, stderr := dyego("parse", dir)
+		Expect(code).To(Equal(1))
+		Expect(stderr).To(ContainSubstring("c.dg"))
+		Expect(stderr).To(Not(ContainSubstring("d.txt")))
+	})
+	It("can bind a file", func() {
+		file := write("m.dg", "let Int = <>\nlet A = < a: Int >\nvar b: A")
+		code, stdout, stderr := dyego("bind", "-print", file)
+		Expect(stderr).To(Equal(""))
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(ContainSubstring("types [A, Int], members [b: m.A]"))
+	})
+	It("reports binding errors", func() {
+		file := write("m.dg", "var b: Missing")
+		code, _, stderr := dyego("check", file)
+		Expect(code).To(Equal(1))
+		Expect(stderr).To(ContainSubstring("Undefined symbol Missing"))
+	})
+	It("reports type errors", func() {
+		file := write("m.dg", "let Int = <>\nlet Boolean = <>\nvar a: Int = true")
+		code, _, stderr := dyego("check", file)
+		Expect(code).To(Equal(1))
+		Expect(stderr).To(ContainSubstring("m.dg:3:14: Expected a value of type m.Int but found m.Boolean"))
+	})
+	It("can run a file", func() {
+		file := write("m.dg", "...Dyego0\nvar a = 2\nprint(\"a is \" + (a * 3).toString())\nreturn a")
+		code, stdout, stderr := dyego("run", file)
+		Expect(stderr).To(Equal(""))
+		Expect(stdout).To(Equal("a is 6"))
+		Expect(code).To(Equal(2))
+	})
+	It("reports runtime errors", func() {
+		file := write("m.dg", "...Dyego0\nval a = [1]\nval b = a[1]")
+		code, _, stderr := dyego("run", file)
+		Expect(code).To(Equal(1))
+		Expect(stderr).To(ContainSubstring("m.dg:3:9: Index 1 out of range [0..1)"))
+		write("m.dg", "...Dyego0\nlet f = { x: Int -> 1 / x }\nval c = f(0)")
+		code, _, stderr = dyego("run", file)
+		Expect(code).To(Equal(1))
+		Expect(stderr).To(ContainSubstring("m.dg:2:21: Division by zero"))
+	})
+	It("can run the ray tracer", func() {
+		code, stdout, stderr := dyego("run", "../../interp/testdata/raytrace.dg")
+		Expect(stderr).To(Equal(""))
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(ContainSubstring("****"))
+	})
+	It("can check modules that refer to each other", func() {
+		write("a/A.dg", "let U = < >\nvar t: b.B.T\n")
+		write("b/B.dg", "let T = < >\n")
+		code, _, stderr := dyego("check", dir)
+		Expect(stderr).To(Equal(""))
+		Expect(code).To(Equal(0))
+		write("b/B.dg", "let T = < >\nvar u: a.A.U\n")
+		code, _, stderr = dyego("check", dir)
+		Expect(code).To(Equal(1))
+		Expect(stderr).To(ContainSubstring("B.dg:2:8: Import cycle a.A -> b.B -> a.A"))
+	})
+	It("can bind modules that refer to each other", func() {
+		write("a/A.dg", "let U = < >\nvar t: b.B.T\n")
+		write("b/B.dg", "let T = < >\n")
+		code, stdout, stderr := dyego("bind", "-print", dir)
+		Expect(stderr).To(Equal(""))
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(ContainSubstring("A.dg: types [U], members [t: B.T]"))
+	})
+	It("can parse, format and list modules that refer to the vocabularies of other modules", func() {
+		write("a/b/C.dg", "let Ops = <| infix operator `^` right |>\n")
+		main := write("Main.dg", "...a::b::C::Ops\nval x = 2^3\nlet V = <| ...a::b::C::Ops |>\n")
+		code, _, stderr := dyego("parse", dir)
+		Expect(stderr).To(Equal(""))
+		Expect(code).To(Equal(0))
+		code, stdout, stderr := dyego("fmt", dir)
+		Expect(stderr).To(Equal(""))
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(HavePrefix("...a::b::C::Ops\nval x = 2 ^ 3\n"))
+		code, stdout, stderr = dyego("vocab", "-name", "V", dir)
+		Expect(stderr).To(Equal(""))
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal(main + ": V\nlevel 1\n  infix `^` right from a::b::C::Ops\n"))
+	})
+	It("reports modules with the same name", func() {
+		first := write("a/m.dg", "...Dyego0\n")
+		second := write("b/m.dg", "...Dyego0\n")
+		code, _, stderr := dyego("check", first, second)
+		Expect(code).To(Equal(2))
+		Expect(stderr).To(ContainSubstring(first + " and " + second + " are both module m"))
+	})
+	It("can run modules that refer to each other", func() {
+		write("Main.dg", "...Dyego0\nprint(lib.Math.twice(lib.Math.base).toString())\nreturn lib.Math.base")
+		write("lib/Math.dg", "...Dyego0\nval base = 21\nlet twice = { a: Int -> a * 2 }\n")
+		code, stdout, stderr := dyego("run", dir)
+		Expect(stderr).To(Equal(""))
+		Expect(stdout).To(Equal("42"))
+		Expect(code).To(Equal(21))
+	})
+	It("can compile a file to WebAssembly", func() {
+		file := write("m.dg", "...Dyego0\nlet double = { a: Int -> a * 2 }\nreturn double(21)")
+		code, _, stderr := dyego("wasm", "-builtins", "../../builtins/Dyego0_wasm.dg", file)
+		Expect(stderr).To(Equal(""))
+		Expect(code).To(Equal(0))
+		data, err := ioutil.ReadFile(filepath.Join(dir, "m.wasm"))
+		Expect(err).To(BeNil())
+		module, err := wasm.Decode(data)
+		Expect(err).To(BeNil())
+		Expect(module.Validate()).To(BeNil())
+		_, ok := module.Export("double")
+		Expect(ok).To(BeTrue())
+		_, ok = module.Export("main")
+		Expect(ok).To(BeTrue())
+	})
+	It("reports code generation errors", func() {
+		file := write("m.dg", "...Dyego0\nlet f = { a: Int -> a }\nlet g = { -> val h = f\n 1 }")
+		output := filepath.Join(dir, "out.wasm")
+		code, _, stderr := dyego("wasm", "-builtins", "../../builtins/Dyego0_wasm.dg", "-o", output, file)
+		Expect(code).To(Equal(1))
+		Expect(stderr).To(ContainSubstring("m.dg:3:22: Lambdas can only be called"))
+		_, err := os.Stat(output)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+	It("can serve the language server protocol", func() {
+		input := &bytes.Buffer{}
+		client := lsp.NewConn(&bytes.Buffer{}, input)
+		Expect(client.Call(1, "initialize", &lsp.InitializeParams{})).To(Succeed())
+		Expect(client.Notify("textDocument/didOpen", &lsp.DidOpenTextDocumentParams{
+			TextDocument: lsp.TextDocumentItem{URI: "file:///m.dg", Text: "...Dyego0\nval a = 1\n"},
+		})).To(Succeed())
+		Expect(client.Call(2, "shutdown", nil)).To(Succeed())
+		Expect(client.Notify("exit", nil)).To(Succeed())
+		code, stdout, stderr := dyegoWithInput(input, "lsp", "-builtins", "../../builtins/Dyego0_wasm.dg")
+		Expect(stderr).To(Equal(""))
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(ContainSubstring(`"hoverProvider":true`))
+		Expect(stdout).To(ContainSubstring(`{"uri":"file:///m.dg","diagnostics":[]}`))
+	})
+	It("can serve documents that refer to the modules of a directory", func() {
+		write("lib/Math.dg", "...Dyego0\nval base = 21\n")
+		input := &bytes.Buffer{}
+		client := lsp.NewConn(&bytes.Buffer{}, input)
+		Expect(client.Call(1, "initialize", &lsp.InitializeParams{})).To(Succeed())
+		Expect(client.Notify("textDocument/didOpen", &lsp.DidOpenTextDocumentParams{
+			TextDocument: lsp.TextDocumentItem{URI: "file:///m.dg", Text: "...Dyego0\nval a = lib.Math.base\n"},
+		})).To(Succeed())
+		Expect(client.Call(2, "shutdown", nil)).To(Succeed())
+		Expect(client.Notify("exit", nil)).To(Succeed())
+		code, stdout, stderr := dyegoWithInput(input, "lsp", "-builtins", "../../builtins/Dyego0_wasm.dg", "-root", dir)
+		Expect(stderr).To(Equal(""))
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(ContainSubstring(`{"uri":"file:///m.dg","diagnostics":[]}`))
+	})
+	It("can format a file", func() {
+		file := write("m.dg", "...Dyego0\nval a = 1+2 // three\n")
+		code, stdout, stderr := dyego("fmt", file)
+		Expect(stderr).To(Equal(""))
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal("...Dyego0\nval a = 1 + 2 // three\n"))
+	})
+	It("can print the diff of formatting a file", func() {
+		file := write("m.dg", "...Dyego0\nval a = 1+2\n")
+		code, stdout, _ := dyego("fmt", "-d", file)
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal("--- " + file + "\n+++ " + file + "\n@@ -1,2 +1,2 @@\n ...Dyego0\n-val a = 1+2\n+val a = 1 + 2\n"))
+	})
+	It("can format a file in place", func() {
+		file := write("m.dg", "...Dyego0\nval a = 1+2\n")
+		code, stdout, _ := dyego("fmt", "-w", file)
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal(""))
+		content, err := ioutil.ReadFile(file)
+		Expect(err).To(BeNil())
+		Expect(string(content)).To(Equal("...Dyego0\nval a = 1 + 2\n"))
+	})
+	It("reports parse errors when formatting", func() {
+		file := write("m.dg", "val = 1\n")
+		code, _, stderr := dyego("fmt", "-w", file)
+		Expect(code).To(Equal(1))
+		Expect(stderr).To(ContainSubstring("m.dg:1:5"))
+	})
+	It("can print the operator table of a vocabulary", func() {
+		file := write("v.dg", "let Base = <| infix operator `*` left, infix operator `+` left |>\n"+
+			"let Power = <|\n  ...Base\n  infix operator `^` before infix `*` right\n|>\n")
+		code, stdout, stderr := dyego("vocab", "-name", "Power", file)
+		Expect(stderr).To(Equal(""))
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal(file + ": Power\nlevel 3\n  infix `^` right\nlevel 2\n  infix `*` left from Base\n" +
+			"level 1\n  infix `+` left from Base\n"))
+	})
+	It("can print the operator table of a vocabulary as JSON", func() {
+		file := write("v.dg", "let V = <| infix operator `+` left |>\n")
+		code, stdout, stderr := dyego("vocab", "-json", file)
+		Expect(stderr).To(Equal(""))
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal(`{"file":"` + file + `","vocabulary":"V","levels":[` +
+			`{"level":1,"operators":[{"name":"+","placement":"infix","associativity":"left"}]}]}` + "\n"))
+	})
+	It("numbers the levels of an embedded vocabulary from 1", func() {
+		file := write("v.dg", "let Base = <| infix operator `+` left |>\nlet V = <| ...Base, prefix operator `-` right |>\n")
+		code, stdout, stderr := dyego("vocab", "-name", "V", "-json", file)
+		Expect(stderr).To(Equal(""))
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal(`{"file":"` + file + `","vocabulary":"V","levels":[` +
+			`{"level":2,"operators":[{"name":"+","placement":"infix","associativity":"left","embedding":"Base"}]},` +
+			`{"level":1,"operators":[{"name":"-","placement":"prefix","associativity":"right"}]}]}` +
+			"\n"))
+	})
+	It("reports a missing vocabulary", func() {
+		file := write("v.dg", "let V = <| infix operator `+` left |>\n")
+		code, _, stderr := dyego("vocab", "-name", "W", file)
+		Expect(code).To(Equal(1))
+		Expect(stderr).To(ContainSubstring("vocabulary 'W' not found"))
+	})
+	It("can check the examples", func() {
+		code, _, stderr := dyego("parse", "../../examples/Simple0.dg", "../../builtins")
+		Expect(stderr).To(Equal(""))
+		Expect(code).To(Equal(0))
+	})
+})
+
+func TestDyego(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Dyego Suite")
+}
@@ -0,0 +1,89 @@
+package codegen
+
+import (
+	"dyego0/ast"
+	"dyego0/binder"
+	"dyego0/intrinsics"
+	"dyego0/symbols"
+	"dyego0/types"
+)
+
+// Builtins are the types and instructions declared by a builtins module, such as
+// builtins/Dyego0_wasm.dg. The primitive types of the builtins describe their representation
+// with the type members `@fmt`, `@size`, `@load.global` and `@store.global`, and implement their
+// operators with intrinsic lambdas. The instructions the generator emits directly are taken
+// from the members of the `inst` type.
+type Builtins struct {
+	evaluator *intrinsics.Evaluator
+	context   *binder.BindingContext
+	module    types.TypeSymbol
+	opcodes   map[string]byte
+}
+
+// NewBuiltins creates the builtins from a module that has been bound and checked
+func NewBuiltins(context *binder.BindingContext, module types.TypeSymbol) *Builtins {
+	b := &Builtins{
+		evaluator: &intrinsics.Evaluator{
+			Definitions: []map[symbols.Symbol]ast.Element{context.Definitions},
+			Scope:       module.Type().TypeScope(),
+		},
+		context: context,
+		module:  module,
+		opcodes: make(map[string]byte),
+	}
+	if sym, ok := b.evaluator.Scope.Find("inst"); ok {
+		if inst, ok := sym.(types.TypeSymbol); ok && inst.Type() != nil {
+			b.collect("", inst)
+		}
+	}
+	return b
+}
+
+// Scope is the scope the modules code is generated for are bound in
+func (b *Builtins) Scope() symbols.Scope {
+	return b.evaluator.Scope
+}
+
+// Context is the context the builtins were bound in
+func (b *Builtins) Context() *binder.BindingContext {
+	return b.context
+}
+
+// collect records the byte constants declared by the instruction type, and its nested
+// types, by their qualified name such as i32.add
+func (b *Builtins) collect(prefix string, typeSym types.TypeSymbol) {
+	typeSym.Type().TypeScope().ForEach(func(sym symbols.Symbol) bool {
+		switch s := sym.(type) {
+		case types.TypeSymbol:
+			if s.Type() != nil {
+				b.collect(prefix+s.Name()+".", s)
+			}
+		case types.TypeMember:
+			if value, ok := b.evaluator.ConstantOf(s); ok {
+				if opcode, ok := value.(byte); ok {
+					b.opcodes[prefix+s.Name()] = opcode
+				}
+			}
+		}
+		return false
+	})
+}
+
+// opcode returns the opcode of the instruction with the given qualified name
+func (b *Builtins) opcode(name string) (byte, bool) {
+	opcode, ok := b.opcodes[name]
+	return opcode, ok
+}
+
+// forEach calls block for each element of a sequence
+func forEach(element ast.Element, block func(element ast.Element)) {
+	for element != nil {
+		sequence, ok := element.(ast.Sequence)
+		if !ok {
+			block(element)
+			return
+		}
+		forEach(sequence.Left(), block)
+		element = sequence.Right()
+	}
+}
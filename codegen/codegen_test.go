@@ -0,0 +1,164 @@
+package codegen_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"dyego0/ast"
+	"dyego0/binder"
+	"dyego0/codegen"
+	"dyego0/parser"
+	"dyego0/scanner"
+	"dyego0/symbols"
+	"dyego0/tokens"
+	"dyego0/types"
+	"dyego0/wasm"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("codegen", func() {
+	bind := func(name, text string, outer symbols.Scope) (*binder.BindingContext, types.TypeSymbol, ast.Element) {
+		fb := tokens.NewFileSet().BuildFile(name, len(text))
+		p := parser.NewParser(scanner.NewScanner(append([]byte(text), 0), 0, fb), parser.DefaultVocabularyScope())
+		element := p.Parse()
+		fb.Build()
+		Expect(p.Errors()).To(BeNil())
+		context := binder.NewContextIn(outer)
+		module := types.NewTypeSymbol(name, nil)
+		context.Enter(element)
+		context.Build(module, element)
+		context.Check(module, element)
+		var messages []string
+		for _, err := range context.Errors {
+			messages = append(messages, err.Error())
+		}
+		Expect(messages).To(BeNil())
+		return context, module, element
+	}
+	var builtins *codegen.Builtins
+	BeforeEach(func() {
+		if builtins != nil {
+			return
+		}
+		text, err := ioutil.ReadFile("../builtins/Dyego0_wasm.dg")
+		Expect(err).To(BeNil())
+		context, module, _ := bind("Dyego0", string(text), symbols.EmptyScope())
+		builtins = codegen.NewBuiltins(context, module)
+	})
+	generate := func(text string) (*wasm.Module, []string) {
+		context, module, element := bind("m", "...Dyego0\n"+text, builtins.Scope())
+		m, errs := codegen.Generate(builtins, context, module, element)
+		var messages []string
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+		return m, messages
+	}
+	valid := func(text string) *wasm.Module {
+		m, messages := generate(text)
+		Expect(messages).To(BeNil())
+		decoded, err := wasm.Decode(m.Encode())
+		Expect(err).To(BeNil())
+		Expect(decoded.Validate()).To(BeNil())
+		return decoded
+	}
+	exported := func(m *wasm.Module, name string) wasm.Function {
+		export, ok := m.Export(name)
+		Expect(ok).To(BeTrue())
+		Expect(export.Kind).To(Equal(wasm.FunctionExport))
+		return m.Functions[export.Index]
+	}
+	instructions := func(m *wasm.Module, name string) []string {
+		decoded, err := wasm.DecodeInstructions(exported(m, name).Body)
+		Expect(err).To(BeNil())
+		var result []string
+		for _, instruction := range decoded {
+			result = append(result, instruction.String())
+		}
+		return result
+	}
+	It("can generate a function", func() {
+		m := valid("let add = { a: Int, b: Int -> a + b }")
+		Expect(m.Types[exported(m, "add").Type]).To(Equal(wasm.FuncType{
+			Params:  []wasm.ValueType{wasm.I32, wasm.I32},
+			Results: []wasm.ValueType{wasm.I32},
+		}))
+		Expect(instructions(m, "add")).To(Equal([]string{"local.get 0", "local.get 1", "i32.add", "end"}))
+		_, ok := m.Export("memory")
+		Expect(ok).To(BeTrue())
+	})
+	It("can generate calls with named arguments", func() {
+		m := valid("let sub = { a: Int, b: Int -> a - b }\nlet f = { -> sub(b: 2, a: 5) + sub(3, 1) }")
+		Expect(instructions(m, "f")).To(Equal([]string{
+			"i32.const 5", "i32.const 2", "call 0", "i32.const 3", "i32.const 1", "call 0", "i32.add", "end",
+		}))
+	})
+	It("can generate conversions", func() {
+		m := valid("let f = { a: Int -> a.toDouble().sqrt().floor().toLong() }")
+		Expect(instructions(m, "f")).To(Equal([]string{
+			"local.get 0", "f64.convert_i32_s", "f64.sqrt", "f64.floor", "i64.trunc_f64_s", "end",
+		}))
+	})
+	It("can generate the statements of the module as main", func() {
+		m := valid("var a = 2\na += 3\nreturn a * 3")
+		Expect(instructions(m, "main")).To(Equal([]string{
+			"i32.const 2", "global.set 1",
+			"global.get 1", "i32.const 3", "i32.add", "global.set 1", "global.get 1", "drop",
+			"global.get 1", "i32.const 3", "i32.mul", "return",
+			"i32.const 0", "end",
+		}))
+	})
+	It("can generate loops", func() {
+		m := valid("let sum = { n: Int ->\n  var i = 0\n  var total = 0\n  while (i < n) {\n" +
+			"    i = i + 1\n    if (i == 3) { continue }\n    total += i\n  }\n  total\n}")
+		Expect(instructions(m, "sum")).To(ContainElement("loop"))
+		Expect(instructions(m, "sum")).To(ContainElement("br 2"))
+	})
+	It("can generate when expressions", func() {
+		m := valid("let sign = { n: Int -> when {\n  n < 0 -> { 2 }\n  n > 0 -> { 1 }\n  else -> { 0 }\n} }\n" +
+			"let name = { n: Int -> when (n) {\n  0 -> { 10 }\n  else -> { 30 }\n} }")
+		Expect(instructions(m, "name")).To(Equal([]string{
+			"local.get 0", "local.set 1", "local.get 1", "i32.const 0", "i32.eq",
+			"if i32", "i32.const 10", "else", "i32.const 30", "end", "end",
+		}))
+	})
+	It("can generate records and methods", func() {
+		m := valid("let Point = <\n  x: Double\n  y: Double\n  let length = { (x * x + y * y).sqrt() }\n>\n" +
+			"let length = { x: Double, y: Double ->\n  val p: Point = [x: x, y: y]\n  p.length()\n}")
+		Expect(instructions(m, "length")).To(ContainElement("call 0"))
+		Expect(m.Functions).To(HaveLen(3))
+	})
+	It("can convert composed records to records with the same layout", func() {
+		source := "let A = < a: Double >\nlet B = < b: Double >\n"
+		valid(source + "let f = { x: Double ->\n  val ab: A & B = [a: x, b: x]\n  val a: A = ab\n  a.a\n}")
+		_, messages := generate(source + "let f = { x: Double ->\n  val ab: A & B = [a: x, b: x]\n" +
+			"  val b: B = ab\n  b.b\n}")
+		Expect(messages).To(Equal([]string{"Converting <a: Dyego0.Double, b: Dyego0.Double> to m.B is not supported"}))
+	})
+	It("can generate arrays", func() {
+		m := valid("val a = [1, 2, 3]\na[0] = a[1] + a.size\nreturn a[0]")
+		Expect(instructions(m, "main")).To(ContainElement("i32.load offset=4"))
+		Expect(instructions(m, "main")).To(ContainElement("i32.store offset=4"))
+	})
+	It("reports generic lambdas", func() {
+		_, messages := generate("let id = { X | x: X -> x }\nlet f = { -> id(1.5) }\nlet g = { -> id(1) }")
+		Expect(messages).To(Equal([]string{"Generic lambdas are not supported"}))
+	})
+	It("reports safe selections", func() {
+		_, messages := generate("let P = < x: Double >\nlet f = { p: P? -> p?.x }")
+		Expect(messages).To(Equal([]string{"Safe selections are not supported"}))
+		_, messages = generate("let f = { a: Double? -> a?.sqrt() }")
+		Expect(messages).To(Equal([]string{"Safe selections are not supported"}))
+	})
+	It("reports lambda values", func() {
+		_, messages := generate("let f = { a: Int -> a }\nlet g = { -> val h = f\n 1 }")
+		Expect(messages).To(ContainElement("Lambdas can only be called"))
+	})
+})
+
+func TestCodegen(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Codegen Suite")
+}
@@ -0,0 +1,740 @@
+package codegen
+
+import (
+	"dyego0/ast"
+	"dyego0/symbols"
+	"dyego0/types"
+	"dyego0/wasm"
+)
+
+// loopLabel records the depth of the block that encloses a loop. A break branches to the
+// block and a continue branches to the loop that immediately follows it.
+type loopLabel struct {
+	label string
+	depth int
+}
+
+// function generates the body of a function. Each expression leaves a value on the stack
+// exactly when the binder recorded a type for it.
+type function struct {
+	g       *generator
+	code    wasm.Encoder
+	params  int
+	locals  []wasm.ValueType
+	indexes map[ast.Element]uint32
+	result  *representation
+
+	// this is the index of the local that holds the record whose fields are in scope or -1
+	this     int
+	thisType types.TypeSymbol
+
+	depth int
+	loops []loopLabel
+}
+
+func newFunction(g *generator, params int, result *representation) *function {
+	return &function{
+		g:       g,
+		params:  params,
+		indexes: make(map[ast.Element]uint32),
+		result:  result,
+		this:    -1,
+	}
+}
+
+// finish ends the function body
+func (f *function) finish(typeIndex uint32) wasm.Function {
+	f.op(nil, "end")
+	return wasm.Function{Type: typeIndex, Locals: f.locals, Body: f.code.Result()}
+}
+
+func (f *function) op(element ast.Element, name string) {
+	f.code.Byte(f.g.op(element, name))
+}
+
+// indexed emits an instruction that takes an index, such as local.get
+func (f *function) indexed(name string, index uint32) {
+	f.op(nil, name)
+	f.code.U32(index)
+}
+
+// local declares a new local
+func (f *function) local(format wasm.ValueType) uint32 {
+	index := uint32(f.params + len(f.locals))
+	f.locals = append(f.locals, format)
+	return index
+}
+
+// open starts a block, loop or if
+func (f *function) open(name string, blockType byte) {
+	f.op(nil, name)
+	f.code.Byte(blockType)
+	f.depth++
+}
+
+func (f *function) close() {
+	f.op(nil, "end")
+	f.depth--
+}
+
+func (f *function) hasValue(element ast.Element) bool {
+	return f.g.context.Types[element] != nil
+}
+
+func (f *function) representationOf(element ast.Element) *representation {
+	return f.g.representationOf(element, f.g.context.Types[element])
+}
+
+func (f *function) blockType(element ast.Element) byte {
+	if !f.hasValue(element) {
+		return emptyBlock
+	}
+	return byte(f.representationOf(element).format)
+}
+
+// body generates the body of a lambda
+func (f *function) body(body ast.Element) {
+	hasValue := false
+	if body != nil {
+		f.expression(body)
+		hasValue = f.hasValue(body)
+	}
+	switch {
+	case f.result != nil && !hasValue:
+		f.op(body, "unreachable")
+	case f.result == nil && hasValue:
+		f.op(body, "drop")
+	}
+}
+
+// statement generates an expression whose value, if any, is not used
+func (f *function) statement(element ast.Element) {
+	f.expression(element)
+	if f.hasValue(element) {
+		f.op(element, "drop")
+	}
+}
+
+func (f *function) expression(element ast.Element) {
+	if target, ok := f.g.context.Conversions[element]; ok {
+		f.convert(element, target)
+	}
+	switch n := element.(type) {
+	case ast.Sequence:
+		f.statement(n.Left())
+		f.expression(n.Right())
+	case ast.Literal:
+		f.g.constant(&f.code, n, f.representationOf(n).format, n.Value())
+	case ast.Name:
+		f.name(n)
+	case ast.Selection:
+		f.selection(n)
+	case ast.SafeSelection:
+		f.g.error(n, "Safe selections are not supported")
+	case ast.Call:
+		f.call(n)
+	case ast.Lambda, ast.IntrinsicLambda:
+		f.g.error(n, "Lambdas can only be declared by modules and types")
+	case ast.ObjectInitializer:
+		f.objectInitializer(n)
+	case ast.ArrayInitializer:
+		f.arrayInitializer(n)
+	case ast.When:
+		f.when(n)
+	case ast.Loop:
+		f.loop(n)
+	case ast.Break:
+		if depth, ok := f.loopDepth(n, n.Label()); ok {
+			f.indexed("br", uint32(f.depth-depth))
+		}
+	case ast.Continue:
+		if depth, ok := f.loopDepth(n, n.Label()); ok {
+			f.indexed("br", uint32(f.depth-depth-1))
+		}
+	case ast.Return:
+		if n.Value() != nil {
+			f.expression(n.Value())
+		}
+		f.op(n, "ret")
+	case ast.Storage:
+		f.declare(n, n.Value())
+	case ast.Definition:
+		f.declare(n, n.Value())
+	}
+}
+
+// declare declares a local and initializes it
+func (f *function) declare(element ast.Element, value ast.Element) {
+	field, ok := f.g.declared(element).(types.Field)
+	if !ok {
+		// Type members are generated separately
+		return
+	}
+	if _, ok := value.(ast.Lambda); ok {
+		f.g.error(value, "Lambdas can only be declared by modules and types")
+		return
+	}
+	r := f.g.representationOf(element, field.Type())
+	index := f.local(r.format)
+	f.indexes[element] = index
+	if value != nil {
+		f.expression(value)
+	} else {
+		f.g.constant(&f.code, element, r.format, 0)
+	}
+	f.indexed("local.set", index)
+}
+
+// isTypeExpression is true if element refers to a type instead of a value
+func (f *function) isTypeExpression(element ast.Element) bool {
+	var sym symbols.Symbol
+	switch n := element.(type) {
+	case ast.Name:
+		sym = f.g.context.References[n]
+	case ast.Selection:
+		sym = f.g.context.References[n.Member()]
+	}
+	_, ok := sym.(types.TypeSymbol)
+	return ok
+}
+
+func (f *function) name(name ast.Name) {
+	switch s := f.g.context.References[name].(type) {
+	case types.TypeSymbol:
+		f.g.error(name, "Expected a value but found type %s", s)
+	case types.Parameter:
+		if parameter, ok := f.g.evaluator.Definition(s).(ast.Parameter); ok {
+			f.indexed("local.get", f.indexes[parameter])
+		} else if s.Name() == "this" && f.this >= 0 {
+			f.indexed("local.get", uint32(f.this))
+		} else {
+			f.g.error(name, "%s is not accessible", name.Text())
+		}
+	case types.TypeMember:
+		f.typeMember(name, s)
+	case types.Member:
+		lvalue := f.lvalue(name)
+		if lvalue != nil {
+			lvalue.load()
+		}
+	default:
+		f.g.error(name, "Undefined symbol %s", name.Text())
+	}
+}
+
+// typeMember generates the value of a type member declared with a constant value
+func (f *function) typeMember(element ast.Element, member types.TypeMember) {
+	if value, ok := f.g.evaluator.ConstantOf(member); ok {
+		f.g.constant(&f.code, element, f.representationOf(element).format, value)
+		return
+	}
+	if _, ok := f.g.functions[member]; ok {
+		f.g.error(element, "Lambdas can only be called")
+		return
+	}
+	f.g.error(element, "%s is not a constant", member.Name())
+}
+
+func (f *function) selection(selection ast.Selection) {
+	target := selection.Target()
+	name := selection.Member().Text()
+	targetType := f.g.context.Types[target]
+	if !f.isTypeExpression(target) && targetType != nil && isArray(targetType) && name == "size" {
+		f.expression(target)
+		f.loadAt(f.g.pointer(), 0)
+		return
+	}
+	switch s := f.g.context.References[selection.Member()].(type) {
+	case types.TypeMember:
+		f.typeMember(selection, s)
+	case types.Member:
+		lvalue := f.lvalue(selection)
+		if lvalue != nil {
+			lvalue.load()
+		}
+	default:
+		f.g.error(selection, "Expected a value")
+	}
+}
+
+// convert reports the conversion of the record value of element to target unless the fields of
+// target are laid out the same in both types, such as the fields of A in a value of A & B
+func (f *function) convert(element ast.Element, target types.TypeSymbol) {
+	value := f.g.context.Types[element]
+	from := f.g.recordLayoutOf(element, value)
+	for name, fld := range f.g.recordLayoutOf(element, target).fields {
+		if other, ok := from.fields[name]; !ok || other.offset != fld.offset {
+			f.g.error(element, "Converting %s to %s is not supported", value, target)
+			return
+		}
+	}
+}
+
+func isArray(typeSym types.TypeSymbol) bool {
+	t := typeSym.Type()
+	if t != nil && t.Kind() == types.Reference {
+		t = t.Referant().Type()
+	}
+	return t != nil && t.Kind() == types.Array
+}
+
+// loadAt loads a value of the given representation from the address on the stack
+func (f *function) loadAt(r *representation, offset uint32) {
+	f.code.Byte(r.load)
+	f.code.U32(r.align)
+	f.code.U32(offset)
+}
+
+// storeAt stores the value on the stack at the address below it
+func (f *function) storeAt(r *representation, offset uint32) {
+	f.code.Byte(r.store)
+	f.code.U32(r.align)
+	f.code.U32(offset)
+}
+
+// lvalue is a local, global or field that can be loaded and stored
+type lvalue struct {
+	f      *function
+	local  int
+	global int
+
+	// pointer is the local holding the address of the record of a field
+	pointer uint32
+	field   *field
+}
+
+func (l *lvalue) load() {
+	switch {
+	case l.local >= 0:
+		l.f.indexed("local.get", uint32(l.local))
+	case l.global >= 0:
+		l.f.indexed("global.get", uint32(l.global))
+	default:
+		l.f.indexed("local.get", l.pointer)
+		l.f.loadAt(l.field.representation, l.field.offset)
+	}
+}
+
+// store stores the value on the stack leaving it on the stack if keep is true
+func (l *lvalue) store(keep bool) {
+	switch {
+	case l.local >= 0:
+		if keep {
+			l.f.indexed("local.tee", uint32(l.local))
+		} else {
+			l.f.indexed("local.set", uint32(l.local))
+		}
+	case l.global >= 0:
+		l.f.indexed("global.set", uint32(l.global))
+		if keep {
+			l.f.indexed("global.get", uint32(l.global))
+		}
+	default:
+		value := l.f.local(l.field.representation.format)
+		l.f.indexed("local.set", value)
+		l.f.indexed("local.get", l.pointer)
+		l.f.indexed("local.get", value)
+		l.f.storeAt(l.field.representation, l.field.offset)
+		if keep {
+			l.f.indexed("local.get", value)
+		}
+	}
+}
+
+// lvalue finds the storage referred to by a name or a selection of a field. The target of a
+// selection is evaluated once into a local.
+func (f *function) lvalue(element ast.Element) *lvalue {
+	result := &lvalue{f: f, local: -1, global: -1}
+	switch n := element.(type) {
+	case ast.Name:
+		sym := f.g.context.References[n]
+		if index, ok := f.g.globals[sym]; ok {
+			result.global = int(index)
+			return result
+		}
+		if index, ok := f.indexes[f.g.evaluator.Definition(sym)]; ok {
+			result.local = int(index)
+			return result
+		}
+		if f.this >= 0 {
+			if fld, ok := f.g.recordLayoutOf(n, f.thisType).fields[n.Text()]; ok {
+				result.pointer = uint32(f.this)
+				result.field = fld
+				return result
+			}
+		}
+	case ast.Selection:
+		if _, ok := f.g.context.References[n.Member()].(types.TypeMember); ok {
+			break
+		}
+		targetType := f.g.context.Types[n.Target()]
+		if targetType == nil || f.isTypeExpression(n.Target()) {
+			break
+		}
+		if fld, ok := f.g.recordLayoutOf(n, targetType).fields[n.Member().Text()]; ok {
+			f.expression(n.Target())
+			result.pointer = f.local(wasm.I32)
+			result.field = fld
+			f.indexed("local.set", result.pointer)
+			return result
+		}
+	}
+	f.g.error(element, "Expression cannot be accessed")
+	return nil
+}
+
+func (f *function) call(call ast.Call) {
+	arguments := call.Arguments()
+	selection, isSelection := call.Target().(ast.Selection)
+	if isSelection && len(arguments) == 1 {
+		switch name := selection.Member().Text(); name {
+		case "=":
+			lvalue := f.lvalue(selection.Target())
+			f.expression(arguments[0])
+			if lvalue != nil {
+				lvalue.store(true)
+			}
+			return
+		case "&&", "||":
+			f.expression(selection.Target())
+			f.open("if", byte(wasm.I32))
+			if name == "&&" {
+				f.expression(arguments[0])
+				f.op(nil, "else")
+				f.g.constant(&f.code, call, wasm.I32, false)
+			} else {
+				f.g.constant(&f.code, call, wasm.I32, true)
+				f.op(nil, "else")
+				f.expression(arguments[0])
+			}
+			f.close()
+			return
+		case "+=", "-=", "*=", "/=", "%=":
+			operator, ok := f.g.context.References[selection.Member()].(types.TypeMember)
+			if ok && operator.Name() == name[:len(name)-1] {
+				lvalue := f.lvalue(selection.Target())
+				if lvalue == nil {
+					return
+				}
+				lvalue.load()
+				f.invoke(call, operator, arguments, func() {})
+				lvalue.store(true)
+				return
+			}
+		}
+	}
+	switch target := call.Target().(type) {
+	case ast.Selection:
+		receiver := target.Target()
+		targetType := f.g.context.Types[receiver]
+		name := target.Member().Text()
+		if !f.isTypeExpression(receiver) && targetType != nil && isArray(targetType) && (name == "get" || name == "set") {
+			f.array(call, receiver, targetType, name)
+			return
+		}
+		member, ok := f.g.context.References[target.Member()].(types.TypeMember)
+		if !ok {
+			break
+		}
+		if f.isTypeExpression(receiver) {
+			f.invoke(call, member, arguments, nil)
+		} else {
+			f.invoke(call, member, arguments, func() { f.expression(receiver) })
+		}
+		return
+	case ast.Name:
+		member, ok := f.g.context.References[target].(types.TypeMember)
+		if !ok {
+			break
+		}
+		var receiver func()
+		if f.this >= 0 && f.g.owners[member] == f.thisType {
+			receiver = func() { f.indexed("local.get", uint32(f.this)) }
+		}
+		f.invoke(call, member, arguments, receiver)
+		return
+	case ast.SafeSelection:
+		f.g.error(target, "Safe selections are not supported")
+		return
+	}
+	f.g.error(call, "Lambda values are not supported")
+}
+
+// invoke calls a type member. The receiver, if given, generates the value of this.
+func (f *function) invoke(call ast.Element, member types.TypeMember, arguments []ast.Element, receiver func()) {
+	definition, ok := f.g.evaluator.Definition(member).(ast.Definition)
+	if !ok {
+		f.g.error(call, "%s cannot be called", member.Name())
+		return
+	}
+	switch lambda := definition.Value().(type) {
+	case ast.IntrinsicLambda:
+		intrinsic, ok := f.g.intrinsicOf(lambda)
+		if !ok {
+			f.g.error(call, "%s is not a valid intrinsic", member.Name())
+			return
+		}
+		if receiver != nil {
+			receiver()
+		}
+		f.arguments(call, lambda.Parameters(), arguments)
+		f.code.Bytes(intrinsic.Code)
+	case ast.Lambda:
+		info, ok := f.g.functions[member]
+		if !ok {
+			if len(lambda.TypeParameters()) == 0 {
+				f.g.error(call, "%s cannot be called", member.Name())
+			}
+			return
+		}
+		if info.method {
+			if receiver == nil {
+				f.g.error(call, "%s must be called on a value of type %s", member.Name(), info.owner)
+				return
+			}
+			receiver()
+		}
+		f.arguments(call, lambda.Parameters(), arguments)
+		index := info.index
+		f.indexed("call", index)
+	default:
+		f.g.error(call, "%s cannot be called", member.Name())
+	}
+}
+
+// arguments generates the arguments of a call in the order of the parameters using the
+// default values of parameters that are not given
+func (f *function) arguments(call ast.Element, parameters []ast.Parameter, arguments []ast.Element) {
+	var names []string
+	for _, parameter := range parameters {
+		names = append(names, parameter.Name().Text())
+	}
+	for i, argument := range order(names, arguments) {
+		switch {
+		case argument != nil:
+			f.expression(argument)
+		case parameters[i].Default() != nil:
+			f.expression(parameters[i].Default())
+		default:
+			f.g.error(call, "No value given for parameter %s", names[i])
+		}
+	}
+}
+
+// order matches the arguments of a call to the parameters with the given names
+func order(names []string, arguments []ast.Element) []ast.Element {
+	result := make([]ast.Element, len(names))
+	next := 0
+	for _, argument := range arguments {
+		if named, ok := argument.(ast.NamedArgument); ok {
+			for i, name := range names {
+				if name == named.Name().Text() {
+					result[i] = named.Value()
+					break
+				}
+			}
+			continue
+		}
+		for next < len(result) && result[next] != nil {
+			next++
+		}
+		if next < len(result) {
+			result[next] = argument
+		}
+	}
+	return result
+}
+
+// array generates the get and set members of arrays. The elements are not bounds checked.
+func (f *function) array(call ast.Call, array ast.Element, arrayType types.TypeSymbol, name string) {
+	layout := f.g.arrayLayoutOf(call, arrayType)
+	names := []string{"index"}
+	if name == "set" {
+		names = append(names, "value")
+	}
+	arguments := order(names, call.Arguments())
+	f.expression(array)
+	f.expression(arguments[0])
+	f.g.constant(&f.code, call, wasm.I32, int(layout.elements.size))
+	f.op(call, "i32.mul")
+	f.op(call, "i32.add")
+	if name == "get" {
+		f.loadAt(layout.elements, layout.header)
+		return
+	}
+	value := f.local(layout.elements.format)
+	f.expression(arguments[1])
+	f.indexed("local.tee", value)
+	f.storeAt(layout.elements, layout.header)
+	f.indexed("local.get", value)
+}
+
+// allocate allocates size bytes storing the address in a new local
+func (f *function) allocate(element ast.Element, size uint32) uint32 {
+	f.g.allocUsed = true
+	pointer := f.local(wasm.I32)
+	f.g.constant(&f.code, element, wasm.I32, int(size))
+	f.indexed("call", f.g.allocIndex)
+	f.indexed("local.set", pointer)
+	return pointer
+}
+
+// objectInitializer allocates a record and initializes its fields. Fields not given a value
+// are initialized with the value declared by the type which can refer to the fields already
+// initialized.
+func (f *function) objectInitializer(initializer ast.ObjectInitializer) {
+	typeSym := f.g.context.Types[initializer]
+	layout := f.g.recordLayoutOf(initializer, typeSym)
+	pointer := f.allocate(initializer, layout.size)
+	initialized := make(map[string]bool)
+	for _, member := range initializer.Members() {
+		switch m := member.(type) {
+		case ast.NamedMemberInitializer:
+			fld, ok := layout.fields[m.Name().Text()]
+			if !ok {
+				continue
+			}
+			initialized[m.Name().Text()] = true
+			f.indexed("local.get", pointer)
+			f.expression(m.Value())
+			f.storeAt(fld.representation, fld.offset)
+		case ast.Spread:
+			sourceType := f.g.context.Types[m.Target()]
+			source := f.local(wasm.I32)
+			f.expression(m.Target())
+			f.indexed("local.set", source)
+			for _, sourceMember := range sourceType.Type().Members() {
+				fld, ok := layout.fields[sourceMember.Name()]
+				if !ok {
+					continue
+				}
+				initialized[sourceMember.Name()] = true
+				sourceField := f.g.recordLayoutOf(m, sourceType).fields[sourceMember.Name()]
+				f.indexed("local.get", pointer)
+				f.indexed("local.get", source)
+				f.loadAt(sourceField.representation, sourceField.offset)
+				f.storeAt(fld.representation, fld.offset)
+			}
+		}
+	}
+	this, thisType := f.this, f.thisType
+	f.this, f.thisType = int(pointer), typeSym
+	for _, member := range typeSym.Type().Members() {
+		if initialized[member.Name()] {
+			continue
+		}
+		storage, ok := f.g.evaluator.Definition(member).(ast.Storage)
+		if !ok || storage.Value() == nil {
+			continue
+		}
+		fld := layout.fields[member.Name()]
+		f.indexed("local.get", pointer)
+		f.expression(storage.Value())
+		f.storeAt(fld.representation, fld.offset)
+	}
+	f.this, f.thisType = this, thisType
+	f.indexed("local.get", pointer)
+}
+
+// arrayInitializer allocates an array and initializes its size and elements
+func (f *function) arrayInitializer(initializer ast.ArrayInitializer) {
+	layout := f.g.arrayLayoutOf(initializer, f.g.context.Types[initializer])
+	elements := initializer.Elements()
+	pointer := f.allocate(initializer, layout.header+uint32(len(elements))*layout.elements.size)
+	f.indexed("local.get", pointer)
+	f.g.constant(&f.code, initializer, wasm.I32, len(elements))
+	f.storeAt(f.g.pointer(), 0)
+	for i, element := range elements {
+		f.indexed("local.get", pointer)
+		f.expression(element)
+		f.storeAt(layout.elements, layout.header+uint32(i)*layout.elements.size)
+	}
+	f.indexed("local.get", pointer)
+}
+
+// when generates a when as nested ifs. A when with a target compares the target to the value
+// of each clause using the == member of the type of the target.
+func (f *function) when(when ast.When) {
+	var equals types.TypeMember
+	var target uint32
+	if when.Target() != nil {
+		targetType := f.g.context.Types[when.Target()]
+		sym, ok := targetType.Type().TypeScope().Find("==")
+		equals, _ = sym.(types.TypeMember)
+		if !ok || equals == nil {
+			f.g.error(when.Target(), "%s does not have a member ==", targetType)
+			return
+		}
+		target = f.local(f.representationOf(when.Target()).format)
+		f.expression(when.Target())
+		f.indexed("local.set", target)
+	}
+	blockType := f.blockType(when)
+	clauses := when.Clauses()
+	var clause func(i int)
+	clause = func(i int) {
+		if i >= len(clauses) {
+			return
+		}
+		switch c := clauses[i].(type) {
+		case ast.WhenElseClause:
+			f.clauseBody(c.Body(), blockType)
+		case ast.WhenValueClause:
+			if equals != nil {
+				f.invoke(c, equals, []ast.Element{c.Value()}, func() { f.indexed("local.get", target) })
+			} else {
+				f.expression(c.Value())
+			}
+			f.open("if", blockType)
+			f.clauseBody(c.Body(), blockType)
+			if i+1 < len(clauses) || blockType != emptyBlock {
+				f.op(nil, "else")
+				clause(i + 1)
+			}
+			f.close()
+		}
+	}
+	clause(0)
+}
+
+func (f *function) clauseBody(body ast.Element, blockType byte) {
+	if body != nil {
+		f.expression(body)
+	}
+	switch {
+	case blockType != emptyBlock && (body == nil || !f.hasValue(body)):
+		f.op(body, "unreachable")
+	case blockType == emptyBlock && body != nil && f.hasValue(body):
+		f.op(body, "drop")
+	}
+}
+
+// loop generates a loop as a loop in a block. The loop branches back to its start at the end
+// of the body.
+func (f *function) loop(loop ast.Loop) {
+	label := ""
+	if loop.Label() != nil {
+		label = loop.Label().Text()
+	}
+	f.open("block", emptyBlock)
+	f.loops = append(f.loops, loopLabel{label: label, depth: f.depth})
+	f.open("loop", emptyBlock)
+	if loop.Body() != nil {
+		f.statement(loop.Body())
+	}
+	f.indexed("br", 0)
+	f.close()
+	f.loops = f.loops[:len(f.loops)-1]
+	f.close()
+}
+
+// loopDepth finds the depth of the block of the loop a break or continue refers to
+func (f *function) loopDepth(element ast.Element, label ast.Name) (int, bool) {
+	for i := len(f.loops) - 1; i >= 0; i-- {
+		if label == nil || f.loops[i].label == label.Text() {
+			return f.loops[i].depth, true
+		}
+	}
+	f.g.error(element, "Expected a loop")
+	return 0, false
+}
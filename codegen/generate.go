@@ -0,0 +1,487 @@
+package codegen
+
+import (
+	"sort"
+
+	"dyego0/ast"
+	"dyego0/binder"
+	"dyego0/errors"
+	"dyego0/intrinsics"
+	"dyego0/symbols"
+	"dyego0/types"
+	"dyego0/wasm"
+)
+
+// emptyBlock is the block type of a block that does not produce a value
+const emptyBlock byte = 0x40
+
+// heapStart is the address of the first record or array allocated
+const heapStart = 16
+
+// pageShift is the log2 of the size of a page of memory
+const pageShift = 16
+
+// functionInfo describes a function generated for a lambda type member
+type functionInfo struct {
+	index  uint32
+	name   string
+	member types.TypeMember
+	lambda ast.Lambda
+
+	// owner is the type that declares the lambda. Lambdas declared by a type other than the
+	// module are methods that receive this as their first parameter.
+	owner  types.TypeSymbol
+	method bool
+
+	signature types.Signature
+	result    *representation
+}
+
+// generator generates a WebAssembly module from a module bound and checked by the binder
+type generator struct {
+	evaluator *intrinsics.Evaluator
+	builtins  *Builtins
+	context   *binder.BindingContext
+	module    types.TypeSymbol
+	wasm      *wasm.Module
+	errors    []errors.Error
+	reported  map[string]bool
+
+	representations map[types.TypeSymbol]*representation
+	records         map[types.TypeSymbol]*recordLayout
+
+	// owners are the types that declare each type member
+	owners map[symbols.Symbol]types.TypeSymbol
+
+	functions map[symbols.Symbol]*functionInfo
+	globals   map[symbols.Symbol]uint32
+
+	// declarations are the symbols declared by each element of the module
+	declarations map[ast.Element]symbols.Symbol
+
+	allocIndex uint32
+	allocUsed  bool
+}
+
+// Generate generates a WebAssembly module for module using the representations and
+// intrinsics declared by builtins. The module must have been bound and checked in a context
+// created with the scope of the builtins. Module level lambdas are exported by name and the
+// statements of the module, if any, are exported as main.
+func Generate(
+	builtins *Builtins,
+	context *binder.BindingContext,
+	module types.TypeSymbol,
+	element ast.Element,
+) (*wasm.Module, []errors.Error) {
+	g := &generator{
+		evaluator: &intrinsics.Evaluator{
+			Definitions: []map[symbols.Symbol]ast.Element{context.Definitions, builtins.context.Definitions},
+			Scope:       symbols.Merge(module.Type().TypeScope(), builtins.evaluator.Scope),
+		},
+		builtins:        builtins,
+		context:         context,
+		module:          module,
+		wasm:            &wasm.Module{},
+		reported:        make(map[string]bool),
+		representations: make(map[types.TypeSymbol]*representation),
+		records:         make(map[types.TypeSymbol]*recordLayout),
+		owners:          make(map[symbols.Symbol]types.TypeSymbol),
+		functions:       make(map[symbols.Symbol]*functionInfo),
+		globals:         make(map[symbols.Symbol]uint32),
+		declarations:    make(map[ast.Element]symbols.Symbol),
+	}
+	for sym, element := range context.Definitions {
+		g.declarations[element] = sym
+	}
+	g.generate(element)
+	return g.wasm, g.errors
+}
+
+func (g *generator) error(element ast.Element, message string, args ...interface{}) {
+	if element == nil {
+		g.errors = append(g.errors, errors.NewAt(-1, -1, message, args...))
+		return
+	}
+	g.errors = append(g.errors, errors.New(element, message, args...))
+}
+
+// op is the opcode of the instruction with the given name, such as i32.add, declared by the
+// builtins
+func (g *generator) op(element ast.Element, name string) byte {
+	opcode, ok := g.builtins.opcode(name)
+	if !ok && !g.reported[name] {
+		g.reported[name] = true
+		g.error(element, "The builtins do not declare the instruction inst.%s", name)
+	}
+	return opcode
+}
+
+// intrinsicOf finds the instructions the binder resolved for an intrinsic lambda declared by
+// the module or the builtins
+func (g *generator) intrinsicOf(lambda ast.IntrinsicLambda) (*intrinsics.Intrinsic, bool) {
+	if intrinsic, ok := g.context.Intrinsics[lambda]; ok {
+		return intrinsic, true
+	}
+	intrinsic, ok := g.builtins.context.Intrinsics[lambda]
+	return intrinsic, ok
+}
+
+func (g *generator) generate(element ast.Element) {
+	var statements []ast.Element
+	forEach(element, func(statement ast.Element) {
+		statements = append(statements, statement)
+	})
+
+	g.wasm.Memories = append(g.wasm.Memories, wasm.Memory{Min: 1})
+	g.wasm.Globals = append(g.wasm.Globals, g.constantGlobal(wasm.I32, heapStart))
+	for _, member := range g.module.Type().Members() {
+		r := g.representationOf(g.evaluator.Definition(member), member.Type())
+		g.globals[member] = uint32(len(g.wasm.Globals))
+		g.wasm.Globals = append(g.wasm.Globals, g.constantGlobal(r.format, 0))
+	}
+
+	functions := g.collect()
+	hasMain := false
+	for _, statement := range statements {
+		if g.isStatement(statement) {
+			hasMain = true
+			break
+		}
+	}
+	g.allocIndex = uint32(len(functions))
+	if hasMain {
+		g.allocIndex++
+	}
+
+	for _, info := range functions {
+		g.function(info)
+		if !info.method {
+			g.export(info.name, info.lambda, info.index)
+		}
+	}
+	if hasMain {
+		g.main(statements)
+	}
+	if g.allocUsed {
+		g.alloc()
+	}
+	g.wasm.Exports = append(g.wasm.Exports, wasm.Export{Name: "memory", Kind: wasm.MemoryExport})
+}
+
+func (g *generator) export(name string, element ast.Element, index uint32) {
+	if _, ok := g.wasm.Export(name); ok {
+		g.error(element, "Duplicate export %s", name)
+		return
+	}
+	g.wasm.Exports = append(g.wasm.Exports, wasm.Export{Name: name, Kind: wasm.FunctionExport, Index: index})
+}
+
+// constantGlobal is a mutable global initialized to value
+func (g *generator) constantGlobal(format wasm.ValueType, value int) wasm.Global {
+	e := &wasm.Encoder{}
+	g.constant(e, nil, format, value)
+	e.Byte(g.op(nil, "end"))
+	return wasm.Global{Type: format, Mutable: true, Init: e.Result()}
+}
+
+// collect finds the lambdas declared by the module and the types it declares. The
+// functions are ordered by their position in the source.
+func (g *generator) collect() []*functionInfo {
+	var result []*functionInfo
+	var visit func(typeSym types.TypeSymbol)
+	visit = func(typeSym types.TypeSymbol) {
+		typeSym.Type().TypeScope().ForEach(func(sym symbols.Symbol) bool {
+			g.owners[sym] = typeSym
+			switch s := sym.(type) {
+			case types.TypeSymbol:
+				if s.Type() != nil && s.Type().Symbol() == s && g.context.Definitions[s] != nil {
+					visit(s)
+				}
+			case types.TypeMember:
+				definition, ok := g.context.Definitions[s].(ast.Definition)
+				if !ok {
+					break
+				}
+				lambda, ok := definition.Value().(ast.Lambda)
+				if !ok {
+					break
+				}
+				if len(lambda.TypeParameters()) > 0 {
+					g.error(lambda, "Generic lambdas are not supported")
+					break
+				}
+				result = append(result, &functionInfo{
+					name:   s.Name(),
+					member: s,
+					lambda: lambda,
+					owner:  typeSym,
+					method: typeSym != g.module,
+				})
+			}
+			return false
+		})
+	}
+	visit(g.module)
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].lambda.Start() < result[j].lambda.Start()
+	})
+	for i, info := range result {
+		info.index = uint32(i)
+		info.signature = signatureOf(info.member.Type())
+		if info.signature != nil && info.signature.Result() != nil {
+			info.result = g.representationOf(info.lambda, info.signature.Result())
+		}
+		g.functions[info.member] = info
+	}
+	return result
+}
+
+// signatureOf is the signature of a lambda type
+func signatureOf(typeSym types.TypeSymbol) types.Signature {
+	if typeSym == nil || typeSym.Type() == nil || len(typeSym.Type().Signatures()) != 1 {
+		return nil
+	}
+	return typeSym.Type().Signatures()[0]
+}
+
+// isStatement is true if the module level statement requires code in main
+func (g *generator) isStatement(statement ast.Element) bool {
+	switch n := statement.(type) {
+	case ast.Definition, ast.Spread:
+		return false
+	case ast.Storage:
+		return n.Value() != nil
+	}
+	return true
+}
+
+// funcType is the WebAssembly type of a function
+func (g *generator) funcType(info *functionInfo) wasm.FuncType {
+	var result wasm.FuncType
+	if info.method {
+		result.Params = append(result.Params, wasm.I32)
+	}
+	if info.signature != nil {
+		for i, parameter := range info.signature.Parameters() {
+			r := g.representationOf(info.lambda.Parameters()[i], parameter.Type())
+			result.Params = append(result.Params, r.format)
+		}
+	}
+	if info.result != nil {
+		result.Results = append(result.Results, info.result.format)
+	}
+	return result
+}
+
+// function generates the function for a lambda
+func (g *generator) function(info *functionInfo) {
+	t := g.funcType(info)
+	f := newFunction(g, len(t.Params), info.result)
+	if info.method {
+		f.this = 0
+		f.thisType = info.owner
+	}
+	offset := 0
+	if info.method {
+		offset = 1
+	}
+	for i, parameter := range info.lambda.Parameters() {
+		f.indexes[parameter] = uint32(offset + i)
+	}
+	f.body(info.lambda.Body())
+	g.wasm.Functions = append(g.wasm.Functions, f.finish(g.wasm.AddType(t)))
+}
+
+// main generates the function that executes the statements of the module
+func (g *generator) main(statements []ast.Element) {
+	var result *representation
+	for _, statement := range statements {
+		if typeSym := g.returned(statement); typeSym != nil {
+			result = g.representationOf(statement, typeSym)
+			break
+		}
+	}
+	var t wasm.FuncType
+	if result != nil {
+		t.Results = append(t.Results, result.format)
+	}
+	f := newFunction(g, 0, result)
+	for _, statement := range statements {
+		if global, ok := g.moduleField(statement); ok {
+			f.expression(global.Value())
+			f.op(global, "global.set")
+			f.code.U32(g.globals[g.declared(global)])
+			continue
+		}
+		if !g.isStatement(statement) {
+			continue
+		}
+		f.statement(statement)
+	}
+	if result != nil {
+		g.constant(&f.code, nil, result.format, 0)
+	}
+	index := uint32(len(g.wasm.Functions))
+	g.wasm.Functions = append(g.wasm.Functions, f.finish(g.wasm.AddType(t)))
+	g.export("main", nil, index)
+}
+
+// moduleField returns the statement if it declares a field of the module with a value
+func (g *generator) moduleField(statement ast.Element) (ast.Storage, bool) {
+	storage, ok := statement.(ast.Storage)
+	if !ok || storage.Value() == nil {
+		return nil, false
+	}
+	_, ok = g.globals[g.declared(storage)]
+	return storage, ok
+}
+
+// declared is the symbol declared by element
+func (g *generator) declared(element ast.Element) symbols.Symbol {
+	return g.declarations[element]
+}
+
+// returned is the type of the value returned by a return statement at the top-level of the
+// module, if any
+func (g *generator) returned(element ast.Element) types.TypeSymbol {
+	switch n := element.(type) {
+	case ast.Return:
+		if n.Value() != nil {
+			return g.context.Types[n.Value()]
+		}
+	case ast.Sequence:
+		if result := g.returned(n.Left()); result != nil {
+			return result
+		}
+		return g.returned(n.Right())
+	case ast.Loop:
+		return g.returned(n.Body())
+	case ast.When:
+		for _, clause := range n.Clauses() {
+			switch c := clause.(type) {
+			case ast.WhenValueClause:
+				if result := g.returned(c.Body()); result != nil {
+					return result
+				}
+			case ast.WhenElseClause:
+				if result := g.returned(c.Body()); result != nil {
+					return result
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// alloc generates the function that allocates memory for records and arrays. Memory is
+// allocated from the heap, in 8 byte aligned blocks, growing the memory as needed. Memory is
+// never freed.
+func (g *generator) alloc() {
+	t := wasm.FuncType{Params: []wasm.ValueType{wasm.I32}, Results: []wasm.ValueType{wasm.I32}}
+	f := newFunction(g, 1, &representation{format: wasm.I32})
+	result := f.local(wasm.I32)
+	e := &f.code
+	get := func(name string, index uint32) {
+		f.op(nil, name)
+		e.U32(index)
+	}
+	i32 := func(value int32) {
+		f.op(nil, "i32.const")
+		e.I32(value)
+	}
+	memorySize := func() {
+		f.op(nil, "memory.size")
+		e.Byte(0)
+		i32(pageShift)
+		f.op(nil, "i32.shl")
+	}
+
+	// result = heap; heap = (heap + size + 7) & ~7
+	get("global.get", 0)
+	get("local.tee", result)
+	get("local.get", 0)
+	f.op(nil, "i32.add")
+	i32(7)
+	f.op(nil, "i32.add")
+	i32(-8)
+	f.op(nil, "i32.and")
+	get("global.set", 0)
+
+	// if heap > memory size { grow by the pages required or trap }
+	get("global.get", 0)
+	memorySize()
+	f.op(nil, "i32.gt_u")
+	f.op(nil, "if")
+	e.Byte(emptyBlock)
+	get("global.get", 0)
+	memorySize()
+	f.op(nil, "i32.sub")
+	i32(1<<pageShift - 1)
+	f.op(nil, "i32.add")
+	i32(pageShift)
+	f.op(nil, "i32.shr_u")
+	f.op(nil, "memory.grow")
+	e.Byte(0)
+	i32(-1)
+	f.op(nil, "i32.eq")
+	f.op(nil, "if")
+	e.Byte(emptyBlock)
+	f.op(nil, "unreachable")
+	f.op(nil, "end")
+	f.op(nil, "end")
+	get("local.get", result)
+	g.wasm.Functions = append(g.wasm.Functions, f.finish(g.wasm.AddType(t)))
+}
+
+// constant emits a constant instruction for a literal value
+func (g *generator) constant(e *wasm.Encoder, element ast.Element, format wasm.ValueType, value interface{}) {
+	e.Byte(g.op(element, format.String()+".const"))
+	switch format {
+	case wasm.I32:
+		e.I32(int32(toInt64(value)))
+	case wasm.I64:
+		e.I64(toInt64(value))
+	case wasm.F32:
+		e.F32(float32(toFloat64(value)))
+	case wasm.F64:
+		e.F64(toFloat64(value))
+	}
+}
+
+func toInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return 1
+		}
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case uint:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case byte:
+		return int64(v)
+	case float32:
+		return int64(v)
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+func toFloat64(value interface{}) float64 {
+	switch v := value.(type) {
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return float64(toInt64(value))
+}
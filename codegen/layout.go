@@ -0,0 +1,169 @@
+package codegen
+
+import (
+	"dyego0/ast"
+	"dyego0/types"
+	"dyego0/wasm"
+)
+
+// representation is how the values of a type are represented in locals and in memory
+type representation struct {
+	format wasm.ValueType
+	size   uint32
+
+	// align is the alignment of the value in memory as a power of two
+	align uint32
+
+	load  byte
+	store byte
+}
+
+// field is a field of a record at an offset from the start of the record
+type field struct {
+	offset         uint32
+	representation *representation
+}
+
+// recordLayout is the layout of a record in memory
+type recordLayout struct {
+	size   uint32
+	fields map[string]*field
+}
+
+// arrayLayout is the layout of an array in memory. The size of the array is stored as an i32
+// at the start of the array followed by the elements starting at header.
+type arrayLayout struct {
+	header   uint32
+	elements *representation
+}
+
+func alignTo(offset, align uint32) uint32 {
+	size := uint32(1) << align
+	return (offset + size - 1) &^ (size - 1)
+}
+
+// isLambda is true if values of the type are lambdas
+func isLambda(typeSym types.TypeSymbol) bool {
+	t := typeSym.Type()
+	return t != nil && len(t.Signatures()) > 0
+}
+
+// pointer is the representation of the records and arrays stored in memory
+func (g *generator) pointer() *representation {
+	return &representation{
+		format: wasm.I32,
+		size:   4,
+		align:  2,
+		load:   g.op(nil, "i32.load"),
+		store:  g.op(nil, "i32.store"),
+	}
+}
+
+// representationOf finds the representation of the values of typeSym. Reports an error at
+// element if the type cannot be represented.
+func (g *generator) representationOf(element ast.Element, typeSym types.TypeSymbol) *representation {
+	t := typeSym.Type()
+	if t == nil || types.IsError(typeSym) {
+		g.error(element, "Type of expression is unknown")
+		return g.pointer()
+	}
+	canonical := t.Symbol()
+	if r, ok := g.representations[canonical]; ok {
+		return r
+	}
+	var r *representation
+	if _, ok := g.evaluator.Property(canonical, "@fmt"); ok {
+		r = g.primitive(element, canonical)
+	} else if isLambda(canonical) {
+		g.error(element, "Lambdas can only be called")
+		r = g.pointer()
+	} else {
+		r = g.pointer()
+	}
+	g.representations[canonical] = r
+	return r
+}
+
+// primitive reads the representation of a primitive type from its type members
+func (g *generator) primitive(element ast.Element, typeSym types.TypeSymbol) *representation {
+	r := &representation{}
+	format, _ := g.evaluator.Property(typeSym, "@fmt")
+	if b, ok := format.(byte); ok && validFormat(wasm.ValueType(b)) {
+		r.format = wasm.ValueType(b)
+	} else {
+		g.error(element, "%s has an invalid `@fmt`", typeSym)
+		r.format = wasm.I32
+	}
+	size, ok := g.evaluator.Property(typeSym, "@size")
+	switch s := size.(type) {
+	case int:
+		r.size = uint32(s)
+	default:
+		if ok {
+			g.error(element, "%s has an invalid `@size`", typeSym)
+		}
+		r.size = 4
+	}
+	for r.align < 3 && uint32(1)<<(r.align+1) <= r.size {
+		r.align++
+	}
+	r.load = g.instructionProperty(element, typeSym, "@load.global")
+	r.store = g.instructionProperty(element, typeSym, "@store.global")
+	return r
+}
+
+func validFormat(format wasm.ValueType) bool {
+	switch format {
+	case wasm.I32, wasm.I64, wasm.F32, wasm.F64:
+		return true
+	}
+	return false
+}
+
+func (g *generator) instructionProperty(element ast.Element, typeSym types.TypeSymbol, name string) byte {
+	value, _ := g.evaluator.Property(typeSym, name)
+	b, ok := value.(byte)
+	if !ok {
+		g.error(element, "%s does not declare `%s`", typeSym, name)
+	}
+	return b
+}
+
+// recordLayoutOf lays out the fields of a record in the order they are declared
+func (g *generator) recordLayoutOf(element ast.Element, typeSym types.TypeSymbol) *recordLayout {
+	t := typeSym.Type()
+	if t == nil {
+		return &recordLayout{fields: make(map[string]*field)}
+	}
+	if t.Kind() == types.Reference {
+		return g.recordLayoutOf(element, t.Referant())
+	}
+	canonical := t.Symbol()
+	if layout, ok := g.records[canonical]; ok {
+		return layout
+	}
+	layout := &recordLayout{fields: make(map[string]*field)}
+	var align uint32
+	for _, member := range t.Members() {
+		r := g.representationOf(element, member.Type())
+		layout.size = alignTo(layout.size, r.align)
+		layout.fields[member.Name()] = &field{offset: layout.size, representation: r}
+		layout.size += r.size
+		if r.align > align {
+			align = r.align
+		}
+	}
+	layout.size = alignTo(layout.size, align)
+	g.records[canonical] = layout
+	return layout
+}
+
+// arrayLayoutOf is the layout of an array type
+func (g *generator) arrayLayoutOf(element ast.Element, typeSym types.TypeSymbol) *arrayLayout {
+	t := typeSym.Type()
+	if t.Kind() == types.Reference {
+		t = t.Referant().Type()
+	}
+	elements := g.representationOf(element, t.Elements())
+	return &arrayLayout{header: alignTo(4, elements.align), elements: elements}
+}
@@ -0,0 +1,69 @@
+package cst
+
+import (
+	"dyego0/location"
+	"dyego0/tokens"
+)
+
+// Builder builds a green tree from the tokens of a text in source order. Nodes are finished by
+// grouping the children added since a Mark, which allows a node to be finished after its first
+// child, as the parser does for an operator that follows its left operand. A copy of a Builder
+// preserves its state so it can be restored to backtrack.
+type Builder struct {
+	children *pending
+}
+
+// pending is an immutable stack of the children not yet grouped in a node. A stack is never
+// modified so a copy of a Builder is not affected by the children added to the Builder.
+type pending struct {
+	green *Green
+	next  *pending
+}
+
+// Mark is the state of a Builder from which a node is finished
+type Mark *pending
+
+// NewBuilder makes a Builder for an empty tree
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Token adds a token spelled text preceded by the white space and comments in trivia
+func (b *Builder) Token(token tokens.Token, trivia, text string) {
+	b.children = &pending{green: NewToken(token, trivia, text), next: b.children}
+}
+
+// Mark returns the current state of the builder
+func (b *Builder) Mark() Mark {
+	return b.children
+}
+
+// Finish groups the children added since mark in a node of kind
+func (b *Builder) Finish(mark Mark, kind Kind) {
+	b.children = &pending{green: NewNode(kind, b.since(mark)), next: mark}
+}
+
+// FinishError groups the children added since mark in an Error node with message
+func (b *Builder) FinishError(mark Mark, message string) {
+	b.children = &pending{green: NewError(message, b.since(mark)), next: mark}
+}
+
+// Tree groups all the children in a node of kind and returns it as the root of a tree where the
+// text starts at pos
+func (b *Builder) Tree(kind Kind, pos location.Pos) *Node {
+	b.Finish(nil, kind)
+	return NewTree(b.children.green, pos)
+}
+
+func (b *Builder) since(mark Mark) []*Green {
+	count := 0
+	for p := b.children; p != mark; p = p.next {
+		count++
+	}
+	result := make([]*Green, count)
+	for p := b.children; p != mark; p = p.next {
+		count--
+		result[count] = p.green
+	}
+	return result
+}
@@ -0,0 +1,340 @@
+package cst
+
+import (
+	"strings"
+
+	"dyego0/location"
+	"dyego0/tokens"
+)
+
+// Kind is the kind of syntax a node of a concrete syntax tree records
+type Kind int
+
+// The kinds of syntax. Each kind other than Token is a node with the tokens and nodes of the
+// syntax as its children in source order.
+const (
+	Token Kind = iota
+	File
+	Sequence
+	Error
+	Name
+	Literal
+	Parenthesized
+	Selection
+	SafeSelection
+	Call
+	Instantiation
+	Index
+	IndexAssignment
+	Prefix
+	Postfix
+	Binary
+	TypeOperator
+	NamedArgument
+	If
+	Then
+	Else
+	When
+	WhenValueClause
+	WhenElseClause
+	Lambda
+	IntrinsicLambda
+	Parameter
+	TypeParameter
+	Spread
+	Definition
+	Storage
+	ObjectInitializer
+	ArrayInitializer
+	NamedMemberInitializer
+	TypeLiteral
+	CallableTypeMember
+	SequenceType
+	OptionalType
+	ReferenceType
+	VocabularyLiteral
+	VocabularyOperatorDeclaration
+	VocabularyOperatorPrecedence
+	VocabularyEmbedding
+	Loop
+	While
+	Break
+	Continue
+	Return
+	lastKind
+)
+
+var kinds = [...]string{
+	Token:                         "Token",
+	File:                          "File",
+	Sequence:                      "Sequence",
+	Error:                         "Error",
+	Name:                          "Name",
+	Literal:                       "Literal",
+	Parenthesized:                 "Parenthesized",
+	Selection:                     "Selection",
+	SafeSelection:                 "SafeSelection",
+	Call:                          "Call",
+	Instantiation:                 "Instantiation",
+	Index:                         "Index",
+	IndexAssignment:               "IndexAssignment",
+	Prefix:                        "Prefix",
+	Postfix:                       "Postfix",
+	Binary:                        "Binary",
+	TypeOperator:                  "TypeOperator",
+	NamedArgument:                 "NamedArgument",
+	If:                            "If",
+	Then:                          "Then",
+	Else:                          "Else",
+	When:                          "When",
+	WhenValueClause:               "WhenValueClause",
+	WhenElseClause:                "WhenElseClause",
+	Lambda:                        "Lambda",
+	IntrinsicLambda:               "IntrinsicLambda",
+	Parameter:                     "Parameter",
+	TypeParameter:                 "TypeParameter",
+	Spread:                        "Spread",
+	Definition:                    "Definition",
+	Storage:                       "Storage",
+	ObjectInitializer:             "ObjectInitializer",
+	ArrayInitializer:              "ArrayInitializer",
+	NamedMemberInitializer:        "NamedMemberInitializer",
+	TypeLiteral:                   "TypeLiteral",
+	CallableTypeMember:            "CallableTypeMember",
+	SequenceType:                  "SequenceType",
+	OptionalType:                  "OptionalType",
+	ReferenceType:                 "ReferenceType",
+	VocabularyLiteral:             "VocabularyLiteral",
+	VocabularyOperatorDeclaration: "VocabularyOperatorDeclaration",
+	VocabularyOperatorPrecedence:  "VocabularyOperatorPrecedence",
+	VocabularyEmbedding:           "VocabularyEmbedding",
+	Loop:                          "Loop",
+	While:                         "While",
+	Break:                         "Break",
+	Continue:                      "Continue",
+	Return:                        "Return",
+}
+
+func (k Kind) String() string {
+	if k >= 0 && k < lastKind {
+		return kinds[k]
+	}
+	return "<invalid>"
+}
+
+// Green is an immutable node of a concrete syntax tree. A green node only records its width, not
+// its position, so it can be shared by the trees of different versions of a text.
+type Green struct {
+	kind     Kind
+	token    tokens.Token
+	trivia   string
+	text     string
+	message  string
+	width    int
+	children []*Green
+}
+
+// NewToken makes a green token node for token, spelled text, preceded by the white space and
+// comments in trivia
+func NewToken(token tokens.Token, trivia, text string) *Green {
+	return &Green{kind: Token, token: token, trivia: trivia, text: text, width: len(trivia) + len(text)}
+}
+
+// NewNode makes a green node of kind with the children given
+func NewNode(kind Kind, children []*Green) *Green {
+	width := 0
+	for _, child := range children {
+		width += child.width
+	}
+	return &Green{kind: kind, children: children, width: width}
+}
+
+// NewError makes a green Error node with the message of the error and the tokens skipped by it
+func NewError(message string, children []*Green) *Green {
+	result := NewNode(Error, children)
+	result.message = message
+	return result
+}
+
+// Kind is the kind of the node
+func (g *Green) Kind() Kind {
+	return g.kind
+}
+
+// Token is the token of a Token node
+func (g *Green) Token() tokens.Token {
+	return g.token
+}
+
+// Trivia is the white space and comments before the token of a Token node
+func (g *Green) Trivia() string {
+	return g.trivia
+}
+
+// Message is the message of an Error node
+func (g *Green) Message() string {
+	return g.message
+}
+
+// Width is the length of the text of the node including trivia
+func (g *Green) Width() int {
+	return g.width
+}
+
+// Children are the children of the node
+func (g *Green) Children() []*Green {
+	return g.children
+}
+
+// Text is the source text of the node including trivia
+func (g *Green) Text() string {
+	if g.kind == Token {
+		return g.trivia + g.text
+	}
+	var result strings.Builder
+	g.write(&result)
+	return result.String()
+}
+
+func (g *Green) write(result *strings.Builder) {
+	if g.kind == Token {
+		result.WriteString(g.trivia)
+		result.WriteString(g.text)
+		return
+	}
+	for _, child := range g.children {
+		child.write(result)
+	}
+}
+
+// Node is a green node at a position in a concrete syntax tree. A node knows its parent so the
+// tree can be navigated in any direction.
+type Node struct {
+	green  *Green
+	parent *Node
+	index  int
+	pos    location.Pos
+}
+
+// NewTree makes the root node of a tree for green where the text of green starts at pos
+func NewTree(green *Green, pos location.Pos) *Node {
+	return &Node{green: green, pos: pos}
+}
+
+// Green is the green node of the node
+func (n *Node) Green() *Green {
+	return n.green
+}
+
+// Kind is the kind of the node
+func (n *Node) Kind() Kind {
+	return n.green.kind
+}
+
+// Token is the token of a Token node
+func (n *Node) Token() tokens.Token {
+	return n.green.token
+}
+
+// Message is the message of an Error node
+func (n *Node) Message() string {
+	return n.green.message
+}
+
+// Parent is the node that contains the node or nil for the root of the tree
+func (n *Node) Parent() *Node {
+	return n.parent
+}
+
+// Children are the child nodes of the node
+func (n *Node) Children() []*Node {
+	var result []*Node
+	pos := n.pos
+	for index, green := range n.green.children {
+		result = append(result, &Node{green: green, parent: n, index: index, pos: pos})
+		pos += location.Pos(green.width)
+	}
+	return result
+}
+
+// Index is the index of the node in the children of its parent
+func (n *Node) Index() int {
+	return n.index
+}
+
+// Pos is the position of the text of the node including its trivia
+func (n *Node) Pos() location.Pos {
+	return n.pos
+}
+
+// Start is the start of the first token of the node, excluding its trivia. A node without tokens,
+// such as an Error node for a missing token, is located at the token that follows it.
+func (n *Node) Start() location.Pos {
+	token := n.FirstToken()
+	if token == nil {
+		token = n.NextToken()
+		if token == nil {
+			return n.pos + location.Pos(n.green.width)
+		}
+	}
+	return token.pos + location.Pos(len(token.green.trivia))
+}
+
+// End is the end of the last token of the node
+func (n *Node) End() location.Pos {
+	if n.FirstToken() == nil {
+		if token := n.NextToken(); token != nil {
+			return token.pos + location.Pos(token.green.width)
+		}
+	}
+	return n.pos + location.Pos(n.green.width)
+}
+
+// Length is the length of the node from Start to End
+func (n *Node) Length() int {
+	return int(n.End() - n.Start())
+}
+
+// Text is the text of the token of a Token node, or the source text of any other node from Start
+// to End
+func (n *Node) Text() string {
+	if n.green.kind == Token {
+		return n.green.text
+	}
+	text := n.green.Text()
+	if n.FirstToken() == nil {
+		return ""
+	}
+	return text[n.Start()-n.pos:]
+}
+
+// FirstToken is the first Token node in the node, or nil if the node has no tokens. A Token node
+// without text, such as the end of the file, counts as a token.
+func (n *Node) FirstToken() *Node {
+	if n.green.kind == Token {
+		return n
+	}
+	for _, child := range n.Children() {
+		if token := child.FirstToken(); token != nil {
+			return token
+		}
+	}
+	return nil
+}
+
+// NextToken is the first Token node that follows the node in the tree, or nil if there is none
+func (n *Node) NextToken() *Node {
+	for node := n; node.parent != nil; node = node.parent {
+		siblings := node.parent.Children()
+		for _, sibling := range siblings[node.index+1:] {
+			if token := sibling.FirstToken(); token != nil {
+				return token
+			}
+		}
+	}
+	return nil
+}
+
+func (n *Node) String() string {
+	return n.green.kind.String()
+}
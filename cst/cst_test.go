@@ -0,0 +1,91 @@
+package cst_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"dyego0/cst"
+	"dyego0/location"
+	"dyego0/tokens"
+)
+
+var _ = Describe("cst", func() {
+	// build builds the tree of "a + b" as the parser records it, finishing the binary node after
+	// its left operand
+	build := func() *cst.Node {
+		b := cst.NewBuilder()
+		mark := b.Mark()
+		b.Token(tokens.Identifier, " ", "a")
+		b.Finish(mark, cst.Name)
+		b.Token(tokens.Symbol, " ", "+")
+		right := b.Mark()
+		b.Token(tokens.Identifier, " ", "b")
+		b.Finish(right, cst.Name)
+		b.Finish(mark, cst.Binary)
+		b.Token(tokens.EOF, " ", "")
+		return b.Tree(cst.File, 0)
+	}
+	It("records the text of the tokens", func() {
+		tree := build()
+		Expect(tree.Green().Text()).To(Equal(" a + b "))
+		Expect(tree.Green().Width()).To(Equal(7))
+	})
+	It("groups the children since a mark", func() {
+		tree := build()
+		children := tree.Children()
+		Expect(children).To(HaveLen(2))
+		Expect(children[0].Kind()).To(Equal(cst.Binary))
+		Expect(children[1].Token()).To(Equal(tokens.EOF))
+		binary := children[0].Children()
+		Expect(binary).To(HaveLen(3))
+		Expect(binary[0].Kind()).To(Equal(cst.Name))
+		Expect(binary[1].Kind()).To(Equal(cst.Token))
+		Expect(binary[2].Kind()).To(Equal(cst.Name))
+	})
+	It("locates nodes at their tokens", func() {
+		binary := build().Children()[0]
+		Expect(binary.Pos()).To(Equal(location.Pos(0)))
+		Expect(binary.Start()).To(Equal(location.Pos(1)))
+		Expect(binary.End()).To(Equal(location.Pos(6)))
+		Expect(binary.Text()).To(Equal("a + b"))
+		operator := binary.Children()[1]
+		Expect(operator.Start()).To(Equal(location.Pos(3)))
+		Expect(operator.Text()).To(Equal("+"))
+		Expect(operator.Parent().Kind()).To(Equal(cst.Binary))
+		Expect(operator.Index()).To(Equal(1))
+	})
+	It("locates an empty node at the token that follows it", func() {
+		b := cst.NewBuilder()
+		mark := b.Mark()
+		b.FinishError(mark, "Expected a name")
+		b.Token(tokens.Identifier, "  ", "a")
+		tree := b.Tree(cst.File, 0)
+		err := tree.Children()[0]
+		Expect(err.Kind()).To(Equal(cst.Error))
+		Expect(err.Message()).To(Equal("Expected a name"))
+		Expect(err.Start()).To(Equal(location.Pos(2)))
+		Expect(err.Text()).To(Equal(""))
+	})
+	It("restores a copy of a builder", func() {
+		b := cst.NewBuilder()
+		b.Token(tokens.Identifier, "", "a")
+		preserved := *b
+		b.Token(tokens.Identifier, " ", "b")
+		*b = preserved
+		b.Token(tokens.Identifier, " ", "c")
+		Expect(b.Tree(cst.File, 0).Green().Text()).To(Equal("a c"))
+	})
+	It("shares green nodes between trees", func() {
+		green := build().Green()
+		tree := cst.NewTree(green, 10)
+		Expect(tree.Children()[0].Start()).To(Equal(location.Pos(11)))
+		Expect(tree.Children()[0].Green()).To(BeIdenticalTo(green.Children()[0]))
+	})
+})
+
+func TestCst(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Concrete syntax tree suite")
+}
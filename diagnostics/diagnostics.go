@@ -3,6 +3,7 @@ package diagnostics
 import (
 	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"dyego0/errors"
 	"dyego0/tokens"
@@ -45,17 +46,20 @@ func Format(errors []errors.Error, fileSet tokens.FileSet, sourceProvider Source
 						startColumn := position.Column()
 						endColumn := endPosition.Column()
 						if position.Line() != endPosition.Line() {
-							endColumn = len(text) + 1
+							endColumn = utf8.RuneCountInString(text) + 1
 						}
 						result += text + "\n"
-						if startColumn > 0 {
-							for _, ch := range text[0 : startColumn-1] {
-								if ch == '\t' {
-									result += "\t"
-								} else {
-									result += " "
-								}
+						column := 1
+						for _, ch := range text {
+							if column >= startColumn {
+								break
 							}
+							if ch == '\t' {
+								result += "\t"
+							} else {
+								result += " "
+							}
+							column++
 						}
 						result += strings.Repeat("^", endColumn-startColumn) + "\n"
 					}
@@ -6,6 +6,7 @@ import (
 	"dyego0/tokens"
 	"strings"
 	"testing"
+	"unicode/utf8"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -52,6 +53,12 @@ var _ = Describe("diagnostics", func() {
 		msg := Format(errs, fs, p)
 		Expect(msg).To(Equal("file:3:10: This is the location\n\t\treport location\n\t\t       ^^^^^^^^\n"))
 	})
+	It("counts columns in runes", func() {
+		text := "größe = location"
+		fs, p, errs := buildErrors(text, "location", "This is the location")
+		msg := Format(errs, fs, p)
+		Expect(msg).To(Equal("file:1:9: This is the location\ngröße = location\n        ^^^^^^^^\n"))
+	})
 })
 
 type sourceProvider struct {
@@ -80,6 +87,9 @@ func fileFor(fs tokens.FileSet, text string) tokens.File {
 		if c == '\n' {
 			fb.AddLine(offset + 1)
 		}
+		if size := utf8.RuneLen(c); size > 1 {
+			fb.AddRune(offset, size)
+		}
 	}
 	return fb.Build()
 }
@@ -0,0 +1,1142 @@
+package format
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"dyego0/ast"
+	"dyego0/errors"
+	"dyego0/location"
+	"dyego0/parser"
+	"dyego0/scanner"
+)
+
+// Source formats src, the text of a Dyego0 module, as canonical Dyego0 source. The operators of
+// the module are parsed and printed using the vocabularies in scope. Comments and single blank
+// lines are preserved. Returns the errors reported by the parser if src cannot be parsed.
+func Source(src []byte, scope parser.VocabularyScope) ([]byte, []errors.Error) {
+	text := append(append([]byte{}, src...), 0)
+	p := parser.NewParser(scanner.NewScanner(text, scanner.TriviaScan, nil), scope)
+	element := p.Parse()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, errs
+	}
+	pr := newPrinter(src, scope)
+	pr.comments = p.Comments()
+	return []byte(pr.module(element)), nil
+}
+
+// Element prints element, such as a module produced by the parser, as Dyego0 source. The
+// operators are printed using the vocabularies in scope embedded by the spreads of element.
+func Element(element ast.Element, scope parser.VocabularyScope) string {
+	return strings.TrimSuffix(newPrinter(nil, scope).module(element), "\n")
+}
+
+// printer prints elements as source. If the elements were parsed from src, and their locations
+// are offsets in src, the source is used to preserve the layout of the original text, such as
+// the spelling of literals and whether a block was written on a single line.
+type printer struct {
+	src       []byte
+	operators *parser.Operators
+	comments  []ast.Comment
+	next      int
+
+	// exclude is true when a > operator must be parenthesized as it would end a type literal
+	exclude bool
+}
+
+func newPrinter(src []byte, scope parser.VocabularyScope) *printer {
+	return &printer{src: src, operators: parser.NewOperators(scope)}
+}
+
+const indentUnit = "  "
+
+func (p *printer) module(element ast.Element) string {
+	text := p.block(flatten(element), "", location.Pos(math.MaxInt32), statementBlock, p.statement)
+	if text == "" {
+		return ""
+	}
+	return text + "\n"
+}
+
+// flatten returns the elements of a sequence
+func flatten(element ast.Element) []ast.Element {
+	if element == nil {
+		return nil
+	}
+	if sequence, ok := element.(ast.Sequence); ok {
+		return append(flatten(sequence.Left()), flatten(sequence.Right())...)
+	}
+	return []ast.Element{element}
+}
+
+// blockKind determines how the items of a block are separated
+type blockKind int
+
+const (
+	// statementBlock items are separated by new lines and, when a new line would not end the
+	// previous item, by a comma
+	statementBlock blockKind = iota
+
+	// lineBlock items are separated by new lines
+	lineBlock
+
+	// listBlock items are each followed by a comma
+	listBlock
+
+	// separatedBlock items are separated by commas
+	separatedBlock
+)
+
+// line is a line of a block and the comment that follows it
+type line struct {
+	text    string
+	comment string
+	code    bool
+}
+
+// block prints items one per line at indent. The comments that precede end that are not
+// printed by the items themselves are printed on their own line or, if they followed code on
+// the same line, after the code.
+func (p *printer) block(
+	items []ast.Element,
+	indent string,
+	end location.Pos,
+	kind blockKind,
+	print func(element ast.Element, indent string) string,
+) string {
+	var lines []line
+	previous := location.Pos(-1)
+	last := -1
+	emitComment := func(c ast.Comment) {
+		text := strings.TrimRight(c.Text, " \t")
+		if !c.OwnLine && len(lines) > 0 && lines[len(lines)-1].code && lines[len(lines)-1].comment == "" {
+			lines[len(lines)-1].comment = text
+		} else {
+			if p.blankBetween(previous, c.Start()) && len(lines) > 0 {
+				lines = append(lines, line{})
+			}
+			lines = append(lines, line{text: indent + text})
+		}
+		previous = c.End()
+	}
+	for index, item := range items {
+		for c, ok := p.pending(item.Start()); ok; c, ok = p.pending(item.Start()) {
+			p.next++
+			emitComment(c)
+		}
+		text := print(item, indent)
+		for c, ok := p.pending(item.End()); ok; c, ok = p.pending(item.End()) {
+			p.next++
+			c.OwnLine = true
+			emitComment(c)
+		}
+		if last >= 0 {
+			switch kind {
+			case statementBlock:
+				if p.needsComma(items[index-1], text) {
+					lines[last].text += ","
+				}
+			case separatedBlock:
+				lines[last].text += ","
+			}
+		}
+		if p.blankBetween(previous, item.Start()) && len(lines) > 0 {
+			lines = append(lines, line{})
+		}
+		for number, part := range strings.Split(text, "\n") {
+			if number == 0 {
+				part = indent + part
+			}
+			lines = append(lines, line{text: part, code: true})
+		}
+		last = len(lines) - 1
+		if kind == listBlock {
+			lines[last].text += ","
+		}
+		previous = item.End()
+	}
+	for c, ok := p.pending(end); ok; c, ok = p.pending(end) {
+		p.next++
+		emitComment(c)
+	}
+	result := make([]string, len(lines))
+	for index, l := range lines {
+		result[index] = l.text
+		if l.comment != "" {
+			result[index] += " " + l.comment
+		}
+	}
+	return strings.Join(result, "\n")
+}
+
+// pending returns the next comment if it starts before limit
+func (p *printer) pending(limit location.Pos) (ast.Comment, bool) {
+	if p.next < len(p.comments) && p.comments[p.next].Start() < limit {
+		return p.comments[p.next], true
+	}
+	return ast.Comment{}, false
+}
+
+// hasComment returns true if a comment that has not been printed is between start and end
+func (p *printer) hasComment(start, end location.Pos) bool {
+	for _, c := range p.comments[p.next:] {
+		if c.Start() >= end {
+			break
+		}
+		if c.Start() >= start {
+			return true
+		}
+	}
+	return false
+}
+
+// valid returns true if start and end are offsets in the source
+func (p *printer) valid(start, end location.Pos) bool {
+	return p.src != nil && start >= 0 && start <= end && int(end) <= len(p.src)
+}
+
+// newlineBetween returns true if the source contains a new line between start and end
+func (p *printer) newlineBetween(start, end location.Pos) bool {
+	return p.valid(start, end) && strings.ContainsAny(string(p.src[start:end]), "\n\r")
+}
+
+// blankBetween returns true if the source contains a blank line between start and end
+func (p *printer) blankBetween(start, end location.Pos) bool {
+	return p.valid(start, end) && strings.Count(string(p.src[start:end]), "\n") > 1
+}
+
+// needsComma returns true if text, the next statement after previous, would be parsed as part of
+// previous if they were only separated by a new line
+func (p *printer) needsComma(previous ast.Element, text string) bool {
+	switch n := previous.(type) {
+	case ast.Return:
+		if n.Value() == nil {
+			return true
+		}
+	case ast.Break:
+		if n.Label() == nil {
+			return true
+		}
+	case ast.Continue:
+		if n.Label() == nil {
+			return true
+		}
+	}
+	if strings.HasPrefix(text, "(") || strings.HasPrefix(text, "[") && !strings.HasPrefix(text, "[!") {
+		return true
+	}
+	symbol := 0
+	for symbol < len(text) && isSymbol(text[symbol]) {
+		symbol++
+	}
+	if symbol > 0 {
+		_, ok := p.operators.Find(text[:symbol], ast.Infix)
+		return ok
+	}
+	return false
+}
+
+func (p *printer) statement(element ast.Element, indent string) string {
+	switch n := element.(type) {
+	case ast.Spread:
+		p.operators.Embed(n.Target())
+		return "..." + p.spreadTarget(n.Target(), indent)
+	case ast.Storage:
+		keyword := "val "
+		if n.Mutable() {
+			keyword = "var "
+		}
+		return keyword + p.storage(n, indent)
+	case ast.Loop:
+		return p.loop(n, indent)
+	case ast.Break:
+		return "break" + p.label(n.Label())
+	case ast.Continue:
+		return "continue" + p.label(n.Label())
+	case ast.Return:
+		if n.Value() == nil {
+			return "return"
+		}
+		return "return " + p.expression(n.Value(), indent)
+	case ast.TypeLiteral:
+		return p.typeLiteral(n, indent)
+	}
+	return p.expression(element, indent)
+}
+
+func (p *printer) spreadTarget(target ast.Element, indent string) string {
+	switch n := target.(type) {
+	case ast.VocabularyLiteral:
+		return p.vocabulary(n, indent)
+	case ast.Name, ast.Selection:
+		if names, ok := qualifiedName(n); ok {
+			return strings.Join(names, "::")
+		}
+	}
+	return p.expression(target, indent)
+}
+
+// qualifiedName returns the names of a selection of names such as a.b.c
+func qualifiedName(element ast.Element) ([]string, bool) {
+	switch n := element.(type) {
+	case ast.Name:
+		return []string{name(n.Text())}, true
+	case ast.Selection:
+		names, ok := qualifiedName(n.Target())
+		return append(names, name(n.Member().Text())), ok
+	}
+	return nil, false
+}
+
+func (p *printer) label(label ast.Name) string {
+	if label == nil {
+		return ""
+	}
+	return " " + name(label.Text())
+}
+
+func (p *printer) storage(storage ast.Storage, indent string) string {
+	result := name(storage.Name().Text())
+	if storage.Type() != nil {
+		result += ": " + p.typeReference(storage.Type(), indent)
+	}
+	if storage.Value() != nil {
+		result += " = " + p.expression(storage.Value(), indent)
+	}
+	return result
+}
+
+func (p *printer) definition(definition ast.Definition, indent string) string {
+	result := "let " + name(definition.Name().Text())
+	if definition.Type() != nil {
+		result += ": " + p.typeReference(definition.Type(), indent)
+	}
+	result += " = "
+	switch value := definition.Value().(type) {
+	case ast.TypeLiteral:
+		return result + p.typeLiteral(value, indent)
+	case ast.VocabularyLiteral:
+		return result + p.vocabulary(value, indent)
+	}
+	return result + p.expression(definition.Value(), indent)
+}
+
+func (p *printer) loop(loop ast.Loop, indent string) string {
+	if test, body, ok := whileLoop(loop); ok {
+		return "while" + p.label(loop.Label()) + " (" + p.expression(test, indent) + ") " +
+			p.braces("{", "}", body, test.End(), loop.End(), indent)
+	}
+	return "loop" + p.label(loop.Label()) + " " + p.braces("{", "}", loop.Body(), loop.Start(), loop.End(), indent)
+}
+
+// whileLoop returns the test and body of a loop the parser produced for a while statement
+func whileLoop(loop ast.Loop) (ast.Element, ast.Element, bool) {
+	when, ok := loop.Body().(ast.When)
+	if !ok || when.Target() != nil || len(when.Clauses()) != 2 {
+		return nil, nil, false
+	}
+	value, ok := when.Clauses()[0].(ast.WhenValueClause)
+	if !ok {
+		return nil, nil, false
+	}
+	otherwise, ok := when.Clauses()[1].(ast.WhenElseClause)
+	if !ok {
+		return nil, nil, false
+	}
+	exit, ok := otherwise.Body().(ast.Break)
+	if !ok || !sameLabel(exit.Label(), loop.Label()) {
+		return nil, nil, false
+	}
+	return value.Value(), value.Body(), true
+}
+
+func sameLabel(a, b ast.Name) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Text() == b.Text()
+}
+
+// braces prints the statements of body between open and close. A body with a single statement
+// that was not written on a new line after from is printed on one line.
+func (p *printer) braces(open, close string, body ast.Element, from, end location.Pos, indent string) string {
+	items := flatten(body)
+	comments := p.hasComment(from, end)
+	if len(items) == 0 && !comments {
+		return open + " " + close
+	}
+	if len(items) == 1 && !comments && !p.newlineBetween(from, body.Start()) {
+		return open + " " + p.statement(items[0], indent) + " " + close
+	}
+	return open + "\n" + p.block(items, indent+indentUnit, end, statementBlock, p.statement) + "\n" +
+		indent + close
+}
+
+// expression prints an expression that is parsed at the lowest precedence level
+func (p *printer) expression(element ast.Element, indent string) string {
+	return p.expressionAt(element, 0, indent)
+}
+
+// expressionAt prints an expression that is parsed where only operators with a precedence
+// higher than level, or right associative operators at level, continue the expression
+func (p *printer) expressionAt(element ast.Element, level int, indent string) string {
+	if op, ok := p.operatorOf(element); ok {
+		return p.operation(op, level, indent)
+	}
+	return p.primary(element, indent)
+}
+
+// parenthesized prints an expression in parentheses which reset the precedence level and the
+// excluded operators
+func (p *printer) parenthesized(element ast.Element, indent string) string {
+	exclude := p.exclude
+	p.exclude = false
+	result := "(" + p.expression(element, indent) + ")"
+	p.exclude = exclude
+	return result
+}
+
+// operation is a call the parser produced for an operator
+type operation struct {
+	element    ast.Element
+	operand    ast.Element
+	right      ast.Element
+	name       string
+	placement  ast.OperatorPlacement
+	precedence parser.OperatorPrecedence
+
+	// parts and arguments are the parts of a mixfix operator and the operands that follow the
+	// first operand, such as ? and : and b and c of a ? b : c
+	parts     []string
+	arguments []ast.Element
+}
+
+const postfixPrefix = "postfix "
+
+// operatorOf determines if element is a call that was written as an operator
+func (p *printer) operatorOf(element ast.Element) (operation, bool) {
+	call, ok := element.(ast.Call)
+	if !ok {
+		return operation{}, false
+	}
+	selection, ok := call.Target().(ast.Selection)
+	if !ok || p.dotted(selection) || p.indexed(selection) {
+		return operation{}, false
+	}
+	text := selection.Member().Text()
+	arguments := call.Arguments()
+	result := operation{element: element, operand: selection.Target(), name: text}
+	if parts := strings.Fields(strings.TrimPrefix(text, postfixPrefix)); len(parts) > 1 {
+		return p.mixfixOf(result, parts, selection, arguments)
+	}
+	switch len(arguments) {
+	case 0:
+		if strings.HasPrefix(text, postfixPrefix) {
+			result.name = text[len(postfixPrefix):]
+			result.placement = ast.Postfix
+			result.precedence, ok = p.operators.Find(result.name, ast.Postfix)
+			return result, ok
+		}
+		member, target := selection.Member(), selection.Target()
+		if member.Start() > target.Start() && member.Start() >= 0 {
+			return operation{}, false
+		}
+		result.placement = ast.Prefix
+		result.precedence, ok = p.operators.Find(text, ast.Prefix)
+		return result, ok
+	case 1:
+		if _, named := arguments[0].(ast.NamedArgument); named {
+			return operation{}, false
+		}
+		result.placement = ast.Infix
+		result.right = arguments[0]
+		result.precedence, ok = p.operators.Find(text, ast.Infix)
+		if !ok && p.src != nil && isIdentifier(text) {
+			result.precedence, ok = p.operators.Identifiers()
+		}
+		return result, ok
+	}
+	return operation{}, false
+}
+
+// mixfixOf determines if a call of a member with a name of several parts was written as a mixfix
+// operator, the operands between and after the parts being the arguments of the call
+func (p *printer) mixfixOf(
+	result operation,
+	parts []string,
+	selection ast.Selection,
+	arguments []ast.Element,
+) (operation, bool) {
+	for _, argument := range arguments {
+		if _, named := argument.(ast.NamedArgument); named {
+			return operation{}, false
+		}
+	}
+	result.name = strings.Join(parts, " ")
+	result.parts = parts
+	result.arguments = arguments
+	switch {
+	case strings.HasPrefix(selection.Member().Text(), postfixPrefix):
+		if len(arguments) != len(parts)-1 {
+			return operation{}, false
+		}
+		result.placement = ast.Postfix
+	case len(arguments) == len(parts):
+		result.placement = ast.Infix
+	case len(arguments) == len(parts)-1:
+		member, target := selection.Member(), selection.Target()
+		if member.Start() > target.Start() && member.Start() >= 0 {
+			return operation{}, false
+		}
+		result.placement = ast.Prefix
+	default:
+		return operation{}, false
+	}
+	var ok bool
+	result.precedence, ok = p.operators.Find(result.name, result.placement)
+	return result, ok
+}
+
+// dotted returns true if the member of selection was written after a '.'
+func (p *printer) dotted(selection ast.Selection) bool {
+	start := selection.Member().Start()
+	if !p.valid(start, start) {
+		return false
+	}
+	for offset := int(start) - 1; offset >= 0; offset-- {
+		switch p.src[offset] {
+		case ' ', '\t', '\n', '\r':
+			continue
+		case '.':
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+// indexed returns true if selection is the target of a call the parser produced for an index
+// expression such as a[i] or a[i] = v
+func (p *printer) indexed(selection ast.Selection) bool {
+	switch selection.Member().Text() {
+	case "get", "set":
+		member, target := selection.Member(), selection.Target()
+		return member.Start() >= 0 && member.Start() <= target.Start()
+	}
+	return false
+}
+
+// isAssignment returns true if element is an index assignment which extends to the end of the
+// expression that contains it
+func (p *printer) isAssignment(element ast.Element) bool {
+	call, ok := element.(ast.Call)
+	if !ok {
+		return false
+	}
+	selection, ok := call.Target().(ast.Selection)
+	return ok && selection.Member().Text() == "set" && p.indexed(selection)
+}
+
+// higher returns true if an operator with the given precedence continues an expression parsed
+// at level
+func higher(precedence parser.OperatorPrecedence, level int) bool {
+	return precedence.Level > level || precedence.Level == level && precedence.Associativity == ast.Right
+}
+
+func (p *printer) operation(op operation, level int, indent string) string {
+	if !higher(op.precedence, level) || op.name == ">" && p.exclude {
+		return p.parenthesized(op.element, indent)
+	}
+	switch op.placement {
+	case ast.Prefix:
+		if op.parts != nil {
+			operands := append([]ast.Element{op.operand}, op.arguments...)
+			return p.mixfix(op.parts[0], op, operands, true, indent)
+		}
+		operand := p.operand(op.operand, op.precedence.Level, indent, false)
+		if isIdentifier(op.name) || len(operand) > 0 && isSymbol(operand[0]) {
+			return op.name + " " + operand
+		}
+		return op.name + operand
+	case ast.Postfix:
+		parens := false
+		if inner, ok := p.operatorOf(op.operand); ok {
+			parens = inner.placement == ast.Infix ||
+				inner.placement == ast.Prefix && higher(op.precedence, inner.precedence.Level)
+		}
+		operand := p.operand(op.operand, level, indent, parens)
+		if op.parts != nil {
+			return p.mixfix(spaced(operand, op.parts[0]), op, op.arguments, false, indent)
+		}
+		if isIdentifier(op.name) || len(operand) > 0 && isSymbol(operand[len(operand)-1]) {
+			return operand + " " + op.name
+		}
+		return operand + op.name
+	}
+	parens := false
+	if left, ok := p.operatorOf(op.operand); ok {
+		switch left.placement {
+		case ast.Infix:
+			parens = left.precedence.Level < op.precedence.Level ||
+				left.precedence.Level == op.precedence.Level && op.precedence.Associativity != ast.Left
+		case ast.Prefix:
+			parens = higher(op.precedence, left.precedence.Level)
+		}
+	}
+	left := p.operand(op.operand, level, indent, parens)
+	if op.parts != nil {
+		return p.mixfix(left+" "+op.parts[0], op, op.arguments, true, indent)
+	}
+	return left + " " + op.name + " " + p.operand(op.right, op.precedence.Level, indent, false)
+}
+
+// mixfix prints the operands of a mixfix operator after text, the operands before each part after
+// the first part and, if trailing, the operand after the last part. The operands between the parts
+// are delimited by them so they are printed at the lowest level.
+func (p *printer) mixfix(text string, op operation, operands []ast.Element, trailing bool, indent string) string {
+	for index, part := range op.parts[1:] {
+		exclude := p.exclude
+		p.exclude = false
+		text = spaced(text, p.expression(operands[index], indent))
+		p.exclude = exclude
+		text = spaced(text, part)
+	}
+	if trailing {
+		text = spaced(text, p.operand(operands[len(operands)-1], op.precedence.Level, indent, false))
+	}
+	return text
+}
+
+// spaced joins text and next with a space unless they are joined by a bracket, such as a[b]
+func spaced(text, next string) string {
+	if strings.HasSuffix(text, "[") || next == "[" || next == "]" {
+		return text + next
+	}
+	return text + " " + next
+}
+
+// operand prints the operand of an operator
+func (p *printer) operand(element ast.Element, level int, indent string, parens bool) string {
+	if parens || p.isAssignment(element) {
+		return p.parenthesized(element, indent)
+	}
+	return p.expressionAt(element, level, indent)
+}
+
+// target prints the target of a selection or a call
+func (p *printer) target(element ast.Element, indent string) string {
+	if _, ok := p.operatorOf(element); ok || p.isAssignment(element) {
+		return p.parenthesized(element, indent)
+	}
+	switch n := element.(type) {
+	case ast.Definition:
+		return p.parenthesized(element, indent)
+	case ast.Literal:
+		text := p.literal(n)
+		if len(text) > 0 && text[0] >= '0' && text[0] <= '9' {
+			return "(" + text + ")"
+		}
+		return text
+	}
+	return p.primary(element, indent)
+}
+
+func (p *printer) primary(element ast.Element, indent string) string {
+	switch n := element.(type) {
+	case ast.Name:
+		return p.reference(n.Text())
+	case ast.Literal:
+		return p.literal(n)
+	case ast.Selection:
+		return p.target(n.Target(), indent) + "." + name(n.Member().Text())
+	case ast.SafeSelection:
+		return p.target(n.Target(), indent) + "?." + name(n.Member().Text())
+	case ast.Call:
+		return p.call(n, indent)
+	case ast.Lambda:
+		return p.lambda("{", "}", n.TypeParameters(), n.Parameters(), n.Body(), n.Result(), n, indent)
+	case ast.IntrinsicLambda:
+		return p.lambda("{!", "!}", nil, n.Parameters(), n.Body(), n.Result(), n, indent)
+	case ast.Instantiation:
+		return p.typeReference(n, indent)
+	case ast.ObjectInitializer:
+		return p.initializer(n.Mutable(), n.Type(), n.Members(), n, indent)
+	case ast.ArrayInitializer:
+		return p.initializer(n.Mutable(), n.Type(), n.Elements(), n, indent)
+	case ast.When:
+		return p.when(n, indent)
+	case ast.Definition:
+		return p.definition(n, indent)
+	case ast.TypeLiteral:
+		return p.typeLiteral(n, indent)
+	case ast.VocabularyLiteral:
+		return p.vocabulary(n, indent)
+	case ast.Spread:
+		return "..." + p.expression(n.Target(), indent)
+	case ast.Storage, ast.Loop, ast.Break, ast.Continue, ast.Return:
+		return p.statement(element, indent)
+	case ast.Sequence:
+		var items []string
+		for _, item := range flatten(n) {
+			items = append(items, p.statement(item, indent))
+		}
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s", element)
+}
+
+func (p *printer) call(call ast.Call, indent string) string {
+	arguments := call.Arguments()
+	if selection, ok := call.Target().(ast.Selection); ok && p.indexed(selection) {
+		target := p.target(selection.Target(), indent)
+		if selection.Member().Text() == "set" && len(arguments) > 0 {
+			last := len(arguments) - 1
+			return target + p.list("[", "]", arguments[:last], selection.Target().End(), arguments[last].Start(),
+				indent, p.argument) + " = " + p.expression(arguments[last], indent)
+		}
+		return target + p.list("[", "]", arguments, selection.Target().End(), call.End(), indent, p.argument)
+	}
+	if instantiation, ok := call.Target().(ast.Instantiation); ok {
+		open := "(" + p.typeArguments(instantiation.TypeArguments(), indent)
+		if len(arguments) > 0 {
+			open += " "
+		}
+		return p.target(instantiation.Target(), indent) +
+			p.list(open, ")", arguments, instantiation.End(), call.End(), indent, p.argument)
+	}
+	return p.target(call.Target(), indent) +
+		p.list("(", ")", arguments, call.Target().End(), call.End(), indent, p.argument)
+}
+
+func (p *printer) typeArguments(typeArguments []ast.Element, indent string) string {
+	var texts []string
+	for _, typeArgument := range typeArguments {
+		texts = append(texts, p.typeReference(typeArgument, indent))
+	}
+	text := strings.Join(texts, ", ")
+	if len(text) > 0 && isSymbol(text[len(text)-1]) {
+		text += " "
+	}
+	return "<" + text + ">"
+}
+
+// list prints items between open and close. The items are printed one per line if the first
+// item was written on a new line or if a comment is between start and end.
+func (p *printer) list(
+	open, close string,
+	items []ast.Element,
+	start, end location.Pos,
+	indent string,
+	print func(element ast.Element, indent string) string,
+) string {
+	if p.multiline(items, start, end) {
+		return open + "\n" + p.block(items, indent+indentUnit, end, listBlock, print) + "\n" + indent + close
+	}
+	var texts []string
+	for _, item := range items {
+		texts = append(texts, print(item, indent))
+	}
+	return open + strings.Join(texts, ", ") + close
+}
+
+// multiline returns true if the items of a list between start and end are printed one per line
+func (p *printer) multiline(items []ast.Element, start, end location.Pos) bool {
+	return p.hasComment(start, end) || len(items) > 0 && p.newlineBetween(start, items[0].Start())
+}
+
+func (p *printer) argument(element ast.Element, indent string) string {
+	if argument, ok := element.(ast.NamedArgument); ok {
+		return p.named(argument.Name(), argument.Value(), indent)
+	}
+	return p.expression(element, indent)
+}
+
+// named prints a named argument or member initializer using the :name shorthand if the name
+// was written as the start of the value
+func (p *printer) named(n ast.Name, value ast.Element, indent string) string {
+	text := p.expression(value, indent)
+	if n.Start() >= 0 && n.Start() == value.Start() {
+		reference := p.reference(n.Text())
+		if strings.HasPrefix(text, reference) {
+			return ":" + text
+		}
+	}
+	return name(n.Text()) + ": " + text
+}
+
+func (p *printer) member(element ast.Element, indent string) string {
+	switch n := element.(type) {
+	case ast.NamedMemberInitializer:
+		return p.named(n.Name(), n.Value(), indent)
+	case ast.Spread:
+		return "..." + p.expression(n.Target(), indent)
+	}
+	return p.expression(element, indent)
+}
+
+func (p *printer) initializer(
+	mutable bool,
+	typ ast.Element,
+	items []ast.Element,
+	element ast.Element,
+	indent string,
+) string {
+	open, close := "[", "]"
+	if mutable {
+		open, close = "[!", "!]"
+	}
+	start := element.Start()
+	prefix := ""
+	if typ != nil {
+		text := p.typeReference(typ, indent)
+		if isSymbol(text[len(text)-1]) {
+			text += " "
+		}
+		prefix = "<" + text + ">"
+		start = typ.End()
+	}
+	if p.multiline(items, start, element.End()) {
+		return p.list(open+prefix, close, items, start, element.End(), indent, p.member)
+	}
+	if mutable && len(items) == 0 {
+		return open + prefix + " " + close
+	}
+	if mutable {
+		open, close = open+" ", " "+close
+	}
+	if prefix != "" && len(items) > 0 {
+		prefix += " "
+	}
+	return p.list(open+prefix, close, items, start, element.End(), indent, p.member)
+}
+
+func (p *printer) lambda(
+	open, close string,
+	typeParameters []ast.TypeParameter,
+	parameters []ast.Parameter,
+	body ast.Element,
+	result ast.Element,
+	element ast.Element,
+	indent string,
+) string {
+	head := open
+	if len(typeParameters) > 0 {
+		head += " " + p.typeParameters(typeParameters, indent) + " |"
+	}
+	if len(parameters) > 0 {
+		var texts []string
+		for _, parameter := range parameters {
+			texts = append(texts, p.parameter(parameter, indent))
+		}
+		head += " " + strings.Join(texts, ", ") + " ->"
+	}
+	end := element.End()
+	if result != nil {
+		end = result.Start()
+	}
+	text := p.braces(head, close, body, element.Start(), end, indent)
+	if result != nil {
+		text += ": " + p.typeReference(result, indent)
+	}
+	return text
+}
+
+func (p *printer) parameter(parameter ast.Parameter, indent string) string {
+	result := name(parameter.Name().Text())
+	if parameter.Type() != nil {
+		result += ": " + p.typeReference(parameter.Type(), indent)
+	}
+	if parameter.Default() != nil {
+		result += " = " + p.expression(parameter.Default(), indent)
+	}
+	return result
+}
+
+func (p *printer) typeParameters(typeParameters []ast.TypeParameter, indent string) string {
+	var texts []string
+	for _, typeParameter := range typeParameters {
+		text := name(typeParameter.Name().Text())
+		if typeParameter.Constraint() != nil {
+			text += ": " + p.typeReference(typeParameter.Constraint(), indent)
+		}
+		texts = append(texts, text)
+	}
+	return strings.Join(texts, ", ")
+}
+
+func (p *printer) when(when ast.When, indent string) string {
+	clauses := when.Clauses()
+	if when.Target() == nil && isIf(clauses) {
+		value := clauses[0].(ast.WhenValueClause)
+		result := "if (" + p.expression(value.Value(), indent) + ") " +
+			p.braces("{", "}", value.Body(), value.Value().End(), value.End(), indent)
+		if len(clauses) > 1 {
+			otherwise := clauses[1].(ast.WhenElseClause)
+			result += " else " + p.braces("{", "}", otherwise.Body(), otherwise.Start(), otherwise.End(), indent)
+		}
+		return result
+	}
+	result := "when "
+	if when.Target() != nil {
+		result += "(" + p.expression(when.Target(), indent) + ") "
+	}
+	if len(clauses) == 0 && !p.hasComment(when.Start(), when.End()) {
+		return result + "{ }"
+	}
+	return result + "{\n" + p.block(clauses, indent+indentUnit, when.End(), lineBlock, p.clause) + "\n" + indent + "}"
+}
+
+func isIf(clauses []ast.Element) bool {
+	if len(clauses) == 0 || len(clauses) > 2 {
+		return false
+	}
+	if _, ok := clauses[0].(ast.WhenValueClause); !ok {
+		return false
+	}
+	if len(clauses) == 2 {
+		_, ok := clauses[1].(ast.WhenElseClause)
+		return ok
+	}
+	return true
+}
+
+func (p *printer) clause(element ast.Element, indent string) string {
+	switch n := element.(type) {
+	case ast.WhenValueClause:
+		return p.expression(n.Value(), indent) + " -> " +
+			p.braces("{", "}", n.Body(), n.Value().End(), n.End(), indent)
+	case ast.WhenElseClause:
+		return "else -> " + p.braces("{", "}", n.Body(), n.Start(), n.End(), indent)
+	}
+	return p.expression(element, indent)
+}
+
+func (p *printer) literal(literal ast.Literal) string {
+	if p.valid(literal.Start(), literal.End()) && literal.Start() < literal.End() {
+		return string(p.src[literal.Start():literal.End()])
+	}
+	if spelling := literal.Spelling(); spelling != "" {
+		return spelling
+	}
+	switch value := literal.Value().(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(value)
+	case string:
+		return quote(value)
+	case int:
+		return strconv.Itoa(value)
+	case uint:
+		return fmt.Sprintf("%du", value)
+	case byte:
+		return fmt.Sprintf("%dub", value)
+	case int64:
+		return fmt.Sprintf("%dl", value)
+	case uint64:
+		return fmt.Sprintf("%dul", value)
+	case int32:
+		return fmt.Sprintf("0x%X", uint32(value))
+	case uint32:
+		return fmt.Sprintf("0x%Xu", value)
+	case float32:
+		return float(float64(value), 32) + "f"
+	case float64:
+		return float(value, 64)
+	}
+	return fmt.Sprintf("%v", literal.Value())
+}
+
+// float formats a floating point value in a form the scanner accepts, which requires a decimal
+// point and does not accept exponents
+func float(value float64, size int) string {
+	result := strconv.FormatFloat(value, 'f', -1, size)
+	if !strings.Contains(result, ".") {
+		result += ".0"
+	}
+	return result
+}
+
+func quote(value string) string {
+	result := "\""
+	for _, r := range value {
+		switch r {
+		case '\n':
+			result += "\\n"
+		case '\r':
+			result += "\\r"
+		case '\b':
+			result += "\\b"
+		case '\t':
+			result += "\\t"
+		case '\\':
+			result += "\\\\"
+		case '"':
+			result += "\\\""
+		case 0:
+			result += "\\0"
+		default:
+			if unicode.IsPrint(r) {
+				result += string(r)
+			} else {
+				result += fmt.Sprintf("\\u{%X}", r)
+			}
+		}
+	}
+	return result + "\""
+}
+
+func (p *printer) typeReference(element ast.Element, indent string) string {
+	switch n := element.(type) {
+	case ast.Name:
+		return name(n.Text())
+	case ast.Selection:
+		return p.typeOperand(n.Target(), indent) + "." + name(n.Member().Text())
+	case ast.SequenceType:
+		return p.typeOperand(n.Elements(), indent) + "[]"
+	case ast.OptionalType:
+		return p.typeOperand(n.Target(), indent) + "?"
+	case ast.ReferenceType:
+		referent := p.typeReference(n.Referent(), indent)
+		if isSymbol(referent[0]) {
+			return "* " + referent
+		}
+		return "*" + referent
+	case ast.TypeLiteral:
+		return p.typeLiteral(n, indent)
+	case ast.Instantiation:
+		return p.typeOperand(n.Target(), indent) + p.typeArguments(n.TypeArguments(), indent)
+	case ast.Call:
+		if left, right, ok := typeOperator(n); ok {
+			return p.typeOperand(left, indent) + " & " + p.typeReference(right, indent)
+		}
+	}
+	return p.primary(element, indent)
+}
+
+// typeOperator returns the operands of a type formed with the & type operator
+func typeOperator(call ast.Call) (ast.Element, ast.Element, bool) {
+	selection, ok := call.Target().(ast.Selection)
+	if !ok || selection.Member().Text() != "&" || len(call.Arguments()) != 1 {
+		return nil, nil, false
+	}
+	return selection.Target(), call.Arguments()[0], true
+}
+
+// typeOperand prints a type that is followed by a type operator or suffix
+func (p *printer) typeOperand(element ast.Element, indent string) string {
+	switch n := element.(type) {
+	case ast.ReferenceType:
+		return "(" + p.typeReference(element, indent) + ")"
+	case ast.Call:
+		if _, _, ok := typeOperator(n); ok {
+			return "(" + p.typeReference(element, indent) + ")"
+		}
+	}
+	return p.typeReference(element, indent)
+}
+
+func (p *printer) typeLiteral(literal ast.TypeLiteral, indent string) string {
+	members := literal.Members()
+	comments := p.hasComment(literal.Start(), literal.End())
+	open, start := "<", literal.Start()
+	if typeParameters := literal.TypeParameters(); len(typeParameters) > 0 {
+		open += " " + p.typeParameters(typeParameters, indent) + " ->"
+		start = typeParameters[len(typeParameters)-1].End()
+	}
+	if len(members) == 0 && !comments {
+		return open + " >"
+	}
+	exclude := p.exclude
+	p.exclude = true
+	defer func() { p.exclude = exclude }()
+	if len(members) > 0 && !comments && p.src != nil && !p.newlineBetween(start, members[0].Start()) {
+		var texts []string
+		for _, member := range members {
+			texts = append(texts, p.typeMember(member, indent))
+		}
+		return open + " " + strings.Join(texts, ", ") + " >"
+	}
+	return open + "\n" + p.block(members, indent+indentUnit, literal.End(), lineBlock, p.typeMember) + "\n" +
+		indent + ">"
+}
+
+func (p *printer) typeMember(element ast.Element, indent string) string {
+	switch n := element.(type) {
+	case ast.Storage:
+		if n.Mutable() {
+			return "var " + p.storage(n, indent)
+		}
+		return p.storage(n, indent)
+	case ast.Definition:
+		return p.definition(n, indent)
+	case ast.CallableTypeMember:
+		var texts []string
+		for _, parameter := range n.Parameters() {
+			if parameter, ok := parameter.(ast.Parameter); ok {
+				texts = append(texts, p.parameter(parameter, indent))
+			}
+		}
+		head := "{ "
+		if len(texts) > 0 {
+			head += strings.Join(texts, ", ") + " "
+		}
+		return head + "-> " + p.typeReference(n.Result(), indent) + " }"
+	case ast.Spread:
+		return "..." + p.typeReference(n.Target(), indent)
+	}
+	return p.expression(element, indent)
+}
+
+func (p *printer) vocabulary(vocabulary ast.VocabularyLiteral, indent string) string {
+	members := vocabulary.Members()
+	comments := p.hasComment(vocabulary.Start(), vocabulary.End())
+	if len(members) == 0 && !comments {
+		return "<| |>"
+	}
+	if len(members) > 0 && !comments && p.src != nil && !p.newlineBetween(vocabulary.Start(), members[0].Start()) {
+		var texts []string
+		for _, member := range members {
+			texts = append(texts, p.vocabularyMember(member, indent))
+		}
+		return "<| " + strings.Join(texts, ", ") + " |>"
+	}
+	return "<|\n" + p.block(members, indent+indentUnit, vocabulary.End(), separatedBlock, p.vocabularyMember) +
+		"\n" + indent + "|>"
+}
+
+func (p *printer) vocabularyMember(element ast.Element, indent string) string {
+	switch n := element.(type) {
+	case ast.VocabularyOperatorDeclaration:
+		result := n.Placement().String() + " operator "
+		names := n.Names()
+		if len(names) == 1 {
+			result += operatorName(names[0].Text())
+		} else {
+			var texts []string
+			for _, name := range names {
+				texts = append(texts, operatorName(name.Text()))
+			}
+			result += "(" + strings.Join(texts, ", ") + ")"
+		}
+		if precedence := n.Precedence(); precedence != nil {
+			result += " " + precedence.Relation().String()
+			if precedence.Placement() != ast.UnspecifiedPlacement {
+				result += " " + precedence.Placement().String()
+			}
+			result += " " + operatorName(precedence.Name().Text())
+		}
+		return result + " " + n.Associativity().String()
+	case ast.VocabularyEmbedding:
+		var texts []string
+		for _, n := range n.Name() {
+			texts = append(texts, name(n.Text()))
+		}
+		return "..." + strings.Join(texts, "::")
+	}
+	return p.expression(element, indent)
+}
@@ -0,0 +1,235 @@
+package format
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"dyego0/ast"
+	"dyego0/location"
+	"dyego0/parser"
+	"dyego0/scanner"
+)
+
+var _ = Describe("format", func() {
+	locations := regexp.MustCompile(`Location\(\d+-\d+\)`)
+	parse := func(text string) (ast.Element, bool) {
+		p := parser.NewParser(scanner.NewScanner(append([]byte(text), 0), 0, nil), parser.DefaultVocabularyScope())
+		element := p.Parse()
+		return element, len(p.Errors()) == 0
+	}
+	structure := func(text string) string {
+		element, ok := parse(text)
+		Expect(ok).To(BeTrue(), text)
+		return locations.ReplaceAllString(fmt.Sprintf("%s", element), "")
+	}
+	f := func(text string) string {
+		result, errors := Source([]byte(text), parser.DefaultVocabularyScope())
+		Expect(errors).To(BeEmpty())
+		return string(result)
+	}
+	roundTrip := func(text string) string {
+		result := f(text)
+		Expect(structure(result)).To(Equal(structure(text)), result)
+		Expect(f(result)).To(Equal(result))
+		return result
+	}
+	same := func(text string) {
+		Expect(roundTrip(text)).To(Equal(text))
+	}
+	d := "...Dyego0\n"
+	Describe("examples", func() {
+		// unparsed are the examples that sketch syntax the parser does not support, so they cannot
+		// be round tripped until it does
+		unparsed := map[string]string{
+			"Example.dg": "it is notes on the syntax, with --- separators and unfinished vocabularies",
+			"Simple.dg":  "it uses the module, value and fun declarations of a later language",
+		}
+		files, _ := filepath.Glob("../examples/*.dg")
+		builtins, _ := filepath.Glob("../builtins/*.dg")
+		for _, fileName := range append(files, builtins...) {
+			fileName := fileName
+			if reason, ok := unparsed[filepath.Base(fileName)]; ok {
+				It(fmt.Sprintf("does not round trip %s because %s", filepath.Base(fileName), reason), func() {
+					content, err := ioutil.ReadFile(fileName)
+					Expect(err).To(BeNil())
+					_, ok := parse(string(content))
+					Expect(ok).To(BeFalse(), "%s parses, so it should be round tripped", fileName)
+				})
+				continue
+			}
+			It(fmt.Sprintf("round trips %s", filepath.Base(fileName)), func() {
+				content, err := ioutil.ReadFile(fileName)
+				Expect(err).To(BeNil())
+				roundTrip(string(content))
+			})
+		}
+	})
+	Describe("expressions", func() {
+		It("normalizes spacing", func() {
+			Expect(roundTrip(d + "val x = a+b*c")).To(Equal(d + "val x = a + b * c\n"))
+		})
+		It("preserves required parentheses", func() {
+			same(d + "val x = (a + b) * c\n")
+			same(d + "val x = a - (b - c)\n")
+			same(d + "val x = -(a + b)\n")
+		})
+		It("removes redundant parentheses", func() {
+			Expect(roundTrip(d + "val x = (a * b) + (c)")).To(Equal(d + "val x = a * b + c\n"))
+		})
+		It("prints prefix and postfix operators", func() {
+			same(d + "val x = -a * b++\n")
+			same(d + "val x = - -a\n")
+			same(d + "val x = (-a)++\n")
+		})
+		It("prints identifiers as operators", func() {
+			same(d + "val x = a dot b\n")
+		})
+		It("prints selections and calls", func() {
+			same(d + "val x = (a + b).c(d, e: f)\n")
+			same("val x = (1).b\n")
+		})
+		It("prints safe selections", func() {
+			same("val x = a?.b?.c(d).e\n")
+			same(d + "val x = (a + b)?.c\n")
+		})
+		It("prints index expressions", func() {
+			same(d + "val x = a[i + 1]\n")
+			same(d + "a[i] = b + c\n")
+		})
+		It("prints shorthand names", func() {
+			same("val x = f(:a, b: c)\n")
+			same("val x = [:a, :b.c]\n")
+		})
+		It("prints literals as written", func() {
+			same("val x = f(0x7Fub, 1.5, 2.5f, 3l, \"a\\n\", 'a', true, null)\n")
+		})
+		It("escapes names", func() {
+			same("val `a b` = `if`\n")
+			same(d + "let `+` = { a }\n")
+		})
+	})
+	Describe("statements", func() {
+		It("separates statements that continue the previous statement", func() {
+			same(d + "a,\n(b + c).d\n")
+			same("a,\n[b]\n")
+			same("{\n  return,\n  a\n}\n")
+		})
+		It("prints control flow", func() {
+			same(d + "if (a) { b } else { c }\n")
+			same(d + "while (a < b) {\n  a = a + 1\n}\n")
+			same("loop outer {\n  break outer\n}\n")
+			same("when (a) {\n  1 -> { b }\n  else -> { c }\n}\n")
+		})
+		It("prints storage", func() {
+			same("var a: Int = 1\nval b: Int[]? = c\n")
+		})
+	})
+	Describe("literals", func() {
+		It("prints lambdas", func() {
+			same("let f = { a: Int, b: Int = 1 -> a }: Int\n")
+			same("let f = { }\n")
+			same("let f = {\n  a\n  b\n}\n")
+		})
+		It("prints generic lambdas", func() {
+			same("let f = { X | x: X -> x }: X\n")
+			same("let f = { X, Y: Comparable | x: X, y: Y -> x }\n")
+			same("val a = f(<Int> 1)\n")
+			same("val a = f(<Int, Box<Int> >)\n")
+		})
+		It("prints intrinsic lambdas", func() {
+			same("val f = {! a: Int -> a !}: Int\n")
+		})
+		It("prints initializers", func() {
+			same("val a = []\n")
+			same("val a = [! a: 1 !]\n")
+			same("val a = [! !]\n")
+			same("val a = [<Point> x: 1, y: 2]\n")
+			same("val a = [\n  1,\n  2,\n]\n")
+		})
+		It("prints type literals", func() {
+			same("let T = < >\n")
+			same("let T = < x: Int, var y: Int >\n")
+			same("let T = <\n  x: Int\n  let f = { x }\n>\n")
+			same("let T = < a: *(A & B)[], b: A & B & C >\n")
+			same("let T = < X, Y: Comparable -> x: X, y: Box<Y>[] >\n")
+			same("let T = < X ->\n  x: X\n>\n")
+		})
+		It("parenthesizes greater than in a type literal", func() {
+			same(d + "let T = < let f = { a: Int -> (a > 1) } >\n")
+		})
+		It("prints vocabularies", func() {
+			same("let V = <| infix operator (`+`, `-`) left, infix operator `*` after infix `+` left |>\n")
+			same("let V = <|\n  infix operator identifiers right,\n  ...a::b\n|>\n")
+			same("let V = <| infix operator `<` none, infix operator `>` same as infix `<` none |>\n")
+		})
+		It("embeds the vocabularies of spreads", func() {
+			same("...<| infix operator `+` right |>\nval x = a + b + c\n")
+		})
+		It("prints mixfix operators", func() {
+			v := "...<|\n  infix operator `? :` right,\n  prefix operator `if then else` right,\n" +
+				"  infix operator `+` before infix `? :` left,\n  postfix operator `[ ]` before infix `+` left\n|>\n"
+			same(v + "val x = a ? b + 1 : c ? d : e\n")
+			same(v + "val x = (a ? b : c) ? d : e\n")
+			same(v + "val x = a ? if b then c else d : e\n")
+			same(v + "val x = x[i] + y[j + 1]\n")
+			Expect(roundTrip(v + "val x = (if a then b else c) + d [e]")).
+				To(Equal(v + "val x = (if a then b else c) + d[e]\n"))
+		})
+	})
+	Describe("comments", func() {
+		It("preserves leading comments", func() {
+			same("// leading\nval a = 1\n")
+		})
+		It("preserves trailing comments", func() {
+			same("val a = 1 // trailing\nval b = 2\n")
+		})
+		It("preserves comments at the end of a block", func() {
+			same("let f = {\n  a\n  // end\n}\n")
+			same("val a = 1\n// end\n")
+		})
+		It("preserves comments in lists", func() {
+			same("val a = f(\n  a, // first\n  b,\n)\n")
+		})
+		It("preserves block comments", func() {
+			same("/* leading */\nval a = 1 /* trailing */\n")
+			same("val a = f(\n  /* first */\n  a,\n)\n")
+		})
+		It("preserves blank lines", func() {
+			Expect(roundTrip("val a = 1\n\n\n\nval b = 2")).To(Equal("val a = 1\n\nval b = 2\n"))
+		})
+	})
+	Describe("elements", func() {
+		It("prints an element without source", func() {
+			element, ok := parse(d + "val x = (a + b) * -c\nval y = 0x10")
+			Expect(ok).To(BeTrue())
+			Expect(Element(element, parser.DefaultVocabularyScope())).To(Equal(d + "val x = (a + b) * -c\nval y = 0x10"))
+		})
+		It("prints literals as spelled without source", func() {
+			element, ok := parse(d + `val größe = "é\0\u{7F}\x41" + 1_000 + 0b1010`)
+			Expect(ok).To(BeTrue())
+			Expect(Element(element, parser.DefaultVocabularyScope())).To(Equal(
+				d + `val größe = "é\0\u{7F}\x41" + 1_000 + 0b1010`))
+		})
+		It("escapes strings without a spelling", func() {
+			b := ast.NewBuilder(location.NewLocation(0, 1))
+			b.PushContext()
+			element := b.Literal("é\x00\x7F\"", "")
+			Expect(Element(element, parser.DefaultVocabularyScope())).To(Equal(`"é\0\u{7F}\""`))
+		})
+	})
+	It("reports parse errors", func() {
+		_, errors := Source([]byte("val = "), parser.DefaultVocabularyScope())
+		Expect(errors).ToNot(BeEmpty())
+	})
+})
+
+func TestFormat(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Format Suite")
+}
@@ -0,0 +1,83 @@
+package format
+
+import (
+	"strings"
+
+	"dyego0/ast"
+	"dyego0/scanner"
+	"dyego0/tokens"
+)
+
+// infixTypeMember is the name the parser gives the declaration of identifiers as infix operators
+const infixTypeMember = "infix type member"
+
+// scanName scans text as a single token returning the token and its pseudo token. The token is
+// tokens.Invalid if text is not exactly one token.
+func scanName(text string) (tokens.Token, tokens.PseudoToken) {
+	if text == "" {
+		return tokens.Invalid, tokens.InvalidPseudoToken
+	}
+	s := scanner.NewScanner(append([]byte(text), 0), 0, nil)
+	token := s.Next()
+	pseudo := s.PseudoToken()
+	if int(s.End()) != len(text) || s.Next() != tokens.EOF {
+		return tokens.Invalid, tokens.InvalidPseudoToken
+	}
+	return token, pseudo
+}
+
+// isIdentifier returns true if text can be written as an identifier without escaping it
+func isIdentifier(text string) bool {
+	token, pseudo := scanName(text)
+	return token == tokens.Identifier && pseudo != tokens.Escaped
+}
+
+// isPseudoKeyword returns true if text is an identifier the parser treats as a keyword in some
+// contexts, such as if or while
+func isPseudoKeyword(text string) bool {
+	token, pseudo := scanName(text)
+	return token == tokens.Identifier && pseudo != tokens.InvalidPseudoToken && pseudo != tokens.Escaped
+}
+
+// isSymbol returns true if b is a character that can be part of an operator symbol
+func isSymbol(b byte) bool {
+	return strings.IndexByte("+|-*/%!&><=:?~@#$^", b) >= 0
+}
+
+// escape writes text as an escaped name
+func escape(text string) string {
+	return "`" + text + "`"
+}
+
+// name prints the name of a declaration or member, escaping it if it is not an identifier
+func name(text string) string {
+	if isIdentifier(text) {
+		return text
+	}
+	return escape(text)
+}
+
+// operatorName prints the name of an operator declared by a vocabulary
+func operatorName(text string) string {
+	if text == infixTypeMember {
+		return "identifiers"
+	}
+	if isIdentifier(text) && !isPseudoKeyword(text) {
+		return text
+	}
+	return escape(text)
+}
+
+// reference prints a name used as an expression. Names that would be parsed as the start of a
+// statement or as an operator are escaped.
+func (p *printer) reference(text string) string {
+	if !isIdentifier(text) || isPseudoKeyword(text) {
+		return escape(text)
+	}
+	for placement := ast.OperatorPlacement(0); placement < ast.UnspecifiedPlacement; placement++ {
+		if _, ok := p.operators.Find(text, placement); ok {
+			return escape(text)
+		}
+	}
+	return text
+}
@@ -0,0 +1,761 @@
+package interp
+
+import (
+	"fmt"
+	"io"
+
+	"dyego0/ast"
+	"dyego0/binder"
+	"dyego0/errors"
+	"dyego0/symbols"
+	"dyego0/types"
+)
+
+// signalKind is the kind of transfer of control out of the normal evaluation order
+type signalKind int
+
+const (
+	breakSignal signalKind = iota
+	continueSignal
+	returnSignal
+)
+
+// signal records a pending break, continue or return as the evaluation unwinds to the
+// loop or lambda that handles it
+type signal struct {
+	kind  signalKind
+	label string
+	value Value
+}
+
+// runtimeError is used to unwind the evaluation when an error is detected
+type runtimeError struct {
+	err errors.Error
+}
+
+// environment is a lexical scope of the values visible to an expression
+type environment struct {
+	parent *environment
+	values map[string]Value
+
+	// this is the record whose fields are in scope, if any
+	this *Record
+
+	// typ is the type whose type members are in scope, if any
+	typ types.TypeSymbol
+
+	// members caches the values of the type members when this is nil
+	members map[string]Value
+}
+
+func newEnvironment(parent *environment) *environment {
+	return &environment{parent: parent, values: make(map[string]Value)}
+}
+
+// Interpreter evaluates the elements of a module bound and checked by the binder
+type Interpreter struct {
+	context  *binder.BindingContext
+	host     *environment
+	module   *environment
+	types    map[types.TypeSymbol]*environment
+	declared map[types.TypeSymbol]*environment
+	members  map[ast.Element]bool
+	imports  map[types.TypeSymbol]*Interpreter
+	signal   *signal
+}
+
+// New creates an interpreter for the module bound in context. The print function of the
+// prelude writes to out.
+func New(context *binder.BindingContext, module types.TypeSymbol, out io.Writer) *Interpreter {
+	i := &Interpreter{
+		context:  context,
+		host:     newEnvironment(nil),
+		types:    make(map[types.TypeSymbol]*environment),
+		declared: make(map[types.TypeSymbol]*environment),
+		members:  make(map[ast.Element]bool),
+		imports:  make(map[types.TypeSymbol]*Interpreter),
+	}
+	i.module = i.typeEnvironment(module, i.host)
+	i.module.values = make(map[string]Value)
+	i.declare(module, i.module)
+	i.Define("print", Function(func(arguments []Value) (Value, error) {
+		for _, argument := range arguments {
+			fmt.Fprint(out, argument)
+		}
+		return nil, nil
+	}))
+	return i
+}
+
+// Define defines a value, such as a host Function, that is visible to the module by name
+func (i *Interpreter) Define(name string, value Value) {
+	i.host.values[name] = value
+}
+
+// Import makes the values of the module run by imported visible to the module, which refers to
+// them by the qualified name of the module
+func (i *Interpreter) Import(imported *Interpreter) {
+	i.imports[imported.module.typ] = imported
+}
+
+// declare records the environment each of the types declared in typeSym is declared in
+func (i *Interpreter) declare(typeSym types.TypeSymbol, env *environment) {
+	t := typeSym.Type()
+	if t == nil {
+		return
+	}
+	t.TypeScope().ForEach(func(sym symbols.Symbol) bool {
+		element := i.context.Definitions[sym]
+		if element != nil {
+			i.members[element] = true
+		}
+		nested, ok := sym.(types.TypeSymbol)
+		if ok && nested.Type() != nil && nested.Type().Container() == typeSym {
+			i.declared[nested] = env
+			i.declare(nested, i.typeEnvironment(nested, env))
+		}
+		return false
+	})
+	for _, member := range t.Members() {
+		if element := i.context.Definitions[member]; element != nil {
+			i.members[element] = true
+		}
+	}
+}
+
+// typeEnvironment is the environment used to evaluate the type members of typeSym that do
+// not refer to this
+func (i *Interpreter) typeEnvironment(typeSym types.TypeSymbol, parent *environment) *environment {
+	env, ok := i.types[typeSym]
+	if !ok {
+		env = &environment{parent: parent, typ: typeSym, members: make(map[string]Value)}
+		i.types[typeSym] = env
+	}
+	return env
+}
+
+// recordEnvironment is the environment used to evaluate the type members of a record
+func (i *Interpreter) recordEnvironment(record *Record) *environment {
+	return &environment{parent: i.declared[record.typ], this: record, typ: record.typ}
+}
+
+// Run evaluates the statements of the module. The value of a return at the top-level of
+// the module is the result. The error, if any, is an errors.Error locating the element that
+// failed.
+func (i *Interpreter) Run(element ast.Element) (result Value, err error) {
+	defer i.recover(&err)
+	for {
+		sequence, ok := element.(ast.Sequence)
+		if !ok {
+			break
+		}
+		i.eval(sequence.Left(), i.module)
+		if i.signal != nil {
+			return i.returned(), nil
+		}
+		element = sequence.Right()
+	}
+	i.eval(element, i.module)
+	return i.returned(), nil
+}
+
+// Call calls a function value, such as a lambda declared by the module, with the given
+// arguments
+func (i *Interpreter) Call(function Value, arguments ...Value) (result Value, err error) {
+	defer i.recover(&err)
+	var args []argument
+	for _, value := range arguments {
+		args = append(args, argument{value: value})
+	}
+	return i.invoke(nil, function, args), nil
+}
+
+// Lookup finds the value of a name declared by the module
+func (i *Interpreter) Lookup(name string) (result Value, ok bool, err error) {
+	defer i.recover(&err)
+	result, ok = i.lookup(i.module, name)
+	return result, ok, nil
+}
+
+func (i *Interpreter) recover(err *error) {
+	if r := recover(); r != nil {
+		e, ok := r.(*runtimeError)
+		if !ok {
+			panic(r)
+		}
+		i.signal = nil
+		*err = e.err
+	}
+}
+
+// returned clears a pending return signal and returns its value
+func (i *Interpreter) returned() Value {
+	s := i.signal
+	i.signal = nil
+	if s != nil && s.kind == returnSignal {
+		return s.value
+	}
+	return nil
+}
+
+// fail reports an error located by element. The element is nil only for an error of a function
+// defined by the host that is called by the host.
+func (i *Interpreter) fail(element ast.Element, message string, args ...interface{}) {
+	if element == nil {
+		panic(&runtimeError{err: errors.NewAt(-1, -1, message, args...)})
+	}
+	panic(&runtimeError{err: errors.New(element, message, args...)})
+}
+
+func (i *Interpreter) lookup(env *environment, name string) (Value, bool) {
+	for e := env; e != nil; e = e.parent {
+		if value, ok := e.values[name]; ok {
+			return value, true
+		}
+		if e.this != nil {
+			if name == "this" {
+				return e.this, true
+			}
+			if value, ok := e.this.Get(name); ok {
+				return value, true
+			}
+		}
+		if e.typ != nil {
+			if value, ok := i.typeMember(e, name); ok {
+				return value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (i *Interpreter) assign(env *environment, name string, value Value) bool {
+	for e := env; e != nil; e = e.parent {
+		if _, ok := e.values[name]; ok {
+			e.values[name] = value
+			return true
+		}
+		if e.this != nil {
+			if _, ok := e.this.Get(name); ok {
+				e.this.Set(name, value)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// typeMember evaluates the type member of env's type with the given name
+func (i *Interpreter) typeMember(env *environment, name string) (Value, bool) {
+	if env.members != nil {
+		if value, ok := env.members[name]; ok {
+			return value, true
+		}
+	}
+	sym, ok := env.typ.Type().TypeScope().Find(name)
+	if !ok {
+		return nil, false
+	}
+	var result Value
+	switch s := sym.(type) {
+	case types.TypeSymbol:
+		result = &typeValue{typ: s}
+	case types.TypeMember:
+		definition, ok := i.context.Definitions[s].(ast.Definition)
+		if !ok {
+			return nil, false
+		}
+		result = i.eval(definition.Value(), env)
+	default:
+		return nil, false
+	}
+	if env.members != nil {
+		env.members[name] = result
+	}
+	return result, true
+}
+
+func (i *Interpreter) eval(element ast.Element, env *environment) Value {
+	switch n := element.(type) {
+	case ast.Sequence:
+		for {
+			i.eval(n.Left(), env)
+			if i.signal != nil {
+				return nil
+			}
+			right, ok := n.Right().(ast.Sequence)
+			if !ok {
+				return i.eval(n.Right(), env)
+			}
+			n = right
+		}
+	case ast.Literal:
+		return n.Value()
+	case ast.Name:
+		value, ok := i.lookup(env, n.Text())
+		if !ok {
+			// A name that refers to a module, or a scope of modules, selects from its type
+			if typeSym, ok := i.context.References[n].(types.TypeSymbol); ok && typeSym.Type() != nil &&
+				typeSym.Type().Kind() == types.Module {
+				return &typeValue{typ: typeSym}
+			}
+			i.fail(n, "%s is not defined", n.Text())
+		}
+		return value
+	case ast.Selection:
+		return i.selection(n, i.eval(n.Target(), env))
+	case ast.SafeSelection:
+		target := i.eval(n.Target(), env)
+		if target == nil {
+			return nil
+		}
+		return i.selection(n, target)
+	case ast.Call:
+		return i.call(n, env)
+	case ast.Instantiation:
+		// Type arguments only affect checking
+		return i.eval(n.Target(), env)
+	case ast.Lambda:
+		return &closure{lambda: n, env: env, interp: i}
+	case ast.IntrinsicLambda:
+		intrinsic, ok := i.context.Intrinsics[n]
+		if !ok {
+			i.fail(n, "Intrinsic lambda is not valid")
+		}
+		receiver, _ := i.lookup(env, "this")
+		return &intrinsicLambda{lambda: n, intrinsic: intrinsic, receiver: receiver, result: resultName(n.Result())}
+	case ast.ObjectInitializer:
+		return i.objectInitializer(n, env)
+	case ast.ArrayInitializer:
+		array := &Array{}
+		for _, item := range n.Elements() {
+			value := i.eval(item, env)
+			if i.signal != nil {
+				return nil
+			}
+			array.Elements = append(array.Elements, value)
+		}
+		return array
+	case ast.When:
+		return i.when(n, env)
+	case ast.Loop:
+		i.loop(n, env)
+	case ast.Break:
+		i.signal = &signal{kind: breakSignal, label: labelOf(n.Label())}
+	case ast.Continue:
+		i.signal = &signal{kind: continueSignal, label: labelOf(n.Label())}
+	case ast.Return:
+		var value Value
+		if n.Value() != nil {
+			value = i.eval(n.Value(), env)
+			if i.signal != nil {
+				return nil
+			}
+		}
+		i.signal = &signal{kind: returnSignal, value: value}
+	case ast.Storage:
+		var value Value
+		if n.Value() != nil {
+			value = i.eval(n.Value(), env)
+			if i.signal != nil {
+				return nil
+			}
+		} else {
+			value = zeroValue(n.Type())
+		}
+		env.values[n.Name().Text()] = value
+	case ast.Definition:
+		if i.members[n] {
+			// Type members are evaluated when they are first referenced
+			return nil
+		}
+		env.values[n.Name().Text()] = i.eval(n.Value(), env)
+	}
+	return nil
+}
+
+func labelOf(label ast.Name) string {
+	if label == nil {
+		return ""
+	}
+	return label.Text()
+}
+
+// zeroValue is the initial value of storage declared without a value
+func zeroValue(typ ast.Element) Value {
+	if name, ok := typ.(ast.Name); ok {
+		switch name.Text() {
+		case "Boolean":
+			return false
+		case "String":
+			return ""
+		case "Int", "UInt", "Long", "ULong", "Byte", "Float", "Double":
+			return convert(0, name.Text())
+		}
+	}
+	return nil
+}
+
+// selection selects the member with the given name from target
+func (i *Interpreter) selection(selection selector, target Value) Value {
+	name := selection.Member().Text()
+	switch t := target.(type) {
+	case *Record:
+		if value, ok := t.Get(name); ok {
+			return value
+		}
+		if t.typ != nil && t.typ.Type() != nil {
+			if value, ok := i.typeMember(i.recordEnvironment(t), name); ok {
+				return value
+			}
+		}
+		// An anonymous record assigned to a record type has the type members of that type
+		if typ := i.context.Types[selection.Target()]; typ != nil && typ != t.typ && typ.Type() != nil &&
+			typ.Type().Kind() == types.Record {
+			env := &environment{parent: i.declared[typ], this: t, typ: typ}
+			if value, ok := i.typeMember(env, name); ok {
+				return value
+			}
+		}
+	case *typeValue:
+		if imported, ok := i.imports[t.typ]; ok {
+			if value, ok := imported.lookup(imported.module, name); ok {
+				return value
+			}
+			break
+		}
+		env := i.typeEnvironment(t.typ, i.declared[t.typ])
+		if value, ok := i.typeMember(env, name); ok {
+			return value
+		}
+	case *Array:
+		switch name {
+		case "size":
+			return len(t.Elements)
+		case "get", "set":
+			return &intrinsic{receiver: t, name: name, selection: selection}
+		}
+	case string:
+		if name == "size" {
+			return len(t)
+		}
+		return &intrinsic{receiver: t, name: name, selection: selection}
+	case nil:
+		i.fail(selection.Target(), "Value is not initialized")
+	default:
+		if kindOf(t) != notNumber || name == "==" || name == "!=" || name == "toString" {
+			return &intrinsic{receiver: t, name: name, selection: selection}
+		}
+	}
+	i.fail(selection.Member(), "%v does not have a member %s", target, name)
+	return nil
+}
+
+// call evaluates a call including the operators with special evaluation rules
+func (i *Interpreter) call(call ast.Call, env *environment) Value {
+	arguments := call.Arguments()
+	if selection, ok := call.Target().(ast.Selection); ok && len(arguments) == 1 {
+		name := selection.Member().Text()
+		switch name {
+		case "=":
+			value := i.eval(arguments[0], env)
+			if i.signal != nil {
+				return nil
+			}
+			i.assignTo(selection.Target(), value, env)
+			return value
+		case "&&", "||":
+			left, _ := i.eval(selection.Target(), env).(bool)
+			if i.signal != nil || left == (name == "||") {
+				return left
+			}
+			right, _ := i.eval(arguments[0], env).(bool)
+			return right
+		case "==", "!=":
+			if isNull(selection.Target()) || isNull(arguments[0]) {
+				left := i.eval(selection.Target(), env)
+				if i.signal != nil {
+					return nil
+				}
+				right := i.eval(arguments[0], env)
+				return (left == nil && right == nil) == (name == "==")
+			}
+		case "+=", "-=", "*=", "/=", "%=":
+			target := i.eval(selection.Target(), env)
+			if record, ok := target.(*Record); ok && record.typ != nil && record.typ.Type() != nil {
+				if _, ok := record.typ.Type().TypeScope().Find(name); ok {
+					break
+				}
+			}
+			operator := i.selection(selection, target)
+			if op, ok := operator.(*intrinsic); ok {
+				op.name = name[:len(name)-1]
+			} else if record, ok := target.(*Record); ok {
+				operator, _ = i.typeMember(i.recordEnvironment(record), name[:len(name)-1])
+			}
+			value := i.invoke(call, operator, i.arguments(arguments, env))
+			i.assignTo(selection.Target(), value, env)
+			return value
+		}
+	}
+	callee := i.eval(call.Target(), env)
+	if i.signal != nil {
+		return nil
+	}
+	if _, ok := call.Target().(ast.SafeSelection); ok && callee == nil {
+		// The target of the safe selection is null so the member is not called
+		return nil
+	}
+	args := i.arguments(arguments, env)
+	if i.signal != nil {
+		return nil
+	}
+	return i.invoke(call, callee, args)
+}
+
+// isNull returns true if element is a null literal
+func isNull(element ast.Element) bool {
+	literal, ok := element.(ast.Literal)
+	return ok && literal.Value() == nil
+}
+
+func (i *Interpreter) arguments(elements []ast.Element, env *environment) []argument {
+	var result []argument
+	for _, element := range elements {
+		if named, ok := element.(ast.NamedArgument); ok {
+			result = append(result, argument{name: named.Name().Text(), value: i.eval(named.Value(), env)})
+		} else {
+			result = append(result, argument{value: i.eval(element, env)})
+		}
+	}
+	return result
+}
+
+// assignTo assigns value to the variable or field referred to by target
+func (i *Interpreter) assignTo(target ast.Element, value Value, env *environment) {
+	switch t := target.(type) {
+	case ast.Name:
+		if i.assign(env, t.Text(), value) {
+			return
+		}
+	case ast.Selection:
+		record, ok := i.eval(t.Target(), env).(*Record)
+		if ok {
+			if _, ok := record.Get(t.Member().Text()); ok {
+				record.Set(t.Member().Text(), value)
+				return
+			}
+		}
+	}
+	i.fail(target, "Cannot assign to expression")
+}
+
+// invoke calls callee with the given arguments
+func (i *Interpreter) invoke(call ast.Element, callee Value, arguments []argument) Value {
+	if call == nil {
+		// Errors of a value called by the host are located by the element that created the value
+		call = elementOf(callee)
+	}
+	switch c := callee.(type) {
+	case *closure:
+		return c.interp.invokeClosure(call, c, arguments)
+	case Function:
+		result, err := c(values(arguments))
+		if err != nil {
+			i.fail(call, "%s", err)
+		}
+		return result
+	case *intrinsic:
+		if array, ok := c.receiver.(*Array); ok {
+			return i.invokeArray(call, array, c.name, values(arguments))
+		}
+		result, err := invokeIntrinsic(c.receiver, c.name, values(arguments))
+		if err != nil {
+			i.fail(call, "%s", err)
+		}
+		return result
+	case *intrinsicLambda:
+		result, err := invokeIntrinsicLambda(c, values(arguments))
+		if err != nil {
+			i.fail(call, "%s", err)
+		}
+		return result
+	}
+	i.fail(call, "%v is not callable", callee)
+	return nil
+}
+
+// elementOf returns the element that created a value that can be called, or nil for a function
+// defined by the host
+func elementOf(callee Value) ast.Element {
+	switch c := callee.(type) {
+	case *closure:
+		return c.lambda
+	case *intrinsic:
+		return c.selection
+	case *intrinsicLambda:
+		return c.lambda
+	}
+	return nil
+}
+
+func values(arguments []argument) []Value {
+	var result []Value
+	for _, argument := range arguments {
+		result = append(result, argument.value)
+	}
+	return result
+}
+
+func (i *Interpreter) invokeClosure(call ast.Element, c *closure, arguments []argument) Value {
+	env := newEnvironment(c.env)
+	parameters := c.lambda.Parameters()
+	assigned := make([]bool, len(parameters))
+	next := 0
+	for _, argument := range arguments {
+		index := -1
+		if argument.name != "" {
+			for p, parameter := range parameters {
+				if parameter.Name().Text() == argument.name {
+					index = p
+					break
+				}
+			}
+		} else {
+			for next < len(parameters) && assigned[next] {
+				next++
+			}
+			if next < len(parameters) {
+				index = next
+			}
+		}
+		if index < 0 {
+			i.fail(call, "Invalid argument")
+		}
+		assigned[index] = true
+		env.values[parameters[index].Name().Text()] = argument.value
+	}
+	for p, parameter := range parameters {
+		if assigned[p] {
+			continue
+		}
+		if parameter.Default() == nil {
+			i.fail(call, "No value given for parameter %s", parameter.Name().Text())
+		}
+		env.values[parameter.Name().Text()] = i.eval(parameter.Default(), env)
+	}
+	if c.lambda.Body() == nil {
+		return nil
+	}
+	result := i.eval(c.lambda.Body(), env)
+	if i.signal != nil {
+		return i.returned()
+	}
+	return result
+}
+
+func (i *Interpreter) invokeArray(call ast.Element, array *Array, name string, arguments []Value) Value {
+	index := int(toInt64(arguments[0]))
+	if index < 0 || index >= len(array.Elements) {
+		i.fail(call, "Index %d out of range [0..%d)", index, len(array.Elements))
+	}
+	if name == "set" {
+		array.Elements[index] = arguments[1]
+		return arguments[1]
+	}
+	return array.Elements[index]
+}
+
+// objectInitializer creates a record of the type inferred by the binder
+func (i *Interpreter) objectInitializer(initializer ast.ObjectInitializer, env *environment) Value {
+	record := NewRecord(i.context.Types[initializer])
+	for _, member := range initializer.Members() {
+		switch m := member.(type) {
+		case ast.NamedMemberInitializer:
+			record.Set(m.Name().Text(), i.eval(m.Value(), env))
+		case ast.Spread:
+			source, ok := i.eval(m.Target(), env).(*Record)
+			if !ok {
+				i.fail(m, "Expected a record")
+			}
+			for _, name := range source.names {
+				record.Set(name, source.fields[name])
+			}
+		}
+		if i.signal != nil {
+			return nil
+		}
+	}
+	if record.typ == nil || record.typ.Type() == nil {
+		return record
+	}
+	for _, member := range record.typ.Type().Members() {
+		if _, ok := record.Get(member.Name()); ok {
+			continue
+		}
+		if storage, ok := i.context.Definitions[member].(ast.Storage); ok && storage.Value() != nil {
+			record.Set(member.Name(), i.eval(storage.Value(), i.recordEnvironment(record)))
+		}
+	}
+	return record
+}
+
+// when evaluates the body of the first clause that matches
+func (i *Interpreter) when(when ast.When, env *environment) Value {
+	var target Value
+	if when.Target() != nil {
+		target = i.eval(when.Target(), env)
+		if i.signal != nil {
+			return nil
+		}
+	}
+	for _, clause := range when.Clauses() {
+		switch c := clause.(type) {
+		case ast.WhenValueClause:
+			value := i.eval(c.Value(), env)
+			if i.signal != nil {
+				return nil
+			}
+			var matched bool
+			if when.Target() == nil {
+				matched, _ = value.(bool)
+			} else {
+				equal, err := binary(target, "==", value)
+				matched = err == nil && equal == true
+			}
+			if matched {
+				return i.body(c.Body(), env)
+			}
+		case ast.WhenElseClause:
+			return i.body(c.Body(), env)
+		}
+	}
+	return nil
+}
+
+// body evaluates a block in a new environment
+func (i *Interpreter) body(body ast.Element, env *environment) Value {
+	if body == nil {
+		return nil
+	}
+	return i.eval(body, newEnvironment(env))
+}
+
+// loop evaluates the body of a loop until a break or return
+func (i *Interpreter) loop(loop ast.Loop, env *environment) {
+	label := labelOf(loop.Label())
+	for {
+		i.body(loop.Body(), env)
+		s := i.signal
+		if s == nil {
+			continue
+		}
+		if s.kind == returnSignal || (s.label != "" && s.label != label) {
+			return
+		}
+		i.signal = nil
+		if s.kind == breakSignal {
+			return
+		}
+	}
+}
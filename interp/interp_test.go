@@ -0,0 +1,218 @@
+package interp_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"dyego0/binder"
+	"dyego0/errors"
+	"dyego0/interp"
+	"dyego0/parser"
+	"dyego0/scanner"
+	"dyego0/tokens"
+	"dyego0/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Interpreter", func() {
+	load := func(text string) (*interp.Interpreter, func() (interp.Value, error), *bytes.Buffer) {
+		text = "...Dyego0\n" + text
+		fb := tokens.NewFileSet().BuildFile("test", len(text))
+		p := parser.NewParser(scanner.NewScanner(append([]byte(text), 0), 0, fb), parser.DefaultVocabularyScope())
+		element := p.Parse()
+		fb.Build()
+		Expect(p.Errors()).To(BeNil())
+		context := binder.NewContextIn(interp.Prelude())
+		module := types.NewTypeSymbol("m", nil)
+		context.Enter(element)
+		context.Build(module, element)
+		context.Check(module, element)
+		var messages []string
+		for _, err := range context.Errors {
+			messages = append(messages, err.Error())
+		}
+		Expect(messages).To(BeNil())
+		out := &bytes.Buffer{}
+		i := interp.New(context, module, out)
+		return i, func() (interp.Value, error) { return i.Run(element) }, out
+	}
+	run := func(text string) interp.Value {
+		_, run, _ := load(text)
+		result, err := run()
+		Expect(err).To(BeNil())
+		return result
+	}
+	fail := func(text, message string) {
+		_, run, _ := load(text)
+		_, err := run()
+		Expect(err).To(Not(BeNil()))
+		Expect(err.Error()).To(Equal(message))
+	}
+	It("can evaluate arithmetic", func() {
+		Expect(run("return 1 + 2 * 3")).To(Equal(7))
+		Expect(run("return 7 / 2 - -1")).To(Equal(4))
+		Expect(run("return 1.5 * 2.0")).To(Equal(3.0))
+		Expect(run("return 10ul % 4ul")).To(Equal(uint64(2)))
+		Expect(run("return (3.7).floor()")).To(Equal(3))
+		Expect(run("return (2).toDouble().sqrt() * (2.0).sqrt()")).To(BeNumerically("~", 2.0, 1e-9))
+	})
+	It("can evaluate comparisons and logical operators", func() {
+		Expect(run("return 1 < 2 && 2 <= 2")).To(Equal(true))
+		Expect(run("return 1 > 2 || 2 != 2")).To(Equal(false))
+	})
+	It("can update variables", func() {
+		Expect(run("var a = 1\na = a + 1\na += 3\nreturn a")).To(Equal(5))
+	})
+	It("can evaluate loops", func() {
+		Expect(run("var i = 0\nvar sum = 0\nwhile (i < 10) {\n  i += 1\n  if (i == 3) { continue }\n  sum += i\n}\nreturn sum")).To(Equal(52))
+		Expect(run("var i = 0\nloop {\n  i += 1\n  if (i == 4) { break }\n}\nreturn i")).To(Equal(4))
+	})
+	It("can break out of a labeled loop", func() {
+		Expect(run("var n = 0\nloop outer {\n  loop {\n    n += 1\n    if (n > 2) { break outer }\n  }\n}\nreturn n")).To(Equal(3))
+	})
+	It("can evaluate a when", func() {
+		source := "let sign = { a: Int -> when {\n  a < 0 -> { -1 }\n  a > 0 -> { 1 }\n  else -> { 0 }\n} }\n"
+		Expect(run(source + "return sign(-5)")).To(Equal(-1))
+		Expect(run(source + "return sign(0)")).To(Equal(0))
+	})
+	It("can call recursive lambdas", func() {
+		Expect(run("let fib = { n: Int -> if (n < 2) { return n }\n  fib(n - 1) + fib(n - 2) }: Int\nreturn fib(15)")).To(Equal(610))
+	})
+	It("can call generic lambdas", func() {
+		Expect(run("let id = { X | x: X -> x }: X\nreturn id(<Int> 2) + id(3)")).To(Equal(5))
+	})
+	It("can compare values with null", func() {
+		source := "let f = { a: Int? -> if (a != null) { return a + 1 }\n  0 }: Int\n"
+		Expect(run(source + "return f(1) + f(null)")).To(Equal(2))
+		Expect(run("var a: Int?\nreturn a == null")).To(Equal(true))
+	})
+	It("can select members of values that can be null with ?.", func() {
+		source := "let P = < x: Int >\nlet f = { p: P? -> p?.x }: Int?\n"
+		Expect(run(source + "return f(null) == null")).To(Equal(true))
+		Expect(run(source + "val p: P = [x: 2]\nreturn f(p)")).To(Equal(2))
+		source = "var count = 0\nlet inc = { -> count += 1\n  1 }: Int\nval a: Int? = null\nval b: Int? = 2\n"
+		Expect(run(source + "val c = a?.`+`(inc())\nval d = b?.`+`(inc())\nreturn count")).To(Equal(1))
+		Expect(run(source + "return a?.`+`(inc()) == null")).To(Equal(true))
+		Expect(run(source + "return b?.`+`(inc())")).To(Equal(3))
+	})
+	It("can call values of callable types", func() {
+		Expect(run("let F = < { x: Int -> Int } >\nlet apply = { f: F, x: Int -> f(x) }: Int\n" +
+			"return apply({ x -> x * 2 }, 3)")).To(Equal(6))
+	})
+	It("can capture variables in closures", func() {
+		Expect(run("var count = 0\nlet inc = { by: Int -> count += by }\ninc(2)\ninc(by: 3)\nreturn count")).To(Equal(5))
+	})
+	It("can call the methods of records", func() {
+		source := "let Point = <\n  x: Int\n  y: Int\n  let `+` = { other: Point -> [:x + other.x, :y + other.y] }: Point\n" +
+			"  let sum = { x + y }\n>\n"
+		Expect(run(source + "val p: Point = [x: 1, y: 2]\nval q: Point = [x: 3, y: 4]\nreturn (p + q).sum()")).To(Equal(10))
+	})
+	It("can call the methods of an anonymous record assigned to a record type", func() {
+		source := "let Point = <\n  x: Int\n  y: Int\n  let sum = { x + y }\n>\n"
+		Expect(run(source + "val a = [x: 1, y: 2]\nval p: Point = a\nreturn p.sum()")).To(Equal(3))
+	})
+	It("can update the fields of records", func() {
+		Expect(run("let C = < var n: Int >\nval c: C = [n: 1]\nc.n = c.n + 1\nreturn c.n")).To(Equal(2))
+	})
+	It("can use defaults of record members", func() {
+		Expect(run("let C = < n: Int = 3 >\nval c: C = []\nreturn c.n")).To(Equal(3))
+	})
+	It("can use the members of spread and composed types", func() {
+		source := "let Sized = < size: Int = 2\n  let twice = { size + size } >\nlet Named = < name: Int >\n" +
+			"let Box = < ...Sized, width: Int >\n"
+		Expect(run(source + "val b: Box = [width: 3]\nreturn b.twice() + b.width")).To(Equal(7))
+		Expect(run(source + "val n: Sized & Named = [name: 1]\nreturn n.size + n.name")).To(Equal(3))
+		Expect(run(source + "val n: Sized & Named = [name: 1]\nval s: Sized = n\nval b: Box = [width: 1]\n" +
+			"val t: Sized = b\nreturn s.twice() + t.twice()")).To(Equal(8))
+	})
+	It("can index arrays", func() {
+		Expect(run("val a = [1, 2, 3]\na[1] = 20\nreturn a[0] + a[1] + a.size")).To(Equal(24))
+	})
+	It("reports an index out of range", func() {
+		fail("val a = [1, 2, 3]\nreturn a[3]", "Index 3 out of range [0..3)")
+	})
+	It("wraps Int arithmetic to 32 bits", func() {
+		Expect(run("var x = 2147483647\nx = x + 1\nreturn x")).To(Equal(-2147483648))
+		Expect(run("val x = 65536\nreturn x * x + 1")).To(Equal(1))
+	})
+	It("reports division by zero", func() {
+		fail("val a = 0\nreturn 1 / a", "Division by zero")
+	})
+	It("can evaluate intrinsic lambdas", func() {
+		math := "let inst = <\n  let i32 = <\n    let div_s = 0x6Dub\n  >\n" +
+			"  let f64 = <\n    let lt = 0x63ub\n    let add = 0xA0ub\n  >\n>\n" +
+			"let Math = <\n" +
+			"  let add = {! a: Double, b: Double -> inst.f64.add !}: Double\n" +
+			"  let less = {! a: Double, b: Double -> inst.f64.lt !}: Boolean\n" +
+			"  let div = {! a: Int, b: Int -> inst.i32.div_s !}: Int\n" +
+			">\n"
+		Expect(run(math + "return Math.add(1.5, 2.0)")).To(Equal(3.5))
+		Expect(run(math + "return Math.less(1.5, 2.0)")).To(Equal(true))
+		Expect(run(math + "return Math.div(-7, 2)")).To(Equal(-3))
+		fail(math+"return Math.div(1, 0)", "Division by zero")
+	})
+	It("can print", func() {
+		_, run, out := load("print(\"a\" + (1).toString())\nprint(\"b\")")
+		_, err := run()
+		Expect(err).To(BeNil())
+		Expect(out.String()).To(Equal("a1b"))
+	})
+	It("can run the ray tracer", func() {
+		text, err := ioutil.ReadFile("testdata/raytrace.dg")
+		Expect(err).To(BeNil())
+		_, run, out := load(strings.TrimPrefix(string(text), "...Dyego0\n"))
+		_, err = run()
+		Expect(err).To(BeNil())
+		Expect(out.String()).To(ContainSubstring("****"))
+	})
+	It("can call a lambda from the host", func() {
+		i, run, _ := load("let double = { a: Int -> a * 2 }")
+		_, err := run()
+		Expect(err).To(BeNil())
+		f, ok, err := i.Lookup("double")
+		Expect(err).To(BeNil())
+		Expect(ok).To(BeTrue())
+		Expect(i.Call(f, 21)).To(Equal(42))
+	})
+	It("locates the errors of lambdas called from the host", func() {
+		source := "let inst = <\n  let i32 = <\n    let div_s = 0x6Dub\n  >\n>\n" +
+			"let Math = <\n  let div = {! a: Int, b: Int -> inst.i32.div_s !}: Int\n>\n" +
+			"val div = Math.div\nlet twice = { a: Int -> a * 2 }"
+		i, run, _ := load(source)
+		_, err := run()
+		Expect(err).To(BeNil())
+		expectAt := func(err error, message, at string) {
+			Expect(err).To(Not(BeNil()))
+			Expect(err.Error()).To(Equal(message))
+			located := err.(errors.Error)
+			text := "...Dyego0\n" + source
+			Expect(text[located.Start():located.End()]).To(Equal(at))
+		}
+		div, _, _ := i.Lookup("div")
+		_, err = i.Call(div, 1, 0)
+		expectAt(err, "Division by zero", "{! a: Int, b: Int -> inst.i32.div_s !}: Int")
+		twice, _, _ := i.Lookup("twice")
+		_, err = i.Call(twice)
+		expectAt(err, "No value given for parameter a", "{ a: Int -> a * 2 }")
+	})
+	It("can call a host function", func() {
+		i, run, _ := load("print(\"x\")")
+		var printed []interp.Value
+		i.Define("print", interp.Function(func(arguments []interp.Value) (interp.Value, error) {
+			printed = append(printed, arguments...)
+			return nil, nil
+		}))
+		_, err := run()
+		Expect(err).To(BeNil())
+		Expect(printed).To(Equal([]interp.Value{"x"}))
+	})
+})
+
+func TestInterp(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Interp Suite")
+}
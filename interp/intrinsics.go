@@ -0,0 +1,364 @@
+package interp
+
+import (
+	"fmt"
+	"math"
+
+	"dyego0/ast"
+)
+
+// numberKind is the kind of arithmetic used for a primitive value
+type numberKind int
+
+const (
+	notNumber numberKind = iota
+	signed
+	unsigned
+	float
+)
+
+func kindOf(value Value) numberKind {
+	switch value.(type) {
+	case int, int32, int64:
+		return signed
+	case uint, uint32, uint64, byte:
+		return unsigned
+	case float32, float64:
+		return float
+	}
+	return notNumber
+}
+
+func toInt64(value Value) int64 {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case uint:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case byte:
+		return int64(v)
+	case float32:
+		return int64(v)
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+func toUint64(value Value) uint64 {
+	switch v := value.(type) {
+	case uint:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case uint64:
+		return v
+	case byte:
+		return uint64(v)
+	}
+	return uint64(toInt64(value))
+}
+
+func toFloat64(value Value) float64 {
+	switch v := value.(type) {
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	case uint, uint32, uint64, byte:
+		return float64(toUint64(v))
+	}
+	return float64(toInt64(value))
+}
+
+// like converts a result computed as an int64, uint64 or float64 back to the Go type of
+// template. Int and UInt values are 32 bits and wrap like they do in WebAssembly.
+func like(template Value, signedValue int64, unsignedValue uint64, floatValue float64) Value {
+	switch template.(type) {
+	case int:
+		return int(int32(signedValue))
+	case int32:
+		return int32(signedValue)
+	case int64:
+		return signedValue
+	case uint:
+		return uint(uint32(unsignedValue))
+	case uint32:
+		return uint32(unsignedValue)
+	case uint64:
+		return unsignedValue
+	case byte:
+		return byte(unsignedValue)
+	case float32:
+		return float32(floatValue)
+	}
+	return floatValue
+}
+
+// convert converts a numeric value to the representation of the named type
+func convert(value Value, typeName string) Value {
+	var template Value
+	switch typeName {
+	case "Byte":
+		template = byte(0)
+	case "Int":
+		template = 0
+	case "UInt":
+		template = uint(0)
+	case "Long":
+		template = int64(0)
+	case "ULong":
+		template = uint64(0)
+	case "Float":
+		template = float32(0)
+	default:
+		template = float64(0)
+	}
+	switch kindOf(value) {
+	case float:
+		f := toFloat64(value)
+		return like(template, int64(f), uint64(f), f)
+	case unsigned:
+		u := toUint64(value)
+		return like(template, int64(u), u, float64(u))
+	}
+	i := toInt64(value)
+	return like(template, i, uint64(i), float64(i))
+}
+
+// invokeIntrinsic invokes the member of a primitive value with the given name
+func invokeIntrinsic(receiver Value, name string, arguments []Value) (Value, error) {
+	switch len(arguments) {
+	case 0:
+		return unary(receiver, name)
+	case 1:
+		return binary(receiver, name, arguments[0])
+	}
+	return nil, fmt.Errorf("Invalid call to %s", name)
+}
+
+func unary(receiver Value, name string) (Value, error) {
+	if name == "toString" {
+		return fmt.Sprint(receiver), nil
+	}
+	kind := kindOf(receiver)
+	if kind != notNumber {
+		if typeName, ok := conversions[name]; ok {
+			return convert(receiver, typeName), nil
+		}
+	}
+	switch kind {
+	case signed:
+		i := toInt64(receiver)
+		switch name {
+		case "+":
+			return receiver, nil
+		case "-":
+			return like(receiver, -i, 0, 0), nil
+		}
+	case unsigned:
+		u := toUint64(receiver)
+		switch name {
+		case "+":
+			return receiver, nil
+		case "-":
+			return like(receiver, 0, -u, 0), nil
+		}
+	case float:
+		f := toFloat64(receiver)
+		switch name {
+		case "+":
+			return receiver, nil
+		case "-":
+			return like(receiver, 0, 0, -f), nil
+		case "sqrt":
+			return like(receiver, 0, 0, math.Sqrt(f)), nil
+		case "floor":
+			return int(math.Floor(f)), nil
+		case "ceil":
+			return int(math.Ceil(f)), nil
+		}
+	}
+	return nil, fmt.Errorf("Unsupported operation %s on %v", name, receiver)
+}
+
+func binary(receiver Value, name string, other Value) (Value, error) {
+	switch name {
+	case "==", "!=":
+		equal := receiver == other
+		if kind := kindOf(receiver); kind != notNumber && kind == kindOf(other) {
+			equal = toInt64(receiver) == toInt64(other) && toFloat64(receiver) == toFloat64(other)
+		}
+		return equal == (name == "=="), nil
+	}
+	if s, ok := receiver.(string); ok && name == "+" {
+		if o, ok := other.(string); ok {
+			return s + o, nil
+		}
+	}
+	kind := kindOf(receiver)
+	if kind == notNumber || kindOf(other) != kind {
+		return nil, fmt.Errorf("Unsupported operation %s on %v and %v", name, receiver, other)
+	}
+	switch kind {
+	case signed:
+		a, b := toInt64(receiver), toInt64(other)
+		switch name {
+		case "+":
+			return like(receiver, a+b, 0, 0), nil
+		case "-":
+			return like(receiver, a-b, 0, 0), nil
+		case "*":
+			return like(receiver, a*b, 0, 0), nil
+		case "/", "%":
+			if b == 0 {
+				return nil, fmt.Errorf("Division by zero")
+			}
+			if name == "/" {
+				return like(receiver, a/b, 0, 0), nil
+			}
+			return like(receiver, a%b, 0, 0), nil
+		}
+		return compare(name, a < b, a > b)
+	case unsigned:
+		a, b := toUint64(receiver), toUint64(other)
+		switch name {
+		case "+":
+			return like(receiver, 0, a+b, 0), nil
+		case "-":
+			return like(receiver, 0, a-b, 0), nil
+		case "*":
+			return like(receiver, 0, a*b, 0), nil
+		case "/", "%":
+			if b == 0 {
+				return nil, fmt.Errorf("Division by zero")
+			}
+			if name == "/" {
+				return like(receiver, 0, a/b, 0), nil
+			}
+			return like(receiver, 0, a%b, 0), nil
+		}
+		return compare(name, a < b, a > b)
+	}
+	a, b := toFloat64(receiver), toFloat64(other)
+	switch name {
+	case "+":
+		return like(receiver, 0, 0, a+b), nil
+	case "-":
+		return like(receiver, 0, 0, a-b), nil
+	case "*":
+		return like(receiver, 0, 0, a*b), nil
+	case "/":
+		return like(receiver, 0, 0, a/b), nil
+	case "%":
+		return like(receiver, 0, 0, math.Mod(a, b)), nil
+	}
+	return compare(name, a < b, a > b)
+}
+
+func compare(name string, less, greater bool) (Value, error) {
+	switch name {
+	case "<":
+		return less, nil
+	case ">":
+		return greater, nil
+	case "<=":
+		return !greater, nil
+	case ">=":
+		return !less, nil
+	}
+	return nil, fmt.Errorf("Unsupported operation %s", name)
+}
+
+// resultName is the name of the result type of an intrinsic lambda
+func resultName(result ast.Element) string {
+	switch r := result.(type) {
+	case ast.Name:
+		return r.Text()
+	case ast.Selection:
+		return r.Member().Text()
+	}
+	return ""
+}
+
+// invokeIntrinsicLambda evaluates the instructions of an intrinsic lambda
+func invokeIntrinsicLambda(lambda *intrinsicLambda, arguments []Value) (Value, error) {
+	var operands []interface{}
+	if lambda.receiver != nil {
+		arguments = append([]Value{lambda.receiver}, arguments...)
+	}
+	for _, argument := range arguments {
+		operand, ok := toOperand(argument)
+		if !ok {
+			return nil, fmt.Errorf("%v cannot be an operand of an intrinsic", argument)
+		}
+		operands = append(operands, operand)
+	}
+	results, err := lambda.intrinsic.Evaluate(operands)
+	if err != nil {
+		return nil, err
+	}
+	switch len(results) {
+	case 0:
+		return nil, nil
+	case 1:
+		return fromOperand(results[0], lambda.result), nil
+	}
+	return nil, fmt.Errorf("Intrinsic produced %d values", len(results))
+}
+
+// toOperand converts a primitive value to the value of the corresponding WebAssembly type
+func toOperand(value Value) (interface{}, bool) {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return int32(1), true
+		}
+		return int32(0), true
+	case byte:
+		return int32(v), true
+	case int:
+		return int32(v), true
+	case uint:
+		return int32(uint32(v)), true
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), true
+	case float32:
+		return v, true
+	case float64:
+		return v, true
+	}
+	return nil, false
+}
+
+// fromOperand converts the value produced by an intrinsic to a value of the named type
+func fromOperand(operand interface{}, typeName string) Value {
+	switch v := operand.(type) {
+	case int32:
+		switch typeName {
+		case "Boolean":
+			return v != 0
+		case "Byte":
+			return byte(v)
+		case "UInt":
+			return uint(uint32(v))
+		}
+		return int(v)
+	case int64:
+		if typeName == "ULong" {
+			return uint64(v)
+		}
+		return v
+	}
+	return operand
+}
@@ -0,0 +1,117 @@
+package interp
+
+import (
+	"dyego0/symbols"
+	"dyego0/types"
+)
+
+// primitive describes a type whose values are represented directly by a Go value
+type primitive struct {
+	name    string
+	numeric bool
+	float   bool
+}
+
+var primitives = []primitive{
+	{name: "Boolean"},
+	{name: "Byte", numeric: true},
+	{name: "Int", numeric: true},
+	{name: "UInt", numeric: true},
+	{name: "Long", numeric: true},
+	{name: "ULong", numeric: true},
+	{name: "Float", numeric: true, float: true},
+	{name: "Double", numeric: true, float: true},
+	{name: "String"},
+}
+
+// conversions maps the names of the conversion members of numeric types to the type they
+// convert to
+var conversions = map[string]string{
+	"toByte":   "Byte",
+	"toInt":    "Int",
+	"toUInt":   "UInt",
+	"toLong":   "Long",
+	"toULong":  "ULong",
+	"toFloat":  "Float",
+	"toDouble": "Double",
+}
+
+type preludeBuilder struct {
+	types map[string]types.TypeSymbol
+}
+
+func (b *preludeBuilder) parameter(name, typeName string) types.Parameter {
+	return types.NewParameter(name, b.types[typeName])
+}
+
+func (b *preludeBuilder) signature(result string, parameters ...types.Parameter) types.Signature {
+	var resultType types.TypeSymbol
+	if result != "" {
+		resultType = b.types[result]
+	}
+	return types.NewSignature(nil, parameters, resultType)
+}
+
+func (b *preludeBuilder) member(scope symbols.ScopeBuilder, name string, signatures ...types.Signature) {
+	sym := types.NewTypeSymbol("", nil)
+	types.NewType(sym, types.Record, nil, nil, nil, signatures, nil)
+	scope.Enter(types.NewTypeMember(name, sym))
+}
+
+func (b *preludeBuilder) build(p primitive) {
+	typeScope := symbols.NewBuilder()
+	var members []types.Member
+	name := p.name
+	other := func() types.Parameter { return b.parameter("other", name) }
+	comparison := func(op string) {
+		b.member(typeScope, op, b.signature("Boolean", other()))
+	}
+	comparison("==")
+	comparison("!=")
+	if name != "String" {
+		b.member(typeScope, "toString", b.signature("String"))
+	}
+	switch {
+	case p.numeric:
+		for _, op := range []string{"+", "-"} {
+			b.member(typeScope, op, b.signature(name, other()), b.signature(name))
+		}
+		for _, op := range []string{"*", "/", "%"} {
+			b.member(typeScope, op, b.signature(name, other()))
+		}
+		for _, op := range []string{"<", ">", "<=", ">="} {
+			comparison(op)
+		}
+		for conversion, result := range conversions {
+			b.member(typeScope, conversion, b.signature(result))
+		}
+		if p.float {
+			b.member(typeScope, "sqrt", b.signature(name))
+			b.member(typeScope, "floor", b.signature("Int"))
+			b.member(typeScope, "ceil", b.signature("Int"))
+		}
+	case name == "String":
+		b.member(typeScope, "+", b.signature(name, other()))
+		members = append(members, types.NewField("size", b.types["Int"], false))
+	}
+	types.NewType(b.types[name], types.Record, members, nil, typeScope.Build(), nil, nil)
+}
+
+// Prelude returns a scope that declares the types and functions the interpreter provides to
+// the programs it runs. Modules bound with the prelude as their outer scope can be run by the
+// interpreter.
+func Prelude() symbols.Scope {
+	b := &preludeBuilder{types: make(map[string]types.TypeSymbol)}
+	for _, p := range primitives {
+		b.types[p.name] = types.NewTypeSymbol(p.name, nil)
+	}
+	for _, p := range primitives {
+		b.build(p)
+	}
+	scope := symbols.NewBuilder()
+	for _, p := range primitives {
+		scope.Enter(b.types[p.name])
+	}
+	b.member(scope, "print", b.signature("", b.parameter("value", "String")))
+	return scope.Build()
+}
@@ -0,0 +1,130 @@
+package interp
+
+import (
+	"fmt"
+	"strings"
+
+	"dyego0/ast"
+	"dyego0/intrinsics"
+	"dyego0/types"
+)
+
+// Value is a value produced by a program. Values of primitive types, such as Int or Double,
+// are represented by the Go value of the corresponding literal, such as int or float64.
+type Value interface{}
+
+// Function is a function provided by the host that can be called by a program
+type Function func(arguments []Value) (Value, error)
+
+// Record is the value of an object initializer
+type Record struct {
+	typ    types.TypeSymbol
+	names  []string
+	fields map[string]Value
+}
+
+// NewRecord creates a record of the given type
+func NewRecord(typ types.TypeSymbol) *Record {
+	return &Record{typ: typ, fields: make(map[string]Value)}
+}
+
+// Type is the type of the record
+func (r *Record) Type() types.TypeSymbol {
+	return r.typ
+}
+
+// Get returns the value of the field with the given name
+func (r *Record) Get(name string) (Value, bool) {
+	value, ok := r.fields[name]
+	return value, ok
+}
+
+// Set sets the value of the field with the given name
+func (r *Record) Set(name string, value Value) {
+	if _, ok := r.fields[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.fields[name] = value
+}
+
+func (r *Record) String() string {
+	var fields []string
+	for _, name := range r.names {
+		fields = append(fields, fmt.Sprintf("%s: %v", name, r.fields[name]))
+	}
+	return "[" + strings.Join(fields, ", ") + "]"
+}
+
+// Array is the value of an array initializer
+type Array struct {
+	Elements []Value
+}
+
+func (a *Array) String() string {
+	var elements []string
+	for _, element := range a.Elements {
+		elements = append(elements, fmt.Sprintf("%v", element))
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// closure is the value of a lambda. A closure is invoked by the interpreter of the module that
+// declares the lambda.
+type closure struct {
+	lambda ast.Lambda
+	env    *environment
+	interp *Interpreter
+}
+
+func (c *closure) String() string {
+	return "<lambda>"
+}
+
+// typeValue is the value of a name that refers to a type. Type members can be selected from
+// a type value.
+type typeValue struct {
+	typ types.TypeSymbol
+}
+
+func (t *typeValue) String() string {
+	return t.typ.String()
+}
+
+// intrinsic is a member of a primitive value, such as Int.+, selected from the value
+type intrinsic struct {
+	receiver Value
+	name     string
+
+	// selection is the selection of the member
+	selection selector
+}
+
+// selector is a selection of a member, either a selection or a safe selection
+type selector interface {
+	ast.Element
+	Target() ast.Element
+	Member() ast.Name
+}
+
+func (i *intrinsic) String() string {
+	return fmt.Sprintf("<intrinsic %s>", i.name)
+}
+
+// intrinsicLambda is the value of an intrinsic lambda. The instructions of the lambda are
+// evaluated with the receiver, if any, followed by the arguments as operands.
+type intrinsicLambda struct {
+	lambda    ast.IntrinsicLambda
+	intrinsic *intrinsics.Intrinsic
+	receiver  Value
+	result    string
+}
+
+func (i *intrinsicLambda) String() string {
+	return "<intrinsic lambda>"
+}
+
+// argument is the value of an argument of a call
+type argument struct {
+	name  string
+	value Value
+}
@@ -0,0 +1,115 @@
+package intrinsics
+
+import (
+	"dyego0/ast"
+	"dyego0/symbols"
+	"dyego0/types"
+	"dyego0/wasm"
+)
+
+// maxConstantDepth limits the number of type members followed to find a constant
+const maxConstantDepth = 16
+
+// Evaluator evaluates the constant values of type members, such as the opcodes declared by
+// the `inst` type of the builtins
+type Evaluator struct {
+	// Definitions are maps of symbols to the elements that declare them, typically the
+	// Definitions of the binding contexts of the modules involved
+	Definitions []map[symbols.Symbol]ast.Element
+
+	// Scope is the scope names are resolved in
+	Scope symbols.Scope
+}
+
+// Definition finds the element that declares sym
+func (e *Evaluator) Definition(sym symbols.Symbol) ast.Element {
+	for _, definitions := range e.Definitions {
+		if element, ok := definitions[sym]; ok {
+			return element
+		}
+	}
+	return nil
+}
+
+// Resolve finds the symbol referred to by a name or a qualified name
+func (e *Evaluator) Resolve(element ast.Element) (symbols.Symbol, bool) {
+	switch n := element.(type) {
+	case ast.Name:
+		return e.Scope.Find(n.Text())
+	case ast.Selection:
+		target, ok := e.Resolve(n.Target())
+		if !ok {
+			return nil, false
+		}
+		typeSym, ok := target.(types.TypeSymbol)
+		if !ok || typeSym.Type() == nil {
+			return nil, false
+		}
+		return typeSym.Type().TypeScope().Find(n.Member().Text())
+	}
+	return nil, false
+}
+
+// ConstantOf is the value of a type member declared with a constant value
+func (e *Evaluator) ConstantOf(member types.TypeMember) (interface{}, bool) {
+	return e.memberConstant(member, 0)
+}
+
+func (e *Evaluator) memberConstant(member types.TypeMember, depth int) (interface{}, bool) {
+	definition, ok := e.Definition(member).(ast.Definition)
+	if !ok || depth > maxConstantDepth {
+		return nil, false
+	}
+	return e.constant(definition.Value(), depth+1)
+}
+
+// Constant evaluates a literal or a reference to a type member with a constant value
+func (e *Evaluator) Constant(element ast.Element) (interface{}, bool) {
+	return e.constant(element, 0)
+}
+
+func (e *Evaluator) constant(element ast.Element, depth int) (interface{}, bool) {
+	if literal, ok := element.(ast.Literal); ok {
+		return literal.Value(), true
+	}
+	sym, ok := e.Resolve(element)
+	if !ok {
+		return nil, false
+	}
+	member, ok := sym.(types.TypeMember)
+	if !ok {
+		return nil, false
+	}
+	return e.memberConstant(member, depth)
+}
+
+// Property is the constant value of a type member of typeSym, such as `@fmt`
+func (e *Evaluator) Property(typeSym types.TypeSymbol, name string) (interface{}, bool) {
+	t := typeSym.Type()
+	if t == nil {
+		return nil, false
+	}
+	sym, ok := t.TypeScope().Find(name)
+	if !ok {
+		return nil, false
+	}
+	member, ok := sym.(types.TypeMember)
+	if !ok {
+		return nil, false
+	}
+	return e.ConstantOf(member)
+}
+
+// Format is the value type declared by the `@fmt` member of a primitive type
+func (e *Evaluator) Format(typeSym types.TypeSymbol) (wasm.ValueType, bool) {
+	value, ok := e.Property(typeSym, "@fmt")
+	b, isByte := value.(byte)
+	if !ok || !isByte {
+		return 0, false
+	}
+	switch format := wasm.ValueType(b); format {
+	case wasm.I32, wasm.I64, wasm.F32, wasm.F64:
+		return format, true
+	}
+	return 0, false
+}
@@ -0,0 +1,336 @@
+package intrinsics
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+)
+
+// Values are represented while evaluating intrinsics by the Go type that corresponds to their
+// value type: int32, int64, float32 and float64. Integers are signed or unsigned depending on
+// the operation applied to them.
+
+var (
+	errDivideByZero      = fmt.Errorf("Division by zero")
+	errOverflow          = fmt.Errorf("Integer overflow")
+	errInvalidConversion = fmt.Errorf("Invalid conversion to integer")
+)
+
+// apply applies an arithmetic, comparison or conversion operation to its operands
+func apply(operation *Operation, operands []interface{}) (interface{}, error) {
+	name := operation.Name
+	dot := strings.IndexByte(name, '.')
+	prefix, op := name[:dot], name[dot+1:]
+	if operation.Kind == Conversion {
+		return convert(prefix, op, operands[0])
+	}
+	switch prefix {
+	case "i32":
+		result, err := integer(op, 32, operands)
+		if operation.Kind == Comparison {
+			return int32(result), err
+		}
+		return int32(uint32(result)), err
+	case "i64":
+		result, err := integer(op, 64, operands)
+		if operation.Kind == Comparison {
+			return int32(result), err
+		}
+		return int64(result), err
+	case "f32":
+		result, err := floating(op, operands)
+		if operation.Kind == Comparison {
+			return int32(result), err
+		}
+		return float32(result), err
+	case "f64":
+		result, err := floating(op, operands)
+		if operation.Kind == Comparison {
+			return int32(result), err
+		}
+		return result, err
+	}
+	return nil, fmt.Errorf("%s cannot be evaluated", name)
+}
+
+// bitsOf is the bits of an integer operand
+func bitsOf(value interface{}) uint64 {
+	switch v := value.(type) {
+	case int32:
+		return uint64(uint32(v))
+	case int64:
+		return uint64(v)
+	}
+	return 0
+}
+
+// signed sign extends the low width bits of value
+func signed(value uint64, width uint) int64 {
+	shift := 64 - width
+	return int64(value<<shift) >> shift
+}
+
+func boolean(value bool) uint64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// integer applies an integer operation to operands of the given width
+func integer(op string, width uint, operands []interface{}) (uint64, error) {
+	a := bitsOf(operands[0])
+	if len(operands) == 1 {
+		switch op {
+		case "eqz":
+			return boolean(a == 0), nil
+		case "clz":
+			return uint64(bits.LeadingZeros64(a) - int(64-width)), nil
+		case "ctz":
+			if a == 0 {
+				return uint64(width), nil
+			}
+			return uint64(bits.TrailingZeros64(a)), nil
+		case "popcnt":
+			return uint64(bits.OnesCount64(a)), nil
+		case "extend8_s":
+			return uint64(signed(a, 8)), nil
+		case "extend16_s":
+			return uint64(signed(a, 16)), nil
+		case "extend32_s":
+			return uint64(signed(a, 32)), nil
+		}
+		return 0, fmt.Errorf("i%d.%s cannot be evaluated", width, op)
+	}
+	b := bitsOf(operands[1])
+	sa, sb := signed(a, width), signed(b, width)
+	shift := uint(b % uint64(width))
+	switch op {
+	case "eq":
+		return boolean(a == b), nil
+	case "ne":
+		return boolean(a != b), nil
+	case "lt_s":
+		return boolean(sa < sb), nil
+	case "lt_u":
+		return boolean(a < b), nil
+	case "gt_s":
+		return boolean(sa > sb), nil
+	case "gt_u":
+		return boolean(a > b), nil
+	case "le_s":
+		return boolean(sa <= sb), nil
+	case "le_u":
+		return boolean(a <= b), nil
+	case "ge_s":
+		return boolean(sa >= sb), nil
+	case "ge_u":
+		return boolean(a >= b), nil
+	case "add":
+		return a + b, nil
+	case "sub":
+		return a - b, nil
+	case "mul":
+		return a * b, nil
+	case "div_s":
+		if b == 0 {
+			return 0, errDivideByZero
+		}
+		if sb == -1 && sa == signed(1<<(width-1), width) {
+			return 0, errOverflow
+		}
+		return uint64(sa / sb), nil
+	case "div_u":
+		if b == 0 {
+			return 0, errDivideByZero
+		}
+		return a / b, nil
+	case "rem_s":
+		if b == 0 {
+			return 0, errDivideByZero
+		}
+		if sb == -1 {
+			return 0, nil
+		}
+		return uint64(sa % sb), nil
+	case "rem_u":
+		if b == 0 {
+			return 0, errDivideByZero
+		}
+		return a % b, nil
+	case "and":
+		return a & b, nil
+	case "or":
+		return a | b, nil
+	case "xor":
+		return a ^ b, nil
+	case "shl":
+		return a << shift, nil
+	case "shr_s":
+		return uint64(sa >> shift), nil
+	case "shr_u":
+		return a >> shift, nil
+	case "rotl":
+		if width == 32 {
+			return uint64(bits.RotateLeft32(uint32(a), int(shift))), nil
+		}
+		return bits.RotateLeft64(a, int(shift)), nil
+	case "rotr":
+		if width == 32 {
+			return uint64(bits.RotateLeft32(uint32(a), -int(shift))), nil
+		}
+		return bits.RotateLeft64(a, -int(shift)), nil
+	}
+	return 0, fmt.Errorf("i%d.%s cannot be evaluated", width, op)
+}
+
+// floatOf is the value of a floating point operand
+func floatOf(value interface{}) float64 {
+	switch v := value.(type) {
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+// floating applies a floating point operation. Operations on f32 are performed in float64 and
+// rounded by the caller which gives the same result for the operations WebAssembly defines.
+func floating(op string, operands []interface{}) (float64, error) {
+	a := floatOf(operands[0])
+	if len(operands) == 1 {
+		switch op {
+		case "abs":
+			return math.Abs(a), nil
+		case "neg":
+			return -a, nil
+		case "ceil":
+			return math.Ceil(a), nil
+		case "floor":
+			return math.Floor(a), nil
+		case "trunc":
+			return math.Trunc(a), nil
+		case "nearest":
+			return math.RoundToEven(a), nil
+		case "sqrt":
+			return math.Sqrt(a), nil
+		}
+		return 0, fmt.Errorf("%s cannot be evaluated", op)
+	}
+	b := floatOf(operands[1])
+	switch op {
+	case "eq":
+		return float64(boolean(a == b)), nil
+	case "ne":
+		return float64(boolean(a != b)), nil
+	case "lt":
+		return float64(boolean(a < b)), nil
+	case "gt":
+		return float64(boolean(a > b)), nil
+	case "le":
+		return float64(boolean(a <= b)), nil
+	case "ge":
+		return float64(boolean(a >= b)), nil
+	case "add":
+		return a + b, nil
+	case "sub":
+		return a - b, nil
+	case "mul":
+		return a * b, nil
+	case "div":
+		return a / b, nil
+	case "min":
+		if math.IsNaN(a) || math.IsNaN(b) {
+			return math.NaN(), nil
+		}
+		return math.Min(a, b), nil
+	case "max":
+		if math.IsNaN(a) || math.IsNaN(b) {
+			return math.NaN(), nil
+		}
+		return math.Max(a, b), nil
+	case "copysign":
+		return math.Copysign(a, b), nil
+	}
+	return 0, fmt.Errorf("%s cannot be evaluated", op)
+}
+
+// convert applies a conversion, such as f64.convert_i32_s, to an operand
+func convert(prefix, op string, operand interface{}) (interface{}, error) {
+	switch op {
+	case "wrap_i64":
+		return int32(bitsOf(operand)), nil
+	case "extend_i32_s":
+		return int64(operand.(int32)), nil
+	case "extend_i32_u":
+		return int64(uint32(operand.(int32))), nil
+	case "demote_f64":
+		return float32(floatOf(operand)), nil
+	case "promote_f32":
+		return floatOf(operand), nil
+	case "reinterpret_f32":
+		return int32(math.Float32bits(operand.(float32))), nil
+	case "reinterpret_f64":
+		return int64(math.Float64bits(operand.(float64))), nil
+	case "reinterpret_i32":
+		return math.Float32frombits(uint32(bitsOf(operand))), nil
+	case "reinterpret_i64":
+		return math.Float64frombits(bitsOf(operand)), nil
+	}
+	unsigned := strings.HasSuffix(op, "_u")
+	switch {
+	case strings.HasPrefix(op, "trunc_"):
+		return truncate(prefix, unsigned, floatOf(operand))
+	case strings.HasPrefix(op, "convert_"):
+		value := bitsOf(operand)
+		switch {
+		case !unsigned && prefix == "f32":
+			return float32(signed(value, widthOf(operand))), nil
+		case !unsigned:
+			return float64(signed(value, widthOf(operand))), nil
+		case prefix == "f32":
+			return float32(value), nil
+		}
+		return float64(value), nil
+	}
+	return nil, fmt.Errorf("%s.%s cannot be evaluated", prefix, op)
+}
+
+func widthOf(operand interface{}) uint {
+	if _, ok := operand.(int32); ok {
+		return 32
+	}
+	return 64
+}
+
+// truncate converts a float to an integer trapping if the value is not representable
+func truncate(prefix string, unsigned bool, value float64) (interface{}, error) {
+	if math.IsNaN(value) {
+		return nil, errInvalidConversion
+	}
+	value = math.Trunc(value)
+	switch {
+	case prefix == "i32" && unsigned:
+		if value < 0 || value >= 1<<32 {
+			return nil, errOverflow
+		}
+		return int32(uint32(value)), nil
+	case prefix == "i32":
+		if value < -(1<<31) || value >= 1<<31 {
+			return nil, errOverflow
+		}
+		return int32(value), nil
+	case unsigned:
+		if value < 0 || value >= 1<<64 {
+			return nil, errOverflow
+		}
+		return int64(uint64(value)), nil
+	default:
+		if value < -(1<<63) || value >= 1<<63 {
+			return nil, errOverflow
+		}
+		return int64(value), nil
+	}
+}
@@ -0,0 +1,248 @@
+package intrinsics
+
+import (
+	"fmt"
+
+	"dyego0/ast"
+	"dyego0/errors"
+	"dyego0/wasm"
+)
+
+// Instruction is an instruction of an intrinsic and the operation it performs
+type Instruction struct {
+	wasm.Instruction
+	Operation *Operation
+}
+
+// Intrinsic is the meaning of an intrinsic lambda: the instructions encoded by its body. The
+// operands of the instructions are the receiver, if the lambda is a member of a type, followed
+// by the parameters of the lambda, and the instructions leave the result of the lambda, if any,
+// on the stack.
+type Intrinsic struct {
+	// Code is the encoded instructions
+	Code         []byte
+	Instructions []Instruction
+}
+
+// Resolve evaluates the body of an intrinsic lambda to the instructions it encodes. Each
+// element of the body must be a byte constant and the bytes must encode instructions in the
+// registry.
+func (r *Registry) Resolve(e *Evaluator, lambda ast.IntrinsicLambda) (*Intrinsic, errors.Error) {
+	var code []byte
+	var elements []ast.Element
+	var err errors.Error
+	forEach(lambda.Body(), func(element ast.Element) {
+		if err != nil {
+			return
+		}
+		value, ok := e.Constant(element)
+		b, isByte := value.(byte)
+		if !ok || !isByte {
+			err = errors.New(element, "Expected an instruction")
+			return
+		}
+		code = append(code, b)
+		elements = append(elements, element)
+	})
+	if err != nil {
+		return nil, err
+	}
+	result, offset, decodeErr := r.decode(code)
+	if decodeErr != nil {
+		return nil, errors.New(elements[offset], "%s", decodeErr)
+	}
+	return result, nil
+}
+
+// Decode decodes the instructions of an intrinsic from their encoding
+func (r *Registry) Decode(code []byte) (*Intrinsic, error) {
+	result, _, err := r.decode(code)
+	return result, err
+}
+
+// decode decodes code returning the offset of the invalid instruction if code is invalid
+func (r *Registry) decode(code []byte) (*Intrinsic, int, error) {
+	result := &Intrinsic{Code: code}
+	for offset := 0; offset < len(code); {
+		instruction, length, err := wasm.DecodeInstruction(code[offset:])
+		if err != nil {
+			return nil, offset, err
+		}
+		operation, ok := r.Operation(instruction.Opcode)
+		if !ok {
+			return nil, offset, fmt.Errorf("%s cannot be used in an intrinsic", instruction.Name())
+		}
+		result.Instructions = append(result.Instructions, Instruction{Instruction: instruction, Operation: operation})
+		offset += length
+	}
+	return result, 0, nil
+}
+
+// Check checks that the instructions consume exactly the operands given by params and leave
+// exactly the values given by results on the stack
+func (i *Intrinsic) Check(params, results []wasm.ValueType) error {
+	stack := append([]wasm.ValueType{}, params...)
+	pop := func(name string, expected wasm.ValueType) (wasm.ValueType, error) {
+		if len(stack) == 0 {
+			return 0, fmt.Errorf("Expected an operand for %s", name)
+		}
+		actual := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if expected != 0 && actual != expected {
+			return 0, fmt.Errorf("Expected an operand of type %s for %s but found %s", expected, name, actual)
+		}
+		return actual, nil
+	}
+	for _, instruction := range i.Instructions {
+		operation := instruction.Operation
+		switch operation.Name {
+		case "unreachable":
+			// The stack is polymorphic after unreachable so the remaining instructions
+			// cannot be checked against the results
+			return nil
+		case "nop":
+		case "drop":
+			if _, err := pop(operation.Name, 0); err != nil {
+				return err
+			}
+		case "select":
+			if _, err := pop(operation.Name, wasm.I32); err != nil {
+				return err
+			}
+			second, err := pop(operation.Name, 0)
+			if err != nil {
+				return err
+			}
+			if _, err := pop(operation.Name, second); err != nil {
+				return err
+			}
+			stack = append(stack, second)
+		default:
+			for p := len(operation.Params) - 1; p >= 0; p-- {
+				if _, err := pop(operation.Name, operation.Params[p]); err != nil {
+					return err
+				}
+			}
+			stack = append(stack, operation.Results...)
+		}
+	}
+	if len(stack) != len(results) {
+		return fmt.Errorf("Expected the intrinsic to produce %s but it produces %s", formats(results), formats(stack))
+	}
+	for index, result := range results {
+		if stack[index] != result {
+			return fmt.Errorf("Expected the intrinsic to produce %s but it produces %s", formats(results), formats(stack))
+		}
+	}
+	return nil
+}
+
+func formats(values []wasm.ValueType) string {
+	switch len(values) {
+	case 0:
+		return "no value"
+	case 1:
+		return values[0].String()
+	}
+	result := "("
+	for index, value := range values {
+		if index > 0 {
+			result += ", "
+		}
+		result += value.String()
+	}
+	return result + ")"
+}
+
+// Evaluate performs the instructions of the intrinsic on the given operands returning the
+// values left on the stack. Operands are int32, int64, float32 or float64 values. Intrinsics
+// that access memory cannot be evaluated.
+func (i *Intrinsic) Evaluate(operands []interface{}) ([]interface{}, error) {
+	stack := append([]interface{}{}, operands...)
+	for _, instruction := range i.Instructions {
+		operation := instruction.Operation
+		switch operation.Kind {
+		case Constant:
+			stack = append(stack, instruction.Value)
+			continue
+		case Load, Store, Memory:
+			return nil, fmt.Errorf("%s cannot be evaluated", operation.Name)
+		case Parametric:
+			var err error
+			stack, err = parametric(operation.Name, stack)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		count := len(operation.Params)
+		if len(stack) < count {
+			return nil, fmt.Errorf("Expected an operand for %s", operation.Name)
+		}
+		args := stack[len(stack)-count:]
+		for index, arg := range args {
+			if formatOf(arg) != operation.Params[index] {
+				return nil, fmt.Errorf("Expected an operand of type %s for %s", operation.Params[index], operation.Name)
+			}
+		}
+		result, err := apply(operation, args)
+		if err != nil {
+			return nil, err
+		}
+		stack = append(stack[:len(stack)-count], result)
+	}
+	return stack, nil
+}
+
+// parametric performs the parametric instructions
+func parametric(name string, stack []interface{}) ([]interface{}, error) {
+	switch name {
+	case "nop":
+		return stack, nil
+	case "unreachable":
+		return nil, fmt.Errorf("Unreachable")
+	case "drop":
+		if len(stack) < 1 {
+			return nil, fmt.Errorf("Expected an operand for drop")
+		}
+		return stack[:len(stack)-1], nil
+	case "select":
+		if len(stack) < 3 {
+			return nil, fmt.Errorf("Expected an operand for select")
+		}
+		top := len(stack) - 3
+		if condition, _ := stack[top+2].(int32); condition == 0 {
+			stack[top] = stack[top+1]
+		}
+		return stack[:top+1], nil
+	}
+	return nil, fmt.Errorf("%s cannot be evaluated", name)
+}
+
+// formatOf is the value type of a value
+func formatOf(value interface{}) wasm.ValueType {
+	switch value.(type) {
+	case int32:
+		return wasm.I32
+	case int64:
+		return wasm.I64
+	case float32:
+		return wasm.F32
+	case float64:
+		return wasm.F64
+	}
+	return 0
+}
+
+// forEach calls block for each element of a sequence
+func forEach(element ast.Element, block func(element ast.Element)) {
+	for element != nil {
+		sequence, ok := element.(ast.Sequence)
+		if !ok {
+			block(element)
+			return
+		}
+		forEach(sequence.Left(), block)
+		element = sequence.Right()
+	}
+}
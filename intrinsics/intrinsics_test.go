@@ -0,0 +1,129 @@
+package intrinsics_test
+
+import (
+	"math"
+	"testing"
+
+	"dyego0/intrinsics"
+	"dyego0/wasm"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("intrinsics", func() {
+	registry := intrinsics.Standard()
+	decode := func(code ...byte) *intrinsics.Intrinsic {
+		intrinsic, err := registry.Decode(code)
+		Expect(err).To(BeNil())
+		return intrinsic
+	}
+	evaluate := func(code []byte, operands ...interface{}) interface{} {
+		results, err := decode(code...).Evaluate(operands)
+		Expect(err).To(BeNil())
+		Expect(results).To(HaveLen(1))
+		return results[0]
+	}
+	trap := func(code []byte, operands ...interface{}) string {
+		_, err := decode(code...).Evaluate(operands)
+		Expect(err).To(Not(BeNil()))
+		return err.Error()
+	}
+	It("classifies the standard operations", func() {
+		kinds := map[string]intrinsics.Kind{
+			"i32.add":           intrinsics.Arithmetic,
+			"i64.popcnt":        intrinsics.Arithmetic,
+			"f64.sqrt":          intrinsics.Arithmetic,
+			"i32.lt_s":          intrinsics.Comparison,
+			"i64.eqz":           intrinsics.Comparison,
+			"f32.ge":            intrinsics.Comparison,
+			"f64.convert_i32_s": intrinsics.Conversion,
+			"i32.wrap_i64":      intrinsics.Conversion,
+			"f32.const":         intrinsics.Constant,
+			"i32.load8_u":       intrinsics.Load,
+			"f64.store":         intrinsics.Store,
+			"memory.grow":       intrinsics.Memory,
+			"select":            intrinsics.Parametric,
+		}
+		for name, kind := range kinds {
+			operation, ok := registry.Named(name)
+			Expect(ok).To(BeTrue(), name)
+			Expect(operation.Kind).To(Equal(kind), name)
+		}
+		operation, _ := registry.Named("i32.add")
+		Expect(operation.Opcode).To(Equal(byte(0x6A)))
+		Expect(operation.Params).To(Equal([]wasm.ValueType{wasm.I32, wasm.I32}))
+		Expect(operation.Results).To(Equal([]wasm.ValueType{wasm.I32}))
+	})
+	It("excludes control and variable instructions", func() {
+		for _, name := range []string{"block", "br", "return", "call", "local.get", "global.set", "end"} {
+			_, ok := registry.Named(name)
+			Expect(ok).To(BeFalse(), name)
+		}
+		_, err := registry.Decode([]byte{0x20, 0x00})
+		Expect(err).To(MatchError("local.get cannot be used in an intrinsic"))
+	})
+	It("can register operations", func() {
+		r := intrinsics.NewRegistry()
+		r.Register(&intrinsics.Operation{Name: "i32.add", Opcode: 0x6A, Kind: intrinsics.Arithmetic})
+		_, ok := r.Operation(0x6A)
+		Expect(ok).To(BeTrue())
+		_, err := r.Decode([]byte{0x6B})
+		Expect(err).To(MatchError("i32.sub cannot be used in an intrinsic"))
+	})
+	It("can check the operands and results", func() {
+		i32, f64 := wasm.I32, wasm.F64
+		Expect(decode(0x6A).Check([]wasm.ValueType{i32, i32}, []wasm.ValueType{i32})).To(BeNil())
+		Expect(decode(0x63).Check([]wasm.ValueType{f64, f64}, []wasm.ValueType{i32})).To(BeNil())
+		Expect(decode(0xA0).Check([]wasm.ValueType{i32, f64}, []wasm.ValueType{f64})).To(
+			MatchError("Expected an operand of type f64 for f64.add but found i32"))
+		Expect(decode(0x6A).Check([]wasm.ValueType{i32}, []wasm.ValueType{i32})).To(
+			MatchError("Expected an operand for i32.add"))
+		Expect(decode(0x6A).Check([]wasm.ValueType{i32, i32}, nil)).To(
+			MatchError("Expected the intrinsic to produce no value but it produces i32"))
+		Expect(decode(0x1B).Check([]wasm.ValueType{f64, f64, i32}, []wasm.ValueType{f64})).To(BeNil())
+		Expect(decode(0x00, 0x6A).Check(nil, []wasm.ValueType{i32})).To(BeNil())
+	})
+	It("can evaluate integer operations", func() {
+		Expect(evaluate([]byte{0x6A}, int32(math.MaxInt32), int32(1))).To(Equal(int32(math.MinInt32)))
+		Expect(evaluate([]byte{0x6D}, int32(-7), int32(2))).To(Equal(int32(-3)))
+		Expect(evaluate([]byte{0x6E}, int32(-1), int32(2))).To(Equal(int32(math.MaxInt32)))
+		Expect(evaluate([]byte{0x6F}, int32(-7), int32(2))).To(Equal(int32(-1)))
+		Expect(evaluate([]byte{0x49}, int32(-1), int32(1))).To(Equal(int32(0)))
+		Expect(evaluate([]byte{0x48}, int32(-1), int32(1))).To(Equal(int32(1)))
+		Expect(evaluate([]byte{0x67}, int32(1))).To(Equal(int32(31)))
+		Expect(evaluate([]byte{0x77}, int32(1), int32(33))).To(Equal(int32(2)))
+		Expect(evaluate([]byte{0x87}, int64(-8), int64(1))).To(Equal(int64(-4)))
+		Expect(evaluate([]byte{0xC0}, int32(0x80))).To(Equal(int32(-128)))
+		Expect(evaluate([]byte{0x41, 0x7F, 0x6C}, int32(3))).To(Equal(int32(-3)))
+	})
+	It("can evaluate floating point operations", func() {
+		Expect(evaluate([]byte{0xA0}, 1.5, 2.0)).To(Equal(3.5))
+		Expect(evaluate([]byte{0x92}, float32(0.1), float32(0.2))).To(Equal(float32(0.1) + float32(0.2)))
+		Expect(evaluate([]byte{0x9E}, 2.5)).To(Equal(2.0))
+		Expect(math.IsNaN(evaluate([]byte{0xA4}, math.NaN(), 1.0).(float64))).To(BeTrue())
+		Expect(evaluate([]byte{0x61}, 1.0, 1.0)).To(Equal(int32(1)))
+	})
+	It("can evaluate conversions", func() {
+		Expect(evaluate([]byte{0xB7}, int32(-2))).To(Equal(-2.0))
+		Expect(evaluate([]byte{0xB8}, int32(-1))).To(Equal(4294967295.0))
+		Expect(evaluate([]byte{0xAA}, -2.9)).To(Equal(int32(-2)))
+		Expect(evaluate([]byte{0xA7}, int64(1<<32+5))).To(Equal(int32(5)))
+		Expect(evaluate([]byte{0xAD}, int32(-1))).To(Equal(int64(4294967295)))
+		Expect(evaluate([]byte{0xB6}, 1.5)).To(Equal(float32(1.5)))
+		Expect(evaluate([]byte{0xBD}, 1.0)).To(Equal(int64(0x3FF0000000000000)))
+	})
+	It("reports traps", func() {
+		Expect(trap([]byte{0x6D}, int32(1), int32(0))).To(Equal("Division by zero"))
+		Expect(trap([]byte{0x6D}, int32(math.MinInt32), int32(-1))).To(Equal("Integer overflow"))
+		Expect(trap([]byte{0xAA}, 3e10)).To(Equal("Integer overflow"))
+		Expect(trap([]byte{0xAA}, math.NaN())).To(Equal("Invalid conversion to integer"))
+		Expect(trap([]byte{0x28, 0x02, 0x00}, int32(0))).To(Equal("i32.load cannot be evaluated"))
+		Expect(trap([]byte{0x6A}, 1.0, 2.0)).To(Equal("Expected an operand of type i32 for i32.add"))
+	})
+})
+
+func TestIntrinsics(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Intrinsics Suite")
+}
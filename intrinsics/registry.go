@@ -0,0 +1,170 @@
+package intrinsics
+
+import (
+	"strings"
+
+	"dyego0/wasm"
+)
+
+// Kind is the kind of a primitive operation
+type Kind int
+
+const (
+	// Arithmetic is an integer or floating point arithmetic or bitwise operation
+	Arithmetic Kind = iota
+
+	// Comparison compares its operands producing an i32 that is 0 or 1
+	Comparison
+
+	// Conversion converts a value to another value type
+	Conversion
+
+	// Constant produces the value encoded in the instruction
+	Constant
+
+	// Load loads a value from memory
+	Load
+
+	// Store stores a value in memory
+	Store
+
+	// Memory queries or grows the size of memory
+	Memory
+
+	// Parametric is an operation, such as drop or select, that applies to any value type
+	Parametric
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Arithmetic:
+		return "arithmetic"
+	case Comparison:
+		return "comparison"
+	case Conversion:
+		return "conversion"
+	case Constant:
+		return "constant"
+	case Load:
+		return "load"
+	case Store:
+		return "store"
+	case Memory:
+		return "memory"
+	case Parametric:
+		return "parametric"
+	}
+	return "invalid kind"
+}
+
+// Operation is a primitive operation an intrinsic lambda can perform
+type Operation struct {
+	// Name is the name of the instruction that performs the operation, such as i32.add
+	Name   string
+	Opcode byte
+	Kind   Kind
+
+	// Params and Results are the value types the operation consumes and produces. They are
+	// nil for parametric operations.
+	Params  []wasm.ValueType
+	Results []wasm.ValueType
+}
+
+// Registry maps the instructions that can be used by intrinsic lambdas to the operations
+// they perform
+type Registry struct {
+	operations map[byte]*Operation
+	names      map[string]*Operation
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{operations: make(map[byte]*Operation), names: make(map[string]*Operation)}
+}
+
+// Register adds an operation to the registry replacing any operation with the same opcode
+func (r *Registry) Register(operation *Operation) {
+	if previous, ok := r.operations[operation.Opcode]; ok {
+		delete(r.names, previous.Name)
+	}
+	r.operations[operation.Opcode] = operation
+	r.names[operation.Name] = operation
+}
+
+// Operation finds the operation performed by the instruction with the given opcode
+func (r *Registry) Operation(opcode byte) (*Operation, bool) {
+	operation, ok := r.operations[opcode]
+	return operation, ok
+}
+
+// Named finds the operation performed by the instruction with the given name
+func (r *Registry) Named(name string) (*Operation, bool) {
+	operation, ok := r.names[name]
+	return operation, ok
+}
+
+var standard *Registry
+
+// Standard returns the registry of the WebAssembly instructions that operate only on the
+// stack or memory. Control instructions and instructions that refer to locals, globals or
+// functions cannot be used by intrinsics as intrinsics are inlined into the function that
+// calls them.
+func Standard() *Registry {
+	if standard != nil {
+		return standard
+	}
+	r := NewRegistry()
+	for code := 0; code < 256; code++ {
+		info, ok := wasm.LookupOpcode(byte(code))
+		if !ok {
+			continue
+		}
+		kind, ok := kindOf(info)
+		if !ok {
+			continue
+		}
+		r.Register(&Operation{
+			Name:    info.Name,
+			Opcode:  byte(code),
+			Kind:    kind,
+			Params:  info.Params,
+			Results: info.Results,
+		})
+	}
+	standard = r
+	return r
+}
+
+// kindOf classifies an instruction returning false if it cannot be used by an intrinsic
+func kindOf(info wasm.OpcodeInfo) (Kind, bool) {
+	name := info.Name
+	dot := strings.IndexByte(name, '.')
+	op := name[dot+1:]
+	switch {
+	case name == "drop" || name == "select" || name == "nop" || name == "unreachable":
+		return Parametric, true
+	case !info.Fixed:
+		return 0, false
+	case strings.HasPrefix(name, "memory."):
+		return Memory, true
+	case strings.HasSuffix(op, "const"):
+		return Constant, true
+	case strings.HasPrefix(op, "load"):
+		return Load, true
+	case strings.HasPrefix(op, "store"):
+		return Store, true
+	case len(info.Results) == 1 && info.Results[0] == wasm.I32 && isComparison(op):
+		return Comparison, true
+	case len(info.Params) == 1 && info.Params[0] != info.Results[0]:
+		return Conversion, true
+	}
+	return Arithmetic, true
+}
+
+func isComparison(op string) bool {
+	switch strings.TrimSuffix(strings.TrimSuffix(op, "_s"), "_u") {
+	case "eqz", "eq", "ne", "lt", "gt", "le", "ge":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,310 @@
+package lsp
+
+import (
+	"path"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"dyego0/ast"
+	"dyego0/binder"
+	"dyego0/diagnostics"
+	"dyego0/errors"
+	"dyego0/location"
+	"dyego0/parser"
+	"dyego0/scanner"
+	"dyego0/symbols"
+	"dyego0/tokens"
+	"dyego0/types"
+)
+
+// document is an open document and the result of analyzing its current text
+type document struct {
+	uri      string
+	version  int
+	text     string
+	file     tokens.File
+	element  ast.Element
+	context  *binder.BindingContext
+	module   types.TypeSymbol
+	errors   []errors.Error
+	declared map[ast.Element]symbols.Symbol
+}
+
+// analyze parses, binds and checks the text of a document. A document can refer to the modules
+// of the root scope, if any.
+func (s *Server) analyze(uri string, version int, text string) *document {
+	d := &document{uri: uri, version: version, text: text, declared: make(map[ast.Element]symbols.Symbol)}
+	var fb tokens.FileBuilder
+	if file, ok := s.files[uri]; ok {
+		// The text replaces the file so the file set does not grow with every change
+		fb = s.fileSet.EditFile(file, tokens.Edit{Start: 0, End: file.Size(), Text: text})
+	} else {
+		fb = s.fileSet.BuildFile(uri, len(text))
+	}
+	if s.loader != nil {
+		module, errs := s.loader.LoadText(moduleName(uri), uri, text, fb)
+		d.element, d.file, d.errors = module.Element, module.File, errs
+		if d.element == nil {
+			return d
+		}
+		d.context, d.module = module.Context, module.Symbol
+	} else {
+		p := parser.NewParser(scanner.NewScanner(append([]byte(text), 0), 0, fb), s.vocabulary)
+		d.element = p.Parse()
+		d.file = fb.Build()
+		d.errors = append(d.errors, p.Errors()...)
+		if d.element == nil {
+			return d
+		}
+		d.context = binder.NewContextIn(s.outer)
+		d.context.Imports = s.imports
+		d.module = types.NewTypeSymbol(moduleName(uri), nil)
+		d.context.Enter(d.element)
+		d.context.Build(d.module, d.element)
+		d.context.Check(d.module, d.element)
+		d.errors = append(d.errors, d.context.Errors...)
+	}
+	for sym, element := range d.context.Definitions {
+		if named, ok := element.(ast.NamedElement); ok && named.Name() != nil {
+			d.declared[named.Name()] = sym
+		}
+	}
+	return d
+}
+
+// moduleName is the name of the module declared by the document with the given URI
+func moduleName(uri string) string {
+	base := path.Base(uri)
+	return strings.TrimSuffix(base, path.Ext(base))
+}
+
+// Text implements diagnostics.Source
+func (d *document) Text(start, end int) string {
+	if end > len(d.text) {
+		end = len(d.text)
+	}
+	return d.text[start:end]
+}
+
+// diagnostics converts the errors reported for the document
+func (d *document) diagnostics() []Diagnostic {
+	result := []Diagnostic{}
+	for _, err := range d.errors {
+		result = append(result, Diagnostic{
+			Range:    rangeOf(d.file, d, err),
+			Severity: SeverityError,
+			Source:   "dyego",
+			Message:  err.Error(),
+		})
+	}
+	return result
+}
+
+// nameAt finds the innermost name at pos. A name is at pos if pos is in the name or
+// immediately after it.
+func (d *document) nameAt(pos location.Pos) ast.Name {
+	var result ast.Name
+	d.walk(func(element ast.Element) {
+		if name, ok := element.(ast.Name); ok && name.Start() <= pos && pos <= name.End() {
+			result = name
+		}
+	})
+	return result
+}
+
+// expressionAt finds the innermost expression with a type that contains pos
+func (d *document) expressionAt(pos location.Pos) ast.Element {
+	var result ast.Element
+	d.walk(func(element ast.Element) {
+		if element.Start() <= pos && pos < element.End() {
+			if _, ok := d.context.Types[element]; ok {
+				result = element
+			}
+		}
+	})
+	return result
+}
+
+func (d *document) walk(block func(element ast.Element)) {
+	if d.element != nil {
+		ast.Walk(d.element, visitor(block))
+	}
+}
+
+type visitor func(element ast.Element)
+
+func (v visitor) Visit(element ast.Element) bool {
+	v(element)
+	return true
+}
+
+// symbolOf is the symbol a name refers to or declares
+func (d *document) symbolOf(name ast.Name) (symbols.Symbol, bool) {
+	if d.context == nil {
+		return nil, false
+	}
+	if sym, ok := d.context.References[name]; ok {
+		return sym, true
+	}
+	sym, ok := d.declared[name]
+	return sym, ok
+}
+
+// symbols are the document symbols for the definitions of the module
+func (d *document) symbols() []DocumentSymbol {
+	result := []DocumentSymbol{}
+	forEachStatement(d.element, func(element ast.Element) {
+		if sym, ok := d.documentSymbol(element, false); ok {
+			result = append(result, sym)
+		}
+	})
+	return result
+}
+
+func (d *document) documentSymbol(element ast.Element, isMember bool) (DocumentSymbol, bool) {
+	var name ast.Name
+	var kind SymbolKind
+	var children []DocumentSymbol
+	switch n := element.(type) {
+	case ast.Definition:
+		name = n.Name()
+		switch value := n.Value().(type) {
+		case ast.TypeLiteral:
+			kind = SymbolClass
+			for _, member := range value.Members() {
+				if child, ok := d.documentSymbol(member, true); ok {
+					children = append(children, child)
+				}
+			}
+		case ast.Lambda, ast.IntrinsicLambda:
+			kind = SymbolFunction
+			if isMember {
+				kind = SymbolMethod
+			}
+		case ast.VocabularyLiteral:
+			kind = SymbolNamespace
+		default:
+			kind = SymbolConstant
+		}
+	case ast.Storage:
+		name = n.Name()
+		switch {
+		case isMember:
+			kind = SymbolField
+		case n.Mutable():
+			kind = SymbolVariable
+		default:
+			kind = SymbolConstant
+		}
+	default:
+		return DocumentSymbol{}, false
+	}
+	if name == nil {
+		return DocumentSymbol{}, false
+	}
+	result := DocumentSymbol{
+		Name:           name.Text(),
+		Kind:           kind,
+		Range:          rangeOf(d.file, d, element),
+		SelectionRange: rangeOf(d.file, d, name),
+		Children:       children,
+	}
+	if sym, ok := d.declared[name]; ok {
+		result.Detail = describeType(sym)
+	}
+	return result, true
+}
+
+// forEachStatement calls block for each element of a sequence
+func forEachStatement(element ast.Element, block func(element ast.Element)) {
+	for {
+		sequence, ok := element.(ast.Sequence)
+		if !ok {
+			break
+		}
+		forEachStatement(sequence.Left(), block)
+		element = sequence.Right()
+	}
+	if element != nil {
+		block(element)
+	}
+}
+
+// describe describes a symbol for a hover
+func describe(sym symbols.Symbol) string {
+	if _, ok := sym.(types.TypeSymbol); ok {
+		return describeType(sym)
+	}
+	return sym.Name() + ": " + describeType(sym)
+}
+
+// describeType is the display name of the type of a symbol, or of the type declared by a type
+// symbol
+func describeType(sym symbols.Symbol) string {
+	switch s := sym.(type) {
+	case types.TypeSymbol:
+		return typeName(s)
+	case types.Member:
+		return typeName(s.Type())
+	case types.Parameter:
+		return typeName(s.Type())
+	}
+	return ""
+}
+
+func typeName(typeSym types.TypeSymbol) string {
+	if typeSym == nil {
+		return ""
+	}
+	if t := typeSym.Type(); t != nil {
+		return t.DisplayName()
+	}
+	return typeSym.Name()
+}
+
+// positionOf converts pos to a position in file. Characters are counted in UTF-16 code units
+// if the source of the file is available and in bytes otherwise.
+func positionOf(file tokens.File, source diagnostics.Source, pos location.Pos) Position {
+	offset := file.Offset(pos)
+	line := file.Line(pos)
+	if line < 1 {
+		return Position{}
+	}
+	start := file.LineStart(line)
+	if start == offset && offset == file.Size() && line > 1 && source != nil &&
+		source.Text(offset-1, offset) != "\n" {
+		// The end of a file that does not end with a new line is on the last line
+		line--
+		start = file.LineStart(line)
+	}
+	character := offset - start
+	if source != nil {
+		character = len(utf16.Encode([]rune(source.Text(start, offset))))
+	}
+	return Position{Line: line - 1, Character: character}
+}
+
+// rangeOf converts the range of loc to a range in file
+func rangeOf(file tokens.File, source diagnostics.Source, loc location.Locatable) Range {
+	return Range{Start: positionOf(file, source, loc.Start()), End: positionOf(file, source, loc.End())}
+}
+
+// posOf converts a position in a document to a pos
+func (d *document) posOf(position Position) location.Pos {
+	start := d.file.LineStart(position.Line + 1)
+	end := d.file.LineStart(position.Line + 2)
+	if end <= start {
+		end = len(d.text)
+	}
+	offset := start
+	for character := 0; character < position.Character && offset < end; {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+		character += len(utf16.Encode([]rune{r}))
+		offset += size
+	}
+	return d.file.Pos(offset)
+}
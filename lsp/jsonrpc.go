@@ -0,0 +1,152 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Message is a JSON-RPC 2.0 request, response or notification. A request has an ID and a
+// Method, a notification only a Method and a response only an ID.
+type Message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *ResponseError   `json:"error,omitempty"`
+}
+
+// IsRequest returns true if the message is a request
+func (m *Message) IsRequest() bool {
+	return m.ID != nil && m.Method != ""
+}
+
+// IsNotification returns true if the message is a notification
+func (m *Message) IsNotification() bool {
+	return m.ID == nil && m.Method != ""
+}
+
+// ResponseError is the error of a response to a request that failed
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return e.Message
+}
+
+// The error codes defined by JSON-RPC and the language server protocol
+const (
+	ParseError           = -32700
+	InvalidRequest       = -32600
+	MethodNotFound       = -32601
+	InvalidParams        = -32602
+	InternalError        = -32603
+	ServerNotInitialized = -32002
+)
+
+// Conn reads and writes messages framed by a Content-Length header as used by the language
+// server protocol
+type Conn struct {
+	reader *bufio.Reader
+	writer io.Writer
+	mutex  sync.Mutex
+}
+
+// NewConn creates a connection that reads messages from r and writes them to w
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	return &Conn{reader: bufio.NewReader(r), writer: w}
+}
+
+// Read reads the next message. Returns io.EOF if there are no more messages.
+func (c *Conn) Read() (*Message, error) {
+	length := -1
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" && length < 0 {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("Invalid header '%s'", line)
+		}
+		if strings.EqualFold(strings.TrimSpace(line[:colon]), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(line[colon+1:]))
+			if err != nil || length < 0 {
+				return nil, fmt.Errorf("Invalid content length '%s'", line)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("Expected a Content-Length header")
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, content); err != nil {
+		return nil, err
+	}
+	message := &Message{}
+	if err := json.Unmarshal(content, message); err != nil {
+		return nil, &ResponseError{Code: ParseError, Message: err.Error()}
+	}
+	return message, nil
+}
+
+// Write writes a message
+func (c *Conn) Write(message *Message) error {
+	message.JSONRPC = "2.0"
+	content, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, err := fmt.Fprintf(c.writer, "Content-Length: %d\r\n\r\n", len(content)); err != nil {
+		return err
+	}
+	_, err = c.writer.Write(content)
+	return err
+}
+
+// Notify writes a notification of method with the given params
+func (c *Conn) Notify(method string, params interface{}) error {
+	content, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.Write(&Message{Method: method, Params: content})
+}
+
+// Call writes a request for method with the given id and params
+func (c *Conn) Call(id int, method string, params interface{}) error {
+	content, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	raw := json.RawMessage(strconv.Itoa(id))
+	return c.Write(&Message{ID: &raw, Method: method, Params: content})
+}
+
+// Reply writes the response to the request with the given id
+func (c *Conn) Reply(id *json.RawMessage, result interface{}, err *ResponseError) error {
+	if err != nil {
+		return c.Write(&Message{ID: id, Error: err})
+	}
+	content, marshalErr := json.Marshal(result)
+	if marshalErr != nil {
+		return c.Write(&Message{ID: id, Error: &ResponseError{Code: InternalError, Message: marshalErr.Error()}})
+	}
+	return c.Write(&Message{ID: id, Result: content})
+}
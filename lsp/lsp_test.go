@@ -0,0 +1,313 @@
+package lsp_test
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"dyego0/binder"
+	"dyego0/diagnostics"
+	"dyego0/lsp"
+	"dyego0/parser"
+	"dyego0/scanner"
+	"dyego0/tokens"
+	"dyego0/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const preludeText = "...Dyego0\nlet Boolean = < >\nlet String = < >\nlet Int = <\n" +
+	"  let `+` = {! other: Int -> !}: Int\n>\n"
+
+type sources map[string]string
+
+func (s sources) Source(fileName string) diagnostics.Source {
+	text, ok := s[fileName]
+	if !ok {
+		return nil
+	}
+	return source(text)
+}
+
+type source string
+
+func (s source) Text(start, end int) string {
+	return string(s[start:end])
+}
+
+// client is an in-process client of a server
+type client struct {
+	conn          *lsp.Conn
+	id            int
+	notifications []*lsp.Message
+	done          chan error
+}
+
+func newClient(options lsp.Options) *client {
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+	c := &client{conn: lsp.NewConn(clientReader, clientWriter), done: make(chan error, 1)}
+	go func() {
+		c.done <- lsp.NewServer(options).Serve(serverReader, serverWriter)
+		serverWriter.Close()
+	}()
+	return c
+}
+
+// call sends a request and reads messages until its response
+func (c *client) call(method string, params, result interface{}) *lsp.ResponseError {
+	c.id++
+	Expect(c.conn.Call(c.id, method, params)).To(Succeed())
+	for {
+		message, err := c.conn.Read()
+		Expect(err).To(BeNil())
+		if message.Method != "" {
+			c.notifications = append(c.notifications, message)
+			continue
+		}
+		Expect(string(*message.ID)).To(Equal(strconv.Itoa(c.id)))
+		if message.Error != nil {
+			return message.Error
+		}
+		if result != nil {
+			Expect(json.Unmarshal(message.Result, result)).To(Succeed())
+		}
+		return nil
+	}
+}
+
+// notify sends a notification
+func (c *client) notify(method string, params interface{}) {
+	Expect(c.conn.Notify(method, params)).To(Succeed())
+}
+
+// next reads the next notification
+func (c *client) next(method string, params interface{}) {
+	var message *lsp.Message
+	if len(c.notifications) > 0 {
+		message = c.notifications[0]
+		c.notifications = c.notifications[1:]
+	} else {
+		var err error
+		message, err = c.conn.Read()
+		Expect(err).To(BeNil())
+	}
+	Expect(message.Method).To(Equal(method))
+	Expect(json.Unmarshal(message.Params, params)).To(Succeed())
+}
+
+func (c *client) open(uri, text string) lsp.PublishDiagnosticsParams {
+	c.notify("textDocument/didOpen", &lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "dyego", Version: 1, Text: text},
+	})
+	var params lsp.PublishDiagnosticsParams
+	c.next("textDocument/publishDiagnostics", &params)
+	return params
+}
+
+func (c *client) exit() error {
+	Expect(c.call("shutdown", nil, nil)).To(BeNil())
+	c.notify("exit", nil)
+	return <-c.done
+}
+
+func at(line, character int) lsp.Position {
+	return lsp.Position{Line: line, Character: character}
+}
+
+func span(line, start, end int) lsp.Range {
+	return lsp.Range{Start: at(line, start), End: at(line, end)}
+}
+
+var _ = Describe("lsp", func() {
+	var options lsp.Options
+	var c *client
+	uri := "file:///work/main.dg"
+	BeforeEach(func() {
+		fileSet := tokens.NewFileSet()
+		fb := fileSet.BuildFile("/work/prelude.dg", len(preludeText))
+		p := parser.NewParser(scanner.NewScanner(append([]byte(preludeText), 0), 0, fb), parser.DefaultVocabularyScope())
+		element := p.Parse()
+		fb.Build()
+		Expect(p.Errors()).To(BeNil())
+		context := binder.NewContext()
+		module := types.NewTypeSymbol("prelude", nil)
+		context.Enter(element)
+		context.Build(module, element)
+		context.Check(module, element)
+		Expect(context.Errors).To(BeNil())
+		options = lsp.Options{
+			FileSet: fileSet,
+			Outer:   module.Type().TypeScope(),
+			Imports: []*binder.BindingContext{context},
+			Sources: sources{"/work/prelude.dg": preludeText},
+		}
+		c = newClient(options)
+		var result lsp.InitializeResult
+		Expect(c.call("initialize", &lsp.InitializeParams{}, &result)).To(BeNil())
+		Expect(result.Capabilities.TextDocumentSync).To(Equal(lsp.SyncFull))
+		Expect(result.Capabilities.HoverProvider).To(BeTrue())
+		c.notify("initialized", struct{}{})
+	})
+	AfterEach(func() {
+		if c != nil {
+			Expect(c.exit()).To(Succeed())
+		}
+	})
+	It("requires initialize", func() {
+		c2 := newClient(options)
+		err := c2.call("textDocument/hover", &lsp.TextDocumentPositionParams{}, nil)
+		Expect(err).To(Not(BeNil()))
+		Expect(err.Code).To(Equal(lsp.ServerNotInitialized))
+		c2.notify("exit", nil)
+		Expect(<-c2.done).To(MatchError("Exit without a shutdown request"))
+	})
+	It("reports unknown methods", func() {
+		err := c.call("workspace/unknown", struct{}{}, nil)
+		Expect(err).To(Not(BeNil()))
+		Expect(err.Code).To(Equal(lsp.MethodNotFound))
+	})
+	It("publishes diagnostics when a document is opened and changed", func() {
+		params := c.open(uri, "...Dyego0\nval a: Int = 1\nval b = c\n")
+		Expect(params.URI).To(Equal(uri))
+		Expect(params.Diagnostics).To(Equal([]lsp.Diagnostic{{
+			Range:    span(2, 8, 9),
+			Severity: lsp.SeverityError,
+			Source:   "dyego",
+			Message:  "Undefined symbol c",
+		}}))
+		c.notify("textDocument/didChange", &lsp.DidChangeTextDocumentParams{
+			TextDocument:   lsp.VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+			ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: "...Dyego0\nval a: Int = 1\nval b = a\n"}},
+		})
+		c.next("textDocument/publishDiagnostics", &params)
+		Expect(params.Version).To(Equal(2))
+		Expect(params.Diagnostics).To(BeEmpty())
+		c.notify("textDocument/didClose", &lsp.DidCloseTextDocumentParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		})
+		c.next("textDocument/publishDiagnostics", &params)
+		Expect(params.Diagnostics).To(BeEmpty())
+	})
+	It("reuses the positions of a document when it changes", func() {
+		text := "...Dyego0\nval a = 1\n"
+		c.open(uri, text)
+		var params lsp.PublishDiagnosticsParams
+		for version, change := range []string{"...Dyego0\nval b = 2\n", "...Dyego0\nval c = a\n", text} {
+			c.notify("textDocument/didChange", &lsp.DidChangeTextDocumentParams{
+				TextDocument:   lsp.VersionedTextDocumentIdentifier{URI: uri, Version: version + 2},
+				ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: change}},
+			})
+			c.next("textDocument/publishDiagnostics", &params)
+		}
+		c.notify("textDocument/didClose", &lsp.DidCloseTextDocumentParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		})
+		c.next("textDocument/publishDiagnostics", &params)
+		c.open(uri, text)
+		fb := options.FileSet.BuildFile("/work/next.dg", 0)
+		Expect(int(fb.Pos(0))).To(Equal(len(preludeText) + len(text)))
+	})
+	It("shows the type of a symbol on hover", func() {
+		c.open(uri, "...Dyego0\nval a = 1 + 2\nlet f = { x: Int -> x + a }\n")
+		hover := func(position lsp.Position) *lsp.Hover {
+			var result *lsp.Hover
+			Expect(c.call("textDocument/hover", &lsp.TextDocumentPositionParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+				Position:     position,
+			}, &result)).To(BeNil())
+			return result
+		}
+		Expect(hover(at(1, 4)).Contents.Value).To(Equal("a: prelude.Int"))
+		Expect(*hover(at(1, 4)).Range).To(Equal(span(1, 4, 5)))
+		Expect(hover(at(2, 25)).Contents.Value).To(Equal("a: prelude.Int"))
+		Expect(hover(at(2, 10)).Contents.Value).To(Equal("x: prelude.Int"))
+		Expect(hover(at(2, 14)).Contents.Value).To(Equal("prelude.Int"))
+		Expect(hover(at(1, 8)).Contents.Value).To(Equal("prelude.Int"))
+		Expect(hover(at(0, 0))).To(BeNil())
+	})
+	It("can go to the definition of a symbol", func() {
+		c.open(uri, "...Dyego0\nval a: Int = 1\nval b = a\n")
+		definition := func(position lsp.Position) []lsp.Location {
+			var result []lsp.Location
+			Expect(c.call("textDocument/definition", &lsp.TextDocumentPositionParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+				Position:     position,
+			}, &result)).To(BeNil())
+			return result
+		}
+		Expect(definition(at(2, 8))).To(Equal([]lsp.Location{{URI: uri, Range: span(1, 4, 5)}}))
+		Expect(definition(at(1, 7))).To(Equal([]lsp.Location{{URI: "file:///work/prelude.dg", Range: span(3, 4, 7)}}))
+		Expect(definition(at(2, 6))).To(BeNil())
+	})
+	It("lists the symbols of a document", func() {
+		c.open(uri, "...Dyego0\nlet Point = <\n  x: Int\n  let twice = { -> x + x }\n>\n"+
+			"var count: Int = 0\nval origin: Point = [x: 0]\n")
+		var result []lsp.DocumentSymbol
+		Expect(c.call("textDocument/documentSymbol", &lsp.DocumentSymbolParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		}, &result)).To(BeNil())
+		type summary struct {
+			Name   string
+			Kind   lsp.SymbolKind
+			Detail string
+			Range  lsp.Range
+		}
+		var summaries []summary
+		var collect func(symbols []lsp.DocumentSymbol)
+		collect = func(symbols []lsp.DocumentSymbol) {
+			for _, sym := range symbols {
+				summaries = append(summaries, summary{sym.Name, sym.Kind, sym.Detail, sym.SelectionRange})
+				collect(sym.Children)
+			}
+		}
+		collect(result)
+		Expect(summaries).To(Equal([]summary{
+			{"Point", lsp.SymbolClass, "main.Point", span(1, 4, 9)},
+			{"x", lsp.SymbolField, "prelude.Int", span(2, 2, 3)},
+			{"twice", lsp.SymbolMethod, "main.Point.{ -> prelude.Int}", span(3, 6, 11)},
+			{"count", lsp.SymbolVariable, "prelude.Int", span(5, 4, 9)},
+			{"origin", lsp.SymbolConstant, "main.Point", span(6, 4, 10)},
+		}))
+	})
+	It("can refer to the modules of the root scope", func() {
+		Expect(c.exit()).To(Succeed())
+		shapes := "...Dyego0\nval size: Int = 2\n"
+		root, err := binder.NewFilesModuleSourceScope([]string{"/lib/Shapes.dg"}, func(string) (io.Reader, error) {
+			return strings.NewReader(shapes), nil
+		})
+		Expect(err).To(BeNil())
+		options.Root = root
+		c = newClient(options)
+		Expect(c.call("initialize", &lsp.InitializeParams{}, nil)).To(BeNil())
+		params := c.open(uri, "...Dyego0\nval a = lib.Shapes.size + 1\nval b = lib.Shapes.c\n")
+		Expect(params.Diagnostics).To(HaveLen(1))
+		Expect(params.Diagnostics[0].Range).To(Equal(span(2, 19, 20)))
+		var result []lsp.Location
+		Expect(c.call("textDocument/definition", &lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+			Position:     at(1, 20),
+		}, &result)).To(BeNil())
+		Expect(result).To(Equal([]lsp.Location{{URI: "file:///lib/Shapes.dg", Range: span(1, 4, 8)}}))
+	})
+	It("counts characters in UTF-16 code units", func() {
+		params := c.open(uri, "...Dyego0\nval a = \"x\"\nval t = [\"\U0001F600é\", a, b]")
+		Expect(params.Diagnostics).To(HaveLen(1))
+		Expect(params.Diagnostics[0].Range).To(Equal(span(2, 19, 20)))
+		var result *lsp.Hover
+		Expect(c.call("textDocument/hover", &lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+			Position:     at(2, 16),
+		}, &result)).To(BeNil())
+		Expect(*result.Range).To(Equal(span(2, 16, 17)))
+		Expect(result.Contents.Value).To(Equal("a: prelude.String"))
+	})
+})
+
+func TestLsp(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "LSP Suite")
+}
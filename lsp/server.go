@@ -0,0 +1,357 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"dyego0/ast"
+	"dyego0/binder"
+	"dyego0/diagnostics"
+	"dyego0/location"
+	"dyego0/parser"
+	"dyego0/symbols"
+	"dyego0/tokens"
+)
+
+// Options configure a Server
+type Options struct {
+	// FileSet is the file set the documents are declared in. Symbols declared in other files
+	// of the file set, such as the files that declare Outer, can be found by go to definition.
+	FileSet tokens.FileSet
+
+	// Vocabulary is the vocabulary scope used to parse documents
+	Vocabulary parser.VocabularyScope
+
+	// Outer is the scope of the symbols documents can refer to that are not declared in the
+	// document
+	Outer symbols.Scope
+
+	// Imports are the binding contexts of the modules that declare the symbols in Outer
+	Imports []*binder.BindingContext
+
+	// Sources provides the text of the files of FileSet that are not documents
+	Sources diagnostics.SourceProvider
+
+	// Root is the scope of the modules documents can refer to by their qualified names, if
+	// any. A module is loaded from Root once, when a document first refers to it.
+	Root binder.ModuleSourceScope
+}
+
+// Server is a language server for Dyego0 documents. It analyzes documents as they are opened
+// and changed, publishing the errors reported, and answers hover, definition and document
+// symbol requests using the result of the analysis.
+type Server struct {
+	fileSet     tokens.FileSet
+	vocabulary  parser.VocabularyScope
+	outer       symbols.Scope
+	imports     []*binder.BindingContext
+	sources     diagnostics.SourceProvider
+	loader      *binder.Loader
+	conn        *Conn
+	documents   map[string]*document
+	initialized bool
+	shutdown    bool
+
+	// files are the files the documents were last analyzed as, kept after a document is closed
+	// so a document reuses the positions of its file when it is analyzed again
+	files map[string]tokens.File
+}
+
+// NewServer creates a language server configured by options
+func NewServer(options Options) *Server {
+	s := &Server{
+		fileSet:    options.FileSet,
+		vocabulary: options.Vocabulary,
+		outer:      options.Outer,
+		imports:    options.Imports,
+		sources:    options.Sources,
+		documents:  make(map[string]*document),
+		files:      make(map[string]tokens.File),
+	}
+	if s.fileSet == nil {
+		s.fileSet = tokens.NewFileSet()
+	}
+	if s.vocabulary == nil {
+		s.vocabulary = parser.DefaultVocabularyScope()
+	}
+	if s.outer == nil {
+		s.outer = symbols.EmptyScope()
+	}
+	if options.Root != nil {
+		s.loader = binder.NewLoader(options.Root, s.fileSet, s.vocabulary, s.outer)
+		s.loader.Imports = s.imports
+	}
+	return s
+}
+
+// Serve reads requests and notifications from r and writes responses and notifications to w
+// until the client sends exit. Returns an error if the connection fails or the client exits,
+// or closes the connection, without requesting a shutdown.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.conn = NewConn(r, w)
+	for {
+		message, err := s.conn.Read()
+		if err == io.EOF {
+			if !s.shutdown {
+				return fmt.Errorf("Connection closed without a shutdown request")
+			}
+			return nil
+		}
+		if err != nil {
+			if responseErr, ok := err.(*ResponseError); ok {
+				if err := s.conn.Reply(nil, nil, responseErr); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+		if message.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("Exit without a shutdown request")
+			}
+			return nil
+		}
+		if message.IsRequest() {
+			result, responseErr := s.request(message)
+			if err := s.conn.Reply(message.ID, result, responseErr); err != nil {
+				return err
+			}
+		} else if message.IsNotification() {
+			if err := s.notification(message); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// request handles a request returning its result
+func (s *Server) request(message *Message) (result interface{}, responseErr *ResponseError) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = nil
+			responseErr = &ResponseError{Code: InternalError, Message: fmt.Sprint(r)}
+		}
+	}()
+	if message.Method == "initialize" {
+		s.initialized = true
+		return &InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:       SyncFull,
+				HoverProvider:          true,
+				DefinitionProvider:     true,
+				DocumentSymbolProvider: true,
+			},
+			ServerInfo: &ServerInfo{Name: "dyego"},
+		}, nil
+	}
+	if !s.initialized {
+		return nil, &ResponseError{Code: ServerNotInitialized, Message: "Server is not initialized"}
+	}
+	switch message.Method {
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/hover":
+		params := &TextDocumentPositionParams{}
+		if err := unmarshal(message, params); err != nil {
+			return nil, err
+		}
+		return s.hover(params), nil
+	case "textDocument/definition":
+		params := &TextDocumentPositionParams{}
+		if err := unmarshal(message, params); err != nil {
+			return nil, err
+		}
+		return s.definition(params), nil
+	case "textDocument/documentSymbol":
+		params := &DocumentSymbolParams{}
+		if err := unmarshal(message, params); err != nil {
+			return nil, err
+		}
+		d, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return nil, unknownDocument(params.TextDocument.URI)
+		}
+		return d.symbols(), nil
+	}
+	return nil, &ResponseError{Code: MethodNotFound, Message: fmt.Sprintf("Method %s not found", message.Method)}
+}
+
+// notification handles a notification. Notifications that are not understood are ignored.
+func (s *Server) notification(message *Message) error {
+	if !s.initialized {
+		return nil
+	}
+	switch message.Method {
+	case "textDocument/didOpen":
+		params := &DidOpenTextDocumentParams{}
+		if unmarshal(message, params) != nil {
+			return nil
+		}
+		item := params.TextDocument
+		return s.update(item.URI, item.Version, item.Text)
+	case "textDocument/didChange":
+		params := &DidChangeTextDocumentParams{}
+		if unmarshal(message, params) != nil || len(params.ContentChanges) == 0 {
+			return nil
+		}
+		// The server requests full synchronization so the last change is the whole text
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		return s.update(params.TextDocument.URI, params.TextDocument.Version, text)
+	case "textDocument/didClose":
+		params := &DidCloseTextDocumentParams{}
+		if unmarshal(message, params) != nil {
+			return nil
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return s.conn.Notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+	}
+	return nil
+}
+
+// update analyzes the new text of a document and publishes its diagnostics
+func (s *Server) update(uri string, version int, text string) (err error) {
+	var d *document
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("Analyzing %s failed: %v", uri, r)
+			}
+		}()
+		d = s.analyze(uri, version, text)
+	}()
+	if err != nil {
+		return s.conn.Notify("window/logMessage", map[string]interface{}{"type": 1, "message": err.Error()})
+	}
+	s.documents[uri] = d
+	s.files[uri] = d.file
+	return s.conn.Notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+		URI:         uri,
+		Version:     version,
+		Diagnostics: d.diagnostics(),
+	})
+}
+
+func (s *Server) hover(params *TextDocumentPositionParams) *Hover {
+	d, ok := s.documents[params.TextDocument.URI]
+	if !ok || d.context == nil {
+		return nil
+	}
+	pos := d.posOf(params.Position)
+	var element ast.Element
+	var text string
+	if name := d.nameAt(pos); name != nil {
+		if sym, ok := d.symbolOf(name); ok {
+			element, text = name, describe(sym)
+		}
+	}
+	if element == nil {
+		expression := d.expressionAt(pos)
+		if expression == nil {
+			return nil
+		}
+		element, text = expression, typeName(d.context.Types[expression])
+	}
+	if text == "" {
+		return nil
+	}
+	r := rangeOf(d.file, d, element)
+	return &Hover{Contents: MarkupContent{Kind: "plaintext", Value: text}, Range: &r}
+}
+
+func (s *Server) definition(params *TextDocumentPositionParams) []Location {
+	d, ok := s.documents[params.TextDocument.URI]
+	if !ok || d.context == nil {
+		return nil
+	}
+	name := d.nameAt(d.posOf(params.Position))
+	if name == nil {
+		return nil
+	}
+	sym, ok := d.symbolOf(name)
+	if !ok {
+		return nil
+	}
+	element, ok := d.context.Definitions[sym]
+	for _, imported := range d.context.Imports {
+		if ok {
+			break
+		}
+		element, ok = imported.Definitions[sym]
+	}
+	if !ok {
+		return nil
+	}
+	if named, ok := element.(ast.NamedElement); ok && named.Name() != nil {
+		element = named.Name()
+	}
+	location, ok := s.locationOf(element)
+	if !ok {
+		return nil
+	}
+	return []Location{location}
+}
+
+// locationOf converts the range of loc to a location in the document or file that contains it
+func (s *Server) locationOf(loc location.Locatable) (Location, bool) {
+	file := s.fileSet.File(loc.Start())
+	if file == nil {
+		return Location{}, false
+	}
+	for _, d := range s.documents {
+		if d.file == file {
+			return Location{URI: d.uri, Range: rangeOf(file, d, loc)}, true
+		}
+	}
+	var source diagnostics.Source
+	if s.sources != nil {
+		source = s.sources.Source(file.FileName())
+	}
+	if s.loader != nil {
+		for _, module := range s.loader.Modules() {
+			if module.File == file {
+				source = moduleText(module.Text)
+			}
+		}
+	}
+	return Location{URI: uriOf(file.FileName()), Range: rangeOf(file, source, loc)}, true
+}
+
+// moduleText is the text of a module loaded from the root scope
+type moduleText string
+
+func (t moduleText) Text(start, end int) string {
+	if end > len(t) {
+		end = len(t)
+	}
+	return string(t[start:end])
+}
+
+// uriOf converts a file name to a URI
+func uriOf(fileName string) string {
+	if strings.Contains(fileName, "://") {
+		return fileName
+	}
+	if abs, err := filepath.Abs(fileName); err == nil {
+		fileName = abs
+	}
+	return "file://" + filepath.ToSlash(fileName)
+}
+
+func unmarshal(message *Message, params interface{}) *ResponseError {
+	if err := json.Unmarshal(message.Params, params); err != nil {
+		return &ResponseError{Code: InvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func unknownDocument(uri string) *ResponseError {
+	return &ResponseError{Code: InvalidParams, Message: fmt.Sprintf("Document %s is not open", uri)}
+}
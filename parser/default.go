@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"dyego0/ast"
+	"dyego0/scanner"
+)
+
+// builtinOperatorsSource is the operator vocabulary declared as Operators in builtins/Dyego0_wasm.dg. The
+// two must be kept in step; the optable tests check that they declare the same operators.
+var builtinOperatorsSource = strings.ReplaceAll(`
+let Operators = <|
+  postfix operator (@++@, @--@, @?@) right,
+  prefix operator (@+@, @-@, @--@, @++@) right,
+  infix operator (@as@, @as?@) left,
+  infix operator (@*@, @/@, @%@) left,
+  infix operator (@+@, @-@) left,
+  infix operator @..@ left,
+  infix operator identifiers left,
+  infix operator @?:@ left,
+  infix operator (@in@, @!in@, @is@, @!is@) left,
+  infix operator (@<@, @>@, @>=@, @<=@) left,
+  infix operator (@==@, @!=@) left,
+  infix operator @&&@ left,
+  infix operator @||@ left,
+  infix operator (@=@, @+=@, @*=@, @/=@, @%=@) right
+|>`, "@", "`")
+
+// DefaultVocabularyScope returns a vocabulary scope that declares the standard Dyego0 operator
+// vocabulary as both dyego and Dyego0.
+func DefaultVocabularyScope() VocabularyScope {
+	src := append([]byte(builtinOperatorsSource), 0)
+	p := NewParser(scanner.NewScanner(src, 0, nil), newVocabularyScope())
+	element := p.Parse()
+	if len(p.Errors()) > 0 {
+		panic(fmt.Sprintf("Default vocabulary has errors: %s", p.Errors()[0]))
+	}
+	definition := element.(ast.Definition)
+	vocabulary, errors := buildVocabulary(newVocabularyScope(), definition.Value().(ast.VocabularyLiteral))
+	if len(errors) > 0 {
+		panic(fmt.Sprintf("Default vocabulary has errors: %s", errors[0].message))
+	}
+	result := newVocabularyScope()
+	result.members["dyego"] = vocabulary
+	result.members["Dyego0"] = vocabulary
+	return result
+}
@@ -0,0 +1,471 @@
+package parser
+
+import (
+	"strings"
+
+	"dyego0/ast"
+	"dyego0/cst"
+	"dyego0/location"
+	"dyego0/scanner"
+	"dyego0/tokens"
+)
+
+// Lower returns the elements the parser builds for the syntax of node, such as the loop a while
+// statement is parsed as. The elements are located at the tokens of the syntax so the elements
+// lowered from the syntax tree recorded by a parser are the elements returned by the parser.
+func Lower(node *cst.Node) ast.Element {
+	context := &span{}
+	l := &lowerer{context: context, builder: ast.NewBuilder(context)}
+	l.builder.PushContext()
+	return l.element(node)
+}
+
+// span is a BuilderContext for the location of the node being lowered
+type span struct {
+	start, end location.Pos
+}
+
+func (s *span) Start() location.Pos {
+	return s.start
+}
+
+func (s *span) End() location.Pos {
+	return s.end
+}
+
+type lowerer struct {
+	context *span
+	builder ast.Builder
+}
+
+// at returns the builder with the location of loc, typically a node, as its context
+func (l *lowerer) at(loc location.Locatable) ast.Builder {
+	l.context.start, l.context.end = loc.Start(), loc.End()
+	l.builder.PopContext()
+	l.builder.PushContext()
+	return l.builder
+}
+
+func (l *lowerer) element(node *cst.Node) ast.Element {
+	if node == nil {
+		return nil
+	}
+	if failed(node) {
+		return l.element(last(node))
+	}
+	nodes, marked := parts(node)
+	switch node.Kind() {
+	case cst.File, cst.Parenthesized:
+		return l.element(first(nodes))
+	case cst.Sequence:
+		left, right := l.element(nodes[0]), l.element(nodes[1])
+		return l.at(node).Sequence(left, right)
+	case cst.Error:
+		return l.at(node).Error("%s", node.Message())
+	case cst.Name:
+		token := node.FirstToken()
+		if token == nil {
+			return l.at(node).Name("<error>")
+		}
+		return l.at(node).Name(scanToken(token).Value().(string))
+	case cst.Literal:
+		token := node.FirstToken()
+		switch token.Token() {
+		case tokens.True:
+			return l.at(node).Literal(true, "true")
+		case tokens.False:
+			return l.at(node).Literal(false, "false")
+		case tokens.Null:
+			return l.at(node).Literal(nil, "null")
+		}
+		return l.at(node).Literal(scanToken(token).Value(), token.Text())
+	case cst.Selection:
+		target, member := l.element(nodes[0]), l.name(nodes[1])
+		return l.at(node).Selection(target, member)
+	case cst.SafeSelection:
+		target, member := l.element(nodes[0]), l.name(nodes[1])
+		return l.at(node).SafeSelection(target, member)
+	case cst.Call:
+		target, arguments := l.element(nodes[0]), l.elements(nodes[1:])
+		return l.at(node).Call(target, arguments)
+	case cst.Instantiation:
+		target, typeArguments := l.element(nodes[0]), l.elements(nodes[1:])
+		return l.at(node).Instantiation(target, typeArguments)
+	case cst.Index, cst.IndexAssignment:
+		target, arguments := l.element(nodes[0]), l.elements(nodes[1:])
+		member := "get"
+		if node.Kind() == cst.IndexAssignment {
+			member = "set"
+		}
+		b := l.at(node)
+		return b.Call(b.Selection(target, b.Name(member)), arguments)
+	case cst.Prefix, cst.Postfix, cst.Binary, cst.TypeOperator:
+		return l.operator(node, nodes)
+	case cst.NamedArgument, cst.NamedMemberInitializer:
+		var name ast.Name
+		if len(nodes) == 1 {
+			// The name of :name is the start of the value
+			name = l.at(nodes[0].FirstToken()).Name(scanToken(nodes[0].FirstToken()).Value().(string))
+		} else {
+			name = l.name(nodes[0])
+		}
+		value := l.element(nodes[len(nodes)-1])
+		if node.Kind() == cst.NamedArgument {
+			return l.at(node).NamedArgument(name, value)
+		}
+		return l.at(node).NamedMemberInitializer(name, nil, value)
+	case cst.If:
+		target := l.element(nodes[0])
+		var clauses []ast.Element
+		for _, clause := range nodes[1:] {
+			clauseNodes, _ := parts(clause)
+			body := l.element(first(clauseNodes))
+			if clause.Kind() == cst.Then {
+				clauses = append(clauses, l.at(clause).WhenValueClause(target, body))
+			} else {
+				clauses = append(clauses, l.at(clause).WhenElseClause(body))
+			}
+		}
+		return l.at(node).When(nil, clauses)
+	case cst.When:
+		target, clauses := l.element(marked["("]), l.elements(ofKind(nodes, cst.WhenValueClause, cst.WhenElseClause))
+		return l.at(node).When(target, clauses)
+	case cst.WhenValueClause:
+		value, body := l.element(nodes[0]), l.element(nodes[1])
+		return l.at(node).WhenValueClause(value, body)
+	case cst.WhenElseClause:
+		body := l.element(first(nodes))
+		return l.at(node).WhenElseClause(body)
+	case cst.Lambda, cst.IntrinsicLambda:
+		typeParameters := l.typeParameters(ofKind(nodes, cst.TypeParameter))
+		parameters := l.parameters(ofKind(nodes, cst.Parameter))
+		var body ast.Element
+		for _, child := range nodes {
+			if child.Kind() != cst.TypeParameter && child.Kind() != cst.Parameter && child != marked[":"] {
+				body = l.element(child)
+			}
+		}
+		result := l.element(marked[":"])
+		if node.Kind() == cst.Lambda {
+			return l.at(node).Lambda(typeParameters, parameters, body, result)
+		}
+		return l.at(node).IntrinsicLambda(parameters, body, result)
+	case cst.Parameter:
+		name, typ, value := l.name(nodes[0]), l.element(marked[":"]), l.element(marked["="])
+		return l.at(node).Parameter(name, typ, value)
+	case cst.TypeParameter:
+		name, constraint := l.name(nodes[0]), l.element(marked[":"])
+		return l.at(node).TypeParameter(name, constraint)
+	case cst.Spread:
+		target := l.element(first(nodes))
+		return l.at(node).Spread(target)
+	case cst.Definition:
+		// The value follows the name, or the type, even if = is missing
+		var valueNode *cst.Node
+		if last := nodes[len(nodes)-1]; len(nodes) > 1 && last != marked[":"] {
+			valueNode = last
+		}
+		name, typ, value := l.name(nodes[0]), l.element(marked[":"]), l.element(valueNode)
+		return l.at(node).Definition(name, typ, value)
+	case cst.Storage:
+		name, typ, value := l.name(nodes[0]), l.element(marked[":"]), l.element(marked["="])
+		mutable := startsWith(node, tokens.Var)
+		return l.at(node).Storage(name, typ, value, mutable)
+	case cst.ObjectInitializer, cst.ArrayInitializer:
+		mutable := startsWith(node, tokens.LBrackBang)
+		typ := l.element(marked["<"])
+		var members []ast.Element
+		for _, child := range nodes {
+			if child != marked["<"] {
+				members = append(members, l.element(child))
+			}
+		}
+		if node.Kind() == cst.ObjectInitializer {
+			return l.at(node).ObjectInitializer(mutable, typ, members)
+		}
+		return l.at(node).ArrayInitializer(mutable, typ, members)
+	case cst.TypeLiteral:
+		typeParameters := l.typeParameters(ofKind(nodes, cst.TypeParameter))
+		var members []ast.Element
+		for _, child := range nodes {
+			if child.Kind() != cst.TypeParameter {
+				members = append(members, l.element(child))
+			}
+		}
+		return l.at(node).TypeLiteral(typeParameters, members)
+	case cst.CallableTypeMember:
+		// The result follows the parameters even if -> is missing
+		var resultNode *cst.Node
+		if last := nodes[len(nodes)-1]; last.Kind() != cst.Parameter {
+			resultNode = last
+		}
+		parameters, result := l.elements(ofKind(nodes, cst.Parameter)), l.element(resultNode)
+		return l.at(node).CallableTypeMember(parameters, result)
+	case cst.SequenceType:
+		target := l.element(nodes[0])
+		return l.at(node).SequenceType(target)
+	case cst.OptionalType:
+		target := l.element(nodes[0])
+		return l.at(node).OptionalType(target)
+	case cst.ReferenceType:
+		target := l.element(nodes[0])
+		return l.at(node).ReferenceType(target)
+	case cst.VocabularyLiteral:
+		members := l.elements(ofKind(nodes, cst.VocabularyOperatorDeclaration, cst.VocabularyEmbedding))
+		return l.at(node).VocabularyLiteral(members)
+	case cst.VocabularyOperatorDeclaration:
+		return l.operatorDeclaration(node, nodes, marked)
+	case cst.VocabularyOperatorPrecedence:
+		relation := ast.After
+		qualifier := node.Children()[1]
+		switch pseudo(node.FirstToken()) {
+		case tokens.Before:
+			relation = ast.Before
+		case tokens.Same:
+			relation = ast.SameAs
+			qualifier = node.Children()[2]
+		}
+		placement := ast.UnspecifiedPlacement
+		if marked["infix"] != nil || marked["prefix"] != nil || marked["postfix"] != nil {
+			placement = placementOf(qualifier)
+		}
+		name := l.name(nodes[0])
+		return l.at(node).VocabularyOperatorPrecedence(name, placement, relation)
+	case cst.VocabularyEmbedding:
+		names := l.names(nodes)
+		return l.at(node).VocabularyEmbedding(names)
+	case cst.Loop:
+		label, rest := l.label(node, nodes)
+		body := l.element(first(rest))
+		return l.at(node).Loop(label, body)
+	case cst.While:
+		label, rest := l.label(node, nodes)
+		var test, body ast.Element
+		if len(rest) >= 2 {
+			test, body = l.element(rest[0]), l.element(rest[1])
+		}
+		b := l.at(node)
+		when := b.When(nil, []ast.Element{b.WhenValueClause(test, body), b.WhenElseClause(b.Break(label))})
+		return b.Loop(label, when)
+	case cst.Break:
+		label := l.name(first(nodes))
+		return l.at(node).Break(label)
+	case cst.Continue:
+		label := l.name(first(nodes))
+		return l.at(node).Continue(label)
+	case cst.Return:
+		value := l.element(first(nodes))
+		return l.at(node).Return(value)
+	}
+	return l.at(node).Error("Unexpected syntax %s", node.Kind())
+}
+
+func (l *lowerer) elements(nodes []*cst.Node) []ast.Element {
+	var result []ast.Element
+	for _, node := range nodes {
+		result = append(result, l.element(node))
+	}
+	return result
+}
+
+func (l *lowerer) name(node *cst.Node) ast.Name {
+	if node == nil {
+		return nil
+	}
+	return l.element(node).(ast.Name)
+}
+
+func (l *lowerer) names(nodes []*cst.Node) []ast.Name {
+	var result []ast.Name
+	for _, node := range nodes {
+		result = append(result, l.name(node))
+	}
+	return result
+}
+
+// label returns the label of a loop, which directly follows the keyword, and the nodes that follow it
+func (l *lowerer) label(node *cst.Node, nodes []*cst.Node) (ast.Name, []*cst.Node) {
+	if children := node.Children(); len(children) > 1 && children[1].Kind() == cst.Name {
+		return l.name(children[1]), nodes[1:]
+	}
+	return nil, nodes
+}
+
+func (l *lowerer) parameters(nodes []*cst.Node) []ast.Parameter {
+	var result []ast.Parameter
+	for _, node := range nodes {
+		result = append(result, l.element(node).(ast.Parameter))
+	}
+	return result
+}
+
+func (l *lowerer) typeParameters(nodes []*cst.Node) []ast.TypeParameter {
+	var result []ast.TypeParameter
+	for _, node := range nodes {
+		result = append(result, l.element(node).(ast.TypeParameter))
+	}
+	return result
+}
+
+// operator lowers an operator to a call of the member of its operand named by the operator. The
+// operands between and after the parts of a mixfix operator, such as b and c of a ? b : c, are the
+// arguments of the call.
+func (l *lowerer) operator(node *cst.Node, nodes []*cst.Node) ast.Element {
+	var operatorTokens []*cst.Node
+	for _, child := range node.Children() {
+		if child.Kind() == cst.Token && (node.Kind() == cst.Prefix || child.Pos() >= nodes[0].End()) {
+			operatorTokens = append(operatorTokens, child)
+		}
+	}
+	token := operatorTokens[0]
+	var parts []string
+	for _, part := range operatorTokens {
+		parts = append(parts, partText(part))
+	}
+	text := strings.Join(parts, " ")
+	if node.Kind() == cst.Postfix {
+		text = "postfix " + text
+	}
+	name := l.at(token).Name(text)
+	target := l.element(nodes[0])
+	if node.Kind() == cst.TypeOperator {
+		// The selection of a type operator ends at the operator
+		selection := l.at(location.NewLocation(nodes[0].Start(), token.End())).Selection(target, name)
+		right := l.element(nodes[1])
+		return l.at(node).Call(selection, []ast.Element{right})
+	}
+	var arguments []ast.Element
+	if len(nodes) > 1 {
+		arguments = l.elements(nodes[1:])
+	}
+	b := l.at(node)
+	return b.Call(b.Selection(target, name), arguments)
+}
+
+// partText is the text of a token of an operator, the name of an identifier or a symbol, or the
+// spelling of any other token such as the : of a ? b : c
+func partText(token *cst.Node) string {
+	if text, ok := scanToken(token).Value().(string); ok {
+		return text
+	}
+	return token.Text()
+}
+
+func (l *lowerer) operatorDeclaration(node *cst.Node, nodes []*cst.Node, marked map[string]*cst.Node) ast.Element {
+	all := node.Children()
+	placement := placementOf(all[0])
+	var names []ast.Name
+	if name := marked["operator"]; name != nil && pseudo(name.FirstToken()) == tokens.Identifiers {
+		// The name is located from the start of the declaration to the operator keyword
+		operator := all[name.Index()-1]
+		names = append(names, l.at(location.NewLocation(node.Start(), operator.End())).Name(infixTypeMember))
+	} else {
+		names = l.names(ofKind(nodes, cst.Name))
+	}
+	var precedence ast.VocabularyOperatorPrecedence
+	if qualifiers := ofKind(nodes, cst.VocabularyOperatorPrecedence); len(qualifiers) > 0 {
+		precedence = l.element(qualifiers[0]).(ast.VocabularyOperatorPrecedence)
+	}
+	associativity := ast.Left
+	switch pseudo(all[len(all)-1]) {
+	case tokens.Right:
+		associativity = ast.Right
+	case tokens.None:
+		associativity = ast.NonAssociative
+	}
+	return l.at(node).VocabularyOperatorDeclaration(names, placement, precedence, associativity)
+}
+
+// parts returns the children of node that are not tokens, and those of them that follow a token,
+// by the text of the token
+func parts(node *cst.Node) ([]*cst.Node, map[string]*cst.Node) {
+	var nodes []*cst.Node
+	marked := map[string]*cst.Node{}
+	var previous *cst.Node
+	for _, child := range node.Children() {
+		if child.Kind() != cst.Token {
+			nodes = append(nodes, child)
+			if previous != nil && previous.Kind() == cst.Token {
+				if _, ok := marked[previous.Text()]; !ok {
+					marked[previous.Text()] = child
+				}
+			}
+		}
+		previous = child
+	}
+	return nodes, marked
+}
+
+// ofKind returns the nodes of the kinds given
+func ofKind(nodes []*cst.Node, kinds ...cst.Kind) []*cst.Node {
+	var result []*cst.Node
+	for _, node := range nodes {
+		for _, kind := range kinds {
+			if node.Kind() == kind {
+				result = append(result, node)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// startsWith returns true if the first child of node is token
+func startsWith(node *cst.Node, token tokens.Token) bool {
+	children := node.Children()
+	return len(children) > 0 && children[0].Kind() == cst.Token && children[0].Token() == token
+}
+
+func first(nodes []*cst.Node) *cst.Node {
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[0]
+}
+
+func last(node *cst.Node) *cst.Node {
+	nodes := node.Children()
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[len(nodes)-1]
+}
+
+// failed returns true if the parser returned an error for node instead of an element of its
+// kind, which it records as the last child of the node. The error of a spread is the reference to
+// a vocabulary that is not found.
+func failed(node *cst.Node) bool {
+	switch node.Kind() {
+	case cst.Spread, cst.VocabularyOperatorDeclaration:
+		err := last(node)
+		if err == nil || err.Kind() != cst.Error {
+			return false
+		}
+		return node.Kind() != cst.Spread || len(ofKind(err.Children(), cst.Name, cst.Selection)) > 0
+	}
+	return false
+}
+
+// scanToken scans the text of token
+func scanToken(token *cst.Node) *scanner.Scanner {
+	s := scanner.NewScanner(append([]byte(token.Text()), 0), 0, nil)
+	s.Next()
+	return s
+}
+
+func pseudo(token *cst.Node) tokens.PseudoToken {
+	if token == nil || token.Kind() != cst.Token {
+		return tokens.InvalidPseudoToken
+	}
+	return scanToken(token).PseudoToken()
+}
+
+func placementOf(token *cst.Node) ast.OperatorPlacement {
+	switch pseudo(token) {
+	case tokens.Prefix:
+		return ast.Prefix
+	case tokens.Postfix:
+		return ast.Postfix
+	}
+	return ast.Infix
+}
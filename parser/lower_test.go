@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"dyego0/ast"
+	"dyego0/cst"
+	"dyego0/scanner"
+)
+
+var _ = Describe("lower", func() {
+	parseSyntax := func(text string) (ast.Element, *cst.Node) {
+		p := NewSyntaxParser(scanner.NewScanner(append([]byte(text), 0), 0, nil), defaultScope)
+		element := p.Parse()
+		return element, p.Syntax()
+	}
+	It("records every token of the text", func() {
+		text := "...Dyego0\n// a comment\nval a = [1, 2] /* two */\na[0] = -a[1] + 3 \n"
+		_, syntax := parseSyntax(text)
+		Expect(syntax.Green().Text()).To(Equal(text))
+		Expect(syntax.Kind()).To(Equal(cst.File))
+	})
+	It("records the form of sugar", func() {
+		_, syntax := parseSyntax("...Dyego0\nwhile (a < b) { a = a + 1 }\nc[1] = 2")
+		var kinds []cst.Kind
+		var visit func(node *cst.Node)
+		visit = func(node *cst.Node) {
+			if node.Kind() != cst.Token {
+				kinds = append(kinds, node.Kind())
+			}
+			for _, child := range node.Children() {
+				visit(child)
+			}
+		}
+		visit(syntax)
+		Expect(kinds).To(ContainElement(cst.While))
+		Expect(kinds).To(ContainElement(cst.IndexAssignment))
+		Expect(kinds).To(ContainElement(cst.Binary))
+		Expect(kinds).ToNot(ContainElement(cst.Call))
+	})
+	It("locates a node at its tokens", func() {
+		_, syntax := parseSyntax("  a.b ")
+		selection := syntax.Children()[0]
+		Expect(selection.Kind()).To(Equal(cst.Selection))
+		Expect(int(selection.Start())).To(Equal(2))
+		Expect(int(selection.End())).To(Equal(5))
+		Expect(selection.Text()).To(Equal("a.b"))
+		Expect(selection.Parent()).To(Equal(syntax))
+	})
+	It("lowers the syntax to the elements parsed", func() {
+		for _, text := range []string{
+			"a", "1 + 2 * 3", "-a + b!", "(a + b) * c", "a.b(c, d: e, :f)", "a[b]", "a[b] = c",
+			"if (a) { b } else { c }", "when (a) { 1 -> { b }\n else -> { c } }", "when { a -> { b } }",
+			"{ a: Int, b = 1 -> a + b }: Int", "{! a -> a !}", "let a = { 1 }", "let a: Int = 1",
+			"var a: Int = 1", "val a = [1, 2]", "val b = [!a: 1, :b, ...c!]", "val c = [<Int> ...a, 1]",
+			"let a = < b: Int, var c: Int, let d = 1, { a: Int -> Int }, ...e >", "val a: *Int[] & B = c",
+			"loop l { break l }", "while (a) { continue }", "while l (a) { break l }", "return a", "return", "...Dyego0\n a + b",
+			"let v = <| infix operator (+, -) after * left, prefix operator ! right, ...dyego |>",
+			"let v = <| infix operator identifiers before infix + right |>", "a, b\nc",
+			"let v = <| infix operator a same as infix b none, postfix operator c same as d left |>",
+			"...<| infix operator `? :` right, prefix operator `if then else` right |>\na ? b : if c then d else e",
+			"...<| postfix operator `[ ]` left, infix operator + left |>\na[b] + c [d + e]",
+			"let f = { X, Y: Int | x: X -> x }: X", "f(<Int, Box<Int> > 1)", "let T = < X -> a: X, b: Box<X>[] >", "val a: Int? = null",
+			"a?.b?.c(d)",
+		} {
+			element, syntax := parseSyntax(text)
+			Expect(describeElement(Lower(syntax))).To(Equal(describeElement(element)), text)
+		}
+	})
+	It("lowers the syntax of text with errors", func() {
+		for _, text := range []string{
+			"loop", "loop {", "while (a { b", "while a) { b }", "let a = ", "a.(b", "val a = [1,",
+			"when (a) { 1 -> }", "a b c", ") a", "{ a: -> }", "let v = <| infix operator |>",
+		} {
+			element, syntax := parseSyntax(text)
+			Expect(syntax.Green().Text()).To(Equal(text))
+			Expect(describeElement(Lower(syntax))).To(Equal(describeElement(element)), text)
+		}
+	})
+	It("lowers the syntax of files", func() {
+		for _, name := range []string{
+			"../examples/Example.dg", "../examples/Simple.dg", "../examples/Simple0.dg", "../builtins/Dyego0_wasm.dg",
+		} {
+			src := readFile(name)
+			text := string(src[:len(src)-1])
+			element, syntax := parseSyntax(text)
+			Expect(syntax.Green().Text()).To(Equal(text))
+			Expect(describeElement(Lower(syntax))).To(Equal(describeElement(element)), name)
+		}
+	})
+})
+
+// describeElement describes the elements of element with their locations
+func describeElement(element ast.Element) string {
+	var result strings.Builder
+	ast.Walk(element, visitorFunc(func(element ast.Element) bool {
+		fmt.Fprintf(&result, "%T %d-%d", element, element.Start(), element.End())
+		switch e := element.(type) {
+		case ast.Name:
+			fmt.Fprintf(&result, " %s", e.Text())
+		case ast.Literal:
+			fmt.Fprintf(&result, " %#v %s", e.Value(), e.Spelling())
+		case ast.Error:
+			fmt.Fprintf(&result, " %s", e.Error())
+		case ast.Storage:
+			fmt.Fprintf(&result, " %v", e.Mutable())
+		case ast.ObjectInitializer:
+			fmt.Fprintf(&result, " %v", e.Mutable())
+		case ast.ArrayInitializer:
+			fmt.Fprintf(&result, " %v", e.Mutable())
+		case ast.VocabularyOperatorDeclaration:
+			fmt.Fprintf(&result, " %s %s", e.Placement(), e.Associativity())
+		case ast.VocabularyOperatorPrecedence:
+			fmt.Fprintf(&result, " %s %s", e.Placement(), e.Relation())
+		}
+		result.WriteString("\n")
+		return true
+	}))
+	return result.String()
+}
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"strings"
+
+	"dyego0/ast"
+)
+
+// Operators are the operators of the vocabularies embedded by the spreads of a module. The
+// vocabularies are embedded in the order the spreads are given, as they are when the module is
+// parsed, so the precedence of the operators the parser turned into calls can be recovered.
+type Operators struct {
+	scope   VocabularyScope
+	context *vocabularyEmbeddingContext
+}
+
+// OperatorPrecedence is the precedence and associativity of an operator in a placement.
+// Operators with a higher level bind more tightly. The lowest operator level is 1; level 0 is the
+// level expressions are parsed at, below every operator.
+type OperatorPrecedence struct {
+	Level         int
+	Associativity ast.OperatorAssociativity
+}
+
+// NewOperators creates an empty set of operators that can embed the vocabularies in scope
+func NewOperators(scope VocabularyScope) *Operators {
+	return &Operators{scope: scope, context: newVocabularyEmbeddingContext()}
+}
+
+// Embed embeds the vocabulary given by the target of a spread, either a vocabulary literal or
+// a reference to a vocabulary in scope. Returns false if target is not a vocabulary.
+func (o *Operators) Embed(target ast.Element) bool {
+	var v vocabulary
+	switch t := target.(type) {
+	case ast.VocabularyLiteral:
+		v, _ = buildVocabulary(o.scope, t)
+	case ast.Name, ast.Selection:
+		if !isVocabularyReference(target) {
+			return false
+		}
+		v, _, _ = lookupVocabulary(o.scope, target)
+	}
+	impl, ok := v.(*vocabularyImpl)
+	if !ok {
+		return false
+	}
+	o.context.embedVocabulary(impl, target)
+	o.context.errors = nil
+	return true
+}
+
+// Find finds the precedence of the operator with the given name and placement. The name of a
+// mixfix operator is its parts separated by spaces, such as ? :.
+func (o *Operators) Find(name string, placement ast.OperatorPlacement) (OperatorPrecedence, bool) {
+	element, ok := o.context.result.Get(operatorKey(name))
+	if !ok {
+		return OperatorPrecedence{}, false
+	}
+	op, ok := element.(operator)
+	if !ok || placement >= ast.UnspecifiedPlacement || op.Levels()[placement] == nil {
+		return OperatorPrecedence{}, false
+	}
+	if strings.Join(op.Parts()[placement], " ") != strings.Join(operatorParts(name), " ") {
+		return OperatorPrecedence{}, false
+	}
+	return OperatorPrecedence{
+		Level:         op.Levels()[placement].Level(),
+		Associativity: op.Associativities()[placement],
+	}, true
+}
+
+// Identifiers finds the precedence of identifiers used as infix operators, such as a dot b
+func (o *Operators) Identifiers() (OperatorPrecedence, bool) {
+	return o.Find(infixTypeMember, ast.Infix)
+}
+
+func isVocabularyReference(element ast.Element) bool {
+	switch e := element.(type) {
+	case ast.Name:
+		return true
+	case ast.Selection:
+		return isVocabularyReference(e.Target())
+	}
+	return false
+}
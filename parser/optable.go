@@ -0,0 +1,157 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"dyego0/ast"
+)
+
+// OperatorTable is the effective operator table of a vocabulary, the precedence levels of its
+// operators from highest to lowest
+type OperatorTable struct {
+	Levels []OperatorLevel
+}
+
+// OperatorLevel is a precedence level of an operator table and the operators declared at it.
+// Operators with a higher level bind more tightly. The lowest operator level is 1; level 0 is the
+// level expressions are parsed at, below every operator.
+type OperatorLevel struct {
+	Level     int
+	Operators []OperatorEntry
+}
+
+// OperatorEntry is an operator of an operator table in one of its placements
+type OperatorEntry struct {
+	// Name is the name of the operator; identifiers used as infix operators are named
+	// identifiers and the parts of a mixfix operator are separated by spaces, such as ? :
+	Name          string
+	Placement     ast.OperatorPlacement
+	Associativity ast.OperatorAssociativity
+
+	// Embedding is the name of the embedded vocabulary that contributed the operator, such as
+	// a::b::Vocab, or "" if it was declared by the vocabulary itself
+	Embedding string
+}
+
+// NewOperatorTable creates the operator table of vocabulary
+func NewOperatorTable(vocabulary Vocabulary) OperatorTable {
+	return newOperatorTable(vocabulary.(*vocabularyImpl))
+}
+
+// Table is the operator table of the vocabularies embedded so far
+func (o *Operators) Table() OperatorTable {
+	return newOperatorTable(o.context.result)
+}
+
+func newOperatorTable(vocabulary *vocabularyImpl) OperatorTable {
+	levels := make(map[int]*OperatorLevel)
+	for _, member := range vocabulary.members {
+		op, ok := member.(operator)
+		if !ok {
+			continue
+		}
+		for placement, precedence := range op.Levels() {
+			if precedence == nil {
+				continue
+			}
+			level, ok := levels[precedence.Level()]
+			if !ok {
+				level = &OperatorLevel{Level: precedence.Level()}
+				levels[precedence.Level()] = level
+			}
+			name := op.Name()
+			if name == infixTypeMember {
+				name = "identifiers"
+			} else if parts := op.Parts()[placement]; parts != nil {
+				name = strings.Join(parts, " ")
+			}
+			level.Operators = append(level.Operators, OperatorEntry{
+				Name:          name,
+				Placement:     ast.OperatorPlacement(placement),
+				Associativity: op.Associativities()[placement],
+				Embedding:     op.Embeddings()[placement],
+			})
+		}
+	}
+	var result OperatorTable
+	for _, level := range levels {
+		sort.Slice(level.Operators, func(i, j int) bool {
+			a, b := level.Operators[i], level.Operators[j]
+			if a.Placement != b.Placement {
+				return a.Placement < b.Placement
+			}
+			return a.Name < b.Name
+		})
+		result.Levels = append(result.Levels, *level)
+	}
+	sort.Slice(result.Levels, func(i, j int) bool {
+		return result.Levels[i].Level > result.Levels[j].Level
+	})
+	return result
+}
+
+// String formats the table as a line per level followed by a line per operator, such as
+//
+//	level 3
+//	  infix `*` left from dyego
+func (t OperatorTable) String() string {
+	var b strings.Builder
+	for _, level := range t.Levels {
+		fmt.Fprintf(&b, "level %d\n", level.Level)
+		for _, op := range level.Operators {
+			fmt.Fprintf(&b, "  %s %s %s", op.Placement, operatorName(op.Name), op.Associativity)
+			if op.Embedding != "" {
+				fmt.Fprintf(&b, " from %s", op.Embedding)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// operatorName quotes the name of an operator that is not an identifier
+func operatorName(name string) string {
+	if name == "identifiers" {
+		return name
+	}
+	for index, r := range name {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || index > 0 && r >= '0' && r <= '9') {
+			return "`" + name + "`"
+		}
+	}
+	return name
+}
+
+type jsonOperatorEntry struct {
+	Name          string `json:"name"`
+	Placement     string `json:"placement"`
+	Associativity string `json:"associativity"`
+	Embedding     string `json:"embedding,omitempty"`
+}
+
+type jsonOperatorLevel struct {
+	Level     int                 `json:"level"`
+	Operators []jsonOperatorEntry `json:"operators"`
+}
+
+// MarshalJSON encodes the table as an array of levels from highest to lowest, each with its
+// operators, placements and associativities given by name
+func (t OperatorTable) MarshalJSON() ([]byte, error) {
+	levels := []jsonOperatorLevel{}
+	for _, level := range t.Levels {
+		operators := []jsonOperatorEntry{}
+		for _, op := range level.Operators {
+			operators = append(operators, jsonOperatorEntry{
+				Name:          op.Name,
+				Placement:     op.Placement.String(),
+				Associativity: op.Associativity.String(),
+				Embedding:     op.Embedding,
+			})
+		}
+		levels = append(levels, jsonOperatorLevel{Level: level.Level, Operators: operators})
+	}
+	return json.Marshal(levels)
+}
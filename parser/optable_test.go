@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"dyego0/ast"
+	"dyego0/location"
+)
+
+var _ = Describe("optable", func() {
+	build := func(scope VocabularyScope, source string) Vocabulary {
+		definition := parseNamed(source, "optable", newVocabularyScope()).(ast.Definition)
+		vocabulary, errors := BuildVocabulary(scope, definition.Value().(ast.VocabularyLiteral))
+		Expect(errors).To(BeNil())
+		return vocabulary
+	}
+	var base, extended Vocabulary
+	var scope *vocabularyScopeImpl
+	BeforeEach(func() {
+		base = build(newVocabularyScope(), "let Base = <| infix operator (`*`, `/`) left, "+
+			"infix operator `+` left, prefix operator `-` right, infix operator identifiers left |>")
+		scope = newVocabularyScope()
+		scope.members["Base"] = base
+		extended = build(scope, "let Extended = <|\n  ...Base\n  infix operator `**` before infix `*` right\n"+
+			"  infix operator `==` after infix `+` left\n|>")
+	})
+
+	It("lists the levels from highest to lowest", func() {
+		Expect(NewOperatorTable(base).String()).To(Equal("level 4\n" +
+			"  infix `*` left\n" +
+			"  infix `/` left\n" +
+			"level 3\n" +
+			"  infix `+` left\n" +
+			"level 2\n" +
+			"  prefix `-` right\n" +
+			"level 1\n" +
+			"  infix identifiers left\n"))
+	})
+	It("records the embedding that contributed an operator", func() {
+		Expect(NewOperatorTable(extended).String()).To(Equal("level 6\n" +
+			"  infix `**` right\n" +
+			"level 5\n" +
+			"  infix `*` left from Base\n" +
+			"  infix `/` left from Base\n" +
+			"level 4\n" +
+			"  infix `+` left from Base\n" +
+			"level 3\n" +
+			"  infix `==` left\n" +
+			"level 2\n" +
+			"  prefix `-` right from Base\n" +
+			"level 1\n" +
+			"  infix identifiers left from Base\n"))
+	})
+	It("names a mixfix operator by its parts", func() {
+		Expect(NewOperatorTable(build(newVocabularyScope(),
+			"let V = <| infix operator `? :` right, postfix operator `[ ]` before infix `? :` left |>")).String()).
+			To(Equal("level 2\n  postfix `[ ]` left\nlevel 1\n  infix `? :` right\n"))
+	})
+	It("can encode a table as JSON", func() {
+		data, err := json.Marshal(NewOperatorTable(build(newVocabularyScope(),
+			"let V = <| infix operator `+` left, prefix operator not right |>")))
+		Expect(err).To(BeNil())
+		Expect(string(data)).To(Equal(`[` +
+			`{"level":2,"operators":[{"name":"+","placement":"infix","associativity":"left"}]},` +
+			`{"level":1,"operators":[{"name":"not","placement":"prefix","associativity":"right"}]}]`))
+	})
+	It("can list the operators embedded by a module", func() {
+		operators := NewOperators(scope)
+		b := ast.NewBuilder(location.NewLocation(0, 0))
+		b.PushContext()
+		Expect(operators.Embed(b.Name("Base"))).To(BeTrue())
+		table := operators.Table()
+		Expect(table.Levels).To(HaveLen(4))
+		Expect(table.Levels[0].Operators[0]).To(Equal(OperatorEntry{
+			Name:          "*",
+			Placement:     ast.Infix,
+			Associativity: ast.Left,
+			Embedding:     "Base",
+		}))
+	})
+	It("lists the same operators as the Operators vocabulary of the builtins", func() {
+		var literal ast.VocabularyLiteral
+		ast.Walk(parseFile("../builtins/Dyego0_wasm.dg"), visitorFunc(func(element ast.Element) bool {
+			if definition, ok := element.(ast.Definition); ok && definition.Name().Text() == "Operators" {
+				literal, _ = definition.Value().(ast.VocabularyLiteral)
+				return false
+			}
+			return true
+		}))
+		Expect(literal).ToNot(BeNil())
+		builtins, errors := BuildVocabulary(newVocabularyScope(), literal)
+		Expect(errors).To(BeNil())
+		defaults, _ := DefaultVocabularyScope().Get("Dyego0")
+		Expect(NewOperatorTable(builtins).String()).To(Equal(NewOperatorTable(defaults.(Vocabulary)).String()))
+	})
+})
@@ -2,9 +2,11 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 
 	"dyego0/assert"
 	"dyego0/ast"
+	"dyego0/cst"
 	"dyego0/errors"
 	"dyego0/location"
 	"dyego0/scanner"
@@ -15,6 +17,14 @@ import (
 type Parser interface {
 	Errors() []errors.Error
 	Parse() ast.Element
+
+	// Comments are the comments found while parsing, in source order. Comments are only found if the
+	// scanner was created with scanner.TriviaScan.
+	Comments() []ast.Comment
+
+	// Syntax is the concrete syntax tree of the text parsed by Parse, or nil if the parser was not
+	// created by NewSyntaxParser
+	Syntax() *cst.Node
 }
 
 type parser struct {
@@ -24,11 +34,25 @@ type parser struct {
 	pseudo            tokens.PseudoToken
 	operator          *selectedOperator
 	excludedOperators []operator
+	excludedParts     []string
 	separatorState    separatorState
-	scope             vocabularyScope
+	scope             VocabularyScope
 	vocabulary        vocabulary
 	embeddingContext  *vocabularyEmbeddingContext
 	errors            []errors.Error
+	comments          []ast.Comment
+	embeddings        []embedding
+
+	// syntax records the concrete syntax tree, if requested, which is syntaxTree once the text is
+	// parsed. The text starts at syntaxPos.
+	syntax     *cst.Builder
+	syntaxTree *cst.Node
+	syntaxPos  location.Pos
+
+	// recovering is true after an error is reported until the parser synchronizes with the source
+	// again. Errors are not reported while recovering as they are most likely caused by the
+	// error already reported.
+	recovering bool
 }
 
 type separatorState int
@@ -52,7 +76,7 @@ func (s separatorState) String() string {
 }
 
 // NewParser creates a new parser
-func NewParser(scanner *scanner.Scanner, scope vocabularyScope) Parser {
+func NewParser(scanner *scanner.Scanner, scope VocabularyScope) Parser {
 	builder := ast.NewBuilder(scanner)
 	context := newVocabularyEmbeddingContext()
 	p := &parser{
@@ -69,42 +93,115 @@ func NewParser(scanner *scanner.Scanner, scope vocabularyScope) Parser {
 	return p
 }
 
+// NewSyntaxParser creates a parser that also records the concrete syntax tree of the text it
+// parses, with every token of the text, which Parse lowers to the elements it returns
+func NewSyntaxParser(scanner *scanner.Scanner, scope VocabularyScope) Parser {
+	p := NewParser(scanner, scope).(*parser)
+	p.syntax = cst.NewBuilder()
+	p.syntaxPos = scanner.PreviousEnd()
+	return p
+}
+
 func (p *parser) Parse() ast.Element {
-	expr := p.sequence()
-	p.expect(tokens.EOF)
-	return expr
+	mark := p.mark()
+	result := p.sequence()
+	for p.current != tokens.EOF {
+		// A closing bracket that does not close an open bracket
+		p.builder.PushContext()
+		errorMark := p.mark()
+		received := p.current
+		p.next()
+		p.synchronize()
+		err := p.report("Expected %v, received %v", tokens.EOF, received)
+		p.finishError(errorMark, err)
+		p.builder.PopContext()
+		result = p.builder.Sequence(result, err)
+		p.finish(mark, cst.Sequence)
+		if p.separator() || p.startsStatement() {
+			p.recovering = false
+			result = p.builder.Sequence(result, p.sequence())
+			p.finish(mark, cst.Sequence)
+		}
+	}
+	if p.syntax != nil {
+		p.syntax.Token(tokens.EOF, p.scanner.Leading(), "")
+		p.syntaxTree = p.syntax.Tree(cst.File, p.syntaxPos)
+	}
+	return result
 }
 
 func (p *parser) Errors() []errors.Error {
 	return p.errors
 }
 
-func (p *parser) report(msg string, args ...interface{}) errors.Error {
+func (p *parser) Comments() []ast.Comment {
+	return p.comments
+}
+
+func (p *parser) Syntax() *cst.Node {
+	return p.syntaxTree
+}
+
+// mark returns the state of the syntax recorded from which a node of the syntax tree is finished
+func (p *parser) mark() cst.Mark {
+	if p.syntax == nil {
+		return nil
+	}
+	return p.syntax.Mark()
+}
+
+// finish groups the syntax recorded since mark in a node of kind
+func (p *parser) finish(mark cst.Mark, kind cst.Kind) {
+	if p.syntax != nil {
+		p.syntax.Finish(mark, kind)
+	}
+}
+
+// finishError groups the syntax recorded since mark in a node for err
+func (p *parser) finishError(mark cst.Mark, err errors.Error) {
+	if p.syntax != nil {
+		p.syntax.FinishError(mark, err.Error())
+	}
+}
+
+// report reports an error at the current context and returns an error element for it. The error
+// is not reported if the parser is recovering from a previous error.
+func (p *parser) report(msg string, args ...interface{}) ast.Error {
 	err := p.builder.Error(msg, args...)
 	errors := p.errors
 	l := len(errors)
-	if l == 0 || errors[l-1].Start() != err.Start() {
+	if !p.recovering && (l == 0 || errors[l-1].Start() != err.Start()) {
 		p.errors = append(p.errors, err)
 	}
+	p.recovering = true
 	return err
 }
 
-func (p *parser) reportElement(element ast.Element, msg string, args ...interface{}) ast.Element {
-	err := errors.New(element, msg, args...)
+func (p *parser) reportElement(element ast.Element, msg string, args ...interface{}) ast.Error {
+	err := ast.NewError(element, msg, args...)
 	p.errors = append(p.errors, err)
 	return err
 }
 
+// expect consumes t or reports it is missing. A missing closing bracket is found by skipping the
+// tokens in the way; any other missing token is treated as if it was present.
 func (p *parser) expect(t tokens.Token) {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
 	if p.current == t {
 		p.next()
-	} else {
-		p.builder.PushContext()
-		defer p.builder.PopContext()
-		p.report("Expected %v, received %v", t, p.current)
-		p.next()
+		p.recovering = false
+		return
+	}
+	p.builder.PushContext()
+	p.report("Expected %v, received %v", t, p.current)
+	p.builder.PopContext()
+	if isClosing(t) {
+		p.skipTo(t)
+		if p.current == t {
+			p.next()
+			p.recovering = false
+		}
 	}
 }
 
@@ -113,6 +210,7 @@ func (p *parser) expectPseudo(t tokens.PseudoToken) {
 	defer p.builder.PopContext()
 	if p.pseudo == t {
 		p.next()
+		p.recovering = false
 	} else {
 		p.builder.PushContext()
 		defer p.builder.PopContext()
@@ -127,6 +225,7 @@ func (p *parser) expectPseudo(t tokens.PseudoToken) {
 func (p *parser) expects(ts ...tokens.Token) ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	mark := p.mark()
 	first := true
 	result := ""
 	for _, t := range ts {
@@ -136,14 +235,16 @@ func (p *parser) expects(ts ...tokens.Token) ast.Element {
 		result += t.String()
 		first = false
 	}
-	p.report("Expected one of %s, received %v", result, p.current)
-	p.next()
-	return p.errors[len(p.errors)-1]
+	err := p.report("Expected one of %s, received %v", result, p.current)
+	p.skipUnexpected()
+	p.finishError(mark, err)
+	return err
 }
 
 func (p *parser) expectsPseudo(ts ...tokens.PseudoToken) ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	mark := p.mark()
 	first := true
 	result := ""
 	for _, t := range ts {
@@ -153,18 +254,21 @@ func (p *parser) expectsPseudo(ts ...tokens.PseudoToken) ast.Element {
 		result += t.String()
 		first = false
 	}
+	var err ast.Error
 	if p.current == tokens.Identifier && p.pseudo != tokens.InvalidPseudoToken {
-		p.report("Expected one of %s, received %s", result, p.pseudo)
+		err = p.report("Expected one of %s, received %s", result, p.pseudo)
 	} else {
-		p.report("Expected one of %s, received %s", result, p.current)
+		err = p.report("Expected one of %s, received %s", result, p.current)
 	}
-	p.next()
-	return p.errors[len(p.errors)-1]
+	p.skipUnexpected()
+	p.finishError(mark, err)
+	return err
 }
 
 func (p *parser) expectItems(items ...interface{}) ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	mark := p.mark()
 	first := true
 	result := ""
 	for _, t := range items {
@@ -174,17 +278,114 @@ func (p *parser) expectItems(items ...interface{}) ast.Element {
 		result += fmt.Sprintf("%s", t)
 		first = false
 	}
+	var err ast.Error
 	if p.current == tokens.Identifier && p.pseudo != tokens.InvalidPseudoToken {
-		p.report("Expected one of %s, received %s", result, p.pseudo)
+		err = p.report("Expected one of %s, received %s", result, p.pseudo)
 	} else {
-		p.report("Expected one of %s, received %s", result, p.current)
+		err = p.report("Expected one of %s, received %s", result, p.current)
 	}
-	p.next()
-	return p.errors[len(p.errors)-1]
+	p.skipUnexpected()
+	p.finishError(mark, err)
+	return err
+}
+
+// skipUnexpected skips the unexpected current token unless the parser can synchronize at it
+func (p *parser) skipUnexpected() {
+	if !p.synchronizes() {
+		p.next()
+	}
+}
+
+// synchronizes returns true if the parser can synchronize with the source at the current token.
+// The parser synchronizes at the end of the file, at closing brackets, which are assumed to close
+// an enclosing bracket, and at let, val and var at the start of a line which most likely start a
+// statement.
+func (p *parser) synchronizes() bool {
+	switch p.current {
+	case tokens.EOF:
+		return true
+	case tokens.Let, tokens.Val, tokens.Var:
+		return p.scanner.NewLineLocation().IsValid()
+	}
+	return isClosing(p.current)
+}
+
+func isOpening(t tokens.Token) bool {
+	switch t {
+	case tokens.LParen, tokens.LBrace, tokens.LBrack, tokens.LBraceBang, tokens.LBrackBang,
+		tokens.VocabularyStart:
+		return true
+	}
+	return false
+}
+
+func isClosing(t tokens.Token) bool {
+	switch t {
+	case tokens.RParen, tokens.RBrace, tokens.RBrack, tokens.BangRBrace, tokens.BangRBrack,
+		tokens.VocabularyEnd:
+		return true
+	}
+	return false
+}
+
+// skipTo skips tokens until the closing bracket t is found. Bracketed tokens are skipped with their
+// brackets. Skipping stops early where the parser synchronizes.
+func (p *parser) skipTo(t tokens.Token) {
+	depth := 0
+	for {
+		switch {
+		case isOpening(p.current):
+			depth++
+		case depth > 0 && isClosing(p.current):
+			depth--
+		case p.current == tokens.EOF, depth == 0 && p.synchronizes():
+			return
+		}
+		p.next()
+	}
+}
+
+// synchronize skips the tokens of a statement that cannot be parsed. Skipping stops at a separator
+// or where the parser synchronizes. Bracketed tokens are skipped with their brackets.
+func (p *parser) synchronize() {
+	depth := 0
+	for {
+		switch {
+		case isOpening(p.current):
+			depth++
+		case depth > 0 && isClosing(p.current):
+			depth--
+		case p.current == tokens.EOF, depth == 0 && (p.synchronizes() || p.current == tokens.Comma):
+			return
+		}
+		p.next()
+		if depth == 0 && p.scanner.NewLineLocation().IsValid() {
+			return
+		}
+	}
+}
+
+// startsStatement returns true if the current token can start a statement of a sequence
+func (p *parser) startsStatement() bool {
+	switch p.current {
+	case tokens.Literal, tokens.True, tokens.False, tokens.Null, tokens.Identifier, tokens.LBrace, tokens.LParen,
+		tokens.Symbol, tokens.Let, tokens.LBrack, tokens.LBrackBang, tokens.LBraceBang, tokens.Var, tokens.Val,
+		tokens.Return:
+		return true
+	}
+	return false
 }
 
 func (p *parser) next() tokens.Token {
+	if p.syntax != nil {
+		p.syntax.Token(p.current, p.scanner.Leading(), p.scanner.Text())
+	}
 	var next = p.scanner.Next()
+	for _, trivia := range p.scanner.Trivia() {
+		if trivia.Kind != scanner.BlankLine {
+			p.comments = append(p.comments, ast.NewComment(trivia.Start, trivia.End, trivia.Text, trivia.OwnLine))
+		}
+	}
 	p.current = next
 	p.separatorState = normalState
 	p.operator = nil
@@ -200,6 +401,7 @@ func (p *parser) next() tokens.Token {
 func (p *parser) expectIdent() ast.Name {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.Name)
 	if p.current == tokens.Identifier {
 		result := p.builder.Name(p.scanner.Value().(string))
 		p.next()
@@ -210,21 +412,35 @@ func (p *parser) expectIdent() ast.Name {
 	return result
 }
 
+// preserve records the state of the parser so it can be restored to backtrack. The builder is not
+// preserved as the locations it records are balanced by the time the parser is restored and the
+// deferred calls that pop them are bound to p.builder. The errors and comments are preserved
+// without spare capacity so appending to them after backtracking cannot overwrite those of a
+// preserved alternative.
 func (p *parser) preserve() *parser {
-	scanner := p.scanner.Clone()
-	builder := p.builder.Clone(scanner)
-	return &parser{scanner: scanner, builder: builder, current: p.current, pseudo: p.pseudo, operator: p.operator,
-		separatorState: p.separatorState, errors: p.errors}
+	errors, comments := p.errors[:len(p.errors):len(p.errors)], p.comments[:len(p.comments):len(p.comments)]
+	var syntax *cst.Builder
+	if p.syntax != nil {
+		preserved := *p.syntax
+		syntax = &preserved
+	}
+	return &parser{scanner: p.scanner.Clone(), current: p.current, pseudo: p.pseudo, operator: p.operator,
+		separatorState: p.separatorState, errors: errors, comments: comments, syntax: syntax,
+		recovering: p.recovering}
 }
 
 func (p *parser) restore(parser *parser) {
-	p.scanner = parser.scanner
-	p.builder = parser.builder
+	*p.scanner = *parser.scanner
 	p.current = parser.current
 	p.pseudo = parser.pseudo
 	p.operator = parser.operator
 	p.separatorState = parser.separatorState
 	p.errors = parser.errors
+	p.comments = parser.comments
+	if p.syntax != nil {
+		*p.syntax = *parser.syntax
+	}
+	p.recovering = parser.recovering
 }
 
 func (p *parser) firstOf(options ...func() ast.Element) ast.Element {
@@ -234,6 +450,7 @@ func (p *parser) firstOf(options ...func() ast.Element) ast.Element {
 	longestErrorEnd := location.Pos(0)
 	var errorResult ast.Element
 	for _, option := range options {
+		p.recovering = false
 		result := option()
 		if len(p.errors) > firstErrorIndex {
 			e := p.errors[firstErrorIndex].End()
@@ -259,6 +476,7 @@ func (p *parser) firstOfArray(options ...func() []ast.Element) []ast.Element {
 	longestErrorEnd := location.Pos(0)
 	var errorResult []ast.Element
 	for _, option := range options {
+		p.recovering = false
 		result := option()
 		if len(p.errors) > firstErrorIndex {
 			e := p.errors[firstErrorIndex].End()
@@ -299,7 +517,7 @@ func (p *parser) separator() bool {
 }
 
 var primitiveTokens = []tokens.Token{
-	tokens.Literal, tokens.True, tokens.False, tokens.Identifier, tokens.LBrace, tokens.LParen,
+	tokens.Literal, tokens.True, tokens.False, tokens.Null, tokens.Identifier, tokens.LBrace, tokens.LParen,
 	tokens.Symbol, tokens.Let, tokens.LBrack, tokens.LBrackBang, tokens.LBraceBang,
 }
 
@@ -307,6 +525,54 @@ func (p *parser) sequence() ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
 
+	mark := p.mark()
+	left, separated := p.statement()
+	if separated && p.startsStatement() {
+		p.recovering = false
+		right := p.sequence()
+		p.finish(mark, cst.Sequence)
+		return p.builder.Sequence(left, right)
+	}
+	return left
+}
+
+// statements parses the statements of a file up to the statement that starts at limit, if limit
+// is valid, or to the end of the file. The statements are parsed as sequence() parses them but are
+// returned separately so they can be reused by a Tree. Returns the statements and the start of
+// their first tokens, which precede the statement for a parenthesized expression.
+func (p *parser) statements(limit location.Pos) ([]ast.Element, []location.Pos) {
+	var result []ast.Element
+	var starts []location.Pos
+	for p.current != tokens.EOF && (!limit.IsValid() || p.scanner.Start() < limit) {
+		p.recovering = false
+		starts = append(starts, p.scanner.Start())
+		p.builder.PushContext()
+		mark := p.mark()
+		if p.startsStatement() {
+			statement, _ := p.statement()
+			result = append(result, statement)
+		} else {
+			// A token that cannot start a statement, such as a closing bracket that does not
+			// close an open bracket
+			received := p.current
+			p.next()
+			if !p.scanner.NewLineLocation().IsValid() {
+				p.synchronize()
+			}
+			err := p.report("Expected a statement, received %v", received)
+			p.finishError(mark, err)
+			result = append(result, err)
+			p.separator()
+		}
+		p.builder.PopContext()
+	}
+	return result, starts
+}
+
+// statement parses a statement of a sequence in the context pushed by the caller. Returns the
+// statement and whether it is separated from what follows it.
+func (p *parser) statement() (ast.Element, bool) {
+	mark := p.mark()
 	var left ast.Element
 	switch p.current {
 	case tokens.Identifier:
@@ -331,7 +597,7 @@ func (p *parser) sequence() ast.Element {
 		default:
 			left = p.expression()
 		}
-	case tokens.Literal, tokens.True, tokens.False, tokens.LBrace, tokens.LParen, tokens.Let,
+	case tokens.Literal, tokens.True, tokens.False, tokens.Null, tokens.LBrace, tokens.LParen, tokens.Let,
 		tokens.LBrack, tokens.LBrackBang, tokens.LBraceBang:
 		left = p.expression()
 	case tokens.Var, tokens.Val:
@@ -341,21 +607,27 @@ func (p *parser) sequence() ast.Element {
 	default:
 		left = p.expects(primitiveTokens...)
 	}
-	if p.separator() {
-		switch p.current {
-		case tokens.Literal, tokens.True, tokens.False, tokens.Identifier, tokens.LBrace, tokens.LParen, tokens.Symbol,
-			tokens.Let, tokens.LBrack, tokens.LBrackBang, tokens.LBraceBang, tokens.Var, tokens.Val, tokens.Return:
-			right := p.sequence()
-			return p.builder.Sequence(left, right)
-		}
+	separated := p.separator()
+	if !separated && !isClosing(p.current) && p.current != tokens.EOF {
+		// The statement is followed by tokens that cannot follow it
+		p.builder.PushContext()
+		errorMark := p.mark()
+		received := p.current
+		p.synchronize()
+		err := p.report("Expected a separator, received %v", received)
+		p.finishError(errorMark, err)
+		p.builder.PopContext()
+		left = p.builder.Sequence(left, err)
+		p.finish(mark, cst.Sequence)
+		separated = p.separator() || p.startsStatement()
 	}
-	return left
+	return left, separated
 }
 
 func (p *parser) expression() ast.Element {
 	switch p.current {
-	case tokens.Literal, tokens.True, tokens.False, tokens.Identifier, tokens.LBrace, tokens.LParen, tokens.Symbol,
-		tokens.Let, tokens.LBrack, tokens.LBrackBang, tokens.LBraceBang:
+	case tokens.Literal, tokens.True, tokens.False, tokens.Null, tokens.Identifier, tokens.LBrace, tokens.LParen,
+		tokens.Symbol, tokens.Let, tokens.LBrack, tokens.LBrackBang, tokens.LBraceBang:
 		return p.operatorExpression(p.embeddingContext.lowestLevel)
 	default:
 		return p.expects(primitiveTokens...)
@@ -367,6 +639,9 @@ type selectedOperator struct {
 	level     precedenceLevel
 	assoc     ast.OperatorAssociativity
 	placement ast.OperatorPlacement
+
+	// parts are the parts of a mixfix operator that follow its first part
+	parts []string
 }
 
 func (op *selectedOperator) String() string {
@@ -397,6 +672,17 @@ func (p *parser) popExcludedOperators() {
 	p.excludedOperators = p.excludedOperators[0 : len(p.excludedOperators)-1]
 }
 
+// excludedPart returns true if text is a part of a mixfix operator expected after the operand
+// being parsed, which ends the operand rather than continues it
+func (p *parser) excludedPart(text string) bool {
+	for _, part := range p.excludedParts {
+		if part == text {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *parser) excludedOperator(operator operator) bool {
 	for _, excludedOp := range p.excludedOperators {
 		if excludedOp != nil && excludedOp == operator {
@@ -421,8 +707,15 @@ func (p *parser) findOperator(placement ast.OperatorPlacement, includeTypeMember
 			break
 		}
 		fallthrough
-	case tokens.Symbol:
-		text := p.scanner.Value().(string)
+	case tokens.Symbol, tokens.LBrack:
+		text := "["
+		if p.current != tokens.LBrack {
+			text = p.scanner.Value().(string)
+		}
+		if p.excludedPart(text) {
+			p.operator = noOperatorSentinal
+			return nil
+		}
 		element, ok := p.vocabulary.Get(text)
 		if !ok {
 			if includeTypeMember && placement == ast.Infix && p.current == tokens.Identifier {
@@ -448,11 +741,18 @@ func (p *parser) findOperator(placement ast.OperatorPlacement, includeTypeMember
 			p.operator = noOperatorSentinal
 			return nil
 		}
+		parts := op.Parts()[placement]
+		if parts != nil {
+			text = strings.Join(parts, " ")
+		}
 		if placement == ast.Postfix {
 			text = "postfix " + text
 		}
 		name := p.builder.Name(text)
 		p.operator = selectOp(name, op, placement)
+		if p.operator != nil && parts != nil {
+			p.operator.parts = parts[1:]
+		}
 		return p.operator
 	}
 	p.operator = noOperatorSentinal
@@ -467,30 +767,95 @@ func (p *parser) binaryOp(target ast.Element, o *selectedOperator, right ast.Ele
 	return p.builder.Call(p.builder.Selection(target, o.name), []ast.Element{right})
 }
 
+func (p *parser) mixfixOp(target ast.Element, o *selectedOperator, arguments []ast.Element) ast.Element {
+	return p.builder.Call(p.builder.Selection(target, o.name), arguments)
+}
+
+// mixfixOperands parses the operands of a mixfix operator after its first part, an operand before
+// each of its other parts and, if trailing, the operand after its last part
+func (p *parser) mixfixOperands(op *selectedOperator, trailing bool) []ast.Element {
+	var operands []ast.Element
+	for _, part := range op.parts {
+		p.excludedParts = append(p.excludedParts, part)
+		operands = append(operands, p.expression())
+		p.excludedParts = p.excludedParts[0 : len(p.excludedParts)-1]
+		p.expectPart(part)
+	}
+	if trailing {
+		p.separatorState = wasInfixState
+		operands = append(operands, p.operatorExpression(op.level))
+	}
+	return operands
+}
+
+// expectPart consumes a part of a mixfix operator or reports it is missing
+func (p *parser) expectPart(part string) {
+	p.builder.PushContext()
+	defer p.builder.PopContext()
+	if p.scanner.Text() == part {
+		p.next()
+		p.recovering = false
+		return
+	}
+	p.builder.PushContext()
+	p.report("Expected %s, received %v", part, p.current)
+	p.builder.PopContext()
+}
+
 func (p *parser) operatorExpression(level precedenceLevel) ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
 
+	mark := p.mark()
 	var left ast.Element
 	op := p.findOperator(ast.Prefix, false)
 	if op != nil && op.isHigher(level) {
 		p.next()
-		left = p.unaryOp(p.operatorExpression(op.level), op)
+		if op.parts != nil {
+			operands := p.mixfixOperands(op, true)
+			left = p.mixfixOp(operands[0], op, operands[1:])
+		} else {
+			left = p.unaryOp(p.operatorExpression(op.level), op)
+		}
+		p.finish(mark, cst.Prefix)
 	} else {
 		left = p.simpleExpression()
 	}
 	op = p.findOperator(ast.Postfix, false)
 	for op != nil && op.isHigher(level) {
 		p.next()
-		left = p.unaryOp(left, op)
+		if op.parts != nil {
+			left = p.mixfixOp(left, op, p.mixfixOperands(op, false))
+		} else {
+			left = p.unaryOp(left, op)
+		}
+		p.finish(mark, cst.Postfix)
 		op = p.findOperator(ast.Postfix, false)
 	}
 	op = p.findOperator(ast.Infix, !p.scanner.NewLineLocation().IsValid())
+	var previous *selectedOperator
 	for op != nil && op.isHigher(level) {
+		if previous != nil && previous.level == op.level {
+			// Operators at the level of a non-associative operator cannot follow it or be followed
+			// by it
+			if previous.assoc == ast.NonAssociative {
+				p.reportElement(op.name, "Operator '%s' is non-associative and cannot be chained with '%s'",
+					previous.name.Text(), op.name.Text())
+			} else if op.assoc == ast.NonAssociative {
+				p.reportElement(op.name, "Operator '%s' is non-associative and cannot be chained with '%s'",
+					op.name.Text(), previous.name.Text())
+			}
+		}
 		p.next()
 		p.separatorState = wasInfixState
-		right := p.operatorExpression(op.level)
-		left = p.binaryOp(left, op, right)
+		if op.parts != nil {
+			left = p.mixfixOp(left, op, p.mixfixOperands(op, true))
+		} else {
+			right := p.operatorExpression(op.level)
+			left = p.binaryOp(left, op, right)
+		}
+		p.finish(mark, cst.Binary)
+		previous = op
 		op = p.findOperator(ast.Infix, true)
 	}
 	return left
@@ -499,20 +864,27 @@ func (p *parser) operatorExpression(level precedenceLevel) ast.Element {
 func (p *parser) simpleExpression() ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	mark := p.mark()
 	switch p.current {
-	case tokens.Literal, tokens.True, tokens.False, tokens.Identifier, tokens.LBrace, tokens.LParen, tokens.Let,
-		tokens.LBrack, tokens.LBrackBang, tokens.LBraceBang:
+	case tokens.Literal, tokens.True, tokens.False, tokens.Null, tokens.Identifier, tokens.LBrace, tokens.LParen,
+		tokens.Let, tokens.LBrack, tokens.LBrackBang, tokens.LBraceBang:
 		left := p.primitive()
 		for {
 			switch p.current {
 			case tokens.Dot:
-				left = p.selector(left)
+				left = p.selector(mark, left)
+				continue
+			case tokens.QuestionDot:
+				left = p.safeSelector(mark, left)
 				continue
 			case tokens.LParen:
-				left = p.call(left)
+				left = p.call(mark, left)
 				continue
 			case tokens.LBrack:
-				left = p.index(left)
+				if p.bracketOperator() {
+					break
+				}
+				left = p.index(mark, left)
 				continue
 			}
 			break
@@ -521,36 +893,70 @@ func (p *parser) simpleExpression() ast.Element {
 	case tokens.Symbol:
 		text := p.scanner.Value()
 		p.next()
-		return p.report("Symbol '%s' is not defined as an operator in the current vocabulary", text)
+		err := p.report("Symbol '%s' is not defined as an operator in the current vocabulary", text)
+		p.finishError(mark, err)
+		return err
 	default:
 		return p.expects(primitiveTokens...)
 	}
 }
 
-func (p *parser) selector(left ast.Element) ast.Element {
+// bracketOperator returns true if '[' is the first part of a postfix or infix mixfix operator of
+// the vocabulary, such as a [ b ], rather than the start of an index
+func (p *parser) bracketOperator() bool {
+	element, _ := p.vocabulary.Get("[")
+	op, ok := element.(operator)
+	if !ok || p.excludedOperator(op) {
+		return false
+	}
+	return op.Parts()[ast.Postfix] != nil || op.Parts()[ast.Infix] != nil
+}
+
+// selector parses a member selection of left. The syntax of left was recorded since mark.
+func (p *parser) selector(mark cst.Mark, left ast.Element) ast.Element {
+	defer p.finish(mark, cst.Selection)
 	p.expect(tokens.Dot)
 	name := p.expectIdent()
 	return p.builder.Selection(left, name)
 }
 
-func (p *parser) call(left ast.Element) ast.Element {
+// safeSelector parses a safe member selection of left. The syntax of left was recorded since mark.
+func (p *parser) safeSelector(mark cst.Mark, left ast.Element) ast.Element {
+	defer p.finish(mark, cst.SafeSelection)
+	p.expect(tokens.QuestionDot)
+	name := p.expectIdent()
+	return p.builder.SafeSelection(left, name)
+}
+
+// call parses a call of left. The syntax of left was recorded since mark.
+func (p *parser) call(mark cst.Mark, left ast.Element) ast.Element {
+	defer p.finish(mark, cst.Call)
 	p.expect(tokens.LParen)
+	if p.pseudo == tokens.LessThan {
+		typeArguments := p.typeArguments()
+		left = p.builder.Instantiation(left, typeArguments)
+		p.finish(mark, cst.Instantiation)
+	}
 	arguments := p.arguments()
 	p.expect(tokens.RParen)
 	return p.builder.Call(left, arguments)
 }
 
-func (p *parser) index(left ast.Element) ast.Element {
+// index parses an index of left, or an assignment to it, as a call of get or set. The syntax of
+// left was recorded since mark.
+func (p *parser) index(mark cst.Mark, left ast.Element) ast.Element {
 	p.expect(tokens.LBrack)
 	arguments := p.arguments()
 	p.expect(tokens.RBrack)
 	if p.pseudo == tokens.Equal {
 		p.next()
 		arguments = append(arguments, p.expression())
+		p.finish(mark, cst.IndexAssignment)
 		name := p.builder.Name("set")
 		selection := p.builder.Selection(left, name)
 		return p.builder.Call(selection, arguments)
 	}
+	p.finish(mark, cst.Index)
 	name := p.builder.Name("get")
 	selection := p.builder.Selection(left, name)
 	return p.builder.Call(selection, arguments)
@@ -559,18 +965,18 @@ func (p *parser) index(left ast.Element) ast.Element {
 func (p *parser) arguments() []ast.Element {
 	var arguments []ast.Element
 	switch p.current {
-	case tokens.Literal, tokens.True, tokens.False, tokens.Identifier, tokens.LBrace, tokens.LParen, tokens.Symbol,
-		tokens.Colon:
+	case tokens.Literal, tokens.True, tokens.False, tokens.Null, tokens.Identifier, tokens.LBrace, tokens.LParen,
+		tokens.Symbol, tokens.Colon:
 		for {
 			switch p.current {
-			case tokens.Literal, tokens.True, tokens.False, tokens.Identifier, tokens.LBrace, tokens.LParen, tokens.Symbol,
-				tokens.Colon:
+			case tokens.Literal, tokens.True, tokens.False, tokens.Null, tokens.Identifier, tokens.LBrace,
+				tokens.LParen, tokens.Symbol, tokens.Colon:
 				argument := p.argument()
 				arguments = append(arguments, argument)
 				if p.separator() {
 					switch p.current {
-					case tokens.Literal, tokens.True, tokens.False, tokens.Identifier, tokens.LBrace, tokens.LParen,
-						tokens.Symbol, tokens.Colon:
+					case tokens.Literal, tokens.True, tokens.False, tokens.Null, tokens.Identifier, tokens.LBrace,
+						tokens.LParen, tokens.Symbol, tokens.Colon:
 						continue
 					}
 				}
@@ -596,14 +1002,11 @@ func (p *parser) argument() ast.Element {
 func (p *parser) namedArgument() ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.NamedArgument)
 	var name ast.Name
 	if p.current == tokens.Colon {
 		p.next()
-		if p.current != tokens.Identifier {
-			name = p.expectIdent()
-		} else {
-			name = p.builder.Name(p.scanner.Value().(string))
-		}
+		name = p.shorthandName()
 	} else {
 		name = p.expectIdent()
 		p.expect(tokens.Colon)
@@ -612,30 +1015,45 @@ func (p *parser) namedArgument() ast.Element {
 	return p.builder.NamedArgument(name, value)
 }
 
+// shorthandName is the name of a named argument or member initializer written as :name where
+// the name is also the start of the value. The identifier is intentionally not consumed so it is
+// considered part of the value.
+func (p *parser) shorthandName() ast.Name {
+	if p.current != tokens.Identifier {
+		return p.expectIdent()
+	}
+	p.builder.PushContext()
+	defer p.builder.PopContext()
+	return p.builder.Name(p.scanner.Value().(string))
+}
+
 func (p *parser) ifExpression() ast.When {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.If)
 	p.expectPseudo(tokens.If)
 	p.expect(tokens.LParen)
 	target := p.expression()
 	var clauses []ast.Element
 	p.expect(tokens.RParen)
-	{
-		p.builder.PushContext()
-		defer p.builder.PopContext()
-		p.expect(tokens.LBrace)
-		thenPart := p.sequence()
-		p.expect(tokens.RBrace)
-		clauses = append(clauses, p.builder.WhenValueClause(target, thenPart))
-	}
+	p.builder.PushContext()
+	mark := p.mark()
+	p.expect(tokens.LBrace)
+	thenPart := p.sequence()
+	p.expect(tokens.RBrace)
+	p.finish(mark, cst.Then)
+	clauses = append(clauses, p.builder.WhenValueClause(target, thenPart))
+	p.builder.PopContext()
 	if p.pseudo == tokens.Else {
 		p.builder.PushContext()
-		defer p.builder.PopContext()
+		mark := p.mark()
 		p.expectPseudo(tokens.Else)
 		p.expect(tokens.LBrace)
 		elsePart := p.sequence()
 		p.expect(tokens.RBrace)
+		p.finish(mark, cst.Else)
 		clauses = append(clauses, p.builder.WhenElseClause(elsePart))
+		p.builder.PopContext()
 	}
 	return p.builder.When(nil, clauses)
 }
@@ -643,6 +1061,7 @@ func (p *parser) ifExpression() ast.When {
 func (p *parser) whenExpression() ast.When {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.When)
 	p.expectPseudo(tokens.When)
 	var target ast.Element
 	if p.current == tokens.LParen {
@@ -653,7 +1072,6 @@ func (p *parser) whenExpression() ast.When {
 	p.expect(tokens.LBrace)
 	clauses := p.whenClauses()
 	p.expect(tokens.RBrace)
-	p.builder.PushContext()
 	return p.builder.When(target, clauses)
 }
 
@@ -671,7 +1089,7 @@ func (p *parser) whenClauses() []ast.Element {
 				}
 			}
 			fallthrough
-		case tokens.Literal, tokens.True, tokens.False, tokens.LBrace, tokens.LParen, tokens.Symbol:
+		case tokens.Literal, tokens.True, tokens.False, tokens.Null, tokens.LBrace, tokens.LParen, tokens.Symbol:
 			result = append(result, p.whenValueClause())
 			if p.separator() {
 				continue
@@ -679,7 +1097,8 @@ func (p *parser) whenClauses() []ast.Element {
 		}
 		if p.separator() {
 			switch p.current {
-			case tokens.Literal, tokens.True, tokens.False, tokens.Identifier, tokens.LBrace, tokens.LParen, tokens.Symbol:
+			case tokens.Literal, tokens.True, tokens.False, tokens.Null, tokens.Identifier, tokens.LBrace,
+				tokens.LParen, tokens.Symbol:
 				continue
 			}
 		}
@@ -692,6 +1111,7 @@ func (p *parser) whenClauses() []ast.Element {
 func (p *parser) whenElseClause() ast.WhenElseClause {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.WhenElseClause)
 	p.expectPseudo(tokens.Else)
 	p.expectPseudo(tokens.Arrow)
 	p.expect(tokens.LBrace)
@@ -703,6 +1123,7 @@ func (p *parser) whenElseClause() ast.WhenElseClause {
 func (p *parser) whenValueClause() ast.WhenValueClause {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.WhenValueClause)
 	value := p.expression()
 	p.expectPseudo(tokens.Arrow)
 	p.expect(tokens.LBrace)
@@ -714,7 +1135,9 @@ func (p *parser) whenValueClause() ast.WhenValueClause {
 func (p *parser) lambda() ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.Lambda)
 	p.expect(tokens.LBrace)
+	typeParameters := p.lambdaTypeParameters()
 	parameters := p.lambdaParameters()
 	var expression ast.Element
 	if p.current != tokens.RBrace {
@@ -726,12 +1149,13 @@ func (p *parser) lambda() ast.Element {
 		p.next()
 		result = p.typeReference()
 	}
-	return p.builder.Lambda(parameters, expression, result)
+	return p.builder.Lambda(typeParameters, parameters, expression, result)
 }
 
 func (p *parser) intrinsicLambda() ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.IntrinsicLambda)
 	p.expect(tokens.LBraceBang)
 	parameters := p.lambdaParameters()
 	var sequence ast.Element
@@ -762,6 +1186,71 @@ func (p *parser) lambdaParameters() []ast.Parameter {
 	return params
 }
 
+// lambdaTypeParameters parses the type parameters of a generic lambda, X and Y of
+// { X, Y | x: X, y: Y -> ... }
+func (p *parser) lambdaTypeParameters() []ast.TypeParameter {
+	return toTypeParameters(p.firstOfArray(func() []ast.Element {
+		result := p.typeParameters()
+		p.expectPseudo(tokens.Bar)
+		return result
+	}, func() []ast.Element {
+		return nil
+	}))
+}
+
+func toTypeParameters(elements []ast.Element) []ast.TypeParameter {
+	var result []ast.TypeParameter
+	for _, element := range elements {
+		result = append(result, element.(ast.TypeParameter))
+	}
+	return result
+}
+
+func (p *parser) typeParameters() []ast.Element {
+	var result []ast.Element
+	for {
+		result = append(result, p.typeParameter())
+		if p.current == tokens.Comma {
+			p.next()
+			continue
+		}
+		break
+	}
+	return result
+}
+
+func (p *parser) typeParameter() ast.TypeParameter {
+	p.builder.PushContext()
+	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.TypeParameter)
+	name := p.expectIdent()
+	var constraint ast.Element
+	if p.current == tokens.Colon {
+		p.next()
+		constraint = p.typeReference()
+	}
+	return p.builder.TypeParameter(name, constraint)
+}
+
+// typeArguments parses the type arguments of an instantiation, Int of Box<Int> or f(<Int> 1)
+func (p *parser) typeArguments() []ast.Element {
+	p.expectPseudo(tokens.LessThan)
+	var result []ast.Element
+	for {
+		result = append(result, p.typeReference())
+		if p.current == tokens.Comma {
+			p.next()
+			continue
+		}
+		break
+	}
+	if p.current == tokens.Symbol && p.pseudo != tokens.GreaterThan && p.scanner.SplitGreaterThan() {
+		p.pseudo = tokens.GreaterThan
+	}
+	p.expectPseudo(tokens.GreaterThan)
+	return result
+}
+
 func (p *parser) parameters() []ast.Element {
 	var result []ast.Element
 	for {
@@ -782,6 +1271,7 @@ func (p *parser) parameters() []ast.Element {
 func (p *parser) parameter() ast.Parameter {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.Parameter)
 	name := p.expectIdent()
 	var typeReference ast.Element
 	if p.current == tokens.Colon {
@@ -799,18 +1289,27 @@ func (p *parser) parameter() ast.Parameter {
 func (p *parser) primitive() ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	mark := p.mark()
 	switch p.current {
 	case tokens.Literal:
-		result := p.builder.Literal(p.scanner.Value())
+		result := p.builder.Literal(p.scanner.Value(), p.scanner.Text())
 		p.next()
+		p.finish(mark, cst.Literal)
 		return result
 	case tokens.True:
-		result := p.builder.Literal(true)
+		result := p.builder.Literal(true, "true")
 		p.next()
+		p.finish(mark, cst.Literal)
 		return result
 	case tokens.False:
-		result := p.builder.Literal(false)
+		result := p.builder.Literal(false, "false")
 		p.next()
+		p.finish(mark, cst.Literal)
+		return result
+	case tokens.Null:
+		result := p.builder.Literal(nil, "null")
+		p.next()
+		p.finish(mark, cst.Literal)
 		return result
 	case tokens.Identifier:
 		switch p.pseudo {
@@ -821,6 +1320,7 @@ func (p *parser) primitive() ast.Element {
 		}
 		result := p.builder.Name(p.scanner.Value().(string))
 		p.next()
+		p.finish(mark, cst.Name)
 		return result
 	case tokens.LBrace:
 		return p.lambda()
@@ -839,12 +1339,13 @@ func (p *parser) primitive() ast.Element {
 			return p.mutableArrayInitializer()
 		})
 	case tokens.LParen:
-		excludedOperators := p.excludedOperators
-		p.excludedOperators = nil
+		excludedOperators, excludedParts := p.excludedOperators, p.excludedParts
+		p.excludedOperators, p.excludedParts = nil, nil
 		p.expect(tokens.LParen)
 		expr := p.expression()
 		p.expect(tokens.RParen)
-		p.excludedOperators = excludedOperators
+		p.finish(mark, cst.Parenthesized)
+		p.excludedOperators, p.excludedParts = excludedOperators, excludedParts
 		return expr
 	case tokens.Let:
 		return p.definition()
@@ -856,6 +1357,7 @@ func (p *parser) primitive() ast.Element {
 func (p *parser) spreadExpression() ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.Spread)
 	p.expectPseudo(tokens.Spread)
 	var target ast.Element
 	var vocabulary vocabulary
@@ -871,21 +1373,26 @@ func (p *parser) spreadExpression() ast.Element {
 		target = vocabularyLiteral
 	} else {
 		preserved := p.preserve()
-		target := p.spreadReference()
+		p.recovering = false
+		mark := p.mark()
+		target = p.spreadReference()
 		if len(p.errors) > len(preserved.errors) {
 			p.restore(preserved)
-			target := p.expression()
+			target = p.expression()
 			return p.builder.Spread(target)
 		}
 
 		// Find an apply vocabulary
-		v, element := lookupVocabulary(p.scope, target)
+		v, element, message := lookupVocabulary(p.scope, target)
 		vocabulary = v
 		if element != nil {
-			return p.reportElement(element, "Expected a vocabulary reference")
+			err := p.reportElement(element, "%s", message)
+			p.finishError(mark, err)
+			return err
 		}
 	}
 	p.embeddingContext.embedVocabulary(vocabulary.(*vocabularyImpl), target)
+	p.embeddings = append(p.embeddings, embedding{vocabulary: vocabulary.(*vocabularyImpl), target: target})
 	for _, err := range p.embeddingContext.errors {
 		p.reportElement(target, err.message)
 	}
@@ -896,11 +1403,13 @@ func (p *parser) spreadExpression() ast.Element {
 func (p *parser) spreadReference() ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	mark := p.mark()
 	var result ast.Element = p.expectIdent()
 	for p.current == tokens.Scope {
 		p.next()
 		name := p.expectIdent()
 		result = p.builder.Selection(result, name)
+		p.finish(mark, cst.Selection)
 	}
 	return result
 }
@@ -908,11 +1417,13 @@ func (p *parser) spreadReference() ast.Element {
 func (p *parser) typeReference() ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	mark := p.mark()
 	result := p.simpleTypeReference()
 	operator := p.typeOperator()
 	if operator != nil {
 		target := p.builder.Selection(result, operator)
 		result = p.builder.Call(target, []ast.Element{p.typeReference()})
+		p.finish(mark, cst.TypeOperator)
 	}
 	return result
 }
@@ -933,6 +1444,7 @@ func (p *parser) typeOperator() ast.Name {
 func (p *parser) simpleTypeReference() ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	mark := p.mark()
 	result := p.typeReferencePrimitive()
 	for {
 		switch p.current {
@@ -940,16 +1452,24 @@ func (p *parser) simpleTypeReference() ast.Element {
 			p.next()
 			name := p.expectIdent()
 			result = p.builder.Selection(result, name)
+			p.finish(mark, cst.Selection)
 			continue
 		case tokens.LBrack:
 			p.next()
 			p.expect(tokens.RBrack)
 			result = p.builder.SequenceType(result)
+			p.finish(mark, cst.SequenceType)
 			continue
 		case tokens.Symbol:
 			if p.pseudo == tokens.Question {
 				p.next()
 				result = p.builder.OptionalType(result)
+				p.finish(mark, cst.OptionalType)
+				continue
+			} else if p.pseudo == tokens.LessThan {
+				typeArguments := p.typeArguments()
+				result = p.builder.Instantiation(result, typeArguments)
+				p.finish(mark, cst.Instantiation)
 				continue
 			}
 		}
@@ -961,11 +1481,13 @@ func (p *parser) simpleTypeReference() ast.Element {
 func (p *parser) typeReferencePrimitive() ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	mark := p.mark()
 	switch p.current {
 	case tokens.LParen:
 		p.next()
 		result := p.typeReference()
 		p.expect(tokens.RParen)
+		p.finish(mark, cst.Parenthesized)
 		return result
 	case tokens.Symbol:
 		switch p.pseudo {
@@ -973,7 +1495,9 @@ func (p *parser) typeReferencePrimitive() ast.Element {
 			return p.typeLiteral()
 		case tokens.Mult:
 			p.next()
-			return p.builder.ReferenceType(p.typeReference())
+			referent := p.typeReference()
+			p.finish(mark, cst.ReferenceType)
+			return p.builder.ReferenceType(referent)
 		}
 		fallthrough
 	default:
@@ -984,9 +1508,17 @@ func (p *parser) typeReferencePrimitive() ast.Element {
 func (p *parser) typeLiteral() ast.TypeLiteral {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.TypeLiteral)
 	p.expectPseudo(tokens.LessThan)
 	p.pushExcludeOperator(tokens.GreaterThan.String())
 	defer p.popExcludedOperators()
+	typeParameters := toTypeParameters(p.firstOfArray(func() []ast.Element {
+		result := p.typeParameters()
+		p.expectPseudo(tokens.Arrow)
+		return result
+	}, func() []ast.Element {
+		return nil
+	}))
 	var members []ast.Element
 	for {
 		switch p.current {
@@ -1000,7 +1532,7 @@ func (p *parser) typeLiteral() ast.TypeLiteral {
 				continue
 			}
 			fallthrough
-		case tokens.Identifier, tokens.Let:
+		case tokens.Identifier, tokens.Let, tokens.Var:
 			members = append(members, p.typeLiteralMember())
 		}
 		if p.separator() {
@@ -1010,12 +1542,13 @@ func (p *parser) typeLiteral() ast.TypeLiteral {
 	}
 	p.separator()
 	p.expectPseudo(tokens.GreaterThan)
-	return p.builder.TypeLiteral(members)
+	return p.builder.TypeLiteral(typeParameters, members)
 }
 
 func (p *parser) spreadTypeMember() ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.Spread)
 	p.expectPseudo(tokens.Spread)
 	reference := p.typeReference()
 	return p.builder.Spread(reference)
@@ -1024,6 +1557,7 @@ func (p *parser) spreadTypeMember() ast.Element {
 func (p *parser) typeLiteralMember() ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	mark := p.mark()
 	var name ast.Name
 	var mutable bool = false
 	switch p.current {
@@ -1042,6 +1576,7 @@ func (p *parser) typeLiteralMember() ast.Element {
 		} else {
 			value = p.expression()
 		}
+		p.finish(mark, cst.Definition)
 		return p.builder.Definition(name, typ, value)
 	case tokens.Var:
 		p.next()
@@ -1063,12 +1598,14 @@ func (p *parser) typeLiteralMember() ast.Element {
 	if typ == nil && value == nil {
 		p.expectPseudo(tokens.Equal)
 	}
+	p.finish(mark, cst.Storage)
 	return p.builder.Storage(name, typ, value, mutable)
 }
 
 func (p *parser) callableTypeMember() ast.CallableTypeMember {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.CallableTypeMember)
 	p.expect(tokens.LBrace)
 	parameters := p.parameters()
 	p.expectPseudo(tokens.Arrow)
@@ -1080,6 +1617,7 @@ func (p *parser) callableTypeMember() ast.CallableTypeMember {
 func (p *parser) definition() ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.Definition)
 	switch p.current {
 	case tokens.Let:
 		p.next()
@@ -1120,6 +1658,7 @@ func (p *parser) letValue() ast.Element {
 func (p *parser) storage() ast.Storage {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.Storage)
 	var mutable = false
 	if p.current == tokens.Var {
 		mutable = true
@@ -1144,6 +1683,7 @@ func (p *parser) storage() ast.Storage {
 func (p *parser) readOnlyObjectInitializer() ast.ObjectInitializer {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.ObjectInitializer)
 	p.expect(tokens.LBrack)
 	typ := p.initializerType()
 	members := p.memberInitializers()
@@ -1164,6 +1704,7 @@ func (p *parser) initializerType() ast.Element {
 func (p *parser) mutableObjectInitializer() ast.ObjectInitializer {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.ObjectInitializer)
 	p.expect(tokens.LBrackBang)
 	typ := p.initializerType()
 	members := p.memberInitializers()
@@ -1193,27 +1734,25 @@ func (p *parser) memberInitializers() []ast.Element {
 func (p *parser) memberInitializer() ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	mark := p.mark()
 	switch p.current {
 	case tokens.Colon:
 		p.next()
-		var name ast.Name
-		if p.current == tokens.Identifier {
-			name = p.builder.Name(p.scanner.Value().(string))
-		} else {
-			name = p.expectIdent()
-		}
-		// Intentionally do not call p.next() so the identifier is considered part of the expression
+		name := p.shorthandName()
 		value := p.expression()
+		p.finish(mark, cst.NamedMemberInitializer)
 		return p.builder.NamedMemberInitializer(name, nil, value)
 	case tokens.Identifier:
 		name := p.expectIdent()
 		p.expect(tokens.Colon)
 		value := p.expression()
+		p.finish(mark, cst.NamedMemberInitializer)
 		return p.builder.NamedMemberInitializer(name, nil, value)
 	case tokens.Symbol:
 		if p.pseudo == tokens.Spread {
 			p.next()
 			spreadValue := p.expression()
+			p.finish(mark, cst.Spread)
 			return p.builder.Spread(spreadValue)
 		}
 	}
@@ -1223,6 +1762,7 @@ func (p *parser) memberInitializer() ast.Element {
 func (p *parser) readOnlyArrayInitializer() ast.ArrayInitializer {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.ArrayInitializer)
 	p.expect(tokens.LBrack)
 	typ := p.initializerType()
 	elements := p.arrayElements()
@@ -1233,6 +1773,7 @@ func (p *parser) readOnlyArrayInitializer() ast.ArrayInitializer {
 func (p *parser) mutableArrayInitializer() ast.ArrayInitializer {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.ArrayInitializer)
 	p.expect(tokens.LBrackBang)
 	typ := p.initializerType()
 	elements := p.arrayElements()
@@ -1253,7 +1794,7 @@ func (p *parser) arrayElements() []ast.Element {
 				break
 			}
 			fallthrough
-		case tokens.Literal, tokens.True, tokens.False, tokens.Identifier, tokens.LBrace,
+		case tokens.Literal, tokens.True, tokens.False, tokens.Null, tokens.Identifier, tokens.LBrace,
 			tokens.LParen, tokens.Let, tokens.LBrack, tokens.LBrackBang, tokens.LBraceBang:
 			elements = append(elements, p.expression())
 		}
@@ -1269,6 +1810,7 @@ func (p *parser) arrayElements() []ast.Element {
 func (p *parser) vocabularyLiteral() ast.VocabularyLiteral {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.VocabularyLiteral)
 	p.expect(tokens.VocabularyStart)
 	members := p.vocabularyMembers()
 	p.expect(tokens.VocabularyEnd)
@@ -1292,6 +1834,7 @@ func (p *parser) vocabularyMembers() []ast.Element {
 		case tokens.Symbol:
 			if p.pseudo == tokens.Spread {
 				result = append(result, p.vocabularyEmbedding())
+				continue
 			} else {
 				break
 			}
@@ -1308,6 +1851,7 @@ func (p *parser) vocabularyMembers() []ast.Element {
 func (p *parser) vocabularyOperatorDeclaration() ast.Element {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.VocabularyOperatorDeclaration)
 	placement := ast.Infix
 	switch p.pseudo {
 	case tokens.Infix:
@@ -1333,8 +1877,11 @@ func (p *parser) vocabularyOperatorDeclaration() ast.Element {
 	case tokens.Right:
 		associativity = ast.Right
 		p.next()
+	case tokens.None:
+		associativity = ast.NonAssociative
+		p.next()
 	default:
-		return p.expectsPseudo(tokens.Left, tokens.Right)
+		return p.expectsPseudo(tokens.Left, tokens.Right, tokens.None)
 	}
 	return p.builder.VocabularyOperatorDeclaration(names, placement, qualifier, associativity)
 }
@@ -1344,8 +1891,10 @@ func (p *parser) names() []ast.Name {
 	case tokens.Identifier:
 		var name ast.Name
 		if p.pseudo == tokens.Identifiers {
+			mark := p.mark()
 			name = p.builder.Name(infixTypeMember)
 			p.next()
+			p.finish(mark, cst.Name)
 		} else {
 			name = p.expectIdent()
 		}
@@ -1377,6 +1926,7 @@ func (p *parser) names() []ast.Name {
 func (p *parser) vocabularyPrecedenceQualifier() ast.VocabularyOperatorPrecedence {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	mark := p.mark()
 	relation := ast.After
 	switch p.pseudo {
 	case tokens.After:
@@ -1385,6 +1935,10 @@ func (p *parser) vocabularyPrecedenceQualifier() ast.VocabularyOperatorPrecedenc
 	case tokens.Before:
 		relation = ast.Before
 		p.next()
+	case tokens.Same:
+		relation = ast.SameAs
+		p.next()
+		p.expectPseudo(tokens.As)
 	default:
 		return nil
 	}
@@ -1401,12 +1955,14 @@ func (p *parser) vocabularyPrecedenceQualifier() ast.VocabularyOperatorPrecedenc
 		p.next()
 	}
 	name := p.expectIdent()
+	p.finish(mark, cst.VocabularyOperatorPrecedence)
 	return p.builder.VocabularyOperatorPrecedence(name, placement, relation)
 }
 
 func (p *parser) vocabularyEmbedding() ast.VocabularyEmbedding {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.VocabularyEmbedding)
 	p.expectPseudo(tokens.Spread)
 	name := p.vocabularyNameReference()
 	return p.builder.VocabularyEmbedding(name)
@@ -1426,32 +1982,42 @@ func (p *parser) vocabularyNameReference() []ast.Name {
 	return result
 }
 
-func lookupVocabulary(scope vocabularyScope, element ast.Element) (vocabulary, ast.Element) {
+// lookupVocabulary finds the vocabulary element refers to. If it is not found, returns the
+// element that could not be resolved and why.
+func lookupVocabulary(scope VocabularyScope, element ast.Element) (vocabulary, ast.Element, string) {
 	result, elem := lookup(scope, element)
+	if err, ok := result.(error); ok {
+		return nil, elem, err.Error()
+	}
 	if elem != nil {
-		return nil, elem
+		return nil, elem, "Expected a vocabulary reference"
 	}
 	vocab, ok := result.(vocabulary)
 	if !ok {
-		return nil, element
+		return nil, element, "Expected a vocabulary reference"
 	}
-	return vocab, nil
+	return vocab, nil, ""
 }
 
-func lookup(scope vocabularyScope, element ast.Element) (any, ast.Element) {
+// lookup finds the member of scope element refers to. If it is not found, returns the element
+// that could not be resolved and the error reported by the scope, if any.
+func lookup(scope VocabularyScope, element ast.Element) (any, ast.Element) {
 	switch e := element.(type) {
 	case ast.Name:
 		result, ok := scope.Get(e.Text())
 		if !ok {
 			return nil, e
 		}
+		if _, ok := result.(error); ok {
+			return result, e
+		}
 		return result, nil
 	case ast.Selection:
 		sc, elem := lookup(scope, e.Target())
 		if elem != nil {
-			return nil, elem
+			return sc, elem
 		}
-		newScope, ok := sc.(vocabularyScope)
+		newScope, ok := sc.(VocabularyScope)
 		if !ok {
 			return nil, e
 		}
@@ -1466,6 +2032,7 @@ func lookup(scope vocabularyScope, element ast.Element) (any, ast.Element) {
 func (p *parser) loopStatement() ast.Loop {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.Loop)
 	var label ast.Name
 	p.expectPseudo(tokens.Loop)
 	if p.current == tokens.Identifier {
@@ -1480,6 +2047,7 @@ func (p *parser) loopStatement() ast.Loop {
 func (p *parser) whileStatement() ast.Loop {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.While)
 	var label ast.Name
 	p.expectPseudo(tokens.While)
 	if p.current == tokens.Identifier {
@@ -1510,6 +2078,7 @@ func (p *parser) whileStatement() ast.Loop {
 func (p *parser) breakStatement() ast.Break {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.Break)
 	p.expectPseudo(tokens.Break)
 	var label ast.Name
 	if p.current == tokens.Identifier {
@@ -1521,6 +2090,7 @@ func (p *parser) breakStatement() ast.Break {
 func (p *parser) continueStatement() ast.Continue {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.Continue)
 	p.expectPseudo(tokens.Continue)
 	var label ast.Name
 	if p.current == tokens.Identifier {
@@ -1532,11 +2102,12 @@ func (p *parser) continueStatement() ast.Continue {
 func (p *parser) returnStatement() ast.Return {
 	p.builder.PushContext()
 	defer p.builder.PopContext()
+	defer p.finish(p.mark(), cst.Return)
 	p.expect(tokens.Return)
 	var value ast.Element
 	switch p.current {
-	case tokens.Literal, tokens.True, tokens.False, tokens.LBrace, tokens.LParen, tokens.Symbol, tokens.LBrack,
-		tokens.LBrackBang, tokens.LBraceBang, tokens.Identifier:
+	case tokens.Literal, tokens.True, tokens.False, tokens.Null, tokens.LBrace, tokens.LParen, tokens.Symbol,
+		tokens.LBrack, tokens.LBrackBang, tokens.LBraceBang, tokens.Identifier:
 		value = p.expression()
 	}
 	return p.builder.Return(value)
@@ -90,6 +90,11 @@ var _ = Describe("parser", func() {
 			Expect(ok).To(Equal(true))
 			Expect(l.Value()).To(Equal(false))
 		})
+		It("can parse null", func() {
+			l, ok := parse("null").(ast.Literal)
+			Expect(ok).To(Equal(true))
+			Expect(l.Value()).To(BeNil())
+		})
 		It("can parse a parenthesised expression", func() {
 			l, ok := parse("(10)").(ast.Literal)
 			Expect(ok).To(Equal(true))
@@ -243,6 +248,21 @@ var _ = Describe("parser", func() {
 			Expect(l.Start()).To(Equal(location.Pos(0)))
 			Expect(l.End()).To(Equal(location.Pos(3)))
 		})
+		It("can parse a safe selection", func() {
+			l, ok := parse("a?.b").(ast.SafeSelection)
+			Expect(ok).To(Equal(true))
+			n(l.Target(), "a")
+			Expect(l.Member().Text()).To(Equal("b"))
+			Expect(l.Start()).To(Equal(location.Pos(0)))
+			Expect(l.End()).To(Equal(location.Pos(4)))
+			c, ok := parse("a?.b?.c(1)").(ast.Call)
+			Expect(ok).To(Equal(true))
+			l, ok = c.Target().(ast.SafeSelection)
+			Expect(ok).To(Equal(true))
+			n(l.Member(), "c")
+			_, ok = l.Target().(ast.SafeSelection)
+			Expect(ok).To(Equal(true))
+		})
 		It("can parse a call", func() {
 			na := func(e ast.Element) ast.NamedArgument {
 				r, ok := e.(ast.NamedArgument)
@@ -295,10 +315,10 @@ var _ = Describe("parser", func() {
 			return b().Parameter(b().Name(name), nil, nil)
 		}
 		pd := func(name string) ast.Parameter {
-			return b().Parameter(b().Name(name), nil, b().Literal(42))
+			return b().Parameter(b().Name(name), nil, b().Literal(42, "42"))
 		}
 		ptd := func(name string, typ string) ast.Parameter {
-			return b().Parameter(b().Name(name), b().Name(typ), b().Literal(42))
+			return b().Parameter(b().Name(name), b().Name(typ), b().Literal(42, "42"))
 		}
 		expectParameter := func(parameter, expected ast.Parameter) {
 			Expect(parameter.Name().Text()).To(Equal(expected.Name().Text()))
@@ -321,6 +341,10 @@ var _ = Describe("parser", func() {
 			expectNil(l.Parameters())
 			expectNil(l.Body())
 		})
+		It("can parse a lambda with a result type", func() {
+			l := lambda("{ 42 }: Int")
+			expectName(l.Result(), "Int")
+		})
 		It("can parse a simple lambda expression", func() {
 			l := lambda("{ 42 }")
 			expectNumber(l.Body(), 42)
@@ -345,6 +369,31 @@ var _ = Describe("parser", func() {
 			expectName(l.Body(), "a")
 			expectParameters(l.Parameters(), ptd("a", "Int"))
 		})
+		It("can parse a generic lambda", func() {
+			l := lambda("{ X, Y: Int | x: X -> x }")
+			Expect(l.TypeParameters()).To(HaveLen(2))
+			expectName(l.TypeParameters()[0].Name(), "X")
+			expectNil(l.TypeParameters()[0].Constraint())
+			expectName(l.TypeParameters()[1].Constraint(), "Int")
+			expectParameters(l.Parameters(), p("x"))
+			expectName(l.Body(), "x")
+		})
+		It("can parse a generic lambda without parameters", func() {
+			l := lambda("{ X | 42 }")
+			Expect(l.TypeParameters()).To(HaveLen(1))
+			expectNil(l.Parameters())
+			expectNumber(l.Body(), 42)
+		})
+		It("can parse an explicit instantiation in a call", func() {
+			c, ok := parse("f(<Int, Box<Int> > 1)").(ast.Call)
+			Expect(ok).To(BeTrue())
+			i, ok := c.Target().(ast.Instantiation)
+			Expect(ok).To(BeTrue())
+			expectName(i.Target(), "f")
+			Expect(i.TypeArguments()).To(HaveLen(2))
+			expectName(i.TypeArguments()[0], "Int")
+			Expect(c.Arguments()).To(HaveLen(1))
+		})
 	})
 	Describe("statements", func() {
 		It("can parser a loop", func() {
@@ -464,6 +513,33 @@ var _ = Describe("parser", func() {
 				Expect(ok).To(BeTrue())
 				n(ot.Target(), "A")
 			})
+			It("can parse an instantiation", func() {
+				ty := t("a.Map<K, V>[]")
+				st, ok := ty.(ast.SequenceType)
+				Expect(ok).To(BeTrue())
+				i, ok := st.Elements().(ast.Instantiation)
+				Expect(ok).To(BeTrue())
+				_, ok = i.Target().(ast.Selection)
+				Expect(ok).To(BeTrue())
+				Expect(i.TypeArguments()).To(HaveLen(2))
+				n(i.TypeArguments()[1], "V")
+			})
+			It("can parse an optional instantiation", func() {
+				ty := t("Box<Int>?")
+				ot, ok := ty.(ast.OptionalType)
+				Expect(ok).To(BeTrue())
+				i, ok := ot.Target().(ast.Instantiation)
+				Expect(ok).To(BeTrue())
+				n(i.Target(), "Box")
+				n(i.TypeArguments()[0], "Int")
+				ty = t("Map<K, List<V>?>?")
+				ot, ok = ty.(ast.OptionalType)
+				Expect(ok).To(BeTrue())
+				i, ok = ot.Target().(ast.Instantiation)
+				Expect(ok).To(BeTrue())
+				_, ok = i.TypeArguments()[1].(ast.OptionalType)
+				Expect(ok).To(BeTrue())
+			})
 		})
 		Describe("type literal", func() {
 			tl := func(text string) ast.TypeLiteral {
@@ -500,6 +576,14 @@ var _ = Describe("parser", func() {
 				n(m.Name(), "b")
 				n(m.Type(), "Int")
 			})
+			It("can parse a mutable member", func() {
+				ty := tl("< var a: Int, b: Int >")
+				m := tm(ty.Members()[0])
+				n(m.Name(), "a")
+				Expect(m.Mutable()).To(BeTrue())
+				m = tm(ty.Members()[1])
+				Expect(m.Mutable()).To(BeFalse())
+			})
 			It("can parse a nested type literal", func() {
 				ty := tl("<a: <a: Int>>")
 				m := tm(ty.Members()[0])
@@ -515,6 +599,18 @@ var _ = Describe("parser", func() {
 				Expect(len(c.Parameters())).To(Equal(2))
 				n(c.Result(), "Int")
 			})
+			It("can parse type parameters", func() {
+				ty := tl("< X, Y: Int -> a: X, b: Y >")
+				Expect(ty.TypeParameters()).To(HaveLen(2))
+				n(ty.TypeParameters()[0].Name(), "X")
+				n(ty.TypeParameters()[1].Constraint(), "Int")
+				Expect(ty.Members()).To(HaveLen(2))
+			})
+			It("can parse members that are not type parameters", func() {
+				ty := tl("< x: Int, y: Int >")
+				Expect(ty.TypeParameters()).To(BeNil())
+				Expect(ty.Members()).To(HaveLen(2))
+			})
 		})
 	})
 	Describe("vocabulary", func() {
@@ -524,6 +620,12 @@ var _ = Describe("parser", func() {
 			v, ok := l.Value().(ast.VocabularyLiteral)
 			return v
 		}
+		expectNames := func(name []ast.Name, expected ...string) {
+			Expect(len(name)).To(Equal(len(expected)))
+			for i := range name {
+				Expect(name[i].Text()).To(Equal(expected[i]))
+			}
+		}
 		It("can parse an empty vocabulary", func() {
 			v := vocab("<| |>")
 			Expect(v.Members()).To(BeNil())
@@ -535,12 +637,6 @@ var _ = Describe("parser", func() {
 				Expect(len(m)).To(Equal(1))
 				return m[0].(ast.VocabularyEmbedding)
 			}
-			expectNames := func(name []ast.Name, expected ...string) {
-				Expect(len(name)).To(Equal(len(expected)))
-				for i := range name {
-					Expect(name[i].Text()).To(Equal(expected[i]))
-				}
-			}
 			It("can parse a vocabulary embedding", func() {
 				e := ve("<| ...Other |>")
 				expectNames(e.Name(), "Other")
@@ -549,6 +645,22 @@ var _ = Describe("parser", func() {
 				e := ve("<| ...a::b |>")
 				expectNames(e.Name(), "a", "b")
 			})
+			It("can parse members after an embedding", func() {
+				v := vocab("<|\n  ...Other\n  infix operator `+` left\n|>")
+				Expect(v.Members()).To(HaveLen(2))
+				expectNames(v.Members()[0].(ast.VocabularyEmbedding).Name(), "Other")
+				o, ok := v.Members()[1].(ast.VocabularyOperatorDeclaration)
+				Expect(ok).To(BeTrue())
+				expectNames(o.Names(), "+")
+				Expect(o.Placement()).To(Equal(ast.Infix))
+				Expect(o.Associativity()).To(Equal(ast.Left))
+			})
+			It("can parse embeddings after an embedding", func() {
+				v := vocab("<|\n  ...A\n  ...B\n|>")
+				Expect(v.Members()).To(HaveLen(2))
+				expectNames(v.Members()[0].(ast.VocabularyEmbedding).Name(), "A")
+				expectNames(v.Members()[1].(ast.VocabularyEmbedding).Name(), "B")
+			})
 		})
 		Describe("operator", func() {
 			op := func(source string) ast.VocabularyOperatorDeclaration {
@@ -607,6 +719,22 @@ var _ = Describe("parser", func() {
 				Expect(p.Relation()).To(Equal(ast.After))
 				Expect(p.Placement()).To(Equal(ast.Postfix))
 			})
+			It("can parse a same as relation", func() {
+				o := op("postfix operator `->` same as postfix `.` left")
+				p := o.Precedence()
+				Expect(p.Relation()).To(Equal(ast.SameAs))
+				Expect(p.Placement()).To(Equal(ast.Postfix))
+				Expect(p.Name().Text()).To(Equal("."))
+			})
+			It("can parse a non-associative operator", func() {
+				o := op("infix operator (`<`, `>`) none")
+				Expect(o.Associativity()).To(Equal(ast.NonAssociative))
+			})
+			It("can declare operators named as, none and same", func() {
+				o := op("infix operator (as, none, same) none")
+				expectNames(o.Names(), "as", "none", "same")
+				Expect(o.Associativity()).To(Equal(ast.NonAssociative))
+			})
 		})
 	})
 	e := func(source string) ast.Element {
@@ -639,6 +767,18 @@ var _ = Describe("parser", func() {
 		Expect(len(arguments)).To(Equal(1))
 		return target, arguments[0]
 	}
+	Describe("vocabulary words", func() {
+		It("can use as, none and same as names", func() {
+			l, r := expectBinaryOp(e("same + none"), "+")
+			expectName(l, "same")
+			expectName(r, "none")
+		})
+		It("can use as as an infix operator", func() {
+			l, r := expectBinaryOp(e("a as b"), "as")
+			expectName(l, "a")
+			expectName(r, "b")
+		})
+	})
 	Describe("prefix expressions", func() {
 		It("can parse a prefix expression", func() {
 			v := e("+1")
@@ -689,6 +829,63 @@ var _ = Describe("parser", func() {
             `)
 		})
 	})
+	Describe("mixfix expressions", func() {
+		vocabulary := "...<|\n" +
+			"  infix operator `? :` right\n" +
+			"  prefix operator `if then else` right\n" +
+			"  infix operator `+` before infix `? :` left\n" +
+			"  postfix operator `[ ]` before infix `+` left\n" +
+			"|>\n"
+		mixfix := func(source string) ast.Element {
+			return parse(vocabulary + source).(ast.Sequence).Right()
+		}
+		expectMixfixOp := func(e ast.Element, name string, count int) (ast.Element, []ast.Element) {
+			member, target, arguments := expectOp(e)
+			expectName(member, name)
+			Expect(len(arguments)).To(Equal(count))
+			return target, arguments
+		}
+		It("can parse an infix mixfix expression", func() {
+			t, a := expectMixfixOp(mixfix("a ? b + 1 : c"), "? :", 2)
+			n(t, "a")
+			expectBinaryOp(a[0], "+")
+			n(a[1], "c")
+		})
+		It("parses the last operand at the level of the operator", func() {
+			t, a := expectMixfixOp(mixfix("a ? b : c ? d : e"), "? :", 2)
+			n(t, "a")
+			n(a[0], "b")
+			expectMixfixOp(a[1], "? :", 2)
+			_, a = expectMixfixOp(mixfix("a ? b : c + d"), "? :", 2)
+			expectBinaryOp(a[1], "+")
+			l, _ := expectBinaryOp(mixfix("(a ? b : c) + d"), "+")
+			expectMixfixOp(l, "? :", 2)
+		})
+		It("can nest mixfix expressions between the parts", func() {
+			_, a := expectMixfixOp(mixfix("a ? b ? c : d : e"), "? :", 2)
+			expectMixfixOp(a[0], "? :", 2)
+			n(a[1], "e")
+		})
+		It("can parse a prefix mixfix expression", func() {
+			t, a := expectMixfixOp(mixfix("if a then b else c + d"), "if then else", 2)
+			n(t, "a")
+			n(a[0], "b")
+			expectBinaryOp(a[1], "+")
+		})
+		It("can parse a bracket pair as a postfix mixfix expression", func() {
+			l, r := expectBinaryOp(mixfix("x[i] + y [j + 1]"), "+")
+			t, a := expectMixfixOp(l, "postfix [ ]", 1)
+			n(t, "x")
+			n(a[0], "i")
+			t, a = expectMixfixOp(r, "postfix [ ]", 1)
+			n(t, "y")
+			expectBinaryOp(a[0], "+")
+		})
+		It("reports a missing part", func() {
+			expectErrors(vocabulary+"a ? b", "Expected :, received <eof>")
+			expectErrors(vocabulary+"if a else b", "Expected then, received <identifier>")
+		})
+	})
 	Describe("separators", func() {
 		sequence := func(e ast.Element) []ast.Element {
 			var result []ast.Element
@@ -723,6 +920,85 @@ var _ = Describe("parser", func() {
 			seq := s("...dyego, a \n + b")
 			Expect(len(seq)).To(Equal(2))
 		})
+		It("records the target of a spread", func() {
+			seq := s("...dyego \n a")
+			spread, ok := seq[0].(ast.Spread)
+			Expect(ok).To(BeTrue())
+			n(spread.Target(), "dyego")
+		})
+	})
+	Describe("locations", func() {
+		loc := func(e ast.Element) []location.Pos {
+			return []location.Pos{e.Start(), e.End()}
+		}
+		It("does not include the following token", func() {
+			seq := parse("val x = foo(a)\nval y = 2").(ast.Sequence)
+			Expect(loc(seq.Left())).To(Equal([]location.Pos{0, 14}))
+			Expect(loc(seq.Left().(ast.Storage).Value())).To(Equal([]location.Pos{8, 14}))
+			Expect(loc(seq.Right())).To(Equal([]location.Pos{15, 24}))
+		})
+		It("locates a when expression", func() {
+			w := parse("when (a) { b -> { c } }").(ast.When)
+			Expect(loc(w)).To(Equal([]location.Pos{0, 23}))
+		})
+		It("locates an if expression", func() {
+			w := parse("if (a) { b } else { c }").(ast.When)
+			Expect(loc(w)).To(Equal([]location.Pos{0, 23}))
+			Expect(loc(w.Clauses()[0])).To(Equal([]location.Pos{7, 12}))
+			Expect(loc(w.Clauses()[1])).To(Equal([]location.Pos{13, 23}))
+		})
+		It("locates the name of a shorthand named argument", func() {
+			c := parse("f(:x)").(ast.Call)
+			a := c.Arguments()[0].(ast.NamedArgument)
+			Expect(loc(a.Name())).To(Equal([]location.Pos{3, 4}))
+			Expect(loc(a.Value())).To(Equal([]location.Pos{3, 4}))
+		})
+	})
+	Describe("comments", func() {
+		parseComments := func(text string) (ast.Element, []ast.Comment) {
+			p := NewParser(scanner.NewScanner(append([]byte(text), 0), scanner.TriviaScan, nil), defaultScope)
+			element := p.Parse()
+			Expect(p.Errors()).To(BeEmpty())
+			return element, p.Comments()
+		}
+		texts := func(comments []ast.Comment) []string {
+			var result []string
+			for _, comment := range comments {
+				result = append(result, comment.Text)
+			}
+			return result
+		}
+		It("does not record comments by default", func() {
+			p := NewParser(scan("// a\nb", nil), defaultScope)
+			p.Parse()
+			Expect(p.Comments()).To(BeEmpty())
+		})
+		It("records the comments once when backtracking", func() {
+			_, comments := parseComments("// a\nval x = f(/* b */ a) // c\n/* d */")
+			Expect(texts(comments)).To(Equal([]string{"// a", "/* b */", "// c", "/* d */"}))
+			Expect(comments[1].Start()).To(Equal(location.Pos(15)))
+			Expect(comments[1].OwnLine).To(BeFalse())
+		})
+		It("attaches leading and trailing comments", func() {
+			element, comments := parseComments("// a\nval x = 1 // b\nval y = 2")
+			m := ast.AttachComments(element, comments)
+			seq := element.(ast.Sequence)
+			Expect(texts(m[seq.Left()].Leading)).To(Equal([]string{"// a"}))
+			Expect(texts(m[seq.Left()].Trailing)).To(Equal([]string{"// b"}))
+			Expect(m[seq.Right()]).To(BeNil())
+		})
+		It("attaches comments to the nearest element in a block", func() {
+			element, comments := parseComments("val f = { a ->\n  // b\n  a\n  // c\n}\nval g = 1")
+			m := ast.AttachComments(element, comments)
+			lambda := element.(ast.Sequence).Left().(ast.Storage).Value().(ast.Lambda)
+			Expect(texts(m[lambda.Body()].Leading)).To(Equal([]string{"// b"}))
+			Expect(texts(m[lambda].Trailing)).To(Equal([]string{"// c"}))
+		})
+		It("attaches the comments of the end of a module", func() {
+			element, comments := parseComments("val a = 1\n// b")
+			m := ast.AttachComments(element, comments)
+			Expect(texts(m[element].Trailing)).To(Equal([]string{"// b"}))
+		})
 	})
 	Describe("locals", func() {
 		dec := func(text string) ast.Storage {
@@ -770,6 +1046,20 @@ var _ = Describe("parser", func() {
 		It("reports invalid vocabulary references", func() {
 			expectErrors("...missing", "Expected a vocabulary reference")
 		})
+		It("reports a same relation without as", func() {
+			expectErrors("...<| infix operator `==` same infix `<` left |>", "Expected as, received infix")
+		})
+		It("reports chained non-associative operators", func() {
+			vocabulary := "...<| infix operator (`<`, `>`) none, infix operator `==` same as infix `<` left |>\n"
+			expectErrors(vocabulary+"a < b < c", "Operator '<' is non-associative and cannot be chained with '<'")
+			expectErrors(vocabulary+"a == b < c", "Operator '<' is non-associative and cannot be chained with '=='")
+			expectErrors(vocabulary+"a < b == c", "Operator '<' is non-associative and cannot be chained with '=='")
+		})
+		It("does not report a non-associative operator with operands at other levels", func() {
+			p := NewParser(scan("...<| infix operator `*` left, infix operator `<` none |>\na * b < c * d", nil), defaultScope)
+			p.Parse()
+			Expect(p.Errors()).To(BeEmpty())
+		})
 		It("reports an invalid expression", func() {
 			expectErrors("(val a)", "Expected one of")
 		})
@@ -793,6 +1083,87 @@ var _ = Describe("parser", func() {
 			expectErrors("a(:1)", "Expected <identifier>")
 		})
 	})
+	Describe("recovery", func() {
+		recover := func(text string) (ast.Element, []errors.Error) {
+			p := NewParser(scan(text, nil), defaultScope)
+			return p.Parse(), p.Errors()
+		}
+		errorElements := func(element ast.Element) []ast.Error {
+			var result []ast.Error
+			ast.Walk(element, visitorFunc(func(element ast.Element) bool {
+				if err, ok := element.(ast.Error); ok {
+					result = append(result, err)
+				}
+				return true
+			}))
+			return result
+		}
+		It("reports a missing closing brace once", func() {
+			_, errs := recover("...dyego\nval f = { a\nval b = 1\nval c = [a: 1, b: 2]")
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Error()).To(Equal("Expected }, received <eof>"))
+		})
+		It("skips to the closing parenthesis", func() {
+			element, errs := recover("...dyego\nval a = f(1 2 (3 4), 5)\nval b = 2")
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Error()).To(Equal("Expected ), received <literal>"))
+			Expect(element.(ast.Sequence).Right().(ast.Sequence).Right().(ast.Storage).Name().Text()).To(Equal("b"))
+		})
+		It("stops skipping at the next declaration", func() {
+			element, errs := recover("...dyego\nval a = f(1\nval b = 2")
+			Expect(errs).To(HaveLen(1))
+			Expect(element.(ast.Sequence).Right().(ast.Sequence).Right().(ast.Storage).Name().Text()).To(Equal("b"))
+		})
+		It("resynchronizes at the next statement", func() {
+			element, errs := recover("...dyego\nval a = 1 2 3\nval b = ) 4\nval c = 3")
+			Expect(errs).To(HaveLen(2))
+			Expect(errs[0].Error()).To(Equal("Expected a separator, received <literal>"))
+			Expect(errs[0].Start()).To(Equal(location.Pos(19)))
+			Expect(errs[0].End()).To(Equal(location.Pos(22)))
+			Expect(errorElements(element)).To(HaveLen(3))
+		})
+		It("recovers from an unmatched closing bracket", func() {
+			element, errs := recover("a)\nb")
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Error()).To(Equal("Expected <eof>, received )"))
+			Expect(errorElements(element)).To(HaveLen(1))
+		})
+		It("places error elements in the tree", func() {
+			element, errs := recover("(val a)")
+			Expect(errs).To(HaveLen(1))
+			Expect(errorElements(element)).To(Equal([]ast.Error{errs[0].(ast.Error)}))
+		})
+		It("produces a complete tree for truncated examples", func() {
+			for _, name := range []string{
+				"../examples/Example.dg", "../examples/Simple.dg", "../examples/Simple0.dg", "../builtins/Dyego0_wasm.dg",
+			} {
+				src := readFile(name)
+				text := string(src[:len(src)-1])
+				for end := 0; end <= len(text); end++ {
+					// Truncate at the end of each line and at points within the lines
+					if end%13 != 0 && end < len(text) && text[end] != '\n' {
+						continue
+					}
+					truncated := text[:end]
+					element, errs := recover(truncated)
+					Expect(element).ToNot(BeNil(), truncated)
+					var outside []location.Locatable
+					ast.Walk(element, visitorFunc(func(element ast.Element) bool {
+						if element.Start() < 0 || element.End() > location.Pos(end) {
+							outside = append(outside, element)
+						}
+						return true
+					}))
+					for _, err := range errs {
+						if err.End() > location.Pos(end) {
+							outside = append(outside, err)
+						}
+					}
+					Expect(outside).To(BeEmpty(), truncated)
+				}
+			}
+		})
+	})
 	Describe("examples", func() {
 		It("can parse the simple example", func() {
 			parseFile("../examples/Simple0.dg")
@@ -821,7 +1192,7 @@ func scan(text string, fb tokens.FileBuilder) *scanner.Scanner {
 
 var dyego0VocabularySource = strings.ReplaceAll(`
 let dyego = <| 
-  postfix operator (@++@, @--@, @?.@, @?@) right,
+  postfix operator (@++@, @--@, @?@) right,
   prefix operator (@+@, @-@, @--@, @++@) right,
   infix operator (@as@, @as?@) left,
   infix operator (@*@, @/@, @%@) left,
@@ -853,7 +1224,7 @@ func parseVocabulary(source, filename string) vocabulary {
 	return v
 }
 
-var defaultScope vocabularyScope
+var defaultScope VocabularyScope
 
 func parse(text string) ast.Element {
 	return parseNamed(text, "text", defaultScope)
@@ -867,7 +1238,7 @@ func recordLines(fb tokens.FileBuilder, text string) {
 	}
 }
 
-func parseNamed(text, filename string, scope vocabularyScope) ast.Element {
+func parseNamed(text, filename string, scope VocabularyScope) ast.Element {
 	fs := tokens.NewFileSet()
 	fb := fs.BuildFile(filename, len(text))
 	p := NewParser(scan(text, fb), scope)
@@ -898,6 +1269,12 @@ loop:
 	}
 }
 
+type visitorFunc func(element ast.Element) bool
+
+func (v visitorFunc) Visit(element ast.Element) bool {
+	return v(element)
+}
+
 func expectNumber(element ast.Element, value int) {
 	n, ok := element.(ast.Literal)
 	Expect(ok).To(Equal(true))
@@ -2,14 +2,16 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 
 	"dyego0/assert"
 	"dyego0/ast"
+	"dyego0/errors"
 )
 
 const infixTypeMember = "infix type member"
 
-type any interface{}
+type any = interface{}
 
 // precedenceLevel
 
@@ -105,12 +107,16 @@ type operator interface {
 	Name() string
 	Levels() []precedenceLevel
 	Associativities() []ast.OperatorAssociativity
+	Embeddings() []string
+	Parts() [][]string
 }
 
 type operatorImpl struct {
 	name            string
 	levels          []precedenceLevel
 	associativities []ast.OperatorAssociativity
+	embeddings      []string
+	parts           [][]string
 }
 
 func (o *operatorImpl) Name() string {
@@ -125,6 +131,18 @@ func (o *operatorImpl) Associativities() []ast.OperatorAssociativity {
 	return o.associativities
 }
 
+// Embeddings are the names of the embedded vocabularies that contributed each placement of the
+// operator, or "" for placements declared by the vocabulary itself
+func (o *operatorImpl) Embeddings() []string {
+	return o.embeddings
+}
+
+// Parts are the parts of each placement of a mixfix operator, such as ? and : of a ? b : c, or nil
+// for placements that are not mixfix. The first part is the name of the operator.
+func (o *operatorImpl) Parts() [][]string {
+	return o.parts
+}
+
 func (o *operatorImpl) String() string {
 	result := "operator " + o.name
 	addPlace := func(placement ast.OperatorPlacement) {
@@ -143,8 +161,16 @@ func newOperator(
 	name string,
 	levels []precedenceLevel,
 	associativities []ast.OperatorAssociativity,
+	embeddings []string,
+	parts [][]string,
 ) operator {
-	return &operatorImpl{name: name, levels: levels, associativities: associativities}
+	return &operatorImpl{
+		name:            name,
+		levels:          levels,
+		associativities: associativities,
+		embeddings:      embeddings,
+		parts:           parts,
+	}
 }
 
 // vocabulary
@@ -153,12 +179,18 @@ type vocabularyMap map[string]any
 
 type vocabularyImpl struct {
 	members vocabularyMap
-	scope   vocabularyScope
+	scope   VocabularyScope
 }
 
 type vocabulary interface {
 	Get(name string) (any, bool)
-	Scope() vocabularyScope
+	Scope() VocabularyScope
+}
+
+// Vocabulary is the operators declared by a vocabulary literal. A vocabulary is a member of a
+// VocabularyScope that can be embedded by a spread such as ...a::b::Vocab.
+type Vocabulary interface {
+	vocabulary
 }
 
 func newVocabulary() *vocabularyImpl {
@@ -170,7 +202,7 @@ func (v *vocabularyImpl) Get(name string) (any, bool) {
 	return result, ok
 }
 
-func (v *vocabularyImpl) Scope() vocabularyScope {
+func (v *vocabularyImpl) Scope() VocabularyScope {
 	return v.scope
 }
 
@@ -182,13 +214,16 @@ func (v *vocabularyImpl) String() string {
 	return result
 }
 
-// vocabularyScope
+// VocabularyScope
 
 type vocabularyScopeImpl struct {
 	members map[string]any
 }
 
-type vocabularyScope interface {
+// VocabularyScope is a scope used to resolve vocabulary references such as ...a::b::Vocab. The
+// members of a scope are vocabularies and nested scopes; a scope can report why a name cannot
+// be resolved by returning an error as its member.
+type VocabularyScope interface {
 	Get(name string) (any, bool)
 }
 
@@ -265,9 +300,24 @@ func (c *vocabularyEmbeddingContext) embedVocabulary(embeddedVocabulary *vocabul
 	for _, member := range members {
 		switch m := member.(type) {
 		case operator:
-			c.recordOperator(embedding, m.Name(), c.mappedPrecedences(m.Levels()), m.Associativities())
+			embeddings := []string{"", "", ""}
+			for placement, level := range m.Levels() {
+				if level != nil {
+					embeddings[placement] = embeddingName(embedding)
+				}
+			}
+			c.recordOperator(
+				embedding,
+				m.Name(),
+				c.mappedPrecedences(m.Levels()),
+				m.Associativities(),
+				embeddings,
+				m.Parts(),
+			)
 		}
 	}
+	// Expressions are parsed at a level below the level of every operator so that left
+	// associative operators at the lowest level continue them
 	last := c.rootLevel
 	for {
 		next := last.Lower()
@@ -276,6 +326,9 @@ func (c *vocabularyEmbeddingContext) embedVocabulary(embeddedVocabulary *vocabul
 		}
 		last = next
 	}
+	if last == c.rootLevel || last != c.lowestLevel {
+		last = last.MakeLower()
+	}
 	c.lowestLevel = last
 }
 
@@ -284,6 +337,8 @@ func (c *vocabularyEmbeddingContext) recordOperator(
 	name string,
 	levels []precedenceLevel,
 	associativities []ast.OperatorAssociativity,
+	embeddings []string,
+	parts [][]string,
 ) {
 	member, ok := c.result.Get(name)
 	if ok {
@@ -294,6 +349,8 @@ func (c *vocabularyEmbeddingContext) recordOperator(
 					if m.Levels()[placement] == nil {
 						m.Levels()[placement] = levels[placement]
 						m.Associativities()[placement] = associativities[placement]
+						m.Embeddings()[placement] = embeddings[placement]
+						m.Parts()[placement] = parts[placement]
 					} else {
 						c.reportError(
 							element,
@@ -310,14 +367,68 @@ func (c *vocabularyEmbeddingContext) recordOperator(
 	op := newOperator(
 		name,
 		levels,
-		associativities,
+		append([]ast.OperatorAssociativity(nil), associativities...),
+		embeddings,
+		append([][]string(nil), parts...),
 	)
 	c.result.members[name] = op
 }
 
+// operatorParts splits the name of a mixfix operator such as `? :` into its parts, or returns nil
+// if name is not the name of a mixfix operator
+func operatorParts(name string) []string {
+	if name == infixTypeMember {
+		return nil
+	}
+	parts := strings.Fields(name)
+	if len(parts) < 2 {
+		return nil
+	}
+	return parts
+}
+
+// operatorKey is the name an operator is recorded by in a vocabulary, the first part of a mixfix
+// operator
+func operatorKey(name string) string {
+	if parts := operatorParts(name); parts != nil {
+		return parts[0]
+	}
+	return name
+}
+
+// embeddingName is the name of the vocabulary embedded by embedding, such as a::b::Vocab
+func embeddingName(embedding ast.Element) string {
+	switch e := embedding.(type) {
+	case ast.VocabularyEmbedding:
+		var names []string
+		for _, name := range e.Name() {
+			names = append(names, name.Text())
+		}
+		return strings.Join(names, "::")
+	case ast.Name:
+		return e.Text()
+	case ast.Selection:
+		return embeddingName(e.Target()) + "::" + e.Member().Text()
+	case ast.VocabularyLiteral:
+		return "<| |>"
+	}
+	return ""
+}
+
 // buildVocabulary
 
-func buildVocabulary(scope vocabularyScope, vocabularyLiteral ast.VocabularyLiteral) (vocabulary, vocabularyErrors) {
+// BuildVocabulary builds the vocabulary declared by vocabularyLiteral. The vocabularies it embeds
+// are found in scope.
+func BuildVocabulary(scope VocabularyScope, vocabularyLiteral ast.VocabularyLiteral) (Vocabulary, []errors.Error) {
+	result, vocabularyErrors := buildVocabulary(scope, vocabularyLiteral)
+	var errs []errors.Error
+	for _, err := range vocabularyErrors {
+		errs = append(errs, ast.NewError(err.element, "%s", err.message))
+	}
+	return result.(*vocabularyImpl), errs
+}
+
+func buildVocabulary(scope VocabularyScope, vocabularyLiteral ast.VocabularyLiteral) (vocabulary, vocabularyErrors) {
 	c := newVocabularyEmbeddingContext()
 
 	lookupVocabulary := func(nameList []ast.Name) vocabulary {
@@ -338,8 +449,11 @@ func buildVocabulary(scope vocabularyScope, vocabularyLiteral ast.VocabularyLite
 			case vocabulary:
 				embeddedVocabulary = v
 				currentScope = nil
-			case vocabularyScope:
+			case VocabularyScope:
 				currentScope = v
+			case error:
+				c.reportError(name, "%s", v)
+				return nil
 			default:
 				assert.Fail("Unknown scope member %#v", lookup)
 			}
@@ -372,7 +486,7 @@ func buildVocabulary(scope vocabularyScope, vocabularyLiteral ast.VocabularyLite
 				continue
 			}
 			c.embedVocabulary(embeddedVocabulary.(*vocabularyImpl), m)
-		case ast.VocabularyOperatorDeclaration:
+		case ast.VocabularyOperatorDeclaration, ast.Error:
 			continue
 		default:
 			assert.Fail("Unknown vmocabulary element %#v", m)
@@ -407,7 +521,7 @@ func buildVocabulary(scope vocabularyScope, vocabularyLiteral ast.VocabularyLite
 			precedence := lowestPrecedence
 			precedenceDeclaration := m.Precedence()
 			if precedenceDeclaration != nil {
-				lookup, ok := c.result.members[precedenceDeclaration.Name().Text()]
+				lookup, ok := c.result.members[operatorKey(precedenceDeclaration.Name().Text())]
 				if !ok {
 					c.reportError(precedenceDeclaration.Name(), "Undeclared identifier '%s'", precedenceDeclaration.Name().Text())
 					continue
@@ -448,6 +562,8 @@ func buildVocabulary(scope vocabularyScope, vocabularyLiteral ast.VocabularyLite
 					precedence = precedence.MakeHigher()
 				case ast.After:
 					precedence = precedence.MakeLower()
+				case ast.SameAs:
+					// The operator shares the level of the referenced operator
 				default:
 					assert.Fail("Relation not defined: %s", precedenceDeclaration.Relation())
 				}
@@ -465,7 +581,16 @@ func buildVocabulary(scope vocabularyScope, vocabularyLiteral ast.VocabularyLite
 					continue
 				}
 				levels, associativities := levelsAndAssociativities(placement, precedence, associativity)
-				c.recordOperator(name, name.Text(), levels, associativities)
+				parts := [][]string{nil, nil, nil}
+				parts[placement] = operatorParts(name.Text())
+				c.recordOperator(
+					name,
+					operatorKey(name.Text()),
+					levels,
+					associativities,
+					[]string{"", "", ""},
+					parts,
+				)
 			}
 		}
 	}
@@ -71,7 +71,7 @@ var _ = Describe("table", func() {
 	})
 	Describe("operator", func() {
 		It("can create a operator", func() {
-			op := newOperator("+", nil, nil)
+			op := newOperator("+", nil, nil, nil, nil)
 			Expect(op.Name()).To(Equal("+"))
 			Expect(op.Levels()).To(BeNil())
 			Expect(op.Associativities()).To(BeNil())
@@ -85,7 +85,7 @@ var _ = Describe("table", func() {
 		})
 		It("can set and get a value", func() {
 			vocab := newVocabulary()
-			op := newOperator("+", nil, nil)
+			op := newOperator("+", nil, nil, nil, nil)
 			vocab.members["+"] = op
 			value, ok := vocab.Get("+")
 			Expect(ok).To(Equal(true))
@@ -113,7 +113,7 @@ var _ = Describe("table", func() {
 		b := ast.NewBuilder(scan(""))
 		b.PushContext()
 
-		var scope vocabularyScope = newVocabularyScope()
+		var scope VocabularyScope = newVocabularyScope()
 
 		build := func(lit ast.VocabularyLiteral) vocabulary {
 			result, errors := buildVocabulary(scope, lit)
@@ -162,7 +162,7 @@ var _ = Describe("table", func() {
 			),
 		)
 
-		s := func(members ...mbr) vocabularyScope {
+		s := func(members ...mbr) VocabularyScope {
 			result := newVocabularyScope()
 			for _, member := range members {
 				result.members[member.name] = member.value
@@ -247,6 +247,43 @@ var _ = Describe("table", func() {
 			shift := getOp(v, ">>")
 			Expect(mult.Levels()[ast.Infix].IsHigherThan(shift.Levels()[ast.Infix])).To(Equal(true))
 		})
+		It("can embedd a vocabulary and declare an operator at the same level", func() {
+			v := build(
+				vl(
+					embed("c"),
+					op(ast.Infix, ast.NonAssociative, ref("+", ast.Infix, ast.SameAs), "<>"),
+				),
+			)
+			plus := getOp(v, "+")
+			other := getOp(v, "<>")
+			Expect(other.Levels()[ast.Infix]).To(BeIdenticalTo(plus.Levels()[ast.Infix]))
+			Expect(other.Associativities()[ast.Infix]).To(Equal(ast.NonAssociative))
+		})
+		It("can define a mixfix operator", func() {
+			v := build(vl(op(ast.Infix, ast.Right, nil, "? :"), op(ast.Postfix, ast.Left, nil, "[ ]")))
+			o := getOp(v, "?")
+			Expect(o.Name()).To(Equal("?"))
+			Expect(o.Parts()[ast.Infix]).To(Equal([]string{"?", ":"}))
+			Expect(o.Associativities()[ast.Infix]).To(Equal(ast.Right))
+			Expect(getOp(v, "[").Parts()[ast.Postfix]).To(Equal([]string{"[", "]"}))
+		})
+		It("can reference a mixfix operator", func() {
+			v := build(
+				vl(
+					op(ast.Infix, ast.Right, nil, "? :"),
+					op(ast.Infix, ast.Left, ref("? :", ast.Infix, ast.Before), "||"),
+				),
+			)
+			or := getOp(v, "||")
+			conditional := getOp(v, "?")
+			Expect(or.Levels()[ast.Infix].IsHigherThan(conditional.Levels()[ast.Infix])).To(Equal(true))
+		})
+		It("keeps the parts of an embedded mixfix operator", func() {
+			inner := build(vl(op(ast.Prefix, ast.Right, nil, "if then else")))
+			v, errors := buildVocabulary(s(m("i", inner)), vl(embed("i")))
+			Expect(errors).To(BeEmpty())
+			Expect(getOp(v, "if").Parts()[ast.Prefix]).To(Equal([]string{"if", "then", "else"}))
+		})
 		It("undeclared embedding", func() {
 			buildError(vl(embed("cpp")), "Undefined vocabulary 'cpp'")
 		})
@@ -0,0 +1,227 @@
+package parser
+
+import (
+	"dyego0/ast"
+	"dyego0/errors"
+	"dyego0/location"
+	"dyego0/scanner"
+	"dyego0/tokens"
+)
+
+// Tree is the result of parsing the text of a file that can be updated by reparsing only the
+// statements of the file affected by an edit to the text.
+type Tree struct {
+	// Element is the sequence of the top-level statements of the file, or nil if the file is
+	// empty
+	Element ast.Element
+
+	// Errors are the errors found parsing the file
+	Errors []errors.Error
+
+	// Comments are the comments of the file if the file was parsed with scanner.TriviaScan
+	Comments []ast.Comment
+
+	// File is the file declared for the text in the file set
+	File tokens.File
+
+	text       string
+	src        []byte
+	flags      int
+	scope      VocabularyScope
+	fileSet    tokens.FileSet
+	statements []ast.Element
+	starts     []location.Pos
+	embeddings []embedding
+}
+
+// embedding is a vocabulary embedded by a spread of target
+type embedding struct {
+	vocabulary *vocabularyImpl
+	target     ast.Element
+}
+
+// ParseFile parses text as the file filename of fileSet with the scanner flags given
+func ParseFile(fileSet tokens.FileSet, filename, text string, flags int, scope VocabularyScope) *Tree {
+	fb := fileSet.BuildFile(filename, len(text))
+	src := nullTerminated(text)
+	p := NewParser(scanner.NewScanner(src, flags, fb), scope).(*parser)
+	statements, starts := p.statements(location.Pos(-1))
+	return &Tree{
+		Element:    ast.SequenceOf(statements),
+		Errors:     p.errors,
+		Comments:   p.comments,
+		File:       fb.Build(),
+		text:       text,
+		src:        src,
+		flags:      flags,
+		scope:      scope,
+		fileSet:    fileSet,
+		statements: statements,
+		starts:     starts,
+		embeddings: p.embeddings,
+	}
+}
+
+// Text is the text of the file
+func (t *Tree) Text() string {
+	return t.text
+}
+
+// Reparse returns the tree for the text of t after edit is applied to it. Only the statements
+// that include the edit, and the statement before them, are parsed again. The statements before
+// and after them, and their errors and comments, are reused with their locations moved to the
+// new file. A file is parsed completely if the edit would change the vocabulary used to parse
+// the statements that follow the edit, or if an error is reported where the statements parsed
+// again start or end as it cannot be told which statement reported it.
+func (t *Tree) Reparse(edit tokens.Edit) *Tree {
+	text := edit.Apply(t.text)
+	statements, starts := t.statements, t.starts
+	count := len(statements)
+
+	// The statements from first up to last are parsed again. The statement before those that
+	// include the edit is also parsed again if the edit changes the first token that follows it,
+	// as the token determines whether the statement continues on the next line.
+	first := 0
+	for first < count && t.offset(statements[first].End()) < edit.Start {
+		first++
+	}
+	last := first
+	for last < count && t.offset(starts[last]) <= edit.End {
+		last++
+	}
+	if first > 0 && (first == count || edit.Start <= t.tokenEnd(starts[first])) {
+		first--
+	}
+	start := 0
+	if first > 0 {
+		start = t.offset(starts[first])
+	}
+	end := len(t.text)
+	if last < count {
+		end = t.offset(starts[last])
+	}
+
+	parseFile := func() *Tree {
+		return ParseFile(t.fileSet, t.File.FileName(), text, t.flags, t.scope)
+	}
+	for _, err := range t.Errors {
+		if offset := t.offset(err.Start()); first > 0 && offset == start || last < count && offset == end {
+			// The error might have been reported by the statements on either side of it
+			return parseFile()
+		}
+	}
+
+	fb := t.fileSet.EditFile(t.File, edit)
+	src := nullTerminated(text)
+	p := NewParser(scanner.NewScannerAt(src, start, t.flags, fb), t.scope).(*parser)
+	var embeddings []embedding
+	for _, e := range t.embeddings {
+		offset := t.offset(e.target.Start())
+		if offset >= end {
+			break
+		}
+		if offset >= start {
+			// The vocabulary of the statements that follow might change
+			return parseFile()
+		}
+		p.embeddingContext.embedVocabulary(e.vocabulary, e.target)
+		embeddings = append(embeddings, e)
+	}
+	p.embeddingContext.errors = nil
+
+	// The errors before the statements are kept as the parser does not report an error at the
+	// start of the last error reported
+	before := int(fb.Pos(0)) - int(t.File.Pos(0))
+	after := before + edit.Delta()
+	for _, err := range t.Errors {
+		if offset := t.offset(err.Start()); offset < start {
+			p.errors = append(p.errors, shiftError(err, before))
+		}
+	}
+	reused := len(p.errors)
+	limit := location.Pos(-1)
+	if last < count {
+		limit = fb.Pos(end + edit.Delta())
+	}
+	parsed, parsedStarts := p.statements(limit)
+	if len(p.embeddings) > 0 || limit.IsValid() && p.scanner.Start() != limit {
+		// The parsed statements embed a vocabulary or do not end where the statements that
+		// follow them start
+		return parseFile()
+	}
+	for _, err := range p.errors[reused:] {
+		if limit.IsValid() && err.Start() >= limit {
+			// The error might hide an error of the statements that follow
+			return parseFile()
+		}
+	}
+
+	result := &Tree{Errors: p.errors, text: text, src: src, flags: t.flags, scope: t.scope, fileSet: t.fileSet}
+	for index, statement := range statements[:first] {
+		result.statements = append(result.statements, ast.Shift(statement, before))
+		result.starts = append(result.starts, starts[index]+location.Pos(before))
+	}
+	result.statements = append(result.statements, parsed...)
+	result.starts = append(result.starts, parsedStarts...)
+	for index, statement := range statements[last:] {
+		result.statements = append(result.statements, ast.Shift(statement, after))
+		result.starts = append(result.starts, starts[last+index]+location.Pos(after))
+	}
+	for _, e := range embeddings {
+		result.embeddings = append(result.embeddings, embedding{vocabulary: e.vocabulary,
+			target: ast.Shift(e.target, before)})
+	}
+	for _, e := range t.embeddings[len(embeddings):] {
+		result.embeddings = append(result.embeddings, embedding{vocabulary: e.vocabulary,
+			target: ast.Shift(e.target, after)})
+	}
+	for _, err := range t.Errors {
+		if offset := t.offset(err.Start()); last < count && offset >= end {
+			result.Errors = append(result.Errors, shiftError(err, after))
+		}
+	}
+	for _, comment := range t.Comments {
+		if offset := t.offset(comment.Start()); offset < start {
+			result.Comments = append(result.Comments, shiftComment(comment, before))
+		}
+	}
+	result.Comments = append(result.Comments, p.comments...)
+	for _, comment := range t.Comments {
+		if offset := t.offset(comment.Start()); offset >= end {
+			result.Comments = append(result.Comments, shiftComment(comment, after))
+		}
+	}
+	result.Element = ast.SequenceOf(result.statements)
+	result.File = fb.Build()
+	return result
+}
+
+func (t *Tree) offset(pos location.Pos) int {
+	return t.File.Offset(pos)
+}
+
+// tokenEnd is the offset of the end of the token that starts at pos
+func (t *Tree) tokenEnd(pos location.Pos) int {
+	s := scanner.NewScannerAt(t.src, t.offset(pos), t.flags, nil)
+	s.Next()
+	return int(s.End())
+}
+
+// nullTerminated is the null terminated source of text required by the scanner
+func nullTerminated(text string) []byte {
+	src := make([]byte, len(text)+1)
+	copy(src, text)
+	return src
+}
+
+func shiftError(err errors.Error, delta int) errors.Error {
+	if element, ok := err.(ast.Error); ok {
+		return ast.Shift(element, delta).(ast.Error)
+	}
+	return errors.NewAt(err.Start()+location.Pos(delta), err.End()+location.Pos(delta), "%s", err.Error())
+}
+
+func shiftComment(comment ast.Comment, delta int) ast.Comment {
+	return ast.NewComment(comment.Start()+location.Pos(delta), comment.End()+location.Pos(delta), comment.Text,
+		comment.OwnLine)
+}
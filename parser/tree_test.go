@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"dyego0/ast"
+	"dyego0/location"
+	"dyego0/scanner"
+	"dyego0/tokens"
+)
+
+var _ = Describe("tree", func() {
+	parseTree := func(text string) *Tree {
+		return ParseFile(tokens.NewFileSet(), "text", text, scanner.TriviaScan, defaultScope)
+	}
+	It("can parse a file", func() {
+		tree := parseTree("...Dyego0\nval a = 1 // one\nval b = a + 1\n")
+		Expect(ast.Statements(tree.Element)).To(HaveLen(3))
+		Expect(tree.Errors).To(BeEmpty())
+		Expect(tree.Comments).To(HaveLen(1))
+		Expect(tree.File.Size()).To(Equal(len(tree.Text())))
+	})
+	It("can parse an empty file", func() {
+		tree := parseTree("")
+		Expect(tree.Element).To(BeNil())
+		tree = tree.Reparse(tokens.Edit{Text: "...Dyego0\na + b"})
+		Expect(ast.Statements(tree.Element)).To(HaveLen(2))
+		Expect(tree.Errors).To(BeEmpty())
+	})
+	It("reports tokens that cannot start a statement", func() {
+		tree := parseTree("a)\nb")
+		Expect(ast.Statements(tree.Element)).To(HaveLen(3))
+		Expect(tree.Errors).To(HaveLen(1))
+		Expect(tree.Errors[0].Error()).To(Equal("Expected a statement, received )"))
+	})
+	It("reuses the statements that are not edited", func() {
+		text := "...Dyego0\nval a = 1\nval b = 2\nval c = 3\nval d = 4\n"
+		tree := parseTree(text)
+		offset := strings.Index(text, "3")
+		reparsed := tree.Reparse(tokens.Edit{Start: offset, End: offset + 1, Text: "a + b"})
+		Expect(describeTree(reparsed)).To(Equal(describeTree(parseTree(reparsed.Text()))))
+		statements := ast.Statements(reparsed.Element)
+		Expect(statements[4].(ast.Storage).Name().Text()).To(Equal("d"))
+		Expect(reparsed.File.Position(statements[4].Start()).String()).To(Equal("text:5:1"))
+	})
+	It("parses the statement before an edit that continues it", func() {
+		text := "...Dyego0\nval a = 1\nb\n"
+		tree := parseTree(text)
+		offset := strings.Index(text, "b")
+		reparsed := tree.Reparse(tokens.Edit{Start: offset, End: offset, Text: "+ "})
+		Expect(ast.Statements(reparsed.Element)).To(HaveLen(2))
+		Expect(describeTree(reparsed)).To(Equal(describeTree(parseTree(reparsed.Text()))))
+	})
+	It("parses the whole file when an edit changes a vocabulary", func() {
+		tree := parseTree("...Dyego0\na + b\n")
+		reparsed := tree.Reparse(tokens.Edit{Start: 3, End: 9, Text: "nothing"})
+		Expect(reparsed.Errors).ToNot(BeEmpty())
+		Expect(describeTree(reparsed)).To(Equal(describeTree(parseTree(reparsed.Text()))))
+	})
+	It("reparses edits as parsing the edited text would", func() {
+		edits := []func(offset int) tokens.Edit{
+			func(offset int) tokens.Edit { return tokens.Edit{Start: offset, End: offset, Text: "\n"} },
+			func(offset int) tokens.Edit { return tokens.Edit{Start: offset, End: offset, Text: "("} },
+			func(offset int) tokens.Edit { return tokens.Edit{Start: offset, End: offset + 1} },
+			func(offset int) tokens.Edit { return tokens.Edit{Start: offset, End: offset, Text: " + 1"} },
+			func(offset int) tokens.Edit { return tokens.Edit{Start: offset, End: offset + 3, Text: "}\n"} },
+			func(offset int) tokens.Edit { return tokens.Edit{Start: offset, End: offset, Text: "/* c */"} },
+			func(offset int) tokens.Edit { return tokens.Edit{Start: offset, End: offset, Text: "\nval x = 1\n"} },
+		}
+		for _, name := range []string{
+			"../examples/Example.dg", "../examples/Simple.dg", "../examples/Simple0.dg", "../builtins/Dyego0_wasm.dg",
+		} {
+			src := readFile(name)
+			text := string(src[:len(src)-1])
+			tree := parseTree(text)
+			for index, offset := 0, 0; offset < len(tree.Text())-3; index, offset = index+1, offset+97 {
+				text := tree.Text()
+				edit := edits[index%len(edits)](offset)
+				if !utf8.RuneStart(text[edit.Start]) || !utf8.RuneStart(text[edit.End]) {
+					continue
+				}
+				tree = tree.Reparse(edit)
+				Expect(describeTree(tree)).To(Equal(describeTree(parseTree(tree.Text()))), "%s %#v", name, edit)
+			}
+		}
+	})
+})
+
+// describeTree describes the elements, errors and comments of a tree with their offsets
+func describeTree(tree *Tree) string {
+	var result strings.Builder
+	offsets := func(loc location.Locatable) string {
+		return fmt.Sprintf("%d-%d", tree.File.Offset(loc.Start()), tree.File.Offset(loc.End()))
+	}
+	ast.Walk(tree.Element, visitorFunc(func(element ast.Element) bool {
+		fmt.Fprintf(&result, "%T %s", element, offsets(element))
+		if name, ok := element.(ast.Name); ok {
+			fmt.Fprintf(&result, " %s", name.Text())
+		}
+		result.WriteString("\n")
+		return true
+	}))
+	for _, err := range tree.Errors {
+		fmt.Fprintf(&result, "error %s %s\n", offsets(err), err.Error())
+	}
+	for _, comment := range tree.Comments {
+		fmt.Fprintf(&result, "comment %s %s %v\n", offsets(comment), comment.Text, comment.OwnLine)
+	}
+	for line := 1; line <= tree.File.Line(tree.File.Pos(tree.File.Size())); line++ {
+		fmt.Fprintf(&result, "line %d\n", tree.File.LineStart(line))
+	}
+	return result.String()
+}
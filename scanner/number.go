@@ -0,0 +1,192 @@
+package scanner
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"unicode/utf8"
+)
+
+// integerKind is the type of an integer literal selected by its suffix
+type integerKind struct {
+	name   string
+	bits   uint
+	signed bool
+}
+
+var (
+	intKind   = integerKind{name: "Int", bits: 32, signed: true}
+	uintKind  = integerKind{name: "UInt", bits: 32}
+	byteKind  = integerKind{name: "Byte", bits: 8}
+	longKind  = integerKind{name: "Long", bits: 64, signed: true}
+	ulongKind = integerKind{name: "ULong", bits: 64}
+)
+
+// maximum is the largest value of a literal of the kind. Literals with a base prefix, such as
+// 0xFFFFFFFF, give the bit pattern of the value so may use all the bits of a signed kind.
+func (k integerKind) maximum(base int) *big.Int {
+	bits := k.bits
+	if k.signed && base == 10 {
+		bits--
+	}
+	result := big.NewInt(1)
+	result.Lsh(result, bits)
+	return result.Sub(result, big.NewInt(1))
+}
+
+func baseName(base int) string {
+	switch base {
+	case 2:
+		return "binary"
+	case 8:
+		return "octal"
+	case 16:
+		return "hexadecimal"
+	}
+	return "decimal"
+}
+
+// scanNumber scans the number literal that starts at start. Returns the value of the literal, the
+// offset after the literal and a message if the literal is invalid.
+//
+// Integer literals are decimal or, with a 0x, 0b or 0o prefix, hexadecimal, binary or octal and
+// may separate digits with '_'. The suffix of an integer literal selects its type, ub for Byte, u
+// for UInt, ul for ULong, l for Long and i, or no suffix, for Int. A decimal literal with a '.' or
+// with an f or d suffix is a Float or a Double.
+func scanNumber(src []byte, start int) (interface{}, int, string) {
+	offset := start
+	base := 10
+	if src[offset] == '0' {
+		switch src[offset+1] {
+		case 'x':
+			base = 16
+		case 'b':
+			base = 2
+		case 'o':
+			base = 8
+		}
+		if base != 10 {
+			offset += 2
+		}
+	}
+
+	// Collect the digits without separators
+	var digits []byte
+	var msg string
+	isFloat := false
+	separator := false
+digitLoop:
+	for {
+		b := src[offset]
+		switch {
+		case b == '_':
+			if separator && msg == "" {
+				msg = "Invalid digit separator"
+			}
+			separator = true
+			offset++
+			continue
+		case b == '.' && base == 10:
+			if src[offset+1] == '.' {
+				break digitLoop
+			}
+			isFloat = true
+		case b >= '0' && b <= '9', base == 16 && (b >= 'a' && b <= 'f' || b >= 'A' && b <= 'F'):
+			if value, _ := hexValue(b); int(value) >= base && msg == "" {
+				msg = fmt.Sprintf("Invalid digit '%c' in %s literal", rune(b), baseName(base))
+			}
+		default:
+			break digitLoop
+		}
+		digits = append(digits, b)
+		separator = false
+		offset++
+	}
+	if separator && msg == "" {
+		msg = "Invalid digit separator"
+	}
+	if len(digits) == 0 && msg == "" {
+		msg = fmt.Sprintf("Invalid %s literal", baseName(base))
+	}
+
+	var result interface{}
+	var kind *integerKind
+	switch b := src[offset]; {
+	case base == 10 && (isFloat || b == 'f' || b == 'd'):
+		size := 64
+		if b == 'f' {
+			size = 32
+		}
+		if b == 'f' || b == 'd' {
+			offset++
+		}
+		value, err := strconv.ParseFloat(string(digits), size)
+		if err != nil {
+			if msg == "" {
+				msg = err.Error()
+			}
+		} else if size == 32 {
+			result = float32(value)
+		} else {
+			result = value
+		}
+	case b == 'u':
+		offset++
+		switch src[offset] {
+		case 'b':
+			offset++
+			kind = &byteKind
+		case 'l':
+			offset++
+			kind = &ulongKind
+		default:
+			kind = &uintKind
+		}
+	case b == 'l':
+		offset++
+		kind = &longKind
+	case b == 'i':
+		offset++
+		fallthrough
+	default:
+		kind = &intKind
+	}
+
+	if identFollows(src[offset:]) {
+		r, _ := utf8.DecodeRune(src[offset:])
+		if msg == "" {
+			msg = fmt.Sprintf("Extra character '%c' after literal", r)
+		}
+		offset = identEnd(src, offset)
+	}
+	if msg != "" || kind == nil {
+		return result, offset, msg
+	}
+
+	value, _ := new(big.Int).SetString(string(digits), base)
+	if value.Cmp(kind.maximum(base)) > 0 {
+		return nil, offset, fmt.Sprintf("Literal %s overflows %s", src[start:offset], kind.name)
+	}
+	bits := value.Uint64()
+	switch kind {
+	case &byteKind:
+		result = byte(bits)
+	case &ulongKind:
+		result = bits
+	case &longKind:
+		result = int64(bits)
+	case &uintKind:
+		if base == 10 {
+			result = uint(bits)
+		} else {
+			result = uint32(bits)
+		}
+	default:
+		if base == 10 {
+			result = int(int32(bits))
+		} else {
+			result = int32(uint32(bits))
+		}
+	}
+	return result, offset, ""
+}
@@ -2,7 +2,7 @@ package scanner
 
 import (
 	"fmt"
-	"strconv"
+	"unicode/utf8"
 
 	"dyego0/location"
 	"dyego0/tokens"
@@ -11,6 +11,9 @@ import (
 const (
 	// InternalScan enables internal identifiers
 	InternalScan = 1 << iota
+
+	// TriviaScan records the comments and blank lines between tokens
+	TriviaScan
 )
 
 // Scanner is a Dyego scanner
@@ -21,11 +24,13 @@ type Scanner struct {
 	line   int
 	start  int
 	end    int
+	prev   int
 	nlloc  int
 	msg    string
 	flags  int
 	pseudo tokens.PseudoToken
 	value  interface{}
+	trivia []Trivia
 }
 
 // NewScanner creates a scanner
@@ -36,16 +41,51 @@ func NewScanner(src []byte, flags int, fb tokens.FileBuilder) *Scanner {
 	}
 	if fb != nil {
 		fb.AddLine(0)
+		for offset := 0; offset < length-1; {
+			if src[offset] < utf8.RuneSelf {
+				offset++
+				continue
+			}
+			_, size := utf8.DecodeRune(src[offset:])
+			if size > 1 {
+				fb.AddRune(offset, size)
+			}
+			offset += size
+		}
 	}
 	return &Scanner{src: src, fb: fb, line: 1, nlloc: -1, flags: flags}
 }
 
+// NewScannerAt creates a scanner that starts scanning src at offset, which must not be inside a
+// token or a comment. Unlike NewScanner, the runes of src are not declared in fb so fb should
+// already declare them, as does a FileBuilder returned by FileSet.EditFile.
+func NewScannerAt(src []byte, offset int, flags int, fb tokens.FileBuilder) *Scanner {
+	length := len(src)
+	if length == 0 || src[length-1] != 0 {
+		panic("NewScannerAt: src must be null terminated")
+	}
+	line := 1
+	for index := 0; index < offset; index++ {
+		switch src[index] {
+		case '\r':
+			if src[index+1] == '\n' {
+				continue
+			}
+			line++
+		case '\n':
+			line++
+		}
+	}
+	return &Scanner{src: src, fb: fb, offset: offset, line: line, start: offset, end: offset, prev: offset,
+		nlloc: -1, flags: flags}
+}
+
 // Clone preserves a copy of the scanner at the current state which can then be used
 // for backtracking, if necessary by using the returned instance instead of the
 // instance that was moved forward.
 func (s *Scanner) Clone() *Scanner {
-	return &Scanner{src: s.src, fb: s.fb, offset: s.offset, line: s.line, start: s.start, end: s.end, nlloc: s.nlloc,
-		msg: s.msg, flags: s.flags, pseudo: s.pseudo, value: s.value}
+	return &Scanner{src: s.src, fb: s.fb, offset: s.offset, line: s.line, start: s.start, end: s.end, prev: s.prev,
+		nlloc: s.nlloc, msg: s.msg, flags: s.flags, pseudo: s.pseudo, value: s.value, trivia: s.trivia}
 }
 
 // Line is the current line of the scanner
@@ -69,6 +109,14 @@ func (s *Scanner) End() location.Pos {
 	return location.Pos(s.end)
 }
 
+// PreviousEnd is the end of the token before the current token
+func (s *Scanner) PreviousEnd() location.Pos {
+	if s.fb != nil {
+		return s.fb.Pos(s.prev)
+	}
+	return location.Pos(s.prev)
+}
+
 // NewLineLocation is the location of a new line prior to the current token
 func (s *Scanner) NewLineLocation() location.Pos {
 	if s.nlloc >= 0 && s.fb != nil {
@@ -87,6 +135,23 @@ func (s *Scanner) Value() interface{} {
 	return s.value
 }
 
+// Trivia is the comments and blank lines between the previous token and the current token. Trivia
+// is only recorded if the scanner was created with the TriviaScan flag.
+func (s *Scanner) Trivia() []Trivia {
+	return s.trivia
+}
+
+// Text is the source text of the current token, such as the spelling of a literal
+func (s *Scanner) Text() string {
+	return string(s.src[s.start:s.end])
+}
+
+// Leading is the source text between the previous token and the current token, the white space
+// and comments before the current token
+func (s *Scanner) Leading() string {
+	return string(s.src[s.prev:s.start])
+}
+
 // Message is the error message if there is one
 func (s *Scanner) Message() string {
 	return s.msg
@@ -121,6 +186,21 @@ func symbolExtender(b byte) bool {
 	return false
 }
 
+// SplitGreaterThan splits a symbol that starts with > into the pseudo token > and the rest of
+// the symbol, which is scanned as the next token. It returns false if the current token does
+// not start with >. The parser uses it to close type arguments, such as in Box<Int>?, where
+// >? is scanned as a single symbol.
+func (s *Scanner) SplitGreaterThan() bool {
+	if s.end-s.start < 2 || s.src[s.start] != '>' {
+		return false
+	}
+	s.offset = s.start + 1
+	s.end = s.offset
+	s.pseudo = tokens.GreaterThan
+	s.value = ">"
+	return true
+}
+
 // PseudoToken returns the pseudo token for the current identifier
 func (s *Scanner) PseudoToken() tokens.PseudoToken {
 	return s.pseudo
@@ -128,6 +208,7 @@ func (s *Scanner) PseudoToken() tokens.PseudoToken {
 
 // Next moves the scanner to the next token
 func (s *Scanner) Next() tokens.Token {
+	s.prev = s.end
 	s.end = s.offset
 	offset := s.offset
 	start := s.offset
@@ -137,6 +218,15 @@ func (s *Scanner) Next() tokens.Token {
 	s.pseudo = tokens.InvalidPseudoToken
 	s.value = nil
 	s.nlloc = -1
+	s.trivia = nil
+
+	// ownLine is true when no token precedes the current offset on the same line and lineStart is
+	// the start of the current line if only white space has been found on it
+	ownLine := offset == 0
+	lineStart := -1
+	if offset == 0 {
+		lineStart = 0
+	}
 loop:
 	for {
 		b := src[offset]
@@ -163,6 +253,11 @@ loop:
 			if s.fb != nil {
 				s.fb.AddLine(offset)
 			}
+			if lineStart >= 0 {
+				s.record(BlankLine, lineStart, start, true)
+			}
+			ownLine = true
+			lineStart = offset
 			continue
 
 		case '+', '|', '-', '*', '/', '%', '!', '&', '>', '<',
@@ -230,6 +325,42 @@ loop:
 							break commentLoop
 						}
 					}
+					s.record(LineComment, start, offset, ownLine)
+					lineStart = -1
+					continue loop
+				}
+				if src[offset] == '*' {
+					offset++
+				blockCommentLoop:
+					for {
+						b := src[offset]
+						offset++
+						switch b {
+						case 0:
+							offset--
+							result = tokens.Invalid
+							s.msg = "Unterminated comment"
+							break loop
+						case '*':
+							if src[offset] == '/' {
+								offset++
+								break blockCommentLoop
+							}
+						case '\r':
+							if src[offset] == '\n' {
+								offset++
+							}
+							fallthrough
+						case '\n':
+							line++
+							s.nlloc = offset - 1
+							if s.fb != nil {
+								s.fb.AddLine(offset)
+							}
+						}
+					}
+					s.record(BlockComment, start, offset, ownLine)
+					lineStart = -1
 					continue loop
 				}
 				if !symbolExtender(src[offset]) {
@@ -347,6 +478,12 @@ loop:
 					break loop
 				}
 			case '?':
+				if src[offset] == '.' && src[offset+1] != '.' {
+					offset++
+					result = tokens.QuestionDot
+					s.value = "?."
+					break loop
+				}
 				if !symbolExtender(src[offset]) {
 					result = tokens.Symbol
 					s.pseudo = tokens.Question
@@ -428,27 +565,36 @@ loop:
 			}
 
 		// Pseudo reserved words and identifiers
-		case 'a', 'b', 'c', 'e', 'f', 'i', 'l', 'o', 'p', 't', 'r', 'v', 'w':
+		case 'a', 'b', 'c', 'e', 'f', 'i', 'l', 'n', 'o', 'p', 't', 'r', 's', 'v', 'w':
 			switch b {
 			case 'a':
 				switch src[offset] {
 				case 'f':
 					// after
 					if src[offset+1] == 't' && src[offset+2] == 'e' && src[offset+3] == 'r' &&
-						!identExtender(src[offset+4]) {
+						!identFollows(src[offset+4:]) {
 						offset += 4
 						result = tokens.Identifier
 						s.pseudo = tokens.After
 						s.value = "after"
 						break loop
 					}
+				case 's':
+					// as
+					if !identFollows(src[offset+1:]) {
+						offset++
+						result = tokens.Identifier
+						s.pseudo = tokens.As
+						s.value = "as"
+						break loop
+					}
 				}
 			case 'b':
 				switch src[offset] {
 				case 'e':
 					// before
 					if src[offset+1] == 'f' && src[offset+2] == 'o' && src[offset+3] == 'r' && src[offset+4] == 'e' &&
-						!identExtender(src[offset+5]) {
+						!identFollows(src[offset+5:]) {
 						offset += 5
 						result = tokens.Identifier
 						s.pseudo = tokens.Before
@@ -457,7 +603,7 @@ loop:
 					}
 				case 'r':
 					// break
-					if src[offset+1] == 'e' && src[offset+2] == 'a' && src[offset+3] == 'k' && !identExtender(src[offset+4]) {
+					if src[offset+1] == 'e' && src[offset+2] == 'a' && src[offset+3] == 'k' && !identFollows(src[offset+4:]) {
 						offset += 4
 						result = tokens.Identifier
 						s.pseudo = tokens.Break
@@ -468,7 +614,7 @@ loop:
 			case 'c':
 				// continue
 				if src[offset] == 'o' && src[offset+1] == 'n' && src[offset+2] == 't' && src[offset+3] == 'i' &&
-					src[offset+4] == 'n' && src[offset+5] == 'u' && src[offset+6] == 'e' && !identExtender(src[offset+7]) {
+					src[offset+4] == 'n' && src[offset+5] == 'u' && src[offset+6] == 'e' && !identFollows(src[offset+7:]) {
 					offset += 7
 					result = tokens.Identifier
 					s.pseudo = tokens.Continue
@@ -477,7 +623,7 @@ loop:
 				}
 			case 'e':
 				// else
-				if src[offset] == 'l' && src[offset+1] == 's' && src[offset+2] == 'e' && !identExtender(src[offset+3]) {
+				if src[offset] == 'l' && src[offset+1] == 's' && src[offset+2] == 'e' && !identFollows(src[offset+3:]) {
 					offset += 3
 					result = tokens.Identifier
 					s.pseudo = tokens.Else
@@ -487,7 +633,7 @@ loop:
 			case 'f':
 				// false
 				if src[offset] == 'a' && src[offset+1] == 'l' && src[offset+2] == 's' && src[offset+3] == 'e' &&
-					!identExtender(src[offset+4]) {
+					!identFollows(src[offset+4:]) {
 					offset += 4
 					result = tokens.False
 					s.value = "false"
@@ -499,7 +645,7 @@ loop:
 					// identifiers
 					if src[offset+1] == 'e' && src[offset+2] == 'n' && src[offset+3] == 't' && src[offset+4] == 'i' &&
 						src[offset+5] == 'f' && src[offset+6] == 'i' && src[offset+7] == 'e' && src[offset+8] == 'r' &&
-						src[offset+9] == 's' && !identExtender(src[offset+10]) {
+						src[offset+9] == 's' && !identFollows(src[offset+10:]) {
 						offset += 10
 						result = tokens.Identifier
 						s.pseudo = tokens.Identifiers
@@ -507,7 +653,7 @@ loop:
 						break loop
 					}
 				case 'f':
-					if !identExtender(src[offset+1]) {
+					if !identFollows(src[offset+1:]) {
 						offset++
 						result = tokens.Identifier
 						s.pseudo = tokens.If
@@ -518,7 +664,7 @@ loop:
 					switch src[offset+1] {
 					case 'f':
 						// infix
-						if src[offset+2] == 'i' && src[offset+3] == 'x' && !identExtender(src[offset+4]) {
+						if src[offset+2] == 'i' && src[offset+3] == 'x' && !identFollows(src[offset+4:]) {
 							offset += 4
 							result = tokens.Identifier
 							s.pseudo = tokens.Infix
@@ -533,7 +679,7 @@ loop:
 					switch src[offset+1] {
 					case 'f':
 						// left
-						if src[offset+2] == 't' && !identExtender(src[offset+3]) {
+						if src[offset+2] == 't' && !identFollows(src[offset+3:]) {
 							offset += 3
 							result = tokens.Identifier
 							s.pseudo = tokens.Left
@@ -542,7 +688,7 @@ loop:
 						}
 					case 't':
 						// let
-						if !identExtender(src[offset+2]) {
+						if !identFollows(src[offset+2:]) {
 							offset += 2
 							result = tokens.Let
 							break loop
@@ -550,7 +696,7 @@ loop:
 					}
 				case 'o':
 					// loop
-					if src[offset+1] == 'o' && src[offset+2] == 'p' && !identExtender(src[offset+3]) {
+					if src[offset+1] == 'o' && src[offset+2] == 'p' && !identFollows(src[offset+3:]) {
 						offset += 3
 						result = tokens.Identifier
 						s.pseudo = tokens.Loop
@@ -558,11 +704,31 @@ loop:
 						break loop
 					}
 				}
+			case 'n':
+				switch src[offset] {
+				case 'o':
+					// none
+					if src[offset+1] == 'n' && src[offset+2] == 'e' && !identFollows(src[offset+3:]) {
+						offset += 3
+						result = tokens.Identifier
+						s.pseudo = tokens.None
+						s.value = "none"
+						break loop
+					}
+				case 'u':
+					// null
+					if src[offset+1] == 'l' && src[offset+2] == 'l' && !identFollows(src[offset+3:]) {
+						offset += 3
+						result = tokens.Null
+						s.value = "null"
+						break loop
+					}
+				}
 			case 'o':
 				// operator
 				if src[offset] == 'p' && src[offset+1] == 'e' && src[offset+2] == 'r' &&
 					src[offset+3] == 'a' && src[offset+4] == 't' && src[offset+5] == 'o' &&
-					src[offset+6] == 'r' && !identExtender(src[offset+7]) {
+					src[offset+6] == 'r' && !identFollows(src[offset+7:]) {
 					offset += 7
 					result = tokens.Identifier
 					s.pseudo = tokens.Operator
@@ -574,7 +740,7 @@ loop:
 				case 'o':
 					// postfix
 					if src[offset+1] == 's' && src[offset+2] == 't' && src[offset+3] == 'f' &&
-						src[offset+4] == 'i' && src[offset+5] == 'x' && !identExtender(src[offset+6]) {
+						src[offset+4] == 'i' && src[offset+5] == 'x' && !identFollows(src[offset+6:]) {
 						offset += 6
 						result = tokens.Identifier
 						s.pseudo = tokens.Postfix
@@ -586,7 +752,7 @@ loop:
 					case 'e':
 						// prefix
 						if src[offset+2] == 'f' && src[offset+3] == 'i' && src[offset+4] == 'x' &&
-							!identExtender(src[offset+5]) {
+							!identFollows(src[offset+5:]) {
 							offset += 5
 							result = tokens.Identifier
 							s.pseudo = tokens.Prefix
@@ -598,7 +764,7 @@ loop:
 			case 't':
 				// true
 				if src[offset] == 'r' && src[offset+1] == 'u' && src[offset+2] == 'e' &&
-					!identExtender(src[offset+3]) {
+					!identFollows(src[offset+3:]) {
 					offset += 3
 					result = tokens.True
 					s.value = "true"
@@ -611,7 +777,7 @@ loop:
 					case 't':
 						// return
 						if src[offset+2] == 'u' && src[offset+3] == 'r' && src[offset+4] == 'n' &&
-							!identExtender(src[offset+5]) {
+							!identFollows(src[offset+5:]) {
 							offset += 5
 							result = tokens.Return
 							break loop
@@ -620,7 +786,7 @@ loop:
 				case 'i':
 					// right
 					if src[offset+1] == 'g' && src[offset+2] == 'h' && src[offset+3] == 't' &&
-						!identExtender(src[offset+4]) {
+						!identFollows(src[offset+4:]) {
 						offset += 4
 						result = tokens.Identifier
 						s.pseudo = tokens.Right
@@ -628,20 +794,29 @@ loop:
 						break loop
 					}
 				}
+			case 's':
+				// same
+				if src[offset] == 'a' && src[offset+1] == 'm' && src[offset+2] == 'e' && !identFollows(src[offset+3:]) {
+					offset += 3
+					result = tokens.Identifier
+					s.pseudo = tokens.Same
+					s.value = "same"
+					break loop
+				}
 			case 'v':
 				switch src[offset] {
 				case 'a':
 					switch src[offset+1] {
 					case 'r':
 						// var
-						if !identExtender(src[offset+2]) {
+						if !identFollows(src[offset+2:]) {
 							offset += 2
 							result = tokens.Var
 							break loop
 						}
 					case 'l':
 						// val
-						if !identExtender(src[offset+2]) {
+						if !identFollows(src[offset+2:]) {
 							offset += 2
 							result = tokens.Val
 							break loop
@@ -656,7 +831,7 @@ loop:
 						switch src[offset+2] {
 						case 'n':
 							// ehrn
-							if !identExtender(src[offset+3]) {
+							if !identFollows(src[offset+3:]) {
 								offset += 3
 								result = tokens.Identifier
 								s.pseudo = tokens.When
@@ -665,7 +840,7 @@ loop:
 							}
 						case 'r':
 							// where
-							if src[offset+3] == 'e' && !identExtender(src[offset+4]) {
+							if src[offset+3] == 'e' && !identFollows(src[offset+4:]) {
 								offset += 4
 								result = tokens.Identifier
 								s.pseudo = tokens.Where
@@ -676,7 +851,7 @@ loop:
 					case 'i':
 						// while
 						if src[offset+2] == 'l' && src[offset+3] == 'e' &&
-							!identExtender(src[offset+4]) {
+							!identFollows(src[offset+4:]) {
 							offset += 4
 							result = tokens.Identifier
 							s.pseudo = tokens.While
@@ -691,8 +866,8 @@ loop:
 			// Identifier
 		case 'd',
 			'g', 'h', 'j',
-			'k', 'm', 'n',
-			'q', 's',
+			'k', 'm',
+			'q',
 			'u', 'x', 'y', 'z',
 			'A', 'B', 'C', 'D', 'E',
 			'F', 'G', 'H', 'I', 'J',
@@ -700,238 +875,43 @@ loop:
 			'P', 'Q', 'R', 'S', 'T',
 			'U', 'V', 'W', 'X', 'Y', 'Z',
 			'_':
-		identLoop:
-			for {
-				last := offset
-				b = src[offset]
-				offset++
-				switch b {
-				default:
-					offset = last
-					break identLoop
-				case 'a', 'b', 'c', 'd', 'e',
-					'f', 'g', 'h', 'i', 'j',
-					'k', 'l', 'm', 'n', 'o',
-					'p', 'q', 'r', 's', 't',
-					'u', 'v', 'w', 'x', 'y', 'z',
-					'A', 'B', 'C', 'D', 'E',
-					'F', 'G', 'H', 'I', 'J',
-					'K', 'L', 'M', 'N', 'O',
-					'P', 'Q', 'R', 'S', 'T',
-					'U', 'V', 'W', 'X', 'Y', 'Z',
-					'_', '$',
-					'0', '1', '2', '3', '4',
-					'5', '6', '7', '8', '9':
-					continue
-				}
-			}
+			offset = identEnd(src, offset)
 			s.value = string(src[start:offset])
 			result = tokens.Identifier
-		case '0':
-			if src[offset] == 'x' {
-				offset++
-				first := offset
-				var value uint64
-			hexLoop:
-				for {
-					last := offset
-					b = src[offset]
-					offset++
-					switch b {
-					default:
-						offset = last
-						break hexLoop
-					case '0', '1', '2', '3', '4',
-						'5', '6', '7', '8', '9':
-						value = value*16 + (uint64(b) - uint64('0'))
-						continue
-					case 'A', 'B', 'C', 'D', 'E', 'F':
-						value = value*16 + 10 + (uint64(b) - uint64('A'))
-						continue
-					case 'a', 'b', 'c', 'd', 'e', 'f':
-						value = value*16 + 10 + (uint64(b) - uint64('a'))
-						continue
-					case '_':
-						continue
-					}
-				}
-				if first == offset {
-					result = tokens.Invalid
-					s.msg = "Invalid hex format"
-				} else {
-					b = src[offset]
-					switch b {
-					case 'u':
-						offset++
-						b = src[offset]
-						switch b {
-						default:
-							result = tokens.Literal
-							s.value = uint32(value)
-						case 'b':
-							offset++
-							result = tokens.Literal
-							s.value = byte(value)
-						case 'l':
-							offset++
-							result = tokens.Literal
-							s.value = uint64(value)
-						}
-					case 'l':
-						offset++
-						result = tokens.Literal
-						s.value = int64(value)
-					case 'i':
-						offset++
-						fallthrough
-					default:
-						result = tokens.Literal
-						s.value = int32(value)
-					}
-				}
-				switch src[offset] {
-				case 'a', 'b', 'c', 'd', 'e',
-					'f', 'g', 'h', 'i', 'j',
-					'k', 'l', 'm', 'n', 'o',
-					'p', 'q', 'r', 's', 't',
-					'u', 'v', 'w', 'x', 'y',
-					'z':
-					result = tokens.Invalid
-					s.msg = fmt.Sprintf("Extra character '%c' after literal", rune(src[offset]))
-				}
-				break loop
-			}
-			fallthrough
-		case '1', '2', '3', '4',
+		case '0', '1', '2', '3', '4',
 			'5', '6', '7', '8', '9':
-			value := uint64(int(b) - int('0'))
-			isFloat := false
-		numberLoop:
-			for {
-				last := offset
-				b = src[offset]
-				offset++
-				switch b {
-				default:
-					offset = last
-					break numberLoop
-				case '0', '1', '2', '3', '4',
-					'5', '6', '7', '8', '9':
-					if !isFloat {
-						value = value*10 + (uint64(b) - uint64('0'))
-					}
-				case '.':
-					if src[offset] == '.' {
-						offset--
-						break numberLoop
-					}
-					isFloat = true
-				}
-			}
-			if !isFloat {
-				b = src[offset]
-				switch b {
-				case 'u':
-					offset++
-					b = src[offset]
-					switch b {
-					default:
-						result = tokens.Literal
-						s.value = uint(value)
-					case 'b':
-						offset++
-						result = tokens.Literal
-						s.value = byte(value)
-					case 'l':
-						offset++
-						result = tokens.Literal
-						s.value = value
-					}
-				case 'l':
-					offset++
-					result = tokens.Literal
-					s.value = int64(value)
-				case 'f':
-					fvalue, err := strconv.ParseFloat(string(src[start:offset]), 32)
-					offset++
-					if err != nil {
-						result = tokens.Invalid
-						s.msg = err.Error()
-					} else {
-						s.value = float32(fvalue)
-						result = tokens.Literal
-					}
-				case 'd':
-					fvalue, err := strconv.ParseFloat(string(src[start:offset]), 64)
-					offset++
-					if err != nil {
-						result = tokens.Invalid
-						s.msg = err.Error()
-					} else {
-						s.value = fvalue
-						result = tokens.Literal
-					}
-				case 'i':
-					offset++
-					fallthrough
-				default:
-					result = tokens.Literal
-					s.value = int(value)
-				}
-			} else {
-				var err error
-				if src[offset] == 'f' {
-					fvalue32, err32 := strconv.ParseFloat(string(src[start:offset]), 32)
-					s.value = float32(fvalue32)
-					err = err32
-					result = tokens.Literal
-					offset++
-				} else {
-					fvalue64, err64 := strconv.ParseFloat(string(src[start:offset]), 64)
-					s.value = fvalue64
-					err = err64
-					result = tokens.Literal
-					if src[offset] == 'd' {
-						offset++
-					}
-				}
-				if err != nil {
-					result = tokens.Invalid
-					s.msg = err.Error()
-				}
+			var msg string
+			s.value, offset, msg = scanNumber(src, start)
+			result = tokens.Literal
+			if msg != "" {
+				result = tokens.Invalid
+				s.msg = msg
 			}
 		case '\'':
 			var value rune
-			b = src[offset]
-			offset++
-			switch b {
+			var msg string
+			switch src[offset] {
 			case '\\':
-				value = '\\'
-				b = src[offset]
+				value, offset, msg = scanEscape(src, offset+1)
+			case '\'':
 				offset++
-				switch b {
-				case '0':
-					value = '\x00'
-				case 'n':
-					value = '\n'
-				case 'r':
-					value = '\r'
-				case 'b':
-					value = '\b'
-				case 't':
-					value = '\t'
-				case '\'':
-					value = '\''
-				default:
-					result = tokens.Invalid
-					s.msg = "Invalid escape"
-				}
+				msg = "Empty character literal"
+			case 0:
+				msg = "Invalid character literal"
 			default:
-				value = rune(b)
+				var size int
+				value, size = utf8.DecodeRune(src[offset:])
+				offset += size
+				if value == utf8.RuneError && size == 1 {
+					msg = "Invalid UTF-8 encoding"
+				}
+			}
+			if msg == "" && src[offset] != '\'' {
+				msg = "Invalid character literal"
 			}
-			if src[offset] != '\'' {
+			if msg != "" {
 				result = tokens.Invalid
-				s.msg = "Invalid character literal"
+				s.msg = msg
 			} else {
 				result = tokens.Literal
 				offset++
@@ -943,7 +923,11 @@ loop:
 				b = src[offset]
 				offset++
 				switch b {
-				case '\n', '\r', '\\', '\x00':
+				case '\n', '\r', '\x00':
+					offset--
+					result = tokens.Invalid
+					break loop
+				case '\\':
 					result = tokens.Invalid
 					break loop
 				case '`':
@@ -955,6 +939,7 @@ loop:
 			}
 		case '"':
 			var value string
+			var invalid string
 			copyFrom := start + 1
 			for {
 				b = src[offset]
@@ -962,38 +947,41 @@ loop:
 				switch b {
 				case '\\':
 					value += string(src[copyFrom : offset-1])
-					b = src[offset]
-					offset++
-					switch b {
-					case 'n':
-						value += "\n"
-						copyFrom = offset
-					case 'r':
-						value += "\r"
-						copyFrom = offset
-					case 'b':
-						value += "\b"
-						copyFrom = offset
-					case 't':
-						value += "\t"
-						copyFrom = offset
-					case '\\':
-						value += "\\"
-						copyFrom = offset
-					default:
-						copyFrom = offset - 1
+					r, next, msg := scanEscape(src, offset)
+					if msg != "" && invalid == "" {
+						invalid = msg
 					}
+					value += string(r)
+					offset = next
+					copyFrom = offset
 				case '"':
 					value += string(src[copyFrom : offset-1])
 					s.value = value
 					result = tokens.Literal
+					if invalid != "" {
+						result = tokens.Invalid
+						s.msg = invalid
+					}
 					break loop
 				case '\n', '\r', 0:
+					offset--
 					result = tokens.Invalid
 					s.msg = "Unterminated string"
 					break loop
 				}
 			}
+		default:
+			if b >= utf8.RuneSelf {
+				r, size := utf8.DecodeRune(src[start:])
+				offset = start + size
+				if isIdentStart(r) {
+					offset = identEnd(src, offset)
+					s.value = string(src[start:offset])
+					result = tokens.Identifier
+				} else {
+					s.msg = fmt.Sprintf("Invalid character %q", r)
+				}
+			}
 		}
 		break loop
 	}
@@ -1003,3 +991,19 @@ loop:
 	s.line = line
 	return result
 }
+
+// record records trivia found between start and end if trivia is being recorded
+func (s *Scanner) record(kind TriviaKind, start, end int, ownLine bool) {
+	if s.flags&TriviaScan == 0 {
+		return
+	}
+	trivia := Trivia{Kind: kind, Start: location.Pos(start), End: location.Pos(end), OwnLine: ownLine}
+	if kind != BlankLine {
+		trivia.Text = string(s.src[start:end])
+	}
+	if s.fb != nil {
+		trivia.Start = s.fb.Pos(start)
+		trivia.End = s.fb.Pos(end)
+	}
+	s.trivia = append(s.trivia, trivia)
+}
@@ -34,6 +34,19 @@ func scannerOf(text string) *scanner.Scanner {
 	return scanner.NewScanner(src, 0, nil)
 }
 
+// value scans text as a single token returning the token and its value, or its message if the
+// token is invalid
+func value(text string) (tokens.Token, interface{}) {
+	s := scannerOf(text)
+	token := s.Next()
+	if token == tokens.Invalid {
+		return token, s.Message()
+	}
+	result := s.Value()
+	Expect(s.Next()).To(Equal(tokens.EOF))
+	return token, result
+}
+
 var _ = Describe("scanner", func() {
 	Describe("when constructing the instance", func() {
 		It("should not panic", func() {
@@ -50,6 +63,15 @@ var _ = Describe("scanner", func() {
 			scanner.NewScanner([]byte{'a', 'b', 'c'}, 0, nil)
 		})
 	})
+	It("can start scanning at an offset", func() {
+		s := scanner.NewScannerAt([]byte("a\r\nb\rc d\n\x00"), 7, 0, nil)
+		Expect(s.Next()).To(Equal(tokens.Identifier))
+		Expect(s.Value()).To(Equal("d"))
+		Expect(s.Start()).To(Equal(location.Pos(7)))
+		Expect(s.Line()).To(Equal(3))
+		Expect(s.Next()).To(Equal(tokens.EOF))
+		Expect(s.Line()).To(Equal(4))
+	})
 	Describe("when parsing", func() {
 		It("should parse 'ident' as an IDENT", func() {
 			scanString("ident", tokens.Identifier)
@@ -96,6 +118,10 @@ var _ = Describe("scanner", func() {
 		It("can special runes in a string", func() {
 			scanString("\" \\\" \\r \\b \\\\ \"", tokens.Literal)
 		})
+		It("reports an unterminated string at the end of the file", func() {
+			scanString("a \"b", tokens.Identifier, tokens.Invalid)
+			scanString("a `b", tokens.Identifier, tokens.Invalid)
+		})
 		It("can scan a escaped identifier", func() {
 			scanString(" `+` ", tokens.Identifier)
 		})
@@ -119,6 +145,191 @@ var _ = Describe("scanner", func() {
 			s.Next()
 			Expect(s.NewLineLocation()).To(Equal(location.Pos(10)))
 		})
+		It("can skip a block comment", func() {
+			scanString("a /* b \n c */ d", tokens.Identifier, tokens.Identifier)
+		})
+		It("can report an unterminated block comment", func() {
+			scanString("a /* b", tokens.Identifier, tokens.Invalid)
+		})
+		It("treats a block comment with a new line as a new line", func() {
+			s := scannerOf("a /* \n */ b")
+			s.Next()
+			s.Next()
+			Expect(s.NewLineLocation()).To(Equal(location.Pos(5)))
+			Expect(s.Line()).To(Equal(2))
+		})
+	})
+	Describe("when scanning unicode", func() {
+		It("can scan unicode identifiers", func() {
+			for _, text := range []string{"größe", "名前", "Δx", "_λ1", "x́", "café$"} {
+				token, v := value(text)
+				Expect(token).To(Equal(tokens.Identifier), text)
+				Expect(v).To(Equal(text))
+			}
+		})
+		It("does not treat a keyword prefix of an identifier as a keyword", func() {
+			token, v := value("valé")
+			Expect(token).To(Equal(tokens.Identifier))
+			Expect(v).To(Equal("valé"))
+		})
+		It("reports an invalid character", func() {
+			scanString("a ∑ b", tokens.Identifier, tokens.Invalid, tokens.Identifier)
+		})
+		It("can scan string escapes", func() {
+			_, v := value(`"\" \' \0 \x41 \u{e9} \u{1F600}"`)
+			Expect(v).To(Equal("\" ' \x00 A é 😀"))
+		})
+		It("can scan unicode strings", func() {
+			_, v := value(`"größe 名前"`)
+			Expect(v).To(Equal("größe 名前"))
+		})
+		It("reports invalid escapes", func() {
+			for _, text := range []string{`"\q"`, `"\x4"`, `"\u{}"`, `"\u{110000}"`, `"\u{D800}"`, `"\u41"`,
+				`'\q'`} {
+				token, _ := value(text)
+				Expect(token).To(Equal(tokens.Invalid), text)
+			}
+		})
+		It("can scan unicode characters", func() {
+			for text, expected := range map[string]rune{
+				"'é'": 'é', "'名'": '名', "'😀'": '😀', `'\u{E9}'`: 'é', `'\x7F'`: '\x7F', `'\"'`: '"',
+			} {
+				token, v := value(text)
+				Expect(token).To(Equal(tokens.Literal), text)
+				Expect(v).To(Equal(expected), text)
+			}
+		})
+		It("reports a character literal with more than one character", func() {
+			token, _ := value("'ab'")
+			Expect(token).To(Equal(tokens.Invalid))
+		})
+		It("counts columns in runes", func() {
+			fs := tokens.NewFileSet()
+			text := "größe 名前 x"
+			fb := fs.BuildFile("file", len(text))
+			s := scanner.NewScanner(append([]byte(text), 0), 0, fb)
+			s.Next()
+			s.Next()
+			s.Next()
+			f := fb.Build()
+			Expect(f.Column(s.Start())).To(Equal(10))
+		})
+	})
+	Describe("when scanning numbers", func() {
+		It("can scan integer literals of each type", func() {
+			for text, expected := range map[string]interface{}{
+				"1_000": 1000, "1_000i": 1000, "4_294_967_295u": uint(4294967295), "255ub": byte(255),
+				"9_223_372_036_854_775_807l": int64(9223372036854775807), "18446744073709551615ul": uint64(18446744073709551615),
+				"0x7F": int32(0x7F), "0xFFFF_FFFF": int32(-1), "0xFFu": uint32(0xFF), "0xFFub": byte(0xFF),
+				"0xFFFFFFFFFFFFFFFFul": uint64(0xFFFFFFFFFFFFFFFF), "0b1010": int32(10), "0b1111_0000ub": byte(0xF0),
+				"0o17": int32(15), "0o777l": int64(511),
+			} {
+				token, v := value(text)
+				Expect(token).To(Equal(tokens.Literal), text)
+				Expect(v).To(Equal(expected), text)
+			}
+		})
+		It("can scan floating point literals with separators", func() {
+			_, v := value("1_000.5")
+			Expect(v).To(Equal(1000.5))
+			_, v = value("1_000f")
+			Expect(v).To(Equal(float32(1000)))
+		})
+		It("reports literals that overflow their type", func() {
+			for text, expected := range map[string]string{
+				"300ub":                  "Literal 300ub overflows Byte",
+				"2147483648":             "Literal 2147483648 overflows Int",
+				"4294967296u":            "Literal 4294967296u overflows UInt",
+				"0x1_0000_0000":          "Literal 0x1_0000_0000 overflows Int",
+				"0xFFFFFFFFFFFFFFFFFF":   "Literal 0xFFFFFFFFFFFFFFFFFF overflows Int",
+				"9223372036854775808l":   "Literal 9223372036854775808l overflows Long",
+				"18446744073709551616ul": "Literal 18446744073709551616ul overflows ULong",
+			} {
+				token, message := value(text)
+				Expect(token).To(Equal(tokens.Invalid), text)
+				Expect(message).To(Equal(expected))
+			}
+		})
+		It("reports invalid digits", func() {
+			for text, expected := range map[string]string{
+				"0b102":  "Invalid digit '2' in binary literal",
+				"0o8":    "Invalid digit '8' in octal literal",
+				"0x":     "Invalid hexadecimal literal",
+				"1__000": "Invalid digit separator",
+				"1_":     "Invalid digit separator",
+				"0xFFg":  "Extra character 'g' after literal",
+			} {
+				token, message := value(text)
+				Expect(token).To(Equal(tokens.Invalid), text)
+				Expect(message).To(Equal(expected))
+			}
+		})
+		It("records the text before a token", func() {
+			s := scannerOf("a /* b */\n c")
+			s.Next()
+			Expect(s.Leading()).To(Equal(""))
+			s.Next()
+			Expect(s.Leading()).To(Equal(" /* b */\n "))
+		})
+		It("records the spelling of a literal", func() {
+			s := scannerOf(" 0xF_Fub ")
+			s.Next()
+			Expect(s.Text()).To(Equal("0xF_Fub"))
+		})
+	})
+	Describe("when recording trivia", func() {
+		trivia := func(text string) [][]scanner.Trivia {
+			s := scanner.NewScanner(append([]byte(text), 0), scanner.TriviaScan, nil)
+			var result [][]scanner.Trivia
+			for {
+				token := s.Next()
+				result = append(result, s.Trivia())
+				if token == tokens.EOF || token == tokens.Invalid {
+					return result
+				}
+			}
+		}
+		It("does not record trivia by default", func() {
+			s := scannerOf("// a\nb")
+			s.Next()
+			Expect(s.Trivia()).To(BeEmpty())
+		})
+		It("records a line comment", func() {
+			t := trivia("a // b\nc")
+			Expect(t[0]).To(BeEmpty())
+			Expect(t[1]).To(Equal([]scanner.Trivia{
+				{Kind: scanner.LineComment, Start: 2, End: 6, Text: "// b", OwnLine: false},
+			}))
+		})
+		It("records a block comment", func() {
+			t := trivia("/* a */ b /* c\n */")
+			Expect(t[0]).To(Equal([]scanner.Trivia{
+				{Kind: scanner.BlockComment, Start: 0, End: 7, Text: "/* a */", OwnLine: true},
+			}))
+			Expect(t[1]).To(Equal([]scanner.Trivia{
+				{Kind: scanner.BlockComment, Start: 10, End: 18, Text: "/* c\n */", OwnLine: false},
+			}))
+		})
+		It("records comments on their own line", func() {
+			t := trivia("a\n  // b\n// c\nd")
+			Expect(t[1]).To(Equal([]scanner.Trivia{
+				{Kind: scanner.LineComment, Start: 4, End: 8, Text: "// b", OwnLine: true},
+				{Kind: scanner.LineComment, Start: 9, End: 13, Text: "// c", OwnLine: true},
+			}))
+		})
+		It("records blank lines", func() {
+			t := trivia("\na\n \n\nb")
+			Expect(t[0]).To(Equal([]scanner.Trivia{{Kind: scanner.BlankLine, Start: 0, End: 0, OwnLine: true}}))
+			Expect(t[1]).To(Equal([]scanner.Trivia{
+				{Kind: scanner.BlankLine, Start: 3, End: 4, OwnLine: true},
+				{Kind: scanner.BlankLine, Start: 5, End: 5, OwnLine: true},
+			}))
+		})
+		It("preserves trivia when cloned", func() {
+			s := scanner.NewScanner(append([]byte("// a\nb"), 0), scanner.TriviaScan, nil)
+			s.Next()
+			Expect(s.Clone().Trivia()).To(Equal(s.Trivia()))
+		})
 	})
 })
 
@@ -104,14 +104,19 @@ func TestIdentifer(t *testing.T) {
 }
 
 func TestReservedSymbols(t *testing.T) {
-	parseString(t, "{}()[];: ,.::<||>[!!]{!!}",
+	parseString(t, "{}()[];: ,.::<||>[!!]{!!}?.",
 		tokens.LBrace, tokens.RBrace, tokens.LParen, tokens.RParen, tokens.LBrack, tokens.RBrack,
 		tokens.Semi, tokens.Colon, tokens.Comma, tokens.Dot, tokens.Scope, tokens.VocabularyStart,
 		tokens.VocabularyEnd, tokens.LBrackBang, tokens.BangRBrack, tokens.LBraceBang,
-		tokens.BangRBrace,
+		tokens.BangRBrace, tokens.QuestionDot,
 	)
 }
 
+func TestQuestionDot(t *testing.T) {
+	parseString(t, "a?.b a?..b", tokens.Identifier, tokens.QuestionDot, tokens.Identifier,
+		tokens.Identifier, tokens.Symbol, tokens.Symbol, tokens.Identifier)
+}
+
 func TestPseudoSymbols(t *testing.T) {
 	parsePseudo(t, "+ & | - * / % ! && || > >= = == != < <= ? -> .. ...", tokens.Symbol,
 		tokens.Add, tokens.And, tokens.Bar, tokens.Sub, tokens.Mult, tokens.Div, tokens.Rem, tokens.Not,
@@ -146,7 +151,7 @@ func testPseudoSymbol(t *testing.T, pseudoList ...tokens.PseudoToken) {
 }
 
 func TestReservedWords(t *testing.T) {
-	testReservedWord(t, tokens.False, tokens.Let, tokens.True, tokens.Return, tokens.Val, tokens.Var)
+	testReservedWord(t, tokens.False, tokens.Let, tokens.Null, tokens.True, tokens.Return, tokens.Val, tokens.Var)
 }
 
 func TestPseudoReservedSymbols(t *testing.T) {
@@ -160,9 +165,9 @@ func TestPseudoReservedSymbols(t *testing.T) {
 
 func TestPseudoWords(t *testing.T) {
 	testPseudoWord(t,
-		tokens.After, tokens.Before, tokens.Break, tokens.Continue, tokens.Else,
-		tokens.If, tokens.Infix, tokens.Identifiers, tokens.Left, tokens.Loop,
-		tokens.Operator, tokens.Postfix, tokens.Prefix, tokens.Right,
+		tokens.After, tokens.As, tokens.Before, tokens.Break, tokens.Continue, tokens.Else,
+		tokens.If, tokens.Infix, tokens.Identifiers, tokens.Left, tokens.Loop, tokens.None,
+		tokens.Operator, tokens.Postfix, tokens.Prefix, tokens.Right, tokens.Same,
 		tokens.When, tokens.Where, tokens.While,
 	)
 }
@@ -0,0 +1,44 @@
+package scanner
+
+import (
+	"dyego0/location"
+)
+
+// TriviaKind is the kind of text found between tokens
+type TriviaKind int
+
+const (
+	// LineComment is a comment that starts with // and ends at the end of the line
+	LineComment TriviaKind = iota
+
+	// BlockComment is a comment that starts with /* and ends with */
+	BlockComment
+
+	// BlankLine is a line that only contains white space
+	BlankLine
+)
+
+func (k TriviaKind) String() string {
+	switch k {
+	case LineComment:
+		return "line comment"
+	case BlockComment:
+		return "block comment"
+	case BlankLine:
+		return "blank line"
+	default:
+		return "invalid trivia"
+	}
+}
+
+// Trivia is a comment or blank line found between tokens when scanning with TriviaScan
+type Trivia struct {
+	Kind       TriviaKind
+	Start, End location.Pos
+
+	// Text is the text of a comment including its delimiters
+	Text string
+
+	// OwnLine is true if no token precedes the trivia on the same line
+	OwnLine bool
+}
@@ -0,0 +1,123 @@
+package scanner
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// isIdentStart returns true if r can start an identifier. Identifiers follow the default
+// identifier syntax of UAX #31 with the addition of '_' as a start character.
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.In(r, unicode.Nl, unicode.Other_ID_Start)
+}
+
+// isIdentContinue returns true if r can continue an identifier. In addition to the UAX #31
+// continue characters, '$' can continue an identifier.
+func isIdentContinue(r rune) bool {
+	return r == '$' || isIdentStart(r) ||
+		unicode.In(r, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc, unicode.Other_ID_Continue)
+}
+
+// identFollows returns true if src starts with a character that continues an identifier
+func identFollows(src []byte) bool {
+	b := src[0]
+	if b < utf8.RuneSelf {
+		return identExtender(b)
+	}
+	r, _ := utf8.DecodeRune(src)
+	return isIdentContinue(r)
+}
+
+// identEnd returns the offset of the end of the identifier that continues at offset
+func identEnd(src []byte, offset int) int {
+	for {
+		b := src[offset]
+		switch {
+		case b < utf8.RuneSelf:
+			if !identExtender(b) {
+				return offset
+			}
+			offset++
+		default:
+			r, size := utf8.DecodeRune(src[offset:])
+			if !isIdentContinue(r) {
+				return offset
+			}
+			offset += size
+		}
+	}
+}
+
+// scanEscape scans the escape sequence in a string or character literal that follows the '\' at
+// offset-1. Returns the rune escaped, the offset after the escape sequence and a message if the
+// escape sequence is invalid.
+func scanEscape(src []byte, offset int) (rune, int, string) {
+	b := src[offset]
+	switch b {
+	case '0':
+		return '\x00', offset + 1, ""
+	case 'n':
+		return '\n', offset + 1, ""
+	case 'r':
+		return '\r', offset + 1, ""
+	case 'b':
+		return '\b', offset + 1, ""
+	case 't':
+		return '\t', offset + 1, ""
+	case '\\', '\'', '"':
+		return rune(b), offset + 1, ""
+	case 'x':
+		// \xHH is the code point HH
+		value, ok := hexValue(src[offset+1])
+		if !ok {
+			return 0, offset + 1, "Invalid hexadecimal escape"
+		}
+		low, ok := hexValue(src[offset+2])
+		if !ok {
+			return 0, offset + 2, "Invalid hexadecimal escape"
+		}
+		return rune(value<<4 | low), offset + 3, ""
+	case 'u':
+		// \u{H...} is the code point H... given by 1 to 6 hexadecimal digits
+		offset++
+		if src[offset] != '{' {
+			return 0, offset, "Invalid unicode escape"
+		}
+		offset++
+		var value rune
+		digits := 0
+		for {
+			b := src[offset]
+			if b == '}' {
+				offset++
+				break
+			}
+			digit, ok := hexValue(b)
+			if !ok || digits == 6 {
+				return 0, offset, "Invalid unicode escape"
+			}
+			value = value<<4 | rune(digit)
+			digits++
+			offset++
+		}
+		if digits == 0 || !utf8.ValidRune(value) {
+			return 0, offset, "Invalid unicode code point"
+		}
+		return value, offset, ""
+	case '\n', '\r', 0:
+		return 0, offset, "Invalid escape"
+	}
+	return 0, offset + 1, "Invalid escape"
+}
+
+func hexValue(b byte) (int32, bool) {
+	switch {
+	case b >= '0' && b <= '9':
+		return int32(b - '0'), true
+	case b >= 'a' && b <= 'f':
+		return int32(b-'a') + 10, true
+	case b >= 'A' && b <= 'F':
+		return int32(b-'A') + 10, true
+	}
+	return 0, false
+}
@@ -0,0 +1,98 @@
+package tokens
+
+import (
+	"unicode/utf8"
+)
+
+// Edit is a change to the text of a file that replaces the text between the 0-based offsets Start
+// and End with Text
+type Edit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// Delta is the change in the size of the file made by the edit
+func (e Edit) Delta() int {
+	return len(e.Text) - (e.End - e.Start)
+}
+
+// Apply returns the result of applying the edit to text
+func (e Edit) Apply(text string) string {
+	return text[:e.Start] + e.Text + text[e.End:]
+}
+
+func (fs *fileSet) EditFile(f File, edit Edit) FileBuilder {
+	old := f.(*file)
+	delta := edit.Delta()
+	size := old.size + delta
+	var fb *fileBuilder
+	if fs.fits(old, size) {
+		// The positions of the file are reused so positions before the edit do not change
+		fb = &fileBuilder{filename: old.filename, size: size, base: old.base, fileSet: fs}
+		if old.base+size > fs.base {
+			fs.base = old.base + size
+		}
+	} else {
+		fb = fs.BuildFile(old.filename, size).(*fileBuilder)
+	}
+
+	// A line is declared at the offset that follows the end of the previous line so the lines
+	// that start in the replaced text are replaced by the lines of the text of the edit
+	fb.lines = make(lines, 0, len(old.lines))
+	for _, line := range old.lines {
+		if line <= edit.Start {
+			fb.lines = append(fb.lines, line)
+		}
+	}
+	for _, r := range old.runes {
+		if r.offset < edit.Start {
+			fb.runes = append(fb.runes, r)
+		}
+	}
+	text := edit.Text
+	for offset := 0; offset < len(text); {
+		b := text[offset]
+		switch {
+		case b == '\r' && offset+1 < len(text) && text[offset+1] == '\n':
+			offset++
+			fallthrough
+		case b == '\n', b == '\r':
+			offset++
+			fb.AddLine(edit.Start + offset)
+		case b >= utf8.RuneSelf:
+			_, size := utf8.DecodeRuneInString(text[offset:])
+			if size > 1 {
+				fb.AddRune(edit.Start+offset, size)
+			}
+			offset += size
+		default:
+			offset++
+		}
+	}
+	for _, line := range old.lines {
+		if line > edit.End {
+			fb.AddLine(line + delta)
+		}
+	}
+	for _, r := range old.runes {
+		if r.offset >= edit.End {
+			fb.AddRune(r.offset+delta, r.size)
+		}
+	}
+	return fb
+}
+
+// fits returns true if a file of the given size fits in the positions of file, which are those up
+// to the base of the file declared after it
+func (fs *fileSet) fits(file *file, size int) bool {
+	index := fs.files.Search(file.base)
+	if index == len(fs.files) || fs.files[index] != file {
+		return false
+	}
+	if index < len(fs.files)-1 {
+		return file.base+size < fs.files[index+1].base
+	}
+	// The last file can grow if no file has been declared after it
+	return file.base+size <= fs.base || file.base+file.size == fs.base
+}
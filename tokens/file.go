@@ -9,7 +9,7 @@ import (
 
 // File access line column inforamtion for a source file
 type File interface {
-	// Column is the 1-based column of the given Pos the file
+	// Column is the 1-based column of the given Pos the file counted in runes
 	Column(p location.Pos) int
 
 	// FileName is the file name given when declared in the FileSet
@@ -43,6 +43,10 @@ type FileBuilder interface {
 	// is more efficient to declare them in order.
 	AddLine(offset int)
 
+	// AddRune declares a rune encoded in UTF-8 with more than one byte at offset. Columns count
+	// such a rune as a single column. Runes can be declared in any order.
+	AddRune(offset, size int)
+
 	// Pos calculates a Pos for the given 0-based offset using UTF-8 encoding.
 	Pos(offset int) location.Pos
 
@@ -57,6 +61,13 @@ type FileSet interface {
 	// building the File defintiion which is immutable after it is built.
 	BuildFile(filename string, size int) FileBuilder
 
+	// EditFile declares a file in the file set that is the result of applying edit to file. The
+	// lines and runes of file outside of the edit, and those in the text of the edit, are
+	// already declared in the FileBuilder returned. If the edited file fits in the positions of
+	// file, the positions before the edit are unchanged and the edited file replaces file in the
+	// file set when it is built.
+	EditFile(file File, edit Edit) FileBuilder
+
 	// Position returns a Position which gives access allows calculating the Line, Column
 	// and FileName of Pos for any file in the FileSet. Pos are only 4 bytes and allow
 	// efficient encoding of file/line/column information. The Position is significantly
@@ -73,8 +84,8 @@ type Position interface {
 	// FileName is the name of the file declared with FileSet.BuildFile
 	FileName() string
 
-	// Column is a 1-based column of the source position which is the number of UTF-8
-	// code units between the source position and the start of the line.
+	// Column is a 1-based column of the source position which is the number of runes
+	// between the source position and the start of the line.
 	Column() int
 
 	// Line is a 1-based line of the soruce position.
@@ -109,11 +120,25 @@ func (ls lines) Insert(index, value int) lines {
 	return result
 }
 
+// wideRune is a rune that is encoded in more than one byte
+type wideRune struct {
+	offset, size int
+}
+
+type wideRunes []wideRune
+
+func (ws wideRunes) Search(offset int) int {
+	return sort.Search(len(ws), func(index int) bool {
+		return ws[index].offset >= offset
+	})
+}
+
 type fileBuilder struct {
 	filename string
 	base     int
 	size     int
 	lines    lines
+	runes    wideRunes
 	fileSet  *fileSet
 }
 
@@ -129,6 +154,20 @@ func (fb *fileBuilder) AddLine(offset int) {
 	}
 }
 
+func (fb *fileBuilder) AddRune(offset, size int) {
+	l := len(fb.runes)
+	if l == 0 || fb.runes[l-1].offset < offset {
+		fb.runes = append(fb.runes, wideRune{offset, size})
+		return
+	}
+	index := fb.runes.Search(offset)
+	if fb.runes[index].offset != offset {
+		fb.runes = append(fb.runes, wideRune{})
+		copy(fb.runes[index+1:], fb.runes[index:])
+		fb.runes[index] = wideRune{offset, size}
+	}
+}
+
 func (fb *fileBuilder) Pos(offset int) location.Pos {
 	if offset < 0 || offset > fb.size {
 		return location.Pos(-1)
@@ -137,7 +176,7 @@ func (fb *fileBuilder) Pos(offset int) location.Pos {
 }
 
 func (fb *fileBuilder) Build() File {
-	result := &file{filename: fb.filename, base: fb.base, size: fb.size, lines: fb.lines}
+	result := &file{filename: fb.filename, base: fb.base, size: fb.size, lines: fb.lines, runes: fb.runes}
 	fb.fileSet.add(result)
 	return result
 }
@@ -189,6 +228,7 @@ type file struct {
 	base     int
 	size     int
 	lines    lines
+	runes    wideRunes
 }
 
 func (f *file) lineOf(p location.Pos) int {
@@ -230,7 +270,12 @@ func (f *file) Column(p location.Pos) int {
 	if int(lp) < 0 {
 		return -1
 	}
-	return int(p-lp) + 1
+	column := int(p-lp) + 1
+	end := int(p) - f.base
+	for index := f.runes.Search(int(lp) - f.base); index < len(f.runes) && f.runes[index].offset < end; index++ {
+		column -= f.runes[index].size - 1
+	}
+	return column
 }
 
 func (f *file) FileName() string {
@@ -289,7 +334,10 @@ func (fs files) add(file *file) files {
 		return append(fs, file)
 	}
 	index := fs.Search(file.base)
-	if fs[index] != file {
+	if fs[index].base == file.base {
+		// An edited file replaces the file it was edited from
+		fs[index] = file
+	} else {
 		fs = fs.Insert(index, file)
 	}
 	return fs
@@ -3,6 +3,7 @@ package tokens_test
 import (
 	"fmt"
 	"strconv"
+	"unicode/utf8"
 
 	"dyego0/location"
 	"dyego0/tokens"
@@ -140,6 +141,21 @@ var _ = Describe("file", func() {
 		Expect(f.Column(location.Pos(2000))).To(Equal(-1))
 		Expect(f.Column(location.Pos(0))).To(Equal(1))
 	})
+	It("counts columns in runes", func() {
+		fs := tokens.NewFileSet()
+		fs.BuildFile("otherfile", 100).Build()
+		fb := fs.BuildFile("somefile", 1000)
+		fb.AddLine(0)
+		fb.AddLine(10)
+		fb.AddRune(14, 3)
+		fb.AddRune(12, 2)
+		fb.AddRune(2, 4)
+		f := fb.Build()
+		Expect(f.Column(f.Pos(8))).To(Equal(6))
+		Expect(f.Column(f.Pos(12))).To(Equal(3))
+		Expect(f.Column(f.Pos(14))).To(Equal(4))
+		Expect(f.Column(f.Pos(18))).To(Equal(6))
+	})
 	It("can convert to an offset", func() {
 		fs := tokens.NewFileSet()
 		f0 := fs.BuildFile("somefile0", 1000).Build()
@@ -180,4 +196,48 @@ var _ = Describe("file", func() {
 		Expect(f.LineStart(5)).To(Equal(1000))
 		Expect(f.LineStart(100000)).To(Equal(1000))
 	})
+	It("can edit a file", func() {
+		fs := tokens.NewFileSet()
+		text := "ab\ncd\n\u00e9f\r\ng\u00e9\n"
+		edits := []tokens.Edit{
+			{Start: 3, End: 6, Text: "x\ny\nz\u00e9"},
+			{Start: 0, End: 0, Text: "\u00e9\n"},
+			{Start: 2, End: 3, Text: ""},
+			{Start: 6, End: 10, Text: "\r"},
+			{Start: 14, End: 14, Text: "h"},
+		}
+		f := declareFile(fs, text)
+		for _, edit := range edits {
+			edited := edit.Apply(text)
+			expected := declareFile(fs, edited)
+			f = fs.EditFile(f, edit).Build()
+			Expect(f.Size()).To(Equal(len(edited)))
+			for offset := 0; offset <= len(edited); offset++ {
+				Expect(f.Line(f.Pos(offset))).To(Equal(expected.Line(expected.Pos(offset))), "%q %d", edited, offset)
+				Expect(f.Column(f.Pos(offset))).To(Equal(expected.Column(expected.Pos(offset))), "%q %d", edited, offset)
+			}
+			text = edited
+		}
+	})
+	It("can apply an edit", func() {
+		edit := tokens.Edit{Start: 2, End: 4, Text: "abc"}
+		Expect(edit.Delta()).To(Equal(1))
+		Expect(edit.Apply("012345")).To(Equal("01abc45"))
+	})
 })
+
+// declareFile declares a file with the lines and runes of text
+func declareFile(fs tokens.FileSet, text string) tokens.File {
+	fb := fs.BuildFile("file", len(text))
+	fb.AddLine(0)
+	for offset, r := range text {
+		switch {
+		case r == '\r' && offset+1 < len(text) && text[offset+1] == '\n':
+		case r == '\n', r == '\r':
+			fb.AddLine(offset + 1)
+		case utf8.RuneLen(r) > 1:
+			fb.AddRune(offset, utf8.RuneLen(r))
+		}
+	}
+	return fb.Build()
+}
@@ -61,6 +61,9 @@ const (
 	// Dot '.'
 	Dot
 
+	// QuestionDot '?.'
+	QuestionDot
+
 	// Scope '::'
 	Scope
 
@@ -76,6 +79,9 @@ const (
 	// Let 'let'
 	Let
 
+	// Null 'null'
+	Null
+
 	// True 'true'
 	True
 
@@ -115,11 +121,13 @@ var tokens = [...]string{
 	Colon:           ":",
 	Comma:           ",",
 	Dot:             ".",
+	QuestionDot:     "?.",
 	Scope:           "::",
 	VocabularyStart: "<|",
 	VocabularyEnd:   "|>",
 	False:           "false",
 	Let:             "let",
+	Null:            "null",
 	True:            "true",
 	Return:          "return",
 	Val:             "val",
@@ -143,6 +151,9 @@ const (
 	// After is the pseudo token "after"
 	After PseudoToken = iota
 
+	// As is the pseudo token "as"
+	As
+
 	// Before is the psuedo token "before"
 	Before
 
@@ -170,6 +181,9 @@ const (
 	// Loop is the pseudo token "loop"
 	Loop
 
+	// None is the pseudo token "none"
+	None
+
 	// Operator it he pseudo token "opeartor"
 	Operator
 
@@ -182,6 +196,9 @@ const (
 	// Right is the pseudo token "right"
 	Right
 
+	// Same is the pseudo token "same"
+	Same
+
 	// When is the pseudo token "when"
 	When
 
@@ -265,6 +282,7 @@ const (
 
 var pseudoTokens = [...]string{
 	After:            "after",
+	As:               "as",
 	Before:           "before",
 	Break:            "break",
 	Continue:         "continue",
@@ -274,10 +292,12 @@ var pseudoTokens = [...]string{
 	Infix:            "infix",
 	Left:             "left",
 	Loop:             "loop",
+	None:             "none",
 	Operator:         "operator",
 	Prefix:           "prefix",
 	Postfix:          "postfix",
 	Right:            "right",
+	Same:             "same",
 	When:             "when",
 	Where:            "where",
 	While:            "while",
@@ -0,0 +1,200 @@
+package types
+
+import "strings"
+
+// Instantiate returns the instance of a generic type with the type arguments given. Instances
+// are shared so instantiating a generic type with the same type arguments returns the same
+// symbol. If the generic type is not built yet, the instance is built by Complete.
+func Instantiate(generic TypeSymbol, arguments []TypeSymbol) TypeSymbol {
+	g := generic.(*typeSymbolImpl)
+	for _, instance := range g.instances {
+		if sameArguments(instance.arguments, arguments) {
+			return instance
+		}
+	}
+	var names []string
+	for _, argument := range arguments {
+		names = append(names, argument.String())
+	}
+	result := &typeSymbolImpl{
+		name:      generic.Name() + "<" + strings.Join(names, ", ") + ">",
+		generic:   generic,
+		arguments: arguments,
+	}
+	g.instances = append(g.instances, result)
+	Complete(result)
+	return result
+}
+
+// Complete builds an instance of a generic type that was instantiated before the generic type
+// was built
+func Complete(instance TypeSymbol) {
+	i := instance.(*typeSymbolImpl)
+	if i.typ != nil || i.generic == nil || i.generic.Type() == nil {
+		return
+	}
+	generic := i.generic.Type()
+	substitution := make(map[TypeSymbol]TypeSymbol)
+	for index, parameter := range generic.TypeParameters() {
+		if index < len(i.arguments) {
+			substitution[parameter] = i.arguments[index]
+		}
+	}
+	members, _ := substituteMembers(generic.Members(), substitution)
+	signatures, _ := substituteSignatures(generic.Signatures(), substitution)
+	NewType(instance, generic.Kind(), members, nil, generic.TypeScope(), signatures, generic.Container())
+}
+
+// GenericOf returns the generic type and the type arguments of an instance of a generic type
+func GenericOf(instance TypeSymbol) (TypeSymbol, []TypeSymbol) {
+	i, ok := instance.(*typeSymbolImpl)
+	if !ok {
+		return nil, nil
+	}
+	return i.generic, i.arguments
+}
+
+// Substitute replaces the type variables in typeSym with the types substitution maps them to
+func Substitute(typeSym TypeSymbol, substitution map[TypeSymbol]TypeSymbol) TypeSymbol {
+	if typeSym == nil || len(substitution) == 0 {
+		return typeSym
+	}
+	if replacement, ok := substitution[typeSym]; ok && replacement != nil {
+		return replacement
+	}
+	if generic, arguments := GenericOf(typeSym); generic != nil {
+		var substituted []TypeSymbol
+		changed := false
+		for _, argument := range arguments {
+			result := Substitute(argument, substitution)
+			changed = changed || result != argument
+			substituted = append(substituted, result)
+		}
+		if changed {
+			return Instantiate(generic, substituted)
+		}
+		return typeSym
+	}
+	t := typeSym.Type()
+	if t == nil {
+		return typeSym
+	}
+	switch t.Kind() {
+	case Array:
+		if elements := Substitute(t.Elements(), substitution); elements != t.Elements() {
+			if t.Size() < 0 {
+				return MakeArray(elements)
+			}
+			result := NewTypeSymbol(elements.Name()+"[]", nil)
+			NewArrayType(result, elements, t.Size())
+			return result
+		}
+	case Reference:
+		if referant := Substitute(t.Referant(), substitution); referant != t.Referant() {
+			return MakeReference(referant)
+		}
+	case Optional:
+		if target := Substitute(t.Target(), substitution); target != t.Target() {
+			return MakeOptional(target)
+		}
+	case Function:
+		signatures, changed := substituteSignatures(t.Signatures(), substitution)
+		if changed {
+			return MakeFunction(signatures[0])
+		}
+	case Record:
+		if t.Symbol().Name() != "" {
+			return typeSym
+		}
+		members, membersChanged := substituteMembers(t.Members(), substitution)
+		signatures, signaturesChanged := substituteSignatures(t.Signatures(), substitution)
+		if membersChanged || signaturesChanged {
+			result := NewTypeSymbol("", nil)
+			NewType(result, Record, members, nil, t.TypeScope(), signatures, t.Container())
+			return result
+		}
+	}
+	return typeSym
+}
+
+// SubstituteSignature replaces the type variables in the parameters and result of signature.
+// The result is not generic so substituting the type parameters of a generic signature with
+// type arguments instantiates it.
+func SubstituteSignature(signature Signature, substitution map[TypeSymbol]TypeSymbol) Signature {
+	this, parameters, result := substituteSignature(signature, substitution)
+	return NewSignature(this, parameters, result)
+}
+
+func substituteSignature(
+	signature Signature,
+	substitution map[TypeSymbol]TypeSymbol,
+) (TypeSymbol, []Parameter, TypeSymbol) {
+	var parameters []Parameter
+	for _, parameter := range signature.Parameters() {
+		typ := Substitute(parameter.Type(), substitution)
+		if typ != parameter.Type() {
+			parameter = NewParameter(parameter.Name(), typ)
+		}
+		parameters = append(parameters, parameter)
+	}
+	return Substitute(signature.This(), substitution), parameters, Substitute(signature.Result(), substitution)
+}
+
+func substituteMembers(members []Member, substitution map[TypeSymbol]TypeSymbol) ([]Member, bool) {
+	var result []Member
+	changed := false
+	for _, member := range members {
+		typ := Substitute(member.Type(), substitution)
+		if typ == member.Type() {
+			result = append(result, member)
+			continue
+		}
+		changed = true
+		switch m := member.(type) {
+		case Field:
+			result = append(result, NewField(m.Name(), typ, m.Mutable()))
+		case TypeMember:
+			result = append(result, NewTypeMember(m.Name(), typ))
+		default:
+			result = append(result, NewField(m.Name(), typ, false))
+		}
+	}
+	return result, changed
+}
+
+func substituteSignatures(signatures []Signature, substitution map[TypeSymbol]TypeSymbol) ([]Signature, bool) {
+	var result []Signature
+	changed := false
+	for _, signature := range signatures {
+		this, parameters, res := substituteSignature(signature, substitution)
+		substituted := NewGenericSignature(signature.TypeParameters(), this, parameters, res)
+		changed = changed || !sameSignature(signature, substituted)
+		result = append(result, substituted)
+	}
+	return result, changed
+}
+
+func sameSignature(a, b Signature) bool {
+	if a.This() != b.This() || a.Result() != b.Result() {
+		return false
+	}
+	for index, parameter := range a.Parameters() {
+		if parameter.Type() != b.Parameters()[index].Type() {
+			return false
+		}
+	}
+	return true
+}
+
+func sameArguments(a, b []TypeSymbol) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for index, argument := range a {
+		other := b[index]
+		if argument != other && (argument.Type() == nil || argument.Type() != other.Type()) {
+			return false
+		}
+	}
+	return true
+}
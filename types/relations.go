@@ -0,0 +1,212 @@
+package types
+
+import (
+	"dyego0/symbols"
+)
+
+// Identical returns true if a and b are the same type. Arrays, references, optionals and
+// functions are identical if the types they are made of are identical. Named records are only
+// identical to themselves but anonymous records are identical if they have identical members
+// and signatures. An error type is identical to every type so an invalid type is only
+// reported once.
+func Identical(a, b TypeSymbol) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if IsError(a) || IsError(b) {
+		return true
+	}
+	at := a.Type()
+	bt := b.Type()
+	if at == nil || bt == nil {
+		return a == b
+	}
+	if at == bt {
+		return true
+	}
+	if at.Kind() != bt.Kind() {
+		return false
+	}
+	switch at.Kind() {
+	case Array:
+		return at.Size() == bt.Size() && Identical(at.Elements(), bt.Elements())
+	case Reference:
+		return Identical(at.Referant(), bt.Referant())
+	case Optional:
+		return Identical(at.Target(), bt.Target())
+	case Function:
+		return identicalSignatures(at.Signatures(), bt.Signatures())
+	case Record:
+		if at.Symbol().Name() != "" || bt.Symbol().Name() != "" {
+			return false
+		}
+		return identicalMembers(at.Members(), bt.Members()) && identicalSignatures(at.Signatures(), bt.Signatures())
+	}
+	return false
+}
+
+// IdenticalSignatures returns true if a and b have parameters and results of identical types
+func IdenticalSignatures(a, b Signature) bool {
+	ap := a.Parameters()
+	bp := b.Parameters()
+	if len(ap) != len(bp) || !Identical(a.Result(), b.Result()) {
+		return false
+	}
+	for i, parameter := range ap {
+		if !Identical(parameter.Type(), bp[i].Type()) {
+			return false
+		}
+	}
+	return true
+}
+
+// AssignableTo returns true if a value of type value can be used where a value of type target
+// is expected. Besides values of identical types,
+//
+//   - a value can be assigned to an optional of a type it can be assigned to,
+//   - a lambda can be assigned to a record with only callable members if one of them has the
+//     signature of the lambda,
+//   - an anonymous record, such as the type of the initializer [x: 1.0, y: 2.0], can be
+//     assigned to a record with the same fields, such as < x: Double, y: Double >, and
+//   - a record composed from another record, such as A & B or < ...A, b: Int >, can be
+//     assigned to it, so A & B can be assigned to A, to B and to < ...B, ...A >.
+func AssignableTo(value, target TypeSymbol) bool {
+	if Identical(value, target) {
+		return true
+	}
+	if value == nil || target == nil {
+		return false
+	}
+	vt, tt := value.Type(), target.Type()
+	if vt == nil || tt == nil {
+		return false
+	}
+	switch tt.Kind() {
+	case Optional:
+		if vt.Kind() == Optional {
+			return AssignableTo(vt.Target(), tt.Target())
+		}
+		return AssignableTo(value, tt.Target())
+	case Record:
+		switch vt.Kind() {
+		case Function:
+			return callable(tt, vt.Signatures()[0])
+		case Record:
+			anonymous := vt.Symbol().Name() == "" && len(vt.Signatures()) == 0 && sameFields(vt, tt)
+			return anonymous || composes(vt, tt)
+		}
+	}
+	return false
+}
+
+// ConvertibleTo returns true if a value of type value can be converted to target. A value can
+// be converted to a type it can be assigned to, an optional can be converted to a type its
+// target can be converted to, which fails if it is null, and records with the same fields can
+// be converted to each other.
+func ConvertibleTo(value, target TypeSymbol) bool {
+	if AssignableTo(value, target) {
+		return true
+	}
+	if value == nil || target == nil {
+		return false
+	}
+	vt, tt := value.Type(), target.Type()
+	if vt == nil || tt == nil {
+		return false
+	}
+	if vt.Kind() == Optional && tt.Kind() != Optional {
+		return ConvertibleTo(vt.Target(), target)
+	}
+	return vt.Kind() == Record && tt.Kind() == Record && sameFields(vt, tt)
+}
+
+func identicalMembers(a, b []Member) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, member := range a {
+		if member.Name() != b[i].Name() || !Identical(member.Type(), b[i].Type()) {
+			return false
+		}
+	}
+	return true
+}
+
+func identicalSignatures(a, b []Signature) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, signature := range a {
+		if !IdenticalSignatures(signature, b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// callable returns true if t has only callable members and one of them has the given signature
+func callable(t Type, signature Signature) bool {
+	if len(t.Members()) != 0 {
+		return false
+	}
+	for _, candidate := range t.Signatures() {
+		if IdenticalSignatures(candidate, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+// composes returns true if a has the members, type members and signatures of b, which it has if
+// it spreads b or the types b spreads. Records without fields, such as Int and Double, are never
+// composed.
+func composes(a, b Type) bool {
+	if len(b.Members()) == 0 {
+		return false
+	}
+	for _, member := range b.Members() {
+		sym, ok := a.MemberScope().Find(member.Name())
+		if !ok || sym != member {
+			return false
+		}
+	}
+	result := true
+	b.TypeScope().ForEach(func(sym symbols.Symbol) bool {
+		existing, ok := a.TypeScope().Find(sym.Name())
+		result = ok && existing == sym
+		return !result
+	})
+	if !result {
+		return false
+	}
+	for _, signature := range b.Signatures() {
+		found := false
+		for _, candidate := range a.Signatures() {
+			found = found || IdenticalSignatures(candidate, signature)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// sameFields returns true if a and b have fields with the same names and identical types, in
+// any order. Records without fields, such as Int and Double, are never the same.
+func sameFields(a, b Type) bool {
+	am := a.Members()
+	if len(am) == 0 || len(am) != len(b.Members()) {
+		return false
+	}
+	for _, member := range am {
+		sym, ok := b.MemberScope().Find(member.Name())
+		if !ok {
+			return false
+		}
+		other, ok := sym.(Member)
+		if !ok || !Identical(member.Type(), other.Type()) {
+			return false
+		}
+	}
+	return true
+}
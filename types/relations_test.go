@@ -0,0 +1,161 @@
+package types_test
+
+import (
+	"dyego0/types"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("relations", func() {
+	named := func(name string, members ...types.Member) types.TypeSymbol {
+		typeSym := types.NewTypeSymbol(name, nil)
+		types.NewType(typeSym, types.Record, members, nil, nil, nil, nil)
+		return typeSym
+	}
+	callable := func(signatures ...types.Signature) types.TypeSymbol {
+		typeSym := types.NewTypeSymbol("", nil)
+		types.NewType(typeSym, types.Record, nil, nil, nil, signatures, nil)
+		return typeSym
+	}
+	field := func(name string, typ types.TypeSymbol) types.Member {
+		return types.NewField(name, typ, false)
+	}
+	signature := func(result types.TypeSymbol, parameters ...types.TypeSymbol) types.Signature {
+		var params []types.Parameter
+		for _, parameter := range parameters {
+			params = append(params, types.NewParameter("p", parameter))
+		}
+		return types.NewSignature(nil, params, result)
+	}
+	intType := named("Int")
+	double := named("Double")
+	vector := named("Vector", field("x", double), field("y", double))
+	point := named("Point", field("x", double), field("y", double))
+	xy := named("", field("x", double), field("y", double))
+	yx := named("", field("y", double), field("x", double))
+	xyCopy := named("", field("x", double), field("y", double))
+	x := named("", field("x", double))
+	xInt := named("", field("x", intType), field("y", double))
+	xyz := named("", field("x", double), field("y", double), field("z", double))
+	unary := types.MakeFunction(signature(intType, intType))
+	unaryCopy := types.MakeFunction(signature(intType, intType))
+	binary := types.MakeFunction(signature(intType, intType, intType))
+	f := callable(signature(intType, intType), signature(double, double))
+	g := callable(signature(double, double))
+	aField := field("a", intType)
+	bField := field("b", double)
+	recordA := named("A", aField)
+	recordB := named("B", bField)
+	ab := named("", aField, bField)
+	ba := named("", bField, aField)
+	spreadsA := named("C", aField, field("c", intType))
+	abCopy := named("", field("a", intType), field("b", double))
+	variable := types.MakeTypeVariable("T", nil)
+	open := types.NewTypeSymbol("", nil)
+	sized := types.NewTypeSymbol("", nil)
+	types.NewArrayType(sized, intType, 10)
+	sizedCopy := types.NewTypeSymbol("", nil)
+	types.NewArrayType(sizedCopy, intType, 10)
+	unsized := types.NewTypeSymbol("", nil)
+	types.NewArrayType(unsized, intType, -1)
+
+	It("shares arrays, references and optionals", func() {
+		Expect(types.MakeArray(intType)).To(BeIdenticalTo(types.MakeArray(intType)))
+		Expect(types.MakeReference(intType)).To(BeIdenticalTo(types.MakeReference(intType)))
+		Expect(types.MakeOptional(intType)).To(BeIdenticalTo(types.MakeOptional(intType)))
+		Expect(types.MakeArray(types.MakeArray(intType))).To(BeIdenticalTo(types.MakeArray(types.MakeArray(intType))))
+		Expect(types.MakeArray(intType)).NotTo(BeIdenticalTo(types.MakeArray(double)))
+		Expect(types.MakeOptional(intType)).NotTo(BeIdenticalTo(types.MakeOptional(double)))
+	})
+
+	table.DescribeTable("Identical",
+		func(a, b types.TypeSymbol, expected bool) {
+			Expect(types.Identical(a, b)).To(Equal(expected))
+			Expect(types.Identical(b, a)).To(Equal(expected))
+		},
+		table.Entry("the same type", intType, intType, true),
+		table.Entry("different named types", intType, double, false),
+		table.Entry("named records with the same fields", vector, point, false),
+		table.Entry("a named and an anonymous record", vector, xy, false),
+		table.Entry("anonymous records with the same fields", xy, xyCopy, true),
+		table.Entry("anonymous records with fields in a different order", xy, yx, false),
+		table.Entry("records composed in a different order", ab, ba, false),
+		table.Entry("anonymous records with different field types", xy, xInt, false),
+		table.Entry("anonymous records with different fields", xy, x, false),
+		table.Entry("arrays", types.MakeArray(intType), types.MakeArray(intType), true),
+		table.Entry("arrays of different elements", types.MakeArray(intType), types.MakeArray(double), false),
+		table.Entry("arrays of the same size", sized, sizedCopy, true),
+		table.Entry("arrays of different sizes", sized, unsized, false),
+		table.Entry("an unsized array made two ways", unsized, types.MakeArray(intType), true),
+		table.Entry("references", types.MakeReference(xy), types.MakeReference(xyCopy), true),
+		table.Entry("references to different types", types.MakeReference(intType), types.MakeReference(double), false),
+		table.Entry("optionals", types.MakeOptional(xy), types.MakeOptional(xyCopy), true),
+		table.Entry("an optional and its target", types.MakeOptional(intType), intType, false),
+		table.Entry("functions with the same signature", unary, unaryCopy, true),
+		table.Entry("functions with different signatures", unary, binary, false),
+		table.Entry("a function and a callable record", unary, f, false),
+		table.Entry("an array and a reference", types.MakeArray(intType), types.MakeReference(intType), false),
+		table.Entry("an error type", types.NewErrorType(), intType, true),
+		table.Entry("a type variable", variable, variable, true),
+		table.Entry("a type variable and a type", variable, intType, false),
+		table.Entry("an open type", open, open, true),
+		table.Entry("an open type and a type", open, intType, false),
+		table.Entry("nil", nil, intType, false),
+	)
+
+	table.DescribeTable("AssignableTo",
+		func(value, target types.TypeSymbol, expected bool) {
+			Expect(types.AssignableTo(value, target)).To(Equal(expected))
+		},
+		table.Entry("the same type", intType, intType, true),
+		table.Entry("a different type", intType, double, false),
+		table.Entry("a value to an optional", intType, types.MakeOptional(intType), true),
+		table.Entry("an optional to its target", types.MakeOptional(intType), intType, false),
+		table.Entry("a value to an optional of a different type", intType, types.MakeOptional(double), false),
+		table.Entry("an anonymous record to a record", xy, vector, true),
+		table.Entry("an anonymous record with fields in a different order", yx, vector, true),
+		table.Entry("an anonymous record to an optional record", xy, types.MakeOptional(vector), true),
+		table.Entry("an optional anonymous record to an optional record",
+			types.MakeOptional(xy), types.MakeOptional(vector), true),
+		table.Entry("an anonymous record missing a field", x, vector, false),
+		table.Entry("an anonymous record with an extra field", xyz, vector, false),
+		table.Entry("an anonymous record with a field of a different type", xInt, vector, false),
+		table.Entry("a record to an anonymous record", vector, xy, false),
+		table.Entry("a record to a record with the same fields", vector, point, false),
+		table.Entry("a composed record to the first record it is composed from", ab, recordA, true),
+		table.Entry("a composed record to the second record it is composed from", ab, recordB, true),
+		table.Entry("records composed in a different order", ba, ab, true),
+		table.Entry("a record that spreads a record to it", spreadsA, recordA, true),
+		table.Entry("a record to a composed record", recordA, ab, false),
+		table.Entry("an anonymous record with the fields of a composed record", abCopy, recordA, false),
+		table.Entry("a function to a callable record", unary, f, true),
+		table.Entry("a function to a callable record with other signatures", unary, g, false),
+		table.Entry("a function to a callable record with more parameters", binary, f, false),
+		table.Entry("a function to a record with fields", unary, vector, false),
+		table.Entry("a callable record to a function", f, unary, false),
+		table.Entry("an array of anonymous records to an array of records",
+			types.MakeArray(xy), types.MakeArray(vector), false),
+		table.Entry("an error type", types.NewErrorType(), intType, true),
+		table.Entry("a type variable to a type", variable, intType, false),
+		table.Entry("an open type to a type", open, intType, false),
+		table.Entry("nil", nil, intType, false),
+	)
+
+	table.DescribeTable("ConvertibleTo",
+		func(value, target types.TypeSymbol, expected bool) {
+			Expect(types.ConvertibleTo(value, target)).To(Equal(expected))
+		},
+		table.Entry("an assignable type", intType, types.MakeOptional(intType), true),
+		table.Entry("an optional to its target", types.MakeOptional(intType), intType, true),
+		table.Entry("an optional to a different type", types.MakeOptional(intType), double, false),
+		table.Entry("an optional anonymous record to a record", types.MakeOptional(xy), vector, true),
+		table.Entry("a record to a record with the same fields", vector, point, true),
+		table.Entry("a record to an anonymous record", vector, yx, true),
+		table.Entry("a record to a record with different fields", vector, xyz, false),
+		table.Entry("a different type", intType, double, false),
+		table.Entry("a function to a record", unary, vector, false),
+		table.Entry("nil", intType, nil, false),
+	)
+})
@@ -21,9 +21,18 @@ const (
 	// Array is a linear block of memory of homomorphic type
 	Array
 
+	// Optional is a value of the target type or null
+	Optional
+
+	// Function is the type of a lambda
+	Function
+
 	// Module is a fixed block of memory similar to a record but in static memory
 	Module
 
+	// Variable is a type parameter of a generic type or lambda
+	Variable
+
 	// Error is the type of invalid expressions
 	Error
 )
@@ -67,6 +76,15 @@ type Type interface {
 	// Referant is the type a reference refers to
 	Referant() TypeSymbol
 
+	// Target is the type of the value of an Optional that is not null
+	Target() TypeSymbol
+
+	// Constraint is the type the type arguments of a type variable must conform to, if given
+	Constraint() TypeSymbol
+
+	// TypeParameters are the type variables of a generic type
+	TypeParameters() []TypeSymbol
+
 	// String returns the display name
 	String() string
 }
@@ -115,6 +133,9 @@ type Field interface {
 
 // Signature is a description of the call supported
 type Signature interface {
+	// TypeParameters are the type variables of a generic lambda
+	TypeParameters() []TypeSymbol
+
 	// This is the context the function is executed in
 	This() TypeSymbol
 
@@ -123,6 +144,9 @@ type Signature interface {
 
 	// Result is the type of the function result
 	Result() TypeSymbol
+
+	// String is the display form of the signature
+	String() string
 }
 
 // Parameter is a function parameter
@@ -153,6 +177,20 @@ func NewType(
 	typeScope symbols.Scope,
 	signatures []Signature,
 	container TypeSymbol,
+) Type {
+	return NewGenericType(symbol, kind, nil, members, memberScope, typeScope, signatures, container)
+}
+
+// NewGenericType creates a new type with the type parameters given
+func NewGenericType(
+	symbol TypeSymbol,
+	kind TypeKind,
+	typeParameters []TypeSymbol,
+	members []Member,
+	memberScope symbols.Scope,
+	typeScope symbols.Scope,
+	signatures []Signature,
+	container TypeSymbol,
 ) Type {
 	if members != nil && memberScope == nil {
 		b := symbols.NewBuilder()
@@ -168,13 +206,14 @@ func NewType(
 		typeScope = symbols.EmptyScope()
 	}
 	result := &typeImpl{
-		symbol:      symbol,
-		kind:        kind,
-		members:     members,
-		memberScope: memberScope,
-		typeScope:   typeScope,
-		signatures:  signatures,
-		container:   container,
+		symbol:         symbol,
+		kind:           kind,
+		typeParameters: typeParameters,
+		members:        members,
+		memberScope:    memberScope,
+		typeScope:      typeScope,
+		signatures:     signatures,
+		container:      container,
 	}
 	if symbol.Type() == nil {
 		UpdateTypeSymbol(symbol, result)
@@ -207,10 +246,18 @@ func NewArrayType(symbol TypeSymbol, elements TypeSymbol, size int) Type {
 	return result
 }
 
-// MakeArray creates a new array
+// MakeArray makes an array of elements. Arrays are shared so making an array of the same
+// element type returns the same symbol.
 func MakeArray(elements TypeSymbol) TypeSymbol {
+	derived := derivedOf(elements)
+	if derived != nil && derived.array != nil {
+		return derived.array
+	}
 	result := NewTypeSymbol(fmt.Sprintf("%s[]", elements.Name()), nil)
 	NewArrayType(result, elements, -1)
+	if derived != nil {
+		derived.array = result
+	}
 	return result
 }
 
@@ -226,13 +273,105 @@ func NewReferenceType(symbol TypeSymbol, referant TypeSymbol) Type {
 	return result
 }
 
-// MakeReference makes a reference type that references referant
+// MakeReference makes a reference type that references referant. References are shared like
+// arrays.
 func MakeReference(referant TypeSymbol) TypeSymbol {
+	derived := derivedOf(referant)
+	if derived != nil && derived.reference != nil {
+		return derived.reference
+	}
 	r := NewTypeSymbol("*"+referant.Name(), nil)
 	NewReferenceType(r, referant)
+	if derived != nil {
+		derived.reference = r
+	}
 	return r
 }
 
+// NewOptionalType creates a new optional type
+func NewOptionalType(symbol TypeSymbol, target TypeSymbol) Type {
+	result := &optionalType{
+		symbol: symbol,
+		target: target,
+	}
+	if symbol.Type() == nil {
+		UpdateTypeSymbol(symbol, result)
+	}
+	return result
+}
+
+// MakeOptional makes an optional type of target. An optional type of an optional type is the
+// optional type itself. Optional types are shared like arrays.
+func MakeOptional(target TypeSymbol) TypeSymbol {
+	if t := target.Type(); t != nil && t.Kind() == Optional {
+		return target
+	}
+	derived := derivedOf(target)
+	if derived != nil && derived.optional != nil {
+		return derived.optional
+	}
+	result := NewTypeSymbol(target.Name()+"?", nil)
+	NewOptionalType(result, target)
+	if derived != nil {
+		derived.optional = result
+	}
+	return result
+}
+
+// derivedOf is the symbol that records the array, reference and optional types made of
+// typeSym. This is the canonical symbol of typeSym once its type is known.
+func derivedOf(typeSym TypeSymbol) *typeSymbolImpl {
+	if typeSym.Type() != nil {
+		typeSym = typeSym.Canonical()
+	}
+	result, _ := typeSym.(*typeSymbolImpl)
+	return result
+}
+
+// IsOptional returns true if typeSym is an optional type
+func IsOptional(typeSym TypeSymbol) bool {
+	t := typeSym.Type()
+	return t != nil && t.Kind() == Optional
+}
+
+// NewFunctionType creates a new function type with the given signature
+func NewFunctionType(symbol TypeSymbol, signature Signature) Type {
+	result := &functionType{
+		symbol:    symbol,
+		signature: signature,
+	}
+	if symbol.Type() == nil {
+		UpdateTypeSymbol(symbol, result)
+	}
+	return result
+}
+
+// MakeFunction makes the type of a lambda with the given signature
+func MakeFunction(signature Signature) TypeSymbol {
+	result := NewTypeSymbol("", nil)
+	NewFunctionType(result, signature)
+	return result
+}
+
+// NewTypeVariable creates a new type variable constrained to constraint, which can be nil
+func NewTypeVariable(symbol TypeSymbol, constraint TypeSymbol) Type {
+	result := &variableType{
+		symbol:     symbol,
+		constraint: constraint,
+	}
+	if symbol.Type() == nil {
+		UpdateTypeSymbol(symbol, result)
+	}
+	return result
+}
+
+// MakeTypeVariable makes a type variable with the given name and constraint
+func MakeTypeVariable(name string, constraint TypeSymbol) TypeSymbol {
+	result := NewTypeSymbol(name, nil)
+	NewTypeVariable(result, constraint)
+	return result
+}
+
 // NewField creates a new field symbol
 func NewField(name string, typ TypeSymbol, mutable bool) Field {
 	return &fieldImpl{memberImpl: memberImpl{name: name, typ: typ}, mutable: mutable}
@@ -240,7 +379,17 @@ func NewField(name string, typ TypeSymbol, mutable bool) Field {
 
 // NewSignature creates a new signature
 func NewSignature(this TypeSymbol, parameters []Parameter, result TypeSymbol) Signature {
-	return &signatureImpl{this: this, parameters: parameters, result: result}
+	return NewGenericSignature(nil, this, parameters, result)
+}
+
+// NewGenericSignature creates a new signature with the type parameters given
+func NewGenericSignature(
+	typeParameters []TypeSymbol,
+	this TypeSymbol,
+	parameters []Parameter,
+	result TypeSymbol,
+) Signature {
+	return &signatureImpl{typeParameters: typeParameters, this: this, parameters: parameters, result: result}
 }
 
 // NewParameter creates a new parameter symbol
@@ -267,13 +416,14 @@ func IsError(typeSym TypeSymbol) bool {
 }
 
 type typeImpl struct {
-	symbol      TypeSymbol
-	kind        TypeKind
-	members     []Member
-	memberScope symbols.Scope
-	typeScope   symbols.Scope
-	signatures  []Signature
-	container   TypeSymbol
+	symbol         TypeSymbol
+	kind           TypeKind
+	typeParameters []TypeSymbol
+	members        []Member
+	memberScope    symbols.Scope
+	typeScope      symbols.Scope
+	signatures     []Signature
+	container      TypeSymbol
 }
 
 func (t *typeImpl) Symbol() TypeSymbol {
@@ -357,6 +507,18 @@ func (t *typeImpl) Referant() TypeSymbol {
 	return nil
 }
 
+func (t *typeImpl) Target() TypeSymbol {
+	return nil
+}
+
+func (t *typeImpl) Constraint() TypeSymbol {
+	return nil
+}
+
+func (t *typeImpl) TypeParameters() []TypeSymbol {
+	return t.typeParameters
+}
+
 func (t *typeImpl) String() string {
 	return t.DisplayName()
 }
@@ -364,6 +526,18 @@ func (t *typeImpl) String() string {
 type typeSymbolImpl struct {
 	name string
 	typ  Type
+
+	// The generic type and type arguments of an instance of a generic type
+	generic   TypeSymbol
+	arguments []TypeSymbol
+
+	// The instances of a generic type
+	instances []*typeSymbolImpl
+
+	// The array, reference and optional types of this type
+	array     TypeSymbol
+	reference TypeSymbol
+	optional  TypeSymbol
 }
 
 func (s *typeSymbolImpl) Name() string {
@@ -447,6 +621,18 @@ func (a *arrayType) Referant() TypeSymbol {
 	return nil
 }
 
+func (a *arrayType) Target() TypeSymbol {
+	return nil
+}
+
+func (a *arrayType) Constraint() TypeSymbol {
+	return nil
+}
+
+func (a *arrayType) TypeParameters() []TypeSymbol {
+	return nil
+}
+
 func (a *arrayType) String() string {
 	return a.DisplayName()
 }
@@ -504,10 +690,239 @@ func (r *referenceType) Referant() TypeSymbol {
 	return r.referant
 }
 
+func (r *referenceType) Target() TypeSymbol {
+	return nil
+}
+
+func (r *referenceType) Constraint() TypeSymbol {
+	return nil
+}
+
+func (r *referenceType) TypeParameters() []TypeSymbol {
+	return nil
+}
+
 func (r *referenceType) String() string {
 	return r.DisplayName()
 }
 
+type optionalType struct {
+	symbol TypeSymbol
+	target TypeSymbol
+}
+
+func (o *optionalType) Symbol() TypeSymbol {
+	return o.symbol
+}
+
+func (o *optionalType) Kind() TypeKind {
+	return Optional
+}
+
+func (o *optionalType) DisplayName() string {
+	return o.target.String() + "?"
+}
+
+func (o *optionalType) Members() []Member {
+	return nil
+}
+
+func (o *optionalType) MemberScope() symbols.Scope {
+	return symbols.EmptyScope()
+}
+
+func (o *optionalType) TypeScope() symbols.Scope {
+	return symbols.EmptyScope()
+}
+
+func (o *optionalType) Signatures() []Signature {
+	return nil
+}
+
+func (o *optionalType) Container() TypeSymbol {
+	return nil
+}
+
+func (o *optionalType) Elements() TypeSymbol {
+	return nil
+}
+
+func (o *optionalType) Size() int {
+	return 0
+}
+
+func (o *optionalType) Referant() TypeSymbol {
+	return nil
+}
+
+func (o *optionalType) Target() TypeSymbol {
+	return o.target
+}
+
+func (o *optionalType) Constraint() TypeSymbol {
+	return nil
+}
+
+func (o *optionalType) TypeParameters() []TypeSymbol {
+	return nil
+}
+
+func (o *optionalType) String() string {
+	return o.DisplayName()
+}
+
+type functionType struct {
+	symbol    TypeSymbol
+	signature Signature
+}
+
+func (f *functionType) Symbol() TypeSymbol {
+	return f.symbol
+}
+
+func (f *functionType) Kind() TypeKind {
+	return Function
+}
+
+func (f *functionType) DisplayName() string {
+	return f.signature.String()
+}
+
+func (f *functionType) Members() []Member {
+	return nil
+}
+
+func (f *functionType) MemberScope() symbols.Scope {
+	return symbols.EmptyScope()
+}
+
+func (f *functionType) TypeScope() symbols.Scope {
+	return symbols.EmptyScope()
+}
+
+func (f *functionType) Signatures() []Signature {
+	return []Signature{f.signature}
+}
+
+func (f *functionType) Container() TypeSymbol {
+	return nil
+}
+
+func (f *functionType) Elements() TypeSymbol {
+	return nil
+}
+
+func (f *functionType) Size() int {
+	return 0
+}
+
+func (f *functionType) Referant() TypeSymbol {
+	return nil
+}
+
+func (f *functionType) Target() TypeSymbol {
+	return nil
+}
+
+func (f *functionType) Constraint() TypeSymbol {
+	return nil
+}
+
+func (f *functionType) TypeParameters() []TypeSymbol {
+	return nil
+}
+
+func (f *functionType) String() string {
+	return f.DisplayName()
+}
+
+// variableType is a type variable. The members of a type variable are the members of its
+// constraint.
+type variableType struct {
+	symbol     TypeSymbol
+	constraint TypeSymbol
+}
+
+func (v *variableType) Symbol() TypeSymbol {
+	return v.symbol
+}
+
+func (v *variableType) Kind() TypeKind {
+	return Variable
+}
+
+func (v *variableType) DisplayName() string {
+	return v.symbol.Name()
+}
+
+// constraintType is the type of the constraint, if it is given and built
+func (v *variableType) constraintType() Type {
+	if v.constraint == nil {
+		return nil
+	}
+	return v.constraint.Type()
+}
+
+func (v *variableType) Members() []Member {
+	if t := v.constraintType(); t != nil {
+		return t.Members()
+	}
+	return nil
+}
+
+func (v *variableType) MemberScope() symbols.Scope {
+	if t := v.constraintType(); t != nil {
+		return t.MemberScope()
+	}
+	return symbols.EmptyScope()
+}
+
+func (v *variableType) TypeScope() symbols.Scope {
+	if t := v.constraintType(); t != nil {
+		return t.TypeScope()
+	}
+	return symbols.EmptyScope()
+}
+
+func (v *variableType) Signatures() []Signature {
+	if t := v.constraintType(); t != nil {
+		return t.Signatures()
+	}
+	return nil
+}
+
+func (v *variableType) Container() TypeSymbol {
+	return nil
+}
+
+func (v *variableType) Elements() TypeSymbol {
+	return nil
+}
+
+func (v *variableType) Size() int {
+	return 0
+}
+
+func (v *variableType) Referant() TypeSymbol {
+	return nil
+}
+
+func (v *variableType) Target() TypeSymbol {
+	return nil
+}
+
+func (v *variableType) Constraint() TypeSymbol {
+	return v.constraint
+}
+
+func (v *variableType) TypeParameters() []TypeSymbol {
+	return nil
+}
+
+func (v *variableType) String() string {
+	return v.DisplayName()
+}
+
 type memberImpl struct {
 	name string
 	typ  TypeSymbol
@@ -539,9 +954,14 @@ func (f *fieldImpl) IsField() bool {
 }
 
 type signatureImpl struct {
-	this       TypeSymbol
-	parameters []Parameter
-	result     TypeSymbol
+	typeParameters []TypeSymbol
+	this           TypeSymbol
+	parameters     []Parameter
+	result         TypeSymbol
+}
+
+func (s *signatureImpl) TypeParameters() []TypeSymbol {
+	return s.typeParameters
 }
 
 func (s *signatureImpl) This() TypeSymbol {
@@ -556,12 +976,22 @@ func (s *signatureImpl) Result() TypeSymbol {
 	return s.result
 }
 
+// String prints the signature as the type of a lambda, such as {X | a: X -> X}, prefixed by the
+// type the lambda is a member of, if any
 func (s *signatureImpl) String() string {
 	builder := &stringBuilder{}
+	if s.this != nil {
+		builder.Convert(s.this)
+		builder.Add(".")
+	}
 	builder.List("{", "}", func() {
-		if s.this != nil {
-			builder.Convert(s.this)
-			builder.Add(".")
+		if len(s.typeParameters) > 0 {
+			var names []string
+			for _, typeParameter := range s.typeParameters {
+				names = append(names, typeParameter.Name())
+			}
+			builder.Add(strings.Join(names, ", "))
+			builder.Add(" | ")
 		}
 		for _, parameter := range s.parameters {
 			builder.Item(func() {
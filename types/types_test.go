@@ -1,6 +1,7 @@
 package types_test
 
 import (
+	"fmt"
 	"testing"
 
 	"dyego0/types"
@@ -42,6 +43,57 @@ var _ = Describe("types", func() {
 		Expect(s.Parameters()).To(BeNil())
 		Expect(s.Result()).To(BeNil())
 	})
+	It("should be able to create an optional type", func() {
+		s := types.NewTypeSymbol("A", nil)
+		types.NewType(s, types.Record, nil, nil, nil, nil, nil)
+		o := types.MakeOptional(s)
+		Expect(o.Type().Kind()).To(Equal(types.Optional))
+		Expect(o.Type().Target()).To(Equal(s))
+		Expect(o.String()).To(Equal("A?"))
+		Expect(types.IsOptional(o)).To(BeTrue())
+		Expect(types.IsOptional(s)).To(BeFalse())
+		Expect(types.MakeOptional(o)).To(Equal(o))
+	})
+	It("should be able to create a function type", func() {
+		a := types.NewTypeSymbol("A", nil)
+		types.NewType(a, types.Record, nil, nil, nil, nil, nil)
+		f := types.MakeFunction(types.NewSignature(nil, []types.Parameter{types.NewParameter("a", a)}, a))
+		Expect(f.Type().Kind()).To(Equal(types.Function))
+		Expect(f.Type().Signatures()).To(HaveLen(1))
+		Expect(f.String()).To(Equal("{a: A -> A}"))
+		m := types.MakeFunction(types.NewSignature(a, nil, a))
+		Expect(m.String()).To(Equal("A.{ -> A}"))
+	})
+	It("should be able to create a type variable", func() {
+		c := types.NewTypeSymbol("C", nil)
+		types.NewType(c, types.Record, []types.Member{types.NewField("a", nil, false)}, nil, nil, nil, nil)
+		x := types.MakeTypeVariable("X", c)
+		Expect(x.Type().Kind()).To(Equal(types.Variable))
+		Expect(x.Type().Constraint()).To(Equal(c))
+		Expect(x.Type().Members()).To(Equal(c.Type().Members()))
+	})
+	It("should be able to create a generic signature", func() {
+		x := types.MakeTypeVariable("X", nil)
+		s := types.NewGenericSignature([]types.TypeSymbol{x}, nil, []types.Parameter{types.NewParameter("x", x)}, x)
+		Expect(s.TypeParameters()).To(Equal([]types.TypeSymbol{x}))
+		Expect(fmt.Sprint(s)).To(Equal("{X | x: X -> X}"))
+	})
+	It("should be able to instantiate a generic type", func() {
+		intSym := types.NewTypeSymbol("Int", nil)
+		types.NewType(intSym, types.Record, nil, nil, nil, nil, nil)
+		x := types.MakeTypeVariable("X", nil)
+		box := types.NewTypeSymbol("Box", nil)
+		types.NewGenericType(box, types.Record, []types.TypeSymbol{x},
+			[]types.Member{types.NewField("value", x, false)}, nil, nil, nil, nil)
+		instance := types.Instantiate(box, []types.TypeSymbol{intSym})
+		Expect(instance.Name()).To(Equal("Box<Int>"))
+		Expect(types.Instantiate(box, []types.TypeSymbol{intSym})).To(BeIdenticalTo(instance))
+		Expect(instance.Type().Members()[0].Type()).To(Equal(intSym))
+		generic, arguments := types.GenericOf(instance)
+		Expect(generic).To(Equal(box))
+		Expect(arguments).To(Equal([]types.TypeSymbol{intSym}))
+		Expect(types.Substitute(x, map[types.TypeSymbol]types.TypeSymbol{x: intSym})).To(Equal(intSym))
+	})
 	It("should be able to create a parameter", func() {
 		p := types.NewParameter("a", nil)
 		Expect(p.Name()).To(Equal("a"))
@@ -66,7 +118,7 @@ var _ = Describe("types", func() {
 			[]types.Signature{types.NewSignature(a, []types.Parameter{types.NewParameter("a", a)}, a)},
 			nil,
 		)
-		Expect(t.DisplayName()).To(Equal("<a: A, {A.a: A -> A}>"))
+		Expect(t.DisplayName()).To(Equal("<a: A, A.{a: A -> A}>"))
 	})
 	It("can produce a display name for a contained type", func() {
 		c := types.NewTypeSymbol("C", nil)
@@ -0,0 +1,417 @@
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Instruction is a decoded instruction
+type Instruction struct {
+	Opcode byte
+
+	// Block is the block type of block, loop and if
+	Block byte
+
+	// Index is the label, function, local or global index or the alignment of memory
+	// instructions
+	Index uint32
+
+	// Offset is the offset of memory instructions
+	Offset uint32
+
+	// Labels are the labels of br_table; the default label is in Index
+	Labels []uint32
+
+	// Value is the int32, int64, float32 or float64 value of a constant
+	Value interface{}
+}
+
+// Name is the text format name of the instruction
+func (i Instruction) Name() string {
+	if op, ok := opcodes[i.Opcode]; ok {
+		return op.name
+	}
+	return fmt.Sprintf("<opcode 0x%02X>", i.Opcode)
+}
+
+func (i Instruction) String() string {
+	op, ok := opcodes[i.Opcode]
+	if !ok {
+		return i.Name()
+	}
+	switch op.immediate {
+	case blockImmediate:
+		if i.Block == emptyBlock {
+			return op.name
+		}
+		return fmt.Sprintf("%s %s", op.name, ValueType(i.Block))
+	case indexImmediate:
+		return fmt.Sprintf("%s %d", op.name, i.Index)
+	case brTableImmediate:
+		var labels []string
+		for _, label := range i.Labels {
+			labels = append(labels, fmt.Sprint(label))
+		}
+		labels = append(labels, fmt.Sprint(i.Index))
+		return fmt.Sprintf("%s %s", op.name, strings.Join(labels, " "))
+	case callIndirectImmediate:
+		return fmt.Sprintf("%s %d", op.name, i.Index)
+	case memoryImmediate:
+		if i.Offset == 0 {
+			return op.name
+		}
+		return fmt.Sprintf("%s offset=%d", op.name, i.Offset)
+	case i32Immediate, i64Immediate, f32Immediate, f64Immediate:
+		return fmt.Sprintf("%s %v", op.name, i.Value)
+	}
+	return op.name
+}
+
+// maxLocals is the maximum number of locals a function can declare
+const maxLocals = 50000
+
+// decoder reads the encodings of the binary format
+type decoder struct {
+	data   []byte
+	offset int
+	base   int
+}
+
+// decodeError is a panic used to unwind the decoder
+type decodeError struct {
+	err error
+}
+
+func (d *decoder) fail(message string, args ...interface{}) {
+	panic(&decodeError{fmt.Errorf("wasm: %s at offset 0x%X", fmt.Sprintf(message, args...), d.base+d.offset)})
+}
+
+func (d *decoder) recover(err *error) {
+	if r := recover(); r != nil {
+		e, ok := r.(*decodeError)
+		if !ok {
+			panic(r)
+		}
+		*err = e.err
+	}
+}
+
+func (d *decoder) done() bool {
+	return d.offset >= len(d.data)
+}
+
+func (d *decoder) byte() byte {
+	if d.offset >= len(d.data) {
+		d.fail("Unexpected end of data")
+	}
+	b := d.data[d.offset]
+	d.offset++
+	return b
+}
+
+func (d *decoder) bytes(n uint32) []byte {
+	if uint64(d.offset)+uint64(n) > uint64(len(d.data)) {
+		d.fail("Unexpected end of data")
+	}
+	result := d.data[d.offset : d.offset+int(n)]
+	d.offset += int(n)
+	return result
+}
+
+func (d *decoder) u32() uint32 {
+	var result uint64
+	for shift := uint(0); ; shift += 7 {
+		if shift >= 35 {
+			d.fail("Integer too large")
+		}
+		b := d.byte()
+		result |= uint64(b&0x7F) << shift
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if result > math.MaxUint32 {
+		d.fail("Integer too large")
+	}
+	return uint32(result)
+}
+
+func (d *decoder) signed(bits uint) int64 {
+	var result int64
+	var shift uint
+	var b byte
+	for {
+		if shift >= bits+7 {
+			d.fail("Integer too large")
+		}
+		b = d.byte()
+		result |= int64(b&0x7F) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if shift < 64 && b&0x40 != 0 {
+		result |= -1 << shift
+	}
+	return result
+}
+
+func (d *decoder) i32() int32 {
+	value := d.signed(32)
+	if value < math.MinInt32 || value > math.MaxInt32 {
+		d.fail("Integer too large")
+	}
+	return int32(value)
+}
+
+func (d *decoder) i64() int64 {
+	return d.signed(64)
+}
+
+func (d *decoder) f32() float32 {
+	data := d.bytes(4)
+	var bits uint32
+	for i := uint(0); i < 4; i++ {
+		bits |= uint32(data[i]) << (i * 8)
+	}
+	return math.Float32frombits(bits)
+}
+
+func (d *decoder) f64() float64 {
+	data := d.bytes(8)
+	var bits uint64
+	for i := uint(0); i < 8; i++ {
+		bits |= uint64(data[i]) << (i * 8)
+	}
+	return math.Float64frombits(bits)
+}
+
+func (d *decoder) name() string {
+	return string(d.bytes(d.u32()))
+}
+
+func (d *decoder) valueType() ValueType {
+	b := d.byte()
+	if !validValueType(b) {
+		d.fail("Invalid value type 0x%02X", b)
+	}
+	return ValueType(b)
+}
+
+func (d *decoder) valueTypes() []ValueType {
+	count := d.u32()
+	var result []ValueType
+	for i := uint32(0); i < count; i++ {
+		result = append(result, d.valueType())
+	}
+	return result
+}
+
+// sub returns a decoder for the next n bytes
+func (d *decoder) sub(n uint32) *decoder {
+	start := d.offset
+	data := d.bytes(n)
+	return &decoder{data: data, base: d.base + start}
+}
+
+// Decode decodes a module in the WebAssembly binary format
+func Decode(data []byte) (module *Module, err error) {
+	d := &decoder{data: data}
+	defer d.recover(&err)
+	if !bytes.Equal(d.bytes(4), magic) {
+		d.fail("Invalid magic number")
+	}
+	if !bytes.Equal(d.bytes(4), version) {
+		d.fail("Unsupported version")
+	}
+	m := &Module{}
+	var functionTypes []uint32
+	var lastSection byte
+	codeCount := -1
+	for !d.done() {
+		id := d.byte()
+		if id != customSection {
+			if id <= lastSection {
+				d.offset--
+				d.fail("Section %d out of order", id)
+			}
+			lastSection = id
+		}
+		section := d.sub(d.u32())
+		switch id {
+		case customSection:
+			section.name()
+			continue
+		case typeSection:
+			section.vector(func() {
+				if section.byte() != funcTypeForm {
+					section.fail("Expected a function type")
+				}
+				params := section.valueTypes()
+				results := section.valueTypes()
+				m.Types = append(m.Types, FuncType{Params: params, Results: results})
+			})
+		case functionSection:
+			section.vector(func() {
+				functionTypes = append(functionTypes, section.u32())
+			})
+		case memorySection:
+			section.vector(func() {
+				var memory Memory
+				switch section.byte() {
+				case 0x00:
+					memory.Min = section.u32()
+				case 0x01:
+					memory.Min = section.u32()
+					memory.Max = section.u32()
+					memory.HasMax = true
+				default:
+					section.fail("Invalid limits")
+				}
+				m.Memories = append(m.Memories, memory)
+			})
+		case globalSection:
+			section.vector(func() {
+				var global Global
+				global.Type = section.valueType()
+				switch section.byte() {
+				case 0x00:
+				case 0x01:
+					global.Mutable = true
+				default:
+					section.fail("Invalid mutability")
+				}
+				global.Init = section.expression()
+				m.Globals = append(m.Globals, global)
+			})
+		case exportSection:
+			section.vector(func() {
+				var export Export
+				export.Name = section.name()
+				export.Kind = ExportKind(section.byte())
+				if export.Kind > GlobalExport {
+					section.fail("Invalid export kind")
+				}
+				export.Index = section.u32()
+				m.Exports = append(m.Exports, export)
+			})
+		case codeSection:
+			codeCount = 0
+			section.vector(func() {
+				if codeCount >= len(functionTypes) {
+					section.fail("More function bodies than functions")
+				}
+				code := section.sub(section.u32())
+				var locals []ValueType
+				code.vector(func() {
+					count := code.u32()
+					t := code.valueType()
+					if uint64(len(locals))+uint64(count) > maxLocals {
+						code.fail("Too many locals")
+					}
+					for i := uint32(0); i < count; i++ {
+						locals = append(locals, t)
+					}
+				})
+				body := code.data[code.offset:]
+				m.Functions = append(m.Functions, Function{Type: functionTypes[codeCount], Locals: locals, Body: body})
+				codeCount++
+			})
+		default:
+			d.fail("Unsupported section %d", id)
+		}
+		if !section.done() {
+			section.fail("Unexpected data at the end of the section")
+		}
+	}
+	if len(functionTypes) > 0 && codeCount != len(functionTypes) {
+		d.fail("Expected %d function bodies", len(functionTypes))
+	}
+	return m, nil
+}
+
+func (d *decoder) vector(item func()) {
+	count := d.u32()
+	for i := uint32(0); i < count; i++ {
+		item()
+	}
+}
+
+// expression reads a constant expression up to and including its end
+func (d *decoder) expression() []byte {
+	start := d.offset
+	for {
+		instruction := d.instruction()
+		if instruction.Opcode == opEnd {
+			return d.data[start:d.offset]
+		}
+	}
+}
+
+// instruction decodes a single instruction
+func (d *decoder) instruction() Instruction {
+	code := d.byte()
+	op, ok := opcodes[code]
+	if !ok {
+		d.offset--
+		d.fail("Unknown opcode 0x%02X", code)
+	}
+	result := Instruction{Opcode: code}
+	switch op.immediate {
+	case blockImmediate:
+		result.Block = d.byte()
+		if result.Block != emptyBlock && !validValueType(result.Block) {
+			d.fail("Invalid block type 0x%02X", result.Block)
+		}
+	case indexImmediate:
+		result.Index = d.u32()
+	case brTableImmediate:
+		d.vector(func() {
+			result.Labels = append(result.Labels, d.u32())
+		})
+		result.Index = d.u32()
+	case callIndirectImmediate:
+		result.Index = d.u32()
+		if d.byte() != 0 {
+			d.fail("Expected a zero byte")
+		}
+	case memoryImmediate:
+		result.Index = d.u32()
+		result.Offset = d.u32()
+	case reservedImmediate:
+		if d.byte() != 0 {
+			d.fail("Expected a zero byte")
+		}
+	case i32Immediate:
+		result.Value = d.i32()
+	case i64Immediate:
+		result.Value = d.i64()
+	case f32Immediate:
+		result.Value = d.f32()
+	case f64Immediate:
+		result.Value = d.f64()
+	}
+	return result
+}
+
+// DecodeInstructions decodes the instructions of a function body or constant expression
+func DecodeInstructions(code []byte) (instructions []Instruction, err error) {
+	d := &decoder{data: code}
+	defer d.recover(&err)
+	for !d.done() {
+		instructions = append(instructions, d.instruction())
+	}
+	return instructions, nil
+}
+
+// DecodeInstruction decodes the instruction at the start of code and returns it with the
+// number of bytes it is encoded in
+func DecodeInstruction(code []byte) (instruction Instruction, length int, err error) {
+	d := &decoder{data: code}
+	defer d.recover(&err)
+	instruction = d.instruction()
+	return instruction, d.offset, nil
+}
@@ -0,0 +1,201 @@
+package wasm
+
+import (
+	"math"
+)
+
+// magic is the first four bytes of a WebAssembly binary, "\0asm"
+var magic = []byte{0x00, 0x61, 0x73, 0x6D}
+
+// version is the version of the binary format
+var version = []byte{0x01, 0x00, 0x00, 0x00}
+
+// Section ids of the sections encoded in a module
+const (
+	customSection   byte = 0
+	typeSection     byte = 1
+	functionSection byte = 3
+	memorySection   byte = 5
+	globalSection   byte = 6
+	exportSection   byte = 7
+	codeSection     byte = 10
+)
+
+// funcTypeForm introduces a function type in the type section
+const funcTypeForm byte = 0x60
+
+// Encoder accumulates bytes in the encodings used by the binary format
+type Encoder struct {
+	data []byte
+}
+
+// Byte appends a single byte
+func (e *Encoder) Byte(b byte) {
+	e.data = append(e.data, b)
+}
+
+// Bytes appends bytes unchanged
+func (e *Encoder) Bytes(b []byte) {
+	e.data = append(e.data, b...)
+}
+
+// U32 appends an unsigned LEB128 encoded integer
+func (e *Encoder) U32(value uint32) {
+	for {
+		b := byte(value & 0x7F)
+		value >>= 7
+		if value == 0 {
+			e.data = append(e.data, b)
+			return
+		}
+		e.data = append(e.data, b|0x80)
+	}
+}
+
+// I32 appends a signed LEB128 encoded 32-bit integer
+func (e *Encoder) I32(value int32) {
+	e.I64(int64(value))
+}
+
+// I64 appends a signed LEB128 encoded 64-bit integer
+func (e *Encoder) I64(value int64) {
+	for {
+		b := byte(value & 0x7F)
+		value >>= 7
+		if (value == 0 && b&0x40 == 0) || (value == -1 && b&0x40 != 0) {
+			e.data = append(e.data, b)
+			return
+		}
+		e.data = append(e.data, b|0x80)
+	}
+}
+
+// F32 appends a little-endian IEEE 754 32-bit float
+func (e *Encoder) F32(value float32) {
+	bits := math.Float32bits(value)
+	for i := uint(0); i < 4; i++ {
+		e.data = append(e.data, byte(bits>>(i*8)))
+	}
+}
+
+// F64 appends a little-endian IEEE 754 64-bit float
+func (e *Encoder) F64(value float64) {
+	bits := math.Float64bits(value)
+	for i := uint(0); i < 8; i++ {
+		e.data = append(e.data, byte(bits>>(i*8)))
+	}
+}
+
+// Name appends a length prefixed UTF-8 string
+func (e *Encoder) Name(name string) {
+	e.U32(uint32(len(name)))
+	e.data = append(e.data, name...)
+}
+
+// Len is the number of bytes appended so far
+func (e *Encoder) Len() int {
+	return len(e.data)
+}
+
+// Result returns the bytes appended
+func (e *Encoder) Result() []byte {
+	return e.data
+}
+
+func (e *Encoder) valueTypes(types []ValueType) {
+	e.U32(uint32(len(types)))
+	for _, t := range types {
+		e.Byte(byte(t))
+	}
+}
+
+func (e *Encoder) section(id byte, count int, content func(e *Encoder)) {
+	if count == 0 {
+		return
+	}
+	body := &Encoder{}
+	body.U32(uint32(count))
+	content(body)
+	e.Byte(id)
+	e.U32(uint32(body.Len()))
+	e.Bytes(body.Result())
+}
+
+// Encode encodes the module in the WebAssembly binary format
+func (m *Module) Encode() []byte {
+	e := &Encoder{}
+	e.Bytes(magic)
+	e.Bytes(version)
+	e.section(typeSection, len(m.Types), func(e *Encoder) {
+		for _, t := range m.Types {
+			e.Byte(funcTypeForm)
+			e.valueTypes(t.Params)
+			e.valueTypes(t.Results)
+		}
+	})
+	e.section(functionSection, len(m.Functions), func(e *Encoder) {
+		for _, f := range m.Functions {
+			e.U32(f.Type)
+		}
+	})
+	e.section(memorySection, len(m.Memories), func(e *Encoder) {
+		for _, memory := range m.Memories {
+			if memory.HasMax {
+				e.Byte(0x01)
+				e.U32(memory.Min)
+				e.U32(memory.Max)
+			} else {
+				e.Byte(0x00)
+				e.U32(memory.Min)
+			}
+		}
+	})
+	e.section(globalSection, len(m.Globals), func(e *Encoder) {
+		for _, global := range m.Globals {
+			e.Byte(byte(global.Type))
+			if global.Mutable {
+				e.Byte(0x01)
+			} else {
+				e.Byte(0x00)
+			}
+			e.Bytes(global.Init)
+		}
+	})
+	e.section(exportSection, len(m.Exports), func(e *Encoder) {
+		for _, export := range m.Exports {
+			e.Name(export.Name)
+			e.Byte(byte(export.Kind))
+			e.U32(export.Index)
+		}
+	})
+	e.section(codeSection, len(m.Functions), func(e *Encoder) {
+		for _, f := range m.Functions {
+			code := &Encoder{}
+			encodeLocals(code, f.Locals)
+			code.Bytes(f.Body)
+			e.U32(uint32(code.Len()))
+			e.Bytes(code.Result())
+		}
+	})
+	return e.Result()
+}
+
+// encodeLocals encodes the locals as runs of the same type
+func encodeLocals(e *Encoder, locals []ValueType) {
+	var counts []uint32
+	var types []ValueType
+	for _, local := range locals {
+		last := len(types) - 1
+		if last >= 0 && types[last] == local {
+			counts[last]++
+			continue
+		}
+		counts = append(counts, 1)
+		types = append(types, local)
+	}
+	e.U32(uint32(len(types)))
+	for i, t := range types {
+		e.U32(counts[i])
+		e.Byte(byte(t))
+	}
+}
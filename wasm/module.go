@@ -0,0 +1,163 @@
+package wasm
+
+import "fmt"
+
+// ValueType is the type of a WebAssembly value
+type ValueType byte
+
+const (
+	// I32 is a 32-bit integer
+	I32 ValueType = 0x7F
+
+	// I64 is a 64-bit integer
+	I64 ValueType = 0x7E
+
+	// F32 is a 32-bit floating point number
+	F32 ValueType = 0x7D
+
+	// F64 is a 64-bit floating point number
+	F64 ValueType = 0x7C
+)
+
+func (v ValueType) String() string {
+	switch v {
+	case I32:
+		return "i32"
+	case I64:
+		return "i64"
+	case F32:
+		return "f32"
+	case F64:
+		return "f64"
+	}
+	return fmt.Sprintf("<valtype 0x%02X>", byte(v))
+}
+
+func validValueType(b byte) bool {
+	switch ValueType(b) {
+	case I32, I64, F32, F64:
+		return true
+	}
+	return false
+}
+
+// FuncType is the signature of a function
+type FuncType struct {
+	Params  []ValueType
+	Results []ValueType
+}
+
+// Equal returns true if t and other have the same parameters and results
+func (t FuncType) Equal(other FuncType) bool {
+	return sameValueTypes(t.Params, other.Params) && sameValueTypes(t.Results, other.Results)
+}
+
+func (t FuncType) String() string {
+	return fmt.Sprintf("%v -> %v", t.Params, t.Results)
+}
+
+func sameValueTypes(a, b []ValueType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, v := range a {
+		if b[i] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Function is a function defined by the module
+type Function struct {
+	// Type is the index of the function's type in the module's types
+	Type uint32
+
+	// Locals are the types of the locals declared in addition to the parameters
+	Locals []ValueType
+
+	// Body is the encoded instructions of the function including the final end
+	Body []byte
+}
+
+// Memory is a linear memory whose size is given in 64KiB pages
+type Memory struct {
+	Min    uint32
+	Max    uint32
+	HasMax bool
+}
+
+// Global is a global variable
+type Global struct {
+	Type    ValueType
+	Mutable bool
+
+	// Init is the encoded constant expression of the initial value including the final end
+	Init []byte
+}
+
+// ExportKind is the kind of definition exported
+type ExportKind byte
+
+const (
+	// FunctionExport exports a function
+	FunctionExport ExportKind = 0x00
+
+	// TableExport exports a table
+	TableExport ExportKind = 0x01
+
+	// MemoryExport exports a memory
+	MemoryExport ExportKind = 0x02
+
+	// GlobalExport exports a global
+	GlobalExport ExportKind = 0x03
+)
+
+// Export makes a definition of the module visible to the host
+type Export struct {
+	Name  string
+	Kind  ExportKind
+	Index uint32
+}
+
+// Module is a WebAssembly module
+type Module struct {
+	Types     []FuncType
+	Functions []Function
+	Memories  []Memory
+	Globals   []Global
+	Exports   []Export
+}
+
+// AddType returns the index of the type in the module adding it if required
+func (m *Module) AddType(t FuncType) uint32 {
+	for i, existing := range m.Types {
+		if existing.Equal(t) {
+			return uint32(i)
+		}
+	}
+	m.Types = append(m.Types, t)
+	return uint32(len(m.Types) - 1)
+}
+
+// Export finds the export with the given name
+func (m *Module) Export(name string) (Export, bool) {
+	for _, export := range m.Exports {
+		if export.Name == name {
+			return export, true
+		}
+	}
+	return Export{}, false
+}
+
+// FunctionType returns the type of the function with the given index
+func (m *Module) FunctionType(index uint32) (FuncType, bool) {
+	if int(index) >= len(m.Functions) {
+		return FuncType{}, false
+	}
+	typeIndex := m.Functions[index].Type
+	if int(typeIndex) >= len(m.Types) {
+		return FuncType{}, false
+	}
+	return m.Types[typeIndex], true
+}
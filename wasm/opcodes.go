@@ -0,0 +1,214 @@
+package wasm
+
+// Opcodes of the control and variable instructions that are validated specially
+const (
+	opUnreachable  byte = 0x00
+	opNop          byte = 0x01
+	opBlock        byte = 0x02
+	opLoop         byte = 0x03
+	opIf           byte = 0x04
+	opElse         byte = 0x05
+	opEnd          byte = 0x0B
+	opBr           byte = 0x0C
+	opBrIf         byte = 0x0D
+	opBrTable      byte = 0x0E
+	opReturn       byte = 0x0F
+	opCall         byte = 0x10
+	opCallIndirect byte = 0x11
+	opDrop         byte = 0x1A
+	opSelect       byte = 0x1B
+	opLocalGet     byte = 0x20
+	opLocalSet     byte = 0x21
+	opLocalTee     byte = 0x22
+	opGlobalGet    byte = 0x23
+	opGlobalSet    byte = 0x24
+	opMemorySize   byte = 0x3F
+	opMemoryGrow   byte = 0x40
+	opI32Const     byte = 0x41
+	opI64Const     byte = 0x42
+	opF32Const     byte = 0x43
+	opF64Const     byte = 0x44
+)
+
+// emptyBlock is the block type of a block that produces no value
+const emptyBlock byte = 0x40
+
+// immediate is the kind of immediate operands that follow an opcode
+type immediate int
+
+const (
+	noImmediate immediate = iota
+	blockImmediate
+	indexImmediate
+	brTableImmediate
+	callIndirectImmediate
+	memoryImmediate
+	reservedImmediate
+	i32Immediate
+	i64Immediate
+	f32Immediate
+	f64Immediate
+)
+
+// opcode describes an instruction
+type opcode struct {
+	name      string
+	immediate immediate
+
+	// params and results are the operands consumed and produced by instructions with a
+	// fixed signature. Control and variable instructions are validated specially.
+	params  []ValueType
+	results []ValueType
+
+	// align is the natural alignment, as a power of two, of memory instructions
+	align uint32
+}
+
+var opcodes = map[byte]*opcode{}
+
+func op(code byte, name string, immediate immediate, params, results []ValueType) {
+	opcodes[code] = &opcode{name: name, immediate: immediate, params: params, results: results}
+}
+
+func memoryOp(code byte, name string, align uint32, params, results []ValueType) {
+	opcodes[code] = &opcode{name: name, immediate: memoryImmediate, params: params, results: results, align: align}
+}
+
+func numeric(first byte, prefix string, params, results []ValueType, names ...string) {
+	for i, name := range names {
+		op(first+byte(i), prefix+name, noImmediate, params, results)
+	}
+}
+
+func init() {
+	var (
+		none = []ValueType{}
+		i32  = []ValueType{I32}
+		i64  = []ValueType{I64}
+		f32  = []ValueType{F32}
+		f64  = []ValueType{F64}
+	)
+	pair := func(t ValueType) []ValueType { return []ValueType{t, t} }
+
+	op(opUnreachable, "unreachable", noImmediate, nil, nil)
+	op(opNop, "nop", noImmediate, none, none)
+	op(opBlock, "block", blockImmediate, nil, nil)
+	op(opLoop, "loop", blockImmediate, nil, nil)
+	op(opIf, "if", blockImmediate, nil, nil)
+	op(opElse, "else", noImmediate, nil, nil)
+	op(opEnd, "end", noImmediate, nil, nil)
+	op(opBr, "br", indexImmediate, nil, nil)
+	op(opBrIf, "br_if", indexImmediate, nil, nil)
+	op(opBrTable, "br_table", brTableImmediate, nil, nil)
+	op(opReturn, "return", noImmediate, nil, nil)
+	op(opCall, "call", indexImmediate, nil, nil)
+	op(opCallIndirect, "call_indirect", callIndirectImmediate, nil, nil)
+	op(opDrop, "drop", noImmediate, nil, nil)
+	op(opSelect, "select", noImmediate, nil, nil)
+	op(opLocalGet, "local.get", indexImmediate, nil, nil)
+	op(opLocalSet, "local.set", indexImmediate, nil, nil)
+	op(opLocalTee, "local.tee", indexImmediate, nil, nil)
+	op(opGlobalGet, "global.get", indexImmediate, nil, nil)
+	op(opGlobalSet, "global.set", indexImmediate, nil, nil)
+
+	memoryOp(0x28, "i32.load", 2, i32, i32)
+	memoryOp(0x29, "i64.load", 3, i32, i64)
+	memoryOp(0x2A, "f32.load", 2, i32, f32)
+	memoryOp(0x2B, "f64.load", 3, i32, f64)
+	memoryOp(0x2C, "i32.load8_s", 0, i32, i32)
+	memoryOp(0x2D, "i32.load8_u", 0, i32, i32)
+	memoryOp(0x2E, "i32.load16_s", 1, i32, i32)
+	memoryOp(0x2F, "i32.load16_u", 1, i32, i32)
+	memoryOp(0x30, "i64.load8_s", 0, i32, i64)
+	memoryOp(0x31, "i64.load8_u", 0, i32, i64)
+	memoryOp(0x32, "i64.load16_s", 1, i32, i64)
+	memoryOp(0x33, "i64.load16_u", 1, i32, i64)
+	memoryOp(0x34, "i64.load32_s", 2, i32, i64)
+	memoryOp(0x35, "i64.load32_u", 2, i32, i64)
+	memoryOp(0x36, "i32.store", 2, pair(I32), none)
+	memoryOp(0x37, "i64.store", 3, []ValueType{I32, I64}, none)
+	memoryOp(0x38, "f32.store", 2, []ValueType{I32, F32}, none)
+	memoryOp(0x39, "f64.store", 3, []ValueType{I32, F64}, none)
+	memoryOp(0x3A, "i32.store8", 0, pair(I32), none)
+	memoryOp(0x3B, "i32.store16", 1, pair(I32), none)
+	memoryOp(0x3C, "i64.store8", 0, []ValueType{I32, I64}, none)
+	memoryOp(0x3D, "i64.store16", 1, []ValueType{I32, I64}, none)
+	memoryOp(0x3E, "i64.store32", 2, []ValueType{I32, I64}, none)
+	op(opMemorySize, "memory.size", reservedImmediate, none, i32)
+	op(opMemoryGrow, "memory.grow", reservedImmediate, i32, i32)
+
+	op(opI32Const, "i32.const", i32Immediate, none, i32)
+	op(opI64Const, "i64.const", i64Immediate, none, i64)
+	op(opF32Const, "f32.const", f32Immediate, none, f32)
+	op(opF64Const, "f64.const", f64Immediate, none, f64)
+
+	numeric(0x45, "i32.", i32, i32, "eqz")
+	numeric(0x46, "i32.", pair(I32), i32,
+		"eq", "ne", "lt_s", "lt_u", "gt_s", "gt_u", "le_s", "le_u", "ge_s", "ge_u")
+	numeric(0x50, "i64.", i64, i32, "eqz")
+	numeric(0x51, "i64.", pair(I64), i32,
+		"eq", "ne", "lt_s", "lt_u", "gt_s", "gt_u", "le_s", "le_u", "ge_s", "ge_u")
+	numeric(0x5B, "f32.", pair(F32), i32, "eq", "ne", "lt", "gt", "le", "ge")
+	numeric(0x61, "f64.", pair(F64), i32, "eq", "ne", "lt", "gt", "le", "ge")
+
+	numeric(0x67, "i32.", i32, i32, "clz", "ctz", "popcnt")
+	numeric(0x6A, "i32.", pair(I32), i32,
+		"add", "sub", "mul", "div_s", "div_u", "rem_s", "rem_u",
+		"and", "or", "xor", "shl", "shr_s", "shr_u", "rotl", "rotr")
+	numeric(0x79, "i64.", i64, i64, "clz", "ctz", "popcnt")
+	numeric(0x7C, "i64.", pair(I64), i64,
+		"add", "sub", "mul", "div_s", "div_u", "rem_s", "rem_u",
+		"and", "or", "xor", "shl", "shr_s", "shr_u", "rotl", "rotr")
+	numeric(0x8B, "f32.", f32, f32, "abs", "neg", "ceil", "floor", "trunc", "nearest", "sqrt")
+	numeric(0x92, "f32.", pair(F32), f32, "add", "sub", "mul", "div", "min", "max", "copysign")
+	numeric(0x99, "f64.", f64, f64, "abs", "neg", "ceil", "floor", "trunc", "nearest", "sqrt")
+	numeric(0xA0, "f64.", pair(F64), f64, "add", "sub", "mul", "div", "min", "max", "copysign")
+
+	numeric(0xA7, "i32.", i64, i32, "wrap_i64")
+	numeric(0xA8, "i32.", f32, i32, "trunc_f32_s", "trunc_f32_u")
+	numeric(0xAA, "i32.", f64, i32, "trunc_f64_s", "trunc_f64_u")
+	numeric(0xAC, "i64.", i32, i64, "extend_i32_s", "extend_i32_u")
+	numeric(0xAE, "i64.", f32, i64, "trunc_f32_s", "trunc_f32_u")
+	numeric(0xB0, "i64.", f64, i64, "trunc_f64_s", "trunc_f64_u")
+	numeric(0xB2, "f32.", i32, f32, "convert_i32_s", "convert_i32_u")
+	numeric(0xB4, "f32.", i64, f32, "convert_i64_s", "convert_i64_u")
+	numeric(0xB6, "f32.", f64, f32, "demote_f64")
+	numeric(0xB7, "f64.", i32, f64, "convert_i32_s", "convert_i32_u")
+	numeric(0xB9, "f64.", i64, f64, "convert_i64_s", "convert_i64_u")
+	numeric(0xBB, "f64.", f32, f64, "promote_f32")
+	numeric(0xBC, "i32.", f32, i32, "reinterpret_f32")
+	numeric(0xBD, "i64.", f64, i64, "reinterpret_f64")
+	numeric(0xBE, "f32.", i32, f32, "reinterpret_i32")
+	numeric(0xBF, "f64.", i64, f64, "reinterpret_i64")
+	numeric(0xC0, "i32.", i32, i32, "extend8_s", "extend16_s")
+	numeric(0xC2, "i64.", i64, i64, "extend8_s", "extend16_s", "extend32_s")
+}
+
+// OpcodeInfo describes an instruction
+type OpcodeInfo struct {
+	Name string
+
+	// Fixed is true if the instruction always consumes Params and produces Results. Control and
+	// variable instructions, and the parametric instructions drop and select, are not fixed.
+	Fixed   bool
+	Params  []ValueType
+	Results []ValueType
+
+	// Memory is true if the instruction accesses memory
+	Memory bool
+}
+
+// LookupOpcode describes the instruction with the given opcode
+func LookupOpcode(code byte) (OpcodeInfo, bool) {
+	op, ok := opcodes[code]
+	if !ok {
+		return OpcodeInfo{}, false
+	}
+	return OpcodeInfo{
+		Name:    op.name,
+		Fixed:   op.params != nil,
+		Params:  op.params,
+		Results: op.results,
+		Memory:  op.immediate == memoryImmediate || op.immediate == reservedImmediate,
+	}, true
+}
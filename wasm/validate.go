@@ -0,0 +1,309 @@
+package wasm
+
+import (
+	"fmt"
+)
+
+// unknown is the type of an operand popped from the polymorphic stack of unreachable code
+const unknown ValueType = 0
+
+// frame is a block being validated
+type frame struct {
+	opcode      byte
+	results     []ValueType
+	height      int
+	unreachable bool
+}
+
+// labelTypes are the types of the operands a branch to the frame takes
+func (f *frame) labelTypes() []ValueType {
+	if f.opcode == opLoop {
+		return nil
+	}
+	return f.results
+}
+
+// validator type checks the instructions of a function
+type validator struct {
+	module   *Module
+	locals   []ValueType
+	results  []ValueType
+	operands []ValueType
+	frames   []*frame
+	position int
+}
+
+func (v *validator) fail(message string, args ...interface{}) {
+	panic(&decodeError{fmt.Errorf("%s at instruction %d", fmt.Sprintf(message, args...), v.position)})
+}
+
+func (v *validator) push(t ValueType) {
+	v.operands = append(v.operands, t)
+}
+
+func (v *validator) pushAll(types []ValueType) {
+	for _, t := range types {
+		v.push(t)
+	}
+}
+
+func (v *validator) pop() ValueType {
+	top := v.frames[len(v.frames)-1]
+	if len(v.operands) == top.height {
+		if top.unreachable {
+			return unknown
+		}
+		v.fail("Expected an operand on the stack")
+	}
+	result := v.operands[len(v.operands)-1]
+	v.operands = v.operands[:len(v.operands)-1]
+	return result
+}
+
+func (v *validator) popExpected(expected ValueType) ValueType {
+	actual := v.pop()
+	if actual == unknown {
+		return expected
+	}
+	if expected != unknown && actual != expected {
+		v.fail("Expected an operand of type %s but found %s", expected, actual)
+	}
+	return actual
+}
+
+func (v *validator) popAll(types []ValueType) {
+	for i := len(types) - 1; i >= 0; i-- {
+		v.popExpected(types[i])
+	}
+}
+
+func (v *validator) pushFrame(opcode byte, results []ValueType) {
+	v.frames = append(v.frames, &frame{opcode: opcode, results: results, height: len(v.operands)})
+}
+
+func (v *validator) popFrame() *frame {
+	top := v.frames[len(v.frames)-1]
+	v.popAll(top.results)
+	if len(v.operands) != top.height {
+		v.fail("Unexpected operands at the end of a block")
+	}
+	v.frames = v.frames[:len(v.frames)-1]
+	return top
+}
+
+func (v *validator) setUnreachable() {
+	top := v.frames[len(v.frames)-1]
+	v.operands = v.operands[:top.height]
+	top.unreachable = true
+}
+
+func (v *validator) label(index uint32) *frame {
+	if int(index) >= len(v.frames) {
+		v.fail("Invalid label %d", index)
+	}
+	return v.frames[len(v.frames)-1-int(index)]
+}
+
+func (v *validator) local(index uint32) ValueType {
+	if int(index) >= len(v.locals) {
+		v.fail("Invalid local %d", index)
+	}
+	return v.locals[index]
+}
+
+func (v *validator) global(index uint32) Global {
+	if int(index) >= len(v.module.Globals) {
+		v.fail("Invalid global %d", index)
+	}
+	return v.module.Globals[index]
+}
+
+func blockResults(block byte) []ValueType {
+	if block == emptyBlock {
+		return nil
+	}
+	return []ValueType{ValueType(block)}
+}
+
+func (v *validator) instruction(instruction Instruction) {
+	op := opcodes[instruction.Opcode]
+	switch instruction.Opcode {
+	case opUnreachable:
+		v.setUnreachable()
+	case opBlock, opLoop:
+		v.pushFrame(instruction.Opcode, blockResults(instruction.Block))
+	case opIf:
+		v.popExpected(I32)
+		v.pushFrame(opIf, blockResults(instruction.Block))
+	case opElse:
+		top := v.popFrame()
+		if top.opcode != opIf {
+			v.fail("else without an if")
+		}
+		v.pushFrame(opElse, top.results)
+	case opEnd:
+		top := v.popFrame()
+		if top.opcode == opIf && len(top.results) > 0 {
+			v.fail("if without an else must not produce a value")
+		}
+		v.pushAll(top.results)
+	case opBr:
+		v.popAll(v.label(instruction.Index).labelTypes())
+		v.setUnreachable()
+	case opBrIf:
+		v.popExpected(I32)
+		types := v.label(instruction.Index).labelTypes()
+		v.popAll(types)
+		v.pushAll(types)
+	case opBrTable:
+		v.popExpected(I32)
+		types := v.label(instruction.Index).labelTypes()
+		for _, label := range instruction.Labels {
+			if !sameValueTypes(v.label(label).labelTypes(), types) {
+				v.fail("Inconsistent br_table label types")
+			}
+		}
+		v.popAll(types)
+		v.setUnreachable()
+	case opReturn:
+		v.popAll(v.results)
+		v.setUnreachable()
+	case opCall:
+		t, ok := v.module.FunctionType(instruction.Index)
+		if !ok {
+			v.fail("Invalid function %d", instruction.Index)
+		}
+		v.popAll(t.Params)
+		v.pushAll(t.Results)
+	case opCallIndirect:
+		v.fail("call_indirect requires a table")
+	case opDrop:
+		v.pop()
+	case opSelect:
+		v.popExpected(I32)
+		t := v.pop()
+		v.push(v.popExpected(t))
+	case opLocalGet:
+		v.push(v.local(instruction.Index))
+	case opLocalSet:
+		v.popExpected(v.local(instruction.Index))
+	case opLocalTee:
+		t := v.local(instruction.Index)
+		v.popExpected(t)
+		v.push(t)
+	case opGlobalGet:
+		v.push(v.global(instruction.Index).Type)
+	case opGlobalSet:
+		global := v.global(instruction.Index)
+		if !global.Mutable {
+			v.fail("Global %d is immutable", instruction.Index)
+		}
+		v.popExpected(global.Type)
+	default:
+		if op.immediate == memoryImmediate || op.immediate == reservedImmediate {
+			if len(v.module.Memories) == 0 {
+				v.fail("%s requires a memory", op.name)
+			}
+			if instruction.Index > op.align && op.immediate == memoryImmediate {
+				v.fail("Alignment of %s must not be larger than natural", op.name)
+			}
+		}
+		v.popAll(op.params)
+		v.pushAll(op.results)
+	}
+}
+
+// validateFunction type checks the body of the function with the given index
+func (m *Module) validateFunction(index int) (err error) {
+	v := &validator{module: m}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*decodeError)
+			if !ok {
+				panic(r)
+			}
+			err = fmt.Errorf("wasm: function %d: %s", index, e.err)
+		}
+	}()
+	f := m.Functions[index]
+	t, ok := m.FunctionType(uint32(index))
+	if !ok {
+		v.fail("Invalid type %d", f.Type)
+	}
+	instructions, err := DecodeInstructions(f.Body)
+	if err != nil {
+		return err
+	}
+	v.locals = append(append([]ValueType{}, t.Params...), f.Locals...)
+	v.results = t.Results
+	v.pushFrame(opBlock, t.Results)
+	for i, instruction := range instructions {
+		v.position = i
+		if len(v.frames) == 0 {
+			v.fail("Instructions after the end of the function")
+		}
+		v.instruction(instruction)
+	}
+	if len(v.frames) != 0 {
+		v.fail("Expected the function to end")
+	}
+	return nil
+}
+
+// validateConstant checks a constant expression produces a value of the given type
+func (m *Module) validateConstant(code []byte, t ValueType) error {
+	instructions, err := DecodeInstructions(code)
+	if err != nil {
+		return err
+	}
+	if len(instructions) != 2 || instructions[1].Opcode != opEnd {
+		return fmt.Errorf("Expected a constant expression")
+	}
+	op := opcodes[instructions[0].Opcode]
+	if op.immediate < i32Immediate || len(op.results) != 1 || op.results[0] != t {
+		return fmt.Errorf("Expected a constant of type %s", t)
+	}
+	return nil
+}
+
+// Validate type checks the module
+func (m *Module) Validate() error {
+	if len(m.Memories) > 1 {
+		return fmt.Errorf("wasm: At most one memory is allowed")
+	}
+	for i, memory := range m.Memories {
+		if memory.HasMax && memory.Max < memory.Min {
+			return fmt.Errorf("wasm: memory %d: Maximum is less than the minimum", i)
+		}
+	}
+	for i, global := range m.Globals {
+		if err := m.validateConstant(global.Init, global.Type); err != nil {
+			return fmt.Errorf("wasm: global %d: %s", i, err)
+		}
+	}
+	names := make(map[string]bool)
+	for _, export := range m.Exports {
+		if names[export.Name] {
+			return fmt.Errorf("wasm: Duplicate export %s", export.Name)
+		}
+		names[export.Name] = true
+		var count int
+		switch export.Kind {
+		case FunctionExport:
+			count = len(m.Functions)
+		case MemoryExport:
+			count = len(m.Memories)
+		case GlobalExport:
+			count = len(m.Globals)
+		}
+		if int(export.Index) >= count {
+			return fmt.Errorf("wasm: Export %s refers to an invalid index %d", export.Name, export.Index)
+		}
+	}
+	for i := range m.Functions {
+		if err := m.validateFunction(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package wasm_test
+
+import (
+	"testing"
+
+	"dyego0/wasm"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("wasm", func() {
+	code := func(block func(e *wasm.Encoder)) []byte {
+		e := &wasm.Encoder{}
+		block(e)
+		return e.Result()
+	}
+	module := func(t wasm.FuncType, locals []wasm.ValueType, body []byte) *wasm.Module {
+		m := &wasm.Module{}
+		m.Functions = append(m.Functions, wasm.Function{Type: m.AddType(t), Locals: locals, Body: body})
+		m.Memories = append(m.Memories, wasm.Memory{Min: 1})
+		m.Exports = append(m.Exports, wasm.Export{Name: "f", Kind: wasm.FunctionExport, Index: 0})
+		return m
+	}
+	roundTrip := func(m *wasm.Module) *wasm.Module {
+		result, err := wasm.Decode(m.Encode())
+		Expect(err).To(BeNil())
+		return result
+	}
+	instructions := func(body []byte) []string {
+		decoded, err := wasm.DecodeInstructions(body)
+		Expect(err).To(BeNil())
+		var result []string
+		for _, instruction := range decoded {
+			result = append(result, instruction.String())
+		}
+		return result
+	}
+	Describe("encoder", func() {
+		It("can encode unsigned integers", func() {
+			Expect(code(func(e *wasm.Encoder) { e.U32(0) })).To(Equal([]byte{0x00}))
+			Expect(code(func(e *wasm.Encoder) { e.U32(127) })).To(Equal([]byte{0x7F}))
+			Expect(code(func(e *wasm.Encoder) { e.U32(128) })).To(Equal([]byte{0x80, 0x01}))
+			Expect(code(func(e *wasm.Encoder) { e.U32(624485) })).To(Equal([]byte{0xE5, 0x8E, 0x26}))
+		})
+		It("can encode signed integers", func() {
+			Expect(code(func(e *wasm.Encoder) { e.I32(0) })).To(Equal([]byte{0x00}))
+			Expect(code(func(e *wasm.Encoder) { e.I32(-1) })).To(Equal([]byte{0x7F}))
+			Expect(code(func(e *wasm.Encoder) { e.I32(63) })).To(Equal([]byte{0x3F}))
+			Expect(code(func(e *wasm.Encoder) { e.I32(64) })).To(Equal([]byte{0xC0, 0x00}))
+			Expect(code(func(e *wasm.Encoder) { e.I64(-123456) })).To(Equal([]byte{0xC0, 0xBB, 0x78}))
+		})
+		It("can encode an empty module", func() {
+			m := &wasm.Module{}
+			Expect(m.Encode()).To(Equal([]byte{0x00, 0x61, 0x73, 0x6D, 0x01, 0x00, 0x00, 0x00}))
+		})
+	})
+	Describe("decoder", func() {
+		It("can decode an encoded module", func() {
+			body := code(func(e *wasm.Encoder) {
+				e.Bytes([]byte{0x20, 0x00, 0x20, 0x01, 0x6A, 0x0B})
+			})
+			m := module(wasm.FuncType{Params: []wasm.ValueType{wasm.I32, wasm.I32}, Results: []wasm.ValueType{wasm.I32}},
+				[]wasm.ValueType{wasm.I64, wasm.I64, wasm.F64}, body)
+			m.Globals = append(m.Globals, wasm.Global{Type: wasm.I32, Mutable: true, Init: []byte{0x41, 0x10, 0x0B}})
+			m.Exports = append(m.Exports, wasm.Export{Name: "memory", Kind: wasm.MemoryExport})
+			d := roundTrip(m)
+			Expect(d).To(Equal(m))
+			Expect(d.Validate()).To(BeNil())
+		})
+		It("can decode instructions", func() {
+			body := code(func(e *wasm.Encoder) {
+				e.Byte(0x41)
+				e.I32(-5)
+				e.Byte(0x44)
+				e.F64(1.5)
+				e.Bytes([]byte{0x1A, 0x02, 0x40, 0x0C, 0x00, 0x0B, 0x28, 0x02, 0x08, 0x0B})
+			})
+			Expect(instructions(body)).To(Equal([]string{
+				"i32.const -5", "f64.const 1.5", "drop", "block", "br 0", "end", "i32.load offset=8", "end",
+			}))
+		})
+		It("reports an invalid magic number", func() {
+			_, err := wasm.Decode([]byte{0x00, 0x61, 0x73, 0x6E, 0x01, 0x00, 0x00, 0x00})
+			Expect(err).To(MatchError("wasm: Invalid magic number at offset 0x4"))
+		})
+		It("reports a truncated module", func() {
+			m := module(wasm.FuncType{}, nil, []byte{0x0B})
+			data := m.Encode()
+			_, err := wasm.Decode(data[:len(data)-1])
+			Expect(err).To(Not(BeNil()))
+		})
+		It("reports an unknown opcode", func() {
+			_, err := wasm.DecodeInstructions([]byte{0x01, 0xFF})
+			Expect(err).To(MatchError("wasm: Unknown opcode 0xFF at offset 0x1"))
+		})
+		It("reports sections out of order", func() {
+			data := []byte{0x00, 0x61, 0x73, 0x6D, 0x01, 0x00, 0x00, 0x00, 0x03, 0x01, 0x00, 0x01, 0x01, 0x00}
+			_, err := wasm.Decode(data)
+			Expect(err).To(MatchError("wasm: Section 1 out of order at offset 0xB"))
+		})
+	})
+	Describe("validation", func() {
+		i32 := []wasm.ValueType{wasm.I32}
+		validate := func(t wasm.FuncType, locals []wasm.ValueType, body ...byte) error {
+			return roundTrip(module(t, locals, body)).Validate()
+		}
+		It("accepts a valid function", func() {
+			// if (local 0) { 1 } else { 2 }
+			Expect(validate(wasm.FuncType{Params: i32, Results: i32}, nil,
+				0x20, 0x00, 0x04, 0x7F, 0x41, 0x01, 0x05, 0x41, 0x02, 0x0B, 0x0B)).To(BeNil())
+		})
+		It("accepts unreachable code after a branch", func() {
+			Expect(validate(wasm.FuncType{Results: i32}, nil,
+				0x02, 0x7F, 0x41, 0x01, 0x0C, 0x00, 0x6A, 0x0B, 0x0B)).To(BeNil())
+			Expect(validate(wasm.FuncType{Results: i32}, nil, 0x00, 0x0B)).To(BeNil())
+		})
+		It("accepts a loop", func() {
+			Expect(validate(wasm.FuncType{}, i32,
+				0x02, 0x40, 0x03, 0x40, 0x20, 0x00, 0x0D, 0x01, 0x0C, 0x00, 0x0B, 0x0B, 0x0B)).To(BeNil())
+		})
+		It("rejects mismatched operands", func() {
+			Expect(validate(wasm.FuncType{Results: i32}, nil, 0x42, 0x01, 0x0B)).To(MatchError(
+				"wasm: function 0: Expected an operand of type i32 but found i64 at instruction 1"))
+		})
+		It("rejects a missing operand", func() {
+			Expect(validate(wasm.FuncType{Results: i32}, nil, 0x41, 0x01, 0x6A, 0x0B)).To(MatchError(
+				"wasm: function 0: Expected an operand on the stack at instruction 1"))
+		})
+		It("rejects extra operands", func() {
+			Expect(validate(wasm.FuncType{}, nil, 0x41, 0x01, 0x0B)).To(MatchError(
+				"wasm: function 0: Unexpected operands at the end of a block at instruction 1"))
+		})
+		It("rejects an invalid label", func() {
+			Expect(validate(wasm.FuncType{}, nil, 0x0C, 0x01, 0x0B)).To(MatchError(
+				"wasm: function 0: Invalid label 1 at instruction 0"))
+		})
+		It("rejects an invalid local", func() {
+			Expect(validate(wasm.FuncType{}, nil, 0x20, 0x00, 0x1A, 0x0B)).To(MatchError(
+				"wasm: function 0: Invalid local 0 at instruction 0"))
+		})
+		It("rejects a function without an end", func() {
+			Expect(validate(wasm.FuncType{}, nil, 0x01)).To(MatchError(
+				"wasm: function 0: Expected the function to end at instruction 0"))
+		})
+		It("rejects a call to an undefined function", func() {
+			Expect(validate(wasm.FuncType{}, nil, 0x10, 0x01, 0x0B)).To(MatchError(
+				"wasm: function 0: Invalid function 1 at instruction 0"))
+		})
+		It("rejects an over aligned load", func() {
+			Expect(validate(wasm.FuncType{Results: i32}, nil, 0x41, 0x00, 0x28, 0x03, 0x00, 0x0B)).To(MatchError(
+				"wasm: function 0: Alignment of i32.load must not be larger than natural at instruction 1"))
+		})
+		It("rejects a duplicate export", func() {
+			m := module(wasm.FuncType{}, nil, []byte{0x0B})
+			m.Exports = append(m.Exports, m.Exports[0])
+			Expect(m.Validate()).To(MatchError("wasm: Duplicate export f"))
+		})
+	})
+})
+
+func TestWasm(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Wasm Suite")
+}